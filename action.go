@@ -0,0 +1,81 @@
+package fsm
+
+import (
+	"context"
+	"github.com/reyoung/delegate"
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+// ActionChain returns an action which invokes the actions in order. It stops and returns at the
+// first error.
+func ActionChain(actions ...func(interface{}, Event) error) func(interface{}, Event) error {
+	return func(payload interface{}, ev Event) error {
+		for _, action := range actions {
+			if err := action(payload, ev); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// ActionMiddleware wraps the actions of all transitions. The middleware should invoke `next` to run the
+// wrapped action (and the inner middlewares), and it can modify the returned error, e.g., a retry
+// middleware may invoke `next` many times.
+type ActionMiddleware func(args ActionHookArgs, next func() error) error
+
+// UseActionMiddleware appends a middleware to the FSM. The first used middleware is the outermost one.
+func (fsm *FSM) UseActionMiddleware(mw ActionMiddleware) {
+	fsm.actionMiddlewares = append(fsm.actionMiddlewares, mw)
+}
+
+// SetGlobalActionHooks enables or disables `GlobalBeforeAction` and `GlobalAfterAction`, they are enabled by
+// default. The hooks receive the `ActionHookArgs` boxed into an interface, which is allocated on the heap only if
+// a hook is added.
+func (fsm *FSM) SetGlobalActionHooks(enabled bool) {
+	fsm.noGlobalHooks = !enabled
+}
+
+// the indices of the fields of `delegate.Delegate`, which does not expose whether a callback is added.
+var delegateLocker, delegateCallbacks = delegateField("locker"), delegateField("callbacks")
+
+func delegateField(name string) int {
+	field, ok := reflect.TypeOf((*delegate.Delegate)(nil)).Elem().FieldByName(name)
+	if !ok {
+		return -1
+	}
+	return field.Index[0]
+}
+
+// hasHooks returns true if a callback is added to the delegate d. It is true if the fields of the delegate are
+// unknown.
+func hasHooks(d *delegate.Delegate) bool {
+	if delegateLocker < 0 || delegateCallbacks < 0 {
+		return true
+	}
+	v := reflect.ValueOf(d).Elem()
+	locker := (*sync.RWMutex)(unsafe.Pointer(v.Field(delegateLocker).UnsafeAddr()))
+	locker.RLock()
+	defer locker.RUnlock()
+	return v.Field(delegateCallbacks).Len() != 0
+}
+
+// runAction invokes the action of t through the middlewares.
+func (fsm *FSM) runAction(ctx context.Context, t *transition, args ActionHookArgs) error {
+	if len(fsm.actionMiddlewares) == 0 {
+		// without the closures below, which escape to the heap.
+		return fsm.invokeAction(ctx, t, args)
+	}
+	next := func() error {
+		return fsm.invokeAction(ctx, t, args)
+	}
+	for i := len(fsm.actionMiddlewares) - 1; i >= 0; i-- {
+		mw, inner := fsm.actionMiddlewares[i], next
+		next = func() error {
+			return mw(args, inner)
+		}
+	}
+	return next()
+}
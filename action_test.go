@@ -0,0 +1,58 @@
+package fsm
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestActionChain(t *testing.T) {
+	var records []string
+	record := func(name string, err error) func(interface{}, Event) error {
+		return func(interface{}, Event) error {
+			records = append(records, name)
+			return err
+		}
+	}
+	assert.Nil(t, ActionChain()(nil, StringEvent("ev")))
+	assert.Nil(t, ActionChain(record("a", nil), record("b", nil))(nil, StringEvent("ev")))
+	assert.EqualError(t, ActionChain(record("c", errors.New("c failed")), record("d", nil))(nil,
+		StringEvent("ev")), "c failed")
+	assert.Equal(t, []string{"a", "b", "c"}, records)
+}
+
+func TestActionMiddleware(t *testing.T) {
+	var (
+		on  = StringState("on")
+		off = StringState("off")
+	)
+	fsm := NewFSM(off, nil)
+	assert.Nil(t, fsm.AddState(on))
+	assert.Nil(t, fsm.AddEvent("switch"))
+	failures := 2
+	assert.Nil(t, fsm.AddTransition(off, "switch", on, func(i interface{}, event Event) error {
+		if failures > 0 {
+			failures--
+			return errors.New("transient")
+		}
+		return nil
+	}, nil))
+
+	var records []string
+	fsm.UseActionMiddleware(func(args ActionHookArgs, next func() error) error {
+		records = append(records, "log "+args.FromState.FSMStateID()+"->"+args.ToState.FSMStateID())
+		return next()
+	})
+	fsm.UseActionMiddleware(func(args ActionHookArgs, next func() error) (err error) {
+		for i := 0; i < 3; i++ {
+			records = append(records, "try")
+			if err = next(); err == nil {
+				return nil
+			}
+		}
+		return err
+	})
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("switch")))
+	assert.Equal(t, on, fsm.CurrentState())
+	assert.Equal(t, []string{"log off->on", "try", "try", "try"}, records)
+}
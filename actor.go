@@ -0,0 +1,19 @@
+package fsm
+
+// ProcessEventAs processes ev attributed to actor, e.g., the user ID or the name of the service, so the audit
+// trails tell who or what triggers each transition. The actor is the `Actor` of the `Envelope` passed to the
+// actions, guards, observers, subscribers, and the debugger, see `WithActor`. The events caused by ev, i.e.,
+// `Envelope.Caused`, are attributed to the actor as well.
+func (fsm *FSM) ProcessEventAs(actor string, ev Event) error {
+	return fsm.ProcessEvent(WithActor(ev, actor))
+}
+
+// ProcessEventAs is the same as `FSM.ProcessEventAs`, but the event is queued.
+func (q *QueuedFSM) ProcessEventAs(actor string, ev Event) error {
+	return q.ProcessEvent(WithActor(ev, actor))
+}
+
+// ProcessEventAs is the same as `FSM.ProcessEventAs`, but the event may be preempted. See `PreemptiveFSM`.
+func (p *PreemptiveFSM) ProcessEventAs(actor string, ev Event) error {
+	return p.ProcessEvent(WithActor(ev, actor))
+}
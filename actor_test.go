@@ -0,0 +1,58 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestProcessEventAs(t *testing.T) {
+	fsm := newAccountFSM(NewFSM(StringState("open"), &account{}))
+	fsm.SetDebugRecording(10, nil)
+	changes, cancel := fsm.Subscribe()
+	defer cancel()
+
+	assert.Nil(t, fsm.ProcessEventAs("alice", &depositEvent{amount: 10}))
+	change := <-changes
+	assert.Equal(t, "alice", ActorOf(change.Event))
+	assert.Equal(t, 10, Unwrap(change.Event).(*depositEvent).amount)
+	frame, _ := fsm.Debugger().Frame()
+	assert.Equal(t, "alice", frame.Actor)
+
+	env := NewEnvelope(&depositEvent{amount: 5}, "api")
+	assert.Nil(t, fsm.ProcessEventAs("bob", env))
+	attributed, _ := EnvelopeOf((<-changes).Event)
+	assert.Equal(t, "bob", attributed.Actor)
+	assert.Equal(t, env.ID, attributed.ID)
+	assert.Equal(t, "api", attributed.Source)
+	assert.Empty(t, env.Actor)
+	assert.Equal(t, 15, fsm.Payload().(*account).balance)
+
+	assert.Nil(t, fsm.ProcessEvent(&depositEvent{amount: 1}))
+	assert.Empty(t, ActorOf((<-changes).Event))
+	frame, _ = fsm.Debugger().Frame()
+	assert.Empty(t, frame.Actor)
+}
+
+func TestActorOf(t *testing.T) {
+	env := WithActor(StringEvent("a"), "alice")
+	assert.Equal(t, env.ID, env.CorrelationID)
+	assert.Equal(t, "alice", ActorOf(env))
+	assert.Equal(t, "alice", ActorOf(CompletionEvent{Cause: env}))
+	assert.Equal(t, "alice", ActorOf(env.Caused(StringEvent("b"), "worker")))
+	assert.Empty(t, ActorOf(StringEvent("a")))
+}
+
+func TestQueuedProcessEventAs(t *testing.T) {
+	machine := NewQueuedFSM(StringState("off"), nil)
+	defer machine.Close()
+	assert.Nil(t, machine.AddEvent("switch"))
+	var actors []string
+	assert.Nil(t, machine.AddTransition(StringState("off"), "switch", StringState("off"),
+		func(payload interface{}, ev Event) error {
+			actors = append(actors, ActorOf(ev))
+			return nil
+		}, nil))
+	assert.Nil(t, machine.ProcessEventAs("scheduler", StringEvent("switch")))
+	assert.Nil(t, machine.ProcessEvent(StringEvent("switch")))
+	assert.Equal(t, []string{"scheduler", ""}, actors)
+}
@@ -0,0 +1,174 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// StuckAlarm is the notification of a machine staying in a state too long. See `AlarmIfStuck`.
+type StuckAlarm struct {
+	// Machine is the name of the FSM, see `SetName`.
+	Machine string
+	State   State
+	// Since is when the state was entered, and Dwell is the time spent in it when the alarm fires.
+	Since time.Time
+	Dwell time.Duration
+}
+
+// StuckEvent is the event of a `StuckAlarm` fired by `QueuedFSM.AlarmIfStuckEvent`.
+type StuckEvent struct {
+	ID string
+	StuckAlarm
+}
+
+func (e StuckEvent) FSMEventID() string {
+	return e.ID
+}
+
+// stuckAlarm is an alarm of `AlarmIfStuck`. The fields after mu are guarded by it, since the timer fires in
+// another goroutine.
+type stuckAlarm struct {
+	fsm      *FSM
+	state    string
+	maxDwell time.Duration
+	callback func(alarm StuckAlarm)
+
+	mu sync.Mutex
+	// active is true if the machine is in the state since `since`. gen identifies the visit, so a stopped timer
+	// which has fired does not alarm the next visit.
+	active bool
+	since  time.Time
+	gen    uint64
+	timer  Timer
+	closed bool
+}
+
+// AlarmIfStuck invokes callback if the machine stays in the state longer than maxDwell, e.g., to detect the
+// stuck orders or jobs without external monitoring queries:
+//
+//	_ = machine.AlarmIfStuck(StringState("shipping"), 48*time.Hour, func(alarm fsm.StuckAlarm) {
+//		log.Printf("%s stuck in %s for %s", alarm.Machine, alarm.State.FSMStateID(), alarm.Dwell)
+//	})
+//
+// The alarm starts when the state is entered, including by `Rollback`, `CompensateTo`, an aborted `Transaction`
+// and `Restore`, or now if the machine is in the state already.
+//   - The machine is in the state if `IsIn` returns true, so the transitions inside a composite state, and the
+//     self-transitions, do not restart the alarm. It is restarted when the state is left and entered again.
+//   - The callback is invoked at most once per visit, in the goroutine of the timer of the clock, see `SetClock`.
+//     It should not process events of the machine directly, see `QueuedFSM.AlarmIfStuckEvent` to fire an event.
+//
+// NOTE: like other modifications, it should not be invoked concurrently with `ProcessEvent`.
+func (fsm *FSM) AlarmIfStuck(state State, maxDwell time.Duration, callback func(alarm StuckAlarm)) error {
+	if !fsm.HasState(state) {
+		return stateNotFound(state)
+	}
+	if maxDwell <= 0 {
+		return errors.New(fmt.Sprintf("the max dwell of state %s should be positive", state.FSMStateID()))
+	}
+	if callback == nil {
+		return errors.New("the callback of the alarm should not be nil")
+	}
+	alarm := &stuckAlarm{fsm: fsm, state: state.FSMStateID(), maxDwell: maxDwell, callback: callback}
+	fsm.alarms = append(fsm.alarms, alarm)
+	if fsm.curState != "" {
+		alarm.update(fsm.isInLocked(alarm.state))
+	}
+	return nil
+}
+
+// isInLocked is `IsIn` for the callers holding curStateMu or processing events.
+func (fsm *FSM) isInLocked(state string) bool {
+	for _, leaf := range fsm.currentLeaves() {
+		if fsm.isDescendant(leaf, state) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAlarms starts or stops the alarms after the current states change.
+func (fsm *FSM) checkAlarms() {
+	for _, alarm := range fsm.alarms {
+		alarm.update(fsm.isInLocked(alarm.state))
+	}
+}
+
+// stopAlarms stops the timers of the alarms, and they never fire again.
+func (fsm *FSM) stopAlarms() {
+	for _, alarm := range fsm.alarms {
+		alarm.mu.Lock()
+		alarm.closed = true
+		alarm.stop()
+		alarm.mu.Unlock()
+	}
+}
+
+// update starts the timer when the state is entered, and stops it when the state is left.
+func (a *stuckAlarm) update(in bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if in == a.active || a.closed {
+		return
+	}
+	if !in {
+		a.stop()
+		return
+	}
+	a.active, a.since = true, a.fsm.clock.Now()
+	a.gen++
+	gen := a.gen
+	a.timer = a.fsm.clock.AfterFunc(a.maxDwell, func() {
+		a.fire(gen)
+	})
+}
+
+// stop stops the timer, the caller should hold mu.
+func (a *stuckAlarm) stop() {
+	a.active = false
+	a.gen++
+	if a.timer != nil {
+		a.timer.Stop()
+		a.timer = nil
+	}
+}
+
+func (a *stuckAlarm) fire(gen uint64) {
+	a.mu.Lock()
+	if gen != a.gen || !a.active || a.closed {
+		a.mu.Unlock()
+		return
+	}
+	a.timer = nil
+	alarm := StuckAlarm{Machine: a.fsm.Name(), State: a.fsm.states[a.state], Since: a.since}
+	alarm.Dwell = a.fsm.clock.Now().Sub(a.since)
+	a.mu.Unlock()
+	a.callback(alarm)
+}
+
+// AlarmIfStuckEvent is the same as `AlarmIfStuck`, but the alarm fires a `StuckEvent` of evId into the machine,
+// e.g., to escalate or cancel the stuck workflows by transitions:
+//
+//	_ = machine.AlarmIfStuckEvent(StringState("shipping"), 48*time.Hour, "escalate")
+//	_ = machine.AddTransition(StringState("shipping"), "escalate", StringState("escalated"), notify, nil)
+//
+// The event is processed after the queued events, so it may be rejected if the machine has left the state, and the
+// rejected event is sent to `SetDeadLetterSink`. The alarms are stopped by `Close`.
+func (q *QueuedFSM) AlarmIfStuckEvent(state State, maxDwell time.Duration, evId string) error {
+	if !q.HasEvent(evId) {
+		return eventNotFound(evId)
+	}
+	return q.AlarmIfStuck(state, maxDwell, func(alarm StuckAlarm) {
+		cancel := context.CancelFunc(func() {})
+		if !q.async.start(&cancel) {
+			return
+		}
+		// fire the event in a new goroutine, so the timers of the clock are not blocked.
+		go func() {
+			defer q.async.finish(&cancel)
+			_ = q.ProcessEvent(StuckEvent{ID: evId, StuckAlarm: alarm})
+		}()
+	})
+}
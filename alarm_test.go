@@ -0,0 +1,152 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAlarmIfStuck(t *testing.T) {
+	clock := &stepClock{}
+	machine := newShipmentFSM(t)
+	machine.SetName("order-1")
+	machine.SetClock(clock)
+	var alarms []StuckAlarm
+	assert.Nil(t, machine.AlarmIfStuck(StringState("shipping"), time.Hour, func(alarm StuckAlarm) {
+		alarms = append(alarms, alarm)
+	}))
+	assert.Equal(t, 0, clock.step())
+
+	assert.Nil(t, machine.ProcessEvent(StringEvent("ship")))
+	// the transitions inside the state do not restart the alarm.
+	assert.Nil(t, machine.ProcessEvent(StringEvent("inspect")))
+	assert.Nil(t, machine.ProcessEvent(StringEvent("track")))
+	assert.Equal(t, 1, clock.step())
+	if assert.Len(t, alarms, 1) {
+		assert.Equal(t, "order-1", alarms[0].Machine)
+		assert.Equal(t, StringState("shipping"), alarms[0].State)
+		assert.GreaterOrEqual(t, int64(alarms[0].Dwell), int64(0))
+	}
+	// the alarm fires once per visit.
+	assert.Equal(t, 0, clock.step())
+
+	assert.Nil(t, machine.ProcessEvent(StringEvent("deliver")))
+	assert.Equal(t, 0, clock.step())
+	assert.Len(t, alarms, 1)
+}
+
+func TestAlarmIfStuckLeft(t *testing.T) {
+	clock := &stepClock{}
+	machine := newShipmentFSM(t)
+	machine.SetClock(clock)
+	assert.Nil(t, machine.Start(StringState("shipping")))
+	fired := 0
+	assert.Nil(t, machine.AlarmIfStuck(StringState("in_transit"), time.Hour, func(StuckAlarm) {
+		fired++
+	}))
+	// the machine is in the state already, and leaves it before the alarm fires.
+	assert.Nil(t, machine.ProcessEvent(StringEvent("inspect")))
+	assert.Equal(t, 0, clock.step())
+	assert.Equal(t, 0, fired)
+
+	assert.NotNil(t, machine.AlarmIfStuck(StringState("unknown"), time.Hour, func(StuckAlarm) {}))
+	assert.NotNil(t, machine.AlarmIfStuck(StringState("shipping"), 0, func(StuckAlarm) {}))
+	assert.NotNil(t, machine.AlarmIfStuck(StringState("shipping"), time.Hour, nil))
+}
+
+// alarmIfStuckCreated alarms the machine stuck in "created", and ships it, so the alarm is stopped.
+func alarmIfStuckCreated(t *testing.T, machine *FSM, clock *stepClock) *int {
+	fired := 0
+	machine.SetClock(clock)
+	machine.SetRollbackLimit(4)
+	assert.Nil(t, machine.AlarmIfStuck(StringState("created"), time.Hour, func(StuckAlarm) {
+		fired++
+	}))
+	assert.Nil(t, machine.ProcessEvent(StringEvent("ship")))
+	return &fired
+}
+
+func TestAlarmIfStuckRollback(t *testing.T) {
+	clock := &stepClock{}
+	machine := newShipmentFSM(t)
+	fired := alarmIfStuckCreated(t, machine, clock)
+	assert.Nil(t, machine.Rollback())
+	assert.Equal(t, 1, clock.step())
+	assert.Equal(t, 1, *fired)
+}
+
+func TestAlarmIfStuckCompensateTo(t *testing.T) {
+	clock := &stepClock{}
+	machine := newShipmentFSM(t)
+	fired := alarmIfStuckCreated(t, machine, clock)
+	assert.Nil(t, machine.ProcessEvent(StringEvent("deliver")))
+	assert.Nil(t, machine.CompensateTo(StringState("created")))
+	assert.Equal(t, 1, clock.step())
+	assert.Equal(t, 1, *fired)
+}
+
+func TestAlarmIfStuckTransactionAborted(t *testing.T) {
+	clock := &stepClock{}
+	machine := newShipmentFSM(t)
+	machine.SetClock(clock)
+	fired := 0
+	assert.Nil(t, machine.AlarmIfStuck(StringState("created"), time.Hour, func(StuckAlarm) {
+		fired++
+	}))
+	assert.NotNil(t, machine.Transaction(func(tx *Tx) error {
+		assert.Nil(t, tx.ProcessEvent(StringEvent("ship")))
+		return errors.New("abort")
+	}))
+	assert.Equal(t, 1, clock.step())
+	assert.Equal(t, 1, fired)
+}
+
+func TestAlarmIfStuckRestore(t *testing.T) {
+	clock := &stepClock{}
+	machine := newShipmentFSM(t)
+	fired := alarmIfStuckCreated(t, machine, clock)
+	assert.Nil(t, machine.Restore([]State{StringState("created")}, 0))
+	assert.Equal(t, 1, clock.step())
+	assert.Equal(t, 1, *fired)
+}
+
+func TestAlarmIfStuckEvent(t *testing.T) {
+	clock := &stepClock{}
+	machine := NewQueuedFSM(StringState("created"), nil)
+	defer machine.Close()
+	machine.SetClock(clock)
+	assert.Nil(t, machine.AddState(StringState("shipping")))
+	assert.Nil(t, machine.AddState(StringState("escalated")))
+	assert.Nil(t, machine.AddEvent("ship"))
+	assert.Nil(t, machine.AddEvent("escalate"))
+	assert.Nil(t, machine.AddTransition(StringState("created"), "ship", StringState("shipping"), nil, nil))
+	escalated := make(chan StuckEvent, 1)
+	assert.Nil(t, machine.AddTransition(StringState("shipping"), "escalate", StringState("escalated"),
+		func(payload interface{}, ev Event) error {
+			escalated <- ev.(StuckEvent)
+			return nil
+		}, nil))
+	assert.NotNil(t, machine.AlarmIfStuckEvent(StringState("shipping"), time.Hour, "unknown"))
+	assert.Nil(t, machine.AlarmIfStuckEvent(StringState("shipping"), time.Hour, "escalate"))
+
+	assert.Nil(t, machine.ProcessEvent(StringEvent("ship")))
+	assert.Equal(t, 1, clock.step())
+	ev := <-escalated
+	assert.Equal(t, StringState("shipping"), ev.State)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.Nil(t, machine.WaitForState(ctx, StringState("escalated")))
+}
+
+func TestAlarmIfStuckEventClosed(t *testing.T) {
+	clock := &stepClock{}
+	machine := NewQueuedFSM(StringState("shipping"), nil)
+	machine.SetClock(clock)
+	assert.Nil(t, machine.AddEvent("escalate"))
+	assert.Nil(t, machine.AlarmIfStuckEvent(StringState("shipping"), time.Hour, "escalate"))
+	assert.Nil(t, machine.Close())
+	// the alarms are stopped by Close.
+	assert.Equal(t, 0, clock.step())
+}
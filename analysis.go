@@ -0,0 +1,172 @@
+package fsm
+
+// transitionGraph is the static transition graph of the FSM, the guards are ignored. A state inherits the
+// transitions of the composite states containing it, and a transition enters the initial child of the composite
+// state it targets. The history states are treated as their composite states.
+type transitionGraph struct {
+	fsm *FSM
+	// state -> transitions, sorted like `FSM.Transitions`.
+	outgoing map[string][]TransitionInfo
+}
+
+func (fsm *FSM) transitionGraph() *transitionGraph {
+	g := &transitionGraph{fsm: fsm, outgoing: make(map[string][]TransitionInfo)}
+	for _, info := range fsm.Transitions() {
+		from := info.From.FSMStateID()
+		g.outgoing[from] = append(g.outgoing[from], info)
+	}
+	return g
+}
+
+// successors returns the transitions which can be taken in state, and the states they enter.
+func (g *transitionGraph) successors(state string) ([]TransitionInfo, []string) {
+	var infos []TransitionInfo
+	var targets []string
+	for from, ok := state, true; ok; from, ok = g.fsm.parents[from] {
+		for _, info := range g.outgoing[from] {
+			infos = append(infos, info)
+			targets = append(targets, g.target(info.To.FSMStateID()))
+		}
+	}
+	return infos, targets
+}
+
+func (g *transitionGraph) target(state string) string {
+	if h, ok := g.fsm.histories[state]; ok {
+		state = h.Composite
+	}
+	for !g.fsm.parallel[state] {
+		child, ok := g.fsm.initialChildren[state]
+		if !ok {
+			break
+		}
+		state = child
+	}
+	return state
+}
+
+// PathsBetween returns all paths from state `from` to state `to` which do not visit a state twice. The guards
+// are ignored. A path reaches `to` if it enters `to` or a state inside `to`. The paths are sorted by the order
+// of `Transitions`.
+// NOTE: the number of paths may grow exponentially with the number of states.
+func (fsm *FSM) PathsBetween(from, to State) [][]TransitionInfo {
+	g := fsm.transitionGraph()
+	result := make([][]TransitionInfo, 0)
+	visited := map[string]bool{from.FSMStateID(): true}
+	var path []TransitionInfo
+	var walk func(state string)
+	walk = func(state string) {
+		infos, targets := g.successors(state)
+		for i, info := range infos {
+			next := targets[i]
+			if visited[next] {
+				continue
+			}
+			path = append(path, info)
+			if fsm.isDescendant(next, to.FSMStateID()) {
+				result = append(result, append([]TransitionInfo(nil), path...))
+			} else {
+				visited[next] = true
+				walk(next)
+				visited[next] = false
+			}
+			path = path[:len(path)-1]
+		}
+	}
+	walk(from.FSMStateID())
+	return result
+}
+
+// HasCycle returns true if a state can be entered again after leaving it, ignoring the guards. The self
+// transitions are cycles as well.
+func (fsm *FSM) HasCycle() bool {
+	g := fsm.transitionGraph()
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	color := make(map[string]int)
+	var visit func(state string) bool
+	visit = func(state string) bool {
+		color[state] = visiting
+		_, targets := g.successors(state)
+		for _, next := range targets {
+			if color[next] == visiting || (color[next] == unvisited && visit(next)) {
+				return true
+			}
+		}
+		color[state] = done
+		return false
+	}
+	for _, state := range fsm.sortedStateIDs() {
+		if color[state] == unvisited && visit(state) {
+			return true
+		}
+	}
+	return false
+}
+
+// ShortestEventSequence returns the fewest events which move the FSM from state `from` to state `to`, ignoring
+// the guards. The completion transitions are taken without events. It returns false if `to` is unreachable.
+func (fsm *FSM) ShortestEventSequence(from, to State) ([]string, bool) {
+	type step struct {
+		prev  string
+		event string
+	}
+	g := fsm.transitionGraph()
+	start := from.FSMStateID()
+	if fsm.isDescendant(start, to.FSMStateID()) {
+		return []string{}, true
+	}
+	// 0-1 BFS, the completion transitions cost nothing.
+	steps := map[string]step{start: {}}
+	dist := map[string]int{start: 0}
+	queue := []string{start}
+	for len(queue) != 0 {
+		state := queue[0]
+		queue = queue[1:]
+		infos, targets := g.successors(state)
+		for i, info := range infos {
+			next, cost := targets[i], 1
+			if info.Event == CompletionEventID {
+				cost = 0
+			}
+			if d, ok := dist[next]; ok && d <= dist[state]+cost {
+				continue
+			}
+			dist[next] = dist[state] + cost
+			steps[next] = step{prev: state, event: info.Event}
+			if cost == 0 {
+				queue = append([]string{next}, queue...)
+			} else {
+				queue = append(queue, next)
+			}
+		}
+	}
+	best := ""
+	for state, d := range dist {
+		if !fsm.isDescendant(state, to.FSMStateID()) {
+			continue
+		}
+		if best == "" || d < dist[best] || (d == dist[best] && state < best) {
+			best = state
+		}
+	}
+	if best == "" {
+		return nil, false
+	}
+	var events []string
+	for state := best; state != start; state = steps[state].prev {
+		if steps[state].event != CompletionEventID {
+			events = append(events, steps[state].event)
+		}
+	}
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	if events == nil {
+		events = []string{}
+	}
+	return events, true
+}
@@ -0,0 +1,51 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPathsBetween(t *testing.T) {
+	fsm := newOrderFSM(t)
+	paths := fsm.PathsBetween(StringState("created"), StringState("canceled"))
+	assert.Len(t, paths, 2)
+	assert.Equal(t, "cancel", paths[0][0].Event)
+	events := make([]string, 0)
+	for _, info := range paths[1] {
+		events = append(events, info.Event)
+	}
+	assert.Equal(t, []string{"pay", CompletionEventID, "cancel"}, events)
+	assert.Empty(t, fsm.PathsBetween(StringState("delivered"), StringState("created")))
+}
+
+func TestHasCycle(t *testing.T) {
+	fsm := newOrderFSM(t)
+	assert.False(t, fsm.HasCycle())
+	assert.Nil(t, fsm.AddEvent("retry"))
+	assert.Nil(t, fsm.AddTransition(StringState("canceled"), "retry", StringState("created"), nil, nil))
+	assert.True(t, fsm.HasCycle())
+
+	assert.True(t, newPlayerFSM(t).HasCycle())
+}
+
+func TestShortestEventSequence(t *testing.T) {
+	fsm := newOrderFSM(t)
+	events, ok := fsm.ShortestEventSequence(StringState("created"), StringState("delivered"))
+	assert.True(t, ok)
+	assert.Equal(t, []string{"pay", "ship", "deliver"}, events)
+	events, ok = fsm.ShortestEventSequence(StringState("created"), StringState("created"))
+	assert.True(t, ok)
+	assert.Empty(t, events)
+	_, ok = fsm.ShortestEventSequence(StringState("shipped"), StringState("canceled"))
+	assert.False(t, ok)
+
+	// the transitions of composite states are inherited.
+	player := newPlayerFSM(t)
+	events, ok = player.ShortestEventSequence(StringState("audio"), StringState("stopped"))
+	assert.True(t, ok)
+	assert.Equal(t, []string{"stop"}, events)
+	events, ok = player.ShortestEventSequence(StringState("stopped"), StringState("paused"))
+	assert.True(t, ok)
+	// the history states are treated as their composite states.
+	assert.Equal(t, []string{"resumeDeep", "pause"}, events)
+}
@@ -0,0 +1,151 @@
+package fsm
+
+import (
+	"context"
+	"sync"
+)
+
+// AsyncResultEvent is fired into the machine when an async action returns. See `QueuedFSM.AddAsyncTransition`.
+type AsyncResultEvent struct {
+	// ID is `AsyncOptions.DoneEvent` if Err is nil, otherwise `AsyncOptions.FailedEvent`.
+	ID string
+	// Cause is the event which fired the transition of the action.
+	Cause Event
+	Err   error
+}
+
+func (e AsyncResultEvent) FSMEventID() string {
+	return e.ID
+}
+
+// AsyncLimit limits the concurrent executions of the async actions sharing it, e.g., the actions of a state
+// calling a rate limited service. See `AsyncOptions.Limit`.
+type AsyncLimit struct {
+	slots chan struct{}
+}
+
+// NewAsyncLimit creates a limit of n concurrent executions.
+func NewAsyncLimit(n int) *AsyncLimit {
+	return &AsyncLimit{slots: make(chan struct{}, n)}
+}
+
+// Running returns the number of the executing actions.
+func (l *AsyncLimit) Running() int {
+	return len(l.slots)
+}
+
+// AsyncOptions configures `QueuedFSM.AddAsyncTransition`.
+type AsyncOptions struct {
+	// DoneEvent is the id of the `AsyncResultEvent` fired when the action succeeds.
+	DoneEvent string
+	// FailedEvent is the id of the `AsyncResultEvent` fired when the action fails. It is DoneEvent if it is empty,
+	// so the transitions of DoneEvent should check the `AsyncResultEvent.Err`.
+	FailedEvent string
+	// Limit limits the concurrent executions. The actions exceeding the limit wait in the background for their
+	// turns. No limit if it is nil.
+	Limit *AsyncLimit
+}
+
+// asyncActions tracks the running async actions of a `QueuedFSM`, and the events fired by the alarms of
+// `AlarmIfStuckEvent`, so `Close` can cancel and wait for them.
+type asyncActions struct {
+	mu      sync.Mutex
+	closed  bool
+	cancels map[*context.CancelFunc]struct{}
+	running sync.WaitGroup
+}
+
+// start registers a new async action. It returns false if the machine is closed.
+func (a *asyncActions) start(cancel *context.CancelFunc) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return false
+	}
+	if a.cancels == nil {
+		a.cancels = make(map[*context.CancelFunc]struct{})
+	}
+	a.cancels[cancel] = struct{}{}
+	a.running.Add(1)
+	return true
+}
+
+func (a *asyncActions) finish(cancel *context.CancelFunc) {
+	a.mu.Lock()
+	delete(a.cancels, cancel)
+	a.mu.Unlock()
+	(*cancel)()
+	a.running.Done()
+}
+
+// close cancels the running actions, and waits for their result events to be processed.
+func (a *asyncActions) close() {
+	a.mu.Lock()
+	a.closed = true
+	for cancel := range a.cancels {
+		(*cancel)()
+	}
+	a.mu.Unlock()
+	a.running.Wait()
+}
+
+// AddAsyncTransition adds a transition whose action runs in a new goroutine, so slow actions, e.g., I/O, do not
+// block the events of the machine:
+//
+//	_ = machine.AddAsyncTransition(StringState("idle"), "fetch", StringState("fetching"), fetch, nil,
+//		fsm.AsyncOptions{DoneEvent: "fetched", FailedEvent: "fetchFailed", Limit: fetchLimit})
+//	_ = machine.AddTransition(StringState("fetching"), "fetched", StringState("ready"), nil, nil)
+//	_ = machine.AddTransition(StringState("fetching"), "fetchFailed", StringState("idle"), nil, nil)
+//
+// The transition is taken immediately, then the action runs with the payload and the event, and its result is
+// fired into the machine as an `AsyncResultEvent`. If the result event is rejected, it is sent to
+// `SetDeadLetterSink`, the other errors of processing it are dropped.
+//
+// The ctx of the action carries the values of `ActionContext`, e.g., the services, and it is canceled by `Close`,
+// which waits for the running actions. The transition fails with `ErrQueueClosed` if the machine is closing.
+// NOTE: the action runs concurrently with the event processing, so it should not access the payload and the
+// machine without synchronization.
+func (q *QueuedFSM) AddAsyncTransition(from State, evId string, to State,
+	action func(ctx context.Context, payload interface{}, ev Event) error, guard func(interface{}, Event) bool,
+	opts AsyncOptions) error {
+	if !q.HasEvent(opts.DoneEvent) {
+		return eventNotFound(opts.DoneEvent)
+	}
+	if opts.FailedEvent == "" {
+		opts.FailedEvent = opts.DoneEvent
+	} else if !q.HasEvent(opts.FailedEvent) {
+		return eventNotFound(opts.FailedEvent)
+	}
+	return q.AddTransition(from, evId, to, func(payload interface{}, ev Event) error {
+		ctx, cancel := context.WithCancel(context.WithoutCancel(q.ActionContext()))
+		if !q.async.start(&cancel) {
+			cancel()
+			return ErrQueueClosed
+		}
+		go q.runAsync(ctx, &cancel, action, payload, ev, opts)
+		return nil
+	}, guard)
+}
+
+func (q *QueuedFSM) runAsync(ctx context.Context, cancel *context.CancelFunc,
+	action func(ctx context.Context, payload interface{}, ev Event) error, payload interface{}, ev Event,
+	opts AsyncOptions) {
+	defer q.async.finish(cancel)
+	var err error
+	if opts.Limit != nil {
+		select {
+		case opts.Limit.slots <- struct{}{}:
+			err = action(ctx, payload, ev)
+			<-opts.Limit.slots
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	} else {
+		err = action(ctx, payload, ev)
+	}
+	result := AsyncResultEvent{ID: opts.DoneEvent, Cause: ev, Err: err}
+	if err != nil {
+		result.ID = opts.FailedEvent
+	}
+	_ = q.ProcessEventContext(context.WithoutCancel(ctx), result)
+}
@@ -0,0 +1,143 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newFetcher creates a machine fetching asynchronously by fetch: idle -fetch-> fetching -fetched-> ready, or
+// fetching -fetchFailed-> idle.
+func newFetcher(t *testing.T, fetch func(ctx context.Context, payload interface{}, ev Event) error,
+	limit *AsyncLimit) *QueuedFSM {
+	machine := NewQueuedFSM(StringState("idle"), nil)
+	for _, state := range []string{"fetching", "ready"} {
+		assert.Nil(t, machine.AddState(StringState(state)))
+	}
+	for _, evID := range []string{"fetch", "fetched", "fetchFailed"} {
+		assert.Nil(t, machine.AddEvent(evID))
+	}
+	assert.Nil(t, machine.AddAsyncTransition(StringState("idle"), "fetch", StringState("fetching"), fetch, nil,
+		AsyncOptions{DoneEvent: "fetched", FailedEvent: "fetchFailed", Limit: limit}))
+	assert.Nil(t, machine.AddTransition(StringState("fetching"), "fetched", StringState("ready"), nil, nil))
+	assert.Nil(t, machine.AddTransition(StringState("fetching"), "fetchFailed", StringState("idle"), nil, nil))
+	return machine
+}
+
+func TestAsyncTransition(t *testing.T) {
+	release := make(chan error)
+	machine := newFetcher(t, func(ctx context.Context, payload interface{}, ev Event) error {
+		return <-release
+	}, nil)
+	defer machine.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the action does not block the machine.
+	assert.Nil(t, machine.ProcessEvent(StringEvent("fetch")))
+	assert.Equal(t, "fetching", machine.CurrentState().FSMStateID())
+	release <- nil
+	assert.Nil(t, machine.WaitForState(ctx, StringState("ready")))
+
+	failing := newFetcher(t, func(ctx context.Context, payload interface{}, ev Event) error {
+		return <-release
+	}, nil)
+	defer failing.Close()
+	assert.Nil(t, failing.ProcessEvent(StringEvent("fetch")))
+	release <- errors.New("unavailable")
+	assert.Nil(t, failing.WaitForState(ctx, StringState("idle")))
+	assert.Equal(t, "idle", failing.CurrentState().FSMStateID())
+}
+
+func TestAsyncTransitionResult(t *testing.T) {
+	var results []AsyncResultEvent
+	machine := NewQueuedFSM(StringState("idle"), nil)
+	_ = machine.AddEvent("run")
+	_ = machine.AddEvent("ran")
+	failure := errors.New("failure")
+	assert.Nil(t, machine.AddAsyncTransition(StringState("idle"), "run", StringState("idle"),
+		func(ctx context.Context, payload interface{}, ev Event) error {
+			return failure
+		}, nil, AsyncOptions{DoneEvent: "ran"}))
+	assert.Nil(t, machine.AddTransition(StringState("idle"), "ran", StringState("idle"), func(payload interface{},
+		ev Event) error {
+		results = append(results, ev.(AsyncResultEvent))
+		return nil
+	}, nil))
+	assert.Equal(t, eventNotFound("missing"), machine.AddAsyncTransition(StringState("idle"), "run",
+		StringState("idle"), nil, nil, AsyncOptions{DoneEvent: "missing"}))
+
+	assert.Nil(t, machine.ProcessEvent(StringEvent("run")))
+	assert.Nil(t, machine.Close())
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "ran", results[0].ID)
+		assert.Equal(t, "run", results[0].Cause.FSMEventID())
+		assert.Equal(t, failure, results[0].Err)
+	}
+}
+
+func TestAsyncLimit(t *testing.T) {
+	limit := NewAsyncLimit(2)
+	release := make(chan struct{})
+	var running, maxRunning atomic.Int32
+	fetch := func(ctx context.Context, payload interface{}, ev Event) error {
+		n := running.Add(1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		<-release
+		running.Add(-1)
+		return nil
+	}
+	var machines []*QueuedFSM
+	for i := 0; i < 4; i++ {
+		machine := newFetcher(t, fetch, limit)
+		assert.Nil(t, machine.ProcessEvent(StringEvent("fetch")))
+		machines = append(machines, machine)
+	}
+	assert.Eventually(t, func() bool { return limit.Running() == 2 }, 5*time.Second, time.Millisecond)
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, machine := range machines {
+		assert.Nil(t, machine.WaitForState(ctx, StringState("ready")))
+		assert.Nil(t, machine.Close())
+	}
+	assert.Equal(t, int32(2), maxRunning.Load())
+	assert.Equal(t, 0, limit.Running())
+}
+
+func TestAsyncTransitionClose(t *testing.T) {
+	var mu sync.Mutex
+	var errs []error
+	started := make(chan struct{})
+	machine := newFetcher(t, func(ctx context.Context, payload interface{}, ev Event) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}, NewAsyncLimit(1))
+	machine.UseActionMiddleware(func(args ActionHookArgs, next func() error) error {
+		if result, ok := args.Event.(AsyncResultEvent); ok {
+			mu.Lock()
+			errs = append(errs, result.Err)
+			mu.Unlock()
+		}
+		return next()
+	})
+
+	assert.Nil(t, machine.ProcessEvent(StringEvent("fetch")))
+	<-started
+	// Close cancels the action, and waits for its result.
+	assert.Nil(t, machine.Close())
+	assert.Equal(t, "idle", machine.CurrentState().FSMStateID())
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []error{context.Canceled}, errs)
+}
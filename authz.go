@@ -0,0 +1,87 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrForbidden is returned by `ProcessEvent` if the actor of the event is not permitted to fire any of the
+// transitions. See `SetAuthorizer`.
+var ErrForbidden = errors.New("the actor is not permitted to fire the transitions")
+
+// Authorizer decides whether the actor holds the permissions required by a transition, e.g., the roles of a user
+// in an approval workflow. The actor is the `ActorOf` the event, it is empty if the event is not attributed.
+type Authorizer interface {
+	Authorize(ctx context.Context, actor string, permissions []string) bool
+}
+
+// AuthorizerFunc adapts a function to `Authorizer`.
+type AuthorizerFunc func(ctx context.Context, actor string, permissions []string) bool
+
+func (f AuthorizerFunc) Authorize(ctx context.Context, actor string, permissions []string) bool {
+	return f(ctx, actor, permissions)
+}
+
+// StaticAuthorizer grants the permissions to the actors, i.e., actor -> permissions. The actor is permitted if it
+// is granted all the required permissions.
+type StaticAuthorizer map[string][]string
+
+func (s StaticAuthorizer) Authorize(ctx context.Context, actor string, permissions []string) bool {
+	for _, required := range permissions {
+		granted := false
+		for _, p := range s[actor] {
+			if p == required {
+				granted = true
+				break
+			}
+		}
+		if !granted {
+			return false
+		}
+	}
+	return true
+}
+
+// SetAuthorizer sets the authorizer of the transitions requiring permissions, see
+// `TransitionOptions.Permissions`. The authorizer is consulted before the guards are evaluated, with the actor of
+// the event, e.g., given by `ProcessEventAs`:
+//   - The forbidden transitions are skipped, and recorded by `ExplainLastRejection` with the `Forbidden`
+//     candidates.
+//   - If all transitions of the event from a state are forbidden, `ProcessEvent` returns `ErrForbidden` and the
+//     state is not changed. The transitions of the parent states are not evaluated either. The event is sent to
+//     the dead-letter sink, see `SetDeadLetterSink`.
+//
+// The transitions without permissions are always permitted. The events of `Replay` are not authorized.
+// NOTE: the transitions requiring permissions are forbidden if the authorizer is nil, which is the default.
+func (fsm *FSM) SetAuthorizer(authorizer Authorizer) {
+	fsm.authorizer = authorizer
+}
+
+// authorize returns the transitions of transList the actor of ev is permitted to fire, the forbidden ones are
+// rejected. It returns `ErrForbidden` if all of them are forbidden.
+func (fsm *FSM) authorize(ctx context.Context, from string, ev Event, transList []*transition) (
+	[]*transition, error) {
+	// permitted is nil unless some transition is forbidden, so transList is not copied in common cases.
+	var permitted []*transition
+	for i, t := range transList {
+		if len(t.permissions) == 0 ||
+			fsm.authorizer != nil && fsm.authorizer.Authorize(ctx, ActorOf(ev), t.permissions) {
+			if permitted != nil {
+				permitted = append(permitted, t)
+			}
+			continue
+		}
+		if permitted == nil {
+			permitted = append(make([]*transition, 0, len(transList)), transList[:i]...)
+		}
+		fsm.reject(from, t, Forbidden)
+	}
+	if permitted == nil {
+		return transList, nil
+	}
+	if len(permitted) == 0 {
+		_ = fsm.noTransition(ev)
+		return nil, ErrForbidden
+	}
+	return permitted, nil
+}
@@ -0,0 +1,82 @@
+package fsm
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newApprovalFSM() *FSM {
+	fsm := NewFSM(StringState("pending"), nil)
+	_ = fsm.AddState(StringState("approved"))
+	_ = fsm.AddState(StringState("review"))
+	_ = fsm.AddState(StringState("withdrawn"))
+	_ = fsm.AddEvent("approve")
+	_ = fsm.AddEvent("submit")
+	_ = fsm.AddEvent("withdraw")
+	_ = fsm.AddTransitionWithOptions(StringState("pending"), "approve", StringState("approved"), nil, nil,
+		TransitionOptions{Permissions: []string{"approve"}})
+	_ = fsm.AddTransitionWithOptions(StringState("pending"), "submit", StringState("approved"), nil, nil,
+		TransitionOptions{Permissions: []string{"approve"}})
+	_ = fsm.AddTransition(StringState("pending"), "submit", StringState("review"), nil, nil)
+	_ = fsm.AddTransition(StringState("pending"), "withdraw", StringState("withdrawn"), nil, nil)
+	return fsm
+}
+
+func TestAuthorizer(t *testing.T) {
+	machine := newApprovalFSM()
+	var letters []DeadLetter
+	machine.SetDeadLetterSink(func(letter DeadLetter) {
+		letters = append(letters, letter)
+	})
+	assert.Equal(t, ErrForbidden, machine.ProcessEventAs("alice", StringEvent("approve")))
+	assert.Equal(t, StringState("pending"), machine.CurrentState())
+	assert.Len(t, letters, 1)
+	rejection := machine.ExplainLastRejection()
+	assert.Len(t, rejection.Candidates, 1)
+	assert.Equal(t, Forbidden, rejection.Candidates[0].Reason)
+	assert.Equal(t, "forbidden", Forbidden.String())
+
+	machine.SetAuthorizer(StaticAuthorizer{"bob": {"approve", "withdraw"}})
+	assert.Equal(t, ErrForbidden, machine.ProcessEventAs("alice", StringEvent("approve")))
+	assert.Equal(t, ErrForbidden, machine.ProcessEvent(StringEvent("approve")))
+	assert.Nil(t, machine.ProcessEventAs("bob", StringEvent("approve")))
+	assert.Equal(t, StringState("approved"), machine.CurrentState())
+}
+
+func TestAuthorizerSkipsForbidden(t *testing.T) {
+	machine := newApprovalFSM()
+	var actors []string
+	machine.SetAuthorizer(AuthorizerFunc(func(ctx context.Context, actor string, permissions []string) bool {
+		actors = append(actors, actor)
+		assert.Equal(t, []string{"approve"}, permissions)
+		return actor == "bob"
+	}))
+	assert.Nil(t, machine.ProcessEventAs("alice", StringEvent("submit")))
+	assert.Equal(t, StringState("review"), machine.CurrentState())
+
+	machine = newApprovalFSM()
+	machine.SetAuthorizer(StaticAuthorizer{"bob": {"approve"}})
+	assert.Nil(t, machine.ProcessEventAs("bob", StringEvent("submit")))
+	assert.Equal(t, StringState("approved"), machine.CurrentState())
+
+	machine = newApprovalFSM()
+	assert.Nil(t, machine.ProcessEventAs("alice", StringEvent("withdraw")))
+	assert.Equal(t, StringState("withdrawn"), machine.CurrentState())
+	assert.Equal(t, []string{"alice"}, actors)
+}
+
+func TestPermissionsDefinition(t *testing.T) {
+	def := newApprovalFSM().Definition()
+	assert.Equal(t, []string{"approve"}, def.Transitions[0].Permissions)
+	machine, err := NewFSMFromDefinition(def, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"approve"}, machine.Transitions()[0].Permissions)
+	assert.Nil(t, machine.Transitions()[3].Permissions)
+
+	after := *def
+	after.Transitions = append([]TransitionDefinition(nil), def.Transitions...)
+	after.Transitions[0].Permissions = []string{"approve", "audit"}
+	assert.Equal(t, []Change{{Kind: ChangeModified, Element: "transition", ID: "pending -approve-> approved",
+		Field: "permissions", Before: "approve", After: "approve, audit"}}, Diff(*def, after))
+}
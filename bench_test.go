@@ -0,0 +1,163 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+// The benchmarks of the package. The baseline of them is testdata/benchmarks.txt, see cmd/benchgate for comparing
+// a change with it.
+
+// newSwitchFSM creates a FSM of two states switched by the event "switch".
+func newSwitchFSM(fsm *FSM) *FSM {
+	_ = fsm.AddState(StringState("on"))
+	_ = fsm.AddEvent("switch")
+	_ = fsm.AddTransition(StringState("off"), "switch", StringState("on"), nil, nil)
+	_ = fsm.AddTransition(StringState("on"), "switch", StringState("off"), nil, nil)
+	return fsm
+}
+
+type nopObserver struct {
+	NopObserver
+}
+
+// BenchmarkProcessEventFeatures measures a transition with a guard and an action, and the cost of the optional
+// features.
+func BenchmarkProcessEventFeatures(b *testing.B) {
+	for _, c := range []struct {
+		name  string
+		setup func(fsm *FSM)
+	}{
+		{"plain", func(*FSM) {}},
+		{"global-hooks", func(fsm *FSM) {
+			fsm.GlobalBeforeAction.Add(func(interface{}) {})
+		}},
+		{"middleware", func(fsm *FSM) {
+			fsm.UseActionMiddleware(func(args ActionHookArgs, next func() error) error {
+				return next()
+			})
+		}},
+		{"observer", func(fsm *FSM) {
+			fsm.AddObserver(nopObserver{})
+		}},
+		{"rollback", func(fsm *FSM) {
+			fsm.SetRollbackLimit(16)
+		}},
+		{"debug", func(fsm *FSM) {
+			fsm.SetDebugRecording(16, nil)
+		}},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			digits := 0
+			fsm := newParserFSM(&digits)
+			c.setup(fsm)
+			ev := &byteEvent{b: '1'}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := fsm.ProcessEvent(ev); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkLargeMachine measures the transitions of the machines of many states and events, by the maps, the
+// compiled table, and the compiled table with `IndexEvent`s.
+func BenchmarkLargeMachine(b *testing.B) {
+	for _, n := range []int{16, 256, 4096} {
+		for _, mode := range []string{"map", "compiled", "indexed"} {
+			b.Run(fmt.Sprintf("states=%d/%s", n, mode), func(b *testing.B) {
+				fsm := newRingFSM(n)
+				if mode != "map" {
+					fsm.Compile()
+				}
+				events := make([]Event, n)
+				for i := range events {
+					if mode == "indexed" {
+						events[i], _ = fsm.IndexEvent(ringEvent(i))
+					} else {
+						events[i] = StringEvent(ringEvent(i))
+					}
+				}
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if err := fsm.ProcessEvent(events[i%n]); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkQueuedFSMThroughput measures the events of concurrent callers processed by one `QueuedFSM`.
+func BenchmarkQueuedFSMThroughput(b *testing.B) {
+	fsm := NewQueuedFSM(StringState("off"), nil)
+	defer fsm.Close()
+	newSwitchFSM(fsm.FSM)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = fsm.ProcessEvent(StringEvent("switch"))
+		}
+	})
+}
+
+// BenchmarkWorkerPoolThroughput measures the events of concurrent callers processed by 1024 machines sharing a
+// `WorkerPool`.
+func BenchmarkWorkerPoolThroughput(b *testing.B) {
+	const machines = 1024
+	pool := NewWorkerPool(4)
+	defer pool.Close()
+	fsms := make([]*QueuedFSM, machines)
+	for i := range fsms {
+		fsms[i] = pool.NewQueuedFSM(StringState("off"), nil)
+		newSwitchFSM(fsms[i].FSM)
+	}
+	var next uint64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = fsms[atomic.AddUint64(&next, 1)%machines].ProcessEvent(StringEvent("switch"))
+		}
+	})
+}
+
+// BenchmarkPreemptiveFSMLatency measures the round trip of an event processed by a `PreemptiveFSM`. In the
+// contended case, the concurrent callers preempt each other, and the ratio of the preempted events is reported.
+func BenchmarkPreemptiveFSMLatency(b *testing.B) {
+	b.Run("sequential", func(b *testing.B) {
+		fsm := NewPreemptiveFSM(StringState("off"), nil)
+		defer fsm.Close()
+		newSwitchFSM(fsm.FSM)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := fsm.ProcessEventContext(context.Background(), StringEvent("switch")); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("contended", func(b *testing.B) {
+		fsm := NewPreemptiveFSM(StringState("off"), nil)
+		defer fsm.Close()
+		newSwitchFSM(fsm.FSM)
+		var preempted uint64
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if fsm.ProcessEvent(StringEvent("switch")) != nil {
+					atomic.AddUint64(&preempted, 1)
+				}
+			}
+		})
+		b.ReportMetric(float64(preempted)/float64(b.N), "preempted/op")
+	})
+}
@@ -0,0 +1,116 @@
+// Package bus connects machines to message buses. A `Pump` feeds the messages of a `Source` into a
+// `fsm.QueuedFSM`, and `PublishTransitions` publishes the state changes of a machine by a `Publisher`.
+//
+//	pump := bus.NewPump(source, machine, bus.NewHeaderDecoder(codec))
+//	go pump.Run(ctx)
+//	go bus.PublishTransitions(ctx, machine.FSM, publisher, "orders.transitions", nil)
+//
+// The adapters of NATS and Kafka are the modules github.com/reyoung/fsm/bus/nats and
+// github.com/reyoung/fsm/bus/kafka.
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/reyoung/fsm"
+	"github.com/reyoung/fsm/persist"
+)
+
+// EventHeader is the message header holding the event id, used by `NewHeaderDecoder`.
+const EventHeader = "fsm-event"
+
+// Message is a message of a bus, independent of the client.
+type Message struct {
+	// Topic is the topic, or the subject, of the message.
+	Topic   string
+	Key     []byte
+	Headers map[string]string
+	Data    []byte
+	// Raw is the message of the underlying client, used by the adapters to acknowledge it.
+	Raw interface{}
+}
+
+// Source receives the messages from a bus.
+type Source interface {
+	// Receive blocks until the next message is received, or ctx is done.
+	Receive(ctx context.Context) (*Message, error)
+	// Ack acknowledges the message once its event is handled, so it is not delivered again.
+	Ack(ctx context.Context, msg *Message) error
+}
+
+// Publisher publishes the messages to a bus.
+type Publisher interface {
+	Publish(ctx context.Context, topic string, msg *Message) error
+}
+
+// Decoder decodes a message into an event.
+type Decoder func(msg *Message) (fsm.Event, error)
+
+// NewHeaderDecoder creates a decoder which reads the event id from the `EventHeader` header, and decodes the
+// data by codec. `persist.JSONCodec` is used if codec is nil.
+func NewHeaderDecoder(codec persist.Codec) Decoder {
+	if codec == nil {
+		codec = persist.NewJSONCodec()
+	}
+	return func(msg *Message) (fsm.Event, error) {
+		evID, ok := msg.Headers[EventHeader]
+		if !ok || evID == "" {
+			return nil, errors.New(fmt.Sprintf("message of topic %s has no %s header", msg.Topic, EventHeader))
+		}
+		return codec.Decode(evID, msg.Data)
+	}
+}
+
+// Encoder encodes a state change of the machine into a message.
+type Encoder func(machine *fsm.FSM, change fsm.StateChange) (*Message, error)
+
+// JSONEncoder encodes the state change as a JSON `fsm.TransitionNotification`, keyed by the machine name, so the
+// changes of a machine are kept in order by the partitioned buses.
+func JSONEncoder(machine *fsm.FSM, change fsm.StateChange) (*Message, error) {
+	data, err := json.Marshal(fsm.TransitionNotification{
+		From:    change.From.FSMStateID(),
+		To:      change.To.FSMStateID(),
+		Event:   change.Event.FSMEventID(),
+		Time:    change.Time,
+		Dropped: change.Dropped,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Message{
+		Key:     []byte(machine.Name()),
+		Headers: map[string]string{EventHeader: change.Event.FSMEventID()},
+		Data:    data,
+	}, nil
+}
+
+// PublishTransitions publishes the state changes of the machine to topic until ctx is done, or publishing fails.
+// `JSONEncoder` is used if encode is nil. It returns the error of ctx when ctx is done.
+//
+// NOTE: the changes are received by `fsm.FSM.Subscribe`, they may be dropped if the publisher is slow. The number
+// of dropped changes is reported by the next change.
+func PublishTransitions(ctx context.Context, machine *fsm.FSM, publisher Publisher, topic string,
+	encode Encoder) error {
+	if encode == nil {
+		encode = JSONEncoder
+	}
+	changes, cancel := machine.Subscribe()
+	defer cancel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case change := <-changes:
+			msg, err := encode(machine, change)
+			if err != nil {
+				return err
+			}
+			msg.Topic = topic
+			if err := publisher.Publish(ctx, topic, msg); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/reyoung/fsm"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// chanSource receives the messages of a channel, and records the acknowledged ones.
+type chanSource struct {
+	ch    chan *Message
+	mu    sync.Mutex
+	acked []*Message
+}
+
+func (s *chanSource) Receive(ctx context.Context) (*Message, error) {
+	select {
+	case msg, ok := <-s.ch:
+		if !ok {
+			return nil, errors.New("closed")
+		}
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *chanSource) Ack(_ context.Context, msg *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.acked = append(s.acked, msg)
+	return nil
+}
+
+type chanPublisher chan *Message
+
+func (p chanPublisher) Publish(_ context.Context, topic string, msg *Message) error {
+	if topic == "broken" {
+		return errors.New("broken")
+	}
+	p <- msg
+	return nil
+}
+
+func newLight() *fsm.QueuedFSM {
+	var (
+		on  = fsm.StringState("on")
+		off = fsm.StringState("off")
+	)
+	machine := fsm.NewQueuedFSM(off, nil)
+	machine.SetName("kitchen")
+	_ = machine.AddState(on)
+	_ = machine.AddEvent("switch")
+	_ = machine.AddTransition(off, "switch", on, nil, nil)
+	_ = machine.AddTransition(on, "switch", off, nil, nil)
+	return machine
+}
+
+func eventMessage(evID string) *Message {
+	return &Message{Topic: "lights", Headers: map[string]string{EventHeader: evID}}
+}
+
+func TestPump(t *testing.T) {
+	machine := newLight()
+	defer machine.Close()
+	source := &chanSource{ch: make(chan *Message, 3)}
+	source.ch <- eventMessage("switch")
+	source.ch <- &Message{Topic: "lights"}
+	source.ch <- eventMessage("switch")
+	close(source.ch)
+
+	pump := NewPump(source, machine, NewHeaderDecoder(nil))
+	assert.Equal(t, "closed", pump.Run(context.Background()).Error())
+	assert.Len(t, source.acked, 3)
+	assert.Equal(t, uint64(2), machine.Version())
+	assert.Equal(t, fsm.StringState("off"), machine.CurrentState())
+
+	source = &chanSource{ch: make(chan *Message, 2)}
+	source.ch <- eventMessage("switch")
+	source.ch <- eventMessage("unknown")
+	pump = NewPump(source, machine, NewHeaderDecoder(nil))
+	var failed *Message
+	pump.SetErrorHandler(func(msg *Message, err error) error {
+		failed = msg
+		return err
+	})
+	assert.NotNil(t, pump.Run(context.Background()))
+	assert.Equal(t, "unknown", failed.Headers[EventHeader])
+	assert.Len(t, source.acked, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, NewPump(source, machine, NewHeaderDecoder(nil)).Run(ctx))
+}
+
+func TestPublishTransitions(t *testing.T) {
+	machine := newLight()
+	defer machine.Close()
+	publisher := make(chanPublisher, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- PublishTransitions(ctx, machine.FSM, publisher, "lights.transitions", nil)
+	}()
+
+	// the subscription is made asynchronously, so the events are processed until a message is published.
+	var msg *Message
+	assert.Eventually(t, func() bool {
+		assert.Nil(t, machine.ProcessEvent(fsm.StringEvent("switch")))
+		select {
+		case msg = <-publisher:
+			return true
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+
+	assert.Equal(t, "lights.transitions", msg.Topic)
+	assert.Equal(t, []byte("kitchen"), msg.Key)
+	assert.Equal(t, "switch", msg.Headers[EventHeader])
+	var notification fsm.TransitionNotification
+	assert.Nil(t, json.Unmarshal(msg.Data, &notification))
+	assert.Equal(t, "switch", notification.Event)
+	assert.NotEqual(t, notification.From, notification.To)
+
+	go func() {
+		done <- PublishTransitions(context.Background(), machine.FSM, publisher, "broken", nil)
+	}()
+	assert.Eventually(t, func() bool {
+		assert.Nil(t, machine.ProcessEvent(fsm.StringEvent("switch")))
+		select {
+		case err := <-done:
+			assert.Equal(t, "broken", err.Error())
+			return true
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+}
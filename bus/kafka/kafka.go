@@ -0,0 +1,72 @@
+// Package kafka adapts kafka-go readers and writers to the `bus.Source` and `bus.Publisher` of
+// github.com/reyoung/fsm/bus.
+//
+//	reader := kafka.NewReader(kafka.ReaderConfig{Brokers: brokers, GroupID: "orders", Topic: "orders.events"})
+//	pump := bus.NewPump(fsmkafka.NewSource(reader), machine, bus.NewHeaderDecoder(codec))
+//	writer := &kafka.Writer{Addr: kafka.TCP(brokers...)}
+//	go bus.PublishTransitions(ctx, machine.FSM, fsmkafka.NewPublisher(writer), "orders.transitions", nil)
+package kafka
+
+import (
+	"context"
+	"github.com/reyoung/fsm/bus"
+	"github.com/segmentio/kafka-go"
+	"sort"
+)
+
+// Reader fetches and commits the messages, e.g., `*kafka.Reader` of a consumer group.
+type Reader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// Source receives the messages of a reader. A message is committed when it is acknowledged.
+type Source struct {
+	reader Reader
+}
+
+func NewSource(reader Reader) *Source {
+	return &Source{reader: reader}
+}
+
+func (s *Source) Receive(ctx context.Context) (*bus.Message, error) {
+	m, err := s.reader.FetchMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	msg := &bus.Message{Topic: m.Topic, Key: m.Key, Headers: make(map[string]string), Data: m.Value, Raw: m}
+	for _, h := range m.Headers {
+		msg.Headers[h.Key] = string(h.Value)
+	}
+	return msg, nil
+}
+
+func (s *Source) Ack(ctx context.Context, msg *bus.Message) error {
+	return s.reader.CommitMessages(ctx, msg.Raw.(kafka.Message))
+}
+
+// Writer writes the messages, e.g., `*kafka.Writer`.
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// Publisher publishes the messages by a writer.
+// NOTE: the topic of the writer should be empty, since the topic is given per message.
+type Publisher struct {
+	writer Writer
+}
+
+func NewPublisher(writer Writer) *Publisher {
+	return &Publisher{writer: writer}
+}
+
+func (p *Publisher) Publish(ctx context.Context, topic string, msg *bus.Message) error {
+	m := kafka.Message{Topic: topic, Key: msg.Key, Value: msg.Data}
+	for key, value := range msg.Headers {
+		m.Headers = append(m.Headers, kafka.Header{Key: key, Value: []byte(value)})
+	}
+	sort.Slice(m.Headers, func(i, j int) bool {
+		return m.Headers[i].Key < m.Headers[j].Key
+	})
+	return p.writer.WriteMessages(ctx, m)
+}
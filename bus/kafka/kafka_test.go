@@ -0,0 +1,70 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"github.com/reyoung/fsm"
+	"github.com/reyoung/fsm/bus"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// fakeReader fetches the messages in order, and records the committed ones.
+type fakeReader struct {
+	msgs      []kafka.Message
+	committed []kafka.Message
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if len(r.msgs) == 0 {
+		return kafka.Message{}, errors.New("no more messages")
+	}
+	m := r.msgs[0]
+	r.msgs = r.msgs[1:]
+	return m, nil
+}
+
+func (r *fakeReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	r.committed = append(r.committed, msgs...)
+	return nil
+}
+
+type fakeWriter []kafka.Message
+
+func (w *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	*w = append(*w, msgs...)
+	return nil
+}
+
+func TestSource(t *testing.T) {
+	machine := fsm.NewQueuedFSM(fsm.StringState("off"), nil)
+	defer machine.Close()
+	_ = machine.AddState(fsm.StringState("on"))
+	_ = machine.AddEvent("switch")
+	_ = machine.AddTransition(fsm.StringState("off"), "switch", fsm.StringState("on"), nil, nil)
+
+	reader := &fakeReader{msgs: []kafka.Message{{
+		Topic:   "lights",
+		Offset:  7,
+		Key:     []byte("kitchen"),
+		Headers: []kafka.Header{{Key: bus.EventHeader, Value: []byte("switch")}},
+	}}}
+	err := bus.NewPump(NewSource(reader), machine, bus.NewHeaderDecoder(nil)).Run(context.Background())
+	assert.Equal(t, "no more messages", err.Error())
+	assert.Equal(t, fsm.StringState("on"), machine.CurrentState())
+	assert.Len(t, reader.committed, 1)
+	assert.Equal(t, int64(7), reader.committed[0].Offset)
+}
+
+func TestPublisher(t *testing.T) {
+	writer := &fakeWriter{}
+	msg := &bus.Message{Key: []byte("kitchen"), Headers: map[string]string{"b": "2", "a": "1"}, Data: []byte("{}")}
+	assert.Nil(t, NewPublisher(writer).Publish(context.Background(), "lights.transitions", msg))
+	assert.Equal(t, []kafka.Message{{
+		Topic:   "lights.transitions",
+		Key:     []byte("kitchen"),
+		Value:   []byte("{}"),
+		Headers: []kafka.Header{{Key: "a", Value: []byte("1")}, {Key: "b", Value: []byte("2")}},
+	}}, []kafka.Message(*writer))
+}
@@ -0,0 +1,92 @@
+// Package nats adapts NATS subscriptions and connections to the `bus.Source` and `bus.Publisher` of
+// github.com/reyoung/fsm/bus.
+//
+//	sub, _ := conn.QueueSubscribeSync("orders.events", "order-workers")
+//	pump := bus.NewPump(nats.NewSource(sub), machine, bus.NewHeaderDecoder(codec))
+//	go bus.PublishTransitions(ctx, machine.FSM, nats.NewPublisher(conn), "orders.transitions", nil)
+package nats
+
+import (
+	"context"
+	"github.com/nats-io/nats.go"
+	"github.com/reyoung/fsm/bus"
+)
+
+// KeyHeader is the header holding the key of the messages, since NATS messages have no key.
+const KeyHeader = "fsm-key"
+
+// Subscription is a synchronous subscription, e.g., `*nats.Subscription` created by `nats.Conn.SubscribeSync`.
+type Subscription interface {
+	NextMsgWithContext(ctx context.Context) (*nats.Msg, error)
+}
+
+// Source receives the messages of a subscription.
+type Source struct {
+	sub Subscription
+	ack bool
+}
+
+// NewSource creates a source of the subscription. The messages are not acknowledged by default, see `SetAck`.
+func NewSource(sub Subscription) *Source {
+	return &Source{sub: sub}
+}
+
+// SetAck makes the source acknowledge the handled messages by `nats.Msg.Ack`, which is required by the
+// JetStream subscriptions. The core NATS messages cannot be acknowledged.
+func (s *Source) SetAck(ack bool) {
+	s.ack = ack
+}
+
+func (s *Source) Receive(ctx context.Context) (*bus.Message, error) {
+	m, err := s.sub.NextMsgWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	msg := &bus.Message{Topic: m.Subject, Headers: make(map[string]string), Data: m.Data, Raw: m}
+	for key, values := range m.Header {
+		if len(values) == 0 {
+			continue
+		}
+		if key == KeyHeader {
+			msg.Key = []byte(values[0])
+		} else {
+			msg.Headers[key] = values[0]
+		}
+	}
+	return msg, nil
+}
+
+func (s *Source) Ack(_ context.Context, msg *bus.Message) error {
+	if !s.ack {
+		return nil
+	}
+	return msg.Raw.(*nats.Msg).Ack()
+}
+
+// Conn publishes the messages, e.g., `*nats.Conn`.
+type Conn interface {
+	PublishMsg(m *nats.Msg) error
+}
+
+// Publisher publishes the messages by a connection.
+type Publisher struct {
+	conn Conn
+}
+
+func NewPublisher(conn Conn) *Publisher {
+	return &Publisher{conn: conn}
+}
+
+// Publish publishes the message to the subject topic. The key of the message is sent as the `KeyHeader` header.
+// NOTE: the core NATS publishing is buffered by the connection, ctx is ignored.
+func (p *Publisher) Publish(_ context.Context, topic string, msg *bus.Message) error {
+	m := nats.NewMsg(topic)
+	for key, value := range msg.Headers {
+		m.Header.Set(key, value)
+	}
+	if len(msg.Key) != 0 {
+		m.Header.Set(KeyHeader, string(msg.Key))
+	}
+	m.Data = msg.Data
+	return p.conn.PublishMsg(m)
+}
@@ -0,0 +1,69 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"github.com/nats-io/nats.go"
+	"github.com/reyoung/fsm"
+	"github.com/reyoung/fsm/bus"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type fakeSubscription []*nats.Msg
+
+func (s *fakeSubscription) NextMsgWithContext(ctx context.Context) (*nats.Msg, error) {
+	if len(*s) == 0 {
+		return nil, errors.New("no more messages")
+	}
+	m := (*s)[0]
+	*s = (*s)[1:]
+	return m, nil
+}
+
+type fakeConn []*nats.Msg
+
+func (c *fakeConn) PublishMsg(m *nats.Msg) error {
+	*c = append(*c, m)
+	return nil
+}
+
+func TestSource(t *testing.T) {
+	machine := fsm.NewQueuedFSM(fsm.StringState("off"), nil)
+	defer machine.Close()
+	_ = machine.AddState(fsm.StringState("on"))
+	_ = machine.AddEvent("switch")
+	_ = machine.AddTransition(fsm.StringState("off"), "switch", fsm.StringState("on"), nil, nil)
+
+	m := nats.NewMsg("lights")
+	m.Header.Set(bus.EventHeader, "switch")
+	m.Header.Set(KeyHeader, "kitchen")
+	sub := &fakeSubscription{m}
+	source := NewSource(sub)
+	msg, err := source.Receive(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, &bus.Message{
+		Topic:   "lights",
+		Key:     []byte("kitchen"),
+		Headers: map[string]string{bus.EventHeader: "switch"},
+		Raw:     m,
+	}, msg)
+	assert.Nil(t, source.Ack(context.Background(), msg))
+
+	*sub = append(*sub, m)
+	err = bus.NewPump(source, machine, bus.NewHeaderDecoder(nil)).Run(context.Background())
+	assert.Equal(t, "no more messages", err.Error())
+	assert.Equal(t, fsm.StringState("on"), machine.CurrentState())
+}
+
+func TestPublisher(t *testing.T) {
+	conn := &fakeConn{}
+	msg := &bus.Message{Key: []byte("kitchen"), Headers: map[string]string{bus.EventHeader: "switch"}, Data: []byte("{}")}
+	assert.Nil(t, NewPublisher(conn).Publish(context.Background(), "lights.transitions", msg))
+	assert.Len(t, *conn, 1)
+	m := (*conn)[0]
+	assert.Equal(t, "lights.transitions", m.Subject)
+	assert.Equal(t, "switch", m.Header.Get(bus.EventHeader))
+	assert.Equal(t, "kitchen", m.Header.Get(KeyHeader))
+	assert.Equal(t, []byte("{}"), m.Data)
+}
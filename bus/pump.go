@@ -0,0 +1,62 @@
+package bus
+
+import (
+	"context"
+	"github.com/reyoung/fsm"
+)
+
+// Pump feeds the messages of a source into a machine. See `NewPump`.
+type Pump struct {
+	source  Source
+	machine *fsm.QueuedFSM
+	decode  Decoder
+	onError func(msg *Message, err error) error
+}
+
+// NewPump creates a pump which decodes the messages of source by decode, and processes the events by the machine
+// one by one. A message is acknowledged after its event is processed.
+func NewPump(source Source, machine *fsm.QueuedFSM, decode Decoder) *Pump {
+	return &Pump{source: source, machine: machine, decode: decode}
+}
+
+// SetErrorHandler sets the handler of the messages which fail to be decoded or processed. If the handler returns
+// nil, the message is acknowledged and skipped, otherwise `Run` stops with the returned error and the message is
+// not acknowledged. By default, the failed messages are skipped.
+func (p *Pump) SetErrorHandler(onError func(msg *Message, err error) error) {
+	p.onError = onError
+}
+
+// Run pumps the messages until ctx is done, or receiving, acknowledging or the error handler fails. It returns
+// the error of ctx when ctx is done.
+func (p *Pump) Run(ctx context.Context) error {
+	for {
+		msg, err := p.source.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if err := p.handle(ctx, msg); err != nil {
+			return err
+		}
+		// the event may be abandoned when ctx is done, so the message is left to be delivered again.
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := p.source.Ack(ctx, msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *Pump) handle(ctx context.Context, msg *Message) error {
+	ev, err := p.decode(msg)
+	if err == nil {
+		err = p.machine.ProcessEventContext(ctx, ev)
+	}
+	if err == nil || p.onError == nil {
+		return nil
+	}
+	return p.onError(msg, err)
+}
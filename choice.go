@@ -0,0 +1,74 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+)
+
+func unreachableAfterChoice(from State, evId string) error {
+	return errors.New(fmt.Sprintf("the transition from state(%s) and event(%s) is unreachable after the choice",
+		from.FSMStateID(), evId))
+}
+
+// ChoiceBranch is a branch of a choice. See `AddChoice`.
+type ChoiceBranch struct {
+	// Guard is nil for the default branch.
+	Guard   func(interface{}, Event) bool
+	To      State
+	Action  func(interface{}, Event) error
+	Options TransitionOptions
+}
+
+// AddChoice adds a choice pseudo-state to the transitions from state `from` and triggered by `evId`. The guards
+// of branches are evaluated in order, and the first branch whose guard returns true is taken.
+//   - The last branch is the default branch, and it is mandatory. i.e., its guard should be nil.
+//   - The guards of the other branches should not be nil.
+//   - A choice cannot be added if there are transitions from the same state and event, and transitions cannot be
+//     added after the choice, since they are unreachable.
+//
+// The choice is rendered as a diamond in diagrams.
+func (fsm *FSM) AddChoice(from State, evId string, branches []ChoiceBranch) error {
+	if len(branches) == 0 || branches[len(branches)-1].Guard != nil {
+		return errors.New("the last branch of a choice should be the default branch without guard")
+	}
+	for i, b := range branches {
+		if b.Guard == nil && i != len(branches)-1 {
+			return errors.New("only the last branch of a choice can be the default branch")
+		}
+		if !fsm.HasState(b.To) {
+			return stateNotFound(b.To)
+		}
+	}
+	if len(fsm.transitions[from.FSMStateID()][evId]) != 0 {
+		return AlreadyExists
+	}
+	for _, b := range branches {
+		if err := fsm.addTransition(from, evId, b.To, b.Action, b.Guard, b.Options, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fsm *FSM) hasChoice(from State, evId string) bool {
+	transList := fsm.transitions[from.FSMStateID()][evId]
+	return len(transList) != 0 && transList[len(transList)-1].choice
+}
+
+func choiceNodeID(from string, evID string) string {
+	return fmt.Sprintf("choice(%s, %s)", from, evID)
+}
+
+// choiceBranchLabel is the label of the edge from the choice pseudo-state to the target.
+func choiceBranchLabel(meta TransitionMetadata, hasGuard bool, guardName string) string {
+	switch {
+	case meta.Name != "":
+		return meta.Name
+	case !hasGuard:
+		return "[else]"
+	case guardName != "":
+		return fmt.Sprintf("[%s]", guardName)
+	default:
+		return ""
+	}
+}
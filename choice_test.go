@@ -0,0 +1,59 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAddChoice(t *testing.T) {
+	var (
+		idle   = StringState("idle")
+		small  = StringState("small")
+		large  = StringState("large")
+		reject = StringState("reject")
+	)
+	newFSM := func() *FSM {
+		fsm := NewFSM(idle, nil)
+		for _, s := range []State{small, large, reject} {
+			assert.Nil(t, fsm.AddState(s))
+		}
+		assert.Nil(t, fsm.AddEvent("temperature"))
+		return fsm
+	}
+	registry := NewHandlerRegistry().
+		MustRegisterGuard("isLarge", GuardEventIs(func(ev *temperatureEvent) bool {
+			return ev.celsius > 100
+		})).
+		MustRegisterGuard("isSmall", GuardEventIs(func(ev *temperatureEvent) bool {
+			return ev.celsius > 0
+		}))
+	isLarge, _ := registry.Guard("isLarge")
+	isSmall, _ := registry.Guard("isSmall")
+
+	fsm := newFSM()
+	assert.NotNil(t, fsm.AddChoice(idle, "temperature", []ChoiceBranch{{Guard: isLarge, To: large}}))
+	assert.NotNil(t, fsm.AddChoice(idle, "temperature", []ChoiceBranch{{To: small}, {To: reject}}))
+	assert.Nil(t, fsm.AddChoice(idle, "temperature", []ChoiceBranch{
+		{Guard: isLarge, To: large, Options: TransitionOptions{GuardName: "isLarge"}},
+		{Guard: isSmall, To: small, Options: TransitionOptions{GuardName: "isSmall"}},
+		{To: reject},
+	}))
+	assert.NotNil(t, fsm.AddTransition(idle, "temperature", small, nil, nil))
+	assert.Equal(t, AlreadyExists, fsm.AddChoice(idle, "temperature", []ChoiceBranch{{To: reject}}))
+
+	assert.Contains(t, fsm.DumpGraphviz(), "[else]")
+	uml := fsm.DumpPlantUML()
+	assert.Contains(t, uml, "state c0 <<choice>>\ns0 --> c0 : temperature\nc0 --> s1 : [isLarge]\n"+
+		"c0 --> s3 : [isSmall]\nc0 --> s2 : [else]\n")
+
+	assert.Nil(t, fsm.ProcessEvent(&temperatureEvent{celsius: 10}))
+	assert.Equal(t, small, fsm.CurrentState())
+
+	def := fsm.Definition()
+	def.Initial = "idle"
+	reloaded, err := NewFSMFromDefinition(def, registry, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, fsm.Transitions(), reloaded.Transitions())
+	assert.Nil(t, reloaded.ProcessEvent(&temperatureEvent{celsius: -10}))
+	assert.Equal(t, reject, reloaded.CurrentState())
+}
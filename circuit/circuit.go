@@ -0,0 +1,297 @@
+// Package circuit implements a circuit breaker on a `fsm.FSM` of `templates.CircuitBreaker`, so the breaker is a
+// standard machine which can be rendered and observed like others:
+//
+//	breaker, _ := circuit.New("payments", circuit.Config{FailureRate: 0.5, OpenTimeout: 10 * time.Second})
+//	breaker.Machine().AddObserver(m.Observer("payments"))
+//	...
+//	err := breaker.Do(func() error {
+//		return client.Charge(ctx, order)
+//	})
+//	if errors.Is(err, circuit.ErrOpen) {
+//		... // fail fast
+//	}
+package circuit
+
+import (
+	"errors"
+	"github.com/reyoung/fsm"
+	"github.com/reyoung/fsm/templates"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned by `Breaker.Allow` and `Breaker.Do` if the call is rejected, because the breaker is open, or
+// the probes of the half-open breaker are running.
+var ErrOpen = errors.New("circuit breaker is open")
+
+// The handler names of the definition of `Definition`.
+const (
+	tripGuard      = "tripped"
+	openAction     = "open"
+	probingGuard   = "probing"
+	probeSucceeded = "probeSucceeded"
+)
+
+// Config configures a `Breaker`. The zero values are replaced by the defaults.
+type Config struct {
+	// Window is the number of the recent calls whose failure rate is checked in the closed state, 100 by default.
+	Window int
+	// MinimumCalls is the number of the calls in the window before the breaker can open, 10 by default.
+	MinimumCalls int
+	// FailureRate opens the breaker when the failure rate of the window reaches it, 0.5 by default.
+	FailureRate float64
+	// OpenTimeout is the duration of the open state before the probes are allowed, 30 seconds by default.
+	OpenTimeout time.Duration
+	// HalfOpenProbes is the number of the probes allowed concurrently in the half-open state, all of which should
+	// succeed to close the breaker, 1 by default.
+	HalfOpenProbes int
+	// IsFailure returns true if the error of a call is a failure, e.g., to ignore the client errors. All non-nil
+	// errors are failures if it is nil.
+	IsFailure func(err error) bool
+	// Clock measures the open state, the system clock if it is nil.
+	Clock fsm.Clock
+}
+
+func (c *Config) setDefaults() {
+	if c.Window <= 0 {
+		c.Window = 100
+	}
+	if c.MinimumCalls <= 0 {
+		c.MinimumCalls = 10
+	}
+	if c.MinimumCalls > c.Window {
+		c.MinimumCalls = c.Window
+	}
+	if c.FailureRate <= 0 {
+		c.FailureRate = 0.5
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = 30 * time.Second
+	}
+	if c.HalfOpenProbes <= 0 {
+		c.HalfOpenProbes = 1
+	}
+	if c.IsFailure == nil {
+		c.IsFailure = func(err error) bool {
+			return err != nil
+		}
+	}
+}
+
+// Metrics are the counters of a `Breaker`.
+type Metrics struct {
+	// State is `templates.BreakerClosed`, `templates.BreakerOpen` or `templates.BreakerHalfOpen`.
+	State string
+	// FailureRate is the failure rate of the window of the closed state.
+	FailureRate float64
+	Successes   uint64
+	Failures    uint64
+	// Rejected is the number of the calls rejected by `ErrOpen`.
+	Rejected uint64
+	// Opened is the number of times the breaker opened.
+	Opened uint64
+}
+
+// Definition returns the definition of the machine of `Breaker`: `templates.CircuitBreaker` opened by the failure
+// rate, and a transition counting the successful probes of the half-open state until all `Config.HalfOpenProbes`
+// succeed. The window of the failure rate is cleared when the breaker leaves the closed state.
+func Definition() *fsm.Definition {
+	def := templates.CircuitBreaker(templates.CircuitBreakerOptions{TripGuard: tripGuard,
+		OpenAction: openAction})
+	// the guarded transition should be evaluated before the one closing the breaker.
+	transitions := make([]fsm.TransitionDefinition, 0, len(def.Transitions)+1)
+	for _, t := range def.Transitions {
+		if t.From == templates.BreakerHalfOpen && t.Event == templates.BreakerSuccess {
+			transitions = append(transitions, fsm.TransitionDefinition{From: templates.BreakerHalfOpen,
+				Event: templates.BreakerSuccess, To: templates.BreakerHalfOpen, Action: probeSucceeded,
+				Guard: probingGuard})
+		}
+		transitions = append(transitions, t)
+	}
+	def.Transitions = transitions
+	return def
+}
+
+// Breaker is a circuit breaker. It is thread-safe.
+type Breaker struct {
+	cfg     Config
+	mu      sync.Mutex
+	machine *fsm.FSM
+	// outcomes is the ring buffer of the window, true for the failures.
+	outcomes []bool
+	next     int
+	calls    int
+	failures int
+	openedAt time.Time
+	// probes is the number of the running probes, probed is the number of the succeeded ones.
+	probes int
+	probed int
+	// generation is increased by each state change, so the results of the calls allowed in the previous states
+	// are ignored.
+	generation uint64
+	metrics    Metrics
+}
+
+// New creates a closed breaker, the name is the name of its machine.
+func New(name string, cfg Config) (*Breaker, error) {
+	cfg.setDefaults()
+	b := &Breaker{cfg: cfg, outcomes: make([]bool, cfg.Window)}
+	registry := fsm.NewHandlerRegistry().
+		MustRegisterGuard(tripGuard, func(interface{}, fsm.Event) bool {
+			return b.calls >= b.cfg.MinimumCalls && b.failureRate() >= b.cfg.FailureRate
+		}).
+		MustRegisterAction(openAction, func(interface{}, fsm.Event) error {
+			b.openedAt = b.machine.Clock().Now()
+			b.metrics.Opened++
+			return nil
+		}).
+		MustRegisterGuard(probingGuard, func(interface{}, fsm.Event) bool {
+			return b.probed+1 < b.cfg.HalfOpenProbes
+		}).
+		MustRegisterAction(probeSucceeded, func(interface{}, fsm.Event) error {
+			b.probed++
+			return nil
+		})
+	machine, err := fsm.NewFSMFromDefinition(Definition(), registry, nil)
+	if err != nil {
+		return nil, err
+	}
+	machine.SetName(name)
+	if cfg.Clock != nil {
+		machine.SetClock(cfg.Clock)
+	}
+	b.machine = machine
+	return b, nil
+}
+
+// Machine returns the machine of the breaker, e.g., to add observers or to render diagrams.
+// NOTE: the machine should not be changed after the breaker is used, and its events are processed by the
+// breaker only.
+func (b *Breaker) Machine() *fsm.FSM {
+	return b.machine
+}
+
+// State returns the current state, see `Metrics.State`.
+func (b *Breaker) State() string {
+	return b.machine.CurrentState().FSMStateID()
+}
+
+// Allow returns nil if a call is allowed, and done should be invoked with the result of the call. It returns
+// `ErrOpen` if the call is rejected.
+func (b *Breaker) Allow() (done func(err error), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state := b.State()
+	if state == templates.BreakerOpen {
+		if b.machine.Clock().Now().Sub(b.openedAt) < b.cfg.OpenTimeout {
+			b.metrics.Rejected++
+			return nil, ErrOpen
+		}
+		b.fire(templates.BreakerCooldown)
+		state = b.State()
+	}
+	if state == templates.BreakerHalfOpen {
+		if b.probes+b.probed >= b.cfg.HalfOpenProbes {
+			b.metrics.Rejected++
+			return nil, ErrOpen
+		}
+		b.probes++
+	}
+	generation := b.generation
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			b.done(generation, b.cfg.IsFailure(err))
+		})
+	}, nil
+}
+
+// Do invokes fn if the call is allowed, and records its result. It returns the error of fn, or `ErrOpen`.
+func (b *Breaker) Do(fn func() error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	err = fn()
+	done(err)
+	return err
+}
+
+// Metrics returns the counters of the breaker.
+func (b *Breaker) Metrics() Metrics {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	metrics := b.metrics
+	metrics.State = b.State()
+	metrics.FailureRate = b.failureRate()
+	return metrics
+}
+
+func (b *Breaker) done(generation uint64, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if failed {
+		b.metrics.Failures++
+	} else {
+		b.metrics.Successes++
+	}
+	if generation != b.generation {
+		return
+	}
+	switch b.State() {
+	case templates.BreakerClosed:
+		b.record(failed)
+	case templates.BreakerHalfOpen:
+		b.probes--
+	}
+	if failed {
+		b.fire(templates.BreakerFailure)
+	} else {
+		b.fire(templates.BreakerSuccess)
+	}
+}
+
+// fire processes the event, the caller should hold mu.
+func (b *Breaker) fire(evID string) {
+	prev := b.State()
+	// the events are fired only in the states accepting them.
+	_ = b.machine.ProcessEvent(fsm.StringEvent(evID))
+	if b.State() != prev {
+		b.generation++
+		b.probes = 0
+		b.probed = 0
+		if prev == templates.BreakerClosed {
+			b.resetWindow()
+		}
+	}
+}
+
+// record adds the outcome of a call to the window.
+func (b *Breaker) record(failed bool) {
+	if b.calls == len(b.outcomes) {
+		if b.outcomes[b.next] {
+			b.failures--
+		}
+	} else {
+		b.calls++
+	}
+	b.outcomes[b.next] = failed
+	if failed {
+		b.failures++
+	}
+	b.next = (b.next + 1) % len(b.outcomes)
+}
+
+func (b *Breaker) resetWindow() {
+	for i := range b.outcomes {
+		b.outcomes[i] = false
+	}
+	b.next, b.calls, b.failures = 0, 0, 0
+}
+
+func (b *Breaker) failureRate() float64 {
+	if b.calls == 0 {
+		return 0
+	}
+	return float64(b.failures) / float64(b.calls)
+}
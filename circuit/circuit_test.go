@@ -0,0 +1,96 @@
+package circuit
+
+import (
+	"errors"
+	"github.com/reyoung/fsm"
+	"github.com/reyoung/fsm/fsmtest"
+	"github.com/reyoung/fsm/templates"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var errUnavailable = errors.New("unavailable")
+
+func call(err error) func() error {
+	return func() error {
+		return err
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	clock := fsmtest.NewFakeClock(time.Unix(0, 0))
+	breaker, err := New("payments", Config{Window: 4, MinimumCalls: 4, FailureRate: 0.5, OpenTimeout: time.Minute,
+		HalfOpenProbes: 2, Clock: clock})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "payments", breaker.Machine().Name())
+
+	// the breaker opens when the failure rate of 4 calls reaches 0.5.
+	assert.Nil(t, breaker.Do(call(nil)))
+	assert.Equal(t, errUnavailable, breaker.Do(call(errUnavailable)))
+	assert.Nil(t, breaker.Do(call(nil)))
+	assert.Equal(t, templates.BreakerClosed, breaker.State())
+	assert.Equal(t, errUnavailable, breaker.Do(call(errUnavailable)))
+	assert.Equal(t, templates.BreakerOpen, breaker.State())
+	assert.Equal(t, ErrOpen, breaker.Do(call(nil)))
+
+	// 2 probes are allowed after the open timeout, and both should succeed.
+	clock.Advance(time.Minute)
+	first, err := breaker.Allow()
+	assert.Nil(t, err)
+	assert.Equal(t, templates.BreakerHalfOpen, breaker.State())
+	second, err := breaker.Allow()
+	assert.Nil(t, err)
+	_, err = breaker.Allow()
+	assert.Equal(t, ErrOpen, err)
+	first(nil)
+	assert.Equal(t, templates.BreakerHalfOpen, breaker.State())
+	_, err = breaker.Allow()
+	assert.Equal(t, ErrOpen, err)
+	second(nil)
+	assert.Equal(t, templates.BreakerClosed, breaker.State())
+
+	assert.Equal(t, Metrics{State: templates.BreakerClosed, Successes: 4, Failures: 2, Rejected: 3, Opened: 1},
+		breaker.Metrics())
+}
+
+func TestBreakerHalfOpenFailure(t *testing.T) {
+	clock := fsmtest.NewFakeClock(time.Unix(0, 0))
+	breaker, err := New("search", Config{Window: 2, MinimumCalls: 1, FailureRate: 1,
+		IsFailure: func(err error) bool { return err == errUnavailable }, Clock: clock})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Equal(t, errors.ErrUnsupported, breaker.Do(call(errors.ErrUnsupported)))
+	assert.Equal(t, templates.BreakerClosed, breaker.State())
+	// a late result of the call allowed by the closed state is ignored by the half-open state.
+	late, err := breaker.Allow()
+	assert.Nil(t, err)
+	assert.Equal(t, errUnavailable, breaker.Do(call(errUnavailable)))
+	assert.Equal(t, templates.BreakerClosed, breaker.State())
+	assert.Equal(t, errUnavailable, breaker.Do(call(errUnavailable)))
+	assert.Equal(t, templates.BreakerOpen, breaker.State())
+	clock.Advance(30 * time.Second)
+	probe, err := breaker.Allow()
+	assert.Nil(t, err)
+	late(nil)
+	assert.Equal(t, templates.BreakerHalfOpen, breaker.State())
+	probe(errUnavailable)
+	assert.Equal(t, templates.BreakerOpen, breaker.State())
+	assert.Equal(t, uint64(2), breaker.Metrics().Opened)
+}
+
+func TestDefinition(t *testing.T) {
+	breaker, err := New("inventory", Config{})
+	if !assert.Nil(t, err) {
+		return
+	}
+	def := breaker.Machine().Definition()
+	assert.Equal(t, []string{templates.BreakerClosed, templates.BreakerHalfOpen, templates.BreakerOpen},
+		def.States)
+	assert.Contains(t, def.Transitions, fsm.TransitionDefinition{From: templates.BreakerHalfOpen,
+		Event: templates.BreakerSuccess, To: templates.BreakerHalfOpen, Action: probeSucceeded, Guard: probingGuard})
+}
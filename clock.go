@@ -0,0 +1,127 @@
+package fsm
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Clock is the source of time, e.g., of the action timeouts, the retry backoffs, the stats, the timestamps of
+// `StateChange` and `DebugFrame`, and the idle timeout of `Manager`. It is `SystemClock` by default, and can be
+// replaced by a fake clock, e.g., `fsmtest.FakeClock`, to test the time-based behaviors without sleeping.
+type Clock interface {
+	Now() time.Time
+	// AfterFunc invokes f after the duration, see `time.AfterFunc`.
+	AfterFunc(d time.Duration, f func()) Timer
+	// NewTicker returns a ticker sending the time every period d, see `time.NewTicker`.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is a timer created by `Clock.AfterFunc`.
+type Timer interface {
+	// Stop prevents the timer from firing. It returns false if the timer has fired or been stopped.
+	Stop() bool
+}
+
+// Ticker is a ticker created by `Clock.NewTicker`.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// SystemClock is the `Clock` of the package time.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// SetClock sets the clock of the FSM. It should be invoked before processing events. The visit of the current
+// state is restarted by the clock, see `Stats`.
+// NOTE: the clocks of the sub-machines are not changed.
+func (fsm *FSM) SetClock(clock Clock) {
+	if clock == nil {
+		clock = SystemClock
+	}
+	fsm.clock = clock
+	fsm.stats.restart(clock.Now())
+}
+
+// Clock returns the clock of the FSM, see `SetClock`.
+func (fsm *FSM) Clock() Clock {
+	return fsm.clock
+}
+
+// Sleep pauses the current goroutine for the duration of clock. It returns early with the error of ctx if ctx
+// is done.
+func Sleep(ctx context.Context, clock Clock, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	done := make(chan struct{})
+	timer := clock.AfterFunc(d, func() {
+		close(done)
+	})
+	defer timer.Stop()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WithTimeout is the same as `context.WithTimeout`, but the deadline is measured by clock.
+func WithTimeout(parent context.Context, clock Clock, d time.Duration) (context.Context, context.CancelFunc) {
+	if clock == SystemClock {
+		return context.WithTimeout(parent, d)
+	}
+	ctx, cancel := context.WithCancel(parent)
+	result := &clockTimeoutContext{Context: ctx, deadline: clock.Now().Add(d)}
+	timer := clock.AfterFunc(d, func() {
+		if ctx.Err() == nil {
+			result.exceeded.Store(true)
+			cancel()
+		}
+	})
+	return result, func() {
+		timer.Stop()
+		cancel()
+	}
+}
+
+// clockTimeoutContext is a context whose deadline is measured by a `Clock`.
+type clockTimeoutContext struct {
+	context.Context
+	deadline time.Time
+	exceeded atomic.Bool
+}
+
+func (c *clockTimeoutContext) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+func (c *clockTimeoutContext) Err() error {
+	if c.exceeded.Load() {
+		return context.DeadlineExceeded
+	}
+	return c.Context.Err()
+}
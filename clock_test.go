@@ -0,0 +1,55 @@
+package fsm
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// manualClock is moved by the tests, its timers are the ones of `SystemClock`.
+type manualClock struct {
+	systemClock
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	return c.now
+}
+
+func TestSleep(t *testing.T) {
+	assert.Nil(t, Sleep(context.Background(), SystemClock, time.Millisecond))
+	assert.Nil(t, Sleep(context.Background(), SystemClock, 0))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, Sleep(ctx, SystemClock, time.Hour))
+}
+
+func TestWithTimeout(t *testing.T) {
+	clock := &manualClock{now: time.Unix(0, 0)}
+	ctx, cancel := WithTimeout(context.Background(), clock, time.Millisecond)
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.Equal(t, time.Unix(0, int64(time.Millisecond)), deadline)
+	<-ctx.Done()
+	assert.Equal(t, context.DeadlineExceeded, ctx.Err())
+	cancel()
+	assert.Equal(t, context.DeadlineExceeded, ctx.Err())
+
+	ctx, cancel = WithTimeout(context.Background(), clock, time.Hour)
+	cancel()
+	assert.Equal(t, context.Canceled, ctx.Err())
+
+	ctx, cancel = WithTimeout(context.Background(), SystemClock, time.Hour)
+	defer cancel()
+	deadline, ok = ctx.Deadline()
+	assert.True(t, ok)
+	assert.True(t, deadline.After(time.Now().Add(time.Minute)))
+
+	fsm := NewFSM(StringState("idle"), nil)
+	assert.Equal(t, SystemClock, fsm.Clock())
+	fsm.SetClock(clock)
+	assert.Equal(t, clock, fsm.Clock())
+	fsm.SetClock(nil)
+	assert.Equal(t, SystemClock, fsm.Clock())
+}
@@ -0,0 +1,97 @@
+// Package etcd implements `cluster.Election` by the elections of etcd.
+//
+//	client, _ := clientv3.New(clientv3.Config{Endpoints: endpoints})
+//	election := etcd.NewElection(client, "/fsm/orders/leader", hostname, 10)
+//	defer election.Close()
+//	host := cluster.NewHost(election, store, codec)
+package etcd
+
+import (
+	"context"
+	"github.com/reyoung/fsm/cluster"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+	"sync"
+)
+
+// Election campaigns under a key prefix of etcd. The leadership is bound to a session, i.e., a lease kept alive
+// by the client, and lost when the session expires, e.g., the host is partitioned from etcd. A new session is
+// created by the next `Campaign` then.
+type Election struct {
+	client *clientv3.Client
+	prefix string
+	value  string
+	ttl    int
+
+	mu       sync.Mutex
+	session  *concurrency.Session
+	election *concurrency.Election
+}
+
+var _ cluster.Election = (*Election)(nil)
+
+// NewElection creates an election of the hosts sharing prefix. The value identifies the host, e.g., its address.
+// The ttl is the seconds of the session lease, the leadership is lost if the host is unreachable longer than it.
+func NewElection(client *clientv3.Client, prefix string, value string, ttl int) *Election {
+	return &Election{client: client, prefix: prefix, value: value, ttl: ttl}
+}
+
+// current returns the election of the alive session, a new session is created if it expired.
+func (e *Election) current() (*concurrency.Election, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.session != nil {
+		select {
+		case <-e.session.Done():
+			e.session, e.election = nil, nil
+		default:
+			return e.election, nil
+		}
+	}
+	session, err := concurrency.NewSession(e.client, concurrency.WithTTL(e.ttl))
+	if err != nil {
+		return nil, err
+	}
+	e.session, e.election = session, concurrency.NewElection(session, e.prefix)
+	return e.election, nil
+}
+
+func (e *Election) Campaign(ctx context.Context) error {
+	election, err := e.current()
+	if err != nil {
+		return err
+	}
+	return election.Campaign(ctx, e.value)
+}
+
+// Done returns the channel closed when the session expires.
+func (e *Election) Done() <-chan struct{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.session == nil {
+		return nil
+	}
+	return e.session.Done()
+}
+
+func (e *Election) Resign(ctx context.Context) error {
+	e.mu.Lock()
+	election := e.election
+	e.mu.Unlock()
+	if election == nil {
+		return nil
+	}
+	return election.Resign(ctx)
+}
+
+// Close closes the session, which revokes its lease, so the leadership is released at once.
+func (e *Election) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.session == nil {
+		return nil
+	}
+	err := e.session.Close()
+	e.session, e.election = nil, nil
+	return err
+}
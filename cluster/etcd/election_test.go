@@ -0,0 +1,51 @@
+package etcd
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newClient connects to the etcd of `FSM_ETCD_ENDPOINTS`, a comma separated list, or skips the test.
+func newClient(t *testing.T) *clientv3.Client {
+	endpoints := os.Getenv("FSM_ETCD_ENDPOINTS")
+	if endpoints == "" {
+		t.Skip("FSM_ETCD_ENDPOINTS is not set")
+	}
+	client, err := clientv3.New(clientv3.Config{Endpoints: strings.Split(endpoints, ","), DialTimeout: 5 * time.Second})
+	assert.Nil(t, err)
+	return client
+}
+
+func TestElection(t *testing.T) {
+	client := newClient(t)
+	defer client.Close()
+	prefix := "/fsm-test/" + time.Now().Format(time.RFC3339Nano)
+	a, b := NewElection(client, prefix, "a", 5), NewElection(client, prefix, "b", 5)
+	defer a.Close()
+	defer b.Close()
+	ctx := context.Background()
+
+	assert.Nil(t, a.Campaign(ctx))
+	select {
+	case <-a.Done():
+		t.Fatal("the leadership of a is lost")
+	default:
+	}
+	timeout, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	assert.NotNil(t, b.Campaign(timeout))
+
+	// b is elected once a closes its session.
+	assert.Nil(t, a.Close())
+	assert.Nil(t, b.Campaign(ctx))
+	assert.Nil(t, b.Resign(ctx))
+
+	// a campaigns by a new session.
+	assert.Nil(t, a.Campaign(ctx))
+	assert.Nil(t, a.Resign(ctx))
+}
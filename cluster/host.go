@@ -0,0 +1,203 @@
+// Package cluster runs machines on the elected leader of a group of hosts. The journals of the machines are
+// stored in a shared `persist.Store`, so when the leader fails, the next leader recovers the machines and
+// resumes them.
+//
+//	host := cluster.NewHost(election, store, codec)
+//	host.Add("order-42", newOrderFSM, func(ctx context.Context, machine *persist.PersistentFSM) error {
+//		return drive(ctx, machine)
+//	})
+//	err := host.Run(ctx)
+//
+// An etcd election is provided by the module github.com/reyoung/fsm/cluster/etcd.
+package cluster
+
+import (
+	"context"
+	"github.com/reyoung/fsm"
+	"github.com/reyoung/fsm/persist"
+	"sort"
+	"sync"
+)
+
+// Election elects one leader among the hosts.
+type Election interface {
+	// Campaign blocks until the host is elected, or ctx is done.
+	Campaign(ctx context.Context) error
+	// Done returns a channel which is closed when the leadership gained by the last `Campaign` is lost.
+	Done() <-chan struct{}
+	// Resign gives up the leadership, so another host can be elected.
+	Resign(ctx context.Context) error
+}
+
+// RunFunc drives a machine while the host is the leader. It should return when ctx is done, i.e., the leadership
+// is lost or the host stops. The machine is only used by the function, since `persist.PersistentFSM` is not
+// thread-safe.
+type RunFunc func(ctx context.Context, machine *persist.PersistentFSM) error
+
+type hostedMachine struct {
+	id         string
+	newMachine func() *fsm.FSM
+	run        RunFunc
+}
+
+// Host runs the added machines while it is the leader. See `NewHost`.
+type Host struct {
+	election Election
+	store    persist.Store
+	codec    persist.Codec
+
+	mu       sync.Mutex
+	hosted   map[string]*hostedMachine
+	running  map[string]*persist.PersistentFSM
+	leader   bool
+	leadings int
+}
+
+// NewHost creates a host whose machines are journaled in store. The events are encoded by codec, see
+// `persist.New`.
+func NewHost(election Election, store persist.Store, codec persist.Codec) *Host {
+	return &Host{
+		election: election,
+		store:    store,
+		codec:    codec,
+		hosted:   make(map[string]*hostedMachine),
+		running:  make(map[string]*persist.PersistentFSM),
+	}
+}
+
+// Add adds the machine id, which is created by newMachine in its initial state and recovered from the store
+// whenever the host is elected. `fsm.AlreadyExists` is returned if the id is added.
+// NOTE: the machines added after the host is elected are run since the next election.
+func (h *Host) Add(id string, newMachine func() *fsm.FSM, run RunFunc) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.hosted[id]; ok {
+		return fsm.AlreadyExists
+	}
+	h.hosted[id] = &hostedMachine{id: id, newMachine: newMachine, run: run}
+	return nil
+}
+
+// IsLeader returns whether the host is the leader and running the machines.
+func (h *Host) IsLeader() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.leader
+}
+
+// Leadings returns how many times the host has been elected.
+func (h *Host) Leadings() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.leadings
+}
+
+// Machine returns the running machine id, it is only found while the host is the leader.
+// NOTE: the machine is driven by its `RunFunc`, it should not be modified by others.
+func (h *Host) Machine(id string) (*persist.PersistentFSM, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	machine, ok := h.running[id]
+	return machine, ok
+}
+
+// Run campaigns for the leadership and runs the machines while the host is the leader, then campaigns again
+// after the leadership is lost. It returns the error of ctx when ctx is done, or the error of recovering or
+// running a machine. The leadership is resigned when it returns.
+func (h *Host) Run(ctx context.Context) error {
+	for {
+		if err := h.election.Campaign(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		err := h.lead(ctx)
+		if err != nil || ctx.Err() != nil {
+			// resign by a fresh context, since ctx may be done.
+			_ = h.election.Resign(context.Background())
+			if err == nil {
+				err = ctx.Err()
+			}
+			return err
+		}
+	}
+}
+
+// lead recovers and runs the machines until the leadership is lost or ctx is done. It returns the error of a
+// machine.
+func (h *Host) lead(ctx context.Context) error {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	machines, running, err := h.recover(leaderCtx)
+	if err != nil {
+		return err
+	}
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i, m := range machines {
+		wg.Add(1)
+		go func(m *hostedMachine, machine *persist.PersistentFSM) {
+			defer wg.Done()
+			if err := m.run(leaderCtx, machine); err != nil && leaderCtx.Err() == nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(m, running[i])
+	}
+	h.setLeader(true)
+	select {
+	case <-leaderCtx.Done():
+	case <-h.election.Done():
+	}
+	cancel()
+	wg.Wait()
+	h.setLeader(false)
+	return firstErr
+}
+
+// recover creates the machines and recovers them from the store, in the order of ids.
+func (h *Host) recover(ctx context.Context) ([]*hostedMachine, []*persist.PersistentFSM, error) {
+	h.mu.Lock()
+	machines := make([]*hostedMachine, 0, len(h.hosted))
+	for _, m := range h.hosted {
+		machines = append(machines, m)
+	}
+	h.mu.Unlock()
+	sort.Slice(machines, func(i, j int) bool {
+		return machines[i].id < machines[j].id
+	})
+	running := make([]*persist.PersistentFSM, 0, len(machines))
+	for _, m := range machines {
+		machine := persist.New(m.newMachine(), m.id, h.store, h.codec)
+		if err := machine.Recover(ctx); err != nil {
+			return nil, nil, err
+		}
+		// the machine is recovered by this goroutine, but run by another one, see `lead`.
+		machine.ReleaseOwnership()
+		running = append(running, machine)
+	}
+	h.mu.Lock()
+	for _, machine := range running {
+		h.running[machine.ID()] = machine
+	}
+	h.mu.Unlock()
+	return machines, running, nil
+}
+
+func (h *Host) setLeader(leader bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.leader = leader
+	if leader {
+		h.leadings++
+	} else {
+		h.running = make(map[string]*persist.PersistentFSM)
+	}
+}
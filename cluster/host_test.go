@@ -0,0 +1,137 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"github.com/reyoung/fsm"
+	"github.com/reyoung/fsm/persist"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// seat is the leadership shared by the fake elections.
+type seat struct {
+	mu     sync.Mutex
+	leader *fakeElection
+}
+
+type fakeElection struct {
+	seat *seat
+	done chan struct{}
+}
+
+func (e *fakeElection) Campaign(ctx context.Context) error {
+	for {
+		e.seat.mu.Lock()
+		if e.seat.leader == nil {
+			e.seat.leader, e.done = e, make(chan struct{})
+			e.seat.mu.Unlock()
+			return nil
+		}
+		e.seat.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func (e *fakeElection) Done() <-chan struct{} {
+	e.seat.mu.Lock()
+	defer e.seat.mu.Unlock()
+	return e.done
+}
+
+// Resign is also used to simulate the loss of the leadership.
+func (e *fakeElection) Resign(context.Context) error {
+	e.seat.mu.Lock()
+	defer e.seat.mu.Unlock()
+	if e.seat.leader == e {
+		e.seat.leader = nil
+		close(e.done)
+	}
+	return nil
+}
+
+func newLight() *fsm.FSM {
+	var (
+		on  = fsm.StringState("on")
+		off = fsm.StringState("off")
+	)
+	machine := fsm.NewFSM(off, nil)
+	_ = machine.AddState(on)
+	_ = machine.AddEvent("switch")
+	_ = machine.AddTransition(off, "switch", on, nil, nil)
+	_ = machine.AddTransition(on, "switch", off, nil, nil)
+	// the machines are recovered and run by different goroutines.
+	machine.SetOwnershipCheck(true)
+	return machine
+}
+
+func TestHostFailover(t *testing.T) {
+	store, s := persist.NewMemoryStore(), &seat{}
+	processed := make(chan fsm.State, 4)
+	// every leader switches the light once.
+	run := func(ctx context.Context, machine *persist.PersistentFSM) error {
+		if err := machine.ProcessEventContext(ctx, fsm.StringEvent("switch")); err != nil {
+			return err
+		}
+		processed <- machine.CurrentState()
+		<-ctx.Done()
+		return nil
+	}
+	electionA, electionB := &fakeElection{seat: s}, &fakeElection{seat: s}
+	a, b := NewHost(electionA, store, nil), NewHost(electionB, store, nil)
+	assert.Nil(t, a.Add("light", newLight, run))
+	assert.Equal(t, fsm.AlreadyExists, a.Add("light", newLight, run))
+	assert.Nil(t, b.Add("light", newLight, run))
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	doneA := make(chan error)
+	go func() { doneA <- a.Run(ctxA) }()
+	assert.Equal(t, fsm.StringState("on"), <-processed)
+	assert.Eventually(t, a.IsLeader, time.Second, time.Millisecond)
+	machine, ok := a.Machine("light")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), machine.Seq())
+
+	ctxB, cancelB := context.WithCancel(context.Background())
+	doneB := make(chan error)
+	go func() { doneB <- b.Run(ctxB) }()
+	assert.False(t, b.IsLeader())
+
+	// b recovers the light switched by a.
+	cancelA()
+	assert.Equal(t, context.Canceled, <-doneA)
+	assert.False(t, a.IsLeader())
+	_, ok = a.Machine("light")
+	assert.False(t, ok)
+	assert.Equal(t, fsm.StringState("off"), <-processed)
+	assert.Eventually(t, b.IsLeader, time.Second, time.Millisecond)
+
+	// b is elected again after it loses the leadership.
+	assert.Nil(t, electionB.Resign(context.Background()))
+	assert.Equal(t, fsm.StringState("on"), <-processed)
+	assert.Eventually(t, func() bool { return b.Leadings() == 2 }, time.Second, time.Millisecond)
+	machine, ok = b.Machine("light")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(3), machine.Seq())
+
+	cancelB()
+	assert.Equal(t, context.Canceled, <-doneB)
+	assert.Nil(t, s.leader)
+}
+
+func TestHostRunError(t *testing.T) {
+	s := &seat{}
+	host := NewHost(&fakeElection{seat: s}, persist.NewMemoryStore(), nil)
+	assert.Nil(t, host.Add("light", newLight, func(ctx context.Context, machine *persist.PersistentFSM) error {
+		return errors.New("broken")
+	}))
+	assert.Equal(t, "broken", host.Run(context.Background()).Error())
+	assert.False(t, host.IsLeader())
+	assert.Nil(t, s.leader)
+}
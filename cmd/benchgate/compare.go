@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// result is the samples of a benchmark, one per run.
+type result struct {
+	nsPerOp     []float64
+	allocsPerOp []float64
+}
+
+// parse reads the benchmark lines of the output of `go test -bench`, e.g.,
+//
+//	BenchmarkProcessEvent-8   	 2000000	       594.7 ns/op	       0 B/op	       0 allocs/op
+//
+// The other lines are skipped. The GOMAXPROCS suffix of the names is removed, so the results of different machines
+// can be compared.
+func parse(r io.Reader) (map[string]*result, error) {
+	results := make(map[string]*result)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+		name := trimProcs(fields[0])
+		res, ok := results[name]
+		if !ok {
+			res = &result{}
+			results[name] = res
+		}
+		// the metrics are pairs of a value and a unit after the iterations.
+		for i := 2; i+1 < len(fields); i += 2 {
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %s of %s", fields[i], fields[0])
+			}
+			switch fields[i+1] {
+			case "ns/op":
+				res.nsPerOp = append(res.nsPerOp, value)
+			case "allocs/op":
+				res.allocsPerOp = append(res.allocsPerOp, value)
+			}
+		}
+	}
+	return results, scanner.Err()
+}
+
+// trimProcs removes the "-N" suffix of a benchmark name.
+func trimProcs(name string) string {
+	i := strings.LastIndexByte(name, '-')
+	if i < 0 {
+		return name
+	}
+	if _, err := strconv.Atoi(name[i+1:]); err != nil {
+		return name
+	}
+	return name[:i]
+}
+
+func median(samples []float64) float64 {
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// report is the comparison of two benchmark outputs.
+type report struct {
+	lines       []string
+	regressions []string
+}
+
+func (r *report) String() string {
+	var sb strings.Builder
+	for _, line := range r.lines {
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+	if len(r.regressions) == 0 {
+		sb.WriteString("no regressions\n")
+	} else {
+		fmt.Fprintf(&sb, "%d regressions: %s\n", len(r.regressions), strings.Join(r.regressions, ", "))
+	}
+	return sb.String()
+}
+
+// compare compares the medians of the benchmarks in both old and cur. The ns/op regresses if it is slower by more
+// than threshold percent, and the allocs/op regresses if it increases.
+func compare(old, cur map[string]*result, threshold float64) *report {
+	names := make([]string, 0, len(cur))
+	for name := range cur {
+		if _, ok := old[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	r := &report{}
+	for _, name := range names {
+		o, c := old[name], cur[name]
+		regressed := false
+		line := name
+		if len(o.nsPerOp) != 0 && len(c.nsPerOp) != 0 {
+			on, cn := median(o.nsPerOp), median(c.nsPerOp)
+			delta := (cn - on) / on * 100
+			line += fmt.Sprintf("\t%.1f -> %.1f ns/op (%+.1f%%)", on, cn, delta)
+			regressed = delta > threshold
+		}
+		if len(o.allocsPerOp) != 0 && len(c.allocsPerOp) != 0 {
+			oa, ca := median(o.allocsPerOp), median(c.allocsPerOp)
+			line += fmt.Sprintf("\t%g -> %g allocs/op", oa, ca)
+			regressed = regressed || ca > oa
+		}
+		if regressed {
+			line += "\tREGRESSED"
+			r.regressions = append(r.regressions, name)
+		}
+		r.lines = append(r.lines, line)
+	}
+	return r
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	results, err := parseFile("testdata/old.txt")
+	assert.Nil(t, err)
+	assert.Len(t, results, 4)
+	assert.Equal(t, []float64{600, 620, 580}, results["BenchmarkProcessEvent"].nsPerOp)
+	assert.Equal(t, []float64{0, 0, 0}, results["BenchmarkProcessEvent"].allocsPerOp)
+	assert.Equal(t, []float64{20}, results["BenchmarkLookup/states=16"].nsPerOp)
+	assert.Nil(t, results["BenchmarkLookup/states=16"].allocsPerOp)
+
+	_, err = parse(strings.NewReader("BenchmarkBad-8 100 fast ns/op\n"))
+	assert.NotNil(t, err)
+}
+
+func TestCompare(t *testing.T) {
+	old, err := parseFile("testdata/old.txt")
+	assert.Nil(t, err)
+	cur, err := parseFile("testdata/new.txt")
+	assert.Nil(t, err)
+
+	// ProcessEvent is 6.7% slower but allocates, Queued is 20% slower.
+	r := compare(old, cur, 10)
+	assert.Equal(t, []string{"BenchmarkProcessEvent", "BenchmarkQueued"}, r.regressions)
+	assert.Len(t, r.lines, 3)
+	assert.Equal(t, "BenchmarkLookup/states=16\t20.0 -> 10.0 ns/op (-50.0%)", r.lines[0])
+	assert.Contains(t, r.String(), "2 regressions")
+
+	r = compare(old, old, 10)
+	assert.Len(t, r.regressions, 0)
+	assert.Contains(t, r.String(), "no regressions")
+}
+
+func TestMedian(t *testing.T) {
+	assert.Equal(t, 2.0, median([]float64{3, 1, 2}))
+	assert.Equal(t, 2.5, median([]float64{4, 1, 2, 3}))
+}
@@ -0,0 +1,51 @@
+// Command benchgate compares the benchmark results of a change with a baseline, and fails if any benchmark
+// regresses. The inputs are the outputs of `go test -bench`, preferably of several runs by `-count`, so they can
+// also be compared by benchstat.
+//
+// Usage:
+//
+//	go test -run '^$' -bench . -benchmem -count 5 . > /tmp/new.txt
+//	benchgate -threshold 10 testdata/benchmarks.txt /tmp/new.txt
+//
+// The median ns/op of a benchmark regresses if it is slower than the baseline by more than -threshold percent, and
+// the median allocs/op regresses if it increases at all. The benchmarks only in one of the inputs are ignored.
+// It exits with 1 if there are regressions.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	threshold := flag.Float64("threshold", 10, "the max percentage of the slowdown of ns/op")
+	flag.Parse()
+	if flag.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "usage: benchgate [-threshold percent] old.txt new.txt")
+		os.Exit(2)
+	}
+	old, err := parseFile(flag.Arg(0))
+	if err == nil {
+		var cur map[string]*result
+		if cur, err = parseFile(flag.Arg(1)); err == nil {
+			report := compare(old, cur, *threshold)
+			fmt.Print(report.String())
+			if len(report.regressions) != 0 {
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	fmt.Fprintln(os.Stderr, "benchgate:", err)
+	os.Exit(2)
+}
+
+func parseFile(path string) (map[string]*result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parse(f)
+}
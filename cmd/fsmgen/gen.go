@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/reyoung/fsm"
+	"go/format"
+	"go/token"
+	"gopkg.in/yaml.v2"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+func detectFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".scxml", ".xml":
+		return "scxml"
+	default:
+		return "json"
+	}
+}
+
+func parseDefinition(data []byte, format string) (*fsm.Definition, error) {
+	def := &fsm.Definition{}
+	var err error
+	switch format {
+	case "json":
+		err = json.Unmarshal(data, def)
+	case "yaml":
+		err = yaml.Unmarshal(data, def)
+	case "scxml":
+		def, err = parseSCXML(data)
+	default:
+		err = fmt.Errorf("unknown definition format %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(def.Composites) != 0 {
+		// the generated exhaustive test assumes flat states.
+		return nil, errors.New("composite states are not supported by fsmgen yet")
+	}
+	// build the machine once to validate the definition. Handlers are not needed for validation.
+	check := *def
+	check.Transitions = make([]fsm.TransitionDefinition, len(def.Transitions))
+	for i, t := range def.Transitions {
+		t.Action, t.Guard = "", ""
+		check.Transitions[i] = t
+	}
+	if _, err := fsm.NewFSMFromDefinition(&check, nil, nil); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+// identifier converts an id like "turn-on" or "turn_on" to an exported Go identifier "TurnOn".
+func identifier(id string) string {
+	b := &strings.Builder{}
+	upper := true
+	for _, r := range id {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	result := b.String()
+	if result == "" || !unicode.IsLetter([]rune(result)[0]) {
+		result = "X" + result
+	}
+	return result
+}
+
+type genState struct {
+	ID    string
+	Const string
+}
+
+type genEvent struct {
+	ID     string
+	Struct string
+	Method string
+}
+
+type genCase struct {
+	From  string
+	Event string
+	To    string
+}
+
+type generator struct {
+	Package    string
+	Type       string
+	Definition *fsm.Definition
+	States     []genState
+	Events     []genEvent
+	Actions    []string
+	Guards     []string
+	// Permissions is true if any transition requires permissions, see `fsm.FSM.SetAuthorizer`.
+	Permissions bool
+	Cases       []genCase
+	eventByID   map[string]genEvent
+	stateByID   map[string]genState
+	definedName map[string]string
+}
+
+func newGenerator(def *fsm.Definition, pkg string, typName string) (*generator, error) {
+	if !token.IsIdentifier(pkg) || !token.IsIdentifier(typName) {
+		return nil, errors.New("package and type should be valid identifiers")
+	}
+	g := &generator{
+		Package:    pkg,
+		Type:       typName,
+		Definition: def,
+		eventByID:  make(map[string]genEvent),
+		stateByID:  make(map[string]genState),
+		// names defined by generated code and their sources, used for collision checking.
+		definedName: map[string]string{
+			typName:                "machine type",
+			"New" + typName:        "constructor",
+			typName + "Definition": "definition function",
+		},
+	}
+	states := append([]string{def.Initial}, def.States...)
+	for _, id := range states {
+		if _, ok := g.stateByID[id]; ok {
+			continue
+		}
+		s := genState{ID: id, Const: "State" + identifier(id)}
+		if err := g.define(s.Const, "state "+id); err != nil {
+			return nil, err
+		}
+		g.stateByID[id] = s
+		g.States = append(g.States, s)
+	}
+	methods := map[string]string{"FSM": "", "CurrentState": ""}
+	for _, id := range def.Events {
+		ev := genEvent{ID: id, Struct: identifier(id) + "Event", Method: identifier(id)}
+		if err := g.define(ev.Struct, "event "+id); err != nil {
+			return nil, err
+		}
+		if _, ok := methods[ev.Method]; ok {
+			return nil, fmt.Errorf("the method %s of event %s conflicts with another method", ev.Method, id)
+		}
+		methods[ev.Method] = id
+		g.eventByID[id] = ev
+		g.Events = append(g.Events, ev)
+	}
+
+	actions, guards := make(map[string]bool), make(map[string]bool)
+	first := make(map[[2]string]string)
+	for _, t := range def.Transitions {
+		if t.Action != "" && !actions[t.Action] {
+			actions[t.Action] = true
+			g.Actions = append(g.Actions, t.Action)
+		}
+		if t.Guard != "" && !guards[t.Guard] {
+			guards[t.Guard] = true
+			g.Guards = append(g.Guards, t.Guard)
+		}
+		if len(t.Permissions) != 0 {
+			g.Permissions = true
+		}
+		if _, ok := first[[2]string{t.From, t.Event}]; !ok {
+			first[[2]string{t.From, t.Event}] = t.To
+		}
+	}
+	for _, s := range g.States {
+		for _, ev := range g.Events {
+			g.Cases = append(g.Cases, genCase{From: s.ID, Event: ev.ID, To: first[[2]string{s.ID, ev.ID}]})
+		}
+	}
+	return g, nil
+}
+
+func (g *generator) define(name string, source string) error {
+	if prev, ok := g.definedName[name]; ok {
+		return fmt.Errorf("the generated name %s of %s conflicts with %s", name, source, prev)
+	}
+	g.definedName[name] = source
+	return nil
+}
+
+func (g *generator) StateConst(id string) string { return g.stateByID[id].Const }
+
+func (g *generator) EventStruct(id string) string { return g.eventByID[id].Struct }
+
+func (g *generator) execute(tmpl *template.Template) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, g); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+func (g *generator) machine() ([]byte, error) { return g.execute(machineTemplate) }
+
+func (g *generator) test() ([]byte, error) { return g.execute(testTemplate) }
+
+var machineTemplate = template.Must(template.New("machine").Parse(`// Code generated by fsmgen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"context"
+	"github.com/reyoung/fsm"
+)
+
+// The states of {{.Type}}.
+const (
+{{- range .States}}
+	{{.Const}} = fsm.StringState({{printf "%q" .ID}})
+{{- end}}
+)
+{{range .Events}}
+// {{.Struct}} is the {{printf "%q" .ID}} event of {{$.Type}}.
+type {{.Struct}} struct{}
+
+func ({{.Struct}}) FSMEventID() string { return {{printf "%q" .ID}} }
+{{end}}
+// {{.Type}}Definition returns the definition of {{.Type}}.
+func {{.Type}}Definition() *fsm.Definition {
+	return &fsm.Definition{
+		{{- if .Definition.Version}}
+		Version: {{printf "%q" .Definition.Version}},
+		{{- end}}
+		Initial: {{printf "%q" .Definition.Initial}},
+		States: []string{ {{- range .Definition.States}}{{printf "%q" .}}, {{end -}} },
+		Events: []string{ {{- range .Definition.Events}}{{printf "%q" .}}, {{end -}} },
+		Transitions: []fsm.TransitionDefinition{
+		{{- range .Definition.Transitions}}
+			{From: {{printf "%q" .From}}, Event: {{printf "%q" .Event}}, To: {{printf "%q" .To}},
+				{{- if .Action}} Action: {{printf "%q" .Action}},{{end}}
+				{{- if .Guard}} Guard: {{printf "%q" .Guard}},{{end}}
+				{{- if .Name}} Name: {{printf "%q" .Name}},{{end}}
+				{{- if .Description}} Description: {{printf "%q" .Description}},{{end}}
+				{{- if .Tags}} Tags: {{printf "%#v" .Tags}},{{end}}
+				{{- if .Choice}} Choice: true,{{end}}
+				{{- if .Priority}} Priority: {{.Priority}},{{end}}
+				{{- if .Permissions}} Permissions: {{printf "%#v" .Permissions}},{{end}}},
+		{{- end}}
+		},
+	}
+}
+
+// {{.Type}} is a typed wrapper of fsm.FSM with one method per event.
+type {{.Type}} struct {
+	machine *fsm.FSM
+}
+
+// New{{.Type}} creates a {{.Type}}.
+{{- if or .Actions .Guards}} The registry should provide
+{{- if .Actions}} actions {{range $i, $n := .Actions}}{{if $i}}, {{end}}{{printf "%q" $n}}{{end}}{{end}}
+{{- if and .Actions .Guards}} and{{end}}
+{{- if .Guards}} guards {{range $i, $n := .Guards}}{{if $i}}, {{end}}{{printf "%q" $n}}{{end}}{{end}}.
+{{- end}}
+func New{{.Type}}(registry *fsm.HandlerRegistry, payload interface{}) (*{{.Type}}, error) {
+	machine, err := fsm.NewFSMFromDefinition({{.Type}}Definition(), registry, payload)
+	if err != nil {
+		return nil, err
+	}
+	return &{{.Type}}{machine: machine}, nil
+}
+
+// FSM returns the underlying fsm.FSM.
+func (m *{{.Type}}) FSM() *fsm.FSM { return m.machine }
+
+// CurrentState returns the current state.
+func (m *{{.Type}}) CurrentState() fsm.StringState {
+	return m.machine.CurrentState().(fsm.StringState)
+}
+{{range .Events}}
+// {{.Method}} processes the {{printf "%q" .ID}} event. The event is not processed if ctx is done.
+func (m *{{$.Type}}) {{.Method}}(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.machine.ProcessEvent({{.Struct}}{})
+}
+{{end}}`))
+
+var testTemplate = template.Must(template.New("test").Parse(`// Code generated by fsmgen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	{{- if .Permissions}}
+	"context"
+	{{- end}}
+	"github.com/reyoung/fsm"
+	"testing"
+)
+
+// Test{{.Type}}Transitions checks every (state, event) pair of {{.Type}}. All actions are no-op, all
+// guards return true and all permissions are granted, so the first transition of each pair should be taken.
+func Test{{.Type}}Transitions(t *testing.T) {
+	registry := fsm.NewHandlerRegistry()
+{{- range .Actions}}
+	registry.MustRegisterAction({{printf "%q" .}}, func(interface{}, fsm.Event) error { return nil })
+{{- end}}
+{{- range .Guards}}
+	registry.MustRegisterGuard({{printf "%q" .}}, func(interface{}, fsm.Event) bool { return true })
+{{- end}}
+	cases := []struct {
+		from  fsm.StringState
+		event fsm.Event
+		to    fsm.StringState // empty if there is no transition
+	}{
+	{{- range .Cases}}
+		{ {{- $.StateConst .From}}, {{$.EventStruct .Event}}{}, {{if .To}}{{$.StateConst .To}}{{else}}""{{end -}} },
+	{{- end}}
+	}
+	for _, c := range cases {
+		def := {{.Type}}Definition()
+		def.Initial = string(c.from)
+		machine, err := fsm.NewFSMFromDefinition(def, registry, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		{{- if .Permissions}}
+		machine.SetAuthorizer(fsm.AuthorizerFunc(func(context.Context, string, []string) bool { return true }))
+		{{- end}}
+		err = machine.ProcessEvent(c.event)
+		if c.to == "" {
+			if err == nil {
+				t.Errorf("unexpected transition from %s by %s", c.from, c.event.FSMEventID())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("transition from %s by %s: %v", c.from, c.event.FSMEventID(), err)
+		} else if machine.CurrentState() != c.to {
+			t.Errorf("transition from %s by %s: expect %s, got %s", c.from, c.event.FSMEventID(), c.to,
+				machine.CurrentState().FSMStateID())
+		}
+	}
+}
+`))
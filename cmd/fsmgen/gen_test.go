@@ -0,0 +1,101 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"testing"
+)
+
+func TestIdentifier(t *testing.T) {
+	assert.Equal(t, "TurnOn", identifier("turn-on"))
+	assert.Equal(t, "TurnOn", identifier("turn_on"))
+	assert.Equal(t, "X404", identifier("404"))
+}
+
+func TestParseSCXML(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/light.scxml")
+	assert.Nil(t, err)
+	def, err := parseDefinition(data, "scxml")
+	assert.Nil(t, err)
+	assert.Equal(t, "off", def.Initial)
+	assert.Equal(t, []string{"off", "on", "broken"}, def.States)
+	assert.Equal(t, []string{"break-down", "switch"}, def.Events)
+	assert.Equal(t, "hasPower", def.Transitions[0].Guard)
+	assert.Len(t, def.Transitions, 3)
+
+	composite := `{"initial": "a", "states": ["a", "b"], "composites": [{"state": "a", "children": ["b"]}]}`
+	_, err = parseDefinition([]byte(composite), "json")
+	assert.NotNil(t, err)
+}
+
+func TestGenerate(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/light.json")
+	assert.Nil(t, err)
+	def, err := parseDefinition(data, detectFormat("testdata/light.json"))
+	assert.Nil(t, err)
+	g, err := newGenerator(def, "light", "Light")
+	assert.Nil(t, err)
+	assert.Len(t, g.Cases, 6)
+
+	code, err := g.machine()
+	assert.Nil(t, err)
+	_, err = parser.ParseFile(token.NewFileSet(), "light_fsm.go", code, 0)
+	assert.Nil(t, err)
+	assert.Contains(t, string(code), `StateBroken = fsm.StringState("broken")`)
+	assert.Contains(t, string(code), "func (m *Light) BreakDown(ctx context.Context) error {")
+	assert.Contains(t, string(code), "type SwitchEvent struct{}")
+	assert.NotContains(t, string(code), "Version:")
+
+	def.Version = "2"
+	code, err = g.machine()
+	assert.Nil(t, err)
+	assert.Contains(t, string(code), `Version: "2",`)
+
+	code, err = g.test()
+	assert.Nil(t, err)
+	_, err = parser.ParseFile(token.NewFileSet(), "light_fsm_test.go", code, 0)
+	assert.Nil(t, err)
+	assert.Contains(t, string(code), `registry.MustRegisterGuard("hasPower"`)
+	assert.Contains(t, string(code), "{StateOn, BreakDownEvent{}, StateBroken},")
+	assert.Contains(t, string(code), `{StateOff, BreakDownEvent{}, ""},`)
+
+	def.Events = append(def.Events, "current-state")
+	_, err = newGenerator(def, "light", "Light")
+	assert.NotNil(t, err)
+}
+
+func TestGeneratePermissions(t *testing.T) {
+	data := `{"initial": "draft", "states": ["draft", "approved"], "events": ["approve"], "transitions": [
+		{"from": "draft", "event": "approve", "to": "approved", "permissions": ["approver"]}]}`
+	def, err := parseDefinition([]byte(data), "json")
+	assert.Nil(t, err)
+	g, err := newGenerator(def, "approval", "Approval")
+	assert.Nil(t, err)
+
+	code, err := g.machine()
+	assert.Nil(t, err)
+	assert.Contains(t, string(code), `Permissions: []string{"approver"}`)
+	assert.NotContains(t, string(code), "Priority:")
+
+	code, err = g.test()
+	assert.Nil(t, err)
+	_, err = parser.ParseFile(token.NewFileSet(), "approval_fsm_test.go", code, 0)
+	assert.Nil(t, err)
+	assert.Contains(t, string(code), "machine.SetAuthorizer(")
+}
+
+func TestGeneratePriority(t *testing.T) {
+	data := `{"initial": "a", "states": ["a", "b", "c"], "events": ["go"], "transitions": [
+		{"from": "a", "event": "go", "to": "b", "priority": -1},
+		{"from": "a", "event": "go", "to": "c", "priority": 2}]}`
+	def, err := parseDefinition([]byte(data), "json")
+	assert.Nil(t, err)
+	g, err := newGenerator(def, "pri", "Pri")
+	assert.Nil(t, err)
+	code, err := g.machine()
+	assert.Nil(t, err)
+	assert.Contains(t, string(code), `{From: "a", Event: "go", To: "b", Priority: -1},`)
+	assert.Contains(t, string(code), `{From: "a", Event: "go", To: "c", Priority: 2},`)
+}
@@ -0,0 +1,70 @@
+// Command fsmgen generates strongly-typed Go code from a JSON, YAML or SCXML machine definition.
+//
+// Usage:
+//
+//	fsmgen -in light.yaml -pkg light -type Light -out light_fsm.go
+//
+// It generates state constants, one struct per event, a typed wrapper with one method per event and,
+// when -test-out is given, a test which checks every (state, event) pair of the definition.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+func main() {
+	var (
+		in      = flag.String("in", "", "the machine definition file (.json, .yaml, .yml, .scxml or .xml)")
+		format  = flag.String("format", "", "the definition format: json, yaml or scxml. Detected by file extension by default")
+		pkg     = flag.String("pkg", "main", "the package name of generated code")
+		typName = flag.String("type", "Machine", "the name of generated machine type")
+		out     = flag.String("out", "", "the output file, stdout by default")
+		testOut = flag.String("test-out", "", "the output file of generated test, no test is generated by default")
+	)
+	flag.Parse()
+	if err := run(*in, *format, *pkg, *typName, *out, *testOut); err != nil {
+		fmt.Fprintln(os.Stderr, "fsmgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(in, format, pkg, typName, out, testOut string) error {
+	if in == "" {
+		return fmt.Errorf("-in is required")
+	}
+	data, err := ioutil.ReadFile(in)
+	if err != nil {
+		return err
+	}
+	if format == "" {
+		format = detectFormat(in)
+	}
+	def, err := parseDefinition(data, format)
+	if err != nil {
+		return err
+	}
+	g, err := newGenerator(def, pkg, typName)
+	if err != nil {
+		return err
+	}
+	code, err := g.machine()
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(code)
+	} else {
+		err = ioutil.WriteFile(out, code, 0644)
+	}
+	if err != nil || testOut == "" {
+		return err
+	}
+	code, err = g.test()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(testOut, code, 0644)
+}
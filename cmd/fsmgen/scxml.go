@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"github.com/reyoung/fsm"
+	"sort"
+)
+
+// scxml is the subset of SCXML understood by fsmgen. Nested states, parallel regions and executable
+// content are not supported. The `cond` attribute of a transition is used as the guard name.
+type scxml struct {
+	XMLName xml.Name     `xml:"scxml"`
+	Initial string       `xml:"initial,attr"`
+	States  []scxmlState `xml:"state"`
+	Finals  []scxmlState `xml:"final"`
+}
+
+type scxmlState struct {
+	ID          string            `xml:"id,attr"`
+	Transitions []scxmlTransition `xml:"transition"`
+}
+
+type scxmlTransition struct {
+	Event  string `xml:"event,attr"`
+	Target string `xml:"target,attr"`
+	Cond   string `xml:"cond,attr"`
+}
+
+func parseSCXML(data []byte) (*fsm.Definition, error) {
+	doc := &scxml{}
+	if err := xml.Unmarshal(data, doc); err != nil {
+		return nil, err
+	}
+	states := append(append([]scxmlState{}, doc.States...), doc.Finals...)
+	if len(states) == 0 {
+		return nil, errors.New("scxml document has no state")
+	}
+	def := &fsm.Definition{
+		Initial:     doc.Initial,
+		Transitions: make([]fsm.TransitionDefinition, 0),
+	}
+	if def.Initial == "" {
+		// the first state in document order is the initial state by default.
+		def.Initial = states[0].ID
+	}
+	events := make(map[string]bool)
+	for _, state := range states {
+		def.States = append(def.States, state.ID)
+		for _, t := range state.Transitions {
+			if t.Event == "" || t.Target == "" {
+				return nil, errors.New("eventless or targetless transitions are not supported in state " + state.ID)
+			}
+			events[t.Event] = true
+			def.Transitions = append(def.Transitions, fsm.TransitionDefinition{
+				From:  state.ID,
+				Event: t.Event,
+				To:    t.Target,
+				Guard: t.Cond,
+			})
+		}
+	}
+	for ev := range events {
+		def.Events = append(def.Events, ev)
+	}
+	sort.Strings(def.Events)
+	return def, nil
+}
@@ -0,0 +1,107 @@
+// Command fsmview is an interactive terminal inspector of a machine. It connects to the HTTP endpoint of
+// `fsm.NewHTTPHandler`, or loads a JSON or YAML machine definition.
+//
+// Usage:
+//
+//	fsmview -url http://localhost:8080/fsm -machine order-42
+//	fsmview -in light.yaml
+//
+// It draws the state graph as ASCII with the current state highlighted, and reads commands from stdin:
+// an event name injects the event into the machine, an empty line refreshes the view, and `q` quits.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+func main() {
+	var (
+		baseURL = flag.String("url", "", "the base URL of the HTTP handler of fsm.NewHTTPHandler")
+		machine = flag.String("machine", "", "the machine name, can be omitted if the endpoint serves one machine")
+		in      = flag.String("in", "", "the machine definition file (.json, .yaml or .yml), instead of -url")
+		color   = flag.Bool("color", isTerminal(os.Stdout), "highlight the current state and clear the screen by ANSI escapes")
+	)
+	flag.Parse()
+	src, err := newSource(*baseURL, *machine, *in)
+	if err == nil {
+		err = (&view{src: src, in: os.Stdin, out: os.Stdout, color: *color}).run()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fsmview:", err)
+		os.Exit(1)
+	}
+}
+
+func newSource(baseURL, machine, in string) (source, error) {
+	switch {
+	case baseURL != "" && in != "":
+		return nil, fmt.Errorf("only one of -url and -in can be given")
+	case baseURL != "":
+		return newHTTPSource(&http.Client{Timeout: 10 * time.Second}, baseURL, machine)
+	case in != "":
+		data, err := ioutil.ReadFile(in)
+		if err != nil {
+			return nil, err
+		}
+		return newLocalSource(in, data)
+	default:
+		return nil, fmt.Errorf("-url or -in is required")
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// view is the interactive loop of fsmview.
+type view struct {
+	src   source
+	in    io.Reader
+	out   io.Writer
+	color bool
+}
+
+// run draws the machine and processes the commands until `q` or the end of input.
+func (v *view) run() error {
+	scanner := bufio.NewScanner(v.in)
+	message := ""
+	for {
+		status, err := v.src.status()
+		if err != nil {
+			return err
+		}
+		if v.color {
+			fmt.Fprint(v.out, ansiClear)
+		}
+		fmt.Fprint(v.out, render(status, v.color))
+		if message != "" {
+			fmt.Fprintf(v.out, "\n%s\n", message)
+		}
+		fmt.Fprint(v.out, "\nevent (empty to refresh, q to quit)> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(v.out)
+			return scanner.Err()
+		}
+		switch cmd := strings.TrimSpace(scanner.Text()); cmd {
+		case "":
+			message = ""
+		case "q", "quit":
+			return nil
+		default:
+			if err := v.src.fire(cmd); err != nil {
+				message = fmt.Sprintf("event %s failed: %v", cmd, err)
+			} else {
+				message = fmt.Sprintf("event %s processed", cmd)
+			}
+		}
+	}
+}
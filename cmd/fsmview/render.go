@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"github.com/reyoung/fsm"
+	"sort"
+	"strings"
+)
+
+const (
+	ansiInverse = "\x1b[7m"
+	ansiReset   = "\x1b[0m"
+	ansiClear   = "\x1b[H\x1b[2J"
+)
+
+// render draws the state graph of the machine as ASCII. Every state is a box followed by its outgoing
+// transitions, the child states are indented under their composite. The current states are drawn with `#`
+// borders, and in inverse video if color is true.
+func render(status *fsm.MachineStatus, color bool) string {
+	def := status.Definition
+	if def == nil {
+		def = &fsm.Definition{Initial: status.CurrentState, States: []string{status.CurrentState}}
+	}
+	current := make(map[string]bool)
+	current[status.CurrentState] = true
+	for _, state := range status.CurrentStates {
+		current[state] = true
+	}
+	children := make(map[string][]string)
+	isChild := make(map[string]bool)
+	for _, c := range def.Composites {
+		children[c.State] = append(children[c.State], c.Children...)
+		for _, child := range c.Children {
+			isChild[child] = true
+		}
+	}
+	outgoing := make(map[string][]fsm.TransitionDefinition)
+	for _, t := range def.Transitions {
+		outgoing[t.From] = append(outgoing[t.From], t)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "machine %s  state %s  version %d\n", status.Name, status.CurrentState, status.Version)
+	var draw func(state string, depth int)
+	draw = func(state string, depth int) {
+		indent := strings.Repeat("    ", depth)
+		sb.WriteString("\n")
+		drawBox(&sb, indent, state, state == def.Initial, current[state], color)
+		for _, t := range outgoing[state] {
+			fmt.Fprintf(&sb, "%s   |--%s--> %s\n", indent, transitionLabel(t), transitionTarget(t))
+		}
+		for _, child := range children[state] {
+			draw(child, depth+1)
+		}
+	}
+	for _, state := range def.States {
+		if !isChild[state] {
+			draw(state, 0)
+		}
+	}
+	if events := availableEvents(def, current); len(events) != 0 {
+		fmt.Fprintf(&sb, "\nevents: %s\n", strings.Join(events, ", "))
+	}
+	return sb.String()
+}
+
+func drawBox(sb *strings.Builder, indent string, state string, initial bool, current bool, color bool) {
+	name := state
+	if initial {
+		name = "(*) " + state
+	}
+	border, side := "+"+strings.Repeat("-", len(name)+2)+"+", "|"
+	if current {
+		border, side = "#"+strings.Repeat("=", len(name)+2)+"#", "#"
+	}
+	middle := side + " " + name + " " + side
+	if current && color {
+		middle = ansiInverse + middle + ansiReset
+	}
+	if current {
+		middle += "  <- current"
+	}
+	fmt.Fprintf(sb, "%s%s\n%s%s\n%s%s\n", indent, border, indent, middle, indent, border)
+}
+
+func transitionLabel(t fsm.TransitionDefinition) string {
+	label := " " + t.Event
+	if t.Guard != "" {
+		label += " [" + t.Guard + "]"
+	}
+	if t.Choice {
+		label += " <choice>"
+	}
+	if len(t.Join) != 0 {
+		label += " <join " + strings.Join(t.Join, ", ") + ">"
+	}
+	return label + " "
+}
+
+func transitionTarget(t fsm.TransitionDefinition) string {
+	if len(t.Fork) != 0 {
+		return t.To + " <fork " + strings.Join(t.Fork, ", ") + ">"
+	}
+	return t.To
+}
+
+// availableEvents returns the sorted events of the transitions from the current states and their ancestors.
+// The guards are not evaluated.
+func availableEvents(def *fsm.Definition, current map[string]bool) []string {
+	parent := make(map[string]string)
+	for _, c := range def.Composites {
+		for _, child := range c.Children {
+			parent[child] = c.State
+		}
+	}
+	active := make(map[string]bool)
+	for state := range current {
+		for s := state; s != "" && !active[s]; s = parent[s] {
+			active[s] = true
+		}
+	}
+	seen := make(map[string]bool)
+	events := make([]string, 0)
+	for _, t := range def.Transitions {
+		if active[t.From] && !seen[t.Event] {
+			seen[t.Event] = true
+			events = append(events, t.Event)
+		}
+	}
+	sort.Strings(events)
+	return events
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"github.com/reyoung/fsm"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	status := &fsm.MachineStatus{
+		Name:          "light",
+		CurrentState:  "on",
+		CurrentStates: []string{"on"},
+		Version:       1,
+		Definition: &fsm.Definition{
+			Initial: "off",
+			States:  []string{"off", "on", "broken"},
+			Events:  []string{"switch", "break-down"},
+			Transitions: []fsm.TransitionDefinition{
+				{From: "off", Event: "switch", To: "on", Guard: "hasPower"},
+				{From: "on", Event: "switch", To: "off"},
+				{From: "on", Event: "break-down", To: "broken"},
+			},
+		},
+	}
+	assert.Equal(t, `machine light  state on  version 1
+
++---------+
+| (*) off |
++---------+
+   |-- switch [hasPower] --> on
+
+#====#
+# on #  <- current
+#====#
+   |-- switch --> off
+   |-- break-down --> broken
+
++--------+
+| broken |
++--------+
+
+events: break-down, switch
+`, render(status, false))
+	assert.Contains(t, render(status, true), ansiInverse+"# on #"+ansiReset)
+}
+
+func TestRenderComposite(t *testing.T) {
+	def := &fsm.Definition{
+		Initial: "idle",
+		States:  []string{"idle", "work", "a", "b"},
+		Transitions: []fsm.TransitionDefinition{
+			{From: "idle", Event: "start", To: "work", Fork: []string{"a", "b"}},
+			{From: "work", Event: "done", To: "idle", Join: []string{"a", "b"}},
+			{From: "a", Event: "step", To: "a"},
+		},
+		Composites: []fsm.CompositeDefinition{{State: "work", Initial: "a", Children: []string{"a", "b"}, Parallel: true}},
+	}
+	out := render(&fsm.MachineStatus{CurrentState: "a", CurrentStates: []string{"a", "b"}, Definition: def}, false)
+	assert.Contains(t, out, "   |-- start --> work <fork a, b>\n")
+	assert.Contains(t, out, "   |-- done <join a, b> --> idle\n")
+	assert.Contains(t, out, "\n    #===#\n    # a #  <- current\n")
+	assert.Contains(t, out, "\n    # b #  <- current\n")
+	assert.True(t, strings.HasSuffix(out, "events: done, step\n"))
+}
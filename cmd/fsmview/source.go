@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/reyoung/fsm"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// source is the machine inspected by fsmview.
+type source interface {
+	// status returns the current status of the machine, with its definition.
+	status() (*fsm.MachineStatus, error)
+	// fire injects the event to the machine.
+	fire(event string) error
+}
+
+// httpSource is a machine exposed by `fsm.NewHTTPHandler`.
+type httpSource struct {
+	client  *http.Client
+	baseURL string
+	name    string
+}
+
+// newHTTPSource creates a source of the machine served under baseURL. If name is empty, the registry should
+// contain exactly one machine.
+func newHTTPSource(client *http.Client, baseURL string, name string) (*httpSource, error) {
+	s := &httpSource{client: client, baseURL: strings.TrimSuffix(baseURL, "/"), name: name}
+	if name != "" {
+		return s, nil
+	}
+	var machines []fsm.MachineStatus
+	if err := s.do(http.MethodGet, "/machines", nil, &machines); err != nil {
+		return nil, err
+	}
+	if len(machines) != 1 {
+		names := make([]string, 0, len(machines))
+		for _, m := range machines {
+			names = append(names, m.Name)
+		}
+		return nil, fmt.Errorf("-machine is required, the machines are [%s]", strings.Join(names, ", "))
+	}
+	s.name = machines[0].Name
+	return s, nil
+}
+
+func (s *httpSource) status() (*fsm.MachineStatus, error) {
+	status := &fsm.MachineStatus{}
+	if err := s.do(http.MethodGet, "/machines/"+url.PathEscape(s.name), nil, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (s *httpSource) fire(event string) error {
+	body, err := json.Marshal(fsm.EventRequest{Event: event})
+	if err != nil {
+		return err
+	}
+	return s.do(http.MethodPost, "/machines/"+url.PathEscape(s.name)+"/events", body, nil)
+}
+
+// do sends the request, and decodes the JSON response into result if it is not nil.
+func (s *httpSource) do(method string, path string, body []byte, result interface{}) error {
+	req, err := http.NewRequestWithContext(context.Background(), method, s.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &e) == nil && e.Error != "" {
+			return errors.New(e.Error)
+		}
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(data, result)
+}
+
+// localSource is a machine created from a definition file, so the definitions can be explored without a
+// running service.
+type localSource struct {
+	name    string
+	machine *fsm.FSM
+}
+
+// newLocalSource creates the machine of a JSON or YAML definition. The actions and guards are ignored, so every
+// transition can be taken.
+func newLocalSource(path string, data []byte) (*localSource, error) {
+	def := &fsm.Definition{}
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, def)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, def)
+	default:
+		err = fmt.Errorf("unknown definition format of %s, it should be .json, .yaml or .yml", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range def.Transitions {
+		def.Transitions[i].Action, def.Transitions[i].Guard = "", ""
+	}
+	machine, err := fsm.NewFSMFromDefinition(def, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	machine.SetName(name)
+	return &localSource{name: name, machine: machine}, nil
+}
+
+func (s *localSource) status() (*fsm.MachineStatus, error) {
+	status := &fsm.MachineStatus{
+		Name:         s.name,
+		CurrentState: s.machine.CurrentState().FSMStateID(),
+		Version:      s.machine.Version(),
+		Definition:   s.machine.Definition(),
+	}
+	for _, state := range s.machine.CurrentStates() {
+		status.CurrentStates = append(status.CurrentStates, state.FSMStateID())
+	}
+	return status, nil
+}
+
+func (s *localSource) fire(event string) error {
+	return s.machine.ProcessEvent(fsm.StringEvent(event))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"github.com/reyoung/fsm"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newLight(t *testing.T, name string) *fsm.FSM {
+	data, err := ioutil.ReadFile("testdata/light.json")
+	assert.Nil(t, err)
+	src, err := newLocalSource(name+".json", data)
+	assert.Nil(t, err)
+	return src.machine
+}
+
+func TestHTTPSource(t *testing.T) {
+	registry := fsm.NewRegistry()
+	assert.Nil(t, registry.Register(newLight(t, "kitchen")))
+	server := httptest.NewServer(fsm.NewHTTPHandler(registry))
+	defer server.Close()
+
+	src, err := newHTTPSource(server.Client(), server.URL+"/", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "kitchen", src.name)
+	assert.Nil(t, src.fire("switch"))
+	status, err := src.status()
+	assert.Nil(t, err)
+	assert.Equal(t, "on", status.CurrentState)
+	assert.Equal(t, "off", status.Definition.Initial)
+	err = src.fire("unknown")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unknown")
+
+	assert.Nil(t, registry.Register(newLight(t, "hall")))
+	_, err = newHTTPSource(server.Client(), server.URL, "")
+	assert.Equal(t, "-machine is required, the machines are [hall, kitchen]", err.Error())
+	src, err = newHTTPSource(server.Client(), server.URL, "cellar")
+	assert.Nil(t, err)
+	_, err = src.status()
+	assert.Equal(t, "machine cellar not found", err.Error())
+}
+
+func TestView(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/light.json")
+	assert.Nil(t, err)
+	src, err := newLocalSource("testdata/light.json", data)
+	assert.Nil(t, err)
+	_, err = newLocalSource("light.toml", data)
+	assert.NotNil(t, err)
+
+	out := &bytes.Buffer{}
+	v := &view{src: src, in: strings.NewReader("switch\nswitch\nrepair\n\nq\nswitch\n"), out: out}
+	assert.Nil(t, v.run())
+	assert.Equal(t, fsm.StringState("off"), src.machine.CurrentState())
+	assert.Equal(t, uint64(2), src.machine.Version())
+	text := out.String()
+	assert.Contains(t, text, "machine light  state on  version 1\n")
+	assert.Contains(t, text, "event switch processed")
+	assert.Contains(t, text, "event repair failed: ")
+	assert.Equal(t, 5, strings.Count(text, "event (empty to refresh, q to quit)> "))
+	assert.NotContains(t, text, ansiClear)
+}
@@ -0,0 +1,72 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CompensationError is returned by `CompensateTo` when a compensation fails. The transitions after the failed
+// one have been compensated and reverted.
+type CompensationError struct {
+	// Change is the state change which is failed to compensate.
+	Change StateChange
+	Err    error
+}
+
+func (e *CompensationError) Error() string {
+	return fmt.Sprintf("compensate transition from state(%s) to state(%s) by event(%s): %v",
+		e.Change.From.FSMStateID(), e.Change.To.FSMStateID(), e.Change.Event.FSMEventID(), e.Err)
+}
+
+func (e *CompensationError) Unwrap() error {
+	return e.Err
+}
+
+// CompensateTo walks back through the kept transitions, invoking their `TransitionOptions.Compensate` in reverse
+// order and reverting them like `Rollback`, until the FSM is in the state `state`. The transitions without
+// `Compensate` are reverted only. It does nothing if the FSM is in the state already.
+//   - It returns an error without any change if `state` is not found in the kept transitions. See
+//     `SetRollbackLimit`.
+//   - If a compensation fails, it stops and returns a `CompensationError`, the FSM stays in the state where the
+//     failed transition moved it to.
+//
+// NOTE: Like `ProcessEvent`, it should not be invoked in action/guard.
+func (fsm *FSM) CompensateTo(state State) error {
+	if fsm.processEventInvokeCounter != 0 {
+		panic(ShouldNotReEnterPanic)
+	}
+	if fsm.isInLeaves(fsm.currentLeaves(), state) {
+		return nil
+	}
+	target := -1
+	for i := len(fsm.undo) - 1; i >= 0; i-- {
+		if fsm.isDescendant(fsm.undo[i].before.curState, state.FSMStateID()) {
+			target = i
+			break
+		}
+	}
+	if target < 0 {
+		return errors.New(fmt.Sprintf("state %s is not found in the kept transitions", state.FSMStateID()))
+	}
+	for len(fsm.undo) > target {
+		entry := fsm.undo[len(fsm.undo)-1]
+		if err := fsm.compensate(entry); err != nil {
+			return &CompensationError{Change: entry.change, Err: err}
+		}
+		fsm.revert(entry)
+	}
+	return nil
+}
+
+// compensate invokes the compensation of the kept transition, and the `SetRollbackAction`.
+func (fsm *FSM) compensate(entry undoEntry) error {
+	if entry.t.compensate != nil {
+		if err := entry.t.compensate(fsm.payload, entry.change.Event); err != nil {
+			return err
+		}
+	}
+	if fsm.rollbackAction != nil {
+		return fsm.rollbackAction(fsm.payload, entry.change)
+	}
+	return nil
+}
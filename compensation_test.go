@@ -0,0 +1,79 @@
+package fsm
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type saga struct {
+	compensated []string
+	failing     string
+}
+
+func newSagaFSM(t *testing.T) *FSM {
+	var (
+		created  = StringState("created")
+		reserved = StringState("reserved")
+		charged  = StringState("charged")
+		shipped  = StringState("shipped")
+	)
+	fsm := NewFSM(created, &saga{})
+	assert.Nil(t, fsm.AddState(reserved))
+	assert.Nil(t, fsm.AddState(charged))
+	assert.Nil(t, fsm.AddState(shipped))
+	compensate := func(payload interface{}, ev Event) error {
+		s := payload.(*saga)
+		if s.failing == ev.FSMEventID() {
+			return errors.New("failed")
+		}
+		s.compensated = append(s.compensated, ev.FSMEventID())
+		return nil
+	}
+	for _, tr := range []struct {
+		from, to State
+		ev       string
+	}{{created, reserved, "reserve"}, {reserved, charged, "charge"}, {charged, shipped, "ship"}} {
+		assert.Nil(t, fsm.AddEvent(tr.ev))
+		opts := TransitionOptions{Compensate: compensate}
+		if tr.ev == "ship" {
+			opts.Compensate = nil
+		}
+		assert.Nil(t, fsm.AddTransitionWithOptions(tr.from, tr.ev, tr.to, nil, nil, opts))
+	}
+	fsm.SetRollbackLimit(10)
+	for _, ev := range []string{"reserve", "charge", "ship"} {
+		assert.Nil(t, fsm.ProcessEvent(StringEvent(ev)))
+	}
+	return fsm
+}
+
+func TestCompensateTo(t *testing.T) {
+	fsm := newSagaFSM(t)
+	assert.Nil(t, fsm.CompensateTo(StringState("shipped")))
+	assert.NotNil(t, fsm.CompensateTo(StringState("unknown")))
+	assert.Equal(t, StringState("shipped"), fsm.CurrentState())
+
+	assert.Nil(t, fsm.CompensateTo(StringState("created")))
+	assert.Equal(t, StringState("created"), fsm.CurrentState())
+	assert.Equal(t, []string{"charge", "reserve"}, fsm.payload.(*saga).compensated)
+	assert.NotNil(t, fsm.Rollback())
+}
+
+func TestCompensateToFailed(t *testing.T) {
+	fsm := newSagaFSM(t)
+	fsm.payload.(*saga).failing = "reserve"
+	err := fsm.CompensateTo(StringState("created"))
+	var compensationErr *CompensationError
+	assert.True(t, errors.As(err, &compensationErr))
+	assert.Equal(t, StringEvent("reserve"), compensationErr.Change.Event)
+	assert.Equal(t, "compensate transition from state(created) to state(reserved) by event(reserve): failed",
+		err.Error())
+	assert.Equal(t, StringState("reserved"), fsm.CurrentState())
+	assert.Equal(t, []string{"charge"}, fsm.payload.(*saga).compensated)
+
+	// Rollback invokes the compensation as well.
+	fsm.payload.(*saga).failing = ""
+	assert.Nil(t, fsm.Rollback())
+	assert.Equal(t, []string{"charge", "reserve"}, fsm.payload.(*saga).compensated)
+}
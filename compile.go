@@ -0,0 +1,51 @@
+package fsm
+
+import "errors"
+
+// ErrCompiled is returned by the methods changing the definition of a FSM after `Compile`.
+var ErrCompiled = errors.New("the FSM is compiled")
+
+// compiledTable is the transitions of a compiled FSM, cells[state*events+event] are the transitions of the event
+// from the state, indexed by `StateIndex` and `EventIndex`.
+type compiledTable struct {
+	events int
+	cells  [][]*transition
+}
+
+// Compile freezes the definition of the FSM into a dense table of the transitions, so `ProcessEvent` looks them
+// up by one hash of the event id, or by slice indexing only for the `IndexedEvent`s. The states, events and
+// transitions cannot be added after compiling, i.e., `AddState`, `AddEvent`, `AddTransition` etc. return
+// `ErrCompiled`. Compiling a compiled FSM does nothing.
+// NOTE: the sub-machines are not compiled, they should be compiled separately.
+func (fsm *FSM) Compile() {
+	if fsm.compiled != nil {
+		return
+	}
+	table := &compiledTable{events: len(fsm.eventIDs)}
+	table.cells = make([][]*transition, len(fsm.stateIDs)*table.events)
+	for si, row := range fsm.table {
+		copy(table.cells[si*table.events:], row)
+	}
+	fsm.compiled = table
+}
+
+// Compiled returns true if the FSM is compiled. See `Compile`.
+func (fsm *FSM) Compiled() bool {
+	return fsm.compiled != nil
+}
+
+// compiledTransitions returns the transitions of ev from state `from` by the compiled table.
+func (fsm *FSM) compiledTransitions(from string, ev Event) []*transition {
+	ei, ok := fsm.eventIndexOf(ev)
+	if !ok {
+		if ei, ok = fsm.events[ev.FSMEventID()]; !ok {
+			// the completion transitions are not in the table.
+			return fsm.transitions[from][ev.FSMEventID()]
+		}
+	}
+	si := fsm.curIndex
+	if from != fsm.curState {
+		si = fsm.stateIndex[from]
+	}
+	return fsm.compiled.cells[si*fsm.compiled.events+ei]
+}
@@ -0,0 +1,146 @@
+package fsm
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	var (
+		off  = StringState("off")
+		on   = StringState("on")
+		low  = StringState("low")
+		high = StringState("high")
+	)
+	fsm := NewFSM(off, nil)
+	assert.Nil(t, fsm.AddState(on))
+	assert.Nil(t, fsm.AddChildState(on, low))
+	assert.Nil(t, fsm.AddChildState(on, high))
+	assert.Nil(t, fsm.AddEvent("power"))
+	assert.Nil(t, fsm.AddEvent("turbo"))
+	assert.Nil(t, fsm.AddEvent("unused"))
+	assert.Nil(t, fsm.AddTransition(off, "power", on, nil, nil))
+	assert.Nil(t, fsm.AddTransition(on, "power", off, nil, nil))
+	assert.Nil(t, fsm.AddTransition(low, "turbo", high, nil, nil))
+	assert.Nil(t, fsm.AddCompletionTransition(high, low, nil, func(interface{}, Event) bool {
+		return false
+	}))
+
+	assert.False(t, fsm.Compiled())
+	fsm.Compile()
+	fsm.Compile()
+	assert.True(t, fsm.Compiled())
+	assert.Equal(t, ErrCompiled, fsm.AddState(StringState("new")))
+	assert.Equal(t, ErrCompiled, fsm.AddEvent("new"))
+	assert.Equal(t, ErrCompiled, fsm.AddTransition(off, "turbo", on, nil, nil))
+	assert.Equal(t, ErrCompiled, fsm.AddChildState(on, StringState("new")))
+	assert.Equal(t, ErrCompiled, fsm.SetInitialChild(on, high))
+
+	turbo, _ := fsm.IndexEvent("turbo")
+	assert.NotNil(t, fsm.ProcessEvent(turbo))
+	assert.NotNil(t, fsm.ProcessEvent(StringEvent("unused")))
+	assert.NotNil(t, fsm.ProcessEvent(StringEvent("unknown")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("power")))
+	assert.Equal(t, low, fsm.CurrentState())
+	assert.Nil(t, fsm.ProcessEvent(turbo))
+	assert.Equal(t, high, fsm.CurrentState())
+	assert.Nil(t, fsm.ProcessEvent(IndexEvent{id: "power", index: 1}))
+	assert.Equal(t, off, fsm.CurrentState())
+}
+
+func ringEvent(i int) string {
+	return fmt.Sprintf("e%d", i)
+}
+
+// newRingFSM creates a FSM of n states and n events, the event i moves the state i to the state i+1.
+func newRingFSM(n int) *FSM {
+	fsm := NewFSM(StringState("s0"), nil)
+	for i := 1; i < n; i++ {
+		_ = fsm.AddState(StringState(fmt.Sprintf("s%d", i)))
+	}
+	for i := 0; i < n; i++ {
+		_ = fsm.AddEvent(ringEvent(i))
+	}
+	for i := 0; i < n; i++ {
+		from, to := StringState(fmt.Sprintf("s%d", i)), StringState(fmt.Sprintf("s%d", (i+1)%n))
+		_ = fsm.AddTransition(from, ringEvent(i), to, nil, nil)
+	}
+	return fsm
+}
+
+func benchmarkRing(b *testing.B, compile bool, indexed bool) {
+	const n = 64
+	fsm := newRingFSM(n)
+	if compile {
+		fsm.Compile()
+	}
+	events := make([]Event, n)
+	for i := range events {
+		if indexed {
+			events[i], _ = fsm.IndexEvent(fmt.Sprintf("e%d", i))
+		} else {
+			events[i] = StringEvent(fmt.Sprintf("e%d", i))
+		}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := fsm.ProcessEvent(events[i%n]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProcessEvent(b *testing.B) {
+	benchmarkRing(b, false, false)
+}
+
+func BenchmarkProcessEventIndexed(b *testing.B) {
+	benchmarkRing(b, false, true)
+}
+
+func BenchmarkProcessEventCompiled(b *testing.B) {
+	benchmarkRing(b, true, false)
+}
+
+func BenchmarkProcessEventCompiledIndexed(b *testing.B) {
+	benchmarkRing(b, true, true)
+}
+
+// benchmarkLookup measures the lookup of the transitions of 1024 states and events. On a Xeon server, it takes
+// about 34ns by the maps, 19ns by the compiled table, and 13ns by the compiled table with `IndexEvent`s. The
+// lookup is a small part of `ProcessEvent`, see `BenchmarkProcessEvent`.
+func benchmarkLookup(b *testing.B, compile bool, indexed bool) {
+	const n = 1024
+	fsm := newRingFSM(n)
+	if compile {
+		fsm.Compile()
+	}
+	events := make([]Event, n)
+	for i := range events {
+		if indexed {
+			events[i], _ = fsm.IndexEvent(fmt.Sprintf("e%d", i))
+		} else {
+			events[i] = StringEvent(fmt.Sprintf("e%d", i))
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if len(fsm.transitionsOf(fsm.curState, events[i%n])) > 1 {
+			b.Fatal("unexpected transitions")
+		}
+	}
+}
+
+func BenchmarkTransitionLookup(b *testing.B) {
+	benchmarkLookup(b, false, false)
+}
+
+func BenchmarkTransitionLookupCompiled(b *testing.B) {
+	benchmarkLookup(b, true, false)
+}
+
+func BenchmarkTransitionLookupCompiledIndexed(b *testing.B) {
+	benchmarkLookup(b, true, true)
+}
@@ -0,0 +1,49 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+)
+
+// CompletionEventID is the event id of completion transitions. See `AddCompletionTransition`.
+const CompletionEventID = ""
+
+// maxCompletionSteps limits the completion transitions fired by one event, to detect loops.
+const maxCompletionSteps = 1000
+
+// CompletionEvent is passed to the action/guard of completion transitions.
+type CompletionEvent struct {
+	// Cause is the event which made the FSM enter the state.
+	Cause Event
+}
+
+func (CompletionEvent) FSMEventID() string {
+	return CompletionEventID
+}
+
+// AddCompletionTransition appends a transition without triggering event, it fires automatically when the FSM
+// enters state `from` and the guard returns true. The completion transitions of a state are evaluated in the
+// order of adding, like the transitions of an event. It makes pass-through and decision states possible.
+//   - The action/guard receive a `CompletionEvent`.
+//   - The completion transitions of the entered `to` state are evaluated in turn.
+//   - If the action returns an error, the FSM stays in the state `from` and `ProcessEvent` returns the error.
+//   - The completion transitions of the initial state are not fired, unless the FSM is started by `Start`.
+func (fsm *FSM) AddCompletionTransition(from State, to State,
+	action func(interface{}, Event) error, guard func(interface{}, Event) bool) error {
+	return fsm.AddTransitionWithOptions(from, CompletionEventID, to, action, guard, TransitionOptions{})
+}
+
+// complete fires the completion transitions after the FSM enters a state by the event `cause`.
+func (fsm *FSM) complete(ctx context.Context, cause Event) error {
+	for i := 0; i < maxCompletionSteps; i++ {
+		transList := fsm.transitions[fsm.curState][CompletionEventID]
+		if len(transList) == 0 {
+			return nil
+		}
+		fired, err := fsm.fire(ctx, fsm.curState, CompletionEvent{Cause: cause}, transList)
+		if err != nil || !fired {
+			return err
+		}
+	}
+	return errors.New("too many completion transitions, there may be a loop")
+}
@@ -0,0 +1,64 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type order struct {
+	amount int
+}
+
+func TestCompletionTransition(t *testing.T) {
+	var (
+		cart     = StringState("cart")
+		checking = StringState("checking")
+		review   = StringState("review")
+		paid     = StringState("paid")
+	)
+	payload := &order{}
+	fsm := NewFSM(cart, payload)
+	for _, s := range []State{checking, review, paid} {
+		assert.Nil(t, fsm.AddState(s))
+	}
+	assert.Nil(t, fsm.AddEvent("checkout"))
+	assert.NotNil(t, fsm.AddEvent(CompletionEventID))
+	assert.Nil(t, fsm.AddTransition(cart, "checkout", checking, nil, nil))
+	var causes []Event
+	assert.Nil(t, fsm.AddCompletionTransition(checking, review, nil, func(i interface{}, event Event) bool {
+		causes = append(causes, event.(CompletionEvent).Cause)
+		return i.(*order).amount > 100
+	}))
+	assert.Nil(t, fsm.AddCompletionTransition(checking, paid, nil, nil))
+
+	assert.Equal(t, []string{"checkout"}, fsm.AvailableEvents())
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("checkout")))
+	assert.Equal(t, paid, fsm.CurrentState())
+	assert.Equal(t, []Event{StringEvent("checkout")}, causes)
+
+	fsm.curState = "checking"
+	assert.False(t, fsm.CanFire(CompletionEvent{}))
+	assert.NotNil(t, fsm.ProcessEvent(CompletionEvent{}))
+
+	def := fsm.Definition()
+	assert.Equal(t, []string{"checkout"}, def.Events)
+	assert.Equal(t, TransitionDefinition{From: "checking", Event: CompletionEventID, To: "review"}, def.Transitions[1])
+	reloaded, err := NewFSMFromDefinition(def, nil, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, reloaded.ProcessEvent(StringEvent("checkout")))
+	assert.Equal(t, StringState("review"), reloaded.CurrentState())
+}
+
+func TestCompletionTransitionLoop(t *testing.T) {
+	var (
+		ping = StringState("ping")
+		pong = StringState("pong")
+	)
+	fsm := NewFSM(ping, nil)
+	assert.Nil(t, fsm.AddState(pong))
+	assert.Nil(t, fsm.AddEvent("start"))
+	assert.Nil(t, fsm.AddTransition(ping, "start", pong, nil, nil))
+	assert.Nil(t, fsm.AddCompletionTransition(pong, ping, nil, nil))
+	assert.Nil(t, fsm.AddCompletionTransition(ping, pong, nil, nil))
+	assert.NotNil(t, fsm.ProcessEvent(StringEvent("start")))
+}
@@ -0,0 +1,52 @@
+package fsm
+
+import "time"
+
+// DeadLetter is an event rejected by the FSM, i.e., `ProcessEvent` returned the no transition error because there
+// is no transition of the event from the current states, or all guards returned false. See `SetDeadLetterSink`.
+type DeadLetter struct {
+	// Rejection is the rejected event, the state and the rejected candidates, see `ExplainLastRejection`.
+	Rejection
+	// Machine is the name of the FSM, see `SetName`.
+	Machine string
+	// States are the `CurrentStates` when the event was rejected.
+	States []State
+	// Version is the `Version` when the event was rejected.
+	Version uint64
+	Time    time.Time
+}
+
+// SetDeadLetterSink sets the sink receiving the rejected events, so unexpected events are not silently lost, e.g.,
+// in production workflows. The sink is invoked synchronously before `ProcessEvent` returns, including for the
+// rejected internal events, but not for the events of `Replay`. It is nil by default, which drops the rejected
+// events.
+//
+//	machine.SetDeadLetterSink(func(letter fsm.DeadLetter) {
+//		log.Printf("%s: %s", letter.Machine, letter.Rejection.String())
+//	})
+//
+// See `DeadLetterChannel` for a sink of channel.
+// NOTE: like `AddObserver`, the sink should be fast and should not invoke `ProcessEvent`.
+func (fsm *FSM) SetDeadLetterSink(sink func(letter DeadLetter)) {
+	fsm.deadLetters = sink
+}
+
+// DeadLetterChannel returns a sink of `SetDeadLetterSink`, which sends the dead letters to ch. The sending blocks
+// if ch is full, so the dead letters are never dropped, and the receivers should keep up with the rejections.
+func DeadLetterChannel(ch chan<- DeadLetter) func(letter DeadLetter) {
+	return func(letter DeadLetter) {
+		ch <- letter
+	}
+}
+
+// deadLetter returns the dead letter of the rejection in the current states, including the uncommitted changes
+// of the running transaction.
+func (fsm *FSM) deadLetter(rejection Rejection) DeadLetter {
+	return DeadLetter{
+		Rejection: rejection,
+		Machine:   fsm.Name(),
+		States:    fsm.leafStates(fsm.currentLeaves()),
+		Version:   fsm.version,
+		Time:      fsm.clock.Now(),
+	}
+}
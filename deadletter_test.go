@@ -0,0 +1,51 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDeadLetter(t *testing.T) {
+	off, on := StringState("off"), StringState("on")
+	fsm := NewFSM(off, nil)
+	fsm.SetName("light")
+	assert.Nil(t, fsm.AddState(on))
+	assert.Nil(t, fsm.AddEvent("switch"))
+	assert.Nil(t, fsm.AddEvent("lock"))
+	assert.Nil(t, fsm.AddTransition(off, "switch", on, nil, func(interface{}, Event) bool {
+		return false
+	}))
+	var letters []DeadLetter
+	fsm.SetDeadLetterSink(func(letter DeadLetter) {
+		letters = append(letters, letter)
+	})
+
+	assert.NotNil(t, fsm.ProcessEvent(StringEvent("switch")))
+	assert.NotNil(t, fsm.ProcessEvent(StringEvent("lock")))
+	assert.Len(t, letters, 2)
+	assert.Equal(t, "light", letters[0].Machine)
+	assert.Equal(t, StringEvent("switch"), letters[0].Event)
+	assert.Equal(t, off, letters[0].State)
+	assert.Equal(t, []State{off}, letters[0].States)
+	assert.Equal(t, GuardReturnedFalse, letters[0].Candidates[0].Reason)
+	assert.Equal(t, StringEvent("lock"), letters[1].Event)
+	assert.Len(t, letters[1].Candidates, 0)
+
+	// the events of replay are not dead letters.
+	assert.NotNil(t, fsm.Replay([]Event{StringEvent("lock")}))
+	assert.Len(t, letters, 2)
+}
+
+func TestDeadLetterChannel(t *testing.T) {
+	queued := NewQueuedFSM(StringState("idle"), nil)
+	defer queued.Close()
+	ch := make(chan DeadLetter, 1)
+	queued.SetDeadLetterSink(DeadLetterChannel(ch))
+	assert.Nil(t, queued.AddEvent("start"))
+
+	assert.NotNil(t, queued.ProcessEvent(StringEvent("start")))
+	letter := <-ch
+	assert.Equal(t, StringEvent("start"), letter.Event)
+	assert.Equal(t, StringState("idle"), letter.State)
+	assert.Equal(t, uint64(0), letter.Version)
+}
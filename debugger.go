@@ -0,0 +1,142 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DebugFrame is a recorded point of the history of a FSM. See `SetDebugRecording`.
+type DebugFrame struct {
+	// Version is the `Version` of the FSM at the frame.
+	Version uint64
+	// Event is the event which led to the frame, it is nil for the first recorded frame.
+	Event Event
+	// Actor is the `ActorOf` the event, it is empty if the event is not attributed.
+	Actor string
+	// From is the visible state before the frame, it is empty for the first recorded frame.
+	From string
+	// State is the visible state, i.e., `CurrentState`, and States are the ids of `CurrentStates`.
+	State  string
+	States []string
+	// Payload is the payload marshaled by the hook of `SetDebugRecording`, or the error of the hook.
+	Payload    []byte
+	PayloadErr error
+	Time       time.Time
+}
+
+// SetDebugRecording records the history of the FSM for `Debugger`, at most the last `limit` frames are kept.
+// The first frame is the current state. A frame is recorded for every transition, including the ones of
+// `Rollback`, with the payload marshaled by marshal, e.g., `json.Marshal`. The payload is not recorded if
+// marshal is nil. A limit of 0 stops recording and drops the frames.
+// NOTE: the frames of a failed `Transaction` are dropped. `Replay` and `Restore` are not recorded.
+func (fsm *FSM) SetDebugRecording(limit int, marshal func(payload interface{}) ([]byte, error)) {
+	fsm.debugMu.Lock()
+	fsm.debugLimit = limit
+	fsm.debugMarshal = marshal
+	fsm.debugFrames = nil
+	fsm.debugMu.Unlock()
+	if limit > 0 {
+		fsm.recordFrame("", nil)
+	}
+}
+
+// recordFrame records the current state, which is entered from state `from` by the event ev.
+func (fsm *FSM) recordFrame(from string, ev Event) {
+	fsm.debugMu.Lock()
+	defer fsm.debugMu.Unlock()
+	if fsm.debugLimit <= 0 {
+		return
+	}
+	// the state in the running transaction, see `FSM.Transaction`.
+	fsm.curStateMu.RLock()
+	frame := DebugFrame{
+		Version: fsm.version,
+		Event:   ev,
+		Actor:   ActorOf(ev),
+		From:    from,
+		State:   fsm.curState,
+		States:  fsm.currentLeaves(),
+		Time:    fsm.clock.Now(),
+	}
+	fsm.curStateMu.RUnlock()
+	if fsm.debugMarshal != nil {
+		frame.Payload, frame.PayloadErr = fsm.debugMarshal(fsm.payload)
+	}
+	fsm.debugFrames = append(fsm.debugFrames, frame)
+	if n := len(fsm.debugFrames) - fsm.debugLimit; n > 0 {
+		fsm.debugFrames = append(fsm.debugFrames[:0:0], fsm.debugFrames[n:]...)
+	}
+}
+
+// dropFrames drops the frames recorded after version.
+func (fsm *FSM) dropFrames(version uint64) {
+	fsm.debugMu.Lock()
+	defer fsm.debugMu.Unlock()
+	for len(fsm.debugFrames) != 0 && fsm.debugFrames[len(fsm.debugFrames)-1].Version > version {
+		fsm.debugFrames = fsm.debugFrames[:len(fsm.debugFrames)-1]
+	}
+}
+
+// Debugger walks through the recorded history of a FSM, without changing the FSM. See `FSM.Debugger`.
+type Debugger struct {
+	frames []DebugFrame
+	cursor int
+}
+
+// Debugger returns a debugger of the frames recorded so far, positioned at the latest frame. The debugger has
+// no frame unless `SetDebugRecording` is enabled. It can be invoked concurrently with `ProcessEvent`.
+func (fsm *FSM) Debugger() *Debugger {
+	fsm.debugMu.Lock()
+	defer fsm.debugMu.Unlock()
+	return &Debugger{frames: append([]DebugFrame(nil), fsm.debugFrames...), cursor: len(fsm.debugFrames) - 1}
+}
+
+// Frames returns all recorded frames, the oldest first.
+func (d *Debugger) Frames() []DebugFrame {
+	return d.frames
+}
+
+// Frame returns the frame at the position, it returns false if there is no frame.
+func (d *Debugger) Frame() (DebugFrame, bool) {
+	if d.cursor < 0 {
+		return DebugFrame{}, false
+	}
+	return d.frames[d.cursor], true
+}
+
+// StepBack moves to the previous frame. It returns false if the position is the oldest frame.
+func (d *Debugger) StepBack() bool {
+	if d.cursor <= 0 {
+		return false
+	}
+	d.cursor--
+	return true
+}
+
+// StepForward moves to the next frame. It returns false if the position is the latest frame.
+func (d *Debugger) StepForward() bool {
+	if d.cursor+1 >= len(d.frames) {
+		return false
+	}
+	d.cursor++
+	return true
+}
+
+// JumpTo moves to the latest frame of version. It returns an error if the version is not recorded, e.g., the
+// frame is dropped by the limit.
+func (d *Debugger) JumpTo(version uint64) error {
+	for i := len(d.frames) - 1; i >= 0; i-- {
+		if d.frames[i].Version == version {
+			d.cursor = i
+			return nil
+		}
+	}
+	return errors.New(fmt.Sprintf("version %d is not recorded", version))
+}
+
+// History returns the frames from the oldest one to the position, i.e., how the FSM got into the state of the
+// position.
+func (d *Debugger) History() []DebugFrame {
+	return d.frames[:d.cursor+1]
+}
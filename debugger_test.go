@@ -0,0 +1,130 @@
+package fsm
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type counterPayload struct {
+	Count int `json:"count"`
+}
+
+func newCounterFSM() *FSM {
+	var (
+		idle    = StringState("idle")
+		running = StringState("running")
+	)
+	fsm := NewFSM(idle, &counterPayload{})
+	_ = fsm.AddState(running)
+	_ = fsm.AddEvent("start")
+	_ = fsm.AddEvent("stop")
+	count := func(payload interface{}, _ Event) error {
+		payload.(*counterPayload).Count++
+		return nil
+	}
+	_ = fsm.AddTransition(idle, "start", running, count, nil)
+	_ = fsm.AddTransition(running, "stop", idle, count, nil)
+	return fsm
+}
+
+func TestDebugger(t *testing.T) {
+	fsm := newCounterFSM()
+	assert.Len(t, fsm.Debugger().Frames(), 0)
+	_, ok := fsm.Debugger().Frame()
+	assert.False(t, ok)
+
+	fsm.SetDebugRecording(10, json.Marshal)
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("start")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("stop")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("start")))
+	assert.NotNil(t, fsm.ProcessEvent(StringEvent("start")))
+
+	d := fsm.Debugger()
+	assert.Len(t, d.Frames(), 4)
+	frame, ok := d.Frame()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(3), frame.Version)
+	assert.Equal(t, StringEvent("start"), frame.Event)
+	assert.Equal(t, "idle", frame.From)
+	assert.Equal(t, "running", frame.State)
+	assert.Equal(t, []string{"running"}, frame.States)
+	assert.Equal(t, `{"count":3}`, string(frame.Payload))
+	assert.False(t, d.StepForward())
+
+	assert.True(t, d.StepBack())
+	frame, _ = d.Frame()
+	assert.Equal(t, "idle", frame.State)
+	assert.Equal(t, `{"count":2}`, string(frame.Payload))
+
+	assert.Nil(t, d.JumpTo(0))
+	frame, _ = d.Frame()
+	assert.Nil(t, frame.Event)
+	assert.Equal(t, "", frame.From)
+	assert.Equal(t, `{"count":0}`, string(frame.Payload))
+	assert.False(t, d.StepBack())
+	assert.True(t, d.StepForward())
+	assert.Len(t, d.History(), 2)
+	assert.NotNil(t, d.JumpTo(4))
+
+	// the debugger is not changed by the later events.
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("stop")))
+	assert.Len(t, d.Frames(), 4)
+	assert.Len(t, fsm.Debugger().Frames(), 5)
+	assert.Equal(t, StringState("idle"), fsm.CurrentState())
+}
+
+func TestDebuggerLimit(t *testing.T) {
+	fsm := newCounterFSM()
+	fsm.SetDebugRecording(2, func(interface{}) ([]byte, error) {
+		return nil, errors.New("not serializable")
+	})
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("start")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("stop")))
+	frames := fsm.Debugger().Frames()
+	assert.Len(t, frames, 2)
+	assert.Equal(t, uint64(1), frames[0].Version)
+	assert.Equal(t, "not serializable", frames[1].PayloadErr.Error())
+	assert.NotNil(t, fsm.Debugger().JumpTo(0))
+
+	fsm.SetDebugRecording(0, nil)
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("start")))
+	assert.Len(t, fsm.Debugger().Frames(), 0)
+}
+
+func TestDebuggerRollbackAndTransaction(t *testing.T) {
+	fsm := newCounterFSM()
+	fsm.SetRollbackLimit(1)
+	fsm.SetDebugRecording(10, nil)
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("start")))
+	assert.Nil(t, fsm.Rollback())
+	frame, _ := fsm.Debugger().Frame()
+	assert.Equal(t, uint64(2), frame.Version)
+	assert.Equal(t, "rollback(start)", frame.Event.FSMEventID())
+	assert.Equal(t, "running", frame.From)
+	assert.Equal(t, "idle", frame.State)
+	assert.Nil(t, frame.Payload)
+
+	assert.NotNil(t, fsm.Transaction(func(tx *Tx) error {
+		assert.Nil(t, tx.ProcessEvent(StringEvent("start")))
+		assert.Len(t, fsm.Debugger().Frames(), 4)
+		return errors.New("abort")
+	}))
+	assert.Len(t, fsm.Debugger().Frames(), 3)
+}
+
+func TestDebuggerNotStarted(t *testing.T) {
+	fsm := NewFSM(nil, &counterPayload{})
+	assert.Nil(t, fsm.AddState(StringState("idle")))
+	fsm.SetDebugRecording(10, nil)
+	frame, ok := fsm.Debugger().Frame()
+	assert.True(t, ok)
+	assert.Equal(t, "", frame.State)
+	assert.Empty(t, frame.States)
+
+	assert.Nil(t, fsm.Start(StringState("idle")))
+	frame, _ = fsm.Debugger().Frame()
+	assert.Equal(t, "idle", frame.State)
+	assert.Equal(t, []string{"idle"}, frame.States)
+}
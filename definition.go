@@ -0,0 +1,254 @@
+package fsm
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Definition is the serializable form of a FSM. The actions and guards are referenced by their names
+// in a `HandlerRegistry`.
+//
+// The JSON form looks like:
+//
+//	{
+//	  "version": "2",
+//	  "initial": "off",
+//	  "states": ["off", "on"],
+//	  "events": ["switch"],
+//	  "transitions": [
+//	    {"from": "off", "event": "switch", "to": "on", "action": "turnOn", "guard": "hasPower"},
+//	    {"from": "on", "event": "switch", "to": "off"}
+//	  ]
+//	}
+//
+// See definition.schema.json for the JSON schema.
+type Definition struct {
+	// Version is the optional `FSM.DefinitionVersion`, which tells the snapshots of the older topologies.
+	Version     string                 `json:"version,omitempty" yaml:"version,omitempty"`
+	Initial     string                 `json:"initial" yaml:"initial"`
+	States      []string               `json:"states" yaml:"states"`
+	Events      []string               `json:"events" yaml:"events"`
+	Transitions []TransitionDefinition `json:"transitions" yaml:"transitions"`
+	// Composites are the composite states, a parent should be defined before its children. The child states
+	// are listed in States as well. The history states are referenced by the ids `<composite>/H` and
+	// `<composite>/H*` in transitions, see `HistoryState`.
+	Composites []CompositeDefinition `json:"composites,omitempty" yaml:"composites,omitempty"`
+}
+
+// CompositeDefinition is the serializable form of a composite state. See `FSM.AddChildState`.
+type CompositeDefinition struct {
+	State    string   `json:"state" yaml:"state"`
+	Initial  string   `json:"initial" yaml:"initial"`
+	Children []string `json:"children" yaml:"children"`
+	// Parallel makes the children orthogonal regions, see `FSM.SetParallel`.
+	Parallel bool `json:"parallel,omitempty" yaml:"parallel,omitempty"`
+}
+
+// TransitionDefinition is the serializable form of a transition. Action and Guard are optional.
+type TransitionDefinition struct {
+	From        string            `json:"from" yaml:"from"`
+	Event       string            `json:"event" yaml:"event"`
+	To          string            `json:"to" yaml:"to"`
+	Action      string            `json:"action,omitempty" yaml:"action,omitempty"`
+	Guard       string            `json:"guard,omitempty" yaml:"guard,omitempty"`
+	Name        string            `json:"name,omitempty" yaml:"name,omitempty"`
+	Description string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	// Choice marks the transition as a branch of a choice. The consecutive choice transitions sharing the same
+	// from state and event form a choice, see `FSM.AddChoice`.
+	Choice bool `json:"choice,omitempty" yaml:"choice,omitempty"`
+	// Fork is the targets of a fork transition, To is the parallel state. See `FSM.AddFork`.
+	Fork []string `json:"fork,omitempty" yaml:"fork,omitempty"`
+	// Join is the sources of a join transition, From is the parallel state. See `FSM.AddJoin`.
+	Join []string `json:"join,omitempty" yaml:"join,omitempty"`
+	// Priority is the `TransitionOptions.Priority`. The transitions are listed in the order of guard evaluation,
+	// so the priorities do not change the order unless the FSM is configured by `FSM.SetPriorityOrder`.
+	Priority int `json:"priority,omitempty" yaml:"priority,omitempty"`
+	// Permissions are the `TransitionOptions.Permissions`, see `FSM.SetAuthorizer`.
+	Permissions []string `json:"permissions,omitempty" yaml:"permissions,omitempty"`
+}
+
+// NewFSMFromDefinition creates a FSM from the definition. The states are created as `StringState`.
+// The `registry` can be nil if no action or guard is referenced. It is kept for `FSM.SwapDefinition`.
+func NewFSMFromDefinition(def *Definition, registry *HandlerRegistry, payload interface{}) (*FSM, error) {
+	if def.Initial == "" {
+		return nil, errors.New("the initial state of definition should not be empty")
+	}
+	if registry == nil {
+		registry = NewHandlerRegistry()
+	}
+	fsm := NewFSM(StringState(def.Initial), payload)
+	fsm.handlers = registry
+	fsm.definitionVersion = def.Version
+	isChild := make(map[string]bool)
+	for _, c := range def.Composites {
+		for _, child := range c.Children {
+			isChild[child] = true
+		}
+	}
+	for _, state := range def.States {
+		if state == def.Initial || isChild[state] {
+			continue
+		}
+		if err := fsm.AddState(StringState(state)); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range def.Composites {
+		for _, child := range c.Children {
+			if err := fsm.AddChildState(StringState(c.State), StringState(child)); err != nil {
+				return nil, err
+			}
+		}
+		if c.Initial != "" {
+			if err := fsm.SetInitialChild(StringState(c.State), StringState(c.Initial)); err != nil {
+				return nil, err
+			}
+		}
+		if c.Parallel {
+			if err := fsm.SetParallel(StringState(c.State)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, t := range def.Transitions {
+		if h, ok := parseHistoryID(t.To); ok && len(fsm.children[h.Composite]) != 0 {
+			if _, err := fsm.history(StringState(h.Composite), h.Deep); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, evID := range def.Events {
+		if err := fsm.AddEvent(evID); err != nil {
+			return nil, err
+		}
+	}
+	for i := 0; i < len(def.Transitions); i++ {
+		t := def.Transitions[i]
+		if !t.Choice {
+			action, guard, opts, err := transitionHandlers(t, registry)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case len(t.Fork) != 0:
+				err = fsm.AddFork(StringState(t.From), t.Event, stringStates(t.Fork), action, guard)
+			case len(t.Join) != 0:
+				err = fsm.AddJoin(stringStates(t.Join), t.Event, StringState(t.To), action, guard)
+			default:
+				err = fsm.AddTransitionWithOptions(StringState(t.From), t.Event, StringState(t.To), action, guard, opts)
+			}
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var branches []ChoiceBranch
+		for ; i < len(def.Transitions); i++ {
+			b := def.Transitions[i]
+			if !b.Choice || b.From != t.From || b.Event != t.Event {
+				break
+			}
+			action, guard, opts, err := transitionHandlers(b, registry)
+			if err != nil {
+				return nil, err
+			}
+			branches = append(branches, ChoiceBranch{Guard: guard, To: StringState(b.To), Action: action, Options: opts})
+		}
+		i--
+		if err := fsm.AddChoice(StringState(t.From), t.Event, branches); err != nil {
+			return nil, err
+		}
+	}
+	return fsm, nil
+}
+
+func stringStates(ids []string) []State {
+	result := make([]State, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, StringState(id))
+	}
+	return result
+}
+
+// transitionHandlers looks up the action and guard of t in registry.
+func transitionHandlers(t TransitionDefinition, registry *HandlerRegistry) (
+	action func(interface{}, Event) error, guard func(interface{}, Event) bool, opts TransitionOptions, err error) {
+	if t.Action != "" {
+		var ok bool
+		if action, ok = registry.Action(t.Action); !ok {
+			return nil, nil, opts, handlerNotFound("action", t.Action)
+		}
+	}
+	if t.Guard != "" {
+		var ok bool
+		if guard, ok = registry.Guard(t.Guard); !ok {
+			return nil, nil, opts, handlerNotFound("guard", t.Guard)
+		}
+	}
+	opts = TransitionOptions{
+		Metadata: TransitionMetadata{
+			Name:        t.Name,
+			Description: t.Description,
+			Tags:        t.Tags,
+		},
+		ActionName: t.Action,
+		GuardName:  t.Guard,
+		Priority:   t.Priority,
+
+		Permissions: t.Permissions,
+	}
+	return action, guard, opts, nil
+}
+
+// Definition exports the topology of the FSM. Actions and guards are exported by the names given in
+// `TransitionOptions`.
+func (fsm *FSM) Definition() *Definition {
+	def := &Definition{
+		Version:     fsm.definitionVersion,
+		Initial:     fsm.initState,
+		States:      make([]string, 0, len(fsm.states)),
+		Events:      fsm.Events(),
+		Transitions: make([]TransitionDefinition, 0),
+	}
+	for _, state := range fsm.sortedStateIDs() {
+		if _, ok := fsm.histories[state]; !ok {
+			def.States = append(def.States, state)
+		}
+	}
+	if len(fsm.children) != 0 {
+		def.Composites = fsm.compositeDefinitions()
+	}
+	for _, info := range fsm.Transitions() {
+		def.Transitions = append(def.Transitions, TransitionDefinition{
+			From:        info.From.FSMStateID(),
+			Event:       info.Event,
+			To:          info.To.FSMStateID(),
+			Action:      info.ActionName,
+			Guard:       info.GuardName,
+			Name:        info.Metadata.Name,
+			Description: info.Metadata.Description,
+			Tags:        info.Metadata.Tags,
+			Choice:      info.Choice,
+			Fork:        stateIDs(info.Fork),
+			Join:        stateIDs(info.Join),
+			Priority:    info.Priority,
+			Permissions: info.Permissions,
+		})
+	}
+	return def
+}
+
+// LoadJSON creates a FSM from a JSON definition. See `Definition` for the format.
+func LoadJSON(data []byte, registry *HandlerRegistry) (*FSM, error) {
+	def := &Definition{}
+	if err := json.Unmarshal(data, def); err != nil {
+		return nil, err
+	}
+	return NewFSMFromDefinition(def, registry, nil)
+}
+
+// ExportJSON exports the FSM as a JSON definition. See `Definition` for the format.
+func (fsm *FSM) ExportJSON() ([]byte, error) {
+	return json.MarshalIndent(fsm.Definition(), "", "  ")
+}
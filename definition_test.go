@@ -0,0 +1,57 @@
+package fsm
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const switchDefinitionJSON = `{
+  "initial": "off",
+  "states": ["off", "on"],
+  "events": ["switch"],
+  "transitions": [
+    {"from": "off", "event": "switch", "to": "on", "action": "count", "guard": "enabled", "name": "turn on"},
+    {"from": "on", "event": "switch", "to": "off"}
+  ]
+}`
+
+func TestLoadJSON(t *testing.T) {
+	counter := 0
+	registry := NewHandlerRegistry()
+	assert.Nil(t, registry.RegisterAction("count", func(i interface{}, event Event) error {
+		counter++
+		return nil
+	}))
+	assert.Nil(t, registry.RegisterGuard("enabled", func(i interface{}, event Event) bool {
+		return true
+	}))
+
+	fsm, err := LoadJSON([]byte(switchDefinitionJSON), registry)
+	assert.Nil(t, err)
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("switch")))
+	assert.Equal(t, StringState("on"), fsm.CurrentState())
+	assert.Equal(t, 1, counter)
+
+	exported, err := fsm.ExportJSON()
+	assert.Nil(t, err)
+	def := &Definition{}
+	assert.Nil(t, json.Unmarshal(exported, def))
+	expected := &Definition{}
+	assert.Nil(t, json.Unmarshal([]byte(switchDefinitionJSON), expected))
+	assert.Equal(t, expected, def)
+
+	_, err = LoadJSON([]byte(switchDefinitionJSON), nil)
+	assert.EqualError(t, err, "action count not found")
+	_, err = LoadJSON([]byte(`{"states": ["off"]}`), nil)
+	assert.NotNil(t, err)
+}
+
+func TestDefinitionVersion(t *testing.T) {
+	fsm, err := LoadJSON([]byte(`{"version": "2", "initial": "off", "states": ["off"], "events": [], "transitions": []}`),
+		nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "2", fsm.DefinitionVersion())
+	fsm.SetDefinitionVersion("3")
+	assert.Equal(t, "3", fsm.Definition().Version)
+}
@@ -0,0 +1,115 @@
+package fsm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// transitionLabel is the edge label of a transition in diagrams.
+func transitionLabel(evID string, meta TransitionMetadata) string {
+	if meta.Name == "" {
+		return evID
+	}
+	return fmt.Sprintf("%s (%s)", evID, meta.Name)
+}
+
+// transitionTooltip renders the description and tags of a transition. It returns an empty string when
+// there is nothing to render.
+func transitionTooltip(meta TransitionMetadata) string {
+	lines := make([]string, 0, len(meta.Tags)+1)
+	if meta.Description != "" {
+		lines = append(lines, meta.Description)
+	}
+	keys := make([]string, 0, len(meta.Tags))
+	for k := range meta.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		lines = append(lines, fmt.Sprintf("%s=%s", k, meta.Tags[k]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DumpPlantUML dumps the FSM as a PlantUML state diagram. States and transitions are sorted, so the
+// result is stable. The initial arrow points to the current state, it is omitted if the FSM is not started.
+func (fsm *FSM) DumpPlantUML() string {
+	stateIDs := fsm.sortedStateIDs()
+	// state ids may contain characters which are not allowed by PlantUML, so use aliases.
+	alias := make(map[string]string, len(stateIDs))
+	for i, state := range stateIDs {
+		alias[state] = fmt.Sprintf("s%d", i)
+	}
+
+	b := &strings.Builder{}
+	b.WriteString("@startuml\n")
+	for _, state := range stateIDs {
+		fmt.Fprintf(b, "state %q as %s\n", state, alias[state])
+	}
+	if fsm.curState != "" {
+		fmt.Fprintf(b, "[*] --> %s\n", alias[fsm.curState])
+	}
+	for _, info := range fsm.Transitions() {
+		from := alias[info.From.FSMStateID()]
+		if info.Choice {
+			choiceID := choiceNodeID(info.From.FSMStateID(), info.Event)
+			if _, ok := alias[choiceID]; !ok {
+				alias[choiceID] = fmt.Sprintf("c%d", len(alias)-len(stateIDs))
+				fmt.Fprintf(b, "state %s <<choice>>\n", alias[choiceID])
+				fmt.Fprintf(b, "%s --> %s : %s\n", from, alias[choiceID], info.Event)
+			}
+			fmt.Fprintf(b, "%s --> %s : %s\n", alias[choiceID], alias[info.To.FSMStateID()],
+				choiceBranchLabel(info.Metadata, info.HasGuard, info.GuardName))
+		} else {
+			fmt.Fprintf(b, "%s --> %s : %s\n", from, alias[info.To.FSMStateID()],
+				transitionLabel(info.Event, info.Metadata))
+		}
+		if tooltip := transitionTooltip(info.Metadata); tooltip != "" {
+			b.WriteString("note on link\n")
+			for _, line := range strings.Split(tooltip, "\n") {
+				fmt.Fprintf(b, "  %s\n", line)
+			}
+			b.WriteString("end note\n")
+		}
+	}
+	b.WriteString("@enduml\n")
+	return b.String()
+}
+
+// DumpMermaid dumps the FSM as a Mermaid state diagram. States and transitions are sorted, so the result is
+// stable. The descriptions and tags of transitions are not rendered. Like `DumpPlantUML`, the initial arrow points
+// to the current state.
+func (fsm *FSM) DumpMermaid() string {
+	stateIDs := fsm.sortedStateIDs()
+	alias := make(map[string]string, len(stateIDs))
+	for i, state := range stateIDs {
+		alias[state] = fmt.Sprintf("s%d", i)
+	}
+
+	b := &strings.Builder{}
+	b.WriteString("stateDiagram-v2\n")
+	for _, state := range stateIDs {
+		fmt.Fprintf(b, "    state %q as %s\n", state, alias[state])
+	}
+	if current := fsm.currentStateID(); current != "" {
+		fmt.Fprintf(b, "    [*] --> %s\n", alias[current])
+	}
+	for _, info := range fsm.Transitions() {
+		from := alias[info.From.FSMStateID()]
+		if info.Choice {
+			choiceID := choiceNodeID(info.From.FSMStateID(), info.Event)
+			if _, ok := alias[choiceID]; !ok {
+				alias[choiceID] = fmt.Sprintf("c%d", len(alias)-len(stateIDs))
+				fmt.Fprintf(b, "    state %s <<choice>>\n", alias[choiceID])
+				fmt.Fprintf(b, "    %s --> %s : %s\n", from, alias[choiceID], info.Event)
+			}
+			fmt.Fprintf(b, "    %s --> %s : %s\n", alias[choiceID], alias[info.To.FSMStateID()],
+				choiceBranchLabel(info.Metadata, info.HasGuard, info.GuardName))
+		} else {
+			fmt.Fprintf(b, "    %s --> %s : %s\n", from, alias[info.To.FSMStateID()],
+				transitionLabel(info.Event, info.Metadata))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,62 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestTransitionMetadata(t *testing.T) {
+	var (
+		on            = StringState("on")
+		off           = StringState("off")
+		triggerSwitch = StringEvent("switch")
+	)
+	fsm := NewFSM(off, nil)
+	assert.Nil(t, fsm.AddState(on))
+	assert.Nil(t, fsm.AddEvent(string(triggerSwitch)))
+	tags := map[string]string{"owner": "ops"}
+	assert.Nil(t, fsm.AddTransitionWithOptions(off, string(triggerSwitch), on, nil, nil, TransitionOptions{
+		Metadata: TransitionMetadata{
+			Name:        "turn on",
+			Description: "power up the device",
+			Tags:        tags,
+		},
+	}))
+	assert.Nil(t, fsm.AddTransition(on, string(triggerSwitch), off, nil, nil))
+	tags["owner"] = "changed"
+
+	metas := fsm.TransitionMetadata(off, string(triggerSwitch))
+	assert.Len(t, metas, 1)
+	assert.Equal(t, "turn on", metas[0].Name)
+	assert.Equal(t, "ops", metas[0].Tags["owner"])
+	assert.Equal(t, []TransitionMetadata{{}}, fsm.TransitionMetadata(on, string(triggerSwitch)))
+
+	graphviz := fsm.DumpGraphviz()
+	assert.Contains(t, graphviz, "switch (turn on)")
+	assert.Contains(t, graphviz, "owner=ops")
+
+	uml := fsm.DumpPlantUML()
+	assert.True(t, strings.HasPrefix(uml, "@startuml\n"))
+	assert.Contains(t, uml, "[*] --> s0\n")
+	assert.Contains(t, uml, "s0 --> s1 : switch (turn on)\nnote on link\n  power up the device\n  owner=ops\nend note\n")
+	assert.Contains(t, uml, "s1 --> s0 : switch\n")
+
+	mermaid := fsm.DumpMermaid()
+	assert.Equal(t, "stateDiagram-v2\n"+
+		"    state \"off\" as s0\n"+
+		"    state \"on\" as s1\n"+
+		"    [*] --> s0\n"+
+		"    s0 --> s1 : switch (turn on)\n"+
+		"    s1 --> s0 : switch\n", mermaid)
+}
+
+func TestDumpNotStarted(t *testing.T) {
+	fsm := NewFSM(nil, nil)
+	assert.Nil(t, fsm.AddState(StringState("off")))
+	assert.NotContains(t, fsm.DumpMermaid(), "[*] -->")
+	assert.NotContains(t, fsm.DumpPlantUML(), "[*] -->")
+	assert.Nil(t, fsm.Start(StringState("off")))
+	assert.Contains(t, fsm.DumpMermaid(), "[*] --> s0")
+	assert.Contains(t, fsm.DumpPlantUML(), "[*] --> s0")
+}
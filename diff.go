@@ -0,0 +1,229 @@
+package fsm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ChangeKind is the kind of a `Change`.
+type ChangeKind int
+
+const (
+	// ChangeAdded means the element is only in the new definition.
+	ChangeAdded ChangeKind = iota
+	// ChangeRemoved means the element is only in the old definition.
+	ChangeRemoved
+	// ChangeModified means a field of the element is changed, see `Change.Field`.
+	ChangeModified
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	default:
+		return fmt.Sprintf("ChangeKind(%d)", int(k))
+	}
+}
+
+// Change is a semantic change between two definitions. See `Diff`.
+type Change struct {
+	Kind ChangeKind
+	// Element is the kind of the changed element, i.e., "version", "initial", "state", "event", "composite" or
+	// "transition".
+	Element string
+	// ID identifies the element, e.g., the state id, or "off -switch-> on" of a transition.
+	ID string
+	// Field is the changed field of a modified element, e.g., "guard" and "action" of a transition. Before and
+	// After are the values of the field, the actions and guards are referenced by their registered names.
+	Field  string
+	Before string
+	After  string
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("+ %s %s", c.Element, c.ID)
+	case ChangeRemoved:
+		return fmt.Sprintf("- %s %s", c.Element, c.ID)
+	}
+	name := c.Element
+	if c.ID != "" {
+		name += " " + c.ID
+	}
+	if c.Field != "" {
+		name += " " + c.Field
+	}
+	return fmt.Sprintf("~ %s: %q -> %q", name, c.Before, c.After)
+}
+
+// Diff returns the semantic changes from definition a to b, e.g., to review the change of a workflow. The changes
+// are listed in the order of the definition version, initial state, states, events, composite states and
+// transitions.
+//
+// The transitions are identified by their from states, events and to states. If several transitions share them,
+// e.g., the guarded transitions of a choice, they are matched in the order of the definitions. The changes of the
+// actions, guards, metadata, choice and priority of a matched transition are reported as modified.
+// NOTE: the order of the transitions is not compared, even if it changes the order of guard evaluation.
+func Diff(a, b Definition) []Change {
+	changes := make([]Change, 0)
+	if a.Version != b.Version {
+		changes = append(changes, Change{Kind: ChangeModified, Element: "version", Before: a.Version, After: b.Version})
+	}
+	if a.Initial != b.Initial {
+		changes = append(changes, Change{Kind: ChangeModified, Element: "initial", Before: a.Initial, After: b.Initial})
+	}
+	changes = diffIDs(changes, "state", a.States, b.States)
+	changes = diffIDs(changes, "event", a.Events, b.Events)
+	changes = diffComposites(changes, a.Composites, b.Composites)
+	return diffTransitions(changes, a.Transitions, b.Transitions)
+}
+
+// FormatChanges formats the changes one per line, like a unified diff:
+//
+//	~ initial: "off" -> "idle"
+//	+ state broken
+//	- event repair
+//	~ transition off -switch-> on guard: "hasPower" -> "isAdmin"
+//
+// It returns an empty string if there is no change.
+func FormatChanges(changes []Change) string {
+	var sb strings.Builder
+	for _, c := range changes {
+		sb.WriteString(c.String())
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// diffIDs appends the removed ids of a, and the added ids of b.
+func diffIDs(changes []Change, element string, a, b []string) []Change {
+	inA := make(map[string]bool, len(a))
+	for _, id := range a {
+		inA[id] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, id := range b {
+		inB[id] = true
+	}
+	for _, id := range a {
+		if !inB[id] {
+			changes = append(changes, Change{Kind: ChangeRemoved, Element: element, ID: id})
+		}
+	}
+	for _, id := range b {
+		if !inA[id] {
+			changes = append(changes, Change{Kind: ChangeAdded, Element: element, ID: id})
+		}
+	}
+	return changes
+}
+
+func diffComposites(changes []Change, a, b []CompositeDefinition) []Change {
+	inB := make(map[string]CompositeDefinition, len(b))
+	for _, c := range b {
+		inB[c.State] = c
+	}
+	inA := make(map[string]bool, len(a))
+	for _, before := range a {
+		inA[before.State] = true
+		after, ok := inB[before.State]
+		if !ok {
+			changes = append(changes, Change{Kind: ChangeRemoved, Element: "composite", ID: before.State})
+			continue
+		}
+		modified := func(field, x, y string) {
+			if x != y {
+				changes = append(changes, Change{Kind: ChangeModified, Element: "composite", ID: before.State,
+					Field: field, Before: x, After: y})
+			}
+		}
+		modified("initial", before.Initial, after.Initial)
+		modified("children", formatIDs(before.Children), formatIDs(after.Children))
+		modified("parallel", fmt.Sprint(before.Parallel), fmt.Sprint(after.Parallel))
+	}
+	for _, c := range b {
+		if !inA[c.State] {
+			changes = append(changes, Change{Kind: ChangeAdded, Element: "composite", ID: c.State})
+		}
+	}
+	return changes
+}
+
+func diffTransitions(changes []Change, a, b []TransitionDefinition) []Change {
+	// key -> the indices of the transitions in b, matched in order.
+	pending := make(map[string][]int)
+	for i, t := range b {
+		key := transitionKey(t)
+		pending[key] = append(pending[key], i)
+	}
+	matched := make([]bool, len(b))
+	for _, before := range a {
+		key := transitionKey(before)
+		if len(pending[key]) == 0 {
+			changes = append(changes, Change{Kind: ChangeRemoved, Element: "transition", ID: key})
+			continue
+		}
+		i := pending[key][0]
+		pending[key] = pending[key][1:]
+		matched[i] = true
+		after := b[i]
+		modified := func(field, x, y string) {
+			if x != y {
+				changes = append(changes, Change{Kind: ChangeModified, Element: "transition", ID: key,
+					Field: field, Before: x, After: y})
+			}
+		}
+		modified("guard", before.Guard, after.Guard)
+		modified("action", before.Action, after.Action)
+		modified("name", before.Name, after.Name)
+		modified("description", before.Description, after.Description)
+		modified("tags", formatTags(before.Tags), formatTags(after.Tags))
+		modified("choice", fmt.Sprint(before.Choice), fmt.Sprint(after.Choice))
+		modified("priority", fmt.Sprint(before.Priority), fmt.Sprint(after.Priority))
+		modified("permissions", strings.Join(before.Permissions, ", "), strings.Join(after.Permissions, ", "))
+	}
+	for i, t := range b {
+		if !matched[i] {
+			changes = append(changes, Change{Kind: ChangeAdded, Element: "transition", ID: transitionKey(t)})
+		}
+	}
+	return changes
+}
+
+// transitionKey identifies the transition in `Diff`, e.g., "off -switch-> on", "[a, b] -done-> finished" of a
+// join, and "idle -start-> [a, b]" of a fork.
+func transitionKey(t TransitionDefinition) string {
+	from, to := t.From, t.To
+	if len(t.Join) != 0 {
+		from = formatIDs(t.Join)
+	}
+	if len(t.Fork) != 0 {
+		to = formatIDs(t.Fork)
+	}
+	return fmt.Sprintf("%s -%s-> %s", from, t.Event, to)
+}
+
+func formatIDs(ids []string) string {
+	return "[" + strings.Join(ids, ", ") + "]"
+}
+
+// formatTags formats the tags sorted by keys, e.g., "owner=billing, sla=1h".
+func formatTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+tags[k])
+	}
+	return strings.Join(pairs, ", ")
+}
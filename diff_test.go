@@ -0,0 +1,104 @@
+package fsm
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func parseDefinition(t *testing.T, data string) Definition {
+	def := Definition{}
+	assert.Nil(t, json.Unmarshal([]byte(data), &def))
+	return def
+}
+
+func TestDiff(t *testing.T) {
+	before := parseDefinition(t, switchDefinitionJSON)
+	after := parseDefinition(t, `{
+  "version": "2",
+  "initial": "on",
+  "states": ["off", "on", "broken"],
+  "events": ["switch", "break"],
+  "transitions": [
+    {"from": "off", "event": "switch", "to": "on", "action": "count", "guard": "isAdmin", "name": "turn on",
+     "tags": {"owner": "ops"}},
+    {"from": "on", "event": "break", "to": "broken"}
+  ]
+}`)
+	changes := Diff(before, after)
+	assert.Equal(t, []Change{
+		{Kind: ChangeModified, Element: "version", After: "2"},
+		{Kind: ChangeModified, Element: "initial", Before: "off", After: "on"},
+		{Kind: ChangeAdded, Element: "state", ID: "broken"},
+		{Kind: ChangeAdded, Element: "event", ID: "break"},
+		{Kind: ChangeModified, Element: "transition", ID: "off -switch-> on", Field: "guard", Before: "enabled",
+			After: "isAdmin"},
+		{Kind: ChangeModified, Element: "transition", ID: "off -switch-> on", Field: "tags", After: "owner=ops"},
+		{Kind: ChangeRemoved, Element: "transition", ID: "on -switch-> off"},
+		{Kind: ChangeAdded, Element: "transition", ID: "on -break-> broken"},
+	}, changes)
+	assert.Equal(t, `~ version: "" -> "2"
+~ initial: "off" -> "on"
++ state broken
++ event break
+~ transition off -switch-> on guard: "enabled" -> "isAdmin"
+~ transition off -switch-> on tags: "" -> "owner=ops"
+- transition on -switch-> off
++ transition on -break-> broken
+`, FormatChanges(changes))
+
+	assert.Len(t, Diff(before, before), 0)
+	assert.Equal(t, "", FormatChanges(nil))
+}
+
+func TestDiffChoice(t *testing.T) {
+	before := Definition{
+		Initial: "idle",
+		States:  []string{"idle", "small", "large"},
+		Events:  []string{"order"},
+		Transitions: []TransitionDefinition{
+			{From: "idle", Event: "order", To: "large", Guard: "isLarge", Choice: true},
+			{From: "idle", Event: "order", To: "small", Choice: true},
+		},
+	}
+	after := Definition{
+		Initial: "idle",
+		States:  []string{"idle", "small", "large"},
+		Events:  []string{"order"},
+		Transitions: []TransitionDefinition{
+			{From: "idle", Event: "order", To: "large", Guard: "isHuge", Choice: true},
+			{From: "idle", Event: "order", To: "large", Guard: "isLarge", Choice: true},
+			{From: "idle", Event: "order", To: "small", Choice: true, Priority: 1},
+		},
+	}
+	// the transitions sharing the key are matched in order.
+	assert.Equal(t, []Change{
+		{Kind: ChangeModified, Element: "transition", ID: "idle -order-> large", Field: "guard", Before: "isLarge",
+			After: "isHuge"},
+		{Kind: ChangeModified, Element: "transition", ID: "idle -order-> small", Field: "priority", Before: "0",
+			After: "1"},
+		{Kind: ChangeAdded, Element: "transition", ID: "idle -order-> large"},
+	}, Diff(before, after))
+}
+
+func TestDiffComposites(t *testing.T) {
+	fsm := NewFSM(StringState("idle"), nil)
+	assert.Nil(t, fsm.AddState(StringState("playing")))
+	assert.Nil(t, fsm.AddChildState(StringState("playing"), StringState("audio")))
+	assert.Nil(t, fsm.AddChildState(StringState("playing"), StringState("video")))
+	assert.Nil(t, fsm.AddEvent("play"))
+	assert.Nil(t, fsm.AddTransition(StringState("idle"), "play", StringState("playing"), nil, nil))
+	before := *fsm.Definition()
+
+	assert.Nil(t, fsm.AddChildState(StringState("playing"), StringState("subtitles")))
+	assert.Nil(t, fsm.SetParallel(StringState("playing")))
+	changes := Diff(before, *fsm.Definition())
+	assert.Equal(t, []Change{
+		{Kind: ChangeAdded, Element: "state", ID: "subtitles"},
+		{Kind: ChangeModified, Element: "composite", ID: "playing", Field: "children", Before: "[audio, video]",
+			After: "[audio, video, subtitles]"},
+		{Kind: ChangeModified, Element: "composite", ID: "playing", Field: "parallel", Before: "false", After: "true"},
+	}, changes)
+	assert.Equal(t, "~ composite playing parallel: \"false\" -> \"true\"", changes[2].String())
+	assert.Equal(t, "removed", ChangeRemoved.String())
+}
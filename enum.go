@@ -0,0 +1,74 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Enum adds the states or the events of ids to the machine in one call, and returns them in order, instead of
+// the sequential `AddState` or `AddEvent` calls:
+//
+//	states, err := fsm.Enum[fsm.StringState](machine, "off", "on", "broken")
+//	events, err := fsm.Enum[fsm.StringEvent](machine, "switch", "repair")
+//
+// The ids should be unique, and should not be added to the machine before, except the initial state, which is
+// added by `NewFSM`. Nothing is added if there is a collision.
+func Enum[E StringState | StringEvent](fsm *FSM, ids ...string) ([]E, error) {
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return nil, errors.New(fmt.Sprintf("%s is declared more than once", id))
+		}
+		seen[id] = true
+		if err := fsm.checkEnum(E(id)); err != nil {
+			return nil, err
+		}
+	}
+	result := make([]E, 0, len(ids))
+	for _, id := range ids {
+		var err error
+		switch e := interface{}(E(id)).(type) {
+		case StringState:
+			if id != fsm.initState {
+				err = fsm.AddState(e)
+			}
+		case StringEvent:
+			err = fsm.AddEvent(id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, E(id))
+	}
+	return result, nil
+}
+
+// MustEnum is the same as `Enum`, but panics if there is an error.
+func MustEnum[E StringState | StringEvent](fsm *FSM, ids ...string) []E {
+	result, err := Enum[E](fsm, ids...)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// checkEnum returns an error if e cannot be added by `Enum`.
+func (fsm *FSM) checkEnum(e interface{}) error {
+	if err := fsm.checkMutable(); err != nil {
+		return err
+	}
+	switch e := e.(type) {
+	case StringState:
+		if state, ok := fsm.states[string(e)]; ok && (string(e) != fsm.initState || state != State(e)) {
+			return errors.New(fmt.Sprintf("state %s collides with the added one", e))
+		}
+	case StringEvent:
+		if e == CompletionEventID {
+			return errors.New("the event id should not be empty")
+		}
+		if fsm.HasEvent(string(e)) {
+			return errors.New(fmt.Sprintf("event %s collides with the added one", e))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type offState struct{}
+
+func (offState) FSMStateID() string {
+	return "off"
+}
+
+func TestEnum(t *testing.T) {
+	fsm := NewFSM(StringState("off"), nil)
+	states, err := Enum[StringState](fsm, "off", "on", "broken")
+	assert.Nil(t, err)
+	assert.Equal(t, []StringState{"off", "on", "broken"}, states)
+	events := MustEnum[StringEvent](fsm, "switch", "repair")
+	assert.Equal(t, []StringEvent{"switch", "repair"}, events)
+	assert.Equal(t, []string{"repair", "switch"}, fsm.Events())
+	assert.Nil(t, fsm.AddTransition(states[0], string(events[0]), states[1], nil, nil))
+	assert.Nil(t, fsm.ProcessEvent(events[0]))
+	assert.Equal(t, states[1], fsm.CurrentState())
+
+	// nothing is added if there is a collision.
+	for _, ids := range [][]string{{"idle", "on"}, {"idle", "idle"}} {
+		_, err = Enum[StringState](fsm, ids...)
+		assert.NotNil(t, err)
+		assert.False(t, fsm.HasState(StringState("idle")))
+	}
+	_, err = Enum[StringEvent](fsm, "reset", "switch")
+	assert.NotNil(t, err)
+	_, err = Enum[StringEvent](fsm, "reset", "")
+	assert.NotNil(t, err)
+	assert.False(t, fsm.HasEvent("reset"))
+	assert.Panics(t, func() {
+		MustEnum[StringEvent](fsm, "switch")
+	})
+
+	// the initial state collides with the states of other types.
+	_, err = Enum[StringState](NewFSM(offState{}, nil), "off")
+	assert.NotNil(t, err)
+	fsm.Compile()
+	_, err = Enum[StringEvent](fsm, "reset")
+	assert.Equal(t, ErrCompiled, err)
+}
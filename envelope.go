@@ -0,0 +1,118 @@
+package fsm
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Envelope wraps an event with the metadata correlating it with the upstream requests, e.g., for audit trails and
+// distributed tracing. It is an `Event` whose id is the id of the wrapped event, so it is processed like the
+// wrapped one, and it is passed as is to the actions, guards, observers, the `StateChange` of subscribers, and the
+// `DebugFrame` of the debugger. The persist package journals the metadata as well.
+//
+//	ev := fsm.NewEnvelope(&Deposit{Amount: 100}, "billing")
+//	ev.CorrelationID = requestID
+//	err := machine.ProcessEvent(ev)
+//
+// The actions use `Unwrap` to get the wrapped event, and `EnvelopeOf` to get the metadata:
+//
+//	deposit := fsm.Unwrap(ev).(*Deposit)
+//	if env, ok := fsm.EnvelopeOf(ev); ok {
+//		log.Printf("deposit %d of request %s", deposit.Amount, env.CorrelationID)
+//	}
+//
+// NOTE: the envelope should not be modified after it is passed to `ProcessEvent`.
+type Envelope struct {
+	Event Event `json:"-"`
+	// ID identifies the envelope, it is the CausationID of the events caused by this one.
+	ID string `json:"id,omitempty"`
+	// CorrelationID is shared by all events of the same upstream request.
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// CausationID is the ID of the envelope which causes this one, empty if it is not caused by an event.
+	CausationID string `json:"causation_id,omitempty"`
+	// Time is when the event is emitted.
+	Time time.Time `json:"time"`
+	// Source is where the event is emitted, e.g., the name of the service.
+	Source string `json:"source,omitempty"`
+	// Actor is who or what triggers the event, e.g., the user ID or the name of the service, for audit trails.
+	Actor string `json:"actor,omitempty"`
+}
+
+// NewEnvelope wraps ev with a new random ID, which is the CorrelationID as well, i.e., ev is the first event of a
+// request. The Time is now.
+func NewEnvelope(ev Event, source string) *Envelope {
+	id := newEnvelopeID()
+	return &Envelope{Event: ev, ID: id, CorrelationID: id, Time: time.Now(), Source: source}
+}
+
+// Caused wraps ev, which is caused by the event of e, e.g., posted by `PostInternal` in the action of e. The new
+// envelope has a new ID, the CorrelationID of e, the CausationID of e.ID, and the Actor of e, i.e., the actor is
+// accountable for the consequences of its events.
+func (e *Envelope) Caused(ev Event, source string) *Envelope {
+	return &Envelope{
+		Event:         ev,
+		ID:            newEnvelopeID(),
+		CorrelationID: e.CorrelationID,
+		CausationID:   e.ID,
+		Time:          time.Now(),
+		Source:        source,
+		Actor:         e.Actor,
+	}
+}
+
+// WithActor returns ev attributed to actor. If ev is an `Envelope`, it is a copy of ev with the Actor replaced,
+// otherwise ev is wrapped by a new envelope like `NewEnvelope`.
+func WithActor(ev Event, actor string) *Envelope {
+	if e, ok := ev.(*Envelope); ok {
+		attributed := *e
+		attributed.Actor = actor
+		return &attributed
+	}
+	id := newEnvelopeID()
+	return &Envelope{Event: ev, ID: id, CorrelationID: id, Time: time.Now(), Actor: actor}
+}
+
+func (e *Envelope) FSMEventID() string {
+	return e.Event.FSMEventID()
+}
+
+// FSMEventIndex makes the envelope of an `IndexedEvent` looked up by its index, the index is -1 if the wrapped
+// event is not indexed.
+func (e *Envelope) FSMEventIndex() int {
+	if indexed, ok := e.Event.(IndexedEvent); ok {
+		return indexed.FSMEventIndex()
+	}
+	return -1
+}
+
+// EnvelopeOf returns the envelope of ev. For a `CompletionEvent`, it is the envelope of the cause.
+func EnvelopeOf(ev Event) (*Envelope, bool) {
+	if completion, ok := ev.(CompletionEvent); ok {
+		ev = completion.Cause
+	}
+	e, ok := ev.(*Envelope)
+	return e, ok
+}
+
+// ActorOf returns the actor of ev, it is empty if ev is not attributed. See `WithActor`.
+func ActorOf(ev Event) string {
+	if e, ok := EnvelopeOf(ev); ok {
+		return e.Actor
+	}
+	return ""
+}
+
+// Unwrap returns the event wrapped by ev if ev is an `Envelope`, otherwise ev itself.
+func Unwrap(ev Event) Event {
+	if e, ok := ev.(*Envelope); ok {
+		return e.Event
+	}
+	return ev
+}
+
+func newEnvelopeID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,74 @@
+package fsm
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type correlationObserver struct {
+	NopObserver
+	ids []string
+}
+
+func (o *correlationObserver) EventFinished(ctx context.Context, fsm *FSM, ev Event, err error) {
+	if env, ok := EnvelopeOf(ev); ok {
+		o.ids = append(o.ids, env.CorrelationID)
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	fsm := newAccountFSM(NewFSM(StringState("open"), &account{}))
+	observer := &correlationObserver{}
+	fsm.AddObserver(observer)
+	fsm.SetDebugRecording(10, nil)
+	changes, cancel := fsm.Subscribe()
+	defer cancel()
+
+	env := NewEnvelope(&depositEvent{amount: 10}, "api")
+	assert.Equal(t, env.ID, env.CorrelationID)
+	assert.Equal(t, "deposit", env.FSMEventID())
+	result, err := fsm.ProcessEventWithResult(env)
+	assert.Nil(t, err)
+	assert.Equal(t, 10, result)
+	assert.Equal(t, []string{env.CorrelationID}, observer.ids)
+	assert.Same(t, env, (<-changes).Event)
+	frame, _ := fsm.Debugger().Frame()
+	assert.Same(t, env, frame.Event)
+
+	caused := env.Caused(StringEvent("audit"), "worker")
+	assert.NotEqual(t, env.ID, caused.ID)
+	assert.Equal(t, env.CorrelationID, caused.CorrelationID)
+	assert.Equal(t, env.ID, caused.CausationID)
+}
+
+func TestEnvelopeOf(t *testing.T) {
+	env := NewEnvelope(StringEvent("a"), "")
+	got, ok := EnvelopeOf(env)
+	assert.True(t, ok)
+	assert.Same(t, env, got)
+	got, ok = EnvelopeOf(CompletionEvent{Cause: env})
+	assert.True(t, ok)
+	assert.Same(t, env, got)
+	_, ok = EnvelopeOf(StringEvent("a"))
+	assert.False(t, ok)
+
+	assert.Equal(t, StringEvent("a"), Unwrap(env))
+	assert.Equal(t, StringEvent("a"), Unwrap(StringEvent("a")))
+}
+
+func TestEnvelopeOfIndexedEvent(t *testing.T) {
+	fsm := NewFSM(StringState("a"), nil)
+	assert.Nil(t, fsm.AddState(StringState("b")))
+	assert.Nil(t, fsm.AddEvent("next"))
+	assert.Nil(t, fsm.AddTransition(StringState("a"), "next", StringState("b"), nil, nil))
+	next, _ := fsm.IndexEvent("next")
+	env := &Envelope{Event: next}
+	index, ok := fsm.eventIndexOf(env)
+	assert.True(t, ok)
+	assert.Equal(t, 0, index)
+	_, ok = fsm.eventIndexOf(&Envelope{Event: StringEvent("next")})
+	assert.False(t, ok)
+	assert.Nil(t, fsm.ProcessEvent(env))
+	assert.Equal(t, StringState("b"), fsm.CurrentState())
+}
@@ -0,0 +1,202 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrCoalesced is returned by `ProcessEvent` of `QueuedFSM` and `PreemptiveFSM` if the event is held by an
+// `EventPolicy`, and replaced by a newer event of the same id before it is delivered.
+var ErrCoalesced = errors.New("the event is coalesced into a newer one")
+
+// EventPolicy limits how often the events of an id are delivered to the machine, see
+// `QueuedFSM.SetEventPolicy`. At most one of Debounce and Throttle can be set. The held events are delivered
+// when the window ends, and only the latest held event is delivered, the replaced ones return `ErrCoalesced`.
+type EventPolicy struct {
+	// Debounce holds the events until no event of the id arrives for the window, e.g., the "search" events
+	// of typing.
+	Debounce time.Duration
+	// Throttle delivers at most one event of the id per window, e.g., at most one "refresh" per 200ms. The first
+	// event is delivered at once, the events arriving in the window are held until it ends.
+	Throttle time.Duration
+}
+
+// eventPolicies holds the events by their `EventPolicy` before they are delivered to the queue. It is the
+// dispatch layer shared by `QueuedFSM` and `PreemptiveFSM`.
+type eventPolicies struct {
+	// enabled is true if any policy is set, so the events are not locked without policies.
+	enabled  atomic.Bool
+	mu       sync.Mutex
+	policies map[string]EventPolicy
+	windows  map[string]*eventWindow
+	closed   bool
+	// inflight counts the held events being delivered by the timers.
+	inflight sync.WaitGroup
+}
+
+// eventWindow is the window of an event id. gen identifies the timer, so a stopped timer which has fired does
+// not flush the window of the next timer.
+type eventWindow struct {
+	held  *eventEntry
+	timer Timer
+	gen   uint64
+}
+
+// set sets the policy of evID, the zero policy removes it.
+func (p *eventPolicies) set(evID string, policy EventPolicy) error {
+	if policy.Debounce < 0 || policy.Throttle < 0 {
+		return errors.New(fmt.Sprintf("the windows of event %s should not be negative", evID))
+	}
+	if policy.Debounce != 0 && policy.Throttle != 0 {
+		return errors.New(fmt.Sprintf("event %s should not be both debounced and throttled", evID))
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.policies == nil {
+		p.policies = make(map[string]EventPolicy)
+		p.windows = make(map[string]*eventWindow)
+	}
+	if policy == (EventPolicy{}) {
+		delete(p.policies, evID)
+	} else {
+		p.policies[evID] = policy
+	}
+	p.enabled.Store(len(p.policies) != 0)
+	return nil
+}
+
+// active returns true if any policy is set. Otherwise, the entries can be delivered without `dispatch`.
+func (p *eventPolicies) active() bool {
+	return p.enabled.Load()
+}
+
+// dispatch delivers the entry by deliver, at once or later by the clock according to the policy of its event.
+func (p *eventPolicies) dispatch(clock Clock, entry *eventEntry, deliver func(entry *eventEntry)) {
+	if entry.ev == nil {
+		deliver(entry)
+		return
+	}
+	evID := entry.ev.FSMEventID()
+	p.mu.Lock()
+	policy, ok := p.policies[evID]
+	if !ok || p.closed {
+		p.mu.Unlock()
+		deliver(entry)
+		return
+	}
+	window := p.windows[evID]
+	if window == nil {
+		window = &eventWindow{}
+		p.windows[evID] = window
+	}
+	replaced := window.held
+	now := false
+	switch {
+	case policy.Debounce > 0:
+		window.held = entry
+		if window.timer != nil {
+			window.timer.Stop()
+		}
+		window.gen++
+		gen := window.gen
+		window.timer = clock.AfterFunc(policy.Debounce, func() {
+			p.flush(window, gen, nil, deliver)
+		})
+	case window.timer != nil:
+		// the throttle window is open.
+		window.held = entry
+	default:
+		now = true
+		p.openThrottle(clock, window, policy.Throttle, deliver)
+	}
+	p.mu.Unlock()
+
+	if replaced != nil {
+		replaced.done <- ErrCoalesced
+	}
+	if now {
+		deliver(entry)
+	}
+}
+
+// openThrottle opens the throttle window, the held event is delivered when it ends, and opens the next window.
+// The caller should hold mu.
+func (p *eventPolicies) openThrottle(clock Clock, window *eventWindow, d time.Duration,
+	deliver func(entry *eventEntry)) {
+	window.gen++
+	gen := window.gen
+	window.timer = clock.AfterFunc(d, func() {
+		p.flush(window, gen, func() {
+			p.openThrottle(clock, window, d, deliver)
+		}, deliver)
+	})
+}
+
+// flush delivers the held event of the window when the timer of gen fires. If there is a held event, reopen is
+// invoked with mu held, otherwise the window is closed.
+func (p *eventPolicies) flush(window *eventWindow, gen uint64, reopen func(), deliver func(entry *eventEntry)) {
+	p.mu.Lock()
+	if window.gen != gen || p.closed {
+		p.mu.Unlock()
+		return
+	}
+	held := window.held
+	window.held = nil
+	window.timer = nil
+	if held == nil {
+		p.mu.Unlock()
+		return
+	}
+	if reopen != nil {
+		reopen()
+	}
+	p.inflight.Add(1)
+	p.mu.Unlock()
+	defer p.inflight.Done()
+	deliver(held)
+}
+
+// close stops the windows, the held events return err. It waits for the held events being delivered, so the
+// queue can be closed after it.
+func (p *eventPolicies) close(err error) {
+	p.mu.Lock()
+	p.closed = true
+	var held []*eventEntry
+	for _, window := range p.windows {
+		if window.timer != nil {
+			window.timer.Stop()
+			window.timer = nil
+		}
+		if window.held != nil {
+			held = append(held, window.held)
+			window.held = nil
+		}
+	}
+	p.mu.Unlock()
+	for _, entry := range held {
+		entry.done <- err
+	}
+	p.inflight.Wait()
+}
+
+// SetEventPolicy sets the debounce or throttle policy of the event evID, the zero policy removes it. The policy
+// is applied when the events are queued, so the actions do not hand-roll timers:
+//
+//	// process at most one "refresh" per 200ms, and deliver the latest.
+//	machine.SetEventPolicy("refresh", fsm.EventPolicy{Throttle: 200 * time.Millisecond})
+//
+// The windows are measured by the `Clock` of the machine. `ProcessEvent` of a held event blocks until it is
+// delivered and processed, or returns `ErrCoalesced` if it is replaced. The held events return `ErrQueueClosed`
+// when the machine is closed.
+func (q *QueuedFSM) SetEventPolicy(evID string, policy EventPolicy) error {
+	return q.policies.set(evID, policy)
+}
+
+// SetEventPolicy sets the debounce or throttle policy of the event evID, see `QueuedFSM.SetEventPolicy`. The
+// delivered events are preempted as usual.
+func (p *PreemptiveFSM) SetEventPolicy(evID string, policy EventPolicy) error {
+	return p.policies.set(evID, policy)
+}
@@ -0,0 +1,181 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stepClock fires its timers when the tests step it.
+type stepClock struct {
+	systemClock
+	mu     sync.Mutex
+	timers []*stepTimer
+}
+
+type stepTimer struct {
+	clock   *stepClock
+	f       func()
+	stopped bool
+}
+
+func (c *stepClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timer := &stepTimer{clock: c, f: f}
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+func (t *stepTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	stopped := t.stopped
+	t.stopped = true
+	return !stopped
+}
+
+// step fires the timers which are not stopped, and returns the number of them.
+func (c *stepClock) step() int {
+	c.mu.Lock()
+	var fired []*stepTimer
+	for _, timer := range c.timers {
+		if !timer.stopped {
+			timer.stopped = true
+			fired = append(fired, timer)
+		}
+	}
+	c.timers = nil
+	c.mu.Unlock()
+	for _, timer := range fired {
+		timer.f()
+	}
+	return len(fired)
+}
+
+type searchEvent struct {
+	text string
+}
+
+func (searchEvent) FSMEventID() string {
+	return "search"
+}
+
+func newPolicyFSM(t *testing.T, searched *[]string) (*QueuedFSM, *stepClock) {
+	clock := &stepClock{}
+	queued := NewQueuedFSM(StringState("idle"), nil)
+	queued.SetClock(clock)
+	assert.Nil(t, queued.AddEvent("search"))
+	assert.Nil(t, queued.AddTransition(StringState("idle"), "search", StringState("idle"),
+		func(_ interface{}, ev Event) error {
+			*searched = append(*searched, ev.(searchEvent).text)
+			return nil
+		}, nil))
+	return queued, clock
+}
+
+// processAsync processes the event in a new goroutine, the error is sent to the returned channel.
+func processAsync(machine interface{ ProcessEvent(Event) error }, ev Event) <-chan error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- machine.ProcessEvent(ev)
+	}()
+	return errCh
+}
+
+// waitHeld waits until the event is held.
+func waitHeld(p *eventPolicies, evID string) {
+	for {
+		p.mu.Lock()
+		window := p.windows[evID]
+		held := window != nil && window.held != nil
+		p.mu.Unlock()
+		if held {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestEventPolicyDebounce(t *testing.T) {
+	var searched []string
+	queued, clock := newPolicyFSM(t, &searched)
+	defer queued.Close()
+	assert.Nil(t, queued.SetEventPolicy("search", EventPolicy{Debounce: 100 * time.Millisecond}))
+
+	first := processAsync(queued, searchEvent{text: "f"})
+	waitHeld(&queued.policies, "search")
+	second := processAsync(queued, searchEvent{text: "fs"})
+	assert.Equal(t, ErrCoalesced, <-first)
+	assert.Equal(t, 1, clock.step())
+	assert.Nil(t, <-second)
+	assert.Equal(t, []string{"fs"}, searched)
+}
+
+func TestEventPolicyThrottle(t *testing.T) {
+	var searched []string
+	queued, clock := newPolicyFSM(t, &searched)
+	defer queued.Close()
+	assert.Nil(t, queued.SetEventPolicy("search", EventPolicy{Throttle: 200 * time.Millisecond}))
+
+	// the first event is delivered at once, and opens the window.
+	assert.Nil(t, queued.ProcessEvent(searchEvent{text: "a"}))
+	second := processAsync(queued, searchEvent{text: "b"})
+	waitHeld(&queued.policies, "search")
+	third := processAsync(queued, searchEvent{text: "c"})
+	assert.Equal(t, ErrCoalesced, <-second)
+	// the latest is delivered when the window ends, and opens the next window.
+	assert.Equal(t, 1, clock.step())
+	assert.Nil(t, <-third)
+	assert.Equal(t, []string{"a", "c"}, searched)
+
+	// the window without held events is closed.
+	assert.Equal(t, 1, clock.step())
+	assert.Nil(t, queued.ProcessEvent(searchEvent{text: "d"}))
+	assert.Equal(t, []string{"a", "c", "d"}, searched)
+
+	// the zero policy removes the policy.
+	assert.Nil(t, queued.SetEventPolicy("search", EventPolicy{}))
+	assert.Nil(t, queued.ProcessEvent(searchEvent{text: "e"}))
+	assert.Equal(t, []string{"a", "c", "d", "e"}, searched)
+}
+
+func TestEventPolicyClose(t *testing.T) {
+	var searched []string
+	queued, _ := newPolicyFSM(t, &searched)
+	assert.Nil(t, queued.SetEventPolicy("search", EventPolicy{Debounce: time.Hour}))
+	held := processAsync(queued, searchEvent{text: "a"})
+	waitHeld(&queued.policies, "search")
+	assert.Nil(t, queued.Close())
+	assert.Equal(t, ErrQueueClosed, <-held)
+	assert.Len(t, searched, 0)
+}
+
+func TestEventPolicyInvalid(t *testing.T) {
+	queued := NewQueuedFSM(StringState("idle"), nil)
+	defer queued.Close()
+	assert.NotNil(t, queued.SetEventPolicy("search", EventPolicy{Debounce: -1}))
+	assert.NotNil(t, queued.SetEventPolicy("search", EventPolicy{Debounce: 1, Throttle: 1}))
+}
+
+func TestPreemptiveEventPolicy(t *testing.T) {
+	clock := &stepClock{}
+	preemptive := NewPreemptiveFSM(StringState("idle"), nil)
+	defer preemptive.Close()
+	preemptive.SetClock(clock)
+	assert.Nil(t, preemptive.AddEvent("refresh"))
+	refreshed := 0
+	assert.Nil(t, preemptive.AddTransition(StringState("idle"), "refresh", StringState("idle"),
+		func(interface{}, Event) error {
+			refreshed++
+			return nil
+		}, nil))
+	assert.Nil(t, preemptive.SetEventPolicy("refresh", EventPolicy{Throttle: time.Second}))
+	assert.Nil(t, preemptive.ProcessEvent(StringEvent("refresh")))
+	held := processAsync(preemptive, StringEvent("refresh"))
+	waitHeld(&preemptive.policies, "refresh")
+	assert.Equal(t, 1, clock.step())
+	assert.Nil(t, <-held)
+	assert.Equal(t, 2, refreshed)
+}
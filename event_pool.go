@@ -0,0 +1,45 @@
+package fsm
+
+import "sync"
+
+// ResettableEvent is an `Event` which can be reused after `Reset`, see `EventPool`.
+type ResettableEvent interface {
+	Event
+	// Reset clears the data of the event.
+	Reset()
+}
+
+// EventPool reuses the events, so processing the events carrying data does not allocate them:
+//
+//	pool := fsm.NewEventPool(func() *ByteEvent { return &ByteEvent{} })
+//	ev := pool.Get()
+//	ev.Byte = b
+//	err := machine.ProcessEvent(ev)
+//	pool.Put(ev)
+//
+// NOTE: an event should not be put back if it is kept by the FSM after `ProcessEvent` returns, i.e., by
+// `SetRollbackLimit`, `SetDebugRecording`, `ExplainLastRejection`, `Subscribe` or a `Transaction`, or by the
+// actions.
+type EventPool[E ResettableEvent] struct {
+	pool sync.Pool
+}
+
+// NewEventPool creates a pool whose events are created by newEvent.
+func NewEventPool[E ResettableEvent](newEvent func() E) *EventPool[E] {
+	p := &EventPool[E]{}
+	p.pool.New = func() interface{} {
+		return newEvent()
+	}
+	return p
+}
+
+// Get returns a reset event.
+func (p *EventPool[E]) Get() E {
+	return p.pool.Get().(E)
+}
+
+// Put resets the event and puts it back to the pool.
+func (p *EventPool[E]) Put(ev E) {
+	ev.Reset()
+	p.pool.Put(ev)
+}
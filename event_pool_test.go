@@ -0,0 +1,145 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type byteEvent struct {
+	b byte
+}
+
+func (*byteEvent) FSMEventID() string {
+	return "byte"
+}
+
+func (e *byteEvent) Reset() {
+	e.b = 0
+}
+
+// newParserFSM creates a FSM counting the digits, which allocates nothing for each event.
+func newParserFSM(digits *int) *FSM {
+	var (
+		start = StringState("start")
+		digit = StringState("digit")
+	)
+	fsm := NewFSM(start, nil)
+	_ = fsm.AddState(digit)
+	_ = fsm.AddEvent("byte")
+	isDigit := func(_ interface{}, ev Event) bool {
+		b := ev.(*byteEvent).b
+		return b >= '0' && b <= '9'
+	}
+	count := func(interface{}, Event) error {
+		*digits++
+		return nil
+	}
+	for _, from := range []State{start, digit} {
+		_ = fsm.AddTransition(from, "byte", digit, count, isDigit)
+		_ = fsm.AddTransition(from, "byte", start, nil, nil)
+	}
+	return fsm
+}
+
+func TestEventPool(t *testing.T) {
+	pool := NewEventPool(func() *byteEvent { return &byteEvent{} })
+	digits := 0
+	fsm := newParserFSM(&digits)
+	for _, b := range []byte("a1b23") {
+		ev := pool.Get()
+		assert.Equal(t, byte(0), ev.b)
+		ev.b = b
+		assert.Nil(t, fsm.ProcessEvent(ev))
+		pool.Put(ev)
+	}
+	assert.Equal(t, 3, digits)
+	assert.Equal(t, StringState("digit"), fsm.CurrentState())
+}
+
+func TestProcessEventAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector allocates")
+	}
+	if ownershipCheckByDefault {
+		t.Skip("the ownership check allocates")
+	}
+	pool := NewEventPool(func() *byteEvent { return &byteEvent{} })
+	digits := 0
+	fsm := newParserFSM(&digits)
+	input := []byte("12a3")
+	i := 0
+	assert.Equal(t, 0.0, testing.AllocsPerRun(1000, func() {
+		ev := pool.Get()
+		ev.b = input[i%len(input)]
+		i++
+		_ = fsm.ProcessEvent(ev)
+		pool.Put(ev)
+	}))
+
+	ring := newRingFSM(8)
+	ring.Compile()
+	events := make([]Event, 8)
+	for i := range events {
+		events[i], _ = ring.IndexEvent(ringEvent(i))
+	}
+	assert.Equal(t, 0.0, testing.AllocsPerRun(1000, func() {
+		_ = ring.ProcessEvent(events[i%len(events)])
+		i++
+	}))
+}
+
+func TestQueuedProcessEventAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector allocates")
+	}
+	queued := NewQueuedFSM(StringState("off"), nil)
+	defer queued.Close()
+	preemptive := NewPreemptiveFSM(StringState("off"), nil)
+	defer preemptive.Close()
+	for _, fsm := range []*FSM{queued.FSM, preemptive.FSM} {
+		_ = fsm.AddState(StringState("on"))
+		_ = fsm.AddEvent("switch")
+		_ = fsm.AddTransition(StringState("off"), "switch", StringState("on"), nil, nil)
+		_ = fsm.AddTransition(StringState("on"), "switch", StringState("off"), nil, nil)
+	}
+	ev := StringEvent("switch")
+	assert.Equal(t, 0.0, testing.AllocsPerRun(1000, func() {
+		_ = queued.ProcessEvent(ev)
+	}))
+	assert.Equal(t, 0.0, testing.AllocsPerRun(1000, func() {
+		_ = preemptive.ProcessEvent(ev)
+	}))
+}
+
+func TestGlobalActionHooks(t *testing.T) {
+	fsm := NewFSM(StringState("off"), nil)
+	_ = fsm.AddState(StringState("on"))
+	_ = fsm.AddEvent("switch")
+	_ = fsm.AddTransition(StringState("off"), "switch", StringState("on"), nil, nil)
+	_ = fsm.AddTransition(StringState("on"), "switch", StringState("off"), nil, nil)
+	var hooked []string
+	fsm.GlobalBeforeAction.Add(func(args interface{}) {
+		hooked = append(hooked, "before "+args.(ActionHookArgs).ToState.FSMStateID())
+	})
+	fsm.GlobalAfterAction.Add(func(args interface{}) {
+		hooked = append(hooked, "after "+args.(ActionHookArgs).ToState.FSMStateID())
+	})
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("switch")))
+	fsm.SetGlobalActionHooks(false)
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("switch")))
+	assert.Equal(t, []string{"before on", "after on"}, hooked)
+}
+
+func BenchmarkQueuedProcessEvent(b *testing.B) {
+	fsm := NewQueuedFSM(StringState("off"), nil)
+	defer fsm.Close()
+	_ = fsm.AddState(StringState("on"))
+	_ = fsm.AddEvent("switch")
+	_ = fsm.AddTransition(StringState("off"), "switch", StringState("on"), nil, nil)
+	_ = fsm.AddTransition(StringState("on"), "switch", StringState("off"), nil, nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = fsm.ProcessEvent(StringEvent("switch"))
+	}
+}
@@ -0,0 +1,331 @@
+// Package tcp is an example of a simplified TCP-like connection, i.e., the SYN/SYN-ACK/ACK handshake and the
+// FIN/ACK close, between two `Endpoint`s connected by a `fsm.Router`. It exercises the features working together:
+//   - hierarchical states: the handshake and closing states are composites, and the timeout of the handshake is a
+//     transition of the composite, which the retransmission of syn_sent takes priority over.
+//   - timers: the handshake and the time_wait state are limited by the timers of `Config.Clock`, whose events are
+//     ignored by a guard once the timers are replaced.
+//   - deferred events: the data sent during the handshake is kept, and processed by `fsm.FSM.PostInternal` when
+//     the connection is established.
+//
+// The machine is defined by a `fsm.Definition`, see `Definition`.
+package tcp
+
+import (
+	"errors"
+	"github.com/reyoung/fsm"
+	"time"
+)
+
+// The states of `Definition`.
+const (
+	Closed      = "closed"
+	Listen      = "listen"
+	Handshake   = "handshake"
+	SynSent     = "syn_sent"
+	SynReceived = "syn_received"
+	Established = "established"
+	Closing     = "closing"
+	FinWait1    = "fin_wait_1"
+	FinWait2    = "fin_wait_2"
+	TimeWait    = "time_wait"
+	CloseWait   = "close_wait"
+	LastAck     = "last_ack"
+)
+
+// The events of `Definition`. Open, Listen, Close and Send are the calls of the application, the others are the
+// segments received from the peer, and the timeouts.
+const (
+	EvOpen    = "open"
+	EvListen  = "listen"
+	EvClose   = "close"
+	EvSend    = "send"
+	EvSyn     = "syn"
+	EvSynAck  = "syn_ack"
+	EvAck     = "ack"
+	EvFin     = "fin"
+	EvData    = "data"
+	EvTimeout = "timeout"
+)
+
+// DataEvent is the data sent by the application (`EvSend`), or received from the peer (`EvData`).
+type DataEvent struct {
+	ID   string
+	Data string
+}
+
+func (e DataEvent) FSMEventID() string {
+	return e.ID
+}
+
+// TimeoutEvent is fired by the timer of generation Gen. The timers of the previous generations are ignored.
+type TimeoutEvent struct {
+	Gen uint64
+}
+
+func (TimeoutEvent) FSMEventID() string {
+	return EvTimeout
+}
+
+// Definition returns the definition of the connection:
+//
+//	closed -open-> syn_sent -syn_ack-> established
+//	closed -listen-> listen -syn-> syn_received -ack-> established
+//	established -close-> fin_wait_1 -ack-> fin_wait_2 -fin-> time_wait -timeout-> closed
+//	established -fin-> close_wait -close-> last_ack -ack-> closed
+//
+// syn_sent and syn_received are the children of handshake, whose timeout or close resets the connection. The
+// states from fin_wait_1 to last_ack are the children of closing.
+func Definition() *fsm.Definition {
+	t := func(from string, event string, to string, action string, guard string) fsm.TransitionDefinition {
+		return fsm.TransitionDefinition{From: from, Event: event, To: to, Action: action, Guard: guard}
+	}
+	return &fsm.Definition{
+		Initial: Closed,
+		States: []string{Closed, Listen, Handshake, SynSent, SynReceived, Established, Closing, FinWait1, FinWait2,
+			TimeWait, CloseWait, LastAck},
+		Events: []string{EvOpen, EvListen, EvClose, EvSend, EvSyn, EvSynAck, EvAck, EvFin, EvData, EvTimeout},
+		Composites: []fsm.CompositeDefinition{
+			{State: Handshake, Initial: SynSent, Children: []string{SynSent, SynReceived}},
+			{State: Closing, Initial: FinWait1, Children: []string{FinWait1, FinWait2, TimeWait, CloseWait, LastAck}},
+		},
+		Transitions: []fsm.TransitionDefinition{
+			t(Closed, EvOpen, SynSent, "sendSyn", ""),
+			t(Closed, EvListen, Listen, "", ""),
+			t(Listen, EvClose, Closed, "", ""),
+			t(Listen, EvSyn, SynReceived, "sendSynAck", ""),
+			t(SynSent, EvTimeout, SynSent, "retransmitSyn", "canRetransmit"),
+			t(SynSent, EvSynAck, Established, "establish", ""),
+			t(SynReceived, EvAck, Established, "accept", ""),
+			t(SynSent, EvSend, SynSent, "deferSend", ""),
+			t(SynReceived, EvSend, SynReceived, "deferSend", ""),
+			t(Handshake, EvTimeout, Closed, "reset", "currentTimer"),
+			t(Handshake, EvClose, Closed, "reset", ""),
+			t(Established, EvSend, Established, "transmit", ""),
+			t(Established, EvData, Established, "receive", ""),
+			t(Established, EvClose, FinWait1, "sendFin", ""),
+			t(Established, EvFin, CloseWait, "sendAck", ""),
+			t(FinWait1, EvAck, FinWait2, "", ""),
+			t(FinWait2, EvFin, TimeWait, "timeWait", ""),
+			t(TimeWait, EvTimeout, Closed, "reset", "currentTimer"),
+			t(CloseWait, EvClose, LastAck, "sendFin", ""),
+			t(LastAck, EvAck, Closed, "", ""),
+		},
+	}
+}
+
+// Config configures an `Endpoint`.
+type Config struct {
+	// HandshakeTimeout limits each attempt of the handshake, 1 second by default.
+	HandshakeTimeout time.Duration
+	// Retransmits is the number of the SYN retransmissions after the first attempt times out.
+	Retransmits int
+	// TimeWait is the duration of the time_wait state, 2 seconds by default.
+	TimeWait time.Duration
+	// Clock measures the timeouts, the system clock if it is nil.
+	Clock fsm.Clock
+}
+
+// tcb is the transmission control block, i.e., the payload of the machine of an `Endpoint`.
+type tcb struct {
+	cfg     Config
+	name    string
+	peer    string
+	router  *fsm.Router
+	machine *fsm.QueuedFSM
+	// timerGen is the generation of the armed timer, see `TimeoutEvent`.
+	timerGen uint64
+	timer    fsm.Timer
+	retries  int
+	// pending is the data deferred until the connection is established.
+	pending  []string
+	received []string
+}
+
+// send sends the segment to the peer. A segment to an unknown peer is lost, like in a network.
+func (c *tcb) send(ev fsm.Event) error {
+	err := c.router.SendContext(c.machine.ActionContext(), c.peer, ev)
+	if errors.Is(err, fsm.ErrRouteNotFound) {
+		return nil
+	}
+	return err
+}
+
+// arm replaces the timer by a new one of d.
+func (c *tcb) arm(d time.Duration) {
+	c.stop()
+	c.timerGen++
+	gen, name, router := c.timerGen, c.name, c.router
+	c.timer = c.machine.Clock().AfterFunc(d, func() {
+		// the timeouts are delivered by the router like the segments, so they are dropped after the router is
+		// closed.
+		_ = router.Send(name, TimeoutEvent{Gen: gen})
+	})
+}
+
+func (c *tcb) stop() {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+}
+
+func newRegistry() *fsm.HandlerRegistry {
+	action := func(fn func(c *tcb, ev fsm.Event) error) func(interface{}, fsm.Event) error {
+		return func(payload interface{}, ev fsm.Event) error {
+			return fn(payload.(*tcb), ev)
+		}
+	}
+	return fsm.NewHandlerRegistry().
+		MustRegisterAction("sendSyn", action(func(c *tcb, ev fsm.Event) error {
+			c.retries = 0
+			c.arm(c.cfg.HandshakeTimeout)
+			return c.send(fsm.StringEvent(EvSyn))
+		})).
+		MustRegisterAction("retransmitSyn", action(func(c *tcb, ev fsm.Event) error {
+			c.retries++
+			c.arm(c.cfg.HandshakeTimeout)
+			return c.send(fsm.StringEvent(EvSyn))
+		})).
+		MustRegisterAction("sendSynAck", action(func(c *tcb, ev fsm.Event) error {
+			c.arm(c.cfg.HandshakeTimeout)
+			return c.send(fsm.StringEvent(EvSynAck))
+		})).
+		MustRegisterAction("establish", action(func(c *tcb, ev fsm.Event) error {
+			c.flush()
+			return c.send(fsm.StringEvent(EvAck))
+		})).
+		MustRegisterAction("accept", action(func(c *tcb, ev fsm.Event) error {
+			c.flush()
+			return nil
+		})).
+		MustRegisterAction("deferSend", action(func(c *tcb, ev fsm.Event) error {
+			c.pending = append(c.pending, ev.(DataEvent).Data)
+			return nil
+		})).
+		MustRegisterAction("transmit", action(func(c *tcb, ev fsm.Event) error {
+			return c.send(DataEvent{ID: EvData, Data: ev.(DataEvent).Data})
+		})).
+		MustRegisterAction("receive", action(func(c *tcb, ev fsm.Event) error {
+			c.received = append(c.received, ev.(DataEvent).Data)
+			return nil
+		})).
+		MustRegisterAction("sendFin", action(func(c *tcb, ev fsm.Event) error {
+			return c.send(fsm.StringEvent(EvFin))
+		})).
+		MustRegisterAction("sendAck", action(func(c *tcb, ev fsm.Event) error {
+			return c.send(fsm.StringEvent(EvAck))
+		})).
+		MustRegisterAction("timeWait", action(func(c *tcb, ev fsm.Event) error {
+			c.arm(c.cfg.TimeWait)
+			return c.send(fsm.StringEvent(EvAck))
+		})).
+		MustRegisterAction("reset", action(func(c *tcb, ev fsm.Event) error {
+			c.stop()
+			c.pending = nil
+			return nil
+		})).
+		MustRegisterGuard("currentTimer", func(payload interface{}, ev fsm.Event) bool {
+			return ev.(TimeoutEvent).Gen == payload.(*tcb).timerGen
+		}).
+		MustRegisterGuard("canRetransmit", func(payload interface{}, ev fsm.Event) bool {
+			c := payload.(*tcb)
+			return ev.(TimeoutEvent).Gen == c.timerGen && c.retries < c.cfg.Retransmits
+		})
+}
+
+// flush stops the handshake timer, and posts the deferred data, which are sent after the current transition
+// completes, i.e., after the connection is established.
+func (c *tcb) flush() {
+	c.stop()
+	for _, data := range c.pending {
+		c.machine.PostInternal(DataEvent{ID: EvSend, Data: data})
+	}
+	c.pending = nil
+}
+
+// Endpoint is an end of a connection, which is added to the router by its name, and sends the segments to the
+// peer by the router.
+type Endpoint struct {
+	name    string
+	router  *fsm.Router
+	machine *fsm.QueuedFSM
+}
+
+// NewEndpoint creates a closed endpoint of name connecting to peer, and adds it to the router.
+func NewEndpoint(router *fsm.Router, name string, peer string, cfg Config) (*Endpoint, error) {
+	if cfg.HandshakeTimeout <= 0 {
+		cfg.HandshakeTimeout = time.Second
+	}
+	if cfg.TimeWait <= 0 {
+		cfg.TimeWait = 2 * time.Second
+	}
+	c := &tcb{cfg: cfg, name: name, peer: peer, router: router}
+	machine := fsm.NewQueuedFSM(fsm.StringState(Closed), c)
+	machine.SetName(name)
+	if cfg.Clock != nil {
+		machine.SetClock(cfg.Clock)
+	}
+	c.machine = machine
+	err := machine.Do(func(m *fsm.FSM) error {
+		m.SetHandlerRegistry(newRegistry())
+		return m.SwapDefinition(*Definition(), nil)
+	})
+	if err == nil {
+		err = router.Add(name, machine)
+	}
+	if err != nil {
+		_ = machine.Close()
+		return nil, err
+	}
+	return &Endpoint{name: name, router: router, machine: machine}, nil
+}
+
+// Machine returns the machine of the endpoint.
+func (e *Endpoint) Machine() *fsm.QueuedFSM {
+	return e.machine
+}
+
+// State returns the current leaf state.
+func (e *Endpoint) State() string {
+	return e.machine.CurrentState().FSMStateID()
+}
+
+// Open starts the active open, i.e., sends SYN to the peer.
+func (e *Endpoint) Open() error {
+	return e.machine.ProcessEvent(fsm.StringEvent(EvOpen))
+}
+
+// Listen waits for the SYN of the peer.
+func (e *Endpoint) Listen() error {
+	return e.machine.ProcessEvent(fsm.StringEvent(EvListen))
+}
+
+// Send sends the data to the peer. The data is deferred if the handshake is in progress.
+func (e *Endpoint) Send(data string) error {
+	return e.machine.ProcessEvent(DataEvent{ID: EvSend, Data: data})
+}
+
+// Close starts closing the connection, or resets the handshake.
+func (e *Endpoint) Close() error {
+	return e.machine.ProcessEvent(fsm.StringEvent(EvClose))
+}
+
+// Received returns the data received from the peer.
+func (e *Endpoint) Received() []string {
+	var received []string
+	_ = e.machine.Do(func(m *fsm.FSM) error {
+		received = append(received, m.Payload().(*tcb).received...)
+		return nil
+	})
+	return received
+}
+
+// Shutdown stops the timer and the machine of the endpoint. The router should be closed before.
+func (e *Endpoint) Shutdown() error {
+	_ = e.machine.Do(func(m *fsm.FSM) error {
+		m.Payload().(*tcb).stop()
+		return nil
+	})
+	e.router.Remove(e.name)
+	return e.machine.Close()
+}
@@ -0,0 +1,162 @@
+package tcp
+
+import (
+	"context"
+	"github.com/reyoung/fsm"
+	"github.com/reyoung/fsm/fsmtest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// network connects the endpoints of a test by a router.
+type network struct {
+	t         *testing.T
+	clock     *fsmtest.FakeClock
+	router    *fsm.Router
+	endpoints []*Endpoint
+	ctx       context.Context
+	cancel    func()
+}
+
+func newNetwork(t *testing.T) *network {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	return &network{t: t, clock: fsmtest.NewFakeClock(time.Unix(0, 0)), router: fsm.NewRouter(), ctx: ctx,
+		cancel: cancel}
+}
+
+func (n *network) endpoint(name string, peer string, retransmits int) *Endpoint {
+	e, err := NewEndpoint(n.router, name, peer, Config{Retransmits: retransmits, Clock: n.clock})
+	if !assert.Nil(n.t, err) {
+		n.t.FailNow()
+	}
+	n.endpoints = append(n.endpoints, e)
+	return e
+}
+
+// wait blocks until the endpoint enters the state.
+func (n *network) wait(e *Endpoint, state string) {
+	assert.Nil(n.t, e.Machine().WaitForState(n.ctx, fsm.StringState(state)), "waiting for %s", state)
+}
+
+func (n *network) close() {
+	assert.Nil(n.t, n.router.Close())
+	for _, e := range n.endpoints {
+		assert.Nil(n.t, e.Shutdown())
+	}
+	n.cancel()
+}
+
+// establish connects the client to the server.
+func (n *network) establish(client *Endpoint, server *Endpoint) {
+	assert.Nil(n.t, server.Listen())
+	assert.Nil(n.t, client.Open())
+	n.wait(client, Established)
+	n.wait(server, Established)
+}
+
+func TestHandshake(t *testing.T) {
+	n := newNetwork(t)
+	client := n.endpoint("client", "server", 0)
+	server := n.endpoint("server", "client", 0)
+	defer n.close()
+
+	n.establish(client, server)
+	assert.Nil(t, client.Send("hello"))
+	assert.Nil(t, server.Send("world"))
+	assert.Eventually(t, func() bool { return len(server.Received()) == 1 && len(client.Received()) == 1 },
+		5*time.Second, time.Millisecond)
+	assert.Equal(t, []string{"hello"}, server.Received())
+	assert.Equal(t, []string{"world"}, client.Received())
+	// the handshake timers are stopped.
+	assert.Equal(t, 0, n.clock.Timers())
+}
+
+func TestDeferredSendAndRetransmission(t *testing.T) {
+	n := newNetwork(t)
+	client := n.endpoint("client", "server", 1)
+	// the first SYN is lost, because the server does not exist.
+	assert.Nil(t, client.Open())
+	server := n.endpoint("server", "client", 0)
+	defer n.close()
+	assert.Nil(t, server.Listen())
+	// the data is deferred until the connection is established.
+	assert.Nil(t, client.Send("first"))
+	assert.Nil(t, client.Send("second"))
+	assert.Equal(t, SynSent, client.State())
+	assert.True(t, client.Machine().IsIn(fsm.StringState(Handshake)))
+
+	n.clock.Advance(time.Second)
+	n.wait(client, Established)
+	assert.Eventually(t, func() bool { return len(server.Received()) == 2 }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, []string{"first", "second"}, server.Received())
+	assert.Equal(t, Established, server.State())
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	n := newNetwork(t)
+	client := n.endpoint("client", "server", 1)
+	defer n.close()
+
+	assert.Nil(t, client.Open())
+	assert.Nil(t, client.Send("lost"))
+	n.clock.WaitTimers(1)
+	n.clock.Advance(time.Second)
+	// the retransmission of syn_sent takes priority over the timeout of handshake.
+	n.clock.WaitTimers(1)
+	assert.Equal(t, SynSent, client.State())
+	n.clock.Advance(time.Second)
+	n.wait(client, Closed)
+	assert.Equal(t, uint64(1), transitionsFired(client, SynSent, EvTimeout, SynSent))
+	assert.Equal(t, uint64(1), transitionsFired(client, Handshake, EvTimeout, Closed))
+
+	// the deferred data is dropped by the reset.
+	server := n.endpoint("server", "client", 0)
+	n.establish(client, server)
+	assert.Nil(t, client.Send("fresh"))
+	assert.Eventually(t, func() bool { return len(server.Received()) == 1 }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, []string{"fresh"}, server.Received())
+}
+
+func TestClose(t *testing.T) {
+	n := newNetwork(t)
+	client := n.endpoint("client", "server", 0)
+	server := n.endpoint("server", "client", 0)
+	defer n.close()
+	n.establish(client, server)
+
+	// the active close of client, and the passive close of server.
+	assert.Nil(t, client.Close())
+	n.wait(server, CloseWait)
+	n.wait(client, FinWait2)
+	assert.True(t, client.Machine().IsIn(fsm.StringState(Closing)))
+	assert.Nil(t, server.Close())
+	n.wait(client, TimeWait)
+	n.wait(server, Closed)
+
+	n.clock.WaitTimers(1)
+	n.clock.Advance(time.Second)
+	assert.Equal(t, TimeWait, client.State())
+	n.clock.Advance(time.Second)
+	n.wait(client, Closed)
+}
+
+func TestHandshakeClose(t *testing.T) {
+	n := newNetwork(t)
+	client := n.endpoint("client", "server", 3)
+	defer n.close()
+
+	assert.Nil(t, client.Open())
+	assert.Nil(t, client.Close())
+	assert.Equal(t, Closed, client.State())
+	assert.Equal(t, 0, n.clock.Timers())
+}
+
+func transitionsFired(e *Endpoint, from string, event string, to string) uint64 {
+	for _, t := range e.Machine().Stats().Transitions {
+		if t.From.FSMStateID() == from && t.Event == event && t.To.FSMStateID() == to {
+			return t.Latency.Count
+		}
+	}
+	return 0
+}
@@ -0,0 +1,229 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// The machines shared by the tests. They are built by the helpers below, which add the definitions in order and
+// assert that they are added.
+
+func addStates(t *testing.T, fsm *FSM, states ...State) {
+	for _, state := range states {
+		assert.Nil(t, fsm.AddState(state))
+	}
+}
+
+func addChildStates(t *testing.T, fsm *FSM, parent State, children ...State) {
+	for _, child := range children {
+		assert.Nil(t, fsm.AddChildState(parent, child))
+	}
+}
+
+func addEvents(t *testing.T, fsm *FSM, events ...string) {
+	for _, ev := range events {
+		assert.Nil(t, fsm.AddEvent(ev))
+	}
+}
+
+// addTransitions adds the transitions of the rows without actions and guards, the Action and the Guard of the rows
+// are ignored.
+func addTransitions(t *testing.T, fsm *FSM, rows ...T) {
+	for _, row := range rows {
+		assert.Nil(t, fsm.AddTransition(row.From, row.Event, row.To, nil, nil))
+	}
+}
+
+// newOwnershipFSM creates a machine toggling in one state, whose ownership is checked.
+func newOwnershipFSM(t *testing.T) *FSM {
+	fsm := NewFSM(StringState("off"), nil)
+	addEvents(t, fsm, "toggle")
+	addTransitions(t, fsm, T{From: StringState("off"), Event: "toggle", To: StringState("off")})
+	fsm.SetOwnershipCheck(true)
+	return fsm
+}
+
+// newTransferFSM creates a machine reserving and charging a transfer.
+func newTransferFSM(t *testing.T) *FSM {
+	var (
+		created  = StringState("created")
+		reserved = StringState("reserved")
+		charged  = StringState("charged")
+	)
+	fsm := NewFSM(created, nil)
+	addStates(t, fsm, reserved, charged)
+	addEvents(t, fsm, "reserve", "charge")
+	addTransitions(t, fsm,
+		T{From: created, Event: "reserve", To: reserved},
+		T{From: reserved, Event: "charge", To: charged})
+	return fsm
+}
+
+// newPaymentFSM creates a machine authorizing and capturing a payment, which is used as a sub-machine.
+func newPaymentFSM(t *testing.T) *FSM {
+	var (
+		pending  = StringState("pending")
+		authed   = StringState("authorized")
+		captured = StringState("captured")
+	)
+	fsm := NewFSM(pending, nil)
+	addStates(t, fsm, authed, captured)
+	addEvents(t, fsm, "authorize", "capture")
+	addTransitions(t, fsm, T{From: pending, Event: "authorize", To: authed})
+	assert.Nil(t, fsm.AddTransition(authed, "capture", captured, func(interface{}, Event) error {
+		return nil
+	}, nil))
+	return fsm
+}
+
+// newOrderFSM creates a machine of an order, which is checked by a completion transition after it is paid.
+func newOrderFSM(t *testing.T) *FSM {
+	var (
+		created   = StringState("created")
+		checking  = StringState("checking")
+		paid      = StringState("paid")
+		shipped   = StringState("shipped")
+		delivered = StringState("delivered")
+		canceled  = StringState("canceled")
+	)
+	fsm := NewFSM(created, nil)
+	addStates(t, fsm, checking, paid, shipped, delivered, canceled)
+	addEvents(t, fsm, "pay", "ship", "deliver", "cancel")
+	addTransitions(t, fsm, T{From: created, Event: "pay", To: checking})
+	assert.Nil(t, fsm.AddCompletionTransition(checking, paid, nil, nil))
+	addTransitions(t, fsm,
+		T{From: created, Event: "cancel", To: canceled},
+		T{From: paid, Event: "ship", To: shipped},
+		T{From: paid, Event: "cancel", To: canceled},
+		T{From: shipped, Event: "deliver", To: delivered})
+	return fsm
+}
+
+// newPriorityFSM creates a machine whose transitions of the same event have different priorities.
+func newPriorityFSM(t *testing.T) *FSM {
+	fsm := NewFSM(StringState("idle"), nil)
+	addStates(t, fsm, StringState("low"), StringState("mid"), StringState("high"))
+	addEvents(t, fsm, "go")
+	assert.Nil(t, fsm.AddTransitionWithOptions(StringState("idle"), "go", StringState("low"), nil, nil,
+		TransitionOptions{Priority: -1}))
+	addTransitions(t, fsm, T{From: StringState("idle"), Event: "go", To: StringState("mid")})
+	assert.Nil(t, fsm.AddTransitionWithOptions(StringState("idle"), "go", StringState("high"), nil, nil,
+		TransitionOptions{Priority: 1}))
+	return fsm
+}
+
+// newShipmentFSM creates a machine of a shipment, whose composite state "shipping" has two children.
+func newShipmentFSM(t *testing.T) *FSM {
+	fsm := NewFSM(StringState("created"), nil)
+	addStates(t, fsm, StringState("shipping"))
+	addChildStates(t, fsm, StringState("shipping"), StringState("in_transit"), StringState("customs"))
+	addStates(t, fsm, StringState("delivered"))
+	addEvents(t, fsm, "ship", "inspect", "track", "deliver", "escalate")
+	addTransitions(t, fsm,
+		T{From: StringState("created"), Event: "ship", To: StringState("shipping")},
+		T{From: StringState("in_transit"), Event: "inspect", To: StringState("customs")},
+		T{From: StringState("shipping"), Event: "track", To: StringState("shipping")},
+		T{From: StringState("shipping"), Event: "deliver", To: StringState("delivered")})
+	return fsm
+}
+
+// newPlayerFSM creates a machine of a media player, whose composite states are nested, and which is resumed by
+// the history states.
+func newPlayerFSM(t *testing.T) *FSM {
+	var (
+		stopped = StringState("stopped")
+		active  = StringState("active")
+		playing = StringState("playing")
+		paused  = StringState("paused")
+		video   = StringState("video")
+		audio   = StringState("audio")
+	)
+	fsm := NewFSM(stopped, nil)
+	addStates(t, fsm, active)
+	addChildStates(t, fsm, active, playing, paused)
+	addChildStates(t, fsm, playing, video, audio)
+	addEvents(t, fsm, "start", "stop", "pause", "resume", "switch", "resumeShallow", "resumeDeep")
+	shallow, err := fsm.ShallowHistory(active)
+	assert.Nil(t, err)
+	deep, err := fsm.DeepHistory(active)
+	assert.Nil(t, err)
+
+	addTransitions(t, fsm,
+		T{From: stopped, Event: "start", To: active},
+		T{From: stopped, Event: "resumeShallow", To: shallow},
+		T{From: stopped, Event: "resumeDeep", To: deep},
+		T{From: active, Event: "stop", To: stopped},
+		T{From: playing, Event: "pause", To: paused},
+		T{From: paused, Event: "resume", To: playing},
+		T{From: video, Event: "switch", To: audio})
+	return fsm
+}
+
+// newDeviceFSM creates a machine of a device, whose parallel state "on" has the regions of the connectivity and
+// the battery.
+func newDeviceFSM(t *testing.T) *FSM {
+	var (
+		off          = StringState("off")
+		on           = StringState("on")
+		connectivity = StringState("connectivity")
+		offline      = StringState("offline")
+		online       = StringState("online")
+		battery      = StringState("battery")
+		discharging  = StringState("discharging")
+		charging     = StringState("charging")
+		empty        = StringState("empty")
+	)
+	fsm := NewFSM(off, nil)
+	addStates(t, fsm, on)
+	addChildStates(t, fsm, on, connectivity)
+	addChildStates(t, fsm, connectivity, offline, online)
+	addChildStates(t, fsm, on, battery)
+	addChildStates(t, fsm, battery, discharging, charging, empty)
+	assert.Nil(t, fsm.SetParallel(on))
+	addEvents(t, fsm, "powerOn", "powerOff", "connect", "plug", "reset", "drain")
+	addTransitions(t, fsm,
+		T{From: off, Event: "powerOn", To: on},
+		T{From: off, Event: "plug", To: charging},
+		T{From: on, Event: "powerOff", To: off},
+		T{From: offline, Event: "connect", To: online},
+		T{From: discharging, Event: "plug", To: charging},
+		T{From: online, Event: "reset", To: offline},
+		T{From: charging, Event: "reset", To: discharging},
+		T{From: discharging, Event: "drain", To: off})
+	return fsm
+}
+
+// newBuildFSM creates a machine of a build, which forks into the regions of the frontend and the backend, and
+// joins them when both are done.
+func newBuildFSM(t *testing.T) *FSM {
+	var (
+		idle     = StringState("idle")
+		building = StringState("building")
+		frontend = StringState("frontend")
+		feBuild  = StringState("fe-build")
+		feLint   = StringState("fe-lint")
+		feDone   = StringState("fe-done")
+		backend  = StringState("backend")
+		beBuild  = StringState("be-build")
+		beDone   = StringState("be-done")
+		deployed = StringState("deployed")
+	)
+	fsm := NewFSM(idle, nil)
+	addStates(t, fsm, building, deployed)
+	addChildStates(t, fsm, building, frontend)
+	addChildStates(t, fsm, frontend, feBuild, feLint, feDone)
+	addChildStates(t, fsm, building, backend)
+	addChildStates(t, fsm, backend, beBuild, beDone)
+	assert.Nil(t, fsm.SetParallel(building))
+	addEvents(t, fsm, "start", "fe", "be")
+	assert.NotNil(t, fsm.AddFork(idle, "start", []State{feLint}, nil, nil))
+	assert.NotNil(t, fsm.AddFork(idle, "start", []State{feLint, feBuild}, nil, nil))
+	assert.NotNil(t, fsm.AddJoin([]State{feDone, deployed}, CompletionEventID, deployed, nil, nil))
+	assert.Nil(t, fsm.AddFork(idle, "start", []State{feLint, beBuild}, nil, nil))
+	addTransitions(t, fsm,
+		T{From: feBuild, Event: "fe", To: feLint},
+		T{From: feLint, Event: "fe", To: feDone},
+		T{From: beBuild, Event: "be", To: beDone})
+	assert.Nil(t, fsm.AddJoin([]State{feDone, beDone}, CompletionEventID, deployed, nil, nil))
+	return fsm
+}
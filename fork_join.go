@@ -0,0 +1,110 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// regionsOf checks that the states are inside different regions of the same parallel state, and returns the
+// parallel state.
+func (fsm *FSM) regionsOf(states []State) (string, error) {
+	if len(states) < 2 {
+		return "", errors.New("fork and join should have at least two states")
+	}
+	parallel := ""
+	regions := make(map[string]bool)
+	for _, state := range states {
+		if !fsm.HasState(state) {
+			return "", stateNotFound(state)
+		}
+		p, ok := fsm.parallelAncestor(state.FSMStateID())
+		if !ok || p == state.FSMStateID() || (parallel != "" && p != parallel) {
+			return "", errors.New(fmt.Sprintf("state %s is not inside a region of the parallel state",
+				state.FSMStateID()))
+		}
+		parallel = p
+		region, _ := fsm.regionOf(p, state.FSMStateID())
+		if regions[region] {
+			return "", errors.New(fmt.Sprintf("more than one state inside region %s", region))
+		}
+		regions[region] = true
+	}
+	return parallel, nil
+}
+
+// stateIDs returns the ids of states, or nil if states is empty.
+func stateIDs(states []State) []string {
+	if len(states) == 0 {
+		return nil
+	}
+	result := make([]string, 0, len(states))
+	for _, state := range states {
+		result = append(result, state.FSMStateID())
+	}
+	return result
+}
+
+// AddFork appends a fork transition, which enters the `targets` at once. The targets should be inside different
+// regions of the same parallel state, the regions without a target start from their initial child.
+// The fork is a transition to the parallel state, so the observers see the parallel state as the `ToState`.
+func (fsm *FSM) AddFork(from State, evId string, targets []State,
+	action func(interface{}, Event) error, guard func(interface{}, Event) bool) error {
+	parallel, err := fsm.regionsOf(targets)
+	if err != nil {
+		return err
+	}
+	if err := fsm.AddTransition(from, evId, fsm.states[parallel], action, guard); err != nil {
+		return err
+	}
+	fsm.lastAdded(from.FSMStateID(), evId).fork = stateIDs(targets)
+	return nil
+}
+
+// AddJoin appends a join transition, which leaves the parallel state containing `sources` only when all
+// `sources` are active and the guard returns true. The sources should be inside different regions of the same
+// parallel state. The join is a transition from the parallel state, and evaluated in the order of adding with
+// the other transitions of the parallel state.
+//   - If evId is `CompletionEventID`, the join fires as soon as all sources are active.
+//   - Otherwise it fires by the event, if no region handles the event. See `SetParallel`.
+func (fsm *FSM) AddJoin(sources []State, evId string, to State,
+	action func(interface{}, Event) error, guard func(interface{}, Event) bool) error {
+	parallel, err := fsm.regionsOf(sources)
+	if err != nil {
+		return err
+	}
+	if err := fsm.AddTransition(fsm.states[parallel], evId, to, action, guard); err != nil {
+		return err
+	}
+	fsm.lastAdded(parallel, evId).join = stateIDs(sources)
+	return nil
+}
+
+// joinReady returns true if all sources of the join transition are active.
+func (fsm *FSM) joinReady(t *transition) bool {
+	leaves := fsm.currentLeaves()
+	for _, source := range t.join {
+		active := false
+		for _, leaf := range leaves {
+			if fsm.isDescendant(leaf, source) {
+				active = true
+				break
+			}
+		}
+		if !active {
+			return false
+		}
+	}
+	return true
+}
+
+// fork enters the targets of the fork transition after the FSM enters the parallel state.
+func (fsm *FSM) fork(targets []string) {
+	fsm.curStateMu.Lock()
+	defer fsm.curStateMu.Unlock()
+	for _, target := range targets {
+		leaf := fsm.resolveState(target)
+		region, _ := fsm.regionOf(fsm.curState, leaf)
+		fsm.regionStates[region] = leaf
+		fsm.recordHistory(leaf)
+	}
+}
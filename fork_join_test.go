@@ -0,0 +1,43 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestForkJoin(t *testing.T) {
+	fsm := newBuildFSM(t)
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("start")))
+	assert.Equal(t, []State{StringState("fe-lint"), StringState("be-build")}, fsm.CurrentStates())
+
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("be")))
+	assert.Equal(t, []State{StringState("fe-lint"), StringState("be-done")}, fsm.CurrentStates())
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("fe")))
+	assert.Equal(t, StringState("deployed"), fsm.CurrentState())
+}
+
+func TestForkJoinDefinition(t *testing.T) {
+	fsm := newBuildFSM(t)
+	var fork, join TransitionInfo
+	for _, info := range fsm.Transitions() {
+		if info.Fork != nil {
+			fork = info
+		}
+		if info.Join != nil {
+			join = info
+		}
+	}
+	assert.Equal(t, StringState("building"), fork.To)
+	assert.Equal(t, []State{StringState("fe-lint"), StringState("be-build")}, fork.Fork)
+	assert.Equal(t, StringState("building"), join.From)
+	assert.Equal(t, []State{StringState("fe-done"), StringState("be-done")}, join.Join)
+
+	def := fsm.Definition()
+	reloaded, err := NewFSMFromDefinition(def, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, def, reloaded.Definition())
+	assert.Nil(t, reloaded.ProcessEvent(StringEvent("start")))
+	assert.Nil(t, reloaded.ProcessEvent(StringEvent("fe")))
+	assert.Nil(t, reloaded.ProcessEvent(StringEvent("be")))
+	assert.Equal(t, StringState("deployed"), reloaded.CurrentState())
+}
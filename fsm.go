@@ -1,10 +1,15 @@
 package fsm
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/emicklei/dot"
 	"github.com/reyoung/delegate"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var (
@@ -13,7 +18,11 @@ var (
 
 const (
 	ShouldNotReEnterPanic = "the process event should not re-enter. " +
-		"i.e., ProcessEvent should not be invoked in action/guard"
+		"i.e., ProcessEvent should not be invoked in action/guard, use PostInternal instead"
+	PostInternalOutsideProcessingPanic = "PostInternal should be invoked in action/guard"
+	SetResultOutsideProcessingPanic    = "SetResult should be invoked in action/guard"
+	NotOwnerPanic                      = "the FSM is not thread-safe, it should be used by one goroutine. " +
+		"i.e., use QueuedFSM or PreemptiveFSM for concurrent events"
 )
 
 func noTrasitionFromStateAndEvent(fromState string, event Event) error {
@@ -35,6 +44,15 @@ type State interface {
 	FSMStateID() string
 }
 
+// StringState is a `State` identified by the string itself, e.g., the states of machines loaded from a
+// `Definition`. It is comparable, so it can be declared as a constant:
+//
+//	const (
+//		Off = fsm.StringState("off")
+//		On  = fsm.StringState("on")
+//	)
+//
+// See `Enum` for declaring and adding a set of them at once, and cmd/fsmgen for generating them from a definition.
 type StringState string
 
 func (s StringState) FSMStateID() string {
@@ -49,6 +67,7 @@ type Event interface {
 	FSMEventID() string
 }
 
+// StringEvent is an `Event` without data, identified by the string itself. See `StringState`.
 type StringEvent string
 
 func (s StringEvent) FSMEventID() string {
@@ -63,6 +82,78 @@ type transition struct {
 	to     State
 	guard  func(interface{}, Event) bool
 	action func(interface{}, Event) error
+	meta   TransitionMetadata
+
+	hasGuard   bool
+	hasAction  bool
+	guardName  string
+	actionName string
+	// choice is true if the transition is a branch of a choice. See `AddChoice`.
+	choice bool
+	// fork and join are the target and source states of fork and join transitions. See `AddFork` and `AddJoin`.
+	fork []string
+	join []string
+	// timeout is the `ActionTimeout` of the action.
+	timeout    time.Duration
+	compensate func(interface{}, Event) error
+	// commit and abort are the `TransitionOptions.Commit` and `TransitionOptions.Abort`.
+	commit func(interface{}, Event)
+	abort  func(interface{}, Event)
+	// priority is the `TransitionOptions.Priority`, seq is the order the transition is added. See `SetPriorityOrder`.
+	priority int
+	seq      uint64
+	// permissions are the `TransitionOptions.Permissions`.
+	permissions []string
+}
+
+// TransitionMetadata describes a transition for human readers. It does not change the FSM behaviour,
+// but it is rendered in `DumpGraphviz`/`DumpPlantUML` and can be retrieved by `TransitionMetadata`.
+type TransitionMetadata struct {
+	Name        string
+	Description string
+	Tags        map[string]string
+}
+
+func (m TransitionMetadata) clone() TransitionMetadata {
+	if m.Tags != nil {
+		tags := make(map[string]string, len(m.Tags))
+		for k, v := range m.Tags {
+			tags[k] = v
+		}
+		m.Tags = tags
+	}
+	return m
+}
+
+// TransitionOptions are the optional arguments of `AddTransitionWithOptions`.
+type TransitionOptions struct {
+	Metadata TransitionMetadata
+	// ActionName and GuardName are the names of action and guard in a `HandlerRegistry`. They are used
+	// when the FSM is exported as a `Definition`.
+	ActionName string
+	GuardName  string
+	// Retry retries the action when it returns an error. The action is not retried if it is nil.
+	Retry *RetryPolicy
+	// ActionTimeout limits the duration of the action, including the retries. The context of the action is
+	// canceled when it exceeds, and `ProcessEvent` returns `ErrActionTimeout` after the action returns, and the
+	// state is not changed. The action should return as soon as the context is done, see `ActionContext`.
+	// No limit if it is 0.
+	ActionTimeout time.Duration
+	// Compensate undoes the action when the transition is reverted by `Rollback` or `CompensateTo`. The event
+	// is the one which fired the transition.
+	Compensate func(payload interface{}, ev Event) error
+	// Commit is the second phase of the action, see `AddTwoPhaseTransition`. It is invoked after the state is
+	// changed, with the payload and the event.
+	Commit func(payload interface{}, ev Event)
+	// Abort releases the resources prepared by the action when the state change is discarded by a failed
+	// `Transaction`, instead of Commit.
+	Abort func(payload interface{}, ev Event)
+	// Priority orders the guard evaluation of the transitions sharing the same from state and event, the higher
+	// ones are evaluated first. It is ignored unless the FSM is configured by `SetPriorityOrder`.
+	Priority int
+	// Permissions are required by the transition, the actor of the event should hold them to fire it. See
+	// `SetAuthorizer`.
+	Permissions []string
 }
 
 type ActionHookArgs struct {
@@ -74,10 +165,45 @@ type ActionHookArgs struct {
 
 // FSM is a finite state machine.
 // NOTE: It is not thread-safe. It is caller's duty to add mutex/shared mutex when calling FSM concurrently.
+//       The only exceptions are `CurrentState` and `StateRef`, which can be invoked concurrently with `ProcessEvent`,
+//       but `CurrentState` is restricted to the owner goroutine if `SetOwnershipCheck` is enabled.
 type FSM struct {
-	curState string
-	states   map[string]State
-	events   map[string]int
+	name string
+	// definitionVersion is the version of the topology, see `SetDefinitionVersion`.
+	definitionVersion string
+	initState         string
+	// curState is only written by the event processing, curStateMu guards the concurrent readers, e.g., `Version`.
+	// See `SetOwnershipCheck` for `CurrentState`.
+	curState   string
+	curStateMu sync.RWMutex
+	// stateRef is the lock-free projection of curState. See `StateRef`.
+	stateRef AtomicStateRef
+	// version is increased by each transition, guarded by curStateMu.
+	version uint64
+	states  map[string]State
+	// events maps the event ids to their indexes. See `EventIndex`.
+	events map[string]int
+	// the interned states and events, and the transitions indexed by them. See `StateIndex`.
+	stateIndex map[string]int
+	stateIDs   []string
+	eventIDs   []string
+	table      [][][]*transition
+	// curIndex is the index of curState, guarded by curStateMu.
+	curIndex int
+	// compiled is the frozen table of the transitions. See `Compile`.
+	compiled *compiledTable
+	// locked is true after the FSM processes events, the definition cannot be changed. See `Unlock`.
+	locked atomic.Bool
+	// ownerCheck enables the check of the goroutine owning the FSM, owner is the id of the goroutine, 0 if it is
+	// not claimed yet. See `SetOwnershipCheck`.
+	ownerCheck bool
+	owner      atomic.Int64
+	// noGlobalHooks skips GlobalBeforeAction and GlobalAfterAction. See `SetGlobalActionHooks`.
+	noGlobalHooks bool
+	// priorityOrder sorts the transitions by their priorities, transitionSeq counts the added transitions.
+	// See `SetPriorityOrder`.
+	priorityOrder bool
+	transitionSeq uint64
 
 	// state -> event -> transitions
 	transitions               map[string]map[string][]*transition
@@ -85,22 +211,109 @@ type FSM struct {
 	processEventInvokeCounter int
 	GlobalBeforeAction        delegate.Delegate
 	GlobalAfterAction         delegate.Delegate
+	observers                 []Observer
+	actionMiddlewares         []ActionMiddleware
+	guardMiddlewares          []GuardMiddleware
+	subs                      subscriptions
+	internalEvents            []Event
+	// result is where `SetResult` stores the result of the processing event, nil if it is not wanted.
+	result *interface{}
+	// replaying is true during `Replay`.
+	replaying bool
+
+	// child -> parent, parent -> children, parent -> initial child. See `AddChildState`.
+	parents         map[string]string
+	children        map[string][]string
+	initialChildren map[string]string
+	histories       map[string]HistoryState
+	parallel        map[string]bool
+	// the active states of the regions of the current parallel state, guarded by curStateMu.
+	regionStates map[string]string
+	subMachines  map[string]*subMachine
+	// the last active child and leaf of composite states, used by history states.
+	activeChildren map[string]string
+	activeLeaves   map[string]string
+	// the rejected candidates of the processing event, and the last rejection. See `ExplainLastRejection`.
+	candidates    []RejectedTransition
+	lastRejection *Rejection
+	// deadLetters receives the rejected events. See `SetDeadLetterSink`.
+	deadLetters      func(letter DeadLetter)
+	unknownEventMode UnknownEventMode
+	stats            statsCollector
+	// services are carried by the context of actions. See `SetServices`.
+	services Services
+	// guardMemo is the memoized guard results, nil if disabled. See `SetGuardMemoization`.
+	guardMemo   map[*transition]bool
+	guardMemoMu sync.Mutex
+	// the guards are evaluated concurrently, except the strict pairs. See `SetParallelGuards`.
+	parallelGuards bool
+	strictGuards   map[stateEvent]bool
+	// wildcards are the transitions of the event namespaces by their from states and namespaces, e.g., "payment"
+	// for "payment.*". See `AddTransition`.
+	wildcards map[string]map[string][]*transition
+	// rateLimits are the token buckets of the transitions. See `SetRateLimit`.
+	rateLimits map[stateEvent]*tokenBucket
+	// alarms are checked when the current states change. See `AlarmIfStuck`.
+	alarms []*stuckAlarm
+	// authorizer authorizes the transitions requiring permissions. See `SetAuthorizer`.
+	authorizer Authorizer
+	// matching are the transitions fired by the matched events. See `AddTransitionMatching`.
+	matching map[string][]matchingTransition
+	// validators are the validators of the event ids. See `SetEventValidator`.
+	validators map[string]func(ev Event) error
+	// invariants are checked after the actions. See `AddInvariant`.
+	invariants []func(payload interface{}) error
+	// the context of the running action. See `ActionContext`.
+	actionCtx   context.Context
+	actionCtxMu sync.Mutex
+	// tx is the running transaction. See `Transaction`.
+	tx *Tx
+	// committed is the runtime state seen by the readers during the transaction, guarded by curStateMu.
+	committed *committedView
+	// the kept transitions for `Rollback`, the last one is the latest.
+	undo           []undoEntry
+	rollbackLimit  int
+	rollbackAction func(payload interface{}, change StateChange) error
+	// the recorded frames for `Debugger`, guarded by debugMu.
+	debugFrames  []DebugFrame
+	debugLimit   int
+	debugMarshal func(payload interface{}) ([]byte, error)
+	debugMu      sync.Mutex
+	clock        Clock
+	// handlers resolves the actions and guards of `SwapDefinition`.
+	handlers *HandlerRegistry
 }
 
+// DumpGraphviz dumps the FSM as a Graphviz digraph. States and transitions are sorted, so the result is stable.
 func (fsm *FSM) DumpGraphviz() string {
 	graph := dot.NewGraph(dot.Directed)
-	for state := range fsm.states {
+	for _, state := range fsm.sortedStateIDs() {
 		node := graph.Node(state)
 		node.Attr("shape", "box")
 	}
 
-	for fromNodeID, evTrans := range fsm.transitions {
+	choiceNodes := make(map[string]dot.Node)
+	for _, info := range fsm.Transitions() {
+		fromNodeID := info.From.FSMStateID()
 		fromNode := graph.Node(fromNodeID)
-		for evID, trans := range evTrans {
-			for _, tran := range trans {
-				toNode := graph.Node(tran.to.FSMStateID())
-				graph.Edge(fromNode, toNode, evID)
+		toNode := graph.Node(info.To.FSMStateID())
+		var edge dot.Edge
+		if info.Choice {
+			choiceID := choiceNodeID(fromNodeID, info.Event)
+			choiceNode, ok := choiceNodes[choiceID]
+			if !ok {
+				choiceNode = graph.Node(choiceID)
+				choiceNode.Attr("shape", "diamond")
+				choiceNode.Attr("label", "")
+				graph.Edge(fromNode, choiceNode, info.Event)
+				choiceNodes[choiceID] = choiceNode
 			}
+			edge = graph.Edge(choiceNode, toNode, choiceBranchLabel(info.Metadata, info.HasGuard, info.GuardName))
+		} else {
+			edge = graph.Edge(fromNode, toNode, transitionLabel(info.Event, info.Metadata))
+		}
+		if tooltip := transitionTooltip(info.Metadata); tooltip != "" {
+			edge.Attr("tooltip", tooltip)
 		}
 	}
 	return graph.String()
@@ -108,17 +321,36 @@ func (fsm *FSM) DumpGraphviz() string {
 
 // NewFSM will create a new fsm with initialize state. The nullable `payload` will pass to each
 // `action`/`guard` methods.
+// The initState can be nil, then the FSM should be started by `Start` after its states are added, and
+// `ProcessEvent` returns `ErrNotStarted` before.
 func NewFSM(initState State, payload interface{}) *FSM {
-	return &FSM{
-		curState: initState.FSMStateID(),
-		states: map[string]State{
-			initState.FSMStateID(): initState,
-		},
+	fsm := &FSM{
+		states:                    make(map[string]State),
 		events:                    make(map[string]int),
+		stateIndex:                make(map[string]int),
 		transitions:               make(map[string]map[string][]*transition),
 		payload:                   payload,
 		processEventInvokeCounter: 0,
+		parents:                   make(map[string]string),
+		children:                  make(map[string][]string),
+		initialChildren:           make(map[string]string),
+		histories:                 make(map[string]HistoryState),
+		parallel:                  make(map[string]bool),
+		regionStates:              make(map[string]string),
+		subMachines:               make(map[string]*subMachine),
+		activeChildren:            make(map[string]string),
+		activeLeaves:              make(map[string]string),
+		clock:                     SystemClock,
+		ownerCheck:                ownershipCheckByDefault,
+	}
+	if initState != nil {
+		fsm.initState = initState.FSMStateID()
+		fsm.states[fsm.initState] = initState
+		fsm.internState(fsm.initState)
+		fsm.setCurState(fsm.initState)
+		fsm.stats.recordEntry(fsm.initState, fsm.clock.Now())
 	}
+	return fsm
 }
 
 // default action just do nothing
@@ -129,6 +361,12 @@ func defaultGuard(interface{}, Event) bool { return true }
 
 // AddTransition will append a transition to fsm.
 // * The states and event should be added before.
+// * The event ids can be dotted namespaces, e.g., "payment.captured". If evId is the wildcard of a namespace, e.g.,
+//   "payment.*", which need not be added, the transition is fired by all events in the namespace, including the
+//   nested ones, e.g., "payment.card.declined". For each state, the transitions of the exact event id are
+//   evaluated first, then the wildcards from the innermost namespace to the outermost one, e.g., "payment.card.*"
+//   before "payment.*", then the transitions added by `AddTransitionMatching`. The transitions of child states
+//   still take priority over their parents.
 // * The `guard` will invoke when the current state is from, and the event is triggered. The action will
 //   be invoked if the `guard` returns true, otherwise, the next transition guard for the same state/event will
 //   be invoked.
@@ -136,102 +374,312 @@ func defaultGuard(interface{}, Event) bool { return true }
 // * If the action returns an error, the state will be not changed and the process event will returns that error.
 func (fsm *FSM) AddTransition(from State, evId string, to State,
 	action func(interface{}, Event) error, guard func(interface{}, Event) bool) error {
-	{ // input arg checks
-		if action == nil {
-			action = defaultAction
-		}
-		if guard == nil {
-			guard = defaultGuard
-		}
-		if !fsm.HasState(from) {
-			return stateNotFound(from)
-		}
-		if !fsm.HasEvent(evId) {
-			return eventNotFound(evId)
-		}
-		if !fsm.HasState(to) {
-			return stateNotFound(to)
-		}
+	return fsm.AddTransitionWithOptions(from, evId, to, action, guard, TransitionOptions{})
+}
+
+// AddTransitionWithOptions is the same as `AddTransition`, but the transition is configured by `opts`.
+// If evId is `CompletionEventID`, a completion transition is added. See `AddCompletionTransition`.
+func (fsm *FSM) AddTransitionWithOptions(from State, evId string, to State,
+	action func(interface{}, Event) error, guard func(interface{}, Event) bool, opts TransitionOptions) error {
+	if fsm.hasChoice(from, evId) {
+		return unreachableAfterChoice(from, evId)
 	}
+	return fsm.addTransition(from, evId, to, action, guard, opts, false)
+}
+
+func (fsm *FSM) addTransition(from State, evId string, to State, action func(interface{}, Event) error,
+	guard func(interface{}, Event) bool, opts TransitionOptions, choice bool) error {
+	if err := fsm.checkTransition(from, evId, to); err != nil {
+		return err
+	}
+	fsm.transitionSeq++
+	t := fsm.newTransition(to, action, guard, opts, choice)
+	t.seq = fsm.transitionSeq
 	fromID := from.FSMStateID()
-	{
-		_, ok := fsm.transitions[fromID]
-		if !ok {
-			fsm.transitions[fromID] = make(map[string][]*transition)
-		}
+	fsm.setTransitions(fromID, evId, fsm.sortTransitions(append(fsm.transitions[fromID][evId], t)))
+	return nil
+}
+
+// checkTransition checks the FSM can be changed, and the states and the event of a transition are added.
+func (fsm *FSM) checkTransition(from State, evId string, to State) error {
+	if err := fsm.checkMutable(); err != nil {
+		return err
 	}
-	{
-		_, ok := fsm.transitions[fromID][evId]
-		if !ok {
-			fsm.transitions[fromID][evId] = make([]*transition, 0)
-		}
+	if !fsm.HasState(from) {
+		return stateNotFound(from)
+	}
+	if evId != CompletionEventID && !isWildcardEvent(evId) && !fsm.HasEvent(evId) {
+		return eventNotFound(evId)
+	}
+	if !fsm.HasState(to) {
+		return stateNotFound(to)
 	}
-
-	fsm.transitions[fromID][evId] = append(fsm.transitions[fromID][evId],
-		&transition{
-			to:     to,
-			guard:  guard,
-			action: action,
-		})
 	return nil
 }
 
+func (fsm *FSM) newTransition(to State, action func(interface{}, Event) error, guard func(interface{}, Event) bool,
+	opts TransitionOptions, choice bool) *transition {
+	hasAction, hasGuard := action != nil, guard != nil
+	if action == nil {
+		action = defaultAction
+	}
+	if guard == nil {
+		guard = defaultGuard
+	}
+	if opts.Retry != nil {
+		action = opts.Retry.withRetry(fsm, action)
+	}
+	return &transition{
+		to:     to,
+		guard:  guard,
+		action: action,
+		meta:   opts.Metadata.clone(),
+
+		hasGuard:   hasGuard,
+		hasAction:  hasAction,
+		guardName:  opts.GuardName,
+		actionName: opts.ActionName,
+		choice:     choice,
+		timeout:    opts.ActionTimeout,
+		compensate: opts.Compensate,
+		commit:     opts.Commit,
+		abort:      opts.Abort,
+		priority:   opts.Priority,
+
+		permissions: append([]string(nil), opts.Permissions...),
+	}
+}
+
 // ProcessEvent will invoke the binding transition and change the current state.
 // See `AddTransition` for more information.
-// It may return NoTransition when there is no binding transition for this event.
+// It may return NoTransition when there is no binding transition for this event. See `ExplainLastRejection`
+// for the guards which rejected it, and `SetUnknownEventMode` for the events which are not added.
 func (fsm *FSM) ProcessEvent(ev Event) error {
+	return fsm.ProcessEventContext(context.Background(), ev)
+}
+
+// ProcessEventContext is the same as `ProcessEvent`, the ctx is passed to observers. See `Observer`.
+func (fsm *FSM) ProcessEventContext(ctx context.Context, ev Event) error {
+	fsm.checkOwner()
+	return fsm.processEventWithResult(ctx, ev, nil)
+}
+
+// processEventWithResult processes the event, the result set by `SetResult` is stored to result if it is not nil.
+func (fsm *FSM) processEventWithResult(ctx context.Context, ev Event, result *interface{}) (err error) {
 	fsm.processEventInvokeCounter += 1
 	defer func() {
 		fsm.processEventInvokeCounter -= 1
+		fsm.internalEvents = nil
 	}()
 	if fsm.processEventInvokeCounter != 1 {
 		panic(ShouldNotReEnterPanic)
 	}
+	if fsm.curState != "" {
+		fsm.lock()
+	}
+	if result != nil {
+		fsm.result = result
+		defer func() {
+			fsm.result = nil
+			if err != nil {
+				*result = nil
+			}
+		}()
+	}
 
-	trans, ok := fsm.transitions[fsm.curState]
-	if !ok {
-		return noTrasitionFromStateAndEvent(fsm.curState, ev)
+	if err := fsm.observedProcessEvent(ctx, ev); err != nil {
+		return err
 	}
-	transList, ok := trans[ev.FSMEventID()]
-	if !ok {
-		return noTrasitionFromStateAndEvent(fsm.curState, ev)
+	return fsm.processInternalEvents(ctx)
+}
+
+func (fsm *FSM) observedProcessEvent(ctx context.Context, ev Event) (err error) {
+	begin := fsm.clock.Now()
+	for _, o := range fsm.observers {
+		o.EventStarted(ctx, fsm, ev)
 	}
-	for _, t := range transList {
-		if !t.guard(fsm.payload, ev) {
-			continue
+	defer func() {
+		for _, o := range fsm.observers {
+			o.EventFinished(ctx, fsm, ev, err)
+		}
+		fsm.stats.recordEvent(ev.FSMEventID(), fsm.clock.Now().Sub(begin), err)
+	}()
+	return fsm.processEvent(ctx, ev)
+}
+
+func (fsm *FSM) processEvent(ctx context.Context, ev Event) error {
+	if fsm.curState == "" {
+		return ErrNotStarted
+	}
+	if err := fsm.validate(ev); err != nil {
+		return err
+	}
+	if ev.FSMEventID() == CompletionEventID {
+		// completion transitions can only be fired by entering states.
+		return fsm.noTransition(ev)
+	}
+	fsm.candidates = nil
+	if sub, ok := fsm.subMachines[fsm.curState]; ok && sub.machine.CanFire(ev) {
+		var err error
+		if fsm.replaying {
+			err = sub.machine.Replay([]Event{ev})
+		} else {
+			err = sub.machine.ProcessEventContext(ctx, ev)
+		}
+		if err != nil {
+			return err
+		}
+		if sub.machine.InFinalState() {
+			fsm.internalEvents = append(fsm.internalEvents, &SubMachineDoneEvent{ID: sub.doneEvent, Machine: sub.machine})
+		}
+		return nil
+	}
+	if fsm.parallel[fsm.curState] {
+		fired, err := fsm.dispatchRegions(ctx, ev)
+		if err != nil {
+			return err
+		}
+		if fired {
+			return fsm.complete(ctx, ev)
 		}
+	}
+	// the transitions of child states take priority over their parents.
+	for from, ok := fsm.curState, true; ok; from, ok = fsm.parents[from] {
+		fired, err := fsm.fire(ctx, from, ev, fsm.transitionsOf(from, ev))
+		if err != nil {
+			return err
+		}
+		if fired {
+			return fsm.complete(ctx, ev)
+		}
+	}
+	return fsm.rejectEvent(ev)
+}
 
+// fire invokes the first transition from state `from` in transList whose guard returns true, and changes the
+// current state. It returns false if all guards return false.
+func (fsm *FSM) fire(ctx context.Context, from string, ev Event, transList []*transition) (bool, error) {
+	if len(transList) != 0 && !fsm.replaying {
+		var err error
+		if transList, err = fsm.authorize(ctx, from, ev, transList); err != nil {
+			return false, err
+		}
+	}
+	if len(fsm.rateLimits) != 0 && len(transList) != 0 && !fsm.replaying {
+		if err := fsm.checkRateLimit(from, ev, transList); err != nil {
+			return false, err
+		}
+	}
+	passed := fsm.checkGuardsParallel(from, ev, transList)
+	for i, t := range transList {
+		if t.join != nil && !fsm.joinReady(t) {
+			fsm.reject(from, t, JoinNotReady)
+			continue
+		}
 		args := ActionHookArgs{
-			FromState: fsm.states[fsm.curState],
+			FromState: fsm.states[from],
 			ToState:   t.to,
 			Event:     ev,
 			Payload:   fsm.payload,
 		}
-		fsm.GlobalBeforeAction.Apply(args)
-		err := t.action(fsm.payload, ev)
+		var ok bool
+		if passed != nil {
+			ok = passed[i]
+		} else {
+			ok = fsm.checkGuard(from, t, ev)
+		}
+		if !ok {
+			fsm.reject(from, t, GuardReturnedFalse)
+			if !fsm.replaying {
+				for _, o := range fsm.observers {
+					o.GuardRejected(ctx, fsm, args)
+				}
+			}
+			continue
+		}
+		if fsm.replaying {
+			fsm.take(from, t)
+			return true, nil
+		}
+
+		// the args are boxed only if a hook is added, see `SetGlobalActionHooks`.
+		var hookArgs interface{}
+		if !fsm.noGlobalHooks && hasHooks(&fsm.GlobalBeforeAction) {
+			hookArgs = args
+			fsm.GlobalBeforeAction.Apply(hookArgs)
+		}
+		begin := fsm.clock.Now()
+		err := fsm.runAction(ctx, t, args)
+		elapsed := fsm.clock.Now().Sub(begin)
+		if err == nil && len(fsm.invariants) != 0 {
+			err = fsm.checkInvariants(args)
+		}
+		fsm.stats.recordTransition(from, ev.FSMEventID(), t.to.FSMStateID(), elapsed, err)
+		for _, o := range fsm.observers {
+			o.ActionFinished(ctx, fsm, args, elapsed, err)
+		}
 		if err != nil {
-			return err
+			return true, err
 		}
-		fsm.curState = t.to.FSMStateID()
-		fsm.GlobalAfterAction.Apply(args)
-		return nil
+		var before *machineState
+		if fsm.rollbackLimit > 0 {
+			before = fsm.snapshotState()
+		}
+		prev, next := fsm.take(from, t)
+		change := StateChange{From: fsm.states[prev], To: fsm.states[next], Event: ev, Time: fsm.clock.Now()}
+		if before != nil {
+			fsm.keepUndo(before, change, t)
+		}
+		fsm.recordFrame(prev, ev)
+		fsm.commit(t, args)
+		fsm.publish(change)
+		if !fsm.noGlobalHooks && hasHooks(&fsm.GlobalAfterAction) {
+			if hookArgs == nil {
+				hookArgs = args
+			}
+			fsm.GlobalAfterAction.Apply(hookArgs)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// take changes the current state by the transition t from state `from`. It returns the previous and the new state.
+func (fsm *FSM) take(from string, t *transition) (prev string, next string) {
+	prev, next = fsm.enter(from, t.to.FSMStateID())
+	if t.fork != nil {
+		fsm.fork(t.fork)
+	}
+	if sub, ok := fsm.subMachines[next]; ok {
+		sub.machine.reset()
 	}
-	return noTrasitionFromStateAndEvent(fsm.curState, ev)
+	return prev, next
 }
 
 func (fsm *FSM) AddState(state State) error {
+	if err := fsm.checkMutable(); err != nil {
+		return err
+	}
 	if fsm.HasState(state) {
 		return AlreadyExists
 	}
 	fsm.states[state.FSMStateID()] = state
+	fsm.internState(state.FSMStateID())
 	return nil
 }
 
 func (fsm *FSM) AddEvent(eventID string) error {
+	if eventID == CompletionEventID {
+		return errors.New("the event id should not be empty")
+	}
+	if err := fsm.checkMutable(); err != nil {
+		return err
+	}
 	if fsm.HasEvent(eventID) {
 		return AlreadyExists
 	}
-	fsm.events[eventID] = 0
+	fsm.events[eventID] = len(fsm.eventIDs)
+	fsm.eventIDs = append(fsm.eventIDs, eventID)
 	return nil
 }
 
@@ -245,6 +693,117 @@ func (fsm *FSM) HasState(state State) bool {
 	return ok
 }
 
+// CurrentState returns the current state, it is nil if the FSM is not started. See `Start`.
+// It panics with `NotOwnerPanic` if it is invoked by a goroutine not owning the FSM, see `SetOwnershipCheck`.
 func (fsm *FSM) CurrentState() State {
-	return fsm.states[fsm.curState]
+	fsm.checkOwner()
+	return fsm.currentState()
+}
+
+// currentState is the same as `CurrentState`, but it is not checked by `SetOwnershipCheck`.
+func (fsm *FSM) currentState() State {
+	return fsm.states[fsm.currentStateID()]
+}
+
+// currentStateID returns the id of `CurrentState`, it is empty if the FSM is not started.
+func (fsm *FSM) currentStateID() string {
+	fsm.curStateMu.RLock()
+	defer fsm.curStateMu.RUnlock()
+	if fsm.committed != nil {
+		return fsm.committed.curState
+	}
+	return fsm.curState
+}
+
+// Version returns the number of transitions taken since the FSM was created, including the completion
+// transitions and the transitions inside regions. It can be used to detect concurrent modifications, and can be
+// invoked concurrently with `ProcessEvent`, like `StateRef`.
+func (fsm *FSM) Version() uint64 {
+	fsm.curStateMu.RLock()
+	defer fsm.curStateMu.RUnlock()
+	if fsm.committed != nil {
+		return fsm.committed.version
+	}
+	return fsm.version
+}
+
+// SetName names the FSM. The name is used by `Registry` and observers.
+// NOTE: the name should not be changed after the FSM is registered.
+func (fsm *FSM) SetName(name string) {
+	fsm.name = name
+}
+
+func (fsm *FSM) Name() string {
+	return fsm.name
+}
+
+// SetDefinitionVersion sets the version of the topology of the FSM, e.g., "2" after a state is renamed. It is saved
+// with the snapshots by package persist, which migrates the snapshots of the older versions when they are restored.
+// The FSMs created by `NewFSMFromDefinition` take the `Definition.Version`.
+// NOTE: unlike `Version`, which counts the transitions, it is only changed by the caller.
+func (fsm *FSM) SetDefinitionVersion(version string) {
+	fsm.definitionVersion = version
+}
+
+func (fsm *FSM) DefinitionVersion() string {
+	return fsm.definitionVersion
+}
+
+// AvailableEvents returns the sorted event ids which have at least one transition from the current states,
+// or from the composite states containing them. The wildcards, e.g., "payment.*", are listed with the added
+// events of their namespaces, see `AddEvent`.
+// NOTE: guards are not evaluated. Use `CanFire` to check whether an event will be accepted. The events of
+// `AddTransitionMatching` are not listed, because they are not known until they are processed.
+func (fsm *FSM) AvailableEvents() []string {
+	result := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, leaf := range fsm.currentLeaves() {
+		for from, ok := leaf, true; ok; from, ok = fsm.parents[from] {
+			for evID, transList := range fsm.transitions[from] {
+				if evID != CompletionEventID && len(transList) != 0 && !seen[evID] {
+					seen[evID] = true
+					result = append(result, evID)
+				}
+			}
+			if len(fsm.wildcards[from]) == 0 {
+				continue
+			}
+			for _, evID := range fsm.eventIDs {
+				if !seen[evID] && len(fsm.appendWildcards(from, evID, nil)) != 0 {
+					seen[evID] = true
+					result = append(result, evID)
+				}
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// CanFire returns true if `ProcessEvent(ev)` would find a transition from the current states or the composite
+// states containing them, i.e., there is a transition for the event whose guard returns true.
+// NOTE: the action is not invoked, so `ProcessEvent` may still fail if the action returns an error.
+func (fsm *FSM) CanFire(ev Event) bool {
+	if ev.FSMEventID() == CompletionEventID {
+		return false
+	}
+	for _, leaf := range fsm.currentLeaves() {
+		for from, ok := leaf, true; ok; from, ok = fsm.parents[from] {
+			if fsm.firstAccepted(from, ev, fsm.transitionsOf(from, ev)) != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TransitionMetadata returns the metadata of transitions from state `from` triggered by `evId`, in the order
+// of guard evaluation.
+func (fsm *FSM) TransitionMetadata(from State, evId string) []TransitionMetadata {
+	transList := fsm.transitions[from.FSMStateID()][evId]
+	result := make([]TransitionMetadata, 0, len(transList))
+	for _, t := range transList {
+		result = append(result, t.meta.clone())
+	}
+	return result
 }
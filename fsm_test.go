@@ -46,3 +46,33 @@ func TestSimpleFSM(t *testing.T) {
 	assert.Equal(t, on, fsm.CurrentState())
 	assert.NotEmpty(t, fsm.DumpGraphviz())
 }
+
+func TestAvailableEventsAndCanFire(t *testing.T) {
+	fsm := fsmModule.NewFSM(off, nil)
+	assert.Nil(t, fsm.AddState(on))
+	assert.Nil(t, fsm.AddEvent(switchEventID))
+	assert.Nil(t, fsm.AddEvent("noop"))
+	enabled := false
+	assert.Nil(t, fsm.AddTransition(off, switchEventID, on, nil, func(i interface{}, event fsmModule.Event) bool {
+		return enabled
+	}))
+	assert.Equal(t, []string{switchEventID}, fsm.AvailableEvents())
+	assert.False(t, fsm.CanFire(&Switch{}))
+	assert.False(t, fsm.CanFire(fsmModule.StringEvent("noop")))
+	enabled = true
+	assert.True(t, fsm.CanFire(&Switch{}))
+	assert.Nil(t, fsm.ProcessEvent(&Switch{}))
+	assert.Empty(t, fsm.AvailableEvents())
+}
+
+func TestVersion(t *testing.T) {
+	fsm := fsmModule.NewFSM(off, nil)
+	assert.Nil(t, fsm.AddState(on))
+	assert.Nil(t, fsm.AddEvent(switchEventID))
+	assert.Nil(t, fsm.AddTransition(off, switchEventID, on, nil, nil))
+	assert.Equal(t, uint64(0), fsm.Version())
+	assert.Nil(t, fsm.ProcessEvent(&Switch{}))
+	assert.Equal(t, uint64(1), fsm.Version())
+	assert.NotNil(t, fsm.ProcessEvent(&Switch{}))
+	assert.Equal(t, uint64(1), fsm.Version())
+}
@@ -0,0 +1,168 @@
+package fsmtest
+
+import (
+	"github.com/reyoung/fsm"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FakeClock is a `fsm.Clock` controlled by the test. The time only moves by `Advance` and `Set`, so the timeouts,
+// the scheduled events and the stats are tested without sleeping:
+//
+//	clock := fsmtest.NewFakeClock(time.Unix(0, 0))
+//	machine.SetClock(clock)
+//	...
+//	clock.Advance(time.Minute)
+//
+// The functions of `AfterFunc` are invoked by `Advance` in the order of their times, in the goroutine of
+// `Advance`, so their effects are visible when `Advance` returns. The tickers drop the ticks if their channels
+// are full, like `time.Ticker`. It is thread-safe.
+type FakeClock struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	now     time.Time
+	timers  []*fakeTimer
+	seq     uint64
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	at    time.Time
+	// seq keeps the timers of the same time in the creation order.
+	seq uint64
+	f   func()
+	// period and ch are set for the tickers.
+	period time.Duration
+	ch     chan time.Time
+}
+
+// NewFakeClock creates a clock at the time now.
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.changed = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) fsm.Timer {
+	return c.add(&fakeTimer{clock: c, at: c.Now().Add(d), f: f})
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) fsm.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	return fakeTicker{c.add(&fakeTimer{clock: c, at: c.Now().Add(d), period: d, ch: make(chan time.Time, 1)})}
+}
+
+func (c *FakeClock) add(t *fakeTimer) *fakeTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	t.seq = c.seq
+	c.timers = append(c.timers, t)
+	c.changed.Broadcast()
+	return t
+}
+
+// Timers returns the number of the active timers and tickers.
+func (c *FakeClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// WaitTimers blocks until there are at least n active timers and tickers, e.g., until a goroutine under test
+// has armed its timer, so the following `Advance` fires it.
+func (c *FakeClock) WaitTimers(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.changed.Wait()
+	}
+}
+
+// Advance moves the time forward by d, and fires the timers and tickers due meanwhile.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set moves the time to now, and fires the timers and tickers due meanwhile. The time is never moved backward.
+func (c *FakeClock) Set(now time.Time) {
+	for {
+		c.mu.Lock()
+		t := c.nextDue(now)
+		if t == nil {
+			if now.After(c.now) {
+				c.now = now
+			}
+			c.mu.Unlock()
+			return
+		}
+		if t.at.After(c.now) {
+			c.now = t.at
+		}
+		if t.period > 0 {
+			t.at = t.at.Add(t.period)
+			select {
+			case t.ch <- c.now:
+			default:
+			}
+			c.mu.Unlock()
+			continue
+		}
+		c.remove(t)
+		c.mu.Unlock()
+		t.f()
+	}
+}
+
+// nextDue returns the earliest timer due at the time now, or nil.
+func (c *FakeClock) nextDue(now time.Time) *fakeTimer {
+	sort.Slice(c.timers, func(i, j int) bool {
+		if !c.timers[i].at.Equal(c.timers[j].at) {
+			return c.timers[i].at.Before(c.timers[j].at)
+		}
+		return c.timers[i].seq < c.timers[j].seq
+	})
+	if len(c.timers) == 0 || c.timers[0].at.After(now) {
+		return nil
+	}
+	return c.timers[0]
+}
+
+// remove removes the timer, it returns false if the timer is not active.
+func (c *FakeClock) remove(t *fakeTimer) bool {
+	for i, timer := range c.timers {
+		if timer == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			c.changed.Broadcast()
+			return true
+		}
+	}
+	return false
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.remove(t)
+}
+
+type fakeTicker struct {
+	*fakeTimer
+}
+
+func (t fakeTicker) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t fakeTicker) Stop() {
+	t.fakeTimer.Stop()
+}
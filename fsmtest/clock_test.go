@@ -0,0 +1,143 @@
+package fsmtest
+
+import (
+	"context"
+	"errors"
+	"github.com/reyoung/fsm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	clock := NewFakeClock(start)
+	var fired []string
+	clock.AfterFunc(2*time.Second, func() {
+		fired = append(fired, "b")
+	})
+	clock.AfterFunc(time.Second, func() {
+		fired = append(fired, "a")
+		assert.Equal(t, start.Add(time.Second), clock.Now())
+		// the timers armed by the fired ones are fired by the same Advance if they are due.
+		clock.AfterFunc(0, func() {
+			fired = append(fired, "a2")
+		})
+	})
+	stopped := clock.AfterFunc(time.Second, func() {
+		fired = append(fired, "stopped")
+	})
+	assert.True(t, stopped.Stop())
+	assert.False(t, stopped.Stop())
+	assert.Equal(t, 2, clock.Timers())
+
+	clock.Advance(1500 * time.Millisecond)
+	assert.Equal(t, []string{"a", "a2"}, fired)
+	assert.Equal(t, start.Add(1500*time.Millisecond), clock.Now())
+	clock.Set(start)
+	assert.Equal(t, start.Add(1500*time.Millisecond), clock.Now())
+	clock.Advance(time.Second)
+	assert.Equal(t, []string{"a", "a2", "b"}, fired)
+	assert.Equal(t, 0, clock.Timers())
+
+	ticker := clock.NewTicker(time.Second)
+	clock.Advance(3 * time.Second)
+	// the ticks are dropped if the channel is full.
+	assert.Equal(t, start.Add(3500*time.Millisecond), <-ticker.C())
+	assert.Len(t, ticker.C(), 0)
+	ticker.Stop()
+	clock.Advance(time.Hour)
+	assert.Len(t, ticker.C(), 0)
+}
+
+func TestFakeClockWaitTimers(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	done := make(chan error)
+	go func() {
+		done <- fsm.Sleep(context.Background(), clock, time.Minute)
+	}()
+	clock.WaitTimers(1)
+	clock.Advance(time.Minute)
+	assert.Nil(t, <-done)
+}
+
+func newClockedFSM(clock fsm.Clock, opts fsm.TransitionOptions,
+	action func(interface{}, fsm.Event) error) *fsm.FSM {
+	machine := fsm.NewFSM(fsm.StringState("idle"), nil)
+	machine.SetClock(clock)
+	_ = machine.AddState(fsm.StringState("done"))
+	_ = machine.AddEvent("run")
+	_ = machine.AddTransitionWithOptions(fsm.StringState("idle"), "run", fsm.StringState("done"), action, nil, opts)
+	return machine
+}
+
+func TestFakeClockActionTimeout(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	var machine *fsm.FSM
+	opts := fsm.TransitionOptions{ActionTimeout: time.Minute}
+	machine = newClockedFSM(clock, opts, func(interface{}, fsm.Event) error {
+		ctx := machine.ActionContext()
+		deadline, ok := ctx.Deadline()
+		assert.True(t, ok)
+		assert.Equal(t, time.Unix(60, 0), deadline)
+		clock.Advance(time.Minute)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.Equal(t, fsm.ErrActionTimeout, machine.ProcessEvent(fsm.StringEvent("run")))
+	assert.Equal(t, fsm.StringState("idle"), machine.CurrentState())
+	assert.Equal(t, 0, clock.Timers())
+}
+
+func TestFakeClockRetryAndStats(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	attempts := 0
+	machine := newClockedFSM(clock, fsm.TransitionOptions{Retry: &fsm.RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     fsm.ConstantBackoff(time.Hour),
+	}}, func(interface{}, fsm.Event) error {
+		attempts++
+		clock.Advance(time.Second)
+		if attempts < 3 {
+			return errors.New("transient")
+		}
+		return nil
+	})
+	go func() {
+		// the backoffs are measured by the clock.
+		for i := 1; i <= 2; i++ {
+			clock.WaitTimers(1)
+			clock.Advance(time.Hour)
+		}
+	}()
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent("run")))
+	assert.Equal(t, 3, attempts)
+
+	stats := machine.Stats()
+	assert.Equal(t, 2*time.Hour+3*time.Second, stats.Transitions[0].Latency.Max)
+	assert.Equal(t, 2*time.Hour+3*time.Second, stats.Events[0].Latency.Max)
+}
+
+func TestFakeClockManagerIdleTimeout(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	evicted := make(chan string, 1)
+	manager := fsm.NewManager(func(string) (*fsm.FSM, error) {
+		return newClockedFSM(clock, fsm.TransitionOptions{}, nil), nil
+	}, fsm.ManagerOptions[string]{
+		Shards:      1,
+		IdleTimeout: time.Minute,
+		Clock:       clock,
+		OnEvict: func(key string, _ *fsm.FSM) {
+			evicted <- key
+		},
+	})
+	defer manager.Close()
+	clock.WaitTimers(1)
+	assert.Nil(t, manager.ProcessEvent("a", fsm.StringEvent("run")))
+
+	clock.Advance(30 * time.Second)
+	assert.Nil(t, manager.Do("a", func(*fsm.FSM) error { return nil }))
+	assert.Len(t, evicted, 0)
+	clock.Advance(time.Minute)
+	assert.Equal(t, "a", <-evicted)
+}
@@ -0,0 +1,123 @@
+package fsmtest
+
+import (
+	"fmt"
+	"github.com/reyoung/fsm"
+	"strings"
+	"testing"
+)
+
+// TransitionCoverage is a transition of the machine, and the number of times it fired.
+type TransitionCoverage struct {
+	fsm.TransitionInfo
+	Fired uint64
+}
+
+// Coverage is the transition coverage of the machines, i.e., which edges of the state graph are fired by the
+// tests, which the code coverage cannot tell. See `CoverageReport`.
+type Coverage struct {
+	// Transitions are in the order of `fsm.FSM.Transitions`.
+	Transitions []TransitionCoverage
+}
+
+// CoverageReport returns the transition coverage of the machines, which should share the topology, e.g., the
+// machines created by the subtests of a table-driven test. The fired transitions are counted by `fsm.FSM.Stats`,
+// so they are recorded since the machines are created, or since `fsm.FSM.ResetStats`, e.g., after a setup:
+//
+//	machine := newOrder()
+//	... // the test
+//	report := fsmtest.CoverageReport(machine)
+//	t.Log(report)
+//
+// NOTE: the `Replay`ed events are not counted. The transitions sharing the same from state, event and to state,
+// e.g., two guarded transitions, are counted together.
+func CoverageReport(machine *fsm.FSM, more ...*fsm.FSM) *Coverage {
+	type edge struct {
+		from, event, to string
+	}
+	fired := make(map[edge]uint64)
+	for _, m := range append([]*fsm.FSM{machine}, more...) {
+		for _, stats := range m.Stats().Transitions {
+			fired[edge{stats.From.FSMStateID(), stats.Event, stats.To.FSMStateID()}] += stats.Latency.Count
+		}
+	}
+	transitions := machine.Transitions()
+	c := &Coverage{Transitions: make([]TransitionCoverage, 0, len(transitions))}
+	for _, info := range transitions {
+		c.Transitions = append(c.Transitions, TransitionCoverage{
+			TransitionInfo: info,
+			Fired:          fired[edge{info.From.FSMStateID(), info.Event, info.To.FSMStateID()}],
+		})
+	}
+	return c
+}
+
+// Covered returns the number of the transitions fired at least once.
+func (c *Coverage) Covered() int {
+	covered := 0
+	for _, t := range c.Transitions {
+		if t.Fired != 0 {
+			covered++
+		}
+	}
+	return covered
+}
+
+// Ratio returns the ratio of the covered transitions, in [0, 1]. It is 1 if the machine has no transition.
+func (c *Coverage) Ratio() float64 {
+	if len(c.Transitions) == 0 {
+		return 1
+	}
+	return float64(c.Covered()) / float64(len(c.Transitions))
+}
+
+// Uncovered returns the transitions which are not fired.
+func (c *Coverage) Uncovered() []fsm.TransitionInfo {
+	result := make([]fsm.TransitionInfo, 0)
+	for _, t := range c.Transitions {
+		if t.Fired == 0 {
+			result = append(result, t.TransitionInfo)
+		}
+	}
+	return result
+}
+
+// String reports the coverage and the untested edges, e.g.,
+//
+//	transition coverage: 2/3 (66.7%)
+//	untested:
+//	  on -break-> broken
+func (c *Coverage) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "transition coverage: %d/%d (%.1f%%)", c.Covered(), len(c.Transitions), c.Ratio()*100)
+	uncovered := c.Uncovered()
+	if len(uncovered) == 0 {
+		return sb.String()
+	}
+	sb.WriteString("\nuntested:")
+	for _, info := range uncovered {
+		fmt.Fprintf(&sb, "\n  %s", edgeLabel(info))
+	}
+	return sb.String()
+}
+
+// edgeLabel formats the transition like "off -switch-> on", the completion transitions have no event.
+func edgeLabel(info fsm.TransitionInfo) string {
+	label := fmt.Sprintf("%s -%s-> %s", info.From.FSMStateID(), info.Event, info.To.FSMStateID())
+	if info.Event == fsm.CompletionEventID {
+		label = fmt.Sprintf("%s --> %s", info.From.FSMStateID(), info.To.FSMStateID())
+	}
+	if info.Metadata.Name != "" {
+		label += fmt.Sprintf(" (%s)", info.Metadata.Name)
+	}
+	return label
+}
+
+// AssertCoverage fails the test with the report of `CoverageReport` if the ratio of the covered transitions is
+// below threshold, e.g., 0.8 for 80%.
+func AssertCoverage(t testing.TB, threshold float64, machine *fsm.FSM, more ...*fsm.FSM) {
+	t.Helper()
+	if c := CoverageReport(machine, more...); c.Ratio() < threshold {
+		t.Errorf("transition coverage is below %.1f%%\n%v", threshold*100, c)
+	}
+}
@@ -0,0 +1,63 @@
+package fsmtest
+
+import (
+	"github.com/reyoung/fsm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newLight() *fsm.FSM {
+	machine := fsm.NewFSM(fsm.StringState("off"), nil)
+	_ = machine.AddState(fsm.StringState("on"))
+	_ = machine.AddState(fsm.StringState("broken"))
+	_ = machine.AddEvent("switch")
+	_ = machine.AddEvent("break")
+	_ = machine.AddTransition(fsm.StringState("off"), "switch", fsm.StringState("on"), nil, nil)
+	_ = machine.AddTransition(fsm.StringState("on"), "switch", fsm.StringState("off"), nil, nil)
+	_ = machine.AddTransitionWithOptions(fsm.StringState("on"), "break", fsm.StringState("broken"), nil, nil,
+		fsm.TransitionOptions{Metadata: fsm.TransitionMetadata{Name: "overload"}})
+	_ = machine.AddCompletionTransition(fsm.StringState("broken"), fsm.StringState("off"), nil, nil)
+	return machine
+}
+
+func TestCoverageReport(t *testing.T) {
+	machine := newLight()
+	c := CoverageReport(machine)
+	assert.Equal(t, 0, c.Covered())
+	assert.Len(t, c.Transitions, 4)
+
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent("switch")))
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent("switch")))
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent("switch")))
+	c = CoverageReport(machine)
+	assert.Equal(t, 2, c.Covered())
+	assert.Equal(t, 0.5, c.Ratio())
+	assert.Equal(t, uint64(2), c.Transitions[1].Fired)
+	assert.Equal(t, `transition coverage: 2/4 (50.0%)
+untested:
+  broken --> off
+  on -break-> broken (overload)`, c.String())
+
+	// the machines of the subtests are merged.
+	other := newLight()
+	assert.Nil(t, other.ProcessEvent(fsm.StringEvent("switch")))
+	assert.Nil(t, other.ProcessEvent(fsm.StringEvent("break")))
+	c = CoverageReport(machine, other)
+	assert.Equal(t, 1.0, c.Ratio())
+	assert.Len(t, c.Uncovered(), 0)
+	assert.Equal(t, "transition coverage: 4/4 (100.0%)", c.String())
+
+	assert.Equal(t, 1.0, CoverageReport(fsm.NewFSM(fsm.StringState("idle"), nil)).Ratio())
+}
+
+func TestAssertCoverage(t *testing.T) {
+	machine := newLight()
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent("switch")))
+	r := &recorder{TB: t}
+	AssertCoverage(r, 0.25, machine)
+	assert.Len(t, r.errors, 0)
+	AssertCoverage(r, 0.5, machine)
+	assert.Len(t, r.errors, 1)
+	assert.Contains(t, r.errors[0], "transition coverage is below 50.0%")
+	assert.Contains(t, r.errors[0], "on -switch-> off")
+}
@@ -0,0 +1,90 @@
+package fsmtest
+
+import (
+	"errors"
+	"fmt"
+	"github.com/reyoung/fsm"
+	"hash/fnv"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// FuzzProcessEvents is the harness of Go native fuzzing for the machines created by `newMachine`:
+//
+//	func FuzzAccount(f *testing.F) {
+//		fsmtest.FuzzProcessEvents(f, newAccount, fsmtest.Config{}, nonNegativeBalance)
+//	}
+//
+// Each byte of the input is decoded as an event of `fsm.FSM.Events`, created by `Config.NewEvent`, so the fuzzer
+// explores the sequences of registered events, including the rejected ones. The input fails if the machine
+// panics, enters a state which is not added, or violates an invariant after an event. The rejected events are
+// expected, but the errors of the events which can be fired fail the input unless `Config.AllowErrors`. The
+// decoded sequence is at most `Config.Steps` events if it is set, and the random source of `Config.NewEvent` is
+// seeded by the input and `Config.Seed`, so the failing inputs are reproducible. `Config.Runs` is not used.
+// NOTE: like `Run`, `newMachine` should return a new machine in the same state each time.
+func FuzzProcessEvents(f *testing.F, newMachine func() *fsm.FSM, cfg Config, invariants ...Invariant) {
+	f.Helper()
+	f.Add([]byte{})
+	seed := make([]byte, len(newMachine().Events()))
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	f.Add(seed)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := fuzzEvents(newMachine, cfg, invariants, data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// fuzzEvents processes the events decoded from data by a new machine, it returns the error failing the input.
+func fuzzEvents(newMachine func() *fsm.FSM, cfg Config, invariants []Invariant, data []byte) (err error) {
+	if cfg.Steps > 0 && len(data) > cfg.Steps {
+		data = data[:cfg.Steps]
+	}
+	if cfg.NewEvent == nil {
+		cfg.NewEvent = func(evID string, _ *rand.Rand) fsm.Event { return fsm.StringEvent(evID) }
+	}
+	h := fnv.New64a()
+	_, _ = h.Write(data)
+	r := rand.New(rand.NewSource(int64(h.Sum64()) ^ cfg.Seed))
+
+	machine := newMachine()
+	evIDs := machine.Events()
+	var events []string
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = errors.New(fmt.Sprintf("after events [%s]: panic: %v", strings.Join(events, ", "), recovered))
+		}
+	}()
+	if err := checkInvariants(machine, invariants); err != nil {
+		return err
+	}
+	if len(evIDs) == 0 {
+		return nil
+	}
+	for _, b := range data {
+		ev := cfg.NewEvent(evIDs[int(b)%len(evIDs)], r)
+		events = append(events, ev.FSMEventID())
+		err := checkFuzzEvent(machine, cfg, invariants, ev)
+		if err != nil {
+			return errors.New(fmt.Sprintf("after events [%s]: %v", strings.Join(events, ", "), err))
+		}
+	}
+	return nil
+}
+
+// checkFuzzEvent processes the event, and checks the states and the invariants of the machine.
+func checkFuzzEvent(machine *fsm.FSM, cfg Config, invariants []Invariant, ev fsm.Event) error {
+	fireable := machine.CanFire(ev)
+	if err := machine.ProcessEvent(ev); err != nil && fireable && !cfg.AllowErrors {
+		return err
+	}
+	for _, state := range machine.CurrentStates() {
+		if state == nil || !machine.HasState(state) {
+			return errors.New(fmt.Sprintf("invalid state %v", state))
+		}
+	}
+	return checkInvariants(machine, invariants)
+}
@@ -0,0 +1,36 @@
+package fsmtest
+
+import (
+	"github.com/reyoung/fsm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func FuzzAccount(f *testing.F) {
+	FuzzProcessEvents(f, newAccountFSM(true), Config{}, nonNegativeBalance)
+}
+
+func TestFuzzEvents(t *testing.T) {
+	// deposit, withdraw, freeze and unfreeze are decoded from 0, 3, 1 and 2.
+	assert.Nil(t, fuzzEvents(newAccountFSM(true), Config{}, []Invariant{nonNegativeBalance}, []byte{0, 4, 3, 1, 2}))
+
+	err := fuzzEvents(newAccountFSM(false), Config{}, []Invariant{nonNegativeBalance}, []byte{0, 3, 3})
+	assert.EqualError(t, err, "after events [deposit, withdraw]: negative balance")
+	// the events after Steps are not processed.
+	assert.Nil(t, fuzzEvents(newAccountFSM(false), Config{Steps: 1}, []Invariant{nonNegativeBalance},
+		[]byte{0, 3, 3}))
+}
+
+func TestFuzzEventsPanic(t *testing.T) {
+	newMachine := func() *fsm.FSM {
+		machine := fsm.NewFSM(fsm.StringState("idle"), nil)
+		_ = machine.AddEvent("crash")
+		_ = machine.AddTransition(fsm.StringState("idle"), "crash", fsm.StringState("idle"),
+			func(interface{}, fsm.Event) error {
+				panic("boom")
+			}, nil)
+		return machine
+	}
+	assert.EqualError(t, fuzzEvents(newMachine, Config{}, nil, []byte{7}), "after events [crash]: panic: boom")
+	assert.Nil(t, fuzzEvents(newMachine, Config{}, nil, nil))
+}
@@ -0,0 +1,79 @@
+package fsmtest
+
+import (
+	"flag"
+	"github.com/reyoung/fsm"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("fsmtest.update", false, "update the golden files of AssertGraphvizGolden")
+
+// AssertGraphvizGolden compares `DumpGraphviz` of the machine with the golden file, and fails the test with a
+// line diff if they differ. Run the test with `-fsmtest.update` to create or update the golden file.
+func AssertGraphvizGolden(t testing.TB, machine *fsm.FSM, path string) {
+	t.Helper()
+	assertGolden(t, machine.DumpGraphviz(), path)
+}
+
+func assertGolden(t testing.TB, actual string, path string) {
+	t.Helper()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("create directory of golden file %s: %v", path, err)
+		}
+		if err := os.WriteFile(path, []byte(actual), 0644); err != nil {
+			t.Fatalf("write golden file %s: %v", path, err)
+		}
+		return
+	}
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file %s: %v, run with -fsmtest.update to create it", path, err)
+	}
+	if string(expected) != actual {
+		t.Errorf("graphviz mismatches golden file %s, run with -fsmtest.update to accept the change\n"+
+			"--- golden\n+++ actual\n%s", path, lineDiff(string(expected), actual))
+	}
+}
+
+// lineDiff returns the diff of the lines, based on the longest common subsequence. The removed lines start with
+// "-", the added lines start with "+" and the common lines start with " ".
+func lineDiff(a, b string) string {
+	x := strings.Split(a, "\n")
+	y := strings.Split(b, "\n")
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			sb.WriteString(" " + x[i] + "\n")
+			i, j = i+1, j+1
+		case j == len(y) || (i < len(x) && lcs[i+1][j] >= lcs[i][j+1]):
+			sb.WriteString("-" + x[i] + "\n")
+			i++
+		default:
+			sb.WriteString("+" + y[j] + "\n")
+			j++
+		}
+	}
+	return sb.String()
+}
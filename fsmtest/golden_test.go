@@ -0,0 +1,53 @@
+package fsmtest
+
+import (
+	"fmt"
+	"github.com/reyoung/fsm"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+// recorder records the errors instead of failing the test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertGraphvizGolden(t *testing.T) {
+	machine := newAccountFSM(false)()
+	AssertGraphvizGolden(t, machine, "testdata/account.dot")
+	if *update {
+		// the changed machine should not be written to the golden file.
+		return
+	}
+
+	_ = machine.AddState(fsm.StringState("closed"))
+	_ = machine.AddEvent("close")
+	_ = machine.AddTransition(fsm.StringState("frozen"), "close", fsm.StringState("closed"), nil, nil)
+	r := &recorder{TB: t}
+	AssertGraphvizGolden(r, machine, "testdata/account.dot")
+	assert.Len(t, r.errors, 1)
+	assert.Contains(t, r.errors[0], "testdata/account.dot")
+	assert.Contains(t, r.errors[0], "+\tn1[label=\"closed\",shape=\"box\"];")
+}
+
+func TestAssertGraphvizGoldenUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "golden", "account.dot")
+	*update = true
+	defer func() { *update = false }()
+	AssertGraphvizGolden(t, newAccountFSM(false)(), path)
+	*update = false
+	AssertGraphvizGolden(t, newAccountFSM(false)(), path)
+}
+
+func TestLineDiff(t *testing.T) {
+	assert.Equal(t, " a\n-b\n+x\n c\n+d\n", lineDiff("a\nb\nc", "a\nx\nc\nd"))
+	assert.Equal(t, " a\n", lineDiff("a", "a"))
+}
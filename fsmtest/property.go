@@ -0,0 +1,166 @@
+// Package fsmtest provides testing helpers for FSMs.
+package fsmtest
+
+import (
+	"errors"
+	"fmt"
+	"github.com/reyoung/fsm"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Invariant returns an error if the machine violates it. It is checked before the first event and after
+// each event.
+type Invariant func(machine *fsm.FSM) error
+
+// Config configures `Run` and `FuzzProcessEvents`. The zero value is valid.
+type Config struct {
+	// Runs is the number of generated sequences, 100 by default.
+	Runs int
+	// Steps is the max length of a generated sequence, 20 by default. A sequence ends early if no event can be
+	// fired.
+	Steps int
+	// Seed seeds the generator. The current time is used if it is 0, the seed is reported by `Failure`.
+	Seed int64
+	// NewEvent creates the event of evID, `fsm.StringEvent` is used if it is nil.
+	NewEvent func(evID string, r *rand.Rand) fsm.Event
+	// AllowErrors accepts the errors returned by `ProcessEvent`. By default, an error fails the run.
+	AllowErrors bool
+}
+
+// Failure is a shrunk sequence which violates an invariant.
+type Failure struct {
+	Seed   int64
+	Events []fsm.Event
+	Err    error
+}
+
+func (f *Failure) Error() string {
+	ids := make([]string, 0, len(f.Events))
+	for _, ev := range f.Events {
+		ids = append(ids, ev.FSMEventID())
+	}
+	return fmt.Sprintf("after events [%s] (seed %d): %v", strings.Join(ids, ", "), f.Seed, f.Err)
+}
+
+// errInvalidSequence means an event of the sequence cannot be fired, it is used by shrinking.
+var errInvalidSequence = errors.New("invalid sequence")
+
+// Check runs `Run` and fails the test with the shrunk sequence if an invariant is violated.
+func Check(t testing.TB, newMachine func() *fsm.FSM, cfg Config, invariants ...Invariant) {
+	t.Helper()
+	if failure := Run(newMachine, cfg, invariants...); failure != nil {
+		t.Fatalf("invariant violated %v", failure)
+	}
+}
+
+// Run generates random sequences of events which can be fired, i.e., `CanFire` returns true, and processes
+// them by the machines created by `newMachine`. If an invariant is violated, the sequence is shrunk to a
+// shorter one which still violates an invariant, and returned as a `Failure`.
+// NOTE: `newMachine` should return a new machine in the same state each time, and the guards should be
+// deterministic, so that the sequences can be replayed when shrinking.
+func Run(newMachine func() *fsm.FSM, cfg Config, invariants ...Invariant) *Failure {
+	if cfg.Runs <= 0 {
+		cfg.Runs = 100
+	}
+	if cfg.Steps <= 0 {
+		cfg.Steps = 20
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+	if cfg.NewEvent == nil {
+		cfg.NewEvent = func(evID string, _ *rand.Rand) fsm.Event { return fsm.StringEvent(evID) }
+	}
+	r := rand.New(rand.NewSource(cfg.Seed))
+	for i := 0; i < cfg.Runs; i++ {
+		events, err := generate(r, newMachine, cfg, invariants)
+		if err != nil {
+			events, err = shrink(newMachine, cfg, invariants, events, err)
+			return &Failure{Seed: cfg.Seed, Events: events, Err: err}
+		}
+	}
+	return nil
+}
+
+func checkInvariants(machine *fsm.FSM, invariants []Invariant) error {
+	for _, invariant := range invariants {
+		if err := invariant(machine); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func process(machine *fsm.FSM, cfg Config, invariants []Invariant, ev fsm.Event) error {
+	if err := machine.ProcessEvent(ev); err != nil && !cfg.AllowErrors {
+		return errors.New(fmt.Sprintf("event %s: %v", ev.FSMEventID(), err))
+	}
+	return checkInvariants(machine, invariants)
+}
+
+// generate processes a random sequence, it returns the sequence and the violation.
+func generate(r *rand.Rand, newMachine func() *fsm.FSM, cfg Config, invariants []Invariant) ([]fsm.Event, error) {
+	machine := newMachine()
+	if err := checkInvariants(machine, invariants); err != nil {
+		return nil, err
+	}
+	var events []fsm.Event
+	for step := 0; step < cfg.Steps; step++ {
+		var candidates []fsm.Event
+		for _, evID := range machine.AvailableEvents() {
+			if ev := cfg.NewEvent(evID, r); machine.CanFire(ev) {
+				candidates = append(candidates, ev)
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		ev := candidates[r.Intn(len(candidates))]
+		events = append(events, ev)
+		if err := process(machine, cfg, invariants, ev); err != nil {
+			return events, err
+		}
+	}
+	return events, nil
+}
+
+// execute processes the sequence by a new machine. It returns errInvalidSequence if an event cannot be fired.
+func execute(newMachine func() *fsm.FSM, cfg Config, invariants []Invariant, events []fsm.Event) error {
+	machine := newMachine()
+	if err := checkInvariants(machine, invariants); err != nil {
+		return err
+	}
+	for _, ev := range events {
+		if !machine.CanFire(ev) {
+			return errInvalidSequence
+		}
+		if err := process(machine, cfg, invariants, ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shrink removes chunks of events from the sequence, as long as the sequence still violates an invariant.
+func shrink(newMachine func() *fsm.FSM, cfg Config, invariants []Invariant, events []fsm.Event,
+	err error) ([]fsm.Event, error) {
+	for chunk := len(events) / 2; chunk >= 1; {
+		reduced := false
+		for i := 0; i+chunk <= len(events); {
+			candidate := append(append([]fsm.Event(nil), events[:i]...), events[i+chunk:]...)
+			if candidateErr := execute(newMachine, cfg, invariants, candidate); candidateErr != nil &&
+				candidateErr != errInvalidSequence {
+				events, err, reduced = candidate, candidateErr, true
+			} else {
+				i += chunk
+			}
+		}
+		if !reduced {
+			chunk /= 2
+		}
+	}
+	return events, err
+}
@@ -0,0 +1,60 @@
+package fsmtest
+
+import (
+	"errors"
+	"github.com/reyoung/fsm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type account struct {
+	balance int
+}
+
+// current is the account of the latest machine created by newAccountFSM.
+var current *account
+
+func newAccountFSM(guarded bool) func() *fsm.FSM {
+	return func() *fsm.FSM {
+		open, frozen := fsm.StringState("open"), fsm.StringState("frozen")
+		current = &account{}
+		machine := fsm.NewFSM(open, current)
+		_ = machine.AddState(frozen)
+		for _, ev := range []string{"deposit", "withdraw", "freeze", "unfreeze"} {
+			_ = machine.AddEvent(ev)
+		}
+		var guard func(interface{}, fsm.Event) bool
+		if guarded {
+			guard = func(payload interface{}, _ fsm.Event) bool {
+				return payload.(*account).balance >= 15
+			}
+		}
+		_ = machine.AddTransition(open, "deposit", open, func(payload interface{}, _ fsm.Event) error {
+			payload.(*account).balance += 10
+			return nil
+		}, nil)
+		_ = machine.AddTransition(open, "withdraw", open, func(payload interface{}, _ fsm.Event) error {
+			payload.(*account).balance -= 15
+			return nil
+		}, guard)
+		_ = machine.AddTransition(open, "freeze", frozen, nil, nil)
+		_ = machine.AddTransition(frozen, "unfreeze", open, nil, nil)
+		return machine
+	}
+}
+
+func nonNegativeBalance(*fsm.FSM) error {
+	if current.balance < 0 {
+		return errors.New("negative balance")
+	}
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	Check(t, newAccountFSM(true), Config{Seed: 1}, nonNegativeBalance)
+
+	failure := Run(newAccountFSM(false), Config{Seed: 1}, nonNegativeBalance)
+	assert.NotNil(t, failure)
+	assert.Equal(t, []fsm.Event{fsm.StringEvent("withdraw")}, failure.Events)
+	assert.Equal(t, "after events [withdraw] (seed 1): negative balance", failure.Error())
+}
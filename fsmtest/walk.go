@@ -0,0 +1,66 @@
+package fsmtest
+
+import (
+	"errors"
+	"fmt"
+	"github.com/reyoung/fsm"
+	"math/rand"
+)
+
+// Step is a transition taken by `RandomWalk`.
+type Step struct {
+	From  fsm.State
+	Event fsm.Event
+	To    fsm.State
+}
+
+// RandomWalk fires `steps` random events one by one, and returns the path taken, e.g., for the soak tests and for
+// exploring the rare combinations of states. Each event is chosen from the `fsm.FSM.AvailableEvents` which can be
+// fired, so the guards are respected. newEvent creates the event of evID, `fsm.StringEvent` is used if it is nil.
+// The walk ends early if no event can be fired. The walk is reproducible by the seed, as long as the machine
+// starts in the same state and its guards are deterministic. The guards can be stubbed by `StubGuards`:
+//
+//	fsmtest.StubGuards(machine, map[string]func(fsm.GuardHookArgs) bool{
+//		"paymentApproved": func(fsm.GuardHookArgs) bool { return r.Intn(10) == 0 },
+//	})
+//	path, err := fsmtest.RandomWalk(machine, seed, 1000, nil)
+//
+// If `ProcessEvent` fails, the path before the event is returned with the error.
+func RandomWalk(machine *fsm.FSM, seed int64, steps int, newEvent func(evID string, r *rand.Rand) fsm.Event) (
+	[]Step, error) {
+	if newEvent == nil {
+		newEvent = func(evID string, _ *rand.Rand) fsm.Event { return fsm.StringEvent(evID) }
+	}
+	r := rand.New(rand.NewSource(seed))
+	path := make([]Step, 0, steps)
+	for i := 0; i < steps; i++ {
+		var candidates []fsm.Event
+		for _, evID := range machine.AvailableEvents() {
+			if ev := newEvent(evID, r); machine.CanFire(ev) {
+				candidates = append(candidates, ev)
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		ev := candidates[r.Intn(len(candidates))]
+		from := machine.CurrentState()
+		if err := machine.ProcessEvent(ev); err != nil {
+			return path, errors.New(fmt.Sprintf("step %d, event %s: %v", i, ev.FSMEventID(), err))
+		}
+		path = append(path, Step{From: from, Event: ev, To: machine.CurrentState()})
+	}
+	return path, nil
+}
+
+// StubGuards replaces the guards of the machine by their registered names, see `fsm.TransitionOptions.GuardName`.
+// The other guards are evaluated as usual. The stubs are used by `fsm.FSM.ProcessEvent` and `fsm.FSM.CanFire`
+// alike, so the walks of `RandomWalk` follow them.
+func StubGuards(machine *fsm.FSM, stubs map[string]func(args fsm.GuardHookArgs) bool) {
+	machine.UseGuardMiddleware(func(args fsm.GuardHookArgs, next func() bool) bool {
+		if stub, ok := stubs[args.GuardName]; ok && args.GuardName != "" {
+			return stub(args)
+		}
+		return next()
+	})
+}
@@ -0,0 +1,67 @@
+package fsmtest
+
+import (
+	"errors"
+	"github.com/reyoung/fsm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newVault() *fsm.FSM {
+	machine := fsm.NewFSM(fsm.StringState("locked"), nil)
+	_ = machine.AddState(fsm.StringState("unlocked"))
+	_ = machine.AddState(fsm.StringState("open"))
+	_ = machine.AddEvent("unlock")
+	_ = machine.AddEvent("open")
+	_ = machine.AddEvent("close")
+	_ = machine.AddEvent("lock")
+	_ = machine.AddTransitionWithOptions(fsm.StringState("locked"), "unlock", fsm.StringState("unlocked"), nil,
+		func(interface{}, fsm.Event) bool { return false }, fsm.TransitionOptions{GuardName: "validCode"})
+	_ = machine.AddTransition(fsm.StringState("unlocked"), "open", fsm.StringState("open"), nil, nil)
+	_ = machine.AddTransition(fsm.StringState("unlocked"), "lock", fsm.StringState("locked"), nil, nil)
+	_ = machine.AddTransition(fsm.StringState("open"), "close", fsm.StringState("unlocked"), nil, nil)
+	return machine
+}
+
+func TestRandomWalk(t *testing.T) {
+	// the guard rejects all codes, so the vault cannot be unlocked.
+	path, err := RandomWalk(newVault(), 1, 10, nil)
+	assert.Nil(t, err)
+	assert.Len(t, path, 0)
+
+	walk := func(seed int64) []Step {
+		machine := newVault()
+		StubGuards(machine, map[string]func(fsm.GuardHookArgs) bool{
+			"validCode": func(fsm.GuardHookArgs) bool { return true },
+		})
+		path, err := RandomWalk(machine, seed, 50, nil)
+		assert.Nil(t, err)
+		assert.Len(t, path, 50)
+		assert.Equal(t, path[len(path)-1].To, machine.CurrentState())
+		return path
+	}
+	path = walk(7)
+	assert.Equal(t, Step{From: fsm.StringState("locked"), Event: fsm.StringEvent("unlock"),
+		To: fsm.StringState("unlocked")}, path[0])
+	for i := 1; i < len(path); i++ {
+		assert.Equal(t, path[i-1].To, path[i].From)
+	}
+	// the walk is reproducible by the seed.
+	assert.Equal(t, path, walk(7))
+	assert.NotEqual(t, path, walk(8))
+}
+
+func TestRandomWalkError(t *testing.T) {
+	machine := newVault()
+	StubGuards(machine, map[string]func(fsm.GuardHookArgs) bool{
+		"validCode": func(fsm.GuardHookArgs) bool { return true },
+	})
+	_ = machine.AddState(fsm.StringState("jammed"))
+	_ = machine.AddEvent("jam")
+	_ = machine.AddTransition(fsm.StringState("unlocked"), "jam", fsm.StringState("jammed"),
+		func(interface{}, fsm.Event) error { return errors.New("jammed") }, nil)
+	path, err := RandomWalk(machine, 3, 100, nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "event jam")
+	assert.Equal(t, fsm.StringState("unlocked"), path[len(path)-1].To)
+}
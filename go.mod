@@ -3,8 +3,13 @@ module github.com/reyoung/fsm
 require (
 	github.com/emicklei/dot v0.10.2
 	github.com/reyoung/delegate v0.1.1
-	github.com/reyoung/parallel v0.1.2
 	github.com/stretchr/testify v1.5.1
+	gopkg.in/yaml.v2 v2.4.0
 )
 
-go 1.13
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+)
+
+go 1.21
@@ -0,0 +1,53 @@
+package grpc
+
+import (
+	"context"
+	"github.com/reyoung/fsm"
+	"github.com/reyoung/fsm/persist"
+	"google.golang.org/grpc"
+)
+
+// Client drives the machines of a remote `Server` by the generated `FSMServiceClient`, encoding the events by a
+// codec. The errors are gRPC status errors, see fsm.proto for the codes.
+type Client struct {
+	client FSMServiceClient
+	codec  persist.Codec
+}
+
+// NewClient creates a client encoding the events by codec, `persist.JSONCodec` is used if it is nil. The codec
+// should be compatible with the one of the server.
+func NewClient(cc grpc.ClientConnInterface, codec persist.Codec) *Client {
+	if codec == nil {
+		codec = persist.NewJSONCodec()
+	}
+	return &Client{client: NewFSMServiceClient(cc), codec: codec}
+}
+
+// CreateMachine creates a machine of kind, and returns its initial state.
+func (c *Client) CreateMachine(ctx context.Context, id string, kind string) (string, error) {
+	resp, err := c.client.CreateMachine(ctx, &CreateMachineRequest{MachineId: id, Kind: kind})
+	if err != nil {
+		return "", err
+	}
+	return resp.State, nil
+}
+
+// ProcessEvent processes the event by the machine.
+func (c *Client) ProcessEvent(ctx context.Context, id string, ev fsm.Event) (*ProcessEventResponse, error) {
+	data, err := c.codec.Encode(ev)
+	if err != nil {
+		return nil, err
+	}
+	return c.client.ProcessEvent(ctx, &ProcessEventRequest{MachineId: id, Event: ev.FSMEventID(), Data: data})
+}
+
+// GetState returns the current state of the machine.
+func (c *Client) GetState(ctx context.Context, id string) (*GetStateResponse, error) {
+	return c.client.GetState(ctx, &GetStateRequest{MachineId: id})
+}
+
+// StreamTransitions streams the transitions of the machine until ctx is canceled. `Recv` of the stream blocks
+// until the next transition, and returns io.EOF when the server ends the stream.
+func (c *Client) StreamTransitions(ctx context.Context, id string) (FSMService_StreamTransitionsClient, error) {
+	return c.client.StreamTransitions(ctx, &StreamTransitionsRequest{MachineId: id})
+}
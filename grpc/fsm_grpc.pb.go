@@ -0,0 +1,260 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: fsm.proto
+
+package grpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	FSMService_CreateMachine_FullMethodName     = "/fsm.v1.FSMService/CreateMachine"
+	FSMService_ProcessEvent_FullMethodName      = "/fsm.v1.FSMService/ProcessEvent"
+	FSMService_GetState_FullMethodName          = "/fsm.v1.FSMService/GetState"
+	FSMService_StreamTransitions_FullMethodName = "/fsm.v1.FSMService/StreamTransitions"
+)
+
+// FSMServiceClient is the client API for FSMService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type FSMServiceClient interface {
+	// CreateMachine creates a machine of the kind. It fails with ALREADY_EXISTS if the machine id is used, or
+	// INVALID_ARGUMENT if the kind is unknown.
+	CreateMachine(ctx context.Context, in *CreateMachineRequest, opts ...grpc.CallOption) (*CreateMachineResponse, error)
+	// ProcessEvent processes the event by the machine. It fails with NOT_FOUND if the machine does not exist,
+	// or FAILED_PRECONDITION if the event cannot be processed in the current state.
+	ProcessEvent(ctx context.Context, in *ProcessEventRequest, opts ...grpc.CallOption) (*ProcessEventResponse, error)
+	// GetState returns the current state of the machine.
+	GetState(ctx context.Context, in *GetStateRequest, opts ...grpc.CallOption) (*GetStateResponse, error)
+	// StreamTransitions streams the transitions of the machine until the call is canceled.
+	StreamTransitions(ctx context.Context, in *StreamTransitionsRequest, opts ...grpc.CallOption) (FSMService_StreamTransitionsClient, error)
+}
+
+type fSMServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFSMServiceClient(cc grpc.ClientConnInterface) FSMServiceClient {
+	return &fSMServiceClient{cc}
+}
+
+func (c *fSMServiceClient) CreateMachine(ctx context.Context, in *CreateMachineRequest, opts ...grpc.CallOption) (*CreateMachineResponse, error) {
+	out := new(CreateMachineResponse)
+	err := c.cc.Invoke(ctx, FSMService_CreateMachine_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fSMServiceClient) ProcessEvent(ctx context.Context, in *ProcessEventRequest, opts ...grpc.CallOption) (*ProcessEventResponse, error) {
+	out := new(ProcessEventResponse)
+	err := c.cc.Invoke(ctx, FSMService_ProcessEvent_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fSMServiceClient) GetState(ctx context.Context, in *GetStateRequest, opts ...grpc.CallOption) (*GetStateResponse, error) {
+	out := new(GetStateResponse)
+	err := c.cc.Invoke(ctx, FSMService_GetState_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fSMServiceClient) StreamTransitions(ctx context.Context, in *StreamTransitionsRequest, opts ...grpc.CallOption) (FSMService_StreamTransitionsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &FSMService_ServiceDesc.Streams[0], FSMService_StreamTransitions_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &fSMServiceStreamTransitionsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type FSMService_StreamTransitionsClient interface {
+	Recv() (*Transition, error)
+	grpc.ClientStream
+}
+
+type fSMServiceStreamTransitionsClient struct {
+	grpc.ClientStream
+}
+
+func (x *fSMServiceStreamTransitionsClient) Recv() (*Transition, error) {
+	m := new(Transition)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// FSMServiceServer is the server API for FSMService service.
+// All implementations must embed UnimplementedFSMServiceServer
+// for forward compatibility
+type FSMServiceServer interface {
+	// CreateMachine creates a machine of the kind. It fails with ALREADY_EXISTS if the machine id is used, or
+	// INVALID_ARGUMENT if the kind is unknown.
+	CreateMachine(context.Context, *CreateMachineRequest) (*CreateMachineResponse, error)
+	// ProcessEvent processes the event by the machine. It fails with NOT_FOUND if the machine does not exist,
+	// or FAILED_PRECONDITION if the event cannot be processed in the current state.
+	ProcessEvent(context.Context, *ProcessEventRequest) (*ProcessEventResponse, error)
+	// GetState returns the current state of the machine.
+	GetState(context.Context, *GetStateRequest) (*GetStateResponse, error)
+	// StreamTransitions streams the transitions of the machine until the call is canceled.
+	StreamTransitions(*StreamTransitionsRequest, FSMService_StreamTransitionsServer) error
+	mustEmbedUnimplementedFSMServiceServer()
+}
+
+// UnimplementedFSMServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedFSMServiceServer struct {
+}
+
+func (UnimplementedFSMServiceServer) CreateMachine(context.Context, *CreateMachineRequest) (*CreateMachineResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateMachine not implemented")
+}
+func (UnimplementedFSMServiceServer) ProcessEvent(context.Context, *ProcessEventRequest) (*ProcessEventResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ProcessEvent not implemented")
+}
+func (UnimplementedFSMServiceServer) GetState(context.Context, *GetStateRequest) (*GetStateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetState not implemented")
+}
+func (UnimplementedFSMServiceServer) StreamTransitions(*StreamTransitionsRequest, FSMService_StreamTransitionsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamTransitions not implemented")
+}
+func (UnimplementedFSMServiceServer) mustEmbedUnimplementedFSMServiceServer() {}
+
+// UnsafeFSMServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FSMServiceServer will
+// result in compilation errors.
+type UnsafeFSMServiceServer interface {
+	mustEmbedUnimplementedFSMServiceServer()
+}
+
+func RegisterFSMServiceServer(s grpc.ServiceRegistrar, srv FSMServiceServer) {
+	s.RegisterService(&FSMService_ServiceDesc, srv)
+}
+
+func _FSMService_CreateMachine_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateMachineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSMServiceServer).CreateMachine(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FSMService_CreateMachine_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSMServiceServer).CreateMachine(ctx, req.(*CreateMachineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FSMService_ProcessEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProcessEventRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSMServiceServer).ProcessEvent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FSMService_ProcessEvent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSMServiceServer).ProcessEvent(ctx, req.(*ProcessEventRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FSMService_GetState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FSMServiceServer).GetState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FSMService_GetState_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FSMServiceServer).GetState(ctx, req.(*GetStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FSMService_StreamTransitions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamTransitionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FSMServiceServer).StreamTransitions(m, &fSMServiceStreamTransitionsServer{stream})
+}
+
+type FSMService_StreamTransitionsServer interface {
+	Send(*Transition) error
+	grpc.ServerStream
+}
+
+type fSMServiceStreamTransitionsServer struct {
+	grpc.ServerStream
+}
+
+func (x *fSMServiceStreamTransitionsServer) Send(m *Transition) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// FSMService_ServiceDesc is the grpc.ServiceDesc for FSMService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var FSMService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "fsm.v1.FSMService",
+	HandlerType: (*FSMServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateMachine",
+			Handler:    _FSMService_CreateMachine_Handler,
+		},
+		{
+			MethodName: "ProcessEvent",
+			Handler:    _FSMService_ProcessEvent_Handler,
+		},
+		{
+			MethodName: "GetState",
+			Handler:    _FSMService_GetState_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamTransitions",
+			Handler:       _FSMService_StreamTransitions_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "fsm.proto",
+}
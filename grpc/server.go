@@ -0,0 +1,166 @@
+// Package grpc serves FSMs over gRPC, so that non-Go services can drive the machines hosted in a Go process.
+// The service is defined by fsm.proto.
+//
+//	server := fsmgrpc.NewServer(nil)
+//	_ = server.RegisterKind("order", newOrderMachine)
+//	s := grpc.NewServer()
+//	fsmgrpc.Register(s, server)
+//
+// The messages and the service stubs in fsm.pb.go and fsm_grpc.pb.go are generated from fsm.proto by
+// protoc-gen-go and protoc-gen-go-grpc, see the go:generate directive below.
+package grpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative fsm.proto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/reyoung/fsm"
+	"github.com/reyoung/fsm/persist"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+)
+
+// Server implements the FSMService. The machines are created by the factories registered as kinds, and they
+// are processed by `fsm.QueuedFSM`, so the concurrent calls are serialized. It is thread-safe.
+type Server struct {
+	UnimplementedFSMServiceServer
+	codec    persist.Codec
+	mu       sync.RWMutex
+	kinds    map[string]func() *fsm.QueuedFSM
+	machines map[string]*fsm.QueuedFSM
+}
+
+// NewServer creates a server decoding the events by codec, `persist.JSONCodec` is used if it is nil.
+func NewServer(codec persist.Codec) *Server {
+	if codec == nil {
+		codec = persist.NewJSONCodec()
+	}
+	return &Server{
+		codec:    codec,
+		kinds:    make(map[string]func() *fsm.QueuedFSM),
+		machines: make(map[string]*fsm.QueuedFSM),
+	}
+}
+
+// RegisterKind registers the factory of the machines of kind. It returns `fsm.AlreadyExists` if the kind is
+// registered already.
+func (s *Server) RegisterKind(kind string, newMachine func() *fsm.QueuedFSM) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.kinds[kind]; ok {
+		return fsm.AlreadyExists
+	}
+	s.kinds[kind] = newMachine
+	return nil
+}
+
+// Machine returns the machine created by `CreateMachine`.
+func (s *Server) Machine(id string) (*fsm.QueuedFSM, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	machine, ok := s.machines[id]
+	return machine, ok
+}
+
+// Close closes all machines.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for id, machine := range s.machines {
+		errs = append(errs, machine.Close())
+		delete(s.machines, id)
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server) machine(id string) (*fsm.QueuedFSM, error) {
+	machine, ok := s.Machine(id)
+	if !ok {
+		return nil, status.Error(codes.NotFound, fmt.Sprintf("machine %s not found", id))
+	}
+	return machine, nil
+}
+
+func (s *Server) CreateMachine(_ context.Context, req *CreateMachineRequest) (*CreateMachineResponse, error) {
+	if req.MachineId == "" {
+		return nil, status.Error(codes.InvalidArgument, "the machine id should not be empty")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	newMachine, ok := s.kinds[req.Kind]
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("unknown kind %s", req.Kind))
+	}
+	if _, ok := s.machines[req.MachineId]; ok {
+		return nil, status.Error(codes.AlreadyExists, fmt.Sprintf("machine %s already exists", req.MachineId))
+	}
+	machine := newMachine()
+	s.machines[req.MachineId] = machine
+	return &CreateMachineResponse{State: machine.CurrentState().FSMStateID()}, nil
+}
+
+func (s *Server) ProcessEvent(ctx context.Context, req *ProcessEventRequest) (*ProcessEventResponse, error) {
+	machine, err := s.machine(req.MachineId)
+	if err != nil {
+		return nil, err
+	}
+	ev, err := s.codec.Decode(req.Event, req.Data)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("decode event %s: %v", req.Event, err))
+	}
+	if err := machine.ProcessEventContext(ctx, ev); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &ProcessEventResponse{State: machine.CurrentState().FSMStateID(), Version: machine.Version()}, nil
+}
+
+func (s *Server) GetState(_ context.Context, req *GetStateRequest) (*GetStateResponse, error) {
+	machine, err := s.machine(req.MachineId)
+	if err != nil {
+		return nil, err
+	}
+	resp := &GetStateResponse{State: machine.CurrentState().FSMStateID(), Version: machine.Version()}
+	for _, state := range machine.CurrentStates() {
+		resp.States = append(resp.States, state.FSMStateID())
+	}
+	return resp, nil
+}
+
+// StreamTransitions sends the transitions of the machine until the stream is canceled.
+func (s *Server) StreamTransitions(req *StreamTransitionsRequest, stream FSMService_StreamTransitionsServer) error {
+	machine, err := s.machine(req.MachineId)
+	if err != nil {
+		return err
+	}
+	changes, cancel := machine.Subscribe()
+	defer cancel()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case change, ok := <-changes:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(&Transition{
+				From:         change.From.FSMStateID(),
+				To:           change.To.FSMStateID(),
+				Event:        change.Event.FSMEventID(),
+				TimeUnixNano: change.Time.UnixNano(),
+				Dropped:      int32(change.Dropped),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Register registers the server to a gRPC server.
+func Register(registrar grpc.ServiceRegistrar, s FSMServiceServer) {
+	RegisterFSMServiceServer(registrar, s)
+}
@@ -0,0 +1,138 @@
+package grpc
+
+import (
+	"context"
+	"github.com/reyoung/fsm"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding"
+	grpcproto "google.golang.org/grpc/encoding/proto"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dial serves the server by an in-memory listener, and returns a client connected to it.
+func dial(t *testing.T, server *Server) *Client {
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	Register(s, server)
+	go func() {
+		_ = s.Serve(lis)
+	}()
+	t.Cleanup(s.Stop)
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return NewClient(conn, nil)
+}
+
+func newLight() *fsm.QueuedFSM {
+	var (
+		red    = fsm.StringState("red")
+		green  = fsm.StringState("green")
+		yellow = fsm.StringState("yellow")
+	)
+	machine := fsm.NewQueuedFSM(red, nil)
+	_ = machine.AddState(green)
+	_ = machine.AddState(yellow)
+	_ = machine.AddEvent("next")
+	_ = machine.AddEvent("reset")
+	_ = machine.AddTransition(red, "next", green, nil, nil)
+	_ = machine.AddTransition(green, "next", yellow, nil, nil)
+	_ = machine.AddTransition(yellow, "next", red, nil, nil)
+	return machine
+}
+
+func TestServer(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	assert.Nil(t, server.RegisterKind("light", newLight))
+	assert.Equal(t, fsm.AlreadyExists, server.RegisterKind("light", newLight))
+	client := dial(t, server)
+	ctx := context.Background()
+
+	state, err := client.CreateMachine(ctx, "l1", "light")
+	assert.Nil(t, err)
+	assert.Equal(t, "red", state)
+	_, err = client.CreateMachine(ctx, "l1", "light")
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	_, err = client.CreateMachine(ctx, "l2", "door")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	resp, err := client.ProcessEvent(ctx, "l1", fsm.StringEvent("next"))
+	assert.Nil(t, err)
+	assert.True(t, proto.Equal(&ProcessEventResponse{State: "green", Version: 1}, resp))
+	_, err = client.ProcessEvent(ctx, "l1", fsm.StringEvent("reset"))
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = client.ProcessEvent(ctx, "l2", fsm.StringEvent("next"))
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	got, err := client.GetState(ctx, "l1")
+	assert.Nil(t, err)
+	assert.True(t, proto.Equal(&GetStateResponse{State: "green", States: []string{"green"}, Version: 1}, got))
+	machine, ok := server.Machine("l1")
+	assert.True(t, ok)
+	assert.Equal(t, fsm.StringState("green"), machine.CurrentState())
+}
+
+func TestStreamTransitions(t *testing.T) {
+	server := NewServer(nil)
+	defer server.Close()
+	assert.Nil(t, server.RegisterKind("light", newLight))
+	client := dial(t, server)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err := client.CreateMachine(ctx, "l1", "light")
+	assert.Nil(t, err)
+
+	stream, err := client.StreamTransitions(ctx, "l1")
+	assert.Nil(t, err)
+	received := make(chan *Transition, 1)
+	go func() {
+		transition, err := stream.Recv()
+		assert.Nil(t, err)
+		received <- transition
+	}()
+	// the subscription is made asynchronously, so the events are processed until a transition is received.
+	var transition *Transition
+	assert.Eventually(t, func() bool {
+		_, err := client.ProcessEvent(ctx, "l1", fsm.StringEvent("next"))
+		assert.Nil(t, err)
+		select {
+		case transition = <-received:
+			return true
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "next", transition.Event)
+	assert.True(t, strings.Contains("red green yellow", transition.From))
+	assert.NotZero(t, transition.TimeUnixNano)
+
+	stream, err = client.StreamTransitions(ctx, "unknown")
+	assert.Nil(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestProtoCodecNotReplaced(t *testing.T) {
+	// the messages are generated, so the "proto" codec of gRPC encodes them.
+	data, err := encoding.GetCodec(grpcproto.Name).Marshal(&GetStateRequest{MachineId: "m"})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x0a, 1, 'm'}, data)
+}
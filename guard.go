@@ -0,0 +1,102 @@
+package fsm
+
+// GuardHookArgs is the transition whose guard is evaluated. See `GuardMiddleware`.
+type GuardHookArgs struct {
+	// FromState is the state declaring the transition, which may be a composite state containing the current state.
+	FromState State
+	ToState   State
+	Event     Event
+	Payload   interface{}
+	// GuardName is the registered guard name, or empty if unknown. HasGuard is false if the transition has no
+	// guard, i.e., the guard always returns true.
+	GuardName string
+	HasGuard  bool
+}
+
+// GuardMiddleware wraps the guards of all transitions, like `ActionMiddleware`. The middleware should invoke
+// `next` to evaluate the wrapped guard (and the inner middlewares), or return its own result, e.g., to stub the
+// guards in tests:
+//
+//	machine.UseGuardMiddleware(func(args fsm.GuardHookArgs, next func() bool) bool {
+//		if args.GuardName == "paymentApproved" {
+//			return true
+//		}
+//		return next()
+//	})
+type GuardMiddleware func(args GuardHookArgs, next func() bool) bool
+
+// UseGuardMiddleware appends a middleware to the FSM. The first used middleware is the outermost one. The
+// middlewares are used by `ProcessEvent`, `CanFire` and `Simulate` alike.
+func (fsm *FSM) UseGuardMiddleware(mw GuardMiddleware) {
+	fsm.guardMiddlewares = append(fsm.guardMiddlewares, mw)
+}
+
+// checkGuard evaluates the guard of t, declared by state `from`, through the middlewares.
+func (fsm *FSM) checkGuard(from string, t *transition, ev Event) bool {
+	if len(fsm.guardMiddlewares) == 0 {
+		return fsm.evalGuard(t, ev)
+	}
+	args := GuardHookArgs{
+		FromState: fsm.states[from],
+		ToState:   t.to,
+		Event:     ev,
+		Payload:   fsm.payload,
+		GuardName: t.guardName,
+		HasGuard:  t.hasGuard,
+	}
+	next := func() bool {
+		return fsm.evalGuard(t, ev)
+	}
+	for i := len(fsm.guardMiddlewares) - 1; i >= 0; i-- {
+		mw, inner := fsm.guardMiddlewares[i], next
+		next = func() bool {
+			return mw(args, inner)
+		}
+	}
+	return next()
+}
+
+// GuardAll returns a guard which returns true if all guards return true. The guards are evaluated in
+// order and the evaluation stops at the first false.
+func GuardAll(guards ...func(interface{}, Event) bool) func(interface{}, Event) bool {
+	return func(payload interface{}, ev Event) bool {
+		for _, g := range guards {
+			if !g(payload, ev) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// GuardAny returns a guard which returns true if any guard returns true. The guards are evaluated in
+// order and the evaluation stops at the first true.
+func GuardAny(guards ...func(interface{}, Event) bool) func(interface{}, Event) bool {
+	return func(payload interface{}, ev Event) bool {
+		for _, g := range guards {
+			if g(payload, ev) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// GuardNot returns a guard which negates g.
+func GuardNot(g func(interface{}, Event) bool) func(interface{}, Event) bool {
+	return func(payload interface{}, ev Event) bool {
+		return !g(payload, ev)
+	}
+}
+
+// GuardEventIs returns a guard which returns true if the event is a T and `pred` returns true for it.
+// The `pred` can be nil to check the event type only.
+func GuardEventIs[T Event](pred func(T) bool) func(interface{}, Event) bool {
+	return func(payload interface{}, ev Event) bool {
+		typed, ok := ev.(T)
+		if !ok {
+			return false
+		}
+		return pred == nil || pred(typed)
+	}
+}
@@ -0,0 +1,53 @@
+package fsm
+
+// SetGuardMemoization enables memoizing the guard results, for the machines whose guards are pure functions of
+// the state and the event id, e.g., checking the configuration, so the guards are evaluated once per transition
+// in high-rate event streams. The payload and the event are not part of the key, so the results should be
+// invalidated by `InvalidateGuards` or `InvalidateGuard` when the values checked by the guards change. It is
+// disabled by default, and enabling or disabling it clears the memoized results.
+//
+// The guard middlewares are invoked as usual, only the guards wrapped by them are memoized.
+// NOTE: like other modifications, it should not be invoked concurrently with `ProcessEvent`.
+func (fsm *FSM) SetGuardMemoization(enabled bool) {
+	fsm.guardMemo = nil
+	if enabled {
+		fsm.guardMemo = make(map[*transition]bool)
+	}
+}
+
+// InvalidateGuards clears all memoized guard results. See `SetGuardMemoization`.
+func (fsm *FSM) InvalidateGuards() {
+	if fsm.guardMemo != nil {
+		clear(fsm.guardMemo)
+	}
+}
+
+// InvalidateGuard clears the memoized results of the guards of the transitions from the state by the event. See
+// `SetGuardMemoization`.
+func (fsm *FSM) InvalidateGuard(state State, evId string) {
+	if fsm.guardMemo == nil {
+		return
+	}
+	for _, t := range fsm.transitions[state.FSMStateID()][evId] {
+		delete(fsm.guardMemo, t)
+	}
+}
+
+// evalGuard evaluates the guard of t, or returns the memoized result.
+func (fsm *FSM) evalGuard(t *transition, ev Event) bool {
+	if fsm.guardMemo == nil || !t.hasGuard {
+		return t.guard(fsm.payload, ev)
+	}
+	// the guards may be evaluated concurrently, see `SetParallelGuards`.
+	fsm.guardMemoMu.Lock()
+	ok, found := fsm.guardMemo[t]
+	fsm.guardMemoMu.Unlock()
+	if found {
+		return ok
+	}
+	ok = t.guard(fsm.payload, ev)
+	fsm.guardMemoMu.Lock()
+	fsm.guardMemo[t] = ok
+	fsm.guardMemoMu.Unlock()
+	return ok
+}
@@ -0,0 +1,56 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGuardMemoization(t *testing.T) {
+	machine := NewFSM(StringState("idle"), nil)
+	_ = machine.AddEvent("tick")
+	_ = machine.AddEvent("stop")
+	var evaluated int
+	enabled := true
+	_ = machine.AddTransition(StringState("idle"), "tick", StringState("idle"), nil, func(interface{}, Event) bool {
+		evaluated++
+		return enabled
+	})
+	_ = machine.AddTransition(StringState("idle"), "stop", StringState("idle"), nil, func(interface{}, Event) bool {
+		evaluated++
+		return true
+	})
+	var intercepted int
+	machine.UseGuardMiddleware(func(args GuardHookArgs, next func() bool) bool {
+		intercepted++
+		return next()
+	})
+
+	machine.SetGuardMemoization(true)
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, machine.ProcessEvent(StringEvent("tick")))
+	}
+	assert.Equal(t, 1, evaluated)
+	assert.Equal(t, 3, intercepted)
+
+	// the memoized result is kept until it is invalidated.
+	enabled = false
+	assert.Nil(t, machine.ProcessEvent(StringEvent("tick")))
+	assert.Nil(t, machine.ProcessEvent(StringEvent("stop")))
+	assert.Equal(t, 2, evaluated)
+	machine.InvalidateGuard(StringState("idle"), "tick")
+	assert.NotNil(t, machine.ProcessEvent(StringEvent("tick")))
+	assert.NotNil(t, machine.ProcessEvent(StringEvent("tick")))
+	assert.Nil(t, machine.ProcessEvent(StringEvent("stop")))
+	assert.Equal(t, 3, evaluated)
+
+	enabled = true
+	machine.InvalidateGuards()
+	assert.Nil(t, machine.ProcessEvent(StringEvent("tick")))
+	assert.Nil(t, machine.ProcessEvent(StringEvent("stop")))
+	assert.Equal(t, 5, evaluated)
+
+	machine.SetGuardMemoization(false)
+	assert.Nil(t, machine.ProcessEvent(StringEvent("tick")))
+	assert.Nil(t, machine.ProcessEvent(StringEvent("tick")))
+	assert.Equal(t, 7, evaluated)
+}
@@ -0,0 +1,78 @@
+package fsm
+
+import "sync"
+
+// SetParallelGuards enables evaluating the guards of the candidate transitions of an event concurrently, for the
+// machines whose guards are expensive, e.g., calling remote services. The event waits for all guards, and the
+// first passing transition in the usual order, e.g., by `SetPriorityOrder`, is taken, so the result is the same as
+// the sequential evaluation. It is disabled by default.
+//   - The guards and the guard middlewares should be thread-safe, since they are invoked concurrently.
+//   - The guards depending on the order of the evaluation, e.g., the guards with side effects, should be declared
+//     by `SetStrictGuardOrder`.
+//   - `CanFire` and `Simulate` evaluate the guards sequentially.
+//
+// NOTE: like other modifications, it should not be invoked concurrently with `ProcessEvent`.
+func (fsm *FSM) SetParallelGuards(enabled bool) {
+	fsm.parallelGuards = enabled
+}
+
+// SetStrictGuardOrder makes the guards of the transitions from the state by the event evaluated sequentially,
+// and stopped at the first passing one, even if `SetParallelGuards` is enabled.
+func (fsm *FSM) SetStrictGuardOrder(from State, evId string, strict bool) {
+	pair := stateEvent{from: from.FSMStateID(), ev: evId}
+	if !strict {
+		delete(fsm.strictGuards, pair)
+		return
+	}
+	if fsm.strictGuards == nil {
+		fsm.strictGuards = make(map[stateEvent]bool)
+	}
+	fsm.strictGuards[pair] = true
+}
+
+// checkGuardsParallel evaluates the guards of transList concurrently, and returns whether each of them passes. It
+// returns nil if the guards should be evaluated sequentially. The transitions whose joins are not ready are not
+// evaluated.
+func (fsm *FSM) checkGuardsParallel(from string, ev Event, transList []*transition) []bool {
+	if !fsm.parallelGuards || len(transList) < 2 || fsm.strictGuards[stateEvent{from: from, ev: ev.FSMEventID()}] {
+		return nil
+	}
+	passed := make([]bool, len(transList))
+	var wg sync.WaitGroup
+	var panicOnce sync.Once
+	var panicked bool
+	var panicVal interface{}
+	inline := -1
+	for i, t := range transList {
+		if t.join != nil && !fsm.joinReady(t) {
+			continue
+		}
+		if inline < 0 {
+			// the first guard is evaluated by the current goroutine.
+			inline = i
+			continue
+		}
+		wg.Add(1)
+		go func(i int, t *transition) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					panicOnce.Do(func() {
+						panicked, panicVal = true, r
+					})
+				}
+			}()
+			passed[i] = fsm.checkGuard(from, t, ev)
+		}(i, t)
+	}
+	if inline >= 0 {
+		// wait for the other guards even if the first one panics.
+		defer wg.Wait()
+		passed[inline] = fsm.checkGuard(from, transList[inline], ev)
+	}
+	wg.Wait()
+	if panicked {
+		panic(panicVal)
+	}
+	return passed
+}
@@ -0,0 +1,112 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newQuoteFSM creates a machine choosing a provider by the guards, which wait for each other to simulate the
+// remote calls evaluated concurrently.
+func newQuoteFSM(t *testing.T, passes map[string]bool) (*FSM, *int32) {
+	machine := NewFSM(StringState("pending"), nil)
+	assert.Nil(t, machine.AddEvent("quote"))
+	var started sync.WaitGroup
+	started.Add(len(passes))
+	var evaluated int32
+	for _, provider := range []string{"primary", "secondary", "fallback"} {
+		pass, ok := passes[provider]
+		if !ok {
+			continue
+		}
+		assert.Nil(t, machine.AddState(StringState(provider)))
+		assert.Nil(t, machine.AddTransition(StringState("pending"), "quote", StringState(provider), nil,
+			func(interface{}, Event) bool {
+				atomic.AddInt32(&evaluated, 1)
+				started.Done()
+				done := make(chan struct{})
+				go func() {
+					started.Wait()
+					close(done)
+				}()
+				select {
+				case <-done:
+					return pass
+				case <-time.After(time.Second):
+					// the guards are evaluated sequentially.
+					return false
+				}
+			}))
+	}
+	return machine, &evaluated
+}
+
+func TestParallelGuards(t *testing.T) {
+	machine, evaluated := newQuoteFSM(t, map[string]bool{"primary": false, "secondary": true, "fallback": true})
+	machine.SetParallelGuards(true)
+	assert.Nil(t, machine.ProcessEvent(StringEvent("quote")))
+	// the first passing transition in order is taken.
+	assert.Equal(t, "secondary", machine.CurrentState().FSMStateID())
+	assert.Equal(t, int32(3), atomic.LoadInt32(evaluated))
+	assert.Nil(t, machine.ExplainLastRejection())
+}
+
+func TestParallelGuardsRejected(t *testing.T) {
+	machine, _ := newQuoteFSM(t, map[string]bool{"primary": false, "secondary": false})
+	machine.SetParallelGuards(true)
+	assert.NotNil(t, machine.ProcessEvent(StringEvent("quote")))
+	assert.Equal(t, "pending", machine.CurrentState().FSMStateID())
+	rejection := machine.ExplainLastRejection()
+	if assert.NotNil(t, rejection) {
+		assert.Len(t, rejection.Candidates, 2)
+	}
+}
+
+func TestStrictGuardOrder(t *testing.T) {
+	machine := NewFSM(StringState("pending"), nil)
+	assert.Nil(t, machine.AddEvent("quote"))
+	assert.Nil(t, machine.AddEvent("retry"))
+	var order []string
+	var mu sync.Mutex
+	for _, provider := range []string{"primary", "secondary"} {
+		provider := provider
+		assert.Nil(t, machine.AddState(StringState(provider)))
+		assert.Nil(t, machine.AddTransition(StringState("pending"), "quote", StringState(provider), nil,
+			func(interface{}, Event) bool {
+				mu.Lock()
+				defer mu.Unlock()
+				order = append(order, provider)
+				return true
+			}))
+	}
+	assert.Nil(t, machine.AddTransition(StringState("primary"), "retry", StringState("pending"), nil, nil))
+	machine.SetParallelGuards(true)
+	machine.SetStrictGuardOrder(StringState("pending"), "quote", true)
+	assert.Nil(t, machine.ProcessEvent(StringEvent("quote")))
+	assert.Equal(t, "primary", machine.CurrentState().FSMStateID())
+	// the evaluation stops at the first passing guard.
+	assert.Equal(t, []string{"primary"}, order)
+
+	machine.SetStrictGuardOrder(StringState("pending"), "quote", false)
+	assert.Nil(t, machine.ProcessEvent(StringEvent("retry")))
+	order = nil
+	assert.Nil(t, machine.ProcessEvent(StringEvent("quote")))
+	assert.Equal(t, "primary", machine.CurrentState().FSMStateID())
+	assert.Len(t, order, 2)
+}
+
+func TestParallelGuardsPanic(t *testing.T) {
+	machine := NewFSM(StringState("pending"), nil)
+	assert.Nil(t, machine.AddEvent("quote"))
+	assert.Nil(t, machine.AddState(StringState("primary")))
+	assert.Nil(t, machine.AddTransition(StringState("pending"), "quote", StringState("primary"), nil,
+		func(interface{}, Event) bool { return false }))
+	assert.Nil(t, machine.AddTransition(StringState("pending"), "quote", StringState("primary"), nil,
+		func(interface{}, Event) bool { panic("unavailable") }))
+	machine.SetParallelGuards(true)
+	assert.PanicsWithValue(t, "unavailable", func() {
+		_ = machine.ProcessEvent(StringEvent("quote"))
+	})
+}
@@ -0,0 +1,72 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type temperatureEvent struct {
+	celsius int
+}
+
+func (t *temperatureEvent) FSMEventID() string {
+	return "temperature"
+}
+
+func TestGuardCombinators(t *testing.T) {
+	var (
+		yes = func(interface{}, Event) bool { return true }
+		no  = func(interface{}, Event) bool { return false }
+		ev  = StringEvent("ev")
+	)
+	assert.True(t, GuardAll()(nil, ev))
+	assert.True(t, GuardAll(yes, yes)(nil, ev))
+	assert.False(t, GuardAll(yes, no)(nil, ev))
+	assert.False(t, GuardAny()(nil, ev))
+	assert.True(t, GuardAny(no, yes)(nil, ev))
+	assert.False(t, GuardAny(no, no)(nil, ev))
+	assert.True(t, GuardNot(no)(nil, ev))
+
+	hot := GuardEventIs(func(ev *temperatureEvent) bool {
+		return ev.celsius > 30
+	})
+	assert.True(t, hot(nil, &temperatureEvent{celsius: 35}))
+	assert.False(t, hot(nil, &temperatureEvent{celsius: 20}))
+	assert.False(t, hot(nil, StringEvent("temperature")))
+	assert.True(t, GuardEventIs[StringEvent](nil)(nil, ev))
+	assert.True(t, GuardAll(GuardNot(hot), GuardEventIs[*temperatureEvent](nil))(nil, &temperatureEvent{}))
+}
+
+func TestGuardMiddleware(t *testing.T) {
+	closed, open := StringState("closed"), StringState("open")
+	fsm := NewFSM(closed, nil)
+	assert.Nil(t, fsm.AddState(open))
+	assert.Nil(t, fsm.AddEvent("open"))
+	assert.Nil(t, fsm.AddTransitionWithOptions(closed, "open", open, nil, func(interface{}, Event) bool {
+		return false
+	}, TransitionOptions{GuardName: "authorized"}))
+	assert.False(t, fsm.CanFire(StringEvent("open")))
+
+	var order []string
+	fsm.UseGuardMiddleware(func(args GuardHookArgs, next func() bool) bool {
+		order = append(order, "outer")
+		assert.Equal(t, closed, args.FromState)
+		assert.Equal(t, open, args.ToState)
+		assert.True(t, args.HasGuard)
+		return next()
+	})
+	fsm.UseGuardMiddleware(func(args GuardHookArgs, next func() bool) bool {
+		order = append(order, "inner")
+		if args.GuardName == "authorized" {
+			return true
+		}
+		return next()
+	})
+	assert.True(t, fsm.CanFire(StringEvent("open")))
+	next, err := fsm.Simulate(StringEvent("open"))
+	assert.Nil(t, err)
+	assert.Equal(t, open, next)
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("open")))
+	assert.Equal(t, open, fsm.CurrentState())
+	assert.Equal(t, []string{"outer", "inner", "outer", "inner", "outer", "inner"}, order)
+}
@@ -0,0 +1,100 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+func handlerNotFound(kind string, name string) error {
+	return errors.New(fmt.Sprintf("%s %s not found", kind, name))
+}
+
+// HandlerRegistry maps names to actions and guards, so that a serialized `Definition` can reference
+// behaviours by name.
+// NOTE: actions and guards have separated namespaces, i.e., an action and a guard can share the same name.
+type HandlerRegistry struct {
+	actions map[string]func(interface{}, Event) error
+	guards  map[string]func(interface{}, Event) bool
+}
+
+func NewHandlerRegistry() *HandlerRegistry {
+	return &HandlerRegistry{
+		actions: make(map[string]func(interface{}, Event) error),
+		guards:  make(map[string]func(interface{}, Event) bool),
+	}
+}
+
+// RegisterAction binds the action to name. It returns AlreadyExists if the name has been registered.
+func (r *HandlerRegistry) RegisterAction(name string, action func(interface{}, Event) error) error {
+	if name == "" || action == nil {
+		return errors.New("action name and action should not be empty")
+	}
+	if _, ok := r.actions[name]; ok {
+		return AlreadyExists
+	}
+	r.actions[name] = action
+	return nil
+}
+
+// RegisterGuard binds the guard to name. It returns AlreadyExists if the name has been registered.
+func (r *HandlerRegistry) RegisterGuard(name string, guard func(interface{}, Event) bool) error {
+	if name == "" || guard == nil {
+		return errors.New("guard name and guard should not be empty")
+	}
+	if _, ok := r.guards[name]; ok {
+		return AlreadyExists
+	}
+	r.guards[name] = guard
+	return nil
+}
+
+// MustRegisterAction is the same as `RegisterAction` but panics on error. It returns the registry so
+// the registrations can be chained.
+func (r *HandlerRegistry) MustRegisterAction(name string, action func(interface{}, Event) error) *HandlerRegistry {
+	if err := r.RegisterAction(name, action); err != nil {
+		panic(fmt.Sprintf("register action %s: %v", name, err))
+	}
+	return r
+}
+
+// MustRegisterGuard is the same as `RegisterGuard` but panics on error. It returns the registry so
+// the registrations can be chained.
+func (r *HandlerRegistry) MustRegisterGuard(name string, guard func(interface{}, Event) bool) *HandlerRegistry {
+	if err := r.RegisterGuard(name, guard); err != nil {
+		panic(fmt.Sprintf("register guard %s: %v", name, err))
+	}
+	return r
+}
+
+// Action returns the action registered by name.
+func (r *HandlerRegistry) Action(name string) (func(interface{}, Event) error, bool) {
+	action, ok := r.actions[name]
+	return action, ok
+}
+
+// Guard returns the guard registered by name.
+func (r *HandlerRegistry) Guard(name string) (func(interface{}, Event) bool, bool) {
+	guard, ok := r.guards[name]
+	return guard, ok
+}
+
+// ActionNames returns the sorted names of all registered actions.
+func (r *HandlerRegistry) ActionNames() []string {
+	result := make([]string, 0, len(r.actions))
+	for name := range r.actions {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// GuardNames returns the sorted names of all registered guards.
+func (r *HandlerRegistry) GuardNames() []string {
+	result := make([]string, 0, len(r.guards))
+	for name := range r.guards {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,31 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestHandlerRegistry(t *testing.T) {
+	action := func(i interface{}, event Event) error { return nil }
+	guard := func(i interface{}, event Event) bool { return true }
+
+	registry := NewHandlerRegistry().
+		MustRegisterAction("b", action).
+		MustRegisterAction("a", action).
+		MustRegisterGuard("a", guard)
+	assert.Equal(t, []string{"a", "b"}, registry.ActionNames())
+	assert.Equal(t, []string{"a"}, registry.GuardNames())
+
+	assert.Equal(t, AlreadyExists, registry.RegisterAction("a", action))
+	assert.Equal(t, AlreadyExists, registry.RegisterGuard("a", guard))
+	assert.NotNil(t, registry.RegisterGuard("", guard))
+	assert.NotNil(t, registry.RegisterAction("c", nil))
+	assert.Panics(t, func() {
+		registry.MustRegisterGuard("a", guard)
+	})
+
+	_, ok := registry.Action("a")
+	assert.True(t, ok)
+	_, ok = registry.Guard("b")
+	assert.False(t, ok)
+}
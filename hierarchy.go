@@ -0,0 +1,262 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	shallowHistorySuffix = "/H"
+	deepHistorySuffix    = "/H*"
+)
+
+// HistoryState is the history pseudo-state of a composite state. The transitions targeting a history state
+// re-enter the composite state where it was left. See `ShallowHistory` and `DeepHistory`.
+type HistoryState struct {
+	Composite string
+	Deep      bool
+}
+
+// FSMStateID returns `<composite>/H` for shallow history and `<composite>/H*` for deep history.
+func (h HistoryState) FSMStateID() string {
+	if h.Deep {
+		return h.Composite + deepHistorySuffix
+	}
+	return h.Composite + shallowHistorySuffix
+}
+
+// parseHistoryID is the reverse of `HistoryState.FSMStateID`.
+func parseHistoryID(id string) (HistoryState, bool) {
+	if strings.HasSuffix(id, deepHistorySuffix) {
+		return HistoryState{Composite: strings.TrimSuffix(id, deepHistorySuffix), Deep: true}, true
+	}
+	if strings.HasSuffix(id, shallowHistorySuffix) {
+		return HistoryState{Composite: strings.TrimSuffix(id, shallowHistorySuffix)}, true
+	}
+	return HistoryState{}, false
+}
+
+func notCompositeState(state State) error {
+	return errors.New(fmt.Sprintf("state %s is not a composite state", state.FSMStateID()))
+}
+
+// AddChildState adds the new state `child` as a sub state of `parent`, which makes `parent` a composite state.
+// When the FSM is in the child state, it is in the parent state as well.
+//   - The transitions of the child state take priority. If the child state has no transition for an event,
+//     or all guards return false, the transitions of the parent state are evaluated, and so on.
+//   - The transitions targeting a composite state enter its initial child recursively, until a leaf state is
+//     reached. So `CurrentState` is always a leaf state, except the initial state of the FSM not
+//     started by `Start`.
+//   - The first child of a composite state is its initial child. See `SetInitialChild`.
+func (fsm *FSM) AddChildState(parent State, child State) error {
+	if !fsm.HasState(parent) {
+		return stateNotFound(parent)
+	}
+	if _, ok := fsm.histories[parent.FSMStateID()]; ok {
+		return errors.New("a history state cannot have child states")
+	}
+	if err := fsm.AddState(child); err != nil {
+		return err
+	}
+	parentID := parent.FSMStateID()
+	fsm.parents[child.FSMStateID()] = parentID
+	fsm.children[parentID] = append(fsm.children[parentID], child.FSMStateID())
+	if _, ok := fsm.initialChildren[parentID]; !ok {
+		fsm.initialChildren[parentID] = child.FSMStateID()
+	}
+	return nil
+}
+
+// SetInitialChild sets the child state entered when a transition targets the composite state `parent`.
+func (fsm *FSM) SetInitialChild(parent State, child State) error {
+	if err := fsm.checkMutable(); err != nil {
+		return err
+	}
+	if !fsm.HasState(child) {
+		return stateNotFound(child)
+	}
+	if fsm.parents[child.FSMStateID()] != parent.FSMStateID() {
+		return errors.New(fmt.Sprintf("state %s is not a child of state %s", child.FSMStateID(),
+			parent.FSMStateID()))
+	}
+	fsm.initialChildren[parent.FSMStateID()] = child.FSMStateID()
+	return nil
+}
+
+// Parent returns the composite state which contains `state`, or nil if `state` is a top level state.
+func (fsm *FSM) Parent(state State) State {
+	parent, ok := fsm.parents[state.FSMStateID()]
+	if !ok {
+		return nil
+	}
+	return fsm.states[parent]
+}
+
+// Children returns the child states of `state` in the order of adding.
+func (fsm *FSM) Children(state State) []State {
+	ids := fsm.children[state.FSMStateID()]
+	result := make([]State, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, fsm.states[id])
+	}
+	return result
+}
+
+// IsIn returns true if one of the current states is `state` or one of its descendants. See `CurrentStates`.
+// It can be invoked concurrently with `ProcessEvent`, like `StateRef`.
+func (fsm *FSM) IsIn(state State) bool {
+	fsm.curStateMu.RLock()
+	defer fsm.curStateMu.RUnlock()
+	return fsm.isInLeaves(fsm.committedLeaves(), state)
+}
+
+// isInLeaves returns true if one of the leaves is `state` or one of its descendants.
+func (fsm *FSM) isInLeaves(leaves []string, state State) bool {
+	for _, leaf := range leaves {
+		if fsm.isDescendant(leaf, state.FSMStateID()) {
+			return true
+		}
+	}
+	return false
+}
+
+// ShallowHistory returns the shallow history pseudo-state of the composite state. A transition targeting it
+// enters the child of `composite` which was active when the composite state was left last time. If that child
+// is a composite state, its initial child is entered. If the composite state has never been entered, its
+// initial child is entered.
+func (fsm *FSM) ShallowHistory(composite State) (State, error) {
+	return fsm.history(composite, false)
+}
+
+// DeepHistory returns the deep history pseudo-state of the composite state. It is like `ShallowHistory`, but
+// the leaf state which was active when the composite state was left last time is entered.
+func (fsm *FSM) DeepHistory(composite State) (State, error) {
+	return fsm.history(composite, true)
+}
+
+func (fsm *FSM) history(composite State, deep bool) (State, error) {
+	if len(fsm.children[composite.FSMStateID()]) == 0 {
+		return nil, notCompositeState(composite)
+	}
+	if fsm.parallel[composite.FSMStateID()] {
+		return nil, errors.New(fmt.Sprintf("the history states of parallel state %s are not supported",
+			composite.FSMStateID()))
+	}
+	h := HistoryState{Composite: composite.FSMStateID(), Deep: deep}
+	if _, ok := fsm.histories[h.FSMStateID()]; !ok {
+		if err := fsm.AddState(h); err != nil {
+			return nil, err
+		}
+		fsm.histories[h.FSMStateID()] = h
+	}
+	return h, nil
+}
+
+// resolveState returns the leaf state, or the parallel state, entered by a transition targeting state `id`.
+func (fsm *FSM) resolveState(id string) string {
+	for {
+		if h, ok := fsm.histories[id]; ok {
+			id = h.Composite
+			if h.Deep {
+				if leaf, ok := fsm.activeLeaves[id]; ok {
+					return leaf
+				}
+			} else if child, ok := fsm.activeChildren[id]; ok {
+				id = child
+			}
+			continue
+		}
+		child, ok := fsm.initialChildren[id]
+		if !ok || fsm.parallel[id] {
+			return id
+		}
+		id = child
+	}
+}
+
+// enter changes the current state by the transition from state `from` to state `target`. It returns the
+// previous and the new state of the FSM, or of the region if the transition is inside a region.
+func (fsm *FSM) enter(from string, target string) (prev string, next string) {
+	fsm.curStateMu.Lock()
+	defer fsm.curStateMu.Unlock()
+	fsm.version++
+	next = fsm.resolveState(target)
+	defer fsm.recordHistory(next)
+	if len(fsm.alarms) != 0 {
+		defer fsm.checkAlarms()
+	}
+	if region, ok := fsm.regionOf(fsm.curState, from); ok && fsm.isDescendant(next, region) {
+		prev = fsm.regionLeaf(region)
+		fsm.regionStates[region] = next
+		return prev, next
+	}
+	prev = fsm.curState
+	fsm.setCurState(next)
+	clear(fsm.regionStates)
+	if parallel, ok := fsm.parallelAncestor(next); ok && parallel != next {
+		fsm.setCurState(parallel)
+		region, _ := fsm.regionOf(parallel, next)
+		fsm.regionStates[region] = next
+	}
+	if !fsm.replaying {
+		fsm.stats.recordEntry(fsm.curState, fsm.clock.Now())
+	}
+	return prev, next
+}
+
+// isDescendant returns true if `state` is `ancestor` or inside `ancestor`.
+func (fsm *FSM) isDescendant(state string, ancestor string) bool {
+	for id, ok := state, true; ok; id, ok = fsm.parents[id] {
+		if id == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// recordHistory remembers the active child and leaf of all composite states containing `leaf`.
+func (fsm *FSM) recordHistory(leaf string) {
+	for child := leaf; ; {
+		parent, ok := fsm.parents[child]
+		if !ok {
+			return
+		}
+		fsm.activeChildren[parent] = child
+		fsm.activeLeaves[parent] = leaf
+		child = parent
+	}
+}
+
+// compositeDefinitions exports the composite states, the parents are exported before their children.
+func (fsm *FSM) compositeDefinitions() []CompositeDefinition {
+	depth := func(id string) int {
+		d := 0
+		for p, ok := fsm.parents[id]; ok; p, ok = fsm.parents[p] {
+			d++
+		}
+		return d
+	}
+	ids := make([]string, 0, len(fsm.children))
+	for id := range fsm.children {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		di, dj := depth(ids[i]), depth(ids[j])
+		if di != dj {
+			return di < dj
+		}
+		return ids[i] < ids[j]
+	})
+	result := make([]CompositeDefinition, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, CompositeDefinition{
+			State:    id,
+			Initial:  fsm.initialChildren[id],
+			Children: append([]string(nil), fsm.children[id]...),
+			Parallel: fsm.parallel[id],
+		})
+	}
+	return result
+}
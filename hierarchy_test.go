@@ -0,0 +1,81 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCompositeState(t *testing.T) {
+	fsm := newPlayerFSM(t)
+	assert.Equal(t, StringState("active"), fsm.Parent(StringState("playing")))
+	assert.Nil(t, fsm.Parent(StringState("active")))
+	assert.Equal(t, []State{StringState("video"), StringState("audio")}, fsm.Children(StringState("playing")))
+	assert.NotNil(t, fsm.SetInitialChild(StringState("active"), StringState("video")))
+	assert.NotNil(t, fsm.AddChildState(StringState("active"), StringState("video")))
+	_, err := fsm.ShallowHistory(StringState("stopped"))
+	assert.NotNil(t, err)
+
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("start")))
+	assert.Equal(t, StringState("video"), fsm.CurrentState())
+	assert.True(t, fsm.IsIn(StringState("playing")))
+	assert.True(t, fsm.IsIn(StringState("active")))
+	assert.False(t, fsm.IsIn(StringState("paused")))
+	assert.Equal(t, []string{"pause", "stop", "switch"}, fsm.AvailableEvents())
+	assert.True(t, fsm.CanFire(StringEvent("stop")))
+
+	// the transitions of composite states are inherited.
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("pause")))
+	assert.Equal(t, StringState("paused"), fsm.CurrentState())
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("stop")))
+	assert.Equal(t, StringState("stopped"), fsm.CurrentState())
+	assert.NotNil(t, fsm.ProcessEvent(StringEvent("pause")))
+
+	fsm.Unlock()
+	assert.Nil(t, fsm.SetInitialChild(StringState("playing"), StringState("audio")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("start")))
+	assert.Equal(t, StringState("audio"), fsm.CurrentState())
+}
+
+func TestHistoryState(t *testing.T) {
+	fsm := newPlayerFSM(t)
+	// without history, the initial child is entered.
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("resumeDeep")))
+	assert.Equal(t, StringState("video"), fsm.CurrentState())
+
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("switch")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("stop")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("resumeDeep")))
+	assert.Equal(t, StringState("audio"), fsm.CurrentState())
+
+	// shallow history resumes `playing`, and enters its initial child.
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("stop")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("resumeShallow")))
+	assert.Equal(t, StringState("video"), fsm.CurrentState())
+
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("pause")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("stop")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("resumeShallow")))
+	assert.Equal(t, StringState("paused"), fsm.CurrentState())
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("stop")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("start")))
+	assert.Equal(t, StringState("video"), fsm.CurrentState())
+}
+
+func TestCompositeStateDefinition(t *testing.T) {
+	fsm := newPlayerFSM(t)
+	def := fsm.Definition()
+	assert.NotContains(t, def.States, "active/H")
+	assert.Equal(t, []CompositeDefinition{
+		{State: "active", Initial: "playing", Children: []string{"playing", "paused"}},
+		{State: "playing", Initial: "video", Children: []string{"video", "audio"}},
+	}, def.Composites)
+
+	reloaded, err := NewFSMFromDefinition(def, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, def, reloaded.Definition())
+	assert.Nil(t, reloaded.ProcessEvent(StringEvent("start")))
+	assert.Nil(t, reloaded.ProcessEvent(StringEvent("switch")))
+	assert.Nil(t, reloaded.ProcessEvent(StringEvent("stop")))
+	assert.Nil(t, reloaded.ProcessEvent(StringEvent("resumeDeep")))
+	assert.Equal(t, StringState("audio"), reloaded.CurrentState())
+}
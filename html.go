@@ -0,0 +1,214 @@
+package fsm
+
+import (
+	"html/template"
+	"io"
+)
+
+// HTMLOptions configures the page of `ExportHTMLWithOptions`.
+type HTMLOptions struct {
+	// Title is the title of the page, the name of the FSM by default.
+	Title string
+	// StreamURL is the URL of the state changes streamed by `NewSSEHandler`. If it is not empty, the page
+	// highlights the current states live, otherwise it shows the states at the time of exporting.
+	StreamURL string
+}
+
+// htmlState and htmlTransition are the JSON model of the page.
+type htmlState struct {
+	ID        string `json:"id"`
+	Parent    string `json:"parent,omitempty"`
+	Composite bool   `json:"composite,omitempty"`
+	Active    bool   `json:"active,omitempty"`
+}
+
+type htmlTransition struct {
+	From  string `json:"from"`
+	Event string `json:"event"`
+	To    string `json:"to"`
+	Label string `json:"label"`
+}
+
+type htmlModel struct {
+	Title       string           `json:"title"`
+	Initial     string           `json:"initial"`
+	StreamURL   string           `json:"stream,omitempty"`
+	States      []htmlState      `json:"states"`
+	Transitions []htmlTransition `json:"transitions"`
+}
+
+// ExportHTML writes a standalone HTML page rendering the FSM, which can be panned by dragging and zoomed by the
+// mouse wheel, e.g., for sharing the workflow diagrams with non-engineers. The page has no external dependency.
+// See `ExportHTMLWithOptions` for the live updating pages.
+func (fsm *FSM) ExportHTML(w io.Writer) error {
+	return fsm.ExportHTMLWithOptions(w, HTMLOptions{})
+}
+
+// ExportHTMLWithOptions is the same as `ExportHTML`, but the page is configured by opts. The states are laid out
+// in columns by their distance from the initial state, and the current states are highlighted.
+func (fsm *FSM) ExportHTMLWithOptions(w io.Writer, opts HTMLOptions) error {
+	model := htmlModel{
+		Title:       opts.Title,
+		Initial:     fsm.initState,
+		StreamURL:   opts.StreamURL,
+		States:      make([]htmlState, 0, len(fsm.states)),
+		Transitions: make([]htmlTransition, 0),
+	}
+	if model.Title == "" {
+		model.Title = fsm.Name()
+	}
+	for _, state := range fsm.RenderModel().States {
+		if state.History {
+			continue
+		}
+		s := htmlState{ID: state.State.FSMStateID(), Composite: len(state.Children) != 0, Active: state.Active}
+		if state.Parent != nil {
+			s.Parent = state.Parent.FSMStateID()
+		}
+		model.States = append(model.States, s)
+	}
+	for _, info := range fsm.Transitions() {
+		model.Transitions = append(model.Transitions, htmlTransition{
+			From:  info.From.FSMStateID(),
+			Event: info.Event,
+			To:    info.To.FSMStateID(),
+			Label: transitionLabel(info.Event, info.Metadata),
+		})
+	}
+	return htmlPage.Execute(w, model)
+}
+
+var htmlPage = template.Must(template.New("fsm").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+html, body { margin: 0; height: 100%; font-family: sans-serif; }
+header { position: absolute; padding: 8px 12px; background: rgba(255, 255, 255, 0.8); }
+svg { width: 100%; height: 100%; cursor: grab; }
+.state rect { fill: #f5f7fa; stroke: #5b6b7f; stroke-width: 1.5; }
+.state.composite rect { stroke-dasharray: 4 3; }
+.state.active rect { fill: #ffe08a; stroke: #c08a00; stroke-width: 3; }
+.edge path { fill: none; stroke: #8795a8; stroke-width: 1.2; marker-end: url(#arrow); }
+text { font-size: 12px; text-anchor: middle; dominant-baseline: middle; }
+.edge text { fill: #46505e; font-size: 11px; }
+</style>
+</head>
+<body>
+<header><strong>{{.Title}}</strong> <span id="status"></span></header>
+<svg id="diagram">
+<defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto">
+<path d="M0,0 L10,5 L0,10 z" fill="#8795a8"></path></marker></defs>
+<g id="viewport"></g>
+</svg>
+<script>
+(function() {
+  var model = {{.}};
+  var svgNS = "http://www.w3.org/2000/svg";
+  var svg = document.getElementById("diagram"), viewport = document.getElementById("viewport");
+  var width = 140, height = 40, gapX = 120, gapY = 50;
+
+  // lay out the states in columns by their distance from the initial state.
+  var rank = {}, next = {}, queue = [model.initial];
+  model.transitions.forEach(function(t) { (next[t.from] = next[t.from] || []).push(t.to); });
+  model.states.forEach(function(s) {
+    if (s.parent) { (next[s.parent] = next[s.parent] || []).push(s.id); }
+  });
+  rank[model.initial] = 0;
+  while (queue.length) {
+    var id = queue.shift();
+    (next[id] || []).forEach(function(to) {
+      if (rank[to] === undefined) { rank[to] = rank[id] + 1; queue.push(to); }
+    });
+  }
+  var maxRank = 0;
+  Object.keys(rank).forEach(function(id) { maxRank = Math.max(maxRank, rank[id]); });
+  var rows = {}, pos = {};
+  model.states.forEach(function(s) {
+    var r = rank[s.id] === undefined ? maxRank + 1 : rank[s.id];
+    rows[r] = (rows[r] || 0) + 1;
+    pos[s.id] = {x: r * (width + gapX) + 20, y: (rows[r] - 1) * (height + gapY) + 60};
+  });
+
+  function element(name, attrs, parent) {
+    var e = document.createElementNS(svgNS, name);
+    Object.keys(attrs).forEach(function(k) { e.setAttribute(k, attrs[k]); });
+    parent.appendChild(e);
+    return e;
+  }
+
+  model.transitions.forEach(function(t) {
+    var a = pos[t.from], b = pos[t.to];
+    if (!a || !b) { return; }
+    var g = element("g", {"class": "edge"}, viewport), d, lx, ly;
+    if (t.from === t.to) {
+      d = "M" + (a.x + width - 20) + "," + a.y + " C" + (a.x + width + 30) + "," + (a.y - 40) + " " +
+        (a.x + width + 30) + "," + (a.y + height + 40) + " " + (a.x + width - 20) + "," + (a.y + height);
+      lx = a.x + width + 30; ly = a.y + height / 2;
+    } else {
+      var x1 = a.x + width / 2, y1 = a.y + height / 2, x2 = b.x + width / 2, y2 = b.y + height / 2;
+      // bend the edges, so the edges of opposite directions do not overlap.
+      var mx = (x1 + x2) / 2 + (y2 - y1) * 0.15, my = (y1 + y2) / 2 - (x2 - x1) * 0.15;
+      var dx = x2 - mx, dy = y2 - my, scale = Math.min(width / 2 / Math.abs(dx || 1), height / 2 / Math.abs(dy || 1));
+      d = "M" + x1 + "," + y1 + " Q" + mx + "," + my + " " + (x2 - dx * scale) + "," + (y2 - dy * scale);
+      lx = (x1 + 2 * mx + x2) / 4; ly = (y1 + 2 * my + y2) / 4 - 6;
+    }
+    element("path", {d: d}, g);
+    element("text", {x: lx, y: ly}, g).textContent = t.label;
+  });
+  var nodes = {}, parents = {};
+  model.states.forEach(function(s) {
+    parents[s.id] = s.parent;
+    var p = pos[s.id];
+    var g = element("g", {"class": "state" + (s.composite ? " composite" : "")}, viewport);
+    element("rect", {x: p.x, y: p.y, width: width, height: height, rx: 8}, g);
+    element("text", {x: p.x + width / 2, y: p.y + height / 2}, g).textContent = s.id;
+    element("title", {}, g).textContent = s.parent ? s.id + " in " + s.parent : s.id;
+    nodes[s.id] = g;
+  });
+
+  // highlight the current states and the composite states containing them.
+  function highlight(current) {
+    var active = {};
+    current.forEach(function(id) {
+      for (; id; id = parents[id]) { active[id] = true; }
+    });
+    model.states.forEach(function(s) { nodes[s.id].classList.toggle("active", !!active[s.id]); });
+  }
+  highlight(model.states.filter(function(s) { return s.active; }).map(function(s) { return s.id; }));
+
+  // pan by dragging, and zoom by the mouse wheel around the cursor.
+  var view = {x: 0, y: 0, k: 1}, drag = null;
+  function apply() {
+    viewport.setAttribute("transform", "translate(" + view.x + "," + view.y + ") scale(" + view.k + ")");
+  }
+  svg.addEventListener("mousedown", function(e) { drag = {x: e.clientX - view.x, y: e.clientY - view.y}; });
+  window.addEventListener("mouseup", function() { drag = null; });
+  window.addEventListener("mousemove", function(e) {
+    if (drag) { view.x = e.clientX - drag.x; view.y = e.clientY - drag.y; apply(); }
+  });
+  svg.addEventListener("wheel", function(e) {
+    e.preventDefault();
+    var k = Math.min(8, Math.max(0.1, view.k * Math.exp(-e.deltaY * 0.001)));
+    view.x = e.clientX - (e.clientX - view.x) * k / view.k;
+    view.y = e.clientY - (e.clientY - view.y) * k / view.k;
+    view.k = k;
+    apply();
+  }, {passive: false});
+
+  if (model.stream && window.EventSource) {
+    var status = document.getElementById("status"), source = new EventSource(model.stream);
+    source.addEventListener("state", function(e) { highlight(JSON.parse(e.data).current_states); });
+    source.addEventListener("transition", function(e) {
+      var change = JSON.parse(e.data);
+      status.textContent = change.from + " → " + change.to + " by " + change.event;
+      highlight([change.to]);
+    });
+    source.onerror = function() { status.textContent = "disconnected"; };
+  }
+})();
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,36 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestExportHTML(t *testing.T) {
+	machine := newPlayerFSM(t)
+	machine.SetName("player")
+	assert.Nil(t, machine.ProcessEvent(StringEvent("start")))
+	b := &strings.Builder{}
+	assert.Nil(t, machine.ExportHTML(b))
+	page := b.String()
+	assert.True(t, strings.HasPrefix(page, "<!DOCTYPE html>"))
+	assert.Contains(t, page, "<title>player</title>")
+	assert.Contains(t, page, `{"id":"video","parent":"playing","active":true}`)
+	assert.Contains(t, page, `{"id":"active","composite":true,"active":true}`)
+	assert.Contains(t, page, `{"from":"video","event":"switch","to":"audio","label":"switch"}`)
+	// the page is standalone, and the history pseudo-states are not rendered.
+	assert.NotContains(t, page, "<script src")
+	assert.NotContains(t, page, `"id":"active/H"`)
+	assert.NotContains(t, page, `"stream":`)
+}
+
+func TestExportHTMLWithOptions(t *testing.T) {
+	machine := NewFSM(StringState("</script><script>alert(1)</script>"), nil)
+	b := &strings.Builder{}
+	assert.Nil(t, machine.ExportHTMLWithOptions(b, HTMLOptions{Title: "a < b", StreamURL: "/machines/m/stream"}))
+	page := b.String()
+	assert.Contains(t, page, "<title>a &lt; b</title>")
+	assert.Contains(t, page, `"stream":"/machines/m/stream"`)
+	// the state ids are escaped in the script.
+	assert.NotContains(t, page, "<script>alert(1)")
+}
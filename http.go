@@ -0,0 +1,201 @@
+package fsm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// MachineStatus is the JSON form of the current state of a registered machine. See `NewHTTPHandler`.
+type MachineStatus struct {
+	Name string `json:"name"`
+	// CurrentState is empty, and CurrentStates is empty, if the machine is not started. See `FSM.Start`.
+	CurrentState  string   `json:"current_state"`
+	CurrentStates []string `json:"current_states"`
+	Version       uint64   `json:"version"`
+	// Definition is only returned by `GET /machines/{name}`.
+	Definition *Definition `json:"definition,omitempty"`
+}
+
+// EventRequest is the body of `POST /machines/{name}/events`. See `NewHTTPHandler`.
+type EventRequest struct {
+	Event string `json:"event"`
+	// Data is decoded into the event by the decoder of the handler. See `HTTPHandler.SetEventDecoder`.
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+type httpError struct {
+	Error string `json:"error"`
+}
+
+// HTTPHandler is an admin HTTP API of the machines in a `Registry`. See `NewHTTPHandler`.
+type HTTPHandler struct {
+	registry *Registry
+	// mu serializes the events processed by the default processor.
+	mu      sync.Mutex
+	decode  func(evID string, data json.RawMessage) (Event, error)
+	process func(ctx context.Context, fsm *FSM, ev Event) error
+}
+
+// NewHTTPHandler creates an HTTP handler exposing the machines of the registry. The responses are JSON, except
+// the diagrams.
+//   - GET /machines lists the `MachineStatus` of all machines, sorted by name.
+//   - GET /machines/{name} returns the `MachineStatus` of the machine with its definition.
+//   - POST /machines/{name}/events processes the `EventRequest` by the machine, and returns its
+//     `MachineStatus`. It responds 409 Conflict if the event is not processed.
+//   - GET /machines/{name}/diagram?format=dot|mermaid|plantuml|html returns the diagram, dot by default. The html
+//     page highlights the current states live, see `ExportHTML`.
+//   - GET /machines/{name}/stream streams the state changes of the machine. See `NewSSEHandler`.
+//
+// The paths are relative to the handler, use `http.StripPrefix` to mount it under a prefix.
+// NOTE: the events are processed by `ProcessEventContext` by default. If the machines are processed by others
+// concurrently, e.g., they are `QueuedFSM`s, use `SetEventProcessor` to process the events properly.
+func NewHTTPHandler(registry *Registry) *HTTPHandler {
+	h := &HTTPHandler{
+		registry: registry,
+		decode: func(evID string, _ json.RawMessage) (Event, error) {
+			return StringEvent(evID), nil
+		},
+	}
+	h.process = func(ctx context.Context, fsm *FSM, ev Event) error {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return fsm.ProcessEventContext(ctx, ev)
+	}
+	return h
+}
+
+// SetEventDecoder sets the decoder of the submitted events. By default, the events are `StringEvent`s and the
+// data is ignored.
+func (h *HTTPHandler) SetEventDecoder(decode func(evID string, data json.RawMessage) (Event, error)) {
+	h.decode = decode
+}
+
+// SetEventProcessor sets the function processing the submitted events. It may be invoked concurrently.
+func (h *HTTPHandler) SetEventProcessor(process func(ctx context.Context, fsm *FSM, ev Event) error) {
+	h.process = process
+}
+
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "machines" || len(parts) > 3 {
+		writeJSON(w, http.StatusNotFound, httpError{Error: "not found"})
+		return
+	}
+	if len(parts) == 1 {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		result := make([]MachineStatus, 0)
+		for _, fsm := range h.registry.snapshot() {
+			result = append(result, machineStatus(fsm))
+		}
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+	fsm, ok := h.registry.Get(parts[1])
+	if !ok {
+		writeJSON(w, http.StatusNotFound, httpError{Error: fmt.Sprintf("machine %s not found", parts[1])})
+		return
+	}
+	switch {
+	case len(parts) == 2:
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		status := machineStatus(fsm)
+		status.Definition = fsm.Definition()
+		writeJSON(w, http.StatusOK, status)
+	case parts[2] == "events":
+		if allowMethod(w, r, http.MethodPost) {
+			h.serveEvent(w, r, fsm)
+		}
+	case parts[2] == "diagram":
+		if allowMethod(w, r, http.MethodGet) {
+			serveDiagram(w, r, fsm)
+		}
+	case parts[2] == "stream":
+		NewSSEHandler(fsm).ServeHTTP(w, r)
+	default:
+		writeJSON(w, http.StatusNotFound, httpError{Error: "not found"})
+	}
+}
+
+func (h *HTTPHandler) serveEvent(w http.ResponseWriter, r *http.Request, fsm *FSM) {
+	var req EventRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, httpError{Error: fmt.Sprintf("invalid body: %v", err)})
+		return
+	}
+	if req.Event == "" {
+		writeJSON(w, http.StatusBadRequest, httpError{Error: "the event should not be empty"})
+		return
+	}
+	ev, err := h.decode(req.Event, req.Data)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, httpError{Error: fmt.Sprintf("decode event %s: %v", req.Event, err)})
+		return
+	}
+	if err := h.process(r.Context(), fsm, ev); err != nil {
+		writeJSON(w, http.StatusConflict, httpError{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, machineStatus(fsm))
+}
+
+func serveDiagram(w http.ResponseWriter, r *http.Request, fsm *FSM) {
+	var diagram, contentType string
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "dot":
+		diagram, contentType = fsm.DumpGraphviz(), "text/vnd.graphviz; charset=utf-8"
+	case "mermaid":
+		diagram, contentType = fsm.DumpMermaid(), "text/plain; charset=utf-8"
+	case "plantuml":
+		diagram, contentType = fsm.DumpPlantUML(), "text/plain; charset=utf-8"
+	case "html":
+		// the page is served by /machines/{name}/diagram, so the stream is relative to it.
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = fsm.ExportHTMLWithOptions(w, HTMLOptions{StreamURL: "stream"})
+		return
+	default:
+		writeJSON(w, http.StatusBadRequest, httpError{Error: fmt.Sprintf("unknown diagram format %s", format)})
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write([]byte(diagram))
+}
+
+func machineStatus(fsm *FSM) MachineStatus {
+	status := MachineStatus{
+		Name:          fsm.Name(),
+		CurrentState:  fsm.currentStateID(),
+		CurrentStates: make([]string, 0),
+		Version:       fsm.Version(),
+	}
+	for _, state := range fsm.CurrentStates() {
+		status.CurrentStates = append(status.CurrentStates, state.FSMStateID())
+	}
+	return status
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeJSON(w, http.StatusMethodNotAllowed, httpError{Error: fmt.Sprintf("method %s not allowed", r.Method)})
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		code, data = http.StatusInternalServerError, []byte(`{"error":"encode response"}`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(append(data, '\n'))
+}
@@ -0,0 +1,132 @@
+package fsm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newHTTPTestHandler(t *testing.T) (*HTTPHandler, *FSM) {
+	var (
+		on  = StringState("on")
+		off = StringState("off")
+	)
+	fsm := NewFSM(off, nil)
+	fsm.SetName("kitchen")
+	assert.Nil(t, fsm.AddState(on))
+	assert.Nil(t, fsm.AddEvent("switch"))
+	assert.Nil(t, fsm.AddTransition(off, "switch", on, nil, nil))
+	registry := NewRegistry()
+	assert.Nil(t, registry.Register(fsm))
+	return NewHTTPHandler(registry), fsm
+}
+
+func serve(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+func TestHTTPHandler(t *testing.T) {
+	h, _ := newHTTPTestHandler(t)
+
+	w := serve(h, http.MethodGet, "/machines", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, `[{"name":"kitchen","current_state":"off","current_states":["off"],"version":0}]`+"\n",
+		w.Body.String())
+
+	w = serve(h, http.MethodGet, "/machines/kitchen", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var status MachineStatus
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, "off", status.CurrentState)
+	assert.Equal(t, "off", status.Definition.Initial)
+
+	w = serve(h, http.MethodPost, "/machines/kitchen/events", `{"event":"switch"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, "on", status.CurrentState)
+	assert.Equal(t, uint64(1), status.Version)
+
+	w = serve(h, http.MethodPost, "/machines/kitchen/events", `{"event":"switch"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "no transition from state(on) and event(switch)")
+
+	w = serve(h, http.MethodGet, "/machines/kitchen/diagram", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "digraph")
+	w = serve(h, http.MethodGet, "/machines/kitchen/diagram?format=mermaid", "")
+	assert.True(t, strings.HasPrefix(w.Body.String(), "stateDiagram-v2\n"))
+	w = serve(h, http.MethodGet, "/machines/kitchen/diagram?format=plantuml", "")
+	assert.True(t, strings.HasPrefix(w.Body.String(), "@startuml\n"))
+	w = serve(h, http.MethodGet, "/machines/kitchen/diagram?format=html", "")
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `"stream":"stream"`)
+}
+
+func TestHTTPHandlerErrors(t *testing.T) {
+	h, _ := newHTTPTestHandler(t)
+	for _, c := range []struct {
+		method, target, body string
+		code                 int
+	}{
+		{http.MethodGet, "/", "", http.StatusNotFound},
+		{http.MethodGet, "/machines/unknown", "", http.StatusNotFound},
+		{http.MethodGet, "/machines/kitchen/unknown", "", http.StatusNotFound},
+		{http.MethodPost, "/machines", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/machines/kitchen/events", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/machines/kitchen/events", "{", http.StatusBadRequest},
+		{http.MethodPost, "/machines/kitchen/events", "{}", http.StatusBadRequest},
+		{http.MethodGet, "/machines/kitchen/diagram?format=svg", "", http.StatusBadRequest},
+	} {
+		w := serve(h, c.method, c.target, c.body)
+		assert.Equal(t, c.code, w.Code, c.target)
+		assert.Contains(t, w.Body.String(), `"error"`)
+	}
+}
+
+func TestHTTPHandlerEventProcessor(t *testing.T) {
+	h, fsm := newHTTPTestHandler(t)
+	type amountEvent struct {
+		Amount int `json:"amount"`
+	}
+	h.SetEventDecoder(func(evID string, data json.RawMessage) (Event, error) {
+		var ev amountEvent
+		if err := json.Unmarshal(data, &ev); err != nil {
+			return nil, err
+		}
+		return StringEvent(evID), nil
+	})
+	var processed []Event
+	h.SetEventProcessor(func(ctx context.Context, machine *FSM, ev Event) error {
+		assert.Equal(t, fsm, machine)
+		processed = append(processed, ev)
+		return errors.New("rejected")
+	})
+	w := serve(h, http.MethodPost, "/machines/kitchen/events", `{"event":"switch","data":"bad"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serve(h, http.MethodPost, "/machines/kitchen/events", `{"event":"switch","data":{"amount":1}}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, []Event{StringEvent("switch")}, processed)
+	assert.Equal(t, StringState("off"), fsm.CurrentState())
+}
+
+func TestHTTPHandlerNotStarted(t *testing.T) {
+	fsm := NewFSM(nil, nil)
+	fsm.SetName("kitchen")
+	assert.Nil(t, fsm.AddState(StringState("off")))
+	registry := NewRegistry()
+	assert.Nil(t, registry.Register(fsm))
+	w := serve(NewHTTPHandler(registry), http.MethodGet, "/machines/kitchen", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var status MachineStatus
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(t, "", status.CurrentState)
+	assert.Empty(t, status.CurrentStates)
+}
@@ -0,0 +1,156 @@
+package fsm
+
+// IndexedEvent is an `Event` which knows the index of its id, see `FSM.EventIndex`. The transitions of the indexed
+// events are looked up by slice indexing rather than hashing their ids, e.g., in the hot loops of protocol
+// parsers. If the index does not match the id, the event is looked up by its id.
+type IndexedEvent interface {
+	Event
+	FSMEventIndex() int
+}
+
+// IndexEvent is an `IndexedEvent` without data, see `FSM.IndexEvent`.
+type IndexEvent struct {
+	id    string
+	index int
+}
+
+func (e IndexEvent) FSMEventID() string {
+	return e.id
+}
+
+func (e IndexEvent) FSMEventIndex() int {
+	return e.index
+}
+
+// StateIndex returns the index of the state. The states are interned to small integers when they are added, the
+// initial state is 0 and the others follow the order of `AddState`. It returns false if the state is not added.
+func (fsm *FSM) StateIndex(state State) (int, bool) {
+	index, ok := fsm.stateIndex[state.FSMStateID()]
+	return index, ok
+}
+
+// EventIndex returns the index of the event id. The events are interned to small integers in the order of
+// `AddEvent`, starting from 0. It returns false if the event is not added.
+func (fsm *FSM) EventIndex(evID string) (int, bool) {
+	index, ok := fsm.events[evID]
+	return index, ok
+}
+
+// IndexEvent returns the indexed event of id, which can be processed repeatedly without hashing its id:
+//
+//	digit, _ := parser.IndexEvent("digit")
+//	for ... {
+//		err := parser.ProcessEvent(digit)
+//	}
+//
+// It returns false if the event is not added.
+func (fsm *FSM) IndexEvent(evID string) (IndexEvent, bool) {
+	index, ok := fsm.events[evID]
+	return IndexEvent{id: evID, index: index}, ok
+}
+
+// CurrentStateIndex returns the index of `CurrentState`. See `StateIndex`.
+func (fsm *FSM) CurrentStateIndex() int {
+	fsm.curStateMu.RLock()
+	defer fsm.curStateMu.RUnlock()
+	if fsm.committed != nil {
+		return fsm.stateIndex[fsm.committed.curState]
+	}
+	return fsm.curIndex
+}
+
+// internState assigns the next index to the state id.
+func (fsm *FSM) internState(id string) {
+	fsm.stateIndex[id] = len(fsm.stateIDs)
+	fsm.stateIDs = append(fsm.stateIDs, id)
+	fsm.table = append(fsm.table, nil)
+}
+
+// setCurState changes the current state, the caller should hold curStateMu.
+func (fsm *FSM) setCurState(id string) {
+	index, ok := fsm.stateIndex[id]
+	fsm.curState = id
+	fsm.curIndex = index
+	if fsm.committed != nil {
+		// the state ref is refreshed when the transaction ends, see `FSM.Transaction`.
+		return
+	}
+	if !ok {
+		// the FSM is not started.
+		fsm.stateRef.id.Store(new(string))
+		return
+	}
+	// the ids are only appended to stateIDs, so the element is not changed even if the slice grows.
+	fsm.stateRef.id.Store(&fsm.stateIDs[index])
+}
+
+// setTransitions replaces the transitions of the event evID from state `from`, in both the map and the table.
+func (fsm *FSM) setTransitions(from string, evID string, transList []*transition) {
+	if _, ok := fsm.transitions[from]; !ok {
+		fsm.transitions[from] = make(map[string][]*transition)
+	}
+	fsm.transitions[from][evID] = transList
+	if isWildcardEvent(evID) {
+		fsm.setWildcard(from, evID, transList)
+		return
+	}
+	ei, ok := fsm.events[evID]
+	if !ok {
+		// the completion transitions are only looked up by the map.
+		return
+	}
+	si := fsm.stateIndex[from]
+	for len(fsm.table[si]) <= ei {
+		fsm.table[si] = append(fsm.table[si], nil)
+	}
+	fsm.table[si][ei] = transList
+}
+
+// transitionsOf returns the transitions of ev from state `from`, followed by the wildcard transitions of its
+// namespaces and the matching transitions accepting ev. See `AddTransition` and `AddTransitionMatching`.
+func (fsm *FSM) transitionsOf(from string, ev Event) []*transition {
+	transList := fsm.exactTransitionsOf(from, ev)
+	if len(fsm.wildcards) != 0 {
+		transList = fsm.appendWildcards(from, ev.FSMEventID(), transList)
+	}
+	if len(fsm.matching) != 0 {
+		transList = fsm.appendMatching(from, ev, transList)
+	}
+	return transList
+}
+
+// exactTransitionsOf returns the transitions of ev from state `from`, by the compiled table after `Compile`.
+// Otherwise, the table is used if both the state and the event have known indexes, i.e., `from` is the current
+// state and ev is an `IndexedEvent`.
+func (fsm *FSM) exactTransitionsOf(from string, ev Event) []*transition {
+	if fsm.compiled != nil {
+		return fsm.compiledTransitions(from, ev)
+	}
+	if from == fsm.curState {
+		if ei, ok := fsm.eventIndexOf(ev); ok {
+			row := fsm.table[fsm.curIndex]
+			if ei >= len(row) {
+				return nil
+			}
+			return row[ei]
+		}
+	}
+	return fsm.transitions[from][ev.FSMEventID()]
+}
+
+// eventIndexOf returns the index of ev if it is an `IndexedEvent` with the right index.
+func (fsm *FSM) eventIndexOf(ev Event) (int, bool) {
+	var (
+		ei int
+		id string
+	)
+	// the assertion of the concrete type is cheaper than the one of the interface.
+	if e, ok := ev.(IndexEvent); ok {
+		ei, id = e.index, e.id
+	} else if e, ok := ev.(IndexedEvent); ok {
+		ei, id = e.FSMEventIndex(), e.FSMEventID()
+	} else {
+		return 0, false
+	}
+	return ei, ei >= 0 && ei < len(fsm.eventIDs) && fsm.eventIDs[ei] == id
+}
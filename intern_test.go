@@ -0,0 +1,81 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIntern(t *testing.T) {
+	var (
+		idle    = StringState("idle")
+		running = StringState("running")
+		paused  = StringState("paused")
+	)
+	fsm := NewFSM(idle, nil)
+	assert.Nil(t, fsm.AddState(running))
+	assert.Nil(t, fsm.AddState(paused))
+	for _, ev := range []string{"start", "pause", "resume"} {
+		assert.Nil(t, fsm.AddEvent(ev))
+	}
+	assert.Nil(t, fsm.AddTransition(idle, "start", running, nil, nil))
+	assert.Nil(t, fsm.AddTransition(running, "pause", paused, nil, nil))
+	assert.Nil(t, fsm.AddTransition(paused, "resume", running, nil, nil))
+
+	index, ok := fsm.StateIndex(paused)
+	assert.True(t, ok)
+	assert.Equal(t, 2, index)
+	_, ok = fsm.StateIndex(StringState("unknown"))
+	assert.False(t, ok)
+	index, ok = fsm.EventIndex("resume")
+	assert.True(t, ok)
+	assert.Equal(t, 2, index)
+	_, ok = fsm.IndexEvent("unknown")
+	assert.False(t, ok)
+
+	start, _ := fsm.IndexEvent("start")
+	pause, _ := fsm.IndexEvent("pause")
+	resume, _ := fsm.IndexEvent("resume")
+	assert.Equal(t, 0, fsm.CurrentStateIndex())
+	assert.NotNil(t, fsm.ProcessEvent(pause))
+	assert.Nil(t, fsm.ProcessEvent(start))
+	assert.Nil(t, fsm.ProcessEvent(pause))
+	assert.Equal(t, 2, fsm.CurrentStateIndex())
+	assert.Nil(t, fsm.ProcessEvent(resume))
+	assert.Equal(t, running, fsm.CurrentState())
+	assert.Equal(t, 1, fsm.CurrentStateIndex())
+
+	// the events with wrong indexes are looked up by their ids.
+	assert.Nil(t, fsm.ProcessEvent(IndexEvent{id: "pause", index: 0}))
+	assert.Nil(t, fsm.ProcessEvent(IndexEvent{id: "resume", index: 42}))
+	assert.Equal(t, running, fsm.CurrentState())
+}
+
+func TestInternHierarchy(t *testing.T) {
+	var (
+		on   = StringState("on")
+		low  = StringState("low")
+		high = StringState("high")
+		off  = StringState("off")
+	)
+	fsm := NewFSM(off, nil)
+	assert.Nil(t, fsm.AddState(on))
+	assert.Nil(t, fsm.AddChildState(on, low))
+	assert.Nil(t, fsm.AddChildState(on, high))
+	assert.Nil(t, fsm.AddEvent("power"))
+	assert.Nil(t, fsm.AddEvent("turbo"))
+	assert.Nil(t, fsm.AddTransition(off, "power", on, nil, nil))
+	assert.Nil(t, fsm.AddTransition(on, "power", off, nil, nil))
+	assert.Nil(t, fsm.AddTransition(low, "turbo", high, nil, nil))
+
+	power, _ := fsm.IndexEvent("power")
+	turbo, _ := fsm.IndexEvent("turbo")
+	assert.Nil(t, fsm.ProcessEvent(power))
+	assert.Equal(t, low, fsm.CurrentState())
+	index, _ := fsm.StateIndex(low)
+	assert.Equal(t, index, fsm.CurrentStateIndex())
+	assert.Nil(t, fsm.ProcessEvent(turbo))
+	// the transition of the parent state is found from the child state.
+	assert.Nil(t, fsm.ProcessEvent(power))
+	assert.Equal(t, off, fsm.CurrentState())
+	assert.Equal(t, 0, fsm.CurrentStateIndex())
+}
@@ -0,0 +1,45 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+)
+
+// InternalEventError is returned by `ProcessEvent` when an event posted by `PostInternal` fails.
+type InternalEventError struct {
+	Event Event
+	Err   error
+}
+
+func (e *InternalEventError) Error() string {
+	return fmt.Sprintf("internal event %s: %v", e.Event.FSMEventID(), e.Err)
+}
+
+func (e *InternalEventError) Unwrap() error {
+	return e.Err
+}
+
+// PostInternal posts an event from action/guard. The posted events are processed in FIFO order after the
+// current transition completes, before `ProcessEvent` returns. i.e., run-to-completion semantics.
+//   - If the action of current transition fails, the events posted by it are discarded.
+//   - If a posted event fails, the remaining posted events are discarded and `ProcessEvent` returns an
+//     `InternalEventError`. The transitions of former events are not reverted.
+//
+// It panics if it is not invoked during `ProcessEvent`.
+func (fsm *FSM) PostInternal(ev Event) {
+	if fsm.processEventInvokeCounter == 0 {
+		panic(PostInternalOutsideProcessingPanic)
+	}
+	fsm.internalEvents = append(fsm.internalEvents, ev)
+}
+
+func (fsm *FSM) processInternalEvents(ctx context.Context) error {
+	for len(fsm.internalEvents) != 0 {
+		ev := fsm.internalEvents[0]
+		fsm.internalEvents = fsm.internalEvents[1:]
+		if err := fsm.observedProcessEvent(ctx, ev); err != nil {
+			return &InternalEventError{Event: ev, Err: err}
+		}
+	}
+	return nil
+}
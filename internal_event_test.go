@@ -0,0 +1,66 @@
+package fsm
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPostInternal(t *testing.T) {
+	var (
+		idle      = StringState("idle")
+		fetching  = StringState("fetching")
+		parsing   = StringState("parsing")
+		done      = StringState("done")
+		parseFail = errors.New("parse failed")
+	)
+	fsm := NewFSM(idle, nil)
+	for _, s := range []State{fetching, parsing, done} {
+		assert.Nil(t, fsm.AddState(s))
+	}
+	for _, ev := range []string{"fetch", "fetched", "parsed"} {
+		assert.Nil(t, fsm.AddEvent(ev))
+	}
+	var parseErr error
+	assert.Nil(t, fsm.AddTransition(idle, "fetch", fetching, func(i interface{}, event Event) error {
+		fsm.PostInternal(StringEvent("fetched"))
+		fsm.PostInternal(StringEvent("parsed"))
+		return nil
+	}, nil))
+	assert.Nil(t, fsm.AddTransition(fetching, "fetched", parsing, nil, nil))
+	assert.Nil(t, fsm.AddTransition(parsing, "parsed", done, func(i interface{}, event Event) error {
+		return parseErr
+	}, nil))
+	assert.Nil(t, fsm.AddTransition(done, "fetch", idle, func(i interface{}, event Event) error {
+		fsm.PostInternal(StringEvent("fetched"))
+		return errors.New("cannot restart")
+	}, nil))
+
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("fetch")))
+	assert.Equal(t, done, fsm.CurrentState())
+
+	// the posted event of the failed action is discarded
+	assert.EqualError(t, fsm.ProcessEvent(StringEvent("fetch")), "cannot restart")
+	assert.Equal(t, done, fsm.CurrentState())
+
+	fsm = NewFSM(parsing, nil)
+	assert.Nil(t, fsm.AddState(done))
+	assert.Nil(t, fsm.AddEvent("parsed"))
+	assert.Nil(t, fsm.AddEvent("retry"))
+	assert.Nil(t, fsm.AddTransition(parsing, "retry", parsing, func(i interface{}, event Event) error {
+		fsm.PostInternal(StringEvent("parsed"))
+		return nil
+	}, nil))
+	assert.Nil(t, fsm.AddTransition(parsing, "parsed", done, func(i interface{}, event Event) error {
+		return parseFail
+	}, nil))
+	err := fsm.ProcessEvent(StringEvent("retry"))
+	var internalErr *InternalEventError
+	assert.True(t, errors.As(err, &internalErr))
+	assert.Equal(t, StringEvent("parsed"), internalErr.Event)
+	assert.True(t, errors.Is(err, parseFail))
+
+	assert.PanicsWithValue(t, PostInternalOutsideProcessingPanic, func() {
+		fsm.PostInternal(StringEvent("parsed"))
+	})
+}
@@ -0,0 +1,102 @@
+package fsm
+
+import "sort"
+
+// TransitionInfo is a read-only description of a transition. See `FSM.Transitions`.
+type TransitionInfo struct {
+	From      State
+	Event     string
+	To        State
+	HasGuard  bool
+	HasAction bool
+	Metadata  TransitionMetadata
+	// GuardName and ActionName are the registered handler names, or empty if unknown.
+	GuardName  string
+	ActionName string
+	// Choice is true if the transition is a branch of a choice. See `FSM.AddChoice`.
+	Choice bool
+	// Fork and Join are the target and source states of fork and join transitions. See `FSM.AddFork` and
+	// `FSM.AddJoin`.
+	Fork []State
+	Join []State
+	// Priority is the `TransitionOptions.Priority`.
+	Priority int
+	// Permissions are the `TransitionOptions.Permissions`.
+	Permissions []string
+}
+
+// States returns all states of the FSM, sorted by state id.
+func (fsm *FSM) States() []State {
+	result := make([]State, 0, len(fsm.states))
+	for _, id := range fsm.sortedStateIDs() {
+		result = append(result, fsm.states[id])
+	}
+	return result
+}
+
+// Events returns all event ids of the FSM in sorted order.
+func (fsm *FSM) Events() []string {
+	result := make([]string, 0, len(fsm.events))
+	for evID := range fsm.events {
+		result = append(result, evID)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Transitions returns all transitions of the FSM. The transitions are sorted by from state id and event id,
+// the transitions sharing the same from state and event keep the order of guard evaluation.
+func (fsm *FSM) Transitions() []TransitionInfo {
+	result := make([]TransitionInfo, 0)
+	for _, from := range fsm.sortedStateIDs() {
+		evTrans := fsm.transitions[from]
+		evIDs := make([]string, 0, len(evTrans))
+		for evID := range evTrans {
+			evIDs = append(evIDs, evID)
+		}
+		sort.Strings(evIDs)
+		for _, evID := range evIDs {
+			for _, t := range evTrans[evID] {
+				result = append(result, TransitionInfo{
+					From:      fsm.states[from],
+					Event:     evID,
+					To:        t.to,
+					HasGuard:  t.hasGuard,
+					HasAction: t.hasAction,
+					Metadata:  t.meta.clone(),
+
+					GuardName:  t.guardName,
+					ActionName: t.actionName,
+					Choice:     t.choice,
+					Fork:       fsm.statesOf(t.fork),
+					Join:       fsm.statesOf(t.join),
+					Priority:   t.priority,
+
+					Permissions: append([]string(nil), t.permissions...),
+				})
+			}
+		}
+	}
+	return result
+}
+
+// statesOf returns the states of ids, or nil if ids is empty.
+func (fsm *FSM) statesOf(ids []string) []State {
+	if len(ids) == 0 {
+		return nil
+	}
+	result := make([]State, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, fsm.states[id])
+	}
+	return result
+}
+
+func (fsm *FSM) sortedStateIDs() []string {
+	result := make([]string, 0, len(fsm.states))
+	for id := range fsm.states {
+		result = append(result, id)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,33 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIntrospection(t *testing.T) {
+	var (
+		on            = StringState("on")
+		off           = StringState("off")
+		triggerSwitch = StringEvent("switch")
+	)
+	fsm := NewFSM(off, nil)
+	assert.Nil(t, fsm.AddState(on))
+	assert.Nil(t, fsm.AddEvent(string(triggerSwitch)))
+	assert.Nil(t, fsm.AddEvent("reset"))
+	assert.Nil(t, fsm.AddTransition(off, string(triggerSwitch), on, func(i interface{}, event Event) error {
+		return nil
+	}, nil))
+	assert.Nil(t, fsm.AddTransition(on, string(triggerSwitch), off, nil, func(i interface{}, event Event) bool {
+		return true
+	}))
+	assert.Nil(t, fsm.AddTransition(on, "reset", off, nil, nil))
+
+	assert.Equal(t, []State{off, on}, fsm.States())
+	assert.Equal(t, []string{"reset", "switch"}, fsm.Events())
+	assert.Equal(t, []TransitionInfo{
+		{From: off, Event: "switch", To: on, HasAction: true},
+		{From: on, Event: "reset", To: off},
+		{From: on, Event: "switch", To: off, HasGuard: true},
+	}, fsm.Transitions())
+}
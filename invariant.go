@@ -0,0 +1,41 @@
+package fsm
+
+import "fmt"
+
+// InvariantViolation is returned by `ProcessEvent` when an invariant of `AddInvariant` fails after the action of
+// a transition. The state is not changed.
+type InvariantViolation struct {
+	From  State
+	To    State
+	Event Event
+	Err   error
+}
+
+func (e *InvariantViolation) Error() string {
+	return fmt.Sprintf("transition from state(%s) to state(%s) by event(%s) violates invariant: %v",
+		e.From.FSMStateID(), e.To.FSMStateID(), e.Event.FSMEventID(), e.Err)
+}
+
+func (e *InvariantViolation) Unwrap() error {
+	return e.Err
+}
+
+// AddInvariant adds an invariant of the payload, e.g., the balance of an account is not negative. The invariants
+// are checked in order after every successful action, and the transition fails with an `InvariantViolation` of
+// the first returned error, so the state is not changed, like a failed action. The observers see the violation as
+// the error of `Observer.ActionFinished`.
+// NOTE: the changes of the payload made by the action are not restored, use `Transaction` and `Compensate` or
+// make the action check the invariants before changing the payload.
+func (fsm *FSM) AddInvariant(invariant func(payload interface{}) error) {
+	fsm.invariants = append(fsm.invariants, invariant)
+}
+
+// checkInvariants returns the first violation of the invariants after the action of args.
+func (fsm *FSM) checkInvariants(args ActionHookArgs) error {
+	for _, invariant := range fsm.invariants {
+		if err := invariant(fsm.payload); err != nil {
+			return &InvariantViolation{From: args.FromState, To: args.ToState, Event: args.Event, Err: err}
+		}
+	}
+	return nil
+}
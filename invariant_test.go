@@ -0,0 +1,60 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type balanceSheet struct {
+	balance int
+}
+
+func TestInvariant(t *testing.T) {
+	account := &balanceSheet{balance: 10}
+	machine := NewFSM(StringState("open"), account)
+	_ = machine.AddState(StringState("settled"))
+	_ = machine.AddEvent("withdraw")
+	_ = machine.AddTransition(StringState("open"), "withdraw", StringState("settled"), func(payload interface{},
+		ev Event) error {
+		payload.(*balanceSheet).balance -= 15
+		return nil
+	}, nil)
+	errNegative := errors.New("negative balance")
+	machine.AddInvariant(func(payload interface{}) error {
+		if payload.(*balanceSheet).balance < 0 {
+			return errNegative
+		}
+		return nil
+	})
+	var observed error
+	machine.AddObserver(&actionErrorObserver{err: &observed})
+
+	err := machine.ProcessEvent(StringEvent("withdraw"))
+	var violation *InvariantViolation
+	if assert.True(t, errors.As(err, &violation)) {
+		assert.Equal(t, "open", violation.From.FSMStateID())
+		assert.Equal(t, "settled", violation.To.FSMStateID())
+		assert.True(t, errors.Is(err, errNegative))
+		assert.Equal(t, "transition from state(open) to state(settled) by event(withdraw) violates invariant: "+
+			"negative balance", err.Error())
+	}
+	assert.Equal(t, err, observed)
+	assert.Equal(t, "open", machine.CurrentState().FSMStateID())
+
+	account.balance = 20
+	assert.Nil(t, machine.ProcessEvent(StringEvent("withdraw")))
+	assert.Equal(t, "settled", machine.CurrentState().FSMStateID())
+}
+
+type actionErrorObserver struct {
+	NopObserver
+	err *error
+}
+
+func (o *actionErrorObserver) ActionFinished(_ context.Context, _ *FSM, _ ActionHookArgs, _ time.Duration,
+	err error) {
+	*o.err = err
+}
@@ -0,0 +1,46 @@
+package fsm
+
+import "errors"
+
+// ErrLocked is returned by the methods changing the definition of a FSM, e.g., `AddState`, `AddEvent` and
+// `AddTransition`, after the FSM starts processing events, until it is unlocked by `Unlock`.
+var ErrLocked = errors.New("the FSM is locked after processing events")
+
+// Unlock allows changing the definition of the FSM until it processes the next event, e.g., to reconfigure a
+// running machine. The FSM is locked by `ProcessEvent` and `Replay`, because the definition is read by the event
+// processing without locks, so changing it concurrently, e.g., with the goroutine of `QueuedFSM`, is a data race.
+// For the queued variants, the definition should be changed in `QueuedFSM.Do` or `PreemptiveFSM.Do`:
+//
+//	err := machine.Do(func(m *fsm.FSM) error {
+//		m.Unlock()
+//		return m.AddTransition(paused, "resume", running, nil, nil)
+//	})
+//
+// NOTE: a compiled FSM cannot be unlocked, see `Compile`. `SwapDefinition` replaces the definition of a locked FSM
+// as well.
+func (fsm *FSM) Unlock() {
+	fsm.locked.Store(false)
+}
+
+// Locked returns true if the definition of the FSM cannot be changed since it processes events. See `Unlock`.
+func (fsm *FSM) Locked() bool {
+	return fsm.locked.Load()
+}
+
+// lock locks the definition before processing events.
+func (fsm *FSM) lock() {
+	if !fsm.locked.Load() {
+		fsm.locked.Store(true)
+	}
+}
+
+// checkMutable returns the error if the definition of the FSM cannot be changed.
+func (fsm *FSM) checkMutable() error {
+	if fsm.compiled != nil {
+		return ErrCompiled
+	}
+	if fsm.locked.Load() {
+		return ErrLocked
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLock(t *testing.T) {
+	off, on := StringState("off"), StringState("on")
+	fsm := NewFSM(off, nil)
+	assert.Nil(t, fsm.AddState(on))
+	assert.Nil(t, fsm.AddEvent("switch"))
+	assert.Nil(t, fsm.AddTransition(off, "switch", on, nil, nil))
+	assert.False(t, fsm.Locked())
+
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("switch")))
+	assert.True(t, fsm.Locked())
+	assert.Equal(t, ErrLocked, fsm.AddState(StringState("broken")))
+	assert.Equal(t, ErrLocked, fsm.AddEvent("repair"))
+	assert.Equal(t, ErrLocked, fsm.AddTransition(on, "switch", off, nil, nil))
+	assert.Equal(t, ErrLocked, fsm.AddCompletionTransition(on, off, nil, nil))
+	assert.Equal(t, ErrLocked, fsm.RemoveTransition(off, "switch", 0))
+
+	// unlocked until the next event.
+	fsm.Unlock()
+	assert.Nil(t, fsm.AddTransition(on, "switch", off, nil, nil))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("switch")))
+	assert.Equal(t, off, fsm.CurrentState())
+	assert.Equal(t, ErrLocked, fsm.AddEvent("repair"))
+
+	// the compiled FSM cannot be unlocked.
+	fsm.Compile()
+	fsm.Unlock()
+	assert.Equal(t, ErrCompiled, fsm.AddEvent("repair"))
+}
+
+func TestLockByReplay(t *testing.T) {
+	fsm := NewFSM(StringState("off"), nil)
+	assert.Nil(t, fsm.AddEvent("switch"))
+	assert.Nil(t, fsm.AddTransition(StringState("off"), "switch", StringState("off"), nil, nil))
+	assert.Nil(t, fsm.Replay([]Event{StringEvent("switch")}))
+	assert.Equal(t, ErrLocked, fsm.AddState(StringState("on")))
+
+	// the FSM not started is not locked.
+	fsm = NewFSM(nil, nil)
+	assert.Equal(t, ErrNotStarted, fsm.ProcessEvent(StringEvent("switch")))
+	assert.Nil(t, fsm.AddState(StringState("off")))
+}
+
+func TestQueuedUnlock(t *testing.T) {
+	queued := NewQueuedFSM(StringState("off"), nil)
+	defer queued.Close()
+	assert.Nil(t, queued.AddState(StringState("on")))
+	assert.Nil(t, queued.AddEvent("switch"))
+	assert.Nil(t, queued.AddTransition(StringState("off"), "switch", StringState("on"), nil, nil))
+	assert.Nil(t, queued.ProcessEvent(StringEvent("switch")))
+	assert.Equal(t, ErrLocked, queued.AddTransition(StringState("on"), "switch", StringState("off"), nil, nil))
+
+	assert.Nil(t, queued.Do(func(fsm *FSM) error {
+		fsm.Unlock()
+		return fsm.AddTransition(StringState("on"), "switch", StringState("off"), nil, nil)
+	}))
+	assert.Nil(t, queued.ProcessEvent(StringEvent("switch")))
+	assert.Equal(t, StringState("off"), queued.CurrentState())
+}
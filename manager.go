@@ -0,0 +1,423 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// ErrManagerClosed is returned by `Manager` after it is closed.
+var ErrManagerClosed = errors.New("the manager is closed")
+
+const (
+	// DefaultManagerShards is the default number of shards of `Manager`.
+	DefaultManagerShards = 16
+	// DefaultManagerQueueSize is the default size of the request queue of each shard of `Manager`.
+	DefaultManagerQueueSize = 64
+)
+
+// ManagerOptions are the options of `NewManager`. The zero values are replaced by the defaults.
+type ManagerOptions[K comparable] struct {
+	// Shards is the number of worker goroutines, `DefaultManagerShards` by default. The machines of a shard are
+	// processed one by one.
+	Shards int
+	// QueueSize is the size of the request queue of each shard, `DefaultManagerQueueSize` by default.
+	QueueSize int
+	// IdleTimeout passivates the machines which process no event for the duration. The machines are never
+	// passivated by default.
+	IdleTimeout time.Duration
+	// OnPassivate is invoked before a machine is evicted, i.e., it is idle, evicted by `Manager.Evict`, or the
+	// manager is closed, e.g., to save its snapshot so the memory can be freed. If it returns an error, the idle
+	// machine is kept and passivated again later. See `persist.Passivator`.
+	OnPassivate func(key K, fsm *FSM) error
+	// OnActivate is invoked after a machine is created by the factory, e.g., to restore the snapshot saved by
+	// OnPassivate, so the passivated machines are re-activated transparently by their next events. If it returns
+	// an error, the machine is discarded and the error is returned to the caller.
+	OnActivate func(key K, fsm *FSM) error
+	// OnEvict is invoked after a machine is evicted. It is invoked by the worker goroutine of the shard, like the
+	// other hooks.
+	OnEvict func(key K, fsm *FSM)
+	// Hash maps the keys to shards. By default, the keys are hashed by FNV-1a of their `fmt.Sprint`.
+	Hash func(key K) uint64
+	// Clock measures the IdleTimeout, `SystemClock` by default.
+	Clock Clock
+	// Tenant maps the keys to tenants, e.g., the customers hosting their workflows, so the machines are
+	// partitioned by tenants. All keys are of the tenant "" by default. See `Manager.TenantKeys`.
+	Tenant func(key K) string
+	// TenantQuota limits the number of alive machines of a tenant, the machines over the quota fail to be created
+	// by `ErrTenantQuotaExceeded`. No limit if it returns 0 or is nil.
+	TenantQuota func(tenant string) int
+	// TenantRegistry returns the registry of a tenant, which resolves the actions and guards of the definitions
+	// swapped in the machines of the tenant, see `FSM.SetHandlerRegistry`. The registries of the machines are
+	// not changed if it returns nil or is nil.
+	TenantRegistry func(tenant string) *HandlerRegistry
+}
+
+// ManagerStats are the aggregate statistics of a `Manager`.
+type ManagerStats struct {
+	// Machines is the number of alive machines.
+	Machines int
+	// Created and Evicted are the numbers of machines created by the factory and evicted.
+	Created uint64
+	Evicted uint64
+	// PassivateErrors is the number of the errors returned by OnPassivate.
+	PassivateErrors uint64
+	// Processed and Errors are the numbers of processed events, and the ones which returned errors.
+	Processed uint64
+	Errors    uint64
+	// States counts the alive machines by the ids of their current states, the machines not started are not
+	// counted.
+	States map[string]int
+	// Tenants counts the alive machines by their tenants. See `ManagerOptions.Tenant`.
+	Tenants map[string]int
+}
+
+type managedFSM struct {
+	fsm      *FSM
+	tenant   string
+	lastUsed time.Time
+}
+
+type managerShard[K comparable] struct {
+	requests chan func(s *managerShard[K])
+	machines map[K]*managedFSM
+	stats    ManagerStats
+}
+
+// Manager owns one machine per key, e.g., an order id or a connection id. The machines are created lazily by
+// the factory, and distributed to shards by keys. Each shard has a worker goroutine processing the requests of
+// its machines one by one, so the machines are not accessed concurrently. It is thread-safe.
+// The machines can be partitioned by tenants, see `ManagerOptions.Tenant`.
+type Manager[K comparable] struct {
+	factory func(key K) (*FSM, error)
+	options ManagerOptions[K]
+	shards  []*managerShard[K]
+
+	mu     sync.RWMutex
+	closed bool
+	exitWG sync.WaitGroup
+
+	// swapMu serializes `SwapDefinition`. The definition and migrate of the last swap are applied to the created
+	// machines, they are written while the workers are paused.
+	swapMu     sync.Mutex
+	definition *Definition
+	migrate    func(oldState string) string
+	// tenantDefinitions are the definitions swapped by `SwapTenantDefinition`, they override the definition of
+	// the tenants. They are written while the workers are paused as well.
+	tenantDefinitions map[string]*tenantDefinition
+
+	// tenantMu guards tenantMachines, the numbers of alive machines by tenants, which are counted across shards.
+	tenantMu       sync.Mutex
+	tenantMachines map[string]int
+}
+
+// NewManager creates a manager whose machines are created by factory. The manager should be closed by `Close`.
+func NewManager[K comparable](factory func(key K) (*FSM, error), options ManagerOptions[K]) *Manager[K] {
+	if options.Shards <= 0 {
+		options.Shards = DefaultManagerShards
+	}
+	if options.QueueSize <= 0 {
+		options.QueueSize = DefaultManagerQueueSize
+	}
+	if options.Clock == nil {
+		options.Clock = SystemClock
+	}
+	if options.Hash == nil {
+		options.Hash = func(key K) uint64 {
+			h := fnv.New64a()
+			_, _ = fmt.Fprint(h, key)
+			return h.Sum64()
+		}
+	}
+	m := &Manager[K]{
+		factory:        factory,
+		options:        options,
+		shards:         make([]*managerShard[K], options.Shards),
+		tenantMachines: make(map[string]int),
+	}
+	for i := range m.shards {
+		m.shards[i] = &managerShard[K]{
+			requests: make(chan func(s *managerShard[K]), options.QueueSize),
+			machines: make(map[K]*managedFSM),
+		}
+		m.exitWG.Add(1)
+		go m.work(m.shards[i])
+	}
+	return m
+}
+
+func (m *Manager[K]) work(s *managerShard[K]) {
+	defer m.exitWG.Done()
+	var tick <-chan time.Time
+	if m.options.IdleTimeout > 0 {
+		ticker := m.options.Clock.NewTicker(m.options.IdleTimeout / 2)
+		defer ticker.Stop()
+		tick = ticker.C()
+	}
+	for {
+		select {
+		case request, ok := <-s.requests:
+			if !ok {
+				// the manager is closing, the machines failed to be passivated are dropped as well.
+				for key := range s.machines {
+					if err := m.evict(s, key); err != nil {
+						m.drop(s, key)
+					}
+				}
+				return
+			}
+			request(s)
+		case <-tick:
+			// the ticks may be dropped, so the idle time is measured by the current time rather than the tick.
+			now := m.options.Clock.Now()
+			for key, machine := range s.machines {
+				if now.Sub(machine.lastUsed) >= m.options.IdleTimeout {
+					_ = m.evict(s, key)
+				}
+			}
+		}
+	}
+}
+
+// evict passivates and drops the machine. The machine is kept if it fails to be passivated.
+func (m *Manager[K]) evict(s *managerShard[K], key K) error {
+	if m.options.OnPassivate != nil {
+		if err := m.options.OnPassivate(key, s.machines[key].fsm); err != nil {
+			s.stats.PassivateErrors++
+			return err
+		}
+	}
+	m.drop(s, key)
+	return nil
+}
+
+func (m *Manager[K]) drop(s *managerShard[K], key K) {
+	machine := s.machines[key]
+	delete(s.machines, key)
+	m.release(machine.tenant)
+	s.stats.Evicted++
+	if m.options.OnEvict != nil {
+		m.options.OnEvict(key, machine.fsm)
+	}
+}
+
+// do runs the request by the worker of the shard, and waits for it.
+func (m *Manager[K]) do(s *managerShard[K], request func(s *managerShard[K]) error) error {
+	m.mu.RLock()
+	if m.closed {
+		m.mu.RUnlock()
+		return ErrManagerClosed
+	}
+	done := make(chan error, 1)
+	s.requests <- func(s *managerShard[K]) {
+		done <- request(s)
+	}
+	m.mu.RUnlock()
+	return <-done
+}
+
+func (m *Manager[K]) shard(key K) *managerShard[K] {
+	return m.shards[m.options.Hash(key)%uint64(len(m.shards))]
+}
+
+// machine returns the machine of key, it is created if not found.
+func (m *Manager[K]) machine(s *managerShard[K], key K) (*managedFSM, error) {
+	if machine, ok := s.machines[key]; ok {
+		return machine, nil
+	}
+	tenant := m.TenantOf(key)
+	if err := m.reserve(tenant); err != nil {
+		return nil, err
+	}
+	fsm, err := m.activate(key, tenant)
+	if err != nil {
+		m.release(tenant)
+		return nil, err
+	}
+	machine := &managedFSM{fsm: fsm, tenant: tenant}
+	s.machines[key] = machine
+	s.stats.Created++
+	return machine, nil
+}
+
+// activate creates the machine of key by the factory, and applies the registry and definition of its tenant.
+func (m *Manager[K]) activate(key K, tenant string) (*FSM, error) {
+	fsm, err := m.factory(key)
+	if err != nil {
+		return nil, err
+	}
+	if m.options.TenantRegistry != nil {
+		if registry := m.options.TenantRegistry(tenant); registry != nil {
+			fsm.SetHandlerRegistry(registry)
+		}
+	}
+	if def, ok := m.tenantDefinitions[tenant]; ok {
+		if err := fsm.SwapDefinition(*def.definition, def.migrate); err != nil {
+			return nil, err
+		}
+	} else if m.definition != nil {
+		if err := fsm.SwapDefinition(*m.definition, m.migrate); err != nil {
+			return nil, err
+		}
+	}
+	if m.options.OnActivate != nil {
+		if err := m.options.OnActivate(key, fsm); err != nil {
+			return nil, err
+		}
+	}
+	return fsm, nil
+}
+
+func (m *Manager[K]) ProcessEvent(key K, ev Event) error {
+	return m.ProcessEventContext(context.Background(), key, ev)
+}
+
+// ProcessEventContext processes the event by the machine of key, which is created if it does not exist.
+// The error of the factory is returned if the machine fails to be created.
+func (m *Manager[K]) ProcessEventContext(ctx context.Context, key K, ev Event) error {
+	return m.do(m.shard(key), func(s *managerShard[K]) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		machine, err := m.machine(s, key)
+		if err != nil {
+			return err
+		}
+		machine.lastUsed = m.options.Clock.Now()
+		err = machine.fsm.ProcessEventContext(ctx, ev)
+		s.stats.Processed++
+		if err != nil {
+			s.stats.Errors++
+		}
+		return err
+	})
+}
+
+// Do invokes fn with the machine of key by the worker of its shard, so fn can read or modify the machine safely.
+// The machine is created if it does not exist. fn should not invoke the manager.
+func (m *Manager[K]) Do(key K, fn func(fsm *FSM) error) error {
+	return m.do(m.shard(key), func(s *managerShard[K]) error {
+		machine, err := m.machine(s, key)
+		if err != nil {
+			return err
+		}
+		machine.lastUsed = m.options.Clock.Now()
+		return fn(machine.fsm)
+	})
+}
+
+// SwapDefinition swaps the definition of all alive machines by `FSM.SwapDefinition` at once, e.g., when the
+// definition file is changed. The workers are paused during the swap, so no event is processed by the old
+// definition after it returns. If any machine fails to be swapped, the error is returned and no machine is
+// changed. After a successful swap, the machines created by the factory are swapped to newDef by migrate as well,
+// before `ManagerOptions.OnActivate`.
+// NOTE: the machines of the tenants swapped by `SwapTenantDefinition` keep their own definitions.
+func (m *Manager[K]) SwapDefinition(newDef Definition, migrate func(oldState string) string) error {
+	m.swapMu.Lock()
+	defer m.swapMu.Unlock()
+	resume, err := m.pause()
+	if err != nil {
+		return err
+	}
+	defer resume()
+	var commits []func()
+	for _, s := range m.shards {
+		for key, machine := range s.machines {
+			if _, ok := m.tenantDefinitions[machine.tenant]; ok {
+				continue
+			}
+			commit, err := machine.fsm.prepareSwap(newDef, migrate)
+			if err != nil {
+				return errors.New(fmt.Sprintf("failed to swap the definition of machine %v: %s", key, err.Error()))
+			}
+			commits = append(commits, commit)
+		}
+	}
+	for _, commit := range commits {
+		commit()
+	}
+	m.definition, m.migrate = &newDef, migrate
+	return nil
+}
+
+// pause parks the workers of all shards, so the machines can be accessed by the caller until the returned function
+// resumes the workers. The callers should hold swapMu, otherwise two callers may park a part of the shards each,
+// and wait for each other.
+func (m *Manager[K]) pause() (func(), error) {
+	m.mu.RLock()
+	if m.closed {
+		m.mu.RUnlock()
+		return nil, ErrManagerClosed
+	}
+	parked := make(chan struct{}, len(m.shards))
+	release := make(chan struct{})
+	for _, s := range m.shards {
+		s.requests <- func(*managerShard[K]) {
+			parked <- struct{}{}
+			<-release
+		}
+	}
+	m.mu.RUnlock()
+	for range m.shards {
+		<-parked
+	}
+	return func() {
+		close(release)
+	}, nil
+}
+
+// Evict passivates and evicts the machine of key. It returns false if the machine does not exist, or the error
+// of OnPassivate, in which case the machine is kept.
+func (m *Manager[K]) Evict(key K) (bool, error) {
+	found := false
+	err := m.do(m.shard(key), func(s *managerShard[K]) error {
+		if _, found = s.machines[key]; !found {
+			return nil
+		}
+		return m.evict(s, key)
+	})
+	return found && err == nil, err
+}
+
+// Stats returns the aggregate statistics of all shards.
+func (m *Manager[K]) Stats() ManagerStats {
+	result := ManagerStats{States: make(map[string]int), Tenants: make(map[string]int)}
+	for _, s := range m.shards {
+		_ = m.do(s, func(s *managerShard[K]) error {
+			result.Machines += len(s.machines)
+			result.Created += s.stats.Created
+			result.Evicted += s.stats.Evicted
+			result.PassivateErrors += s.stats.PassivateErrors
+			result.Processed += s.stats.Processed
+			result.Errors += s.stats.Errors
+			for _, machine := range s.machines {
+				if state := machine.fsm.currentStateID(); state != "" {
+					result.States[state]++
+				}
+				result.Tenants[machine.tenant]++
+			}
+			return nil
+		})
+	}
+	return result
+}
+
+// Close stops the workers after the queued requests are processed. The alive machines are passivated and
+// evicted, the ones failed to be passivated are evicted as well.
+func (m *Manager[K]) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	for _, s := range m.shards {
+		close(s.requests)
+	}
+	m.mu.Unlock()
+	m.exitWG.Wait()
+	return nil
+}
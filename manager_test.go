@@ -0,0 +1,191 @@
+package fsm
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newManagedLight(id int) (*FSM, error) {
+	if id < 0 {
+		return nil, errors.New("invalid id")
+	}
+	var (
+		on  = StringState("on")
+		off = StringState("off")
+	)
+	fsm := NewFSM(off, nil)
+	_ = fsm.AddState(on)
+	_ = fsm.AddEvent("switch")
+	_ = fsm.AddTransition(off, "switch", on, nil, nil)
+	_ = fsm.AddTransition(on, "switch", off, nil, nil)
+	return fsm, nil
+}
+
+func TestManager(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		evicted = make(map[int]State)
+	)
+	m := NewManager(newManagedLight, ManagerOptions[int]{
+		Shards: 4,
+		OnEvict: func(key int, fsm *FSM) {
+			mu.Lock()
+			defer mu.Unlock()
+			evicted[key] = fsm.CurrentState()
+		},
+	})
+
+	var wg sync.WaitGroup
+	for key := 0; key < 10; key++ {
+		wg.Add(1)
+		go func(key int) {
+			defer wg.Done()
+			// the even lights are switched on.
+			for i := 0; i <= key%2; i++ {
+				assert.Nil(t, m.ProcessEvent(key, StringEvent("switch")))
+			}
+		}(key)
+	}
+	wg.Wait()
+	assert.NotNil(t, m.ProcessEvent(0, StringEvent("unknown")))
+	assert.Equal(t, "invalid id", m.ProcessEvent(-1, StringEvent("switch")).Error())
+	assert.Equal(t, ManagerStats{
+		Machines:  10,
+		Created:   10,
+		Processed: 16,
+		Errors:    1,
+		States:    map[string]int{"on": 5, "off": 5},
+		Tenants:   map[string]int{"": 10},
+	}, m.Stats())
+
+	assert.Nil(t, m.Do(2, func(fsm *FSM) error {
+		assert.Equal(t, StringState("on"), fsm.CurrentState())
+		return nil
+	}))
+	evictedNow, err := m.Evict(2)
+	assert.True(t, evictedNow)
+	assert.Nil(t, err)
+	evictedNow, err = m.Evict(2)
+	assert.False(t, evictedNow)
+	assert.Nil(t, err)
+	assert.Equal(t, map[int]State{2: StringState("on")}, evicted)
+	assert.Nil(t, m.Do(2, func(fsm *FSM) error {
+		assert.Equal(t, StringState("off"), fsm.CurrentState())
+		return nil
+	}))
+
+	assert.Nil(t, m.Close())
+	assert.Nil(t, m.Close())
+	assert.Len(t, evicted, 10)
+	assert.Equal(t, ErrManagerClosed, m.ProcessEvent(0, StringEvent("switch")))
+}
+
+func TestManagerIdleTimeout(t *testing.T) {
+	evicted := make(chan string, 1)
+	m := NewManager(func(key string) (*FSM, error) {
+		return newManagedLight(0)
+	}, ManagerOptions[string]{
+		IdleTimeout: 10 * time.Millisecond,
+		OnEvict: func(key string, fsm *FSM) {
+			evicted <- key
+		},
+	})
+	defer m.Close()
+	assert.Nil(t, m.ProcessEvent("kitchen", StringEvent("switch")))
+	assert.Equal(t, "kitchen", <-evicted)
+	stats := m.Stats()
+	assert.Equal(t, 0, stats.Machines)
+	assert.Equal(t, uint64(1), stats.Evicted)
+}
+
+func TestManagerPassivation(t *testing.T) {
+	passivated := make(map[string]State)
+	m := NewManager(func(key string) (*FSM, error) {
+		return newManagedLight(0)
+	}, ManagerOptions[string]{
+		OnPassivate: func(key string, fsm *FSM) error {
+			passivated[key] = fsm.CurrentState()
+			return nil
+		},
+		OnActivate: func(key string, fsm *FSM) error {
+			if key == "broken" {
+				return errors.New("broken")
+			}
+			if state, ok := passivated[key]; ok {
+				return fsm.Restore([]State{state}, 1)
+			}
+			return nil
+		},
+	})
+	defer m.Close()
+	assert.Nil(t, m.ProcessEvent("kitchen", StringEvent("switch")))
+	evicted, err := m.Evict("kitchen")
+	assert.True(t, evicted)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]State{"kitchen": StringState("on")}, passivated)
+	assert.Nil(t, m.ProcessEvent("kitchen", StringEvent("switch")))
+	assert.Nil(t, m.Do("kitchen", func(fsm *FSM) error {
+		assert.Equal(t, StringState("off"), fsm.CurrentState())
+		assert.Equal(t, uint64(2), fsm.Version())
+		return nil
+	}))
+
+	assert.Equal(t, "broken", m.ProcessEvent("broken", StringEvent("switch")).Error())
+	assert.Equal(t, 1, m.Stats().Machines)
+}
+
+func TestManagerSwapDefinition(t *testing.T) {
+	m := NewManager(newManagedLight, ManagerOptions[int]{Shards: 4})
+	for key := 0; key < 8; key++ {
+		if key%2 == 1 {
+			assert.Nil(t, m.ProcessEvent(key, StringEvent("switch")))
+		} else {
+			assert.Nil(t, m.Do(key, func(*FSM) error { return nil }))
+		}
+	}
+	// the light gains a dimmed state between off and on.
+	def := Definition{
+		Initial: "off",
+		States:  []string{"off", "dimmed", "bright"},
+		Events:  []string{"switch"},
+		Transitions: []TransitionDefinition{
+			{From: "off", Event: "switch", To: "dimmed"},
+			{From: "dimmed", Event: "switch", To: "bright"},
+			{From: "bright", Event: "switch", To: "off"},
+		},
+	}
+	// "on" is not migrated, so no machine is swapped.
+	assert.NotNil(t, m.SwapDefinition(def, nil))
+	assert.Equal(t, map[string]int{"off": 4, "on": 4}, m.Stats().States)
+
+	assert.Nil(t, m.SwapDefinition(def, func(state string) string {
+		if state == "on" {
+			return "bright"
+		}
+		return state
+	}))
+	assert.Equal(t, map[string]int{"off": 4, "bright": 4}, m.Stats().States)
+	assert.Nil(t, m.ProcessEvent(0, StringEvent("switch")))
+	// the created machines are swapped as well.
+	assert.Nil(t, m.ProcessEvent(100, StringEvent("switch")))
+	assert.Equal(t, map[string]int{"off": 3, "dimmed": 2, "bright": 4}, m.Stats().States)
+
+	assert.Nil(t, m.Close())
+	assert.Equal(t, ErrManagerClosed, m.SwapDefinition(def, nil))
+}
+
+func TestManagerNotStarted(t *testing.T) {
+	m := NewManager(func(key int) (*FSM, error) {
+		fsm := NewFSM(nil, nil)
+		_ = fsm.AddState(StringState("off"))
+		return fsm, nil
+	}, ManagerOptions[int]{Shards: 2})
+	defer m.Close()
+	assert.Equal(t, ErrNotStarted, m.ProcessEvent(1, StringEvent("switch")))
+	stats := m.Stats()
+	assert.Equal(t, 1, stats.Machines)
+	assert.Empty(t, stats.States)
+}
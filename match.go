@@ -0,0 +1,66 @@
+package fsm
+
+import "errors"
+
+// matchingTransition is a transition fired by the events accepted by match. See `AddTransitionMatching`.
+type matchingTransition struct {
+	match func(Event) bool
+	t     *transition
+}
+
+// AddTransitionMatching adds a transition from state `from` to state `to` fired by any event accepted by match,
+// e.g., the events whose ids have prefix "error.", instead of the events of an exact id. It is useful for the
+// protocol gateways handling families of events.
+//   - The matching transitions of a state are evaluated after its exact-ID and wildcard transitions, in the order
+//     they are added, and the first matching one is fired. The transitions of child states still take priority
+//     over their parents, so a matching transition of a child state takes priority over the exact-ID ones of its
+//     parents. See `AddTransition`.
+//   - The events need not be added by `AddEvent`.
+//   - match is invoked for the candidate events, so it should be cheap and have no side effects.
+//
+// NOTE: the matching transitions are not part of `Definition`, so they are removed by `SwapDefinition`, and their
+// events are not listed by `AvailableEvents`.
+func (fsm *FSM) AddTransitionMatching(from State, match func(Event) bool, to State,
+	action func(interface{}, Event) error) error {
+	if err := fsm.checkMutable(); err != nil {
+		return err
+	}
+	if !fsm.HasState(from) {
+		return stateNotFound(from)
+	}
+	if !fsm.HasState(to) {
+		return stateNotFound(to)
+	}
+	if match == nil {
+		return errors.New("the match of a transition should not be nil")
+	}
+	fsm.transitionSeq++
+	t := fsm.newTransition(to, action, nil, TransitionOptions{}, false)
+	t.seq = fsm.transitionSeq
+	if fsm.matching == nil {
+		fsm.matching = make(map[string][]matchingTransition)
+	}
+	fromID := from.FSMStateID()
+	fsm.matching[fromID] = append(fsm.matching[fromID], matchingTransition{match: match, t: t})
+	return nil
+}
+
+// appendMatching returns transList followed by the matching transitions of state `from` accepting ev. transList
+// is not modified.
+func (fsm *FSM) appendMatching(from string, ev Event, transList []*transition) []*transition {
+	var result []*transition
+	for _, m := range fsm.matching[from] {
+		if !m.match(ev) {
+			continue
+		}
+		if result == nil {
+			result = make([]*transition, len(transList), len(transList)+1)
+			copy(result, transList)
+		}
+		result = append(result, m.t)
+	}
+	if result == nil {
+		return transList
+	}
+	return result
+}
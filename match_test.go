@@ -0,0 +1,83 @@
+package fsm
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func errorEvents(ev Event) bool {
+	return strings.HasPrefix(ev.FSMEventID(), "error.")
+}
+
+func TestAddTransitionMatching(t *testing.T) {
+	machine := NewFSM(StringState("connected"), nil)
+	assert.Nil(t, machine.AddState(StringState("degraded")))
+	assert.Nil(t, machine.AddState(StringState("closed")))
+	assert.Nil(t, machine.AddEvent("error.fatal"))
+	var handled []string
+	assert.Nil(t, machine.AddTransition(StringState("connected"), "error.fatal", StringState("closed"), nil, nil))
+	assert.Nil(t, machine.AddTransitionMatching(StringState("connected"), errorEvents, StringState("degraded"),
+		func(payload interface{}, ev Event) error {
+			handled = append(handled, ev.FSMEventID())
+			return nil
+		}))
+	assert.Nil(t, machine.AddTransitionMatching(StringState("degraded"), func(Event) bool { return true },
+		StringState("degraded"), nil))
+
+	// the events need not be added.
+	assert.True(t, machine.CanFire(StringEvent("error.timeout")))
+	assert.False(t, machine.CanFire(StringEvent("data")))
+	assert.Nil(t, machine.ProcessEvent(StringEvent("error.timeout")))
+	assert.Equal(t, "degraded", machine.CurrentState().FSMStateID())
+	assert.Equal(t, []string{"error.timeout"}, handled)
+	assert.Nil(t, machine.ProcessEvent(StringEvent("data")))
+	assert.Equal(t, "degraded", machine.CurrentState().FSMStateID())
+}
+
+func TestAddTransitionMatchingOrder(t *testing.T) {
+	machine := NewFSM(StringState("connected"), nil)
+	assert.Nil(t, machine.AddState(StringState("degraded")))
+	assert.Nil(t, machine.AddState(StringState("closed")))
+	assert.Nil(t, machine.AddEvent("error.fatal"))
+	assert.Nil(t, machine.AddTransition(StringState("connected"), "error.fatal", StringState("closed"), nil,
+		func(interface{}, Event) bool { return false }))
+	assert.Nil(t, machine.AddTransition(StringState("connected"), "error.fatal", StringState("closed"), nil, nil))
+	assert.Nil(t, machine.AddTransitionMatching(StringState("connected"), errorEvents, StringState("degraded"), nil))
+
+	// the exact-ID transitions are evaluated first.
+	next, err := machine.Simulate(StringEvent("error.fatal"))
+	assert.Nil(t, err)
+	assert.Equal(t, "closed", next.FSMStateID())
+	// the exact-ID transitions are not modified by the matching ones.
+	assert.Len(t, machine.TransitionMetadata(StringState("connected"), "error.fatal"), 2)
+	assert.Nil(t, machine.ProcessEvent(StringEvent("error.fatal")))
+	assert.Equal(t, "closed", machine.CurrentState().FSMStateID())
+}
+
+func TestAddTransitionMatchingHierarchy(t *testing.T) {
+	machine := NewFSM(StringState("session"), nil)
+	assert.Nil(t, machine.AddState(StringState("closed")))
+	assert.Nil(t, machine.AddState(StringState("retrying")))
+	assert.Nil(t, machine.AddChildState(StringState("session"), StringState("active")))
+	assert.Nil(t, machine.AddEvent("error.reset"))
+	assert.Nil(t, machine.AddTransition(StringState("session"), "error.reset", StringState("closed"), nil, nil))
+	assert.Nil(t, machine.AddTransitionMatching(StringState("active"), errorEvents, StringState("retrying"), nil))
+	assert.Nil(t, machine.Start(StringState("session")))
+	assert.Equal(t, "active", machine.CurrentState().FSMStateID())
+
+	// the matching transitions of child states take priority over the exact-ID ones of their parents.
+	assert.Nil(t, machine.ProcessEvent(StringEvent("error.reset")))
+	assert.Equal(t, "retrying", machine.CurrentState().FSMStateID())
+}
+
+func TestAddTransitionMatchingErrors(t *testing.T) {
+	machine := NewFSM(StringState("connected"), nil)
+	assert.NotNil(t, machine.AddTransitionMatching(StringState("connected"), errorEvents, StringState("unknown"), nil))
+	assert.NotNil(t, machine.AddTransitionMatching(StringState("unknown"), errorEvents, StringState("connected"), nil))
+	assert.NotNil(t, machine.AddTransitionMatching(StringState("connected"), nil, StringState("connected"), nil))
+	machine.lock()
+	assert.True(t, errors.Is(machine.AddTransitionMatching(StringState("connected"), errorEvents,
+		StringState("connected"), nil), ErrLocked))
+}
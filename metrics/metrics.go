@@ -0,0 +1,185 @@
+// Package metrics exports Prometheus metrics of FSMs.
+//
+//	m := metrics.New("myapp")
+//	prometheus.MustRegister(m)
+//	machine.AddObserver(m.Observer("order-1234"))
+//
+// The `machine` label of all metrics is the name given to `Observer`. The metrics of the tenants of a multi-tenant
+// `fsm.Manager` are isolated by the constant labels of `NewWithLabels`.
+package metrics
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/reyoung/fsm"
+	"time"
+)
+
+// Metrics is a prometheus.Collector holding the metrics of all observed machines.
+type Metrics struct {
+	transitions     *prometheus.CounterVec
+	actionErrors    *prometheus.CounterVec
+	guardRejections *prometheus.CounterVec
+	currentState    *prometheus.GaugeVec
+	actionDuration  *prometheus.HistogramVec
+	stateEntries    *prometheus.CounterVec
+	stateDwell      *prometheus.HistogramVec
+}
+
+// DwellBuckets are the buckets of the `state_dwell_seconds` histogram, from a second to a week, since the
+// workflows may stay in the states for days.
+var DwellBuckets = []float64{1, 10, 60, 600, 3600, 6 * 3600, 24 * 3600, 7 * 24 * 3600}
+
+// New creates the metrics with the given namespace. The metrics are in the subsystem `fsm`.
+func New(namespace string) *Metrics {
+	return NewWithLabels(namespace, nil)
+}
+
+// NewWithLabels is the same as `New`, but the metrics have the constant labels, e.g., the tenant of the machines
+// managed by a multi-tenant `fsm.Manager`. The metrics of the tenants are registered to the same registry:
+//
+//	acme := metrics.NewWithLabels("myapp", prometheus.Labels{"tenant": "acme"})
+//	prometheus.MustRegister(acme)
+func NewWithLabels(namespace string, labels prometheus.Labels) *Metrics {
+	edgeLabels := []string{"machine", "from", "event", "to"}
+	return &Metrics{
+		transitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "fsm",
+			ConstLabels: labels,
+			Name:        "transitions_total",
+			Help:        "The number of succeeded transitions.",
+		}, edgeLabels),
+		actionErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "fsm",
+			ConstLabels: labels,
+			Name:        "action_errors_total",
+			Help:        "The number of transitions whose action returned an error.",
+		}, edgeLabels),
+		guardRejections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "fsm",
+			ConstLabels: labels,
+			Name:        "guard_rejections_total",
+			Help:        "The number of candidate transitions rejected by guards.",
+		}, edgeLabels),
+		currentState: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "fsm",
+			ConstLabels: labels,
+			Name:        "current_state",
+			Help:        "1 if the machine is in the state, otherwise 0.",
+		}, []string{"machine", "state"}),
+		actionDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   "fsm",
+			ConstLabels: labels,
+			Name:        "action_duration_seconds",
+			Help:        "The duration of transition actions.",
+			Buckets:     prometheus.DefBuckets,
+		}, edgeLabels),
+		stateEntries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "fsm",
+			ConstLabels: labels,
+			Name:        "state_entries_total",
+			Help:        "The number of times the state was entered.",
+		}, []string{"machine", "state"}),
+		stateDwell: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   "fsm",
+			ConstLabels: labels,
+			Name:        "state_dwell_seconds",
+			Help:        "The time spent in the state before leaving it.",
+			Buckets:     DwellBuckets,
+		}, []string{"machine", "state"}),
+	}
+}
+
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.transitions, m.actionErrors, m.guardRejections, m.currentState,
+		m.actionDuration, m.stateEntries, m.stateDwell}
+}
+
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range m.collectors() {
+		c.Describe(ch)
+	}
+}
+
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range m.collectors() {
+		c.Collect(ch)
+	}
+}
+
+// Observer returns a `fsm.Observer` which records the metrics of a machine with the `machine` label. The current
+// state is entered if an event takes transitions, see `fsm.FSM.Version`, so the states passed by the completion
+// transitions of the event are not counted. The dwell time is measured by the clock of the machine.
+func (m *Metrics) Observer(machine string) fsm.Observer {
+	return &observer{metrics: m, machine: machine}
+}
+
+type observer struct {
+	fsm.NopObserver
+	metrics *Metrics
+	machine string
+	// the state reported as current, empty before the first event.
+	state string
+	// the version of the machine when the state was entered since. See `fsm.FSM.Version`.
+	version uint64
+	since   time.Time
+}
+
+func (o *observer) EventStarted(ctx context.Context, machine *fsm.FSM, ev fsm.Event) {
+	o.setState(machine)
+}
+
+func (o *observer) GuardRejected(ctx context.Context, machine *fsm.FSM, args fsm.ActionHookArgs) {
+	o.metrics.guardRejections.WithLabelValues(o.edgeLabels(args)...).Inc()
+}
+
+func (o *observer) ActionFinished(ctx context.Context, machine *fsm.FSM, args fsm.ActionHookArgs,
+	duration time.Duration, err error) {
+	labels := o.edgeLabels(args)
+	o.metrics.actionDuration.WithLabelValues(labels...).Observe(duration.Seconds())
+	if err != nil {
+		o.metrics.actionErrors.WithLabelValues(labels...).Inc()
+	} else {
+		o.metrics.transitions.WithLabelValues(labels...).Inc()
+	}
+}
+
+func (o *observer) EventFinished(ctx context.Context, machine *fsm.FSM, ev fsm.Event, err error) {
+	o.setState(machine)
+}
+
+func (o *observer) setState(machine *fsm.FSM) {
+	current := machine.CurrentState()
+	if current == nil {
+		// the machine is not started, see `fsm.FSM.Start`.
+		return
+	}
+	state, version, now := current.FSMStateID(), machine.Version(), machine.Clock().Now()
+	if o.state != "" && version != o.version {
+		// the state is entered again by a self-transition if it is not changed.
+		o.metrics.stateDwell.WithLabelValues(o.machine, o.state).Observe(now.Sub(o.since).Seconds())
+		o.metrics.stateEntries.WithLabelValues(o.machine, state).Inc()
+	}
+	if o.state == "" || version != o.version {
+		o.version, o.since = version, now
+	}
+	if state == o.state {
+		return
+	}
+	if o.state != "" {
+		o.metrics.currentState.WithLabelValues(o.machine, o.state).Set(0)
+	}
+	o.metrics.currentState.WithLabelValues(o.machine, state).Set(1)
+	o.state = state
+}
+
+func (o *observer) edgeLabels(args fsm.ActionHookArgs) []string {
+	return []string{o.machine, args.FromState.FSMStateID(), args.Event.FSMEventID(), args.ToState.FSMStateID()}
+}
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/reyoung/fsm"
+	"github.com/reyoung/fsm/fsmtest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	var (
+		on  = fsm.StringState("on")
+		off = fsm.StringState("off")
+	)
+	machine := fsm.NewFSM(off, nil)
+	assert.Nil(t, machine.AddState(on))
+	assert.Nil(t, machine.AddEvent("switch"))
+	assert.Nil(t, machine.AddTransition(off, "switch", off, nil, func(i interface{}, event fsm.Event) bool {
+		return false
+	}))
+	assert.Nil(t, machine.AddTransition(off, "switch", on, nil, nil))
+	assert.Nil(t, machine.AddTransition(on, "switch", off, func(i interface{}, event fsm.Event) error {
+		return errors.New("stuck")
+	}, nil))
+	m := New("test")
+	machine.AddObserver(m.Observer("light"))
+
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent("switch")))
+	assert.NotNil(t, machine.ProcessEvent(fsm.StringEvent("switch")))
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.transitions.WithLabelValues("light", "off", "switch", "on")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.guardRejections.WithLabelValues("light", "off", "switch", "off")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.actionErrors.WithLabelValues("light", "on", "switch", "off")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.currentState.WithLabelValues("light", "on")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.currentState.WithLabelValues("light", "off")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.actionDuration))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.stateEntries.WithLabelValues("light", "on")))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.stateDwell))
+	assert.Equal(t, 9, testutil.CollectAndCount(m))
+}
+
+func TestStateMetrics(t *testing.T) {
+	clock := fsmtest.NewFakeClock(time.Unix(0, 0))
+	machine := fsm.NewFSM(fsm.StringState("pending"), nil)
+	machine.SetClock(clock)
+	assert.Nil(t, machine.AddState(fsm.StringState("approved")))
+	assert.Nil(t, machine.AddEvent("remind"))
+	assert.Nil(t, machine.AddEvent("approve"))
+	assert.Nil(t, machine.AddTransition(fsm.StringState("pending"), "remind", fsm.StringState("pending"), nil, nil))
+	assert.Nil(t, machine.AddTransition(fsm.StringState("pending"), "approve", fsm.StringState("approved"), nil,
+		nil))
+	m := New("test")
+	machine.AddObserver(m.Observer("request"))
+
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent("remind")))
+	clock.Advance(time.Hour)
+	assert.NotNil(t, machine.ProcessEvent(fsm.StringEvent("unknown")))
+	clock.Advance(time.Hour)
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent("approve")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.stateEntries.WithLabelValues("request", "pending")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.stateEntries.WithLabelValues("request", "approved")))
+	// approved is not left yet.
+	assert.Equal(t, 1, testutil.CollectAndCount(m.stateDwell))
+}
+
+func TestTenantMetrics(t *testing.T) {
+	tenantOf := func(key string) string { return key[:4] }
+	tenants := map[string]*Metrics{
+		"acme": NewWithLabels("test", prometheus.Labels{"tenant": "acme"}),
+		"init": NewWithLabels("test", prometheus.Labels{"tenant": "init"}),
+	}
+	registry := prometheus.NewRegistry()
+	for _, m := range tenants {
+		assert.Nil(t, registry.Register(m))
+	}
+	manager := fsm.NewManager(func(key string) (*fsm.FSM, error) {
+		machine := fsm.NewFSM(fsm.StringState("off"), nil)
+		_ = machine.AddState(fsm.StringState("on"))
+		_ = machine.AddEvent("switch")
+		_ = machine.AddTransition(fsm.StringState("off"), "switch", fsm.StringState("on"), nil, nil)
+		machine.AddObserver(tenants[tenantOf(key)].Observer(key))
+		return machine, nil
+	}, fsm.ManagerOptions[string]{Tenant: tenantOf})
+	defer manager.Close()
+	for _, key := range []string{"acme-1", "acme-2", "init-1"} {
+		assert.Nil(t, manager.ProcessEvent(key, fsm.StringEvent("switch")))
+	}
+	assert.Equal(t, 2, testutil.CollectAndCount(tenants["acme"].transitions))
+	assert.Equal(t, 1, testutil.CollectAndCount(tenants["init"].transitions))
+	assert.Equal(t, 1.0, testutil.ToFloat64(tenants["init"].currentState.WithLabelValues("init-1", "on")))
+}
+
+func TestMetricsNotStarted(t *testing.T) {
+	machine := fsm.NewFSM(nil, nil)
+	assert.Nil(t, machine.AddState(fsm.StringState("off")))
+	assert.Nil(t, machine.AddEvent("switch"))
+	m := New("test")
+	machine.AddObserver(m.Observer("light"))
+	assert.Equal(t, fsm.ErrNotStarted, machine.ProcessEvent(fsm.StringEvent("switch")))
+	assert.Equal(t, 0, testutil.CollectAndCount(m.currentState))
+
+	assert.Nil(t, machine.Start(fsm.StringState("off")))
+	assert.NotNil(t, machine.ProcessEvent(fsm.StringEvent("switch")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.currentState.WithLabelValues("light", "off")))
+}
@@ -0,0 +1,57 @@
+package fsm
+
+import "strings"
+
+// isWildcardEvent returns true if evID is the wildcard of an event namespace, e.g., "payment.*".
+func isWildcardEvent(evID string) bool {
+	return len(evID) > 2 && strings.HasSuffix(evID, ".*")
+}
+
+// inWildcardNamespace returns true if there is a wildcard transition of a namespace containing evID.
+func (fsm *FSM) inWildcardNamespace(evID string) bool {
+	for _, namespaces := range fsm.wildcards {
+		for i := strings.LastIndexByte(evID, '.'); i > 0; i = strings.LastIndexByte(evID[:i], '.') {
+			if len(namespaces[evID[:i]]) != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// setWildcard indexes the transitions of the wildcard evID from state `from` by its namespace.
+func (fsm *FSM) setWildcard(from string, evID string, transList []*transition) {
+	if fsm.wildcards == nil {
+		fsm.wildcards = make(map[string]map[string][]*transition)
+	}
+	if _, ok := fsm.wildcards[from]; !ok {
+		fsm.wildcards[from] = make(map[string][]*transition)
+	}
+	fsm.wildcards[from][strings.TrimSuffix(evID, ".*")] = transList
+}
+
+// appendWildcards returns transList followed by the wildcard transitions of the namespaces containing evID from
+// state `from`, from the innermost namespace to the outermost one. transList is not modified.
+func (fsm *FSM) appendWildcards(from string, evID string, transList []*transition) []*transition {
+	namespaces := fsm.wildcards[from]
+	if len(namespaces) == 0 || isWildcardEvent(evID) {
+		return transList
+	}
+	copied := false
+	for i := strings.LastIndexByte(evID, '.'); i > 0; i = strings.LastIndexByte(evID[:i], '.') {
+		wildcard := namespaces[evID[:i]]
+		if len(wildcard) == 0 {
+			continue
+		}
+		if len(transList) == 0 {
+			transList = wildcard
+			continue
+		}
+		if !copied {
+			transList = append(make([]*transition, 0, len(transList)+len(wildcard)), transList...)
+			copied = true
+		}
+		transList = append(transList, wildcard...)
+	}
+	return transList
+}
@@ -0,0 +1,103 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// newGatewayFSM creates a machine recording which transition handles the payment events.
+func newGatewayFSM(t *testing.T) (*FSM, *[]string) {
+	machine := NewFSM(StringState("authorized"), nil)
+	assert.Nil(t, machine.AddEvent("payment.captured"))
+	var handled []string
+	for _, evID := range []string{"payment.*", "payment.captured", "payment.card.*"} {
+		evID := evID
+		assert.Nil(t, machine.AddTransition(StringState("authorized"), evID, StringState("authorized"),
+			func(payload interface{}, ev Event) error {
+				handled = append(handled, evID)
+				return nil
+			}, nil))
+	}
+	assert.Nil(t, machine.AddTransitionMatching(StringState("authorized"), func(Event) bool { return true },
+		StringState("authorized"), func(payload interface{}, ev Event) error {
+			handled = append(handled, "matching")
+			return nil
+		}))
+	return machine, &handled
+}
+
+func TestEventNamespace(t *testing.T) {
+	machine, handled := newGatewayFSM(t)
+	for _, evID := range []string{"payment.captured", "payment.failed", "payment.card.declined",
+		"payment.card.3ds.challenged", "refund.created", "payment"} {
+		assert.Nil(t, machine.ProcessEvent(StringEvent(evID)), evID)
+	}
+	assert.Equal(t, []string{"payment.captured", "payment.*", "payment.card.*", "payment.card.*", "matching",
+		"matching"}, *handled)
+}
+
+func TestEventNamespacePrecedence(t *testing.T) {
+	machine := NewFSM(StringState("authorized"), nil)
+	assert.Nil(t, machine.AddState(StringState("captured")))
+	assert.Nil(t, machine.AddState(StringState("failed")))
+	assert.Nil(t, machine.AddEvent("payment.captured"))
+	assert.Nil(t, machine.AddTransition(StringState("authorized"), "payment.*", StringState("failed"), nil, nil))
+	assert.Nil(t, machine.AddTransition(StringState("authorized"), "payment.captured", StringState("captured"), nil,
+		func(payload interface{}, ev Event) bool { return payload != nil }))
+
+	// the wildcard is evaluated if the guards of the exact event id return false.
+	next, err := machine.Simulate(StringEvent("payment.captured"))
+	assert.Nil(t, err)
+	assert.Equal(t, "failed", next.FSMStateID())
+	machine.payload = "amount"
+	next, err = machine.Simulate(StringEvent("payment.captured"))
+	assert.Nil(t, err)
+	assert.Equal(t, "captured", next.FSMStateID())
+	assert.Equal(t, []string{"payment.*", "payment.captured"}, machine.AvailableEvents())
+}
+
+func TestEventNamespaceHierarchy(t *testing.T) {
+	machine := NewFSM(StringState("session"), nil)
+	assert.Nil(t, machine.AddChildState(StringState("session"), StringState("paying")))
+	assert.Nil(t, machine.AddState(StringState("closed")))
+	assert.Nil(t, machine.AddState(StringState("retrying")))
+	assert.Nil(t, machine.AddEvent("payment.failed"))
+	assert.Nil(t, machine.AddTransition(StringState("session"), "payment.failed", StringState("closed"), nil, nil))
+	assert.Nil(t, machine.AddTransition(StringState("paying"), "payment.*", StringState("retrying"), nil, nil))
+	assert.Nil(t, machine.Start(StringState("session")))
+
+	// the wildcard of a child state takes priority over the exact event id of its parent.
+	assert.Nil(t, machine.ProcessEvent(StringEvent("payment.failed")))
+	assert.Equal(t, "retrying", machine.CurrentState().FSMStateID())
+}
+
+func TestEventNamespaceAvailableEvents(t *testing.T) {
+	machine, _ := newGatewayFSM(t)
+	assert.Nil(t, machine.AddEvent("payment.card.declined"))
+	assert.Nil(t, machine.AddEvent("refund.created"))
+	assert.Equal(t, []string{"payment.*", "payment.captured", "payment.card.*", "payment.card.declined"},
+		machine.AvailableEvents())
+}
+
+func TestEventNamespaceUnknownEvent(t *testing.T) {
+	machine := NewFSM(StringState("authorized"), nil)
+	assert.Nil(t, machine.AddState(StringState("failed")))
+	assert.Nil(t, machine.AddTransition(StringState("failed"), "payment.*", StringState("authorized"), nil, nil))
+	machine.SetUnknownEventMode(UnknownEventError)
+	err := machine.ProcessEvent(StringEvent("payment.failed"))
+	assert.NotNil(t, err)
+	assert.NotEqual(t, ErrUnknownEvent, err)
+	assert.Equal(t, ErrUnknownEvent, machine.ProcessEvent(StringEvent("refund.created")))
+}
+
+func TestEventNamespaceDefinition(t *testing.T) {
+	machine, _ := newGatewayFSM(t)
+	loaded, err := NewFSMFromDefinition(machine.Definition(), nil, nil)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.True(t, loaded.CanFire(StringEvent("payment.card.declined")))
+	assert.False(t, loaded.CanFire(StringEvent("refund.created")))
+	loaded.Compile()
+	assert.Nil(t, loaded.ProcessEvent(StringEvent("payment.failed")))
+}
@@ -0,0 +1,146 @@
+// Package netconn binds a `fsm.QueuedFSM` to the lifecycle of a net.Conn, for the protocol implementations: the
+// frames read from the connection are decoded into events, the actions write the frames to the connection, and the
+// connection errors are processed as events.
+//
+//	session := netconn.NewSession(conn, machine, codec, "disconnected")
+//	// in an action of machine.
+//	session, _ := netconn.FromContext(machine.ActionContext())
+//	return session.Write(pong)
+//	...
+//	err := session.Run(ctx)
+package netconn
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/reyoung/fsm"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Codec decodes the frames read from a connection into events, and encodes the frames written to it.
+type Codec interface {
+	// Decode reads a frame from r and returns its event. It returns the error of reading, e.g., io.EOF.
+	Decode(r *bufio.Reader) (fsm.Event, error)
+	// Encode writes the frame to w.
+	Encode(w io.Writer, frame interface{}) error
+}
+
+// LineCodec is a `Codec` of text lines. The frames are written by fmt.Fprintln.
+type LineCodec struct {
+	// Parse returns the event of a line without the line break. The line is a `fsm.StringEvent` if it is nil.
+	Parse func(line string) (fsm.Event, error)
+}
+
+func (c LineCodec) Decode(r *bufio.Reader) (fsm.Event, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if c.Parse == nil {
+		return fsm.StringEvent(line), nil
+	}
+	return c.Parse(line)
+}
+
+func (c LineCodec) Encode(w io.Writer, frame interface{}) error {
+	_, err := fmt.Fprintln(w, frame)
+	return err
+}
+
+// ErrorEvent is processed by the machine when the connection fails, e.g., the peer closed it (io.EOF) or a frame
+// cannot be decoded. See `NewSession`.
+type ErrorEvent struct {
+	ID  string
+	Err error
+}
+
+func (e ErrorEvent) FSMEventID() string {
+	return e.ID
+}
+
+type sessionKey struct{}
+
+// FromContext returns the session of the events read by `Session.Run`, e.g., from `fsm.FSM.ActionContext`.
+func FromContext(ctx context.Context) (*Session, bool) {
+	s, ok := ctx.Value(sessionKey{}).(*Session)
+	return s, ok
+}
+
+// Session is a connection driving a machine. See `NewSession`.
+type Session struct {
+	conn       net.Conn
+	machine    *fsm.QueuedFSM
+	codec      Codec
+	errorEvent string
+	onError    func(ev fsm.Event, err error)
+	writeMu    sync.Mutex
+}
+
+// NewSession creates a session processing the events read from conn by the machine. The connection errors are
+// processed as the `ErrorEvent` of errorEvent, which should be an event of the machine.
+func NewSession(conn net.Conn, machine *fsm.QueuedFSM, codec Codec, errorEvent string) *Session {
+	return &Session{conn: conn, machine: machine, codec: codec, errorEvent: errorEvent}
+}
+
+// SetErrorHandler sets the handler of the events failed to be processed, e.g., the unexpected frames of the
+// protocol. The failed events are dropped by default.
+// NOTE: it should be invoked before `Run`.
+func (s *Session) SetErrorHandler(onError func(ev fsm.Event, err error)) {
+	s.onError = onError
+}
+
+// Conn returns the connection.
+func (s *Session) Conn() net.Conn {
+	return s.conn
+}
+
+// Run reads the frames and processes their events one by one, until reading or decoding fails, or ctx is done.
+// Then it processes the `ErrorEvent`, and returns the error, or the error of ctx when ctx is done. The events are
+// processed with a context carrying the session, see `FromContext`. The connection is not closed by Run.
+func (s *Session) Run(ctx context.Context) error {
+	// unblock the reading when ctx is done.
+	stop := context.AfterFunc(ctx, func() {
+		_ = s.conn.SetReadDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+	evCtx := context.WithValue(ctx, sessionKey{}, s)
+	r := bufio.NewReader(s.conn)
+	for {
+		ev, err := s.codec.Decode(r)
+		if err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			s.process(context.WithValue(context.WithoutCancel(ctx), sessionKey{}, s),
+				ErrorEvent{ID: s.errorEvent, Err: err})
+			return err
+		}
+		s.process(evCtx, ev)
+	}
+}
+
+func (s *Session) process(ctx context.Context, ev fsm.Event) {
+	if err := s.machine.ProcessEventContext(ctx, ev); err != nil && s.onError != nil {
+		s.onError(ev, err)
+	}
+}
+
+// Write encodes the frame to the connection. It can be invoked concurrently, e.g., by the actions and other
+// goroutines. The error is returned to the caller, e.g., to fail the action, and the broken connection is
+// reported to the machine by `Run` when the reading fails.
+func (s *Session) Write(frame interface{}) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.codec.Encode(s.conn, frame)
+}
+
+// Close closes the connection, so `Run` stops with the error of reading.
+func (s *Session) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,92 @@
+package netconn
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"github.com/reyoung/fsm"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// newPingServer creates a machine answering "ping" by "pong" until the connection is closed.
+func newPingServer(t *testing.T) (*fsm.QueuedFSM, *error) {
+	machine := fsm.NewQueuedFSM(fsm.StringState("open"), nil)
+	assert.Nil(t, machine.AddState(fsm.StringState("closed")))
+	assert.Nil(t, machine.AddEvent("ping"))
+	assert.Nil(t, machine.AddEvent("disconnected"))
+	assert.Nil(t, machine.AddTransition(fsm.StringState("open"), "ping", fsm.StringState("open"),
+		func(interface{}, fsm.Event) error {
+			session, ok := FromContext(machine.ActionContext())
+			if !ok {
+				return errors.New("no session")
+			}
+			return session.Write("pong")
+		}, nil))
+	var disconnected error
+	assert.Nil(t, machine.AddTransition(fsm.StringState("open"), "disconnected", fsm.StringState("closed"),
+		func(payload interface{}, ev fsm.Event) error {
+			disconnected = ev.(ErrorEvent).Err
+			return nil
+		}, nil))
+	return machine, &disconnected
+}
+
+func TestSession(t *testing.T) {
+	server, client := net.Pipe()
+	machine, disconnected := newPingServer(t)
+	defer machine.Close()
+	session := NewSession(server, machine, LineCodec{}, "disconnected")
+	var failed []string
+	session.SetErrorHandler(func(ev fsm.Event, err error) {
+		failed = append(failed, ev.FSMEventID())
+	})
+	assert.Equal(t, server, session.Conn())
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Run(context.Background())
+	}()
+
+	r := bufio.NewReader(client)
+	_, err := io.WriteString(client, "ping\n")
+	assert.Nil(t, err)
+	line, err := r.ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, "pong\n", line)
+	_, err = io.WriteString(client, "hello\r\n")
+	assert.Nil(t, err)
+
+	assert.Nil(t, client.Close())
+	assert.Equal(t, io.EOF, <-done)
+	assert.Equal(t, "closed", machine.CurrentState().FSMStateID())
+	assert.Equal(t, io.EOF, *disconnected)
+	assert.Equal(t, []string{"hello"}, failed)
+}
+
+func TestSessionContext(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	machine, disconnected := newPingServer(t)
+	defer machine.Close()
+	session := NewSession(server, machine, LineCodec{Parse: func(line string) (fsm.Event, error) {
+		return fsm.StringEvent(strings.ToLower(line)), nil
+	}}, "disconnected")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Run(ctx)
+	}()
+
+	_, err := io.WriteString(client, "PING\n")
+	assert.Nil(t, err)
+	line, err := bufio.NewReader(client).ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, "pong\n", line)
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+	assert.Equal(t, context.Canceled, *disconnected)
+	assert.Nil(t, session.Close())
+}
@@ -0,0 +1,39 @@
+package fsm
+
+import (
+	"context"
+	"time"
+)
+
+// Observer observes the event processing of a FSM. It is used by integrations like tracing, metrics and
+// logging. Observers are invoked synchronously in the event processing goroutine, in the order of
+// `AddObserver`, so they should be fast and should not invoke `ProcessEvent`.
+// Embed `NopObserver` to implement only a part of the methods.
+type Observer interface {
+	// EventStarted is invoked before the event is processed.
+	EventStarted(ctx context.Context, fsm *FSM, ev Event)
+	// GuardRejected is invoked when the guard of a candidate transition returns false.
+	GuardRejected(ctx context.Context, fsm *FSM, args ActionHookArgs)
+	// ActionFinished is invoked after the action of the chosen transition returns. The state is not
+	// changed yet, and it will not be changed if err is not nil.
+	ActionFinished(ctx context.Context, fsm *FSM, args ActionHookArgs, duration time.Duration, err error)
+	// EventFinished is invoked when the event processing completes. err is the result of `ProcessEvent`.
+	EventFinished(ctx context.Context, fsm *FSM, ev Event, err error)
+}
+
+// NopObserver is an Observer which does nothing.
+type NopObserver struct{}
+
+func (NopObserver) EventStarted(context.Context, *FSM, Event) {}
+
+func (NopObserver) GuardRejected(context.Context, *FSM, ActionHookArgs) {}
+
+func (NopObserver) ActionFinished(context.Context, *FSM, ActionHookArgs, time.Duration, error) {}
+
+func (NopObserver) EventFinished(context.Context, *FSM, Event, error) {}
+
+// AddObserver appends an observer to the FSM.
+// NOTE: like other modifications, it should not be invoked concurrently with `ProcessEvent`.
+func (fsm *FSM) AddObserver(o Observer) {
+	fsm.observers = append(fsm.observers, o)
+}
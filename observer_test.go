@@ -0,0 +1,63 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type recordObserver struct {
+	NopObserver
+	records []string
+}
+
+func (r *recordObserver) EventStarted(ctx context.Context, fsm *FSM, ev Event) {
+	r.records = append(r.records, "start "+ev.FSMEventID())
+}
+
+func (r *recordObserver) GuardRejected(ctx context.Context, fsm *FSM, args ActionHookArgs) {
+	r.records = append(r.records, "reject "+args.ToState.FSMStateID())
+}
+
+func (r *recordObserver) ActionFinished(ctx context.Context, fsm *FSM, args ActionHookArgs,
+	duration time.Duration, err error) {
+	r.records = append(r.records, "action "+args.ToState.FSMStateID())
+}
+
+func (r *recordObserver) EventFinished(ctx context.Context, fsm *FSM, ev Event, err error) {
+	if err != nil {
+		r.records = append(r.records, "error "+err.Error())
+	} else {
+		r.records = append(r.records, "finish "+fsm.CurrentState().FSMStateID())
+	}
+}
+
+func TestObserver(t *testing.T) {
+	var (
+		on     = StringState("on")
+		off    = StringState("off")
+		broken = StringState("broken")
+	)
+	fsm := NewFSM(off, nil)
+	assert.Nil(t, fsm.AddState(on))
+	assert.Nil(t, fsm.AddState(broken))
+	assert.Nil(t, fsm.AddEvent("switch"))
+	assert.Nil(t, fsm.AddTransition(off, "switch", broken, nil, func(i interface{}, event Event) bool {
+		return false
+	}))
+	assert.Nil(t, fsm.AddTransition(off, "switch", on, nil, nil))
+	assert.Nil(t, fsm.AddTransition(on, "switch", off, func(i interface{}, event Event) error {
+		return errors.New("stuck")
+	}, nil))
+	observer := &recordObserver{}
+	fsm.AddObserver(observer)
+
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("switch")))
+	assert.NotNil(t, fsm.ProcessEventContext(context.Background(), StringEvent("switch")))
+	assert.Equal(t, []string{
+		"start switch", "reject broken", "action on", "finish on",
+		"start switch", "action off", "error stuck",
+	}, observer.records)
+}
@@ -0,0 +1,116 @@
+// Package otel traces the event processing of FSMs with OpenTelemetry.
+//
+//	machine.AddObserver(otel.NewObserver(otel.Tracer("my-service")))
+//
+// Each `ProcessEvent` starts a span, which is a child of the span in the context passed to
+// `ProcessEventContext`. Guard rejections are recorded as span events, and action errors are recorded
+// as span errors. The correlation and causation ids of the events wrapped by `fsm.Envelope` are recorded as span
+// attributes.
+package otel
+
+import (
+	"context"
+	"github.com/reyoung/fsm"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"sync"
+	"time"
+)
+
+const (
+	SpanName = "fsm.ProcessEvent"
+
+	MachineKey   = attribute.Key("fsm.machine")
+	EventKey     = attribute.Key("fsm.event")
+	FromStateKey = attribute.Key("fsm.from_state")
+	ToStateKey   = attribute.Key("fsm.to_state")
+
+	CorrelationIDKey = attribute.Key("fsm.correlation_id")
+	CausationIDKey   = attribute.Key("fsm.causation_id")
+	ActorKey         = attribute.Key("fsm.actor")
+)
+
+// Observer is a `fsm.Observer` which starts a span for each event. One Observer can be shared by many
+// machines.
+type Observer struct {
+	tracer trace.Tracer
+
+	mu    sync.Mutex
+	spans map[*fsm.FSM]trace.Span
+}
+
+func NewObserver(tracer trace.Tracer) *Observer {
+	return &Observer{
+		tracer: tracer,
+		spans:  make(map[*fsm.FSM]trace.Span),
+	}
+}
+
+func (o *Observer) span(machine *fsm.FSM) trace.Span {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.spans[machine]
+}
+
+func (o *Observer) EventStarted(ctx context.Context, machine *fsm.FSM, ev fsm.Event) {
+	attrs := []attribute.KeyValue{EventKey.String(ev.FSMEventID())}
+	// the from state is unknown if the machine is not started, see `fsm.FSM.Start`.
+	if from := machine.CurrentState(); from != nil {
+		attrs = append(attrs, FromStateKey.String(from.FSMStateID()))
+	}
+	if machine.Name() != "" {
+		attrs = append(attrs, MachineKey.String(machine.Name()))
+	}
+	if env, ok := ev.(*fsm.Envelope); ok {
+		if env.CorrelationID != "" {
+			attrs = append(attrs, CorrelationIDKey.String(env.CorrelationID))
+		}
+		if env.CausationID != "" {
+			attrs = append(attrs, CausationIDKey.String(env.CausationID))
+		}
+		if env.Actor != "" {
+			attrs = append(attrs, ActorKey.String(env.Actor))
+		}
+	}
+	_, span := o.tracer.Start(ctx, SpanName,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attrs...))
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.spans[machine] = span
+}
+
+func (o *Observer) GuardRejected(ctx context.Context, machine *fsm.FSM, args fsm.ActionHookArgs) {
+	if span := o.span(machine); span != nil {
+		span.AddEvent("guard rejected", trace.WithAttributes(ToStateKey.String(args.ToState.FSMStateID())))
+	}
+}
+
+func (o *Observer) ActionFinished(ctx context.Context, machine *fsm.FSM, args fsm.ActionHookArgs,
+	duration time.Duration, err error) {
+	span := o.span(machine)
+	if span == nil {
+		return
+	}
+	span.SetAttributes(attribute.Int64("fsm.action_duration_us", duration.Microseconds()))
+	if err != nil {
+		span.RecordError(err, trace.WithAttributes(ToStateKey.String(args.ToState.FSMStateID())))
+	}
+}
+
+func (o *Observer) EventFinished(ctx context.Context, machine *fsm.FSM, ev fsm.Event, err error) {
+	o.mu.Lock()
+	span := o.spans[machine]
+	delete(o.spans, machine)
+	o.mu.Unlock()
+	if span == nil {
+		return
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetAttributes(ToStateKey.String(machine.CurrentState().FSMStateID()))
+	}
+	span.End()
+}
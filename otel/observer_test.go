@@ -0,0 +1,97 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"github.com/reyoung/fsm"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+func attributeValue(attrs []attribute.KeyValue, key attribute.Key) string {
+	for _, kv := range attrs {
+		if kv.Key == key {
+			return kv.Value.AsString()
+		}
+	}
+	return ""
+}
+
+func TestObserver(t *testing.T) {
+	var (
+		on  = fsm.StringState("on")
+		off = fsm.StringState("off")
+	)
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	machine := fsm.NewFSM(off, nil)
+	machine.SetName("light")
+	assert.Nil(t, machine.AddState(on))
+	assert.Nil(t, machine.AddEvent("switch"))
+	assert.Nil(t, machine.AddTransition(off, "switch", off, nil, func(i interface{}, event fsm.Event) bool {
+		return false
+	}))
+	assert.Nil(t, machine.AddTransition(off, "switch", on, nil, nil))
+	assert.Nil(t, machine.AddTransition(on, "switch", off, func(i interface{}, event fsm.Event) error {
+		return errors.New("stuck")
+	}, nil))
+	machine.AddObserver(NewObserver(tracer))
+
+	ctx, parent := tracer.Start(context.Background(), "parent")
+	assert.Nil(t, machine.ProcessEventContext(ctx, fsm.StringEvent("switch")))
+	parent.End()
+	assert.NotNil(t, machine.ProcessEvent(fsm.StringEvent("switch")))
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 3)
+	first, second := spans[0], spans[2]
+	assert.Equal(t, SpanName, first.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), first.Parent().SpanID())
+	assert.Equal(t, "light", attributeValue(first.Attributes(), MachineKey))
+	assert.Equal(t, "switch", attributeValue(first.Attributes(), EventKey))
+	assert.Equal(t, "off", attributeValue(first.Attributes(), FromStateKey))
+	assert.Equal(t, "on", attributeValue(first.Attributes(), ToStateKey))
+	assert.Equal(t, "guard rejected", first.Events()[0].Name)
+
+	assert.Equal(t, codes.Error, second.Status().Code)
+	assert.Equal(t, "stuck", second.Status().Description)
+	assert.Equal(t, "", attributeValue(second.Attributes(), ToStateKey))
+}
+
+func TestObserverEnvelope(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	machine := fsm.NewFSM(fsm.StringState("off"), nil)
+	assert.Nil(t, machine.AddEvent("switch"))
+	assert.Nil(t, machine.AddTransition(fsm.StringState("off"), "switch", fsm.StringState("off"), nil, nil))
+	machine.AddObserver(NewObserver(tracer))
+
+	env := &fsm.Envelope{
+		Event: fsm.StringEvent("switch"), ID: "2", CorrelationID: "req-1", CausationID: "1", Actor: "alice",
+	}
+	assert.Nil(t, machine.ProcessEvent(env))
+	span := recorder.Ended()[0]
+	assert.Equal(t, "switch", attributeValue(span.Attributes(), EventKey))
+	assert.Equal(t, "req-1", attributeValue(span.Attributes(), CorrelationIDKey))
+	assert.Equal(t, "1", attributeValue(span.Attributes(), CausationIDKey))
+	assert.Equal(t, "alice", attributeValue(span.Attributes(), ActorKey))
+}
+
+func TestObserverNotStarted(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	machine := fsm.NewFSM(nil, nil)
+	assert.Nil(t, machine.AddState(fsm.StringState("off")))
+	assert.Nil(t, machine.AddEvent("switch"))
+	machine.AddObserver(NewObserver(tracer))
+
+	assert.Equal(t, fsm.ErrNotStarted, machine.ProcessEvent(fsm.StringEvent("switch")))
+	span := recorder.Ended()[0]
+	assert.Equal(t, "", attributeValue(span.Attributes(), FromStateKey))
+	assert.Equal(t, codes.Error, span.Status().Code)
+}
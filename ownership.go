@@ -0,0 +1,61 @@
+package fsm
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+)
+
+// SetOwnershipCheck enables the debug check of the goroutine owning the FSM. The FSM is owned by the goroutine
+// which processes the first event or reads the current state after it is enabled, and `ProcessEvent`,
+// `ProcessEventWithResult`, `Replay`, `Start` and `CurrentState` panic with `NotOwnerPanic` if they are invoked by
+// another goroutine, so the common misuse of sharing a plain FSM between goroutines is caught before it becomes a
+// data race, even if the race detector misses it.
+// Enabling it again or `ReleaseOwnership` releases the ownership, e.g., to hand the FSM over to another goroutine.
+// It is disabled by default, and enabled by default if the package is built with the `fsmdebug` tag:
+//
+//	go test -tags fsmdebug ./...
+//
+// NOTE: the check looks up the goroutine id from the stack trace, which is slow, so it should only be enabled
+// for debugging. `QueuedFSM`, `PreemptiveFSM` and `Manager` are not checked since they process the events by
+// their own goroutines, and `Version` is not checked since it is thread-safe.
+func (fsm *FSM) SetOwnershipCheck(enabled bool) {
+	fsm.ownerCheck = enabled
+	fsm.owner.Store(0)
+}
+
+// ReleaseOwnership hands the FSM over, the next goroutine using it becomes its owner. The wrappers serializing the
+// access to the FSM by their own lock should release it before unlocking, and the FSM built by one goroutine and
+// run by another should be released before it is run. It does nothing if the ownership check is disabled.
+func (fsm *FSM) ReleaseOwnership() {
+	fsm.owner.Store(0)
+}
+
+// checkOwner panics if the ownership check is enabled and the FSM is owned by another goroutine.
+func (fsm *FSM) checkOwner() {
+	if !fsm.ownerCheck {
+		return
+	}
+	id := goroutineID()
+	if fsm.owner.CompareAndSwap(0, id) {
+		return
+	}
+	if owner := fsm.owner.Load(); owner != id {
+		panic(fmt.Sprintf("%s: the FSM %q is owned by goroutine %d, but used by goroutine %d",
+			NotOwnerPanic, fsm.name, owner, id))
+	}
+}
+
+// goroutineID returns the id of the current goroutine, which is parsed from the header of the stack trace, i.e.,
+// "goroutine 18 [running]:".
+func goroutineID() int64 {
+	var buf [64]byte
+	header := buf[:runtime.Stack(buf[:], false)]
+	header = bytes.TrimPrefix(header, []byte("goroutine "))
+	if i := bytes.IndexByte(header, ' '); i >= 0 {
+		header = header[:i]
+	}
+	id, _ := strconv.ParseInt(string(header), 10, 64)
+	return id
+}
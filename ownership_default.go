@@ -0,0 +1,5 @@
+//go:build !fsmdebug
+
+package fsm
+
+const ownershipCheckByDefault = false
@@ -0,0 +1,6 @@
+//go:build fsmdebug
+
+package fsm
+
+// ownershipCheckByDefault enables `SetOwnershipCheck` for all FSMs built with the fsmdebug tag.
+const ownershipCheckByDefault = true
@@ -0,0 +1,95 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// processIn processes the event in a new goroutine, and returns the recovered panic.
+func processIn(fsm *FSM, ev Event) (recovered interface{}) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() {
+			recovered = recover()
+		}()
+		_ = fsm.ProcessEvent(ev)
+	}()
+	<-done
+	return recovered
+}
+
+func TestOwnershipCheck(t *testing.T) {
+	fsm := newOwnershipFSM(t)
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("toggle")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("toggle")))
+	assert.Equal(t, StringState("off"), fsm.CurrentState())
+
+	recovered := processIn(fsm, StringEvent("toggle"))
+	assert.NotNil(t, recovered)
+	assert.Contains(t, recovered, NotOwnerPanic)
+	assert.Equal(t, uint64(2), fsm.Version())
+	// the owner is unchanged.
+	_, err := fsm.ProcessEventWithResult(StringEvent("toggle"))
+	assert.Nil(t, err)
+}
+
+func TestOwnershipCheckHandOver(t *testing.T) {
+	fsm := newOwnershipFSM(t)
+	assert.Nil(t, processIn(fsm, StringEvent("toggle")))
+	assert.Panics(t, func() {
+		_ = fsm.ProcessEvent(StringEvent("toggle"))
+	})
+
+	// enabling it again releases the ownership.
+	fsm.SetOwnershipCheck(true)
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("toggle")))
+	fsm.SetOwnershipCheck(false)
+	assert.Nil(t, processIn(fsm, StringEvent("toggle")))
+}
+
+func TestReleaseOwnership(t *testing.T) {
+	fsm := newOwnershipFSM(t)
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("toggle")))
+	fsm.ReleaseOwnership()
+	assert.Nil(t, processIn(fsm, StringEvent("toggle")))
+	assert.NotNil(t, processIn(fsm, StringEvent("toggle")))
+	assert.Panics(t, func() {
+		_ = fsm.CurrentState()
+	})
+}
+
+func TestOwnershipCheckCurrentState(t *testing.T) {
+	fsm := newOwnershipFSM(t)
+	assert.Equal(t, StringState("off"), fsm.CurrentState())
+	recovered := make(chan interface{})
+	go func() {
+		defer func() {
+			recovered <- recover()
+		}()
+		_ = fsm.CurrentState()
+	}()
+	assert.Contains(t, <-recovered, NotOwnerPanic)
+	// the FSM is owned by the goroutine reading the current state first.
+	assert.NotNil(t, processIn(fsm, StringEvent("toggle")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("toggle")))
+
+	queued := NewQueuedFSM(StringState("off"), nil)
+	defer queued.Close()
+	assert.Nil(t, queued.AddEvent("toggle"))
+	assert.Nil(t, queued.AddTransition(StringState("off"), "toggle", StringState("off"), nil, nil))
+	queued.SetOwnershipCheck(true)
+	assert.Nil(t, queued.ProcessEvent(StringEvent("toggle")))
+	assert.Equal(t, StringState("off"), queued.CurrentState())
+}
+
+func TestGoroutineID(t *testing.T) {
+	id := goroutineID()
+	assert.NotEqual(t, int64(0), id)
+	assert.Equal(t, id, goroutineID())
+	other := make(chan int64)
+	go func() {
+		other <- goroutineID()
+	}()
+	assert.NotEqual(t, id, <-other)
+}
@@ -0,0 +1,200 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// SetParallel makes the children of the composite state orthogonal regions. When the FSM is in a parallel
+// state, every region has an active state, which starts from the initial child of the region.
+//   - `CurrentState` returns the parallel state, and `CurrentStates` returns the active state of each region.
+//   - An event is dispatched to each region in the order of adding. The transitions of the parallel state and
+//     its ancestors are evaluated only if no region handles the event.
+//   - A transition from a region to a state outside the region leaves the parallel state, the other regions
+//     are not dispatched anymore. A transition targeting a state inside a region enters the parallel state,
+//     the other regions start from their initial child.
+//
+// NOTE: parallel states cannot be nested, and their history states are not supported. The completion
+// transitions of the states inside regions are not fired.
+func (fsm *FSM) SetParallel(state State) error {
+	if err := fsm.checkMutable(); err != nil {
+		return err
+	}
+	id := state.FSMStateID()
+	if len(fsm.children[id]) == 0 {
+		return notCompositeState(state)
+	}
+	for p, ok := fsm.parents[id]; ok; p, ok = fsm.parents[p] {
+		if fsm.parallel[p] {
+			return errors.New(fmt.Sprintf("state %s is inside the parallel state %s", id, p))
+		}
+	}
+	for p := range fsm.parallel {
+		if fsm.isDescendant(p, id) {
+			return errors.New(fmt.Sprintf("state %s contains the parallel state %s", id, p))
+		}
+	}
+	for _, h := range fsm.histories {
+		if h.Composite == id {
+			return errors.New(fmt.Sprintf("the history states of parallel state %s are not supported", id))
+		}
+	}
+	fsm.parallel[id] = true
+	return nil
+}
+
+// IsParallel returns true if the state is a parallel state. See `SetParallel`.
+func (fsm *FSM) IsParallel(state State) bool {
+	return fsm.parallel[state.FSMStateID()]
+}
+
+// CurrentStates returns the active state of each region if the FSM is in a parallel state, otherwise it
+// returns the current state. It can be invoked concurrently with `ProcessEvent`, like `StateRef`.
+func (fsm *FSM) CurrentStates() []State {
+	fsm.curStateMu.RLock()
+	defer fsm.curStateMu.RUnlock()
+	return fsm.leafStates(fsm.committedLeaves())
+}
+
+// leafStates returns the states of the leaf ids.
+func (fsm *FSM) leafStates(leaves []string) []State {
+	result := make([]State, 0, len(leaves))
+	for _, leaf := range leaves {
+		result = append(result, fsm.states[leaf])
+	}
+	return result
+}
+
+// Restore moves the FSM to `states`, which are returned by `CurrentStates` before, and sets the `Version`,
+// without invoking any action or observer. It is used to restore the FSM from a snapshot.
+// NOTE: the history of composite states is not restored.
+func (fsm *FSM) Restore(states []State, version uint64) error {
+	if len(states) == 0 {
+		return errors.New("the states to restore should not be empty")
+	}
+	for _, state := range states {
+		if !fsm.HasState(state) {
+			return stateNotFound(state)
+		}
+	}
+	fsm.curStateMu.Lock()
+	defer fsm.curStateMu.Unlock()
+	if len(fsm.alarms) != 0 {
+		defer fsm.checkAlarms()
+	}
+	first := states[0].FSMStateID()
+	parallel, ok := fsm.parallelAncestor(first)
+	if !ok || parallel == first {
+		if len(states) != 1 {
+			return errors.New(fmt.Sprintf("state %s is not inside a region of a parallel state", first))
+		}
+		fsm.setCurState(first)
+		fsm.regionStates = make(map[string]string)
+		fsm.version = version
+		fsm.recordHistory(first)
+		fsm.stats.restoreEntry(first, fsm.clock.Now())
+		return nil
+	}
+	regionStates := make(map[string]string)
+	for _, state := range states {
+		region, ok := fsm.regionOf(parallel, state.FSMStateID())
+		if !ok {
+			return errors.New(fmt.Sprintf("state %s is not inside the parallel state %s", state.FSMStateID(),
+				parallel))
+		}
+		if _, ok := regionStates[region]; ok {
+			return errors.New(fmt.Sprintf("more than one state inside region %s", region))
+		}
+		regionStates[region] = state.FSMStateID()
+	}
+	fsm.setCurState(parallel)
+	fsm.regionStates = regionStates
+	fsm.version = version
+	for _, leaf := range regionStates {
+		fsm.recordHistory(leaf)
+	}
+	fsm.stats.restoreEntry(parallel, fsm.clock.Now())
+	return nil
+}
+
+// currentLeaves returns the ids of `CurrentStates`.
+func (fsm *FSM) currentLeaves() []string {
+	if fsm.curState == "" {
+		// the FSM is not started, see `Start`.
+		return nil
+	}
+	if !fsm.parallel[fsm.curState] {
+		return []string{fsm.curState}
+	}
+	regions := fsm.children[fsm.curState]
+	result := make([]string, 0, len(regions))
+	for _, region := range regions {
+		result = append(result, fsm.regionLeaf(region))
+	}
+	return result
+}
+
+// committedLeaves returns `currentLeaves` before the running transaction, see `FSM.Transaction`. The caller
+// should hold curStateMu.
+func (fsm *FSM) committedLeaves() []string {
+	if fsm.committed != nil {
+		return fsm.committed.leaves
+	}
+	return fsm.currentLeaves()
+}
+
+// regionLeaf returns the active state of the region of the current parallel state.
+func (fsm *FSM) regionLeaf(region string) string {
+	if leaf, ok := fsm.regionStates[region]; ok {
+		return leaf
+	}
+	return fsm.resolveState(region)
+}
+
+// regionOf returns the region of the parallel state which contains `state`.
+func (fsm *FSM) regionOf(parallel string, state string) (string, bool) {
+	if !fsm.parallel[parallel] {
+		return "", false
+	}
+	for id, ok := state, true; ok; id, ok = fsm.parents[id] {
+		if fsm.parents[id] == parallel {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// parallelAncestor returns the parallel state which is `state` or contains `state`.
+func (fsm *FSM) parallelAncestor(state string) (string, bool) {
+	for id, ok := state, true; ok; id, ok = fsm.parents[id] {
+		if fsm.parallel[id] {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// dispatchRegions dispatches the event to each region of the current parallel state. It returns true if any
+// region handles the event.
+func (fsm *FSM) dispatchRegions(ctx context.Context, ev Event) (bool, error) {
+	parallel := fsm.curState
+	handled := false
+	for _, region := range fsm.children[parallel] {
+		if fsm.curState != parallel {
+			// the parallel state is left by the previous region.
+			break
+		}
+		for from := fsm.regionLeaf(region); from != parallel; from = fsm.parents[from] {
+			fired, err := fsm.fire(ctx, from, ev, fsm.transitionsOf(from, ev))
+			if err != nil {
+				return handled, err
+			}
+			if fired {
+				handled = true
+				break
+			}
+		}
+	}
+	return handled, nil
+}
@@ -0,0 +1,72 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParallelState(t *testing.T) {
+	fsm := newDeviceFSM(t)
+	assert.True(t, fsm.IsParallel(StringState("on")))
+	assert.NotNil(t, fsm.SetParallel(StringState("battery")))
+	_, err := fsm.DeepHistory(StringState("on"))
+	assert.NotNil(t, err)
+	assert.Equal(t, []State{StringState("off")}, fsm.CurrentStates())
+
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("powerOn")))
+	assert.Equal(t, StringState("on"), fsm.CurrentState())
+	assert.Equal(t, []State{StringState("offline"), StringState("discharging")}, fsm.CurrentStates())
+	assert.True(t, fsm.IsIn(StringState("battery")))
+	assert.False(t, fsm.IsIn(StringState("online")))
+	assert.Equal(t, []string{"connect", "drain", "plug", "powerOff"}, fsm.AvailableEvents())
+
+	ch, cancel := fsm.Subscribe()
+	defer cancel()
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("connect")))
+	assert.Equal(t, []State{StringState("online"), StringState("discharging")}, fsm.CurrentStates())
+	change := <-ch
+	assert.Equal(t, StringState("offline"), change.From)
+	assert.Equal(t, StringState("online"), change.To)
+
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("plug")))
+	// the event is dispatched to both regions.
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("reset")))
+	assert.Equal(t, []State{StringState("offline"), StringState("discharging")}, fsm.CurrentStates())
+
+	// a region leaves the parallel state.
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("drain")))
+	assert.Equal(t, []State{StringState("off")}, fsm.CurrentStates())
+
+	// entering a state inside a region, the other regions start from the initial child.
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("plug")))
+	assert.Equal(t, StringState("on"), fsm.CurrentState())
+	assert.Equal(t, []State{StringState("offline"), StringState("charging")}, fsm.CurrentStates())
+
+	// the parallel state handles the event if no region does.
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("powerOff")))
+	assert.Equal(t, StringState("off"), fsm.CurrentState())
+}
+
+func TestParallelStateDefinition(t *testing.T) {
+	def := newDeviceFSM(t).Definition()
+	assert.True(t, def.Composites[0].Parallel)
+	reloaded, err := NewFSMFromDefinition(def, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, def, reloaded.Definition())
+	assert.Nil(t, reloaded.ProcessEvent(StringEvent("powerOn")))
+	assert.Equal(t, []State{StringState("offline"), StringState("discharging")}, reloaded.CurrentStates())
+}
+
+func TestRestore(t *testing.T) {
+	fsm := newDeviceFSM(t)
+	assert.NotNil(t, fsm.Restore(nil, 0))
+	assert.NotNil(t, fsm.Restore([]State{StringState("off"), StringState("online")}, 0))
+	assert.NotNil(t, fsm.Restore([]State{StringState("online"), StringState("offline")}, 0))
+	assert.Nil(t, fsm.Restore([]State{StringState("online"), StringState("charging")}, 0))
+	assert.Equal(t, StringState("on"), fsm.CurrentState())
+	assert.Equal(t, []State{StringState("online"), StringState("charging")}, fsm.CurrentStates())
+
+	assert.Nil(t, fsm.Restore([]State{StringState("off")}, 42))
+	assert.Equal(t, []State{StringState("off")}, fsm.CurrentStates())
+	assert.Equal(t, uint64(42), fsm.Version())
+}
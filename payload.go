@@ -0,0 +1,78 @@
+package fsm
+
+// Payload returns the payload passed to the actions and guards. See `NewFSM`.
+func (fsm *FSM) Payload() interface{} {
+	return fsm.payload
+}
+
+// UpdatePayload replaces the payload by the one returned by update, which receives the current payload. The
+// payload is passed to the actions and guards of the later events.
+// NOTE: Like `ProcessEvent`, it is not thread-safe. The queued variants, i.e., `QueuedFSM` and `PreemptiveFSM`,
+// update the payload between their events.
+func (fsm *FSM) UpdatePayload(update func(payload interface{}) interface{}) {
+	fsm.payload = update(fsm.payload)
+}
+
+// PayloadAs returns the payload of the machine as P, e.g., `fsm.PayloadAs[*Order](machine)`. It returns false if
+// the payload is not a P.
+func PayloadAs[P any](machine interface{ Payload() interface{} }) (P, bool) {
+	payload, ok := machine.Payload().(P)
+	return payload, ok
+}
+
+// Do invokes fn with the FSM between the queued events, so fn can read or modify the machine without racing with
+// the actions, and returns the error of fn. fn is invoked after the events queued before.
+// NOTE: Like `ProcessEvent`, it should not be invoked in action/guard.
+func (q *QueuedFSM) Do(fn func(fsm *FSM) error) error {
+	entry := getEventEntry(nil, nil)
+	entry.do = fn
+	q.push(entry)
+	return entry.wait()
+}
+
+// Payload is the same as `FSM.Payload`, but the payload is read between the queued events. See `Do`.
+func (q *QueuedFSM) Payload() interface{} {
+	var payload interface{}
+	_ = q.Do(func(fsm *FSM) error {
+		payload = fsm.Payload()
+		return nil
+	})
+	return payload
+}
+
+// UpdatePayload is the same as `FSM.UpdatePayload`, but the payload is updated between the queued events. See
+// `Do`.
+func (q *QueuedFSM) UpdatePayload(update func(payload interface{}) interface{}) {
+	_ = q.Do(func(fsm *FSM) error {
+		fsm.UpdatePayload(update)
+		return nil
+	})
+}
+
+// Do invokes fn with the FSM when no event is being processed, so fn can read or modify the machine without
+// racing with the actions, and returns the error of fn. Unlike the events, fn is never preempted.
+// NOTE: Like `ProcessEvent`, it should not be invoked in action/guard.
+func (p *PreemptiveFSM) Do(fn func(fsm *FSM) error) error {
+	p.processing.Lock()
+	defer p.processing.Unlock()
+	return fn(p.FSM)
+}
+
+// Payload is the same as `FSM.Payload`, but the payload is read when no event is being processed. See `Do`.
+func (p *PreemptiveFSM) Payload() interface{} {
+	var payload interface{}
+	_ = p.Do(func(fsm *FSM) error {
+		payload = fsm.Payload()
+		return nil
+	})
+	return payload
+}
+
+// UpdatePayload is the same as `FSM.UpdatePayload`, but the payload is updated when no event is being
+// processed. See `Do`.
+func (p *PreemptiveFSM) UpdatePayload(update func(payload interface{}) interface{}) {
+	_ = p.Do(func(fsm *FSM) error {
+		fsm.UpdatePayload(update)
+		return nil
+	})
+}
@@ -0,0 +1,72 @@
+package fsm
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestPayload(t *testing.T) {
+	fsm := newAccountFSM(NewFSM(StringState("open"), &account{}))
+	acc, ok := PayloadAs[*account](fsm)
+	assert.True(t, ok)
+	assert.Same(t, fsm.Payload(), acc)
+	_, ok = PayloadAs[string](fsm)
+	assert.False(t, ok)
+
+	fsm.UpdatePayload(func(interface{}) interface{} {
+		return &account{balance: 100}
+	})
+	result, err := fsm.ProcessEventWithResult(&depositEvent{amount: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, 101, result)
+}
+
+func TestQueuedPayload(t *testing.T) {
+	queued := NewQueuedFSM(StringState("open"), &account{})
+	defer queued.Close()
+	newAccountFSM(queued.FSM)
+	pool := NewWorkerPool(2)
+	defer pool.Close()
+	pooled := pool.NewQueuedFSM(StringState("open"), &account{})
+	newAccountFSM(pooled.FSM)
+	preemptive := NewPreemptiveFSM(StringState("open"), &account{})
+	defer preemptive.Close()
+	newAccountFSM(preemptive.FSM)
+
+	for _, machine := range []interface {
+		ProcessEvent(ev Event) error
+		Payload() interface{}
+		UpdatePayload(update func(payload interface{}) interface{})
+		Do(fn func(fsm *FSM) error) error
+	}{queued, pooled, preemptive} {
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				_ = machine.ProcessEvent(&depositEvent{amount: 1})
+			}()
+			go func() {
+				defer wg.Done()
+				// the payload is updated without racing with the actions.
+				machine.UpdatePayload(func(payload interface{}) interface{} {
+					payload.(*account).balance += 1000
+					return payload
+				})
+			}()
+		}
+		wg.Wait()
+		acc, ok := PayloadAs[*account](machine)
+		assert.True(t, ok)
+		assert.Equal(t, 50000, acc.balance/1000*1000)
+		assert.Equal(t, errors.New("boom"), machine.Do(func(*FSM) error {
+			return errors.New("boom")
+		}))
+	}
+
+	// the pooled machine cannot be accessed after it is closed.
+	assert.Nil(t, pooled.Close())
+	assert.Equal(t, ErrQueueClosed, pooled.Do(func(*FSM) error { return nil }))
+}
@@ -0,0 +1,21 @@
+module github.com/reyoung/fsm/persist/bolt
+
+go 1.21
+
+require (
+	github.com/reyoung/fsm v0.1.0
+	github.com/stretchr/testify v1.8.4
+	go.etcd.io/bbolt v1.3.8
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/emicklei/dot v0.10.2 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/reyoung/delegate v0.1.1 // indirect
+	golang.org/x/sys v0.4.0 // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/reyoung/fsm => ../../
@@ -0,0 +1,148 @@
+// Package bolt implements `persist.Store` by the embedded database bbolt.
+package bolt
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/reyoung/fsm/persist"
+	"go.etcd.io/bbolt"
+	"time"
+)
+
+var (
+	eventsBucket    = []byte("events")
+	snapshotsBucket = []byte("snapshots")
+)
+
+// Store stores the journal of each machine in a sub bucket of bucket "events", keyed by the big-endian
+// sequence number. The snapshots are stored in bucket "snapshots", keyed by the machine id. It is thread-safe.
+type Store struct {
+	db *bbolt.DB
+}
+
+var _ persist.Store = (*Store)(nil)
+
+// Open opens or creates the database file. The store should be closed by `Close`.
+func Open(path string) (*Store, error) {
+	db, err := bbolt.Open(path, 0644, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	store, err := New(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// New creates the store in an opened database, the buckets are created if they do not exist.
+func New(db *bbolt.DB) (*Store, error) {
+	err := db.Update(func(tx *bbolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(eventsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(snapshotsBucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// DB returns the underlying database.
+func (s *Store) DB() *bbolt.DB {
+	return s.db
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func seqKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+func (s *Store) AppendEvent(_ context.Context, id string, record persist.Record) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		journal, err := tx.Bucket(eventsBucket).CreateBucketIfNotExists([]byte(id))
+		if err != nil {
+			return err
+		}
+		lastSeq := uint64(0)
+		if k, _ := journal.Cursor().Last(); k != nil {
+			lastSeq = binary.BigEndian.Uint64(k)
+		}
+		if record.Seq != lastSeq+1 {
+			return persist.ErrConflict
+		}
+		return journal.Put(seqKey(record.Seq), value)
+	})
+}
+
+func (s *Store) LoadEvents(_ context.Context, id string, afterSeq uint64) ([]persist.Record, error) {
+	result := make([]persist.Record, 0)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		journal := tx.Bucket(eventsBucket).Bucket([]byte(id))
+		if journal == nil {
+			return nil
+		}
+		c := journal.Cursor()
+		for k, v := c.Seek(seqKey(afterSeq + 1)); k != nil; k, v = c.Next() {
+			var record persist.Record
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			result = append(result, record)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *Store) SaveSnapshot(_ context.Context, id string, snapshot persist.Snapshot) error {
+	value, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		snapshots := tx.Bucket(snapshotsBucket)
+		if saved := snapshots.Get([]byte(id)); saved != nil {
+			var savedSnapshot persist.Snapshot
+			if err := json.Unmarshal(saved, &savedSnapshot); err != nil {
+				return err
+			}
+			if savedSnapshot.Seq > snapshot.Seq {
+				return persist.ErrConflict
+			}
+		}
+		return snapshots.Put([]byte(id), value)
+	})
+}
+
+func (s *Store) LoadSnapshot(_ context.Context, id string) (*persist.Snapshot, error) {
+	var snapshot *persist.Snapshot
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		value := tx.Bucket(snapshotsBucket).Get([]byte(id))
+		if value == nil {
+			return nil
+		}
+		snapshot = &persist.Snapshot{}
+		return json.Unmarshal(value, snapshot)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,70 @@
+package bolt
+
+import (
+	"context"
+	"github.com/reyoung/fsm"
+	"github.com/reyoung/fsm/persist"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "fsm.db")
+	store, err := Open(path)
+	assert.Nil(t, err)
+	snapshot, err := store.LoadSnapshot(ctx, "order-1")
+	assert.Nil(t, err)
+	assert.Nil(t, snapshot)
+
+	now := time.Unix(1700000000, 0).UTC()
+	for i := uint64(1); i <= 3; i++ {
+		assert.Nil(t, store.AppendEvent(ctx, "order-1", persist.Record{Seq: i, EventID: "pay", Time: now}))
+	}
+	assert.Equal(t, persist.ErrConflict,
+		store.AppendEvent(ctx, "order-1", persist.Record{Seq: 3, EventID: "pay", Time: now}))
+	assert.Nil(t, store.SaveSnapshot(ctx, "order-1", persist.Snapshot{Seq: 1, States: []string{"paid"}, Time: now}))
+	assert.Equal(t, persist.ErrConflict, store.SaveSnapshot(ctx, "order-1", persist.Snapshot{Seq: 0}))
+	assert.Nil(t, store.Close())
+
+	store, err = Open(path)
+	assert.Nil(t, err)
+	defer store.Close()
+	records, err := store.LoadEvents(ctx, "order-1", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []persist.Record{
+		{Seq: 2, EventID: "pay", Time: now},
+		{Seq: 3, EventID: "pay", Time: now},
+	}, records)
+	records, err = store.LoadEvents(ctx, "order-2", 0)
+	assert.Nil(t, err)
+	assert.Empty(t, records)
+	snapshot, err = store.LoadSnapshot(ctx, "order-1")
+	assert.Nil(t, err)
+	assert.Equal(t, &persist.Snapshot{Seq: 1, States: []string{"paid"}, Time: now}, snapshot)
+}
+
+func TestPersistentFSM(t *testing.T) {
+	newMachine := func() *fsm.FSM {
+		machine := fsm.NewFSM(fsm.StringState("off"), nil)
+		assert.Nil(t, machine.AddState(fsm.StringState("on")))
+		assert.Nil(t, machine.AddEvent("switch"))
+		assert.Nil(t, machine.AddTransition(fsm.StringState("off"), "switch", fsm.StringState("on"), nil, nil))
+		assert.Nil(t, machine.AddTransition(fsm.StringState("on"), "switch", fsm.StringState("off"), nil, nil))
+		return machine
+	}
+	store, err := Open(filepath.Join(t.TempDir(), "fsm.db"))
+	assert.Nil(t, err)
+	defer store.Close()
+	light := persist.New(newMachine(), "light", store, nil)
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, light.ProcessEvent(fsm.StringEvent("switch")))
+	}
+
+	rebuilt := persist.New(newMachine(), "light", store, nil)
+	assert.Nil(t, rebuilt.Recover(context.Background()))
+	assert.Equal(t, fsm.StringState("on"), rebuilt.CurrentState())
+	assert.Equal(t, uint64(3), rebuilt.Seq())
+}
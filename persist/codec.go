@@ -0,0 +1,85 @@
+package persist
+
+import (
+	"encoding/json"
+	"github.com/reyoung/fsm"
+	"sync"
+)
+
+// Codec encodes the events into `Record.Data`, and decodes them when the FSM is rebuilt.
+type Codec interface {
+	Encode(ev fsm.Event) ([]byte, error)
+	Decode(evID string, data []byte) (fsm.Event, error)
+}
+
+// JSONCodec encodes events by `encoding/json`. The events are decoded into the types registered by `Register`,
+// or into `fsm.StringEvent` if the event id is not registered. It is thread-safe.
+type JSONCodec struct {
+	mu        sync.RWMutex
+	factories map[string]func() fsm.Event
+}
+
+func NewJSONCodec() *JSONCodec {
+	return &JSONCodec{factories: make(map[string]func() fsm.Event)}
+}
+
+// Register registers the event type of evID. `newEvent` should return a pointer, which the data is
+// unmarshalled into.
+func (c *JSONCodec) Register(evID string, newEvent func() fsm.Event) *JSONCodec {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.factories[evID] = newEvent
+	return c
+}
+
+func (c *JSONCodec) Encode(ev fsm.Event) ([]byte, error) {
+	if _, ok := ev.(fsm.StringEvent); ok {
+		return nil, nil
+	}
+	return json.Marshal(ev)
+}
+
+func (c *JSONCodec) Decode(evID string, data []byte) (fsm.Event, error) {
+	c.mu.RLock()
+	newEvent, ok := c.factories[evID]
+	c.mu.RUnlock()
+	if !ok {
+		return fsm.StringEvent(evID), nil
+	}
+	ev := newEvent()
+	if len(data) == 0 {
+		return ev, nil
+	}
+	if err := json.Unmarshal(data, ev); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
+
+// encodeEvent encodes the event by codec. If ev is a `fsm.Envelope`, the wrapped event is encoded, and the
+// envelope without the event is returned to be journaled with the data.
+func encodeEvent(codec Codec, ev fsm.Event) ([]byte, *fsm.Envelope, error) {
+	env, ok := ev.(*fsm.Envelope)
+	if !ok {
+		data, err := codec.Encode(ev)
+		return data, nil, err
+	}
+	data, err := codec.Encode(env.Event)
+	if err != nil {
+		return nil, nil, err
+	}
+	metadata := *env
+	metadata.Event = nil
+	return data, &metadata, nil
+}
+
+// decodeEvent decodes the event encoded by `encodeEvent`, and wraps it by env if env is not nil.
+func decodeEvent(codec Codec, evID string, data []byte, env *fsm.Envelope) (fsm.Event, error) {
+	ev, err := codec.Decode(evID, data)
+	if err != nil || env == nil {
+		return ev, err
+	}
+	wrapped := *env
+	wrapped.Event = ev
+	return &wrapped, nil
+}
@@ -0,0 +1,214 @@
+package persist
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileStore is a `Store` and a `ScheduleStore` in a directory. The journal of each machine is a file of JSON
+// lines, and the snapshot and the scheduled events are JSON files which are replaced atomically.
+// NOTE: the directory should not be shared by multiple processes.
+type FileStore struct {
+	mu  sync.Mutex
+	dir string
+	// lastSeq caches the Seq of the last record of each journal.
+	lastSeq map[string]uint64
+}
+
+// NewFileStore creates the store in dir, the dir is created if it does not exist.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir, lastSeq: make(map[string]uint64)}, nil
+}
+
+func (s *FileStore) path(id string, ext string) string {
+	return filepath.Join(s.dir, url.PathEscape(id)+ext)
+}
+
+func (s *FileStore) AppendEvent(_ context.Context, id string, record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lastSeq, ok := s.lastSeq[id]
+	if !ok {
+		records, err := s.loadEvents(id, 0)
+		if err != nil {
+			return err
+		}
+		if len(records) != 0 {
+			lastSeq = records[len(records)-1].Seq
+		}
+	}
+	if record.Seq != lastSeq+1 {
+		return ErrConflict
+	}
+	f, err := os.OpenFile(s.path(id, ".events"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(line, '\n')); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		s.lastSeq[id] = record.Seq
+	} else {
+		// the file may be written partially.
+		delete(s.lastSeq, id)
+	}
+	return err
+}
+
+func (s *FileStore) LoadEvents(_ context.Context, id string, afterSeq uint64) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadEvents(id, afterSeq)
+}
+
+func (s *FileStore) loadEvents(id string, afterSeq uint64) ([]Record, error) {
+	result := make([]Record, 0)
+	f, err := os.Open(s.path(id, ".events"))
+	if errors.Is(err, os.ErrNotExist) {
+		return result, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, err
+		}
+		if record.Seq > afterSeq {
+			result = append(result, record)
+		}
+	}
+	return result, scanner.Err()
+}
+
+func (s *FileStore) SaveSnapshot(_ context.Context, id string, snapshot Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saved, err := s.loadSnapshot(id)
+	if err != nil {
+		return err
+	}
+	if saved != nil && saved.Seq > snapshot.Seq {
+		return ErrConflict
+	}
+	tmp := s.path(id, ".snapshot.tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(id, ".snapshot"))
+}
+
+func (s *FileStore) LoadSnapshot(_ context.Context, id string) (*Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadSnapshot(id)
+}
+
+func (s *FileStore) loadSnapshot(id string) (*Snapshot, error) {
+	data, err := os.ReadFile(s.path(id, ".snapshot"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	snapshot := &Snapshot{}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+func (s *FileStore) SaveScheduled(_ context.Context, id string, scheduled ScheduledEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending, err := s.loadScheduled(id)
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i := range pending {
+		if pending[i].Token == scheduled.Token {
+			pending[i], replaced = scheduled, true
+		}
+	}
+	if !replaced {
+		pending = append(pending, scheduled)
+	}
+	return s.saveScheduled(id, pending)
+}
+
+func (s *FileStore) DeleteScheduled(_ context.Context, id string, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending, err := s.loadScheduled(id)
+	if err != nil {
+		return err
+	}
+	kept := pending[:0]
+	for _, scheduled := range pending {
+		if scheduled.Token != token {
+			kept = append(kept, scheduled)
+		}
+	}
+	if len(kept) == len(pending) {
+		return nil
+	}
+	return s.saveScheduled(id, kept)
+}
+
+func (s *FileStore) LoadScheduled(_ context.Context, id string) ([]ScheduledEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadScheduled(id)
+}
+
+func (s *FileStore) loadScheduled(id string) ([]ScheduledEvent, error) {
+	result := make([]ScheduledEvent, 0)
+	data, err := os.ReadFile(s.path(id, ".scheduled"))
+	if errors.Is(err, os.ErrNotExist) {
+		return result, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// saveScheduled replaces the scheduled events of machine id atomically.
+func (s *FileStore) saveScheduled(id string, pending []ScheduledEvent) error {
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return err
+	}
+	tmp := s.path(id, ".scheduled.tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(id, ".scheduled"))
+}
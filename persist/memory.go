@@ -0,0 +1,98 @@
+package persist
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore is a `Store` and a `ScheduleStore` in memory. It is useful in tests.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	events    map[string][]Record
+	snapshots map[string]Snapshot
+	scheduled map[string]map[string]ScheduledEvent
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		events:    make(map[string][]Record),
+		snapshots: make(map[string]Snapshot),
+		scheduled: make(map[string]map[string]ScheduledEvent),
+	}
+}
+
+func (s *MemoryStore) AppendEvent(_ context.Context, id string, record Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	journal := s.events[id]
+	lastSeq := uint64(0)
+	if len(journal) != 0 {
+		lastSeq = journal[len(journal)-1].Seq
+	}
+	if record.Seq != lastSeq+1 {
+		return ErrConflict
+	}
+	s.events[id] = append(journal, record)
+	return nil
+}
+
+func (s *MemoryStore) LoadEvents(_ context.Context, id string, afterSeq uint64) ([]Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]Record, 0)
+	for _, record := range s.events[id] {
+		if record.Seq > afterSeq {
+			result = append(result, record)
+		}
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) SaveSnapshot(_ context.Context, id string, snapshot Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if saved, ok := s.snapshots[id]; ok && saved.Seq > snapshot.Seq {
+		return ErrConflict
+	}
+	snapshot.States = append([]string(nil), snapshot.States...)
+	s.snapshots[id] = snapshot
+	return nil
+}
+
+func (s *MemoryStore) LoadSnapshot(_ context.Context, id string) (*Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snapshot, ok := s.snapshots[id]
+	if !ok {
+		return nil, nil
+	}
+	snapshot.States = append([]string(nil), snapshot.States...)
+	return &snapshot, nil
+}
+
+func (s *MemoryStore) SaveScheduled(_ context.Context, id string, scheduled ScheduledEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.scheduled[id] == nil {
+		s.scheduled[id] = make(map[string]ScheduledEvent)
+	}
+	s.scheduled[id][scheduled.Token] = scheduled
+	return nil
+}
+
+func (s *MemoryStore) DeleteScheduled(_ context.Context, id string, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.scheduled[id], token)
+	return nil
+}
+
+func (s *MemoryStore) LoadScheduled(_ context.Context, id string) ([]ScheduledEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]ScheduledEvent, 0, len(s.scheduled[id]))
+	for _, scheduled := range s.scheduled[id] {
+		result = append(result, scheduled)
+	}
+	return result, nil
+}
@@ -0,0 +1,106 @@
+package persist
+
+import (
+	"errors"
+	"fmt"
+	"github.com/reyoung/fsm"
+)
+
+// Migrations migrates the states of the snapshots saved by the older `fsm.FSM.DefinitionVersion`, so the
+// long-lived machines survive the changes of their topology:
+//
+//	migrations := persist.NewMigrations()
+//	// v2 renames "paid" to "charged", and drops "legacy".
+//	_ = migrations.Add("v1", "v2", persist.RenameStates(map[string]string{"paid": "charged", "legacy": "failed"}))
+//	p := persist.New(machine, id, store, nil)
+//	p.SetMigrations(migrations)
+//
+// The migrations are chained, e.g., the snapshot of v1 is migrated by the migrations v1 -> v2 and v2 -> v3 if the
+// machine is of v3. The snapshots saved before the definition versions are used have the version "".
+type Migrations struct {
+	steps map[string]migration
+}
+
+type migration struct {
+	to      string
+	migrate func(oldState string) string
+}
+
+func NewMigrations() *Migrations {
+	return &Migrations{steps: make(map[string]migration)}
+}
+
+// Add adds the migration from the definition version `from` to `to`. migrate maps each state of the snapshot to
+// the state of `to`, like the migrate of `fsm.FSM.SwapDefinition`. At most one migration can be added from a
+// version.
+func (m *Migrations) Add(from, to string, migrate func(oldState string) string) error {
+	if from == to {
+		return errors.New(fmt.Sprintf("the migration from definition version %q should not be to itself", from))
+	}
+	if step, ok := m.steps[from]; ok {
+		return errors.New(fmt.Sprintf("the migration from definition version %q to %q is added", from, step.to))
+	}
+	m.steps[from] = migration{to: to, migrate: migrate}
+	return nil
+}
+
+// RenameStates returns the migrate function of `Migrations.Add` which maps the states by mapping, e.g., the
+// renamed states to their new ids, and the dropped states to the fallback states. The states not in mapping are
+// kept.
+func RenameStates(mapping map[string]string) func(oldState string) string {
+	return func(oldState string) string {
+		if state, ok := mapping[oldState]; ok {
+			return state
+		}
+		return oldState
+	}
+}
+
+// migrate migrates the states of definition version `from` to `to`.
+func (m *Migrations) migrate(states []string, from, to string) ([]string, error) {
+	result := append([]string(nil), states...)
+	for version, visited := from, 0; version != to; visited++ {
+		step, ok := m.steps[version]
+		if !ok || visited == len(m.steps) {
+			return nil, errors.New(fmt.Sprintf("no migration of the snapshot from definition version %q to %q",
+				from, to))
+		}
+		for i, state := range result {
+			result[i] = step.migrate(state)
+		}
+		version = step.to
+	}
+	return result, nil
+}
+
+// restoreSnapshot restores the machine from the snapshot, the states are migrated if the snapshot is saved by
+// another definition version.
+func restoreSnapshot(machine *fsm.FSM, snapshot *Snapshot, migrations *Migrations) error {
+	ids := snapshot.States
+	if snapshot.DefinitionVersion != machine.DefinitionVersion() {
+		if migrations == nil {
+			return errors.New(fmt.Sprintf("the snapshot of definition version %q cannot be restored to %q "+
+				"without migrations", snapshot.DefinitionVersion, machine.DefinitionVersion()))
+		}
+		var err error
+		if ids, err = migrations.migrate(ids, snapshot.DefinitionVersion, machine.DefinitionVersion()); err != nil {
+			return err
+		}
+	}
+	states := make([]fsm.State, 0, len(ids))
+	for _, id := range ids {
+		states = append(states, stateByID(machine, id))
+	}
+	return machine.Restore(states, snapshot.Version)
+}
+
+// newSnapshot returns the snapshot of the current states of the machine.
+func newSnapshot(machine *fsm.FSM, seq uint64) Snapshot {
+	states := machine.CurrentStates()
+	snapshot := Snapshot{Seq: seq, States: make([]string, 0, len(states)), Version: machine.Version(),
+		DefinitionVersion: machine.DefinitionVersion(), Time: machine.Clock().Now()}
+	for _, state := range states {
+		snapshot.States = append(snapshot.States, state.FSMStateID())
+	}
+	return snapshot
+}
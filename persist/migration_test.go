@@ -0,0 +1,94 @@
+package persist
+
+import (
+	"context"
+	"github.com/reyoung/fsm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// newOrderV3 is the order of definition version v3, which renames "paid" to "charged".
+func newOrderV3(t *testing.T) *fsm.FSM {
+	machine := fsm.NewFSM(fsm.StringState("created"), nil)
+	machine.SetDefinitionVersion("v3")
+	assert.Nil(t, machine.AddState(fsm.StringState("charged")))
+	assert.Nil(t, machine.AddState(fsm.StringState("shipped")))
+	assert.Nil(t, machine.AddEvent("pay"))
+	assert.Nil(t, machine.AddEvent("ship"))
+	assert.Nil(t, machine.AddTransition(fsm.StringState("created"), "pay", fsm.StringState("charged"), nil, nil))
+	assert.Nil(t, machine.AddTransition(fsm.StringState("charged"), "ship", fsm.StringState("shipped"), nil, nil))
+	return machine
+}
+
+func newOrderMigrations(t *testing.T) *Migrations {
+	migrations := NewMigrations()
+	assert.Nil(t, migrations.Add("v1", "v2", RenameStates(map[string]string{"paid": "charged"})))
+	// v3 drops the state "legacy" of v2.
+	assert.Nil(t, migrations.Add("v2", "v3", RenameStates(map[string]string{"legacy": "created"})))
+	return migrations
+}
+
+func TestPersistentFSMMigration(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	codec := NewJSONCodec().Register("pay", func() fsm.Event { return &payEvent{} })
+	paid := 0
+	v1 := newOrderFSM(t, &paid)
+	v1.SetDefinitionVersion("v1")
+	order := New(v1, "order-1", store, codec)
+	assert.Nil(t, order.ProcessEvent(&payEvent{Amount: 10}))
+	assert.Nil(t, order.Snapshot(ctx))
+	snapshot, err := store.LoadSnapshot(ctx, "order-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "v1", snapshot.DefinitionVersion)
+	assert.Nil(t, order.ProcessEvent(fsm.StringEvent("ship")))
+
+	// the snapshot of v1 cannot be restored without migrations.
+	rebuilt := New(newOrderV3(t), "order-1", store, codec)
+	assert.NotNil(t, rebuilt.Recover(ctx))
+
+	// the snapshot is migrated by v1 -> v2 -> v3, and the events after it are replayed by v3.
+	rebuilt = New(newOrderV3(t), "order-1", store, codec)
+	rebuilt.SetMigrations(newOrderMigrations(t))
+	assert.Nil(t, rebuilt.Recover(ctx))
+	assert.Equal(t, fsm.StringState("shipped"), rebuilt.CurrentState())
+	assert.Equal(t, uint64(2), rebuilt.Version())
+
+	assert.Nil(t, rebuilt.Snapshot(ctx))
+	snapshot, err = store.LoadSnapshot(ctx, "order-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "v3", snapshot.DefinitionVersion)
+}
+
+func TestPassivatorMigration(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	assert.Nil(t, store.SaveSnapshot(ctx, "1", Snapshot{States: []string{"legacy"}, Version: 3,
+		DefinitionVersion: "v2"}))
+	p := NewPassivator[int](store, nil)
+	p.SetMigrations(newOrderMigrations(t))
+	machine := newOrderV3(t)
+	assert.Nil(t, p.Activate(1, machine))
+	assert.Equal(t, fsm.StringState("created"), machine.CurrentState())
+	assert.Equal(t, uint64(3), machine.Version())
+}
+
+func TestMigrations(t *testing.T) {
+	migrations := newOrderMigrations(t)
+	assert.NotNil(t, migrations.Add("v1", "v4", nil))
+	assert.NotNil(t, migrations.Add("v4", "v4", nil))
+
+	states, err := migrations.migrate([]string{"paid", "created"}, "v1", "v3")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"charged", "created"}, states)
+	states, err = migrations.migrate([]string{"paid"}, "v3", "v3")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"paid"}, states)
+	_, err = migrations.migrate([]string{"paid"}, "v0", "v3")
+	assert.NotNil(t, err)
+
+	// the cycle of migrations does not reach the version.
+	assert.Nil(t, migrations.Add("v3", "v1", RenameStates(nil)))
+	_, err = migrations.migrate([]string{"paid"}, "v1", "v4")
+	assert.NotNil(t, err)
+}
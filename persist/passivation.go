@@ -0,0 +1,56 @@
+package persist
+
+import (
+	"context"
+	"fmt"
+	"github.com/reyoung/fsm"
+)
+
+// Passivator saves the snapshots of the idle machines of a `fsm.Manager` into a `Store`, and restores them when
+// the machines are re-activated:
+//
+//	p := persist.NewPassivator[string](store, nil)
+//	manager := fsm.NewManager(newOrder, fsm.ManagerOptions[string]{
+//		IdleTimeout: time.Minute,
+//		OnPassivate: p.Passivate,
+//		OnActivate:  p.Activate,
+//	})
+//
+// NOTE: the snapshots are saved with `Seq` 0, the ids should not be shared with the journals of `PersistentFSM`.
+type Passivator[K comparable] struct {
+	store      Store
+	id         func(key K) string
+	migrations *Migrations
+}
+
+// NewPassivator creates a passivator storing the snapshot of key by id(key). The keys are formatted by
+// `fmt.Sprint` if id is nil.
+func NewPassivator[K comparable](store Store, id func(key K) string) *Passivator[K] {
+	if id == nil {
+		id = func(key K) string {
+			return fmt.Sprint(key)
+		}
+	}
+	return &Passivator[K]{store: store, id: id}
+}
+
+// SetMigrations sets the migrations of the snapshots saved by other `FSM.DefinitionVersion`, see
+// `PersistentFSM.SetMigrations`.
+func (p *Passivator[K]) SetMigrations(migrations *Migrations) {
+	p.migrations = migrations
+}
+
+// Passivate saves the current states, the version and the definition version of the machine.
+func (p *Passivator[K]) Passivate(key K, machine *fsm.FSM) error {
+	return p.store.SaveSnapshot(context.Background(), p.id(key), newSnapshot(machine, 0))
+}
+
+// Activate restores the machine from its snapshot. The machine is kept in its initial state if there is no
+// snapshot. The snapshot of another definition version is migrated by `SetMigrations`.
+func (p *Passivator[K]) Activate(key K, machine *fsm.FSM) error {
+	snapshot, err := p.store.LoadSnapshot(context.Background(), p.id(key))
+	if err != nil || snapshot == nil {
+		return err
+	}
+	return restoreSnapshot(machine, snapshot, p.migrations)
+}
@@ -0,0 +1,73 @@
+package persist
+
+import (
+	"context"
+	"errors"
+	"github.com/reyoung/fsm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// failingStore fails to save the snapshots.
+type failingStore struct {
+	*MemoryStore
+}
+
+func (failingStore) SaveSnapshot(context.Context, string, Snapshot) error {
+	return errors.New("store is down")
+}
+
+func TestPassivator(t *testing.T) {
+	store := NewMemoryStore()
+	p := NewPassivator[int](store, nil)
+	paid := 0
+	passivated := make(chan int, 1)
+	manager := fsm.NewManager(func(int) (*fsm.FSM, error) {
+		return newOrderFSM(t, &paid), nil
+	}, fsm.ManagerOptions[int]{
+		IdleTimeout: 10 * time.Millisecond,
+		OnPassivate: p.Passivate,
+		OnActivate:  p.Activate,
+		OnEvict: func(key int, _ *fsm.FSM) {
+			passivated <- key
+		},
+	})
+	defer manager.Close()
+
+	assert.Nil(t, manager.ProcessEvent(1, &payEvent{Amount: 10}))
+	assert.Equal(t, 1, <-passivated)
+	snapshot, err := store.LoadSnapshot(context.Background(), "1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"paid"}, snapshot.States)
+	assert.Equal(t, uint64(1), snapshot.Version)
+
+	// the order is re-activated in state paid by the next event.
+	assert.Nil(t, manager.ProcessEvent(1, fsm.StringEvent("ship")))
+	assert.Nil(t, manager.Do(1, func(machine *fsm.FSM) error {
+		assert.Equal(t, fsm.StringState("shipped"), machine.CurrentState())
+		assert.Equal(t, uint64(2), machine.Version())
+		return nil
+	}))
+	assert.Equal(t, 10, paid)
+	stats := manager.Stats()
+	assert.Equal(t, uint64(2), stats.Created)
+	assert.Equal(t, uint64(1), stats.Evicted)
+}
+
+func TestPassivatorError(t *testing.T) {
+	p := NewPassivator[string](failingStore{NewMemoryStore()}, func(key string) string { return "order/" + key })
+	manager := fsm.NewManager(func(string) (*fsm.FSM, error) {
+		paid := 0
+		return newOrderFSM(t, &paid), nil
+	}, fsm.ManagerOptions[string]{OnPassivate: p.Passivate, OnActivate: p.Activate})
+	assert.Nil(t, manager.ProcessEvent("a", &payEvent{Amount: 10}))
+	evicted, err := manager.Evict("a")
+	assert.False(t, evicted)
+	assert.Equal(t, "store is down", err.Error())
+	assert.Equal(t, uint64(1), manager.Stats().PassivateErrors)
+	assert.Equal(t, 1, manager.Stats().Machines)
+
+	// the machines are dropped when the manager is closed even if they fail to be passivated.
+	assert.Nil(t, manager.Close())
+}
@@ -0,0 +1,125 @@
+package persist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/reyoung/fsm"
+)
+
+// PersistentFSM journals every event accepted by the FSM into a `Store`, and rebuilds the FSM by `Recover`.
+// Like `fsm.FSM`, it is not thread-safe.
+type PersistentFSM struct {
+	*fsm.FSM
+	id    string
+	store Store
+	codec Codec
+	seq   uint64
+	// migrations migrates the snapshots of other definition versions, see `SetMigrations`.
+	migrations *Migrations
+}
+
+// New wraps the machine, its journal is stored in `store` by `id`. If codec is nil, a `JSONCodec` without
+// registered events is used, i.e., the events are rebuilt as `fsm.StringEvent`.
+func New(machine *fsm.FSM, id string, store Store, codec Codec) *PersistentFSM {
+	if codec == nil {
+		codec = NewJSONCodec()
+	}
+	return &PersistentFSM{FSM: machine, id: id, store: store, codec: codec}
+}
+
+// ID returns the id of the machine in the store.
+func (p *PersistentFSM) ID() string {
+	return p.id
+}
+
+// Seq returns the sequence number of the last journaled event.
+func (p *PersistentFSM) Seq() uint64 {
+	return p.seq
+}
+
+func (p *PersistentFSM) ProcessEvent(ev fsm.Event) error {
+	return p.ProcessEventContext(context.Background(), ev)
+}
+
+// ProcessEventContext processes the event, and journals it if it is accepted. If the journal is appended by
+// others since the FSM was recovered, `ErrConflict` is returned. If ev is a `fsm.Envelope`, the wrapped event is
+// encoded by the codec, and the metadata is journaled in `Record.Envelope`.
+// NOTE: if the store fails, the error is returned but the transition is not reverted.
+func (p *PersistentFSM) ProcessEventContext(ctx context.Context, ev fsm.Event) error {
+	if err := p.FSM.ProcessEventContext(ctx, ev); err != nil {
+		return err
+	}
+	data, env, err := encodeEvent(p.codec, ev)
+	if err != nil {
+		return err
+	}
+	record := Record{Seq: p.seq + 1, EventID: ev.FSMEventID(), Data: data, Envelope: env, Version: p.Version(),
+		Time: p.Clock().Now()}
+	if err := p.store.AppendEvent(ctx, p.id, record); err != nil {
+		return err
+	}
+	p.seq = record.Seq
+	return nil
+}
+
+// SetMigrations sets the migrations of the snapshots saved by other `FSM.DefinitionVersion`. Without migrations,
+// `Recover` fails if the definition version of the snapshot differs.
+func (p *PersistentFSM) SetMigrations(migrations *Migrations) {
+	p.migrations = migrations
+}
+
+// Snapshot saves the current states and the definition version, so that the journaled events before are not
+// replayed by `Recover`.
+func (p *PersistentFSM) Snapshot(ctx context.Context) error {
+	return p.store.SaveSnapshot(ctx, p.id, newSnapshot(p.FSM, p.seq))
+}
+
+// Recover rebuilds the FSM from the latest snapshot and the events journaled after it. The FSM should be in its
+// initial state if there is no snapshot. The events journaled with `fsm.Envelope` are replayed with their envelopes.
+// The journaled events are replayed by `FSM.Replay`, so the actions are not invoked.
+// If the snapshot is saved by another definition version, its states are migrated by `SetMigrations`, and the
+// events after it are replayed by the current definition.
+// NOTE: the migration is repeated by every `Recover` until a new snapshot is saved.
+func (p *PersistentFSM) Recover(ctx context.Context) error {
+	snapshot, err := p.store.LoadSnapshot(ctx, p.id)
+	if err != nil {
+		return err
+	}
+	p.seq = 0
+	if snapshot != nil {
+		if err := restoreSnapshot(p.FSM, snapshot, p.migrations); err != nil {
+			return err
+		}
+		p.seq = snapshot.Seq
+	}
+	records, err := p.store.LoadEvents(ctx, p.id, p.seq)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		ev, err := decodeEvent(p.codec, record.EventID, record.Data, record.Envelope)
+		if err != nil {
+			return err
+		}
+		if err := p.Replay([]fsm.Event{ev}); err != nil {
+			return err
+		}
+		if p.Version() != record.Version {
+			return errors.New(fmt.Sprintf("the version of event %d is %d after replay, but %d is journaled",
+				record.Seq, p.Version(), record.Version))
+		}
+		p.seq = record.Seq
+	}
+	return nil
+}
+
+// stateByID finds the state by id, or returns a `fsm.StringState` if it is not found.
+func stateByID(machine *fsm.FSM, id string) fsm.State {
+	for _, state := range machine.States() {
+		if state.FSMStateID() == id {
+			return state
+		}
+	}
+	return fsm.StringState(id)
+}
@@ -0,0 +1,109 @@
+package persist
+
+import (
+	"context"
+	"github.com/reyoung/fsm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type payEvent struct {
+	Amount int `json:"amount"`
+}
+
+func (*payEvent) FSMEventID() string {
+	return "pay"
+}
+
+func newOrderFSM(t *testing.T, paid *int) *fsm.FSM {
+	machine := fsm.NewFSM(fsm.StringState("created"), nil)
+	setupOrder(t, machine, paid)
+	return machine
+}
+
+func setupOrder(t *testing.T, machine *fsm.FSM, paid *int) {
+	assert.Nil(t, machine.AddState(fsm.StringState("paid")))
+	assert.Nil(t, machine.AddState(fsm.StringState("shipped")))
+	assert.Nil(t, machine.AddEvent("pay"))
+	assert.Nil(t, machine.AddEvent("ship"))
+	assert.Nil(t, machine.AddTransition(fsm.StringState("created"), "pay", fsm.StringState("paid"),
+		func(_ interface{}, ev fsm.Event) error {
+			*paid += fsm.Unwrap(ev).(*payEvent).Amount
+			return nil
+		}, nil))
+	assert.Nil(t, machine.AddTransition(fsm.StringState("paid"), "ship", fsm.StringState("shipped"), nil, nil))
+}
+
+func TestPersistentFSM(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	codec := NewJSONCodec().Register("pay", func() fsm.Event { return &payEvent{} })
+	paid := 0
+	order := New(newOrderFSM(t, &paid), "order-1", store, codec)
+	assert.Nil(t, order.ProcessEvent(&payEvent{Amount: 10}))
+	assert.NotNil(t, order.ProcessEvent(fsm.StringEvent("pay")))
+	assert.Equal(t, uint64(1), order.Seq())
+
+	rebuiltPaid := 0
+	rebuilt := New(newOrderFSM(t, &rebuiltPaid), "order-1", store, codec)
+	assert.Nil(t, rebuilt.Recover(ctx))
+	assert.Equal(t, fsm.StringState("paid"), rebuilt.CurrentState())
+	assert.Equal(t, 0, rebuiltPaid)
+
+	assert.Nil(t, order.Snapshot(ctx))
+	assert.Nil(t, order.ProcessEvent(fsm.StringEvent("ship")))
+	records, err := store.LoadEvents(ctx, "order-1", 0)
+	assert.Nil(t, err)
+	assert.Len(t, records, 2)
+
+	// the events before the snapshot are not replayed.
+	rebuiltPaid = 0
+	rebuilt = New(newOrderFSM(t, &rebuiltPaid), "order-1", store, codec)
+	assert.Nil(t, rebuilt.Recover(ctx))
+	assert.Equal(t, fsm.StringState("shipped"), rebuilt.CurrentState())
+	assert.Equal(t, uint64(2), rebuilt.Seq())
+	assert.Equal(t, uint64(2), rebuilt.Version())
+	assert.Equal(t, 0, rebuiltPaid)
+
+	// the journal is modified by another machine.
+	first := New(newOrderFSM(t, &paid), "order-2", store, codec)
+	second := New(newOrderFSM(t, &paid), "order-2", store, codec)
+	assert.Nil(t, first.ProcessEvent(&payEvent{Amount: 10}))
+	assert.Equal(t, ErrConflict, second.ProcessEvent(&payEvent{Amount: 10}))
+}
+
+func TestPersistentFSMEnvelope(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(t.TempDir())
+	assert.Nil(t, err)
+	codec := NewJSONCodec().Register("pay", func() fsm.Event { return &payEvent{} })
+	paid := 0
+	order := New(newOrderFSM(t, &paid), "order-1", store, codec)
+	env := fsm.NewEnvelope(&payEvent{Amount: 10}, "checkout")
+	assert.Nil(t, order.ProcessEvent(env))
+	assert.Equal(t, 10, paid)
+
+	records, err := store.LoadEvents(ctx, "order-1", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "pay", records[0].EventID)
+	assert.Equal(t, `{"amount":10}`, string(records[0].Data))
+	assert.Equal(t, env.CorrelationID, records[0].Envelope.CorrelationID)
+	assert.Nil(t, records[0].Envelope.Event)
+
+	// the replayed event is wrapped by the journaled envelope, the guards are evaluated by `Replay`.
+	var replayed *fsm.Envelope
+	machine := fsm.NewFSM(fsm.StringState("created"), nil)
+	assert.Nil(t, machine.AddState(fsm.StringState("paid")))
+	assert.Nil(t, machine.AddEvent("pay"))
+	assert.Nil(t, machine.AddTransition(fsm.StringState("created"), "pay", fsm.StringState("paid"), nil,
+		func(_ interface{}, ev fsm.Event) bool {
+			replayed, _ = fsm.EnvelopeOf(ev)
+			return true
+		}))
+	assert.Nil(t, New(machine, "order-1", store, codec).Recover(ctx))
+	assert.NotNil(t, replayed)
+	assert.Equal(t, env.ID, replayed.ID)
+	assert.Equal(t, "checkout", replayed.Source)
+	assert.True(t, env.Time.Equal(replayed.Time))
+	assert.Equal(t, &payEvent{Amount: 10}, replayed.Event)
+}
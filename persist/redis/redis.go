@@ -0,0 +1,259 @@
+// Package redis shares the current states of a logical machine among the replicas of a service by Redis.
+// See `DistributedFSM`.
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/reyoung/fsm"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrLocked is returned when the lock of the machine is not acquired before the context is done.
+	ErrLocked = errors.New("the machine is locked by another owner")
+	// ErrStaleFence is returned when the states are not saved, because the lock expired and was acquired by
+	// another owner while the event was processed.
+	ErrStaleFence = errors.New("the fencing token is stale")
+)
+
+const (
+	// DefaultLockTTL is the default expiration of the lock, see `DistributedFSM.SetLockTTL`.
+	DefaultLockTTL = 10 * time.Second
+	// DefaultRetryInterval is the default interval of acquiring a held lock, see `DistributedFSM.SetRetryInterval`.
+	DefaultRetryInterval = 50 * time.Millisecond
+)
+
+var (
+	// acquireScript sets the lock if it is not held, and increases the fencing token of the machine.
+	acquireScript = redis.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+  return redis.call('HINCRBY', KEYS[2], 'fence', 1)
+end
+return 0`)
+	// commitScript saves the states if the fencing token is not increased by others.
+	commitScript = redis.NewScript(`
+if tonumber(redis.call('HGET', KEYS[1], 'fence')) ~= tonumber(ARGV[1]) then
+  return 0
+end
+redis.call('HSET', KEYS[1], 'states', ARGV[2], 'version', ARGV[3])
+return 1`)
+	// releaseScript deletes the lock if it is still held by the owner.
+	releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('DEL', KEYS[1])
+end
+return 0`)
+)
+
+// Client is the subset of the Redis commands used, e.g., `*redis.Client` or `*redis.ClusterClient`.
+type Client interface {
+	redis.Scripter
+	HMGet(ctx context.Context, key string, fields ...string) *redis.SliceCmd
+}
+
+// DistributedFSM drives a logical machine shared by the replicas of a service. The current states are stored in
+// the Redis hash `<id>`, and every event is processed under the lock `<id>:lock`:
+//   - the lock is acquired by SET NX with a TTL, and a fencing token is issued by increasing the `fence` field
+//     of the hash;
+//   - the states are loaded from Redis, and the event is processed by the local FSM;
+//   - the states are saved only if the fencing token is still the latest, so a stale owner whose lock expired
+//     cannot overwrite the states saved by the next owner;
+//   - the lock is released.
+//
+// The machine id should contain a hash tag, e.g., `{order-42}`, when Redis Cluster is used, since both keys are
+// accessed by scripts. It is thread-safe, the events of a replica are processed one by one.
+// NOTE: the states are shared, but the payload is not. The actions of an event are not reverted when the states
+// fail to be saved.
+type DistributedFSM struct {
+	*fsm.FSM
+	client  Client
+	id      string
+	owner   string
+	initial []string
+
+	mu    sync.Mutex
+	ttl   time.Duration
+	retry time.Duration
+	fence int64
+}
+
+// New wraps the machine, whose states are shared by id. The current states of the machine are used if no state
+// is stored yet.
+func New(machine *fsm.FSM, client Client, id string) *DistributedFSM {
+	d := &DistributedFSM{
+		FSM:    machine,
+		client: client,
+		id:     id,
+		owner:  newOwner(),
+		ttl:    DefaultLockTTL,
+		retry:  DefaultRetryInterval,
+	}
+	d.initial = stateIDs(machine.CurrentStates())
+	return d
+}
+
+func newOwner() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func stateIDs(states []fsm.State) []string {
+	result := make([]string, 0, len(states))
+	for _, state := range states {
+		result = append(result, state.FSMStateID())
+	}
+	return result
+}
+
+// ID returns the id of the machine in Redis.
+func (d *DistributedFSM) ID() string {
+	return d.id
+}
+
+// Fence returns the fencing token of the last processed event, or 0 if no event is processed.
+func (d *DistributedFSM) Fence() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.fence
+}
+
+// SetLockTTL sets the expiration of the lock. It should be longer than the processing of any event, otherwise
+// the event fails with `ErrStaleFence` if the lock is acquired by another owner meanwhile.
+func (d *DistributedFSM) SetLockTTL(ttl time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.ttl = ttl
+}
+
+// SetRetryInterval sets the interval of acquiring the lock held by another owner.
+func (d *DistributedFSM) SetRetryInterval(retry time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.retry = retry
+}
+
+func (d *DistributedFSM) lockKey() string {
+	return d.id + ":lock"
+}
+
+func (d *DistributedFSM) ProcessEvent(ev fsm.Event) error {
+	return d.ProcessEventContext(context.Background(), ev)
+}
+
+// ProcessEventContext processes the event under the lock of the machine. It waits for the lock until ctx is done,
+// and returns `ErrLocked` then.
+func (d *DistributedFSM) ProcessEventContext(ctx context.Context, ev fsm.Event) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// the events are processed by the goroutines holding mu one by one, see `fsm.FSM.SetOwnershipCheck`.
+	defer d.ReleaseOwnership()
+	fence, err := d.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// the lock expires anyway, so the error is ignored.
+		_ = releaseScript.Run(context.Background(), d.client, []string{d.lockKey()}, d.owner).Err()
+	}()
+	if err := d.load(ctx); err != nil {
+		return err
+	}
+	if err := d.FSM.ProcessEventContext(ctx, ev); err != nil {
+		return err
+	}
+	states, err := json.Marshal(stateIDs(d.CurrentStates()))
+	if err != nil {
+		return err
+	}
+	saved, err := commitScript.Run(ctx, d.client, []string{d.id}, fence, string(states), d.Version()).Int64()
+	if err != nil {
+		return err
+	}
+	if saved == 0 {
+		return ErrStaleFence
+	}
+	d.fence = fence
+	return nil
+}
+
+// acquire acquires the lock, and returns the fencing token.
+func (d *DistributedFSM) acquire(ctx context.Context) (int64, error) {
+	for {
+		fence, err := acquireScript.Run(ctx, d.client, []string{d.lockKey(), d.id}, d.owner,
+			d.ttl.Milliseconds()).Int64()
+		if err != nil {
+			return 0, err
+		}
+		if fence != 0 {
+			return fence, nil
+		}
+		timer := time.NewTimer(d.retry)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return 0, ErrLocked
+		case <-timer.C:
+		}
+	}
+}
+
+// CurrentState returns the current state of the local FSM. Unlike `fsm.FSM.CurrentState`, it can be invoked by
+// any goroutine even if the ownership check is enabled.
+func (d *DistributedFSM) CurrentState() fsm.State {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	defer d.ReleaseOwnership()
+	return d.FSM.CurrentState()
+}
+
+// Sync loads the current states from Redis without the lock, so the local FSM reflects the events processed by
+// other replicas.
+func (d *DistributedFSM) Sync(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	defer d.ReleaseOwnership()
+	return d.load(ctx)
+}
+
+// load restores the local FSM to the states stored in Redis, or to the initial states if no state is stored.
+func (d *DistributedFSM) load(ctx context.Context) error {
+	values, err := d.client.HMGet(ctx, d.id, "states", "version").Result()
+	if err != nil {
+		return err
+	}
+	ids, version := d.initial, uint64(0)
+	if len(values) == 2 && values[0] != nil {
+		encoded, _ := values[0].(string)
+		if err := json.Unmarshal([]byte(encoded), &ids); err != nil {
+			return errors.New(fmt.Sprintf("invalid states of machine %s: %v", d.id, err))
+		}
+		text, _ := values[1].(string)
+		if version, err = strconv.ParseUint(text, 10, 64); err != nil {
+			return errors.New(fmt.Sprintf("invalid version of machine %s: %v", d.id, err))
+		}
+	}
+	states := make([]fsm.State, 0, len(ids))
+	for _, id := range ids {
+		states = append(states, d.stateByID(id))
+	}
+	return d.Restore(states, version)
+}
+
+// stateByID finds the state by id, or returns a `fsm.StringState` if it is not found.
+func (d *DistributedFSM) stateByID(id string) fsm.State {
+	for _, state := range d.States() {
+		if state.FSMStateID() == id {
+			return state
+		}
+	}
+	return fsm.StringState(id)
+}
@@ -0,0 +1,202 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/reyoung/fsm"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis emulates the scripts of the package in memory. The locks never expire unless `expire` is invoked.
+type fakeRedis struct {
+	mu      sync.Mutex
+	strings map[string]string
+	hashes  map[string]map[string]string
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{strings: make(map[string]string), hashes: make(map[string]map[string]string)}
+}
+
+func (r *fakeRedis) expire(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.strings, key)
+}
+
+func (r *fakeRedis) hash(key string) map[string]string {
+	if r.hashes[key] == nil {
+		r.hashes[key] = make(map[string]string)
+	}
+	return r.hashes[key]
+}
+
+func (r *fakeRedis) EvalSha(ctx context.Context, sha string, keys []string, args ...interface{}) *redis.Cmd {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cmd := redis.NewCmd(ctx)
+	switch sha {
+	case acquireScript.Hash():
+		if _, ok := r.strings[keys[0]]; ok {
+			cmd.SetVal(int64(0))
+			break
+		}
+		r.strings[keys[0]] = fmt.Sprint(args[0])
+		fence, _ := strconv.ParseInt(r.hash(keys[1])["fence"], 10, 64)
+		r.hash(keys[1])["fence"] = strconv.FormatInt(fence+1, 10)
+		cmd.SetVal(fence + 1)
+	case commitScript.Hash():
+		if r.hash(keys[0])["fence"] != fmt.Sprint(args[0]) {
+			cmd.SetVal(int64(0))
+			break
+		}
+		r.hash(keys[0])["states"] = fmt.Sprint(args[1])
+		r.hash(keys[0])["version"] = fmt.Sprint(args[2])
+		cmd.SetVal(int64(1))
+	case releaseScript.Hash():
+		if owner, ok := r.strings[keys[0]]; ok && owner == fmt.Sprint(args[0]) {
+			delete(r.strings, keys[0])
+			cmd.SetVal(int64(1))
+		} else {
+			cmd.SetVal(int64(0))
+		}
+	default:
+		cmd.SetErr(errors.New("NOSCRIPT No matching script"))
+	}
+	return cmd
+}
+
+func (r *fakeRedis) Eval(ctx context.Context, _ string, _ []string, _ ...interface{}) *redis.Cmd {
+	cmd := redis.NewCmd(ctx)
+	cmd.SetErr(errors.New("unknown script"))
+	return cmd
+}
+
+func (r *fakeRedis) EvalRO(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd {
+	return r.Eval(ctx, script, keys, args...)
+}
+
+func (r *fakeRedis) EvalShaRO(ctx context.Context, sha string, keys []string, args ...interface{}) *redis.Cmd {
+	return r.EvalSha(ctx, sha, keys, args...)
+}
+
+func (r *fakeRedis) ScriptExists(ctx context.Context, _ ...string) *redis.BoolSliceCmd {
+	return redis.NewBoolSliceCmd(ctx)
+}
+
+func (r *fakeRedis) ScriptLoad(ctx context.Context, _ string) *redis.StringCmd {
+	return redis.NewStringCmd(ctx)
+}
+
+func (r *fakeRedis) HMGet(ctx context.Context, key string, fields ...string) *redis.SliceCmd {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	values := make([]interface{}, 0, len(fields))
+	for _, field := range fields {
+		if value, ok := r.hashes[key][field]; ok {
+			values = append(values, value)
+		} else {
+			values = append(values, nil)
+		}
+	}
+	cmd := redis.NewSliceCmd(ctx)
+	cmd.SetVal(values)
+	return cmd
+}
+
+// newLight creates a replica of the light, whose action of "switch" is invoked if action is not nil.
+func newLight(client Client, action func()) *DistributedFSM {
+	var (
+		on  = fsm.StringState("on")
+		off = fsm.StringState("off")
+	)
+	machine := fsm.NewFSM(off, nil)
+	_ = machine.AddState(on)
+	_ = machine.AddEvent("switch")
+	switchAction := func(interface{}, fsm.Event) error {
+		if action != nil {
+			action()
+		}
+		return nil
+	}
+	_ = machine.AddTransition(off, "switch", on, switchAction, nil)
+	_ = machine.AddTransition(on, "switch", off, switchAction, nil)
+	return New(machine, client, "{light}")
+}
+
+func TestDistributedFSM(t *testing.T) {
+	client := newFakeRedis()
+	a, b := newLight(client, nil), newLight(client, nil)
+	assert.Nil(t, a.ProcessEvent(fsm.StringEvent("switch")))
+	assert.Equal(t, fsm.StringState("on"), a.CurrentState())
+	assert.Equal(t, int64(1), a.Fence())
+
+	// b loads the states saved by a before processing.
+	assert.Nil(t, b.ProcessEvent(fsm.StringEvent("switch")))
+	assert.Equal(t, fsm.StringState("off"), b.CurrentState())
+	assert.Equal(t, uint64(2), b.Version())
+	assert.Equal(t, int64(2), b.Fence())
+	assert.Equal(t, map[string]string{"fence": "2", "states": `["off"]`, "version": "2"}, client.hashes["{light}"])
+	assert.Empty(t, client.strings)
+
+	assert.Equal(t, fsm.StringState("on"), a.CurrentState())
+	assert.Nil(t, a.Sync(context.Background()))
+	assert.Equal(t, fsm.StringState("off"), a.CurrentState())
+	assert.Equal(t, uint64(2), a.Version())
+
+	// the rejected events do not change the stored states.
+	assert.NotNil(t, a.ProcessEvent(fsm.StringEvent("unknown")))
+	assert.Equal(t, "2", client.hashes["{light}"]["version"])
+	assert.Empty(t, client.strings)
+}
+
+func TestDistributedFSMLocked(t *testing.T) {
+	client := newFakeRedis()
+	client.strings["{light}:lock"] = "another owner"
+	light := newLight(client, nil)
+	light.SetRetryInterval(time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, ErrLocked, light.ProcessEventContext(ctx, fsm.StringEvent("switch")))
+	assert.Equal(t, fsm.StringState("off"), light.CurrentState())
+
+	// the lock of another owner is not released.
+	assert.Equal(t, "another owner", client.strings["{light}:lock"])
+}
+
+func TestDistributedFSMStaleFence(t *testing.T) {
+	client := newFakeRedis()
+	b := newLight(client, nil)
+	// the lock of a expires while its action is running, and b processes an event meanwhile.
+	a := newLight(client, func() {
+		client.expire("{light}:lock")
+		assert.Nil(t, b.ProcessEvent(fsm.StringEvent("switch")))
+	})
+	assert.Equal(t, ErrStaleFence, a.ProcessEvent(fsm.StringEvent("switch")))
+	assert.Equal(t, map[string]string{"fence": "2", "states": `["on"]`, "version": "1"}, client.hashes["{light}"])
+	assert.Equal(t, int64(0), a.Fence())
+	assert.Equal(t, int64(2), b.Fence())
+}
+
+func TestDistributedFSMOwnership(t *testing.T) {
+	light := newLight(newFakeRedis(), nil)
+	light.SetOwnershipCheck(true)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Nil(t, light.ProcessEvent(fsm.StringEvent("switch")))
+			assert.NotNil(t, light.CurrentState())
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, fsm.StringState("off"), light.CurrentState())
+	assert.Equal(t, uint64(4), light.Version())
+}
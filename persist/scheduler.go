@@ -0,0 +1,280 @@
+package persist
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/reyoung/fsm"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrSchedulerClosed is returned by `Scheduler` after it is closed.
+var ErrSchedulerClosed = errors.New("the scheduler is closed")
+
+// ScheduledEvent is a pending event of a `Scheduler`.
+type ScheduledEvent struct {
+	// Token identifies the scheduled event, see `Scheduler.Cancel`.
+	Token   string `json:"token"`
+	EventID string `json:"event"`
+	Data    []byte `json:"data,omitempty"`
+	// Envelope is the metadata of the event wrapped by `fsm.Envelope`, see `Record.Envelope`.
+	Envelope *fsm.Envelope `json:"envelope,omitempty"`
+	At       time.Time     `json:"at"`
+	// Cron is the expression of a recurring event, see `Scheduler.ScheduleCron`. At is the next time of it.
+	Cron string `json:"cron,omitempty"`
+}
+
+// ScheduleStore stores the pending scheduled events of machines, the machines are identified by ids.
+// `MemoryStore` and `FileStore` implement it. The implementations should be thread-safe.
+type ScheduleStore interface {
+	// SaveScheduled adds or replaces the scheduled event of machine id by its token.
+	SaveScheduled(ctx context.Context, id string, scheduled ScheduledEvent) error
+	// DeleteScheduled deletes the scheduled event, it is not an error if the event does not exist.
+	DeleteScheduled(ctx context.Context, id string, token string) error
+	// LoadScheduled returns the scheduled events of machine id, in any order.
+	LoadScheduled(ctx context.Context, id string) ([]ScheduledEvent, error)
+}
+
+type pendingEvent struct {
+	scheduled ScheduledEvent
+	ev        fsm.Event
+	cron      *CronSchedule
+	timer     fsm.Timer
+}
+
+// Scheduler delivers events to a machine at the scheduled time, e.g., reminders and timeouts. The pending events
+// are saved in a `ScheduleStore`, so they are delivered after restarts once `Recover` is invoked. The time is
+// measured by the `fsm.Clock` of the machine. It is thread-safe.
+//
+// The events are delivered by `fsm.QueuedFSM.ProcessEventContext`, and deleted from the store, or rescheduled
+// if they are recurring, after they are processed. So an event may be delivered again if the process crashes in
+// between.
+type Scheduler struct {
+	machine *fsm.QueuedFSM
+	id      string
+	store   ScheduleStore
+	codec   Codec
+
+	mu       sync.Mutex
+	pending  map[string]*pendingEvent
+	closed   bool
+	onError  func(scheduled ScheduledEvent, err error)
+	inflight sync.WaitGroup
+}
+
+// NewScheduler creates a scheduler of the machine, its pending events are stored in store by id. If codec is nil,
+// a `JSONCodec` without registered events is used. The scheduler should be closed by `Close` before the machine.
+func NewScheduler(machine *fsm.QueuedFSM, id string, store ScheduleStore, codec Codec) *Scheduler {
+	if codec == nil {
+		codec = NewJSONCodec()
+	}
+	return &Scheduler{machine: machine, id: id, store: store, codec: codec, pending: make(map[string]*pendingEvent)}
+}
+
+// SetErrorHandler sets the handler of the errors of delivering, i.e., the event is not processed by the machine,
+// or fails to be deleted from or rescheduled in the store. The errors are ignored by default.
+func (s *Scheduler) SetErrorHandler(onError func(scheduled ScheduledEvent, err error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onError = onError
+}
+
+func newToken() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// ScheduleEvent schedules the event at the time, it is delivered at once if the time has passed. It returns the
+// token of the scheduled event.
+func (s *Scheduler) ScheduleEvent(ev fsm.Event, at time.Time) (string, error) {
+	data, env, err := encodeEvent(s.codec, ev)
+	if err != nil {
+		return "", err
+	}
+	scheduled := ScheduledEvent{Token: newToken(), EventID: ev.FSMEventID(), Data: data, Envelope: env, At: at}
+	return s.schedule(scheduled, ev, nil)
+}
+
+func (s *Scheduler) schedule(scheduled ScheduledEvent, ev fsm.Event, cron *CronSchedule) (string, error) {
+	if err := s.store.SaveScheduled(context.Background(), s.id, scheduled); err != nil {
+		return "", err
+	}
+	if err := s.arm(scheduled, ev, cron); err != nil {
+		_ = s.store.DeleteScheduled(context.Background(), s.id, scheduled.Token)
+		return "", err
+	}
+	return scheduled.Token, nil
+}
+
+// ScheduleCron schedules the event recurring by the cron expression, e.g., `0 0 * * *` for every midnight, see
+// `ParseCron`. The event is kept scheduled after delivering until it is canceled by the token.
+// NOTE: the missed times are not made up, i.e., an overdue recurring event is delivered once by `Recover`.
+func (s *Scheduler) ScheduleCron(ev fsm.Event, spec string) (string, error) {
+	cron, err := ParseCron(spec)
+	if err != nil {
+		return "", err
+	}
+	at := cron.Next(s.machine.Clock().Now())
+	if at.IsZero() {
+		return "", errors.New(fmt.Sprintf("cron expression %q is never matched", spec))
+	}
+	data, env, err := encodeEvent(s.codec, ev)
+	if err != nil {
+		return "", err
+	}
+	scheduled := ScheduledEvent{Token: newToken(), EventID: ev.FSMEventID(), Data: data, Envelope: env, At: at,
+		Cron: spec}
+	return s.schedule(scheduled, ev, cron)
+}
+
+// ScheduleAfter schedules the event after the duration. See `ScheduleEvent`.
+func (s *Scheduler) ScheduleAfter(ev fsm.Event, d time.Duration) (string, error) {
+	return s.ScheduleEvent(ev, s.machine.Clock().Now().Add(d))
+}
+
+// Cancel cancels the pending event of token. It returns false if the event is not pending, e.g., it has been
+// delivered.
+func (s *Scheduler) Cancel(token string) (bool, error) {
+	s.mu.Lock()
+	p, ok := s.pending[token]
+	if ok {
+		p.timer.Stop()
+		delete(s.pending, token)
+	}
+	s.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	return true, s.store.DeleteScheduled(context.Background(), s.id, token)
+}
+
+// Pending returns the pending events, sorted by their time.
+func (s *Scheduler) Pending() []ScheduledEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]ScheduledEvent, 0, len(s.pending))
+	for _, p := range s.pending {
+		result = append(result, p.scheduled)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].At.Before(result[j].At)
+	})
+	return result
+}
+
+// Recover schedules the pending events loaded from the store, the overdue ones are delivered at once. It should be
+// invoked once, after the machine is recovered.
+func (s *Scheduler) Recover(ctx context.Context) error {
+	loaded, err := s.store.LoadScheduled(ctx, s.id)
+	if err != nil {
+		return err
+	}
+	for _, scheduled := range loaded {
+		ev, err := decodeEvent(s.codec, scheduled.EventID, scheduled.Data, scheduled.Envelope)
+		if err != nil {
+			return err
+		}
+		var cron *CronSchedule
+		if scheduled.Cron != "" {
+			if cron, err = ParseCron(scheduled.Cron); err != nil {
+				return err
+			}
+		}
+		if err := s.arm(scheduled, ev, cron); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) arm(scheduled ScheduledEvent, ev fsm.Event, cron *CronSchedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSchedulerClosed
+	}
+	if _, ok := s.pending[scheduled.Token]; ok {
+		return fsm.AlreadyExists
+	}
+	p := &pendingEvent{scheduled: scheduled, ev: ev, cron: cron}
+	s.startTimer(p)
+	s.pending[scheduled.Token] = p
+	return nil
+}
+
+func (s *Scheduler) startTimer(p *pendingEvent) {
+	clock := s.machine.Clock()
+	p.timer = clock.AfterFunc(p.scheduled.At.Sub(clock.Now()), func() {
+		s.deliver(p)
+	})
+}
+
+// deliver processes the pending event. A recurring event is kept pending while it is processed, so it can be
+// canceled meanwhile.
+func (s *Scheduler) deliver(p *pendingEvent) {
+	token := p.scheduled.Token
+	s.mu.Lock()
+	if s.pending[token] != p || s.closed {
+		s.mu.Unlock()
+		return
+	}
+	if p.cron == nil {
+		delete(s.pending, token)
+	}
+	s.inflight.Add(1)
+	onError := s.onError
+	scheduled := p.scheduled
+	s.mu.Unlock()
+	defer s.inflight.Done()
+
+	err := s.machine.ProcessEventContext(context.Background(), p.ev)
+	if err != nil && onError != nil {
+		onError(scheduled, err)
+	}
+	if p.cron == nil {
+		err = s.store.DeleteScheduled(context.Background(), s.id, token)
+	} else {
+		err = s.reschedule(p)
+	}
+	if err != nil && onError != nil {
+		onError(scheduled, err)
+	}
+}
+
+// reschedule arms the recurring event at its next time, unless it is canceled or the scheduler is closed.
+func (s *Scheduler) reschedule(p *pendingEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending[p.scheduled.Token] != p || s.closed {
+		return nil
+	}
+	next := p.cron.Next(s.machine.Clock().Now())
+	if next.IsZero() {
+		delete(s.pending, p.scheduled.Token)
+		return s.store.DeleteScheduled(context.Background(), s.id, p.scheduled.Token)
+	}
+	p.scheduled.At = next
+	if err := s.store.SaveScheduled(context.Background(), s.id, p.scheduled); err != nil {
+		return err
+	}
+	s.startTimer(p)
+	return nil
+}
+
+// Close stops delivering, and waits for the events being delivered. The pending events are kept in the store.
+func (s *Scheduler) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for token, p := range s.pending {
+		p.timer.Stop()
+		delete(s.pending, token)
+	}
+	s.mu.Unlock()
+	s.inflight.Wait()
+	return nil
+}
@@ -0,0 +1,167 @@
+package persist
+
+import (
+	"context"
+	"github.com/reyoung/fsm"
+	"github.com/reyoung/fsm/fsmtest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newQueuedOrder(t *testing.T, paid *int, clock fsm.Clock) *fsm.QueuedFSM {
+	machine := fsm.NewQueuedFSM(fsm.StringState("created"), nil)
+	machine.SetClock(clock)
+	setupOrder(t, machine.FSM, paid)
+	return machine
+}
+
+func TestScheduler(t *testing.T) {
+	clock := fsmtest.NewFakeClock(time.Unix(1700000000, 0))
+	store := NewMemoryStore()
+	codec := NewJSONCodec().Register("pay", func() fsm.Event { return &payEvent{} })
+	paid := 0
+	machine := newQueuedOrder(t, &paid, clock)
+	defer machine.Close()
+	scheduler := NewScheduler(machine, "order-1", store, codec)
+	defer scheduler.Close()
+
+	shipToken, err := scheduler.ScheduleAfter(fsm.StringEvent("ship"), time.Hour)
+	assert.Nil(t, err)
+	_, err = scheduler.ScheduleAfter(&payEvent{Amount: 10}, time.Minute)
+	assert.Nil(t, err)
+	pending := scheduler.Pending()
+	assert.Len(t, pending, 2)
+	assert.Equal(t, "pay", pending[0].EventID)
+	assert.Equal(t, clock.Now().Add(time.Minute), pending[0].At)
+	assert.Equal(t, shipToken, pending[1].Token)
+
+	clock.Advance(59 * time.Second)
+	assert.Equal(t, fsm.StringState("created"), machine.CurrentState())
+	clock.Advance(time.Second)
+	assert.Equal(t, fsm.StringState("paid"), machine.CurrentState())
+	assert.Equal(t, 10, paid)
+	loaded, err := store.LoadScheduled(context.Background(), "order-1")
+	assert.Nil(t, err)
+	assert.Len(t, loaded, 1)
+
+	canceled, err := scheduler.Cancel(shipToken)
+	assert.True(t, canceled)
+	assert.Nil(t, err)
+	canceled, err = scheduler.Cancel(shipToken)
+	assert.False(t, canceled)
+	assert.Nil(t, err)
+	assert.Empty(t, scheduler.Pending())
+	loaded, err = store.LoadScheduled(context.Background(), "order-1")
+	assert.Nil(t, err)
+	assert.Empty(t, loaded)
+	clock.Advance(time.Hour)
+	assert.Equal(t, fsm.StringState("paid"), machine.CurrentState())
+}
+
+func TestSchedulerRecover(t *testing.T) {
+	clock := fsmtest.NewFakeClock(time.Unix(1700000000, 0))
+	store, err := NewFileStore(t.TempDir())
+	assert.Nil(t, err)
+	codec := NewJSONCodec().Register("pay", func() fsm.Event { return &payEvent{} })
+	paid := 0
+	machine := newQueuedOrder(t, &paid, clock)
+	defer machine.Close()
+
+	scheduler := NewScheduler(machine, "order-1", store, codec)
+	_, err = scheduler.ScheduleAfter(&payEvent{Amount: 10}, time.Minute)
+	assert.Nil(t, err)
+	_, err = scheduler.ScheduleAfter(fsm.StringEvent("ship"), time.Hour)
+	assert.Nil(t, err)
+	assert.Nil(t, scheduler.Close())
+	_, err = scheduler.ScheduleAfter(fsm.StringEvent("ship"), time.Hour)
+	assert.Equal(t, ErrSchedulerClosed, err)
+
+	// the pending events are kept after closing, and the overdue one is delivered once recovered.
+	clock.Advance(10 * time.Minute)
+	assert.Equal(t, fsm.StringState("created"), machine.CurrentState())
+	loaded, err := store.LoadScheduled(context.Background(), "order-1")
+	assert.Nil(t, err)
+	assert.Len(t, loaded, 2)
+
+	scheduler = NewScheduler(machine, "order-1", store, codec)
+	defer scheduler.Close()
+	assert.Nil(t, scheduler.Recover(context.Background()))
+	clock.Advance(0)
+	assert.Equal(t, fsm.StringState("paid"), machine.CurrentState())
+	assert.Equal(t, 10, paid)
+	pending := scheduler.Pending()
+	assert.Len(t, pending, 1)
+	assert.Equal(t, "ship", pending[0].EventID)
+	assert.Equal(t, fsm.AlreadyExists, scheduler.Recover(context.Background()))
+
+	clock.Advance(time.Hour)
+	assert.Equal(t, fsm.StringState("shipped"), machine.CurrentState())
+	loaded, err = store.LoadScheduled(context.Background(), "order-1")
+	assert.Nil(t, err)
+	assert.Empty(t, loaded)
+}
+
+func TestSchedulerErrorHandler(t *testing.T) {
+	clock := fsmtest.NewFakeClock(time.Unix(1700000000, 0))
+	paid := 0
+	machine := newQueuedOrder(t, &paid, clock)
+	defer machine.Close()
+	scheduler := NewScheduler(machine, "order-1", NewMemoryStore(), nil)
+	defer scheduler.Close()
+
+	var failed []string
+	scheduler.SetErrorHandler(func(scheduled ScheduledEvent, err error) {
+		failed = append(failed, scheduled.EventID)
+	})
+	_, err := scheduler.ScheduleEvent(fsm.StringEvent("ship"), clock.Now())
+	assert.Nil(t, err)
+	clock.Advance(0)
+	assert.Equal(t, []string{"ship"}, failed)
+	assert.Equal(t, fsm.StringState("created"), machine.CurrentState())
+}
+
+func TestSchedulerCron(t *testing.T) {
+	clock := fsmtest.NewFakeClock(time.Date(2024, 1, 31, 10, 30, 0, 0, time.UTC))
+	store := NewMemoryStore()
+	codec := NewJSONCodec().Register("pay", func() fsm.Event { return &payEvent{} })
+	paid := 0
+	machine := newQueuedOrder(t, &paid, clock)
+	defer machine.Close()
+	scheduler := NewScheduler(machine, "order-1", store, codec)
+	_, err := scheduler.ScheduleCron(&payEvent{Amount: 10}, "0 0 30 2 *")
+	assert.NotNil(t, err)
+	_, err = scheduler.ScheduleCron(&payEvent{Amount: 10}, "* * *")
+	assert.NotNil(t, err)
+
+	token, err := scheduler.ScheduleCron(&payEvent{Amount: 10}, "0 * * * *")
+	assert.Nil(t, err)
+	pending := scheduler.Pending()
+	assert.Len(t, pending, 1)
+	assert.Equal(t, "0 * * * *", pending[0].Cron)
+	assert.Equal(t, time.Date(2024, 1, 31, 11, 0, 0, 0, time.UTC), pending[0].At)
+	assert.Nil(t, scheduler.Close())
+
+	// an overdue recurring event is delivered once, and rescheduled at its next time.
+	clock.Advance(3 * time.Hour)
+	scheduler = NewScheduler(machine, "order-1", store, codec)
+	defer scheduler.Close()
+	assert.Nil(t, scheduler.Recover(context.Background()))
+	clock.Advance(0)
+	assert.Equal(t, 10, paid)
+	loaded, err := store.LoadScheduled(context.Background(), "order-1")
+	assert.Nil(t, err)
+	assert.Len(t, loaded, 1)
+	assert.Equal(t, time.Date(2024, 1, 31, 14, 0, 0, 0, time.UTC), loaded[0].At)
+
+	// the event is kept after it is rejected by the machine.
+	clock.Advance(time.Hour)
+	assert.Equal(t, time.Date(2024, 1, 31, 15, 0, 0, 0, time.UTC), scheduler.Pending()[0].At)
+
+	canceled, err := scheduler.Cancel(token)
+	assert.True(t, canceled)
+	assert.Nil(t, err)
+	loaded, err = store.LoadScheduled(context.Background(), "order-1")
+	assert.Nil(t, err)
+	assert.Empty(t, loaded)
+}
@@ -0,0 +1,54 @@
+// Package persist journals the events accepted by a FSM, so that the FSM can be rebuilt after restarts.
+// See `PersistentFSM`.
+package persist
+
+import (
+	"context"
+	"errors"
+	"github.com/reyoung/fsm"
+	"time"
+)
+
+// ErrConflict is returned by `Store` when the journal or the snapshot is modified concurrently.
+var ErrConflict = errors.New("concurrent modification")
+
+// Record is a journaled event.
+type Record struct {
+	// Seq is the sequence number of the event, starts from 1.
+	Seq     uint64 `json:"seq"`
+	EventID string `json:"event"`
+	Data    []byte `json:"data,omitempty"`
+	// Envelope is the metadata of the event wrapped by `fsm.Envelope`, without the event, which is in Data.
+	Envelope *fsm.Envelope `json:"envelope,omitempty"`
+	// Version is the `FSM.Version` after the event is processed.
+	Version uint64    `json:"version"`
+	Time    time.Time `json:"time"`
+}
+
+// Snapshot is the states of a FSM after the event `Seq` is processed. The events after `Seq` are replayed
+// when the FSM is rebuilt.
+type Snapshot struct {
+	Seq uint64 `json:"seq"`
+	// States are the ids of `FSM.CurrentStates`.
+	States  []string `json:"states"`
+	Version uint64   `json:"version"`
+	// DefinitionVersion is the `FSM.DefinitionVersion` of the machine, the snapshots of other versions are migrated
+	// by `Migrations` when they are restored.
+	DefinitionVersion string    `json:"definition_version,omitempty"`
+	Time              time.Time `json:"time"`
+}
+
+// Store stores the journals and snapshots of machines, the machines are identified by ids.
+// The implementations should be thread-safe.
+type Store interface {
+	// AppendEvent appends the record to the journal of machine id. It is a compare-and-swap, which returns
+	// `ErrConflict` unless `Seq` of the record is one more than `Seq` of the last record in the journal.
+	AppendEvent(ctx context.Context, id string, record Record) error
+	// LoadEvents returns the records whose `Seq` is greater than `afterSeq`, in the order of appending.
+	LoadEvents(ctx context.Context, id string, afterSeq uint64) ([]Record, error)
+	// SaveSnapshot replaces the snapshot of machine id. It returns `ErrConflict` if `Seq` of the saved snapshot
+	// is greater than `Seq` of the snapshot.
+	SaveSnapshot(ctx context.Context, id string, snapshot Snapshot) error
+	// LoadSnapshot returns the latest snapshot of machine id, or nil if there is no snapshot.
+	LoadSnapshot(ctx context.Context, id string) (*Snapshot, error)
+}
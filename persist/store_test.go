@@ -0,0 +1,49 @@
+package persist
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+	snapshot, err := store.LoadSnapshot(ctx, "order/1")
+	assert.Nil(t, err)
+	assert.Nil(t, snapshot)
+	records, err := store.LoadEvents(ctx, "order/1", 0)
+	assert.Nil(t, err)
+	assert.Empty(t, records)
+
+	now := time.Unix(1700000000, 0).UTC()
+	for i := uint64(1); i <= 3; i++ {
+		assert.Nil(t, store.AppendEvent(ctx, "order/1", Record{Seq: i, EventID: "pay", Data: []byte(`{}`), Time: now}))
+	}
+	assert.Equal(t, ErrConflict, store.AppendEvent(ctx, "order/1", Record{Seq: 3, EventID: "pay", Time: now}))
+	assert.Equal(t, ErrConflict, store.AppendEvent(ctx, "order/2", Record{Seq: 2, EventID: "cancel", Time: now}))
+	assert.Nil(t, store.AppendEvent(ctx, "order/2", Record{Seq: 1, EventID: "cancel", Time: now}))
+	records, err = store.LoadEvents(ctx, "order/1", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []Record{
+		{Seq: 2, EventID: "pay", Data: []byte(`{}`), Time: now},
+		{Seq: 3, EventID: "pay", Data: []byte(`{}`), Time: now},
+	}, records)
+
+	assert.Nil(t, store.SaveSnapshot(ctx, "order/1", Snapshot{Seq: 2, States: []string{"paid"}, Time: now}))
+	assert.Nil(t, store.SaveSnapshot(ctx, "order/1", Snapshot{Seq: 3, States: []string{"shipped"}, Time: now}))
+	assert.Equal(t, ErrConflict, store.SaveSnapshot(ctx, "order/1", Snapshot{Seq: 2, States: []string{"paid"}}))
+	snapshot, err = store.LoadSnapshot(ctx, "order/1")
+	assert.Nil(t, err)
+	assert.Equal(t, &Snapshot{Seq: 3, States: []string{"shipped"}, Time: now}, snapshot)
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	assert.Nil(t, err)
+	testStore(t, store)
+}
@@ -1,27 +1,81 @@
 package fsm
 
 import (
+	"context"
 	"errors"
-	"github.com/reyoung/parallel"
 	"sync"
 )
 
-type preemptiveEventEntry struct {
-	ev         Event
-	onComplete func(error)
-}
+// ErrPreempted is returned by `PreemptiveFSM.ProcessEvent` if the pending event is preempted by a newer one. It is
+// also the `context.Cause` of the action context cancelled by the preemption, see `SetCancelInFlight`.
+var ErrPreempted = errors.New("the current event has been preempted")
 
 // PreemptiveFSM is a thread safe FSM.
 // If there is a processing event, the `ProcessEvent` will be wait until the processing complete.
 // If `ProcessEvent` is invoked more than once together, old events will be ignored and ProcessEvent
 // will return error. i.e., the event is preemptive.
+// By default, all pending events share one slot, see `SetCoalescingKey` for the preemption scoped by event ids,
+// and `SetCancelInFlight` for the preemption of the running action.
 type PreemptiveFSM struct {
 	*FSM
-	evChan           chan *preemptiveEventEntry
-	exitWG           sync.WaitGroup
-	exitFlag         bool
-	nextEntry        *preemptiveEventEntry
+	evChan   chan *eventEntry
+	exitWG   sync.WaitGroup
+	exitFlag bool
+	// the pending events in the order of arrival, and their coalescing keys. guarded by nextEntrySetCond.L.
+	nextEntries      []*eventEntry
+	nextKeys         []string
+	coalescingKey    func(ev Event) string
 	nextEntrySetCond *sync.Cond
+	// the coalescing key and the cancel function of the processing event, guarded by nextEntrySetCond.L.
+	// cancelRunning is nil unless cancelInFlight is true.
+	cancelInFlight bool
+	runningKey     string
+	cancelRunning  context.CancelCauseFunc
+	// processing is held during the event processing. See `Do`.
+	processing sync.Mutex
+	// policies holds the events by their `EventPolicy`.
+	policies eventPolicies
+}
+
+// CoalesceByEventID is a coalescing key of `SetCoalescingKey`, the pending event is only preempted by the events
+// of the same id.
+func CoalesceByEventID(ev Event) string {
+	return ev.FSMEventID()
+}
+
+// SetCoalescingKey scopes the preemption by the key of events, i.e., a new event only preempts the pending event
+// of the same key, and the pending events of different keys are processed in the order of arrival. For example,
+// with `CoalesceByEventID`, a new "resize" event preempts the pending "resize", but not the pending "save". The
+// key can be coarser than event ids, e.g., the ids of the target resources of the events.
+// The preempting event takes the place after the other pending events, so the surviving events keep their order.
+// A nil key, which is the default, puts all events into one slot.
+func (p *PreemptiveFSM) SetCoalescingKey(key func(ev Event) string) {
+	p.nextEntrySetCond.L.Lock()
+	defer p.nextEntrySetCond.L.Unlock()
+	p.coalescingKey = key
+}
+
+// SetCancelInFlight enables the cooperative cancellation of the running action. If it is enabled, the context of
+// the processing event is cancelled when a newer event of the same coalescing key arrives, so the long-running
+// actions, e.g., rendering, can abort early by checking `ActionContext`:
+//
+//	render := func(payload interface{}, ev fsm.Event) error {
+//		ctx := machine.ActionContext()
+//		for _, tile := range tiles {
+//			if ctx.Err() != nil {
+//				return context.Cause(ctx) // fsm.ErrPreempted
+//			}
+//			draw(tile)
+//		}
+//		return nil
+//	}
+//
+// The cancelled action decides what to do, the transition is taken if it returns nil anyway. The context
+// passed to `ProcessEventContext` is the parent of the cancelled one. It is disabled by default.
+func (p *PreemptiveFSM) SetCancelInFlight(enabled bool) {
+	p.nextEntrySetCond.L.Lock()
+	defer p.nextEntrySetCond.L.Unlock()
+	p.cancelInFlight = enabled
 }
 
 func (p *PreemptiveFSM) mainLoop() {
@@ -37,33 +91,63 @@ func (p *PreemptiveFSM) mainLoop() {
 		for {
 			l := p.nextEntrySetCond.L
 			l.Lock()
-			for !p.exitFlag && p.nextEntry == nil {
+			for !p.exitFlag && len(p.nextEntries) == 0 {
 				p.nextEntrySetCond.Wait()
 			}
 			if p.exitFlag {
+				l.Unlock()
 				return
 			}
-			evEntry := p.nextEntry
-			p.nextEntry = nil
+			evEntry, key := p.nextEntries[0], p.nextKeys[0]
+			// shift rather than reslice, so the pending slices are reused without allocations.
+			n := copy(p.nextEntries, p.nextEntries[1:])
+			copy(p.nextKeys, p.nextKeys[1:])
+			p.nextEntries[n] = nil
+			p.nextEntries, p.nextKeys = p.nextEntries[:n], p.nextKeys[:n]
+			var cancel context.CancelCauseFunc
+			if p.cancelInFlight {
+				evEntry.ctx, cancel = context.WithCancelCause(evEntry.ctx)
+				p.runningKey, p.cancelRunning = key, cancel
+			}
 			l.Unlock()
 
-			evEntry.onComplete(p.FSM.ProcessEvent(evEntry.ev))
+			p.processing.Lock()
+			evEntry.process(p.FSM)
+			p.processing.Unlock()
+			if cancel != nil {
+				l.Lock()
+				p.cancelRunning = nil
+				l.Unlock()
+				cancel(nil)
+			}
 		}
 	}()
 	for {
 		evEntry := <-p.evChan
+		var preempted []*eventEntry
 		l := p.nextEntrySetCond.L
 		l.Lock()
-		prevEvEntry := p.nextEntry
-		p.nextEntry = evEntry
 		if evEntry == nil {
 			p.exitFlag = true
+			preempted = p.nextEntries
+			p.nextEntries, p.nextKeys = nil, nil
+		} else {
+			var key string
+			if p.coalescingKey != nil {
+				key = p.coalescingKey(evEntry.ev)
+			}
+			if prevEvEntry := p.enqueue(evEntry, key); prevEvEntry != nil {
+				preempted = append(preempted, prevEvEntry)
+			}
+			if p.cancelRunning != nil && p.runningKey == key {
+				p.cancelRunning(ErrPreempted)
+			}
 		}
 		l.Unlock()
 		p.nextEntrySetCond.Broadcast()
 
-		if prevEvEntry != nil {
-			prevEvEntry.onComplete(errors.New("the current event has been preempted"))
+		for _, prevEvEntry := range preempted {
+			prevEvEntry.done <- ErrPreempted
 		}
 		if evEntry == nil {
 			break
@@ -74,21 +158,53 @@ func (p *PreemptiveFSM) mainLoop() {
 	wg.Wait()
 }
 
+// enqueue appends the entry of the key to the pending events, and returns the preempted one of the same key, or
+// nil. The caller should hold nextEntrySetCond.L.
+func (p *PreemptiveFSM) enqueue(entry *eventEntry, key string) *eventEntry {
+	var preempted *eventEntry
+	for i, k := range p.nextKeys {
+		if k == key {
+			preempted = p.nextEntries[i]
+			p.nextEntries = append(p.nextEntries[:i], p.nextEntries[i+1:]...)
+			p.nextKeys = append(p.nextKeys[:i], p.nextKeys[i+1:]...)
+			break
+		}
+	}
+	p.nextEntries = append(p.nextEntries, entry)
+	p.nextKeys = append(p.nextKeys, key)
+	return preempted
+}
+
 func (p *PreemptiveFSM) ProcessEvent(event Event) error {
-	notification := parallel.NewNotification()
-	var result error
-	p.evChan <- &preemptiveEventEntry{
-		ev: event,
-		onComplete: func(err error) {
-			result = err
-			notification.Done()
-		},
+	return p.ProcessEventContext(context.Background(), event)
+}
+
+func (p *PreemptiveFSM) ProcessEventContext(ctx context.Context, event Event) error {
+	entry := getEventEntry(ctx, event)
+	p.push(entry)
+	return entry.wait()
+}
+
+// CurrentState returns the current state, it can be invoked by any goroutine. See `SetOwnershipCheck`.
+func (p *PreemptiveFSM) CurrentState() State {
+	return p.currentState()
+}
+
+// push sends the entry to the main loop, or holds it by its `EventPolicy`.
+func (p *PreemptiveFSM) push(entry *eventEntry) {
+	if p.policies.active() {
+		p.policies.dispatch(p.clock, entry, p.send)
+	} else {
+		p.send(entry)
 	}
-	notification.Wait()
-	return result
+}
+
+func (p *PreemptiveFSM) send(entry *eventEntry) {
+	p.evChan <- entry
 }
 
 func (p *PreemptiveFSM) Close() error {
+	p.policies.close(ErrQueueClosed)
 	p.evChan <- nil
 	p.exitWG.Wait()
 	return nil
@@ -98,10 +214,9 @@ func NewPreemptiveFSM(initState State, payload interface{}) *PreemptiveFSM {
 	fsm := NewFSM(initState, payload)
 	result := &PreemptiveFSM{
 		FSM:              fsm,
-		evChan:           make(chan *preemptiveEventEntry),
+		evChan:           make(chan *eventEntry),
 		exitWG:           sync.WaitGroup{},
 		exitFlag:         false,
-		nextEntry:        nil,
 		nextEntrySetCond: sync.NewCond(&sync.Mutex{}),
 	}
 	result.exitWG.Add(1)
@@ -1,6 +1,7 @@
 package fsm
 
 import (
+	"context"
 	"github.com/stretchr/testify/assert"
 	"sync"
 	"testing"
@@ -59,3 +60,105 @@ func TestNewPreemptiveFSM(t *testing.T) {
 	// should only two event processed
 	assert.Equal(t, 2, counter)
 }
+
+// waitPending waits until n events are pending.
+func waitPending(p *PreemptiveFSM, n int) {
+	for {
+		p.nextEntrySetCond.L.Lock()
+		pending := len(p.nextEntries)
+		p.nextEntrySetCond.L.Unlock()
+		if pending == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPreemptiveFSMCoalescingKey(t *testing.T) {
+	idle := StringState("idle")
+	fsm := NewPreemptiveFSM(idle, nil)
+	defer fsm.Close()
+	fsm.SetCoalescingKey(CoalesceByEventID)
+	var (
+		processed []string
+		started   = make(chan struct{})
+		blocked   = make(chan struct{})
+	)
+	for _, ev := range []string{"block", "resize", "save"} {
+		assert.Nil(t, fsm.AddEvent(ev))
+		assert.Nil(t, fsm.AddTransition(idle, ev, idle, func(_ interface{}, ev Event) error {
+			if ev.FSMEventID() == "block" {
+				close(started)
+				<-blocked
+			}
+			processed = append(processed, string(ev.(StringEvent)))
+			return nil
+		}, nil))
+	}
+
+	errs := make([]chan error, 4)
+	for i, ev := range []string{"block", "resize", "save", "resize"} {
+		errs[i] = make(chan error, 1)
+		go func(ev string, errCh chan error) {
+			errCh <- fsm.ProcessEvent(StringEvent(ev))
+		}(ev, errs[i])
+		switch i {
+		case 0:
+			<-started
+		case 3:
+			// the pending resize is preempted, but the pending save is not.
+			assert.Equal(t, ErrPreempted, <-errs[1])
+		default:
+			waitPending(fsm, i)
+		}
+	}
+	close(blocked)
+	assert.Nil(t, <-errs[0])
+	assert.Nil(t, <-errs[2])
+	assert.Nil(t, <-errs[3])
+	// the preempting resize is processed after the pending save.
+	assert.Equal(t, []string{"block", "save", "resize"}, processed)
+}
+
+func TestPreemptiveFSMCancelInFlight(t *testing.T) {
+	idle := StringState("idle")
+	fsm := NewPreemptiveFSM(idle, nil)
+	defer fsm.Close()
+	fsm.SetCoalescingKey(CoalesceByEventID)
+	fsm.SetCancelInFlight(true)
+	running := make(chan context.Context, 1)
+	renders := 0
+	assert.Nil(t, fsm.AddEvent("render"))
+	assert.Nil(t, fsm.AddEvent("save"))
+	assert.Nil(t, fsm.AddTransition(idle, "render", idle, func(interface{}, Event) error {
+		renders++
+		if renders > 1 {
+			return nil
+		}
+		ctx := fsm.ActionContext()
+		running <- ctx
+		<-ctx.Done()
+		return context.Cause(ctx)
+	}, nil))
+	assert.Nil(t, fsm.AddTransition(idle, "save", idle, nil, nil))
+
+	errs := []chan error{make(chan error, 1), make(chan error, 1), make(chan error, 1)}
+	go func() {
+		errs[0] <- fsm.ProcessEvent(StringEvent("render"))
+	}()
+	ctx := <-running
+	go func() {
+		errs[1] <- fsm.ProcessEvent(StringEvent("save"))
+	}()
+	waitPending(fsm, 1)
+	// the event of another key does not cancel the running action.
+	assert.Nil(t, ctx.Err())
+
+	go func() {
+		errs[2] <- fsm.ProcessEvent(StringEvent("render"))
+	}()
+	assert.Equal(t, ErrPreempted, <-errs[0])
+	assert.Nil(t, <-errs[1])
+	assert.Nil(t, <-errs[2])
+	assert.Equal(t, 2, renders)
+}
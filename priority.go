@@ -0,0 +1,112 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+func transitionNotFound(from State, evId string, index int) error {
+	return errors.New(fmt.Sprintf("transition %d from state(%s) and event(%s) not found",
+		index, from.FSMStateID(), evId))
+}
+
+func pseudoTransition(from State, evId string, index int) error {
+	return errors.New(fmt.Sprintf("transition %d from state(%s) and event(%s) is a choice, fork or join transition",
+		index, from.FSMStateID(), evId))
+}
+
+// SetPriorityOrder sets the order of the guard evaluation among the transitions sharing the same from state and
+// event. By default, they are evaluated in the order they are added. If byPriority is true, the transitions of
+// higher `TransitionOptions.Priority` are evaluated first, and the ones of the same priority keep the order they
+// are added. The added transitions are reordered as well.
+// NOTE: the branches of a choice are always evaluated in order, see `AddChoice`.
+func (fsm *FSM) SetPriorityOrder(byPriority bool) error {
+	if err := fsm.checkMutable(); err != nil {
+		return err
+	}
+	fsm.priorityOrder = byPriority
+	for from, evTrans := range fsm.transitions {
+		for evID, transList := range evTrans {
+			fsm.setTransitions(from, evID, fsm.sortTransitions(transList))
+		}
+	}
+	return nil
+}
+
+// ReplaceTransition replaces the `index`-th transition from state `from` and triggered by `evId`, in the order of
+// guard evaluation, i.e., the order of `Transitions`. The arguments are the same as `AddTransitionWithOptions`.
+// The new transition takes the place of the replaced one in the order they are added.
+// NOTE: the branches of a choice, fork and join transitions cannot be replaced.
+func (fsm *FSM) ReplaceTransition(from State, evId string, index int, to State,
+	action func(interface{}, Event) error, guard func(interface{}, Event) bool, opts TransitionOptions) error {
+	if err := fsm.checkTransition(from, evId, to); err != nil {
+		return err
+	}
+	transList, err := fsm.editableTransitions(from, evId, index)
+	if err != nil {
+		return err
+	}
+	t := fsm.newTransition(to, action, guard, opts, false)
+	t.seq = transList[index].seq
+	transList[index] = t
+	fsm.setTransitions(from.FSMStateID(), evId, fsm.sortTransitions(transList))
+	return nil
+}
+
+// RemoveTransition removes the `index`-th transition from state `from` and triggered by `evId`, in the order of
+// guard evaluation, i.e., the order of `Transitions`.
+// NOTE: the branches of a choice, fork and join transitions cannot be removed.
+func (fsm *FSM) RemoveTransition(from State, evId string, index int) error {
+	if err := fsm.checkTransition(from, evId, from); err != nil {
+		return err
+	}
+	transList, err := fsm.editableTransitions(from, evId, index)
+	if err != nil {
+		return err
+	}
+	transList = append(transList[:index], transList[index+1:]...)
+	if len(transList) == 0 {
+		transList = nil
+	}
+	fsm.setTransitions(from.FSMStateID(), evId, transList)
+	return nil
+}
+
+// editableTransitions returns a copy of the transitions of from and evId, if the `index`-th one can be replaced
+// or removed. The transitions are copied since the processing event may be iterating them.
+func (fsm *FSM) editableTransitions(from State, evId string, index int) ([]*transition, error) {
+	transList := fsm.transitions[from.FSMStateID()][evId]
+	if index < 0 || index >= len(transList) {
+		return nil, transitionNotFound(from, evId, index)
+	}
+	if t := transList[index]; t.choice || len(t.fork) != 0 || len(t.join) != 0 {
+		return nil, pseudoTransition(from, evId, index)
+	}
+	return append([]*transition(nil), transList...), nil
+}
+
+// sortTransitions sorts transList in the order of guard evaluation. See `SetPriorityOrder`.
+func (fsm *FSM) sortTransitions(transList []*transition) []*transition {
+	if len(transList) < 2 || transList[len(transList)-1].choice {
+		return transList
+	}
+	sort.SliceStable(transList, func(i, j int) bool {
+		a, b := transList[i], transList[j]
+		if fsm.priorityOrder && a.priority != b.priority {
+			return a.priority > b.priority
+		}
+		return a.seq < b.seq
+	})
+	return transList
+}
+
+// lastAdded returns the last added transition from state `from` and triggered by evID.
+func (fsm *FSM) lastAdded(from string, evID string) *transition {
+	for _, t := range fsm.transitions[from][evID] {
+		if t.seq == fsm.transitionSeq {
+			return t
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func targetsOf(fsm *FSM) []string {
+	var result []string
+	for _, info := range fsm.Transitions() {
+		result = append(result, info.To.FSMStateID())
+	}
+	return result
+}
+
+func TestSetPriorityOrder(t *testing.T) {
+	fsm := newPriorityFSM(t)
+	assert.Equal(t, []string{"low", "mid", "high"}, targetsOf(fsm))
+
+	assert.Nil(t, fsm.SetPriorityOrder(true))
+	assert.Equal(t, []string{"high", "mid", "low"}, targetsOf(fsm))
+	assert.Equal(t, 1, fsm.Transitions()[0].Priority)
+	// the transitions of the same priority keep the order they are added.
+	assert.Nil(t, fsm.AddTransitionWithOptions(StringState("idle"), "go", StringState("idle"), nil, nil,
+		TransitionOptions{Priority: 1}))
+	assert.Equal(t, []string{"high", "idle", "mid", "low"}, targetsOf(fsm))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("go")))
+	assert.Equal(t, StringState("high"), fsm.CurrentState())
+
+	fsm.Unlock()
+	assert.Nil(t, fsm.SetPriorityOrder(false))
+	assert.Equal(t, []string{"low", "mid", "high", "idle"}, targetsOf(fsm))
+
+	// the priorities are kept by the definition.
+	assert.Nil(t, fsm.SetPriorityOrder(true))
+	loaded, err := NewFSMFromDefinition(fsm.Definition(), nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"high", "idle", "mid", "low"}, targetsOf(loaded))
+	assert.Equal(t, fsm.Definition(), loaded.Definition())
+
+	fsm.Compile()
+	assert.Equal(t, ErrCompiled, fsm.SetPriorityOrder(false))
+}
+
+func TestSetPriorityOrderCompiledTable(t *testing.T) {
+	fsm := newPriorityFSM(t)
+	assert.Nil(t, fsm.SetPriorityOrder(true))
+	fsm.Compile()
+	ev, _ := fsm.IndexEvent("go")
+	assert.Nil(t, fsm.ProcessEvent(ev))
+	assert.Equal(t, StringState("high"), fsm.CurrentState())
+}
+
+func TestReplaceTransition(t *testing.T) {
+	fsm := newPriorityFSM(t)
+	assert.Nil(t, fsm.ReplaceTransition(StringState("idle"), "go", 0, StringState("mid"), nil,
+		func(interface{}, Event) bool { return false }, TransitionOptions{GuardName: "never"}))
+	assert.Equal(t, []string{"mid", "mid", "high"}, targetsOf(fsm))
+	assert.Equal(t, "never", fsm.Transitions()[0].GuardName)
+	// the guard of the first transition rejects the event, and the table is updated as well.
+	ev, _ := fsm.IndexEvent("go")
+	assert.Nil(t, fsm.ProcessEvent(ev))
+	assert.Equal(t, StringState("mid"), fsm.CurrentState())
+
+	// the replaced transition keeps its place in the order they are added.
+	fsm = newPriorityFSM(t)
+	assert.Nil(t, fsm.SetPriorityOrder(true))
+	assert.Nil(t, fsm.ReplaceTransition(StringState("idle"), "go", 0, StringState("idle"), nil, nil,
+		TransitionOptions{}))
+	assert.Equal(t, []string{"mid", "idle", "low"}, targetsOf(fsm))
+
+	assert.NotNil(t, fsm.ReplaceTransition(StringState("idle"), "go", 3, StringState("idle"), nil, nil,
+		TransitionOptions{}))
+	assert.NotNil(t, fsm.ReplaceTransition(StringState("idle"), "go", 0, StringState("unknown"), nil, nil,
+		TransitionOptions{}))
+	assert.NotNil(t, fsm.ReplaceTransition(StringState("idle"), "unknown", 0, StringState("idle"), nil, nil,
+		TransitionOptions{}))
+	fsm.Compile()
+	assert.Equal(t, ErrCompiled, fsm.ReplaceTransition(StringState("idle"), "go", 0, StringState("idle"), nil,
+		nil, TransitionOptions{}))
+}
+
+func TestRemoveTransition(t *testing.T) {
+	fsm := newPriorityFSM(t)
+	assert.Nil(t, fsm.RemoveTransition(StringState("idle"), "go", 1))
+	assert.Equal(t, []string{"low", "high"}, targetsOf(fsm))
+	assert.Nil(t, fsm.RemoveTransition(StringState("idle"), "go", 0))
+	assert.Nil(t, fsm.RemoveTransition(StringState("idle"), "go", 0))
+	assert.NotNil(t, fsm.RemoveTransition(StringState("idle"), "go", 0))
+	assert.Len(t, fsm.Transitions(), 0)
+	ev, _ := fsm.IndexEvent("go")
+	assert.NotNil(t, fsm.ProcessEvent(ev))
+	assert.NotNil(t, fsm.ProcessEvent(StringEvent("go")))
+
+	// the choices can be added after all transitions are removed.
+	fsm.Unlock()
+	assert.Nil(t, fsm.AddChoice(StringState("idle"), "go", []ChoiceBranch{{To: StringState("low")}}))
+	assert.NotNil(t, fsm.RemoveTransition(StringState("idle"), "go", 0))
+	assert.NotNil(t, fsm.ReplaceTransition(StringState("idle"), "go", 0, StringState("idle"), nil, nil,
+		TransitionOptions{}))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("go")))
+	assert.Equal(t, StringState("low"), fsm.CurrentState())
+}
@@ -1,19 +1,77 @@
 package fsm
 
 import (
-	"github.com/reyoung/parallel"
+	"context"
 	"sync"
 )
 
-type queuedEventEntry struct {
-	ev         Event
-	onComplete func(error)
+// eventEntry is an event waiting to be processed, the error is sent to done, after the result of `SetResult` is
+// stored to result. If do is not nil, it is invoked instead. See `QueuedFSM.Do`. The entries are pooled, so the
+// queued `ProcessEvent`s do not allocate.
+type eventEntry struct {
+	ctx    context.Context
+	ev     Event
+	do     func(fsm *FSM) error
+	result interface{}
+	done   chan error
+}
+
+var eventEntryPool = sync.Pool{
+	New: func() interface{} {
+		return &eventEntry{done: make(chan error, 1)}
+	},
+}
+
+func getEventEntry(ctx context.Context, ev Event) *eventEntry {
+	entry := eventEntryPool.Get().(*eventEntry)
+	entry.ctx = ctx
+	entry.ev = ev
+	return entry
+}
+
+// wait waits for the error, and puts the entry back to the pool.
+func (e *eventEntry) wait() error {
+	_, err := e.waitResult()
+	return err
+}
+
+// waitResult waits for the result and the error, and puts the entry back to the pool.
+func (e *eventEntry) waitResult() (interface{}, error) {
+	err := <-e.done
+	result := e.result
+	e.ctx = nil
+	e.ev = nil
+	e.do = nil
+	e.result = nil
+	eventEntryPool.Put(e)
+	return result, err
+}
+
+// process processes the event of the entry by fsm, and sends the error to done.
+func (e *eventEntry) process(fsm *FSM) {
+	if e.do != nil {
+		e.done <- e.do(fsm)
+		return
+	}
+	e.done <- fsm.processEventWithResult(e.ctx, e.ev, &e.result)
 }
 
 type QueuedFSM struct {
 	*FSM
-	evChan chan *queuedEventEntry
+	evChan chan *eventEntry
 	exitWG sync.WaitGroup
+
+	// the pending events of the machine created by `WorkerPool.NewQueuedFSM`, guarded by mu. scheduled is
+	// true if the machine is ready or being processed by a worker.
+	pool      *WorkerPool
+	mu        sync.Mutex
+	mailbox   []*eventEntry
+	scheduled bool
+	closed    bool
+	// policies holds the events by their `EventPolicy`.
+	policies eventPolicies
+	// async tracks the actions of `AddAsyncTransition`.
+	async asyncActions
 }
 
 func (q *QueuedFSM) mainLoop() {
@@ -22,34 +80,59 @@ func (q *QueuedFSM) mainLoop() {
 		if ev == nil {
 			break
 		}
-		ev.onComplete(q.FSM.ProcessEvent(ev.ev))
+		ev.process(q.FSM)
 	}
 	q.exitWG.Done()
 }
 
 func (q *QueuedFSM) Close() error {
+	q.stopAlarms()
+	q.async.close()
+	q.policies.close(ErrQueueClosed)
+	if q.pool != nil {
+		return q.closePooled()
+	}
 	q.evChan <- nil
 	q.exitWG.Wait()
 	return nil
 }
 
-func (q *QueuedFSM) ProcessEvent(ev Event) (errResult error) {
-	notification := parallel.NewNotification()
-	q.evChan <- &queuedEventEntry{
-		ev: ev,
-		onComplete: func(err error) {
-			errResult = err
-			notification.Done()
-		},
+func (q *QueuedFSM) ProcessEvent(ev Event) error {
+	return q.ProcessEventContext(context.Background(), ev)
+}
+
+func (q *QueuedFSM) ProcessEventContext(ctx context.Context, ev Event) error {
+	return q.enqueue(ctx, ev).wait()
+}
+
+// CurrentState returns the current state, it can be invoked by any goroutine. See `SetOwnershipCheck`.
+func (q *QueuedFSM) CurrentState() State {
+	return q.currentState()
+}
+
+// enqueue queues the event, the caller should wait for the returned entry.
+func (q *QueuedFSM) enqueue(ctx context.Context, ev Event) *eventEntry {
+	entry := getEventEntry(ctx, ev)
+	if q.policies.active() {
+		q.policies.dispatch(q.clock, entry, q.push)
+	} else {
+		q.push(entry)
+	}
+	return entry
+}
+
+func (q *QueuedFSM) push(entry *eventEntry) {
+	if q.pool != nil {
+		q.submit(entry)
+	} else {
+		q.evChan <- entry
 	}
-	notification.Wait()
-	return
 }
 
 func NewQueuedFSM(initState State, payload interface{}) *QueuedFSM {
 	result := &QueuedFSM{
 		FSM:    NewFSM(initState, payload),
-		evChan: make(chan *queuedEventEntry),
+		evChan: make(chan *eventEntry),
 		exitWG: sync.WaitGroup{},
 	}
 	result.exitWG.Add(1)
@@ -0,0 +1,6 @@
+//go:build race
+
+package fsm
+
+// raceEnabled is true if the tests are built with the race detector, which allocates.
+const raceEnabled = true
@@ -0,0 +1,99 @@
+package fsm
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrRateLimited is returned by `ProcessEvent` if the event exceeds the rate limit of the transitions. See
+// `SetRateLimit`.
+var ErrRateLimited = errors.New("the event is rate limited")
+
+// stateEvent is a (state, event) pair of transitions.
+type stateEvent struct {
+	from string
+	ev   string
+}
+
+// RateLimit is a token bucket limiting the rate of events. See `SetRateLimit`.
+type RateLimit struct {
+	// Rate is the number of events allowed per second.
+	Rate float64
+	// Burst is the maximum number of events allowed at once. It is 1 if it is less than 1.
+	Burst int
+}
+
+// tokenBucket is the state of a `RateLimit`.
+type tokenBucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token at now, and returns false if there is no token.
+func (b *tokenBucket) allow(now time.Time) bool {
+	burst := float64(b.limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.limit.Rate
+		if b.tokens > burst {
+			b.tokens = burst
+		}
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// SetRateLimit limits the rate of the event evId processed by the transitions from state `from`, so abusive or
+// runaway event sources cannot spin the machine. Each event evaluating the transitions takes a token, whether a
+// transition is fired or not, and the bucket starts full. The events without tokens are rejected without
+// evaluating the guards:
+//   - `ProcessEvent` returns `ErrRateLimited`, and the state is not changed. The transitions of the parent states
+//     are not evaluated either.
+//   - The event is recorded by `ExplainLastRejection` with the `RateLimited` candidates, and sent to the
+//     dead-letter sink, see `SetDeadLetterSink`.
+//
+// The time is measured by the clock of the FSM, see `SetClock`. The limit is removed if limit.Rate is not
+// positive, and the events of `Replay` are not limited.
+// NOTE: like other modifications, it should not be invoked concurrently with `ProcessEvent`.
+func (fsm *FSM) SetRateLimit(from State, evId string, limit RateLimit) error {
+	if !fsm.HasState(from) {
+		return stateNotFound(from)
+	}
+	if !fsm.HasEvent(evId) {
+		return eventNotFound(evId)
+	}
+	key := stateEvent{from: from.FSMStateID(), ev: evId}
+	if limit.Rate <= 0 {
+		delete(fsm.rateLimits, key)
+		return nil
+	}
+	if fsm.rateLimits == nil {
+		fsm.rateLimits = make(map[stateEvent]*tokenBucket)
+	}
+	bucket := &tokenBucket{limit: limit, tokens: float64(limit.Burst), last: fsm.clock.Now()}
+	if bucket.tokens < 1 {
+		bucket.tokens = 1
+	}
+	fsm.rateLimits[key] = bucket
+	return nil
+}
+
+// checkRateLimit takes a token of ev from state `from`, or rejects transList and returns `ErrRateLimited`.
+func (fsm *FSM) checkRateLimit(from string, ev Event, transList []*transition) error {
+	bucket, ok := fsm.rateLimits[stateEvent{from: from, ev: ev.FSMEventID()}]
+	if !ok || bucket.allow(fsm.clock.Now()) {
+		return nil
+	}
+	for _, t := range transList {
+		fsm.reject(from, t, RateLimited)
+	}
+	_ = fsm.noTransition(ev)
+	return ErrRateLimited
+}
@@ -0,0 +1,63 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	clock := &manualClock{now: time.Unix(0, 0)}
+	machine := NewFSM(StringState("idle"), nil)
+	machine.SetClock(clock)
+	assert.Nil(t, machine.AddState(StringState("session")))
+	assert.Nil(t, machine.AddChildState(StringState("session"), StringState("polling")))
+	assert.Nil(t, machine.AddEvent("poll"))
+	assert.Nil(t, machine.AddTransition(StringState("polling"), "poll", StringState("polling"), nil, nil))
+	assert.Nil(t, machine.AddTransition(StringState("session"), "poll", StringState("idle"), nil, nil))
+	assert.Nil(t, machine.Start(StringState("session")))
+	var letters []DeadLetter
+	machine.SetDeadLetterSink(func(letter DeadLetter) {
+		letters = append(letters, letter)
+	})
+
+	assert.Nil(t, machine.SetRateLimit(StringState("polling"), "poll", RateLimit{Rate: 2, Burst: 2}))
+	assert.Nil(t, machine.ProcessEvent(StringEvent("poll")))
+	assert.Nil(t, machine.ProcessEvent(StringEvent("poll")))
+	// the transitions of the parent state are not evaluated either.
+	assert.Equal(t, ErrRateLimited, machine.ProcessEvent(StringEvent("poll")))
+	assert.Equal(t, "polling", machine.CurrentState().FSMStateID())
+	if assert.Len(t, letters, 1) {
+		assert.Equal(t, []RejectedTransition{{From: StringState("polling"), To: StringState("polling"),
+			Reason: RateLimited}}, letters[0].Candidates)
+		assert.Contains(t, letters[0].Rejection.String(), "rate limited")
+	}
+	assert.Equal(t, machine.ExplainLastRejection(), &letters[0].Rejection)
+
+	// a token is refilled in 500ms.
+	clock.now = clock.now.Add(499 * time.Millisecond)
+	assert.Equal(t, ErrRateLimited, machine.ProcessEvent(StringEvent("poll")))
+	clock.now = clock.now.Add(time.Millisecond)
+	assert.Nil(t, machine.ProcessEvent(StringEvent("poll")))
+	// the tokens are not more than the burst.
+	clock.now = clock.now.Add(time.Hour)
+	assert.Nil(t, machine.ProcessEvent(StringEvent("poll")))
+	assert.Nil(t, machine.ProcessEvent(StringEvent("poll")))
+	assert.Equal(t, ErrRateLimited, machine.ProcessEvent(StringEvent("poll")))
+
+	// the limit is removed.
+	assert.Nil(t, machine.SetRateLimit(StringState("polling"), "poll", RateLimit{}))
+	assert.Nil(t, machine.ProcessEvent(StringEvent("poll")))
+	assert.Len(t, letters, 3)
+}
+
+func TestRateLimitErrors(t *testing.T) {
+	machine := NewFSM(StringState("idle"), nil)
+	assert.Nil(t, machine.AddEvent("poll"))
+	assert.NotNil(t, machine.SetRateLimit(StringState("unknown"), "poll", RateLimit{Rate: 1}))
+	assert.NotNil(t, machine.SetRateLimit(StringState("idle"), "unknown", RateLimit{Rate: 1}))
+	// the rate limits of the events without transitions do nothing.
+	assert.Nil(t, machine.SetRateLimit(StringState("idle"), "poll", RateLimit{Rate: 1}))
+	assert.NotEqual(t, ErrRateLimited, machine.ProcessEvent(StringEvent("poll")))
+	assert.NotEqual(t, ErrRateLimited, machine.ProcessEvent(StringEvent("poll")))
+}
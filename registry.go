@@ -0,0 +1,120 @@
+package fsm
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// Registry tracks live machines by their names, so that operators can inspect all machines in one place.
+// It is thread-safe.
+// NOTE: the registry only reads the current states and topologies of machines. The topologies should
+// not be modified after the machines are registered.
+type Registry struct {
+	mu       sync.RWMutex
+	machines map[string]*FSM
+}
+
+// DefaultRegistry is the package level registry.
+var DefaultRegistry = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{machines: make(map[string]*FSM)}
+}
+
+// Register adds the machine by its name. The machine should be named by `SetName` before.
+// It returns AlreadyExists if there is another machine with the same name.
+func (r *Registry) Register(fsm *FSM) error {
+	if fsm.Name() == "" {
+		return errors.New("the machine should be named before registering")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.machines[fsm.Name()]; ok {
+		return AlreadyExists
+	}
+	r.machines[fsm.Name()] = fsm
+	return nil
+}
+
+// Unregister removes the machine by name. It returns false if the machine is not found.
+func (r *Registry) Unregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.machines[name]
+	delete(r.machines, name)
+	return ok
+}
+
+func (r *Registry) Get(name string) (*FSM, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	fsm, ok := r.machines[name]
+	return fsm, ok
+}
+
+// Names returns the sorted names of registered machines.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make([]string, 0, len(r.machines))
+	for name := range r.machines {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// snapshot returns the registered machines sorted by name.
+func (r *Registry) snapshot() []*FSM {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make([]*FSM, 0, len(r.machines))
+	for _, fsm := range r.machines {
+		result = append(result, fsm)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name() < result[j].Name()
+	})
+	return result
+}
+
+// CurrentStates returns the current state id of each machine by name, it is empty for the machines not started.
+func (r *Registry) CurrentStates() map[string]string {
+	result := make(map[string]string)
+	for _, fsm := range r.snapshot() {
+		result[fsm.Name()] = fsm.currentStateID()
+	}
+	return result
+}
+
+// DumpGraphviz dumps each machine as Graphviz by name.
+func (r *Registry) DumpGraphviz() map[string]string {
+	result := make(map[string]string)
+	for _, fsm := range r.snapshot() {
+		result[fsm.Name()] = fsm.DumpGraphviz()
+	}
+	return result
+}
+
+// MachineDump is the JSON form of a registered machine. See `Registry.DumpJSON`.
+type MachineDump struct {
+	Name         string      `json:"name"`
+	CurrentState string      `json:"current_state"`
+	Definition   *Definition `json:"definition"`
+}
+
+// DumpJSON dumps the current states and definitions of all machines as a JSON array sorted by name.
+func (r *Registry) DumpJSON() ([]byte, error) {
+	machines := r.snapshot()
+	result := make([]MachineDump, 0, len(machines))
+	for _, fsm := range machines {
+		result = append(result, MachineDump{
+			Name:         fsm.Name(),
+			CurrentState: fsm.currentStateID(),
+			Definition:   fsm.Definition(),
+		})
+	}
+	return json.MarshalIndent(result, "", "  ")
+}
@@ -0,0 +1,66 @@
+package fsm
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	var (
+		on  = StringState("on")
+		off = StringState("off")
+	)
+	newLight := func(name string) *QueuedFSM {
+		fsm := NewQueuedFSM(off, nil)
+		fsm.SetName(name)
+		assert.Nil(t, fsm.AddState(on))
+		assert.Nil(t, fsm.AddEvent("switch"))
+		assert.Nil(t, fsm.AddTransition(off, "switch", on, nil, nil))
+		return fsm
+	}
+	kitchen, bedroom := newLight("kitchen"), newLight("bedroom")
+	defer func() {
+		assert.Nil(t, kitchen.Close())
+		assert.Nil(t, bedroom.Close())
+	}()
+
+	registry := NewRegistry()
+	assert.NotNil(t, registry.Register(NewFSM(off, nil)))
+	assert.Nil(t, registry.Register(kitchen.FSM))
+	assert.Nil(t, registry.Register(bedroom.FSM))
+	assert.Equal(t, AlreadyExists, registry.Register(kitchen.FSM))
+	assert.Equal(t, []string{"bedroom", "kitchen"}, registry.Names())
+
+	assert.Nil(t, kitchen.ProcessEvent(StringEvent("switch")))
+	assert.Equal(t, map[string]string{"bedroom": "off", "kitchen": "on"}, registry.CurrentStates())
+	assert.Len(t, registry.DumpGraphviz(), 2)
+
+	data, err := registry.DumpJSON()
+	assert.Nil(t, err)
+	var dumps []MachineDump
+	assert.Nil(t, json.Unmarshal(data, &dumps))
+	assert.Equal(t, "bedroom", dumps[0].Name)
+	assert.Equal(t, "on", dumps[1].CurrentState)
+	assert.Equal(t, []string{"off", "on"}, dumps[1].Definition.States)
+
+	assert.True(t, registry.Unregister("kitchen"))
+	assert.False(t, registry.Unregister("kitchen"))
+	_, ok := registry.Get("kitchen")
+	assert.False(t, ok)
+}
+
+func TestRegistryNotStarted(t *testing.T) {
+	fsm := NewFSM(nil, nil)
+	fsm.SetName("kitchen")
+	assert.Nil(t, fsm.AddState(StringState("off")))
+	registry := NewRegistry()
+	assert.Nil(t, registry.Register(fsm))
+	assert.Equal(t, map[string]string{"kitchen": ""}, registry.CurrentStates())
+
+	data, err := registry.DumpJSON()
+	assert.Nil(t, err)
+	var dumps []MachineDump
+	assert.Nil(t, json.Unmarshal(data, &dumps))
+	assert.Equal(t, "", dumps[0].CurrentState)
+}
@@ -0,0 +1,99 @@
+package fsm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RejectionReason is the reason why a candidate transition is not fired.
+type RejectionReason int
+
+const (
+	// GuardReturnedFalse means the guard of the transition returned false.
+	GuardReturnedFalse RejectionReason = iota
+	// JoinNotReady means the source states of the join transition are not all active. See `AddJoin`.
+	JoinNotReady
+	// RateLimited means the event exceeded the rate limit of the transitions. See `SetRateLimit`.
+	RateLimited
+	// Forbidden means the actor of the event is not permitted to fire the transition. See `SetAuthorizer`.
+	Forbidden
+)
+
+func (r RejectionReason) String() string {
+	switch r {
+	case GuardReturnedFalse:
+		return "guard returned false"
+	case JoinNotReady:
+		return "join is not ready"
+	case RateLimited:
+		return "rate limited"
+	case Forbidden:
+		return "forbidden"
+	default:
+		return fmt.Sprintf("RejectionReason(%d)", int(r))
+	}
+}
+
+// RejectedTransition is a candidate transition which is not fired. See `Rejection`.
+type RejectedTransition struct {
+	From State
+	To   State
+	// GuardName is the registered guard name, or empty if unknown.
+	GuardName string
+	Metadata  TransitionMetadata
+	Reason    RejectionReason
+}
+
+// Rejection explains why an event was not processed. See `ExplainLastRejection`.
+type Rejection struct {
+	// State is the current state when the event was rejected.
+	State State
+	Event Event
+	// Candidates are the transitions of the event from the current states and the composite states containing
+	// them, in the order of evaluation. It is empty if there is no transition of the event at all.
+	Candidates []RejectedTransition
+}
+
+func (r *Rejection) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("event(%s) rejected in state(%s)", r.Event.FSMEventID(), r.State.FSMStateID()))
+	if len(r.Candidates) == 0 {
+		sb.WriteString(": no transition")
+	}
+	for _, c := range r.Candidates {
+		sb.WriteString(fmt.Sprintf("\n  %s -> %s", c.From.FSMStateID(), c.To.FSMStateID()))
+		if c.GuardName != "" {
+			sb.WriteString(fmt.Sprintf(" [%s]", c.GuardName))
+		}
+		sb.WriteString(": " + c.Reason.String())
+	}
+	return sb.String()
+}
+
+// ExplainLastRejection returns why the last rejected event was not processed, i.e., the last time
+// `ProcessEvent` returned the no transition error. It returns nil if no event has been rejected.
+// NOTE: the actions of fired transitions do not change the result, even if they return errors.
+func (fsm *FSM) ExplainLastRejection() *Rejection {
+	return fsm.lastRejection
+}
+
+// reject records that the candidate transition t from state `from` is not fired.
+func (fsm *FSM) reject(from string, t *transition, reason RejectionReason) {
+	fsm.candidates = append(fsm.candidates, RejectedTransition{
+		From:      fsm.states[from],
+		To:        t.to,
+		GuardName: t.guardName,
+		Metadata:  t.meta.clone(),
+		Reason:    reason,
+	})
+}
+
+// noTransition records the rejection of ev and returns the no transition error.
+func (fsm *FSM) noTransition(ev Event) error {
+	fsm.lastRejection = &Rejection{State: fsm.states[fsm.curState], Event: ev, Candidates: fsm.candidates}
+	fsm.candidates = nil
+	if fsm.deadLetters != nil && !fsm.replaying {
+		fsm.deadLetters(fsm.deadLetter(*fsm.lastRejection))
+	}
+	return noTrasitionFromStateAndEvent(fsm.curState, ev)
+}
@@ -0,0 +1,62 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestExplainLastRejection(t *testing.T) {
+	var (
+		active  = StringState("active")
+		idle    = StringState("idle")
+		busy    = StringState("busy")
+		closed  = StringState("closed")
+		balance = 0
+	)
+	fsm := NewFSM(busy, nil)
+	assert.Nil(t, fsm.AddState(active))
+	assert.Nil(t, fsm.AddChildState(active, idle))
+	assert.Nil(t, fsm.AddState(closed))
+	assert.Nil(t, fsm.AddEvent("close"))
+	assert.Nil(t, fsm.AddEvent("work"))
+	assert.Nil(t, fsm.AddTransitionWithOptions(idle, "close", closed, nil, func(interface{}, Event) bool {
+		return balance == 0
+	}, TransitionOptions{GuardName: "isEmpty"}))
+	assert.Nil(t, fsm.AddTransition(active, "close", closed, nil, func(interface{}, Event) bool {
+		return false
+	}))
+	assert.Nil(t, fsm.AddTransition(busy, "work", active, nil, nil))
+	assert.Nil(t, fsm.ExplainLastRejection())
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("work")))
+
+	balance = 1
+	assert.NotNil(t, fsm.ProcessEvent(StringEvent("close")))
+	rejection := fsm.ExplainLastRejection()
+	assert.Equal(t, idle, rejection.State)
+	assert.Equal(t, StringEvent("close"), rejection.Event)
+	assert.Equal(t, []RejectedTransition{
+		{From: idle, To: closed, GuardName: "isEmpty", Reason: GuardReturnedFalse},
+		{From: active, To: closed, Reason: GuardReturnedFalse},
+	}, rejection.Candidates)
+	assert.Equal(t, "event(close) rejected in state(idle)\n"+
+		"  idle -> closed [isEmpty]: guard returned false\n"+
+		"  active -> closed: guard returned false", rejection.String())
+
+	assert.NotNil(t, fsm.ProcessEvent(StringEvent("work")))
+	assert.Empty(t, fsm.ExplainLastRejection().Candidates)
+	assert.Equal(t, "event(work) rejected in state(idle): no transition", fsm.ExplainLastRejection().String())
+
+	// a processed event keeps the last rejection.
+	balance = 0
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("close")))
+	assert.Equal(t, StringEvent("work"), fsm.ExplainLastRejection().Event)
+}
+
+func TestExplainLastRejectionJoin(t *testing.T) {
+	fsm := newBuildFSM(t)
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("start")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("be")))
+	assert.NotNil(t, fsm.ProcessEvent(StringEvent("be")))
+	assert.Equal(t, "event(be) rejected in state(building): no transition", fsm.ExplainLastRejection().String())
+	assert.Equal(t, JoinNotReady.String(), "join is not ready")
+}
@@ -0,0 +1,86 @@
+package fsm
+
+import (
+	"io"
+	"text/template"
+)
+
+// StateInfo is a read-only description of a state. See `RenderModel`.
+type StateInfo struct {
+	State State
+	// Parent is the composite state containing the state, or nil for the top level states.
+	Parent State
+	// Children are the child states in the order of adding, and InitialChild is the one entered by default. They
+	// are empty for the states which are not composite states.
+	Children     []State
+	InitialChild State
+	Parallel     bool
+	// History is true for the history pseudo-states. See `ShallowHistory` and `DeepHistory`.
+	History bool
+	// Final is true if the state and its parents have no outgoing transition.
+	Final bool
+	// Active is true if the state is one of the current states or contains one of them. See `IsIn`.
+	Active bool
+}
+
+// RenderModel is the introspection model of a FSM, which is the data of the templates of `Render`.
+type RenderModel struct {
+	Name    string
+	Version uint64
+	// Initial is the initial state, nil if the FSM has no initial state.
+	Initial State
+	// Current are the `CurrentStates`.
+	Current []State
+	// States are sorted by state id, see `States`.
+	States []StateInfo
+	// Events are sorted, see `Events`.
+	Events []string
+	// Transitions are sorted by from state id and event id, see `Transitions`.
+	Transitions []TransitionInfo
+	// Definition is the exported topology, see `Definition`.
+	Definition *Definition
+}
+
+// RenderModel returns the introspection model of the FSM rendered by `Render`.
+func (fsm *FSM) RenderModel() *RenderModel {
+	model := &RenderModel{
+		Name:        fsm.Name(),
+		Version:     fsm.Version(),
+		Initial:     fsm.states[fsm.initState],
+		Current:     fsm.CurrentStates(),
+		States:      make([]StateInfo, 0, len(fsm.states)),
+		Events:      fsm.Events(),
+		Transitions: fsm.Transitions(),
+		Definition:  fsm.Definition(),
+	}
+	for _, state := range fsm.States() {
+		id := state.FSMStateID()
+		_, history := fsm.histories[id]
+		info := StateInfo{
+			State:    state,
+			Parent:   fsm.Parent(state),
+			Parallel: fsm.parallel[id],
+			History:  history,
+			Final:    fsm.isFinal(id),
+			Active:   fsm.IsIn(state),
+		}
+		if len(fsm.children[id]) != 0 {
+			info.Children = fsm.Children(state)
+			info.InitialChild = fsm.states[fsm.initialChildren[id]]
+		}
+		model.States = append(model.States, info)
+	}
+	return model
+}
+
+// Render executes tmpl with the `RenderModel` of the FSM, and writes the result to w, so the custom formats can
+// be generated without the package supporting them, e.g., HTML reports, CSV transition tables or internal DSLs:
+//
+//	tmpl := template.Must(template.New("csv").Parse(
+//		"from,event,to\n{{range .Transitions}}{{.From.FSMStateID}},{{.Event}},{{.To.FSMStateID}}\n{{end}}"))
+//	err := machine.Render(tmpl, os.Stdout)
+//
+// NOTE: like other introspection methods, it should not be invoked concurrently with the modifications.
+func (fsm *FSM) Render(tmpl *template.Template, w io.Writer) error {
+	return tmpl.Execute(w, fsm.RenderModel())
+}
@@ -0,0 +1,63 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestRender(t *testing.T) {
+	machine := newPlayerFSM(t)
+	machine.SetName("player")
+	assert.Nil(t, machine.ProcessEvent(StringEvent("start")))
+
+	tmpl := template.Must(template.New("csv").Parse(
+		"from,event,to\n{{range .Transitions}}{{.From.FSMStateID}},{{.Event}},{{.To.FSMStateID}}\n{{end}}"))
+	b := &strings.Builder{}
+	assert.Nil(t, machine.Render(tmpl, b))
+	assert.Equal(t, "from,event,to\n"+
+		"active,stop,stopped\n"+
+		"paused,resume,playing\n"+
+		"playing,pause,paused\n"+
+		"stopped,resumeDeep,active/H*\n"+
+		"stopped,resumeShallow,active/H\n"+
+		"stopped,start,active\n"+
+		"video,switch,audio\n", b.String())
+
+	tmpl = template.Must(template.New("states").Funcs(template.FuncMap{"join": strings.Join}).Parse(
+		`{{.Name}} in {{range .Current}}{{.FSMStateID}}{{end}}:
+{{range .States}}{{if not .History}}{{.State.FSMStateID}}{{if .Parent}} < {{.Parent.FSMStateID}}{{end}}
+{{- if .Children}} initial={{.InitialChild.FSMStateID}}{{end}}{{if .Active}} active{{end}}
+{{end}}{{end}}events: {{join .Events ","}}`))
+	b.Reset()
+	assert.Nil(t, machine.Render(tmpl, b))
+	assert.Equal(t, `player in video:
+active initial=playing active
+audio < playing
+paused < active
+playing < active initial=video active
+stopped
+video < playing active
+events: pause,resume,resumeDeep,resumeShallow,start,stop,switch`, b.String())
+}
+
+func TestRenderModel(t *testing.T) {
+	machine := newPlayerFSM(t)
+	model := machine.RenderModel()
+	assert.Equal(t, StringState("stopped"), model.Initial)
+	assert.Equal(t, []State{StringState("stopped")}, model.Current)
+	assert.Equal(t, machine.Transitions(), model.Transitions)
+	assert.Equal(t, machine.Definition(), model.Definition)
+	assert.Len(t, model.States, len(machine.States()))
+	for _, info := range model.States {
+		if info.State.FSMStateID() == "audio" {
+			// the parents of audio have outgoing transitions.
+			assert.False(t, info.Final)
+			assert.Equal(t, StringState("playing"), info.Parent)
+		}
+	}
+
+	tmpl := template.Must(template.New("error").Parse("{{.Unknown}}"))
+	assert.NotNil(t, machine.Render(tmpl, &strings.Builder{}))
+}
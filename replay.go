@@ -0,0 +1,60 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+)
+
+// ReplayError is returned by `Replay` when an event cannot be replayed.
+type ReplayError struct {
+	// Index is the index of the event in the replayed events.
+	Index int
+	Event Event
+	Err   error
+}
+
+func (e *ReplayError) Error() string {
+	return fmt.Sprintf("replay event %d(%s): %v", e.Index, e.Event.FSMEventID(), e.Err)
+}
+
+func (e *ReplayError) Unwrap() error {
+	return e.Err
+}
+
+// Replay processes the events to reconstruct the state from an event log, without side effects. The guards are
+// evaluated as `ProcessEvent`, but
+//   - the actions, the action middlewares and the `GlobalBeforeAction`/`GlobalAfterAction` are not invoked.
+//   - the observers and the subscribers are not notified.
+//   - the events of sub-machines are replayed as well.
+//
+// It stops at the first event which has no transition, and returns a `ReplayError`.
+// NOTE: Like `ProcessEvent`, it should not be invoked in action/guard. For `QueuedFSM` and `PreemptiveFSM`,
+// it should be invoked before processing any event.
+func (fsm *FSM) Replay(events []Event) error {
+	fsm.checkOwner()
+	fsm.processEventInvokeCounter += 1
+	defer func() {
+		fsm.processEventInvokeCounter -= 1
+		fsm.replaying = false
+	}()
+	if fsm.processEventInvokeCounter != 1 {
+		panic(ShouldNotReEnterPanic)
+	}
+	fsm.lock()
+	fsm.replaying = true
+	ctx := context.Background()
+	for i, ev := range events {
+		err := fsm.processEvent(ctx, ev)
+		// the done events of sub-machines.
+		for err == nil && len(fsm.internalEvents) != 0 {
+			internal := fsm.internalEvents[0]
+			fsm.internalEvents = fsm.internalEvents[1:]
+			err = fsm.processEvent(ctx, internal)
+		}
+		fsm.internalEvents = nil
+		if err != nil {
+			return &ReplayError{Index: i, Event: ev, Err: err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type countingObserver struct {
+	NopObserver
+	started int
+}
+
+func (o *countingObserver) EventStarted(context.Context, *FSM, Event) {
+	o.started++
+}
+
+func TestReplay(t *testing.T) {
+	var (
+		cart     = StringState("cart")
+		checking = StringState("checking")
+		paid     = StringState("paid")
+		review   = StringState("review")
+	)
+	actions := 0
+	action := func(interface{}, Event) error {
+		actions++
+		return nil
+	}
+	fsm := NewFSM(cart, 200)
+	for _, s := range []State{checking, paid, review} {
+		assert.Nil(t, fsm.AddState(s))
+	}
+	assert.Nil(t, fsm.AddEvent("checkout"))
+	assert.Nil(t, fsm.AddEvent("approve"))
+	assert.Nil(t, fsm.AddTransition(cart, "checkout", checking, action, nil))
+	assert.Nil(t, fsm.AddCompletionTransition(checking, review, action, func(payload interface{}, _ Event) bool {
+		return payload.(int) > 100
+	}))
+	assert.Nil(t, fsm.AddCompletionTransition(checking, paid, action, nil))
+	assert.Nil(t, fsm.AddTransition(review, "approve", paid, func(interface{}, Event) error {
+		return errors.New("should not be invoked")
+	}, nil))
+	observer := &countingObserver{}
+	fsm.AddObserver(observer)
+	fsm.UseActionMiddleware(func(args ActionHookArgs, next func() error) error {
+		actions++
+		return next()
+	})
+	ch, cancel := fsm.Subscribe()
+	defer cancel()
+
+	assert.Nil(t, fsm.Replay([]Event{StringEvent("checkout"), StringEvent("approve")}))
+	assert.Equal(t, paid, fsm.CurrentState())
+	assert.Equal(t, uint64(3), fsm.Version())
+	assert.Equal(t, 0, actions)
+	assert.Equal(t, 0, observer.started)
+	assert.Equal(t, 0, len(ch))
+
+	err := fsm.Replay([]Event{StringEvent("checkout")})
+	replayErr := &ReplayError{}
+	assert.True(t, errors.As(err, &replayErr))
+	assert.Equal(t, 0, replayErr.Index)
+	assert.Equal(t, paid, fsm.CurrentState())
+}
@@ -0,0 +1,74 @@
+package fsm
+
+import "context"
+
+// SetResult sets the result of the processing event, which is returned by `ProcessEventWithResult`, e.g., the
+// reply of a request/reply transition. The last set result wins, including the ones set by the actions of the
+// internal events. It is discarded if the event is processed by `ProcessEvent`.
+// It panics if it is not invoked during `ProcessEvent`.
+func (fsm *FSM) SetResult(result interface{}) {
+	if fsm.processEventInvokeCounter == 0 {
+		panic(SetResultOutsideProcessingPanic)
+	}
+	if fsm.result != nil {
+		*fsm.result = result
+	}
+}
+
+// ResultAction adapts an action returning a result to the action of `AddTransition`, the result is set by
+// `SetResult` if the action succeeds:
+//
+//	query := machine.ResultAction(func(p interface{}, ev fsm.Event) (interface{}, error) {
+//		return p.(*Account).Balance, nil
+//	})
+//	machine.AddTransition(idle, "query", idle, query, nil)
+//	balance, err := machine.ProcessEventWithResult(fsm.StringEvent("query"))
+func (fsm *FSM) ResultAction(
+	action func(payload interface{}, ev Event) (interface{}, error)) func(interface{}, Event) error {
+	return func(payload interface{}, ev Event) error {
+		result, err := action(payload, ev)
+		if err != nil {
+			return err
+		}
+		fsm.SetResult(result)
+		return nil
+	}
+}
+
+// ProcessEventWithResult is the same as `ProcessEvent`, and returns the result set by `SetResult`. The result is
+// nil if no result is set, or the processing fails.
+func (fsm *FSM) ProcessEventWithResult(ev Event) (interface{}, error) {
+	return fsm.ProcessEventWithResultContext(context.Background(), ev)
+}
+
+// ProcessEventWithResultContext is the same as `ProcessEventWithResult`, the ctx is passed to observers.
+func (fsm *FSM) ProcessEventWithResultContext(ctx context.Context, ev Event) (interface{}, error) {
+	fsm.checkOwner()
+	var result interface{}
+	err := fsm.processEventWithResult(ctx, ev, &result)
+	return result, err
+}
+
+// ProcessEventWithResult is the same as `FSM.ProcessEventWithResult`, but the event is queued. The result is
+// passed back to the caller, so it does not race with the later events like the results stored in the payload.
+func (q *QueuedFSM) ProcessEventWithResult(ev Event) (interface{}, error) {
+	return q.ProcessEventWithResultContext(context.Background(), ev)
+}
+
+// ProcessEventWithResultContext is the same as `ProcessEventWithResult`, the ctx is passed to observers.
+func (q *QueuedFSM) ProcessEventWithResultContext(ctx context.Context, ev Event) (interface{}, error) {
+	return q.enqueue(ctx, ev).waitResult()
+}
+
+// ProcessEventWithResult is the same as `FSM.ProcessEventWithResult`, but the event may be preempted. See
+// `PreemptiveFSM`.
+func (p *PreemptiveFSM) ProcessEventWithResult(ev Event) (interface{}, error) {
+	return p.ProcessEventWithResultContext(context.Background(), ev)
+}
+
+// ProcessEventWithResultContext is the same as `ProcessEventWithResult`, the ctx is passed to observers.
+func (p *PreemptiveFSM) ProcessEventWithResultContext(ctx context.Context, ev Event) (interface{}, error) {
+	entry := getEventEntry(ctx, ev)
+	p.push(entry)
+	return entry.waitResult()
+}
@@ -0,0 +1,103 @@
+package fsm
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+type account struct {
+	balance int
+}
+
+// newAccountFSM creates a FSM whose "deposit" events of `*depositEvent` reply the balance.
+func newAccountFSM(fsm *FSM) *FSM {
+	_ = fsm.AddEvent("deposit")
+	_ = fsm.AddEvent("audit")
+	_ = fsm.AddTransition(StringState("open"), "deposit", StringState("open"),
+		fsm.ResultAction(func(payload interface{}, ev Event) (interface{}, error) {
+			amount := Unwrap(ev).(*depositEvent).amount
+			if amount <= 0 {
+				return nil, errors.New("invalid amount")
+			}
+			payload.(*account).balance += amount
+			return payload.(*account).balance, nil
+		}), nil)
+	_ = fsm.AddTransition(StringState("open"), "audit", StringState("open"), func(interface{}, Event) error {
+		fsm.SetResult("audited")
+		fsm.PostInternal(&depositEvent{amount: 1})
+		return nil
+	}, nil)
+	return fsm
+}
+
+type depositEvent struct {
+	amount int
+}
+
+func (e *depositEvent) FSMEventID() string {
+	return "deposit"
+}
+
+func TestProcessEventWithResult(t *testing.T) {
+	fsm := newAccountFSM(NewFSM(StringState("open"), &account{}))
+	result, err := fsm.ProcessEventWithResult(&depositEvent{amount: 10})
+	assert.Nil(t, err)
+	assert.Equal(t, 10, result)
+	assert.Nil(t, fsm.ProcessEvent(&depositEvent{amount: 5}))
+
+	result, err = fsm.ProcessEventWithResult(&depositEvent{amount: -1})
+	assert.NotNil(t, err)
+	assert.Nil(t, result)
+	// the result of the internal event wins.
+	result, err = fsm.ProcessEventWithResult(StringEvent("audit"))
+	assert.Nil(t, err)
+	assert.Equal(t, 16, result)
+
+	assert.PanicsWithValue(t, SetResultOutsideProcessingPanic, func() {
+		fsm.SetResult(1)
+	})
+}
+
+func TestQueuedProcessEventWithResult(t *testing.T) {
+	queued := NewQueuedFSM(StringState("open"), &account{})
+	defer queued.Close()
+	newAccountFSM(queued.FSM)
+	pool := NewWorkerPool(2)
+	defer pool.Close()
+	pooled := pool.NewQueuedFSM(StringState("open"), &account{})
+	newAccountFSM(pooled.FSM)
+
+	for _, q := range []*QueuedFSM{queued, pooled} {
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			results = make(map[int]bool)
+		)
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				result, err := q.ProcessEventWithResult(&depositEvent{amount: 1})
+				assert.Nil(t, err)
+				mu.Lock()
+				defer mu.Unlock()
+				results[result.(int)] = true
+			}()
+		}
+		wg.Wait()
+		// each caller receives the balance after its own deposit.
+		assert.Len(t, results, 100)
+		result, err := q.ProcessEventWithResult(&depositEvent{amount: 0})
+		assert.NotNil(t, err)
+		assert.Nil(t, result)
+	}
+
+	preemptive := NewPreemptiveFSM(StringState("open"), &account{})
+	defer preemptive.Close()
+	newAccountFSM(preemptive.FSM)
+	result, err := preemptive.ProcessEventWithResult(&depositEvent{amount: 3})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, result)
+}
@@ -0,0 +1,75 @@
+package fsm
+
+import (
+	"fmt"
+	"time"
+)
+
+// RetryPolicy configures the retry of a failing transition action. See `TransitionOptions.Retry`.
+type RetryPolicy struct {
+	// MaxAttempts is the max number of action invocations, including the first one.
+	MaxAttempts int
+	// Backoff returns the delay before the next attempt, attempt starts from 1. No delay if it is nil.
+	Backoff func(attempt int) time.Duration
+	// Retryable returns true if the error is transient. All errors are retryable if it is nil.
+	Retryable func(err error) bool
+}
+
+// ConstantBackoff waits `d` between attempts.
+func ConstantBackoff(d time.Duration) func(int) time.Duration {
+	return func(int) time.Duration {
+		return d
+	}
+}
+
+// ExponentialBackoff waits `initial`, 2*`initial`, 4*`initial`... between attempts, but no more than `max`.
+func ExponentialBackoff(initial time.Duration, max time.Duration) func(int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := initial
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d
+	}
+}
+
+// RetryError is returned by `ProcessEvent` when the action of a transition with `RetryPolicy` fails.
+type RetryError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("action failed after %d attempt(s): %v", e.Attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// withRetry returns an action which invokes action following the policy. The backoffs are measured by the clock
+// of fsm, and stopped early if the action context is done.
+func (p *RetryPolicy) withRetry(fsm *FSM, action func(interface{}, Event) error) func(interface{}, Event) error {
+	return func(payload interface{}, ev Event) error {
+		ctx := fsm.ActionContext()
+		attempt := 1
+		for {
+			err := action(payload, ev)
+			if err == nil {
+				return nil
+			}
+			if attempt >= p.MaxAttempts || (p.Retryable != nil && !p.Retryable(err)) {
+				return &RetryError{Attempts: attempt, Err: err}
+			}
+			if p.Backoff != nil {
+				if sleepErr := Sleep(ctx, fsm.clock, p.Backoff(attempt)); sleepErr != nil {
+					return &RetryError{Attempts: attempt, Err: err}
+				}
+			}
+			attempt++
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package fsm
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	var (
+		on        = StringState("on")
+		off       = StringState("off")
+		transient = errors.New("transient")
+		fatal     = errors.New("fatal")
+	)
+	fsm := NewFSM(off, nil)
+	assert.Nil(t, fsm.AddState(on))
+	assert.Nil(t, fsm.AddEvent("switch"))
+	var errs []error
+	attempts := 0
+	assert.Nil(t, fsm.AddTransitionWithOptions(off, "switch", on, func(i interface{}, event Event) error {
+		attempts++
+		if len(errs) == 0 {
+			return nil
+		}
+		err := errs[0]
+		errs = errs[1:]
+		return err
+	}, nil, TransitionOptions{
+		Retry: &RetryPolicy{
+			MaxAttempts: 3,
+			Backoff:     ConstantBackoff(time.Millisecond),
+			Retryable: func(err error) bool {
+				return err == transient
+			},
+		},
+	}))
+
+	errs = []error{transient, transient, transient}
+	err := fsm.ProcessEvent(StringEvent("switch"))
+	assert.EqualError(t, err, "action failed after 3 attempt(s): transient")
+	assert.True(t, errors.Is(err, transient))
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, off, fsm.CurrentState())
+
+	errs, attempts = []error{fatal}, 0
+	var retryErr *RetryError
+	err = fsm.ProcessEvent(StringEvent("switch"))
+	assert.True(t, errors.As(err, &retryErr))
+	assert.Equal(t, 1, retryErr.Attempts)
+
+	errs, attempts = []error{transient}, 0
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("switch")))
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, on, fsm.CurrentState())
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(time.Second, 5*time.Second)
+	assert.Equal(t, time.Second, backoff(1))
+	assert.Equal(t, 2*time.Second, backoff(2))
+	assert.Equal(t, 4*time.Second, backoff(3))
+	assert.Equal(t, 5*time.Second, backoff(4))
+}
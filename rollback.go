@@ -0,0 +1,97 @@
+package fsm
+
+import (
+	"errors"
+)
+
+// undoEntry records a transition for `Rollback`.
+type undoEntry struct {
+	// before is the runtime state before the transition.
+	before *machineState
+	change StateChange
+	t      *transition
+}
+
+// RollbackEvent is the `StateChange.Event` of the state changes made by `Rollback`.
+type RollbackEvent struct {
+	// Event is the event of the reverted transition.
+	Event Event
+}
+
+func (ev *RollbackEvent) FSMEventID() string {
+	return "rollback(" + ev.Event.FSMEventID() + ")"
+}
+
+// SetRollbackLimit keeps the last `limit` transitions, so that they can be reverted by `Rollback`. The
+// transitions are not kept if limit is 0, which is the default.
+// NOTE: the transitions made by `Replay` are not kept.
+func (fsm *FSM) SetRollbackLimit(limit int) {
+	if limit < 0 {
+		limit = 0
+	}
+	fsm.rollbackLimit = limit
+	fsm.trimUndo()
+}
+
+// SetRollbackAction sets the action invoked by `Rollback` and `CompensateTo` before the state is reverted, after
+// the `TransitionOptions.Compensate` of the reverted transition, e.g., to undo the changes of the payload. The
+// change is the reverted state change. If the action returns an error, the state is not reverted.
+func (fsm *FSM) SetRollbackAction(action func(payload interface{}, change StateChange) error) {
+	fsm.rollbackAction = action
+}
+
+// Rollback reverts the last kept transition, the current state, the active states of regions and
+// sub-machines, and the history of composite states are restored to the ones before the transition. The
+// subscribers are notified with a `RollbackEvent`. The `Version` is increased, like a transition. It returns
+// an error if there is no transition to revert. See `SetRollbackLimit`.
+// NOTE: the actions are not reverted, see `TransitionOptions.Compensate` and `SetRollbackAction`. Like
+// `ProcessEvent`, it should not be invoked in action/guard.
+func (fsm *FSM) Rollback() error {
+	if fsm.processEventInvokeCounter != 0 {
+		panic(ShouldNotReEnterPanic)
+	}
+	if len(fsm.undo) == 0 {
+		return errors.New("no transition to rollback")
+	}
+	entry := fsm.undo[len(fsm.undo)-1]
+	if err := fsm.compensate(entry); err != nil {
+		return err
+	}
+	fsm.revert(entry)
+	return nil
+}
+
+// revert restores the state before the last kept transition entry, and removes it.
+func (fsm *FSM) revert(entry undoEntry) {
+	// the state in the running transaction, see `FSM.Transaction`.
+	current, version := fsm.states[fsm.curState], fsm.version
+	fsm.restoreState(entry.before)
+	fsm.curStateMu.Lock()
+	fsm.version = version + 1
+	fsm.curStateMu.Unlock()
+	fsm.undo[len(fsm.undo)-1] = undoEntry{}
+	fsm.undo = fsm.undo[:len(fsm.undo)-1]
+	rollback := &RollbackEvent{Event: entry.change.Event}
+	fsm.recordFrame(current.FSMStateID(), rollback)
+	fsm.publish(StateChange{
+		From:  current,
+		To:    fsm.states[fsm.curState],
+		Event: rollback,
+		Time:  fsm.clock.Now(),
+	})
+}
+
+// keepUndo keeps the transition t for `Rollback`.
+func (fsm *FSM) keepUndo(before *machineState, change StateChange, t *transition) {
+	fsm.undo = append(fsm.undo, undoEntry{before: before, change: change, t: t})
+	fsm.trimUndo()
+}
+
+func (fsm *FSM) trimUndo() {
+	if n := len(fsm.undo) - fsm.rollbackLimit; n > 0 {
+		for i := 0; i < n; i++ {
+			fsm.undo[i] = undoEntry{}
+		}
+		fsm.undo = fsm.undo[n:]
+	}
+}
@@ -0,0 +1,79 @@
+package fsm
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRollback(t *testing.T) {
+	fsm := newTransferFSM(t)
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("reserve")))
+	assert.NotNil(t, fsm.Rollback())
+
+	fsm = newTransferFSM(t)
+	fsm.SetRollbackLimit(1)
+	changes, cancel := fsm.Subscribe()
+	defer cancel()
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("reserve")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("charge")))
+	<-changes
+	<-changes
+
+	assert.Nil(t, fsm.Rollback())
+	assert.Equal(t, StringState("reserved"), fsm.CurrentState())
+	assert.Equal(t, uint64(3), fsm.Version())
+	change := <-changes
+	assert.Equal(t, StringState("charged"), change.From)
+	assert.Equal(t, StringState("reserved"), change.To)
+	assert.Equal(t, &RollbackEvent{Event: StringEvent("charge")}, change.Event)
+	assert.Equal(t, "rollback(charge)", change.Event.FSMEventID())
+
+	// only one transition is kept.
+	assert.NotNil(t, fsm.Rollback())
+	assert.Equal(t, StringState("reserved"), fsm.CurrentState())
+}
+
+func TestRollbackAction(t *testing.T) {
+	fsm := newTransferFSM(t)
+	fsm.SetRollbackLimit(10)
+	var reverted []StateChange
+	failing := true
+	fsm.SetRollbackAction(func(payload interface{}, change StateChange) error {
+		if failing {
+			return errors.New("failed")
+		}
+		reverted = append(reverted, change)
+		return nil
+	})
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("reserve")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("charge")))
+
+	assert.NotNil(t, fsm.Rollback())
+	assert.Equal(t, StringState("charged"), fsm.CurrentState())
+	failing = false
+	assert.Nil(t, fsm.Rollback())
+	assert.Nil(t, fsm.Rollback())
+	assert.Equal(t, StringState("created"), fsm.CurrentState())
+	assert.Len(t, reverted, 2)
+	assert.Equal(t, StringEvent("charge"), reverted[0].Event)
+	assert.Equal(t, StringState("reserved"), reverted[1].To)
+}
+
+func TestRollbackParallel(t *testing.T) {
+	fsm := newBuildFSM(t)
+	fsm.SetRollbackLimit(10)
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("start")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("be")))
+	assert.Equal(t, []State{StringState("fe-lint"), StringState("be-done")}, fsm.CurrentStates())
+	assert.Nil(t, fsm.Rollback())
+	assert.Equal(t, []State{StringState("fe-lint"), StringState("be-build")}, fsm.CurrentStates())
+
+	// the transitions kept in a failed transaction are discarded.
+	assert.NotNil(t, fsm.Transaction(func(tx *Tx) error {
+		assert.Nil(t, tx.ProcessEvent(StringEvent("be")))
+		return errors.New("abort")
+	}))
+	assert.Nil(t, fsm.Rollback())
+	assert.Equal(t, StringState("idle"), fsm.CurrentState())
+}
@@ -0,0 +1,248 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrRouteNotFound is returned by `Router.Send` if no machine is routed by the name.
+var ErrRouteNotFound = errors.New("no machine is routed by the name")
+
+// ErrRoutingLoop is returned by `Router.Send` if the routed events form a loop. See `Router.SendContext`.
+var ErrRoutingLoop = errors.New("the routed events form a loop")
+
+// RouteTarget is a machine which the events are routed to by a `Router`, e.g., `QueuedFSM` and `PreemptiveFSM`.
+// Its `ProcessEventContext` should be thread-safe, so a plain `FSM` should be wrapped into a `QueuedFSM`.
+type RouteTarget interface {
+	ProcessEventContext(ctx context.Context, ev Event) error
+}
+
+// routeHop is an event routed to the target, the hops of a chain of routed events are carried by the context.
+type routeHop struct {
+	target string
+	event  string
+}
+
+type routeHopsKey struct{}
+
+type routedEvent struct {
+	ctx    context.Context
+	target RouteTarget
+	ev     Event
+}
+
+// routeMailbox is the pending events of a name, delivered in order by a goroutine while running is true.
+type routeMailbox struct {
+	queue   []routedEvent
+	running bool
+}
+
+// Router routes the events between named machines, so an action can emit events to other machines without
+// ad-hoc goroutines:
+//
+//	router := fsm.NewRouter()
+//	_ = router.Add("payment", payment)
+//	_ = router.Add("shipping", shipping)
+//	...
+//	// in an action of payment.
+//	return router.SendContext(payment.ActionContext(), "shipping", ShipEvent{OrderID: id})
+//
+// The events are delivered asynchronously through `RouteTarget.ProcessEventContext`, in the order of `Send` per
+// name, so an action can send events to any machine including its own without deadlocks. The errors of the
+// delivery are reported to `SetErrorHandler`. It is thread-safe.
+type Router struct {
+	mu        sync.Mutex
+	targets   map[string]RouteTarget
+	resolvers []func(name string) (RouteTarget, bool)
+	mailboxes map[string]*routeMailbox
+	onError   func(name string, ev Event, err error)
+	maxHops   int
+	// closed rejects the new events, drained rejects the events caused by the routed ones as well.
+	closed  bool
+	drained bool
+	running sync.WaitGroup
+}
+
+func NewRouter() *Router {
+	return &Router{
+		targets:   make(map[string]RouteTarget),
+		mailboxes: make(map[string]*routeMailbox),
+		maxHops:   32,
+	}
+}
+
+// Add routes the events of name to the target. It returns `AlreadyExists` if the name is added.
+func (r *Router) Add(name string, target RouteTarget) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.targets[name]; ok {
+		return AlreadyExists
+	}
+	r.targets[name] = target
+	return nil
+}
+
+// Remove stops routing the events of name, the pending events are still delivered. It returns false if the name
+// is not added.
+func (r *Router) Remove(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.targets[name]
+	delete(r.targets, name)
+	return ok
+}
+
+// AddResolver resolves the names which are not added, e.g., the machines of a `Manager`, see `ManagerRoutes`. The
+// resolvers are tried in the order of adding.
+func (r *Router) AddResolver(resolve func(name string) (RouteTarget, bool)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.resolvers = append(r.resolvers, resolve)
+}
+
+// SetErrorHandler sets the handler of the errors returned by the targets, e.g., to log the rejected events. The
+// handler is invoked in the delivering goroutine, the errors are dropped if it is nil.
+func (r *Router) SetErrorHandler(handler func(name string, ev Event, err error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onError = handler
+}
+
+// SetMaxHops sets the max length of a chain of routed events, 32 by default. See `SendContext`.
+func (r *Router) SetMaxHops(hops int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.maxHops = hops
+}
+
+// Send routes the event to the machine of name, see `SendContext`.
+func (r *Router) Send(name string, ev Event) error {
+	return r.SendContext(context.Background(), name, ev)
+}
+
+// SendContext routes the event to the machine of name. It returns after the event is queued, so the result of
+// the delivery is reported to `SetErrorHandler`. It returns `ErrRouteNotFound` if the name is not routed, and
+// `ErrQueueClosed` if the router is closed.
+//
+// The event is delivered with the values of ctx, e.g., the services of `FSM.SetServices`, but without its
+// cancellation. The actions should send the events with `FSM.ActionContext`, which carries the chain of the routed
+// events causing the action, so the loops are detected: it returns `ErrRoutingLoop` if the same event is routed to
+// the same name twice in a chain, e.g., "ping" from A to B causes "ping" from B to A and "ping" from A to B again,
+// or the chain is longer than `SetMaxHops`.
+func (r *Router) SendContext(ctx context.Context, name string, ev Event) error {
+	hops, _ := ctx.Value(routeHopsKey{}).([]routeHop)
+	hop := routeHop{target: name, event: ev.FSMEventID()}
+	for _, h := range hops {
+		if h == hop {
+			return ErrRoutingLoop
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.drained || r.closed && len(hops) == 0 {
+		return ErrQueueClosed
+	}
+	if len(hops) >= r.maxHops {
+		return ErrRoutingLoop
+	}
+	target, ok := r.resolve(name)
+	if !ok {
+		return ErrRouteNotFound
+	}
+	hops = append(hops[:len(hops):len(hops)], hop)
+	ctx = context.WithValue(context.WithoutCancel(ctx), routeHopsKey{}, hops)
+	box := r.mailboxes[name]
+	if box == nil {
+		box = &routeMailbox{}
+		r.mailboxes[name] = box
+	}
+	box.queue = append(box.queue, routedEvent{ctx: ctx, target: target, ev: ev})
+	if !box.running {
+		box.running = true
+		r.running.Add(1)
+		go r.deliver(name, box)
+	}
+	return nil
+}
+
+// resolve returns the target of name, the caller should hold mu.
+func (r *Router) resolve(name string) (RouteTarget, bool) {
+	if target, ok := r.targets[name]; ok {
+		return target, true
+	}
+	for _, resolve := range r.resolvers {
+		if target, ok := resolve(name); ok {
+			return target, true
+		}
+	}
+	return nil, false
+}
+
+// deliver delivers the events of the mailbox in order, until it is empty.
+func (r *Router) deliver(name string, box *routeMailbox) {
+	defer r.running.Done()
+	for {
+		r.mu.Lock()
+		if len(box.queue) == 0 {
+			box.running = false
+			delete(r.mailboxes, name)
+			r.mu.Unlock()
+			return
+		}
+		routed := box.queue[0]
+		box.queue[0] = routedEvent{}
+		box.queue = box.queue[1:]
+		r.mu.Unlock()
+		if err := routed.target.ProcessEventContext(routed.ctx, routed.ev); err != nil {
+			r.reportError(name, routed.ev, err)
+		}
+	}
+}
+
+// reportError invokes the handler of `SetErrorHandler` if it is set.
+func (r *Router) reportError(name string, ev Event, err error) {
+	r.mu.Lock()
+	onError := r.onError
+	r.mu.Unlock()
+	if onError != nil {
+		onError(name, ev, err)
+	}
+}
+
+// Close stops routing the new events, and waits for the pending events to be delivered, including the events
+// sent by their actions with `FSM.ActionContext`. After it returns, `Send` returns `ErrQueueClosed`. The targets
+// should be closed after the router.
+func (r *Router) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+	r.running.Wait()
+	r.mu.Lock()
+	r.drained = true
+	r.mu.Unlock()
+	return nil
+}
+
+type managerTarget[K comparable] struct {
+	manager *Manager[K]
+	key     K
+}
+
+func (t managerTarget[K]) ProcessEventContext(ctx context.Context, ev Event) error {
+	return t.manager.ProcessEventContext(ctx, t.key, ev)
+}
+
+// ManagerRoutes returns the resolver of `Router.AddResolver`, which routes the names to the machines of the
+// manager by the keys parsed by key, e.g., "order/42" to the order 42. The names whose keys are not parsed are left
+// to the next resolver.
+func ManagerRoutes[K comparable](manager *Manager[K], key func(name string) (K, bool)) func(name string) (
+	RouteTarget, bool) {
+	return func(name string) (RouteTarget, bool) {
+		k, ok := key(name)
+		if !ok {
+			return nil, false
+		}
+		return managerTarget[K]{manager: manager, key: k}, true
+	}
+}
@@ -0,0 +1,159 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// newPingPong creates a queued machine which counts the events, and sends the event "ping" or "pong" to peer
+// by the router.
+func newPingPong(router *Router, peer string, reply string, count *int) *QueuedFSM {
+	machine := NewQueuedFSM(StringState("idle"), nil)
+	_ = machine.AddEvent("ping")
+	_ = machine.AddEvent("pong")
+	for _, evID := range []string{"ping", "pong"} {
+		_ = machine.AddTransition(StringState("idle"), evID, StringState("idle"), func(interface{}, Event) error {
+			*count++
+			if reply == "" {
+				return nil
+			}
+			return router.SendContext(machine.ActionContext(), peer, StringEvent(reply))
+		}, nil)
+	}
+	return machine
+}
+
+func TestRouter(t *testing.T) {
+	router := NewRouter()
+	var pinged, ponged int
+	a := newPingPong(router, "b", "ping", &pinged)
+	defer a.Close()
+	b := newPingPong(router, "a", "", &ponged)
+	defer b.Close()
+	assert.Nil(t, router.Add("a", a))
+	assert.Nil(t, router.Add("b", b))
+	assert.Equal(t, AlreadyExists, router.Add("a", b))
+
+	assert.Nil(t, router.Send("a", StringEvent("pong")))
+	assert.Equal(t, ErrRouteNotFound, router.Send("c", StringEvent("ping")))
+	assert.Nil(t, router.Close())
+	assert.Equal(t, 1, pinged)
+	assert.Equal(t, 1, ponged)
+	assert.Equal(t, ErrQueueClosed, router.Send("a", StringEvent("ping")))
+}
+
+func TestRouterLoop(t *testing.T) {
+	router := NewRouter()
+	var mu sync.Mutex
+	var errs []error
+	router.SetErrorHandler(func(name string, ev Event, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	})
+	var aCount, bCount int
+	a := NewQueuedFSM(StringState("idle"), nil)
+	defer a.Close()
+	_ = a.AddEvent("ping")
+	_ = a.AddTransition(StringState("idle"), "ping", StringState("idle"), func(interface{}, Event) error {
+		aCount++
+		return router.SendContext(a.ActionContext(), "b", StringEvent("ping"))
+	}, nil)
+	b := newPingPong(router, "a", "ping", &bCount)
+	defer b.Close()
+	assert.Nil(t, router.Add("a", a))
+	assert.Nil(t, router.Add("b", b))
+
+	// a -> b -> a ..., the second "ping" to a is a loop, which fails the action of b.
+	assert.Nil(t, router.Send("a", StringEvent("ping")))
+	assert.Nil(t, router.Close())
+	assert.Equal(t, 1, aCount)
+	assert.Equal(t, 1, bCount)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []error{ErrRoutingLoop}, errs)
+}
+
+func TestRouterMaxHops(t *testing.T) {
+	router := NewRouter()
+	router.SetMaxHops(3)
+	var errs []error
+	router.SetErrorHandler(func(name string, ev Event, err error) {
+		errs = append(errs, err)
+	})
+	var counts [5]int
+	for i := range counts {
+		i := i
+		machine := NewQueuedFSM(StringState("idle"), nil)
+		defer machine.Close()
+		_ = machine.AddEvent("next")
+		_ = machine.AddTransition(StringState("idle"), "next", StringState("idle"), func(interface{}, Event) error {
+			counts[i]++
+			return router.SendContext(machine.ActionContext(), strconv.Itoa(i+1), StringEvent("next"))
+		}, nil)
+		assert.Nil(t, router.Add(strconv.Itoa(i), machine))
+	}
+	assert.Nil(t, router.Send("0", StringEvent("next")))
+	assert.Nil(t, router.Close())
+	assert.Equal(t, [5]int{1, 1, 1, 0, 0}, counts)
+	assert.Equal(t, []error{ErrRoutingLoop}, errs)
+}
+
+func TestRouterManager(t *testing.T) {
+	paid := make(map[int]int)
+	var mu sync.Mutex
+	manager := NewManager(func(key int) (*FSM, error) {
+		machine := NewFSM(StringState("created"), nil)
+		_ = machine.AddState(StringState("paid"))
+		_ = machine.AddEvent("pay")
+		_ = machine.AddTransition(StringState("created"), "pay", StringState("paid"), func(interface{}, Event) error {
+			mu.Lock()
+			defer mu.Unlock()
+			paid[key]++
+			return nil
+		}, nil)
+		return machine, nil
+	}, ManagerOptions[int]{})
+	defer manager.Close()
+	router := NewRouter()
+	router.AddResolver(ManagerRoutes(manager, func(name string) (int, bool) {
+		key, err := strconv.Atoi(name)
+		return key, err == nil
+	}))
+	var errs []error
+	router.SetErrorHandler(func(name string, ev Event, err error) {
+		errs = append(errs, errors.New(name+": "+err.Error()))
+	})
+	assert.Nil(t, router.Send("1", StringEvent("pay")))
+	assert.Nil(t, router.Send("2", StringEvent("pay")))
+	assert.Nil(t, router.Send("2", StringEvent("pay")))
+	assert.Equal(t, ErrRouteNotFound, router.Send("order", StringEvent("pay")))
+	assert.Nil(t, router.Close())
+	assert.Equal(t, map[int]int{1: 1, 2: 1}, paid)
+	assert.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "2: ")
+}
+
+func TestRouterContextValues(t *testing.T) {
+	router := NewRouter()
+	machine := NewQueuedFSM(StringState("idle"), nil)
+	defer machine.Close()
+	_ = machine.AddEvent("charge")
+	var charged string
+	_ = machine.AddTransition(StringState("idle"), "charge", StringState("idle"),
+		machine.ContextAction(func(ctx context.Context, _ interface{}, _ Event) error {
+			charged, _ = Service[string](ctx, "gateway")
+			return ctx.Err()
+		}), nil)
+	assert.Nil(t, router.Add("billing", machine))
+	// the values are delivered without the cancellation.
+	ctx, cancel := context.WithCancel(WithServices(context.Background(), Services{"gateway": "stripe"}))
+	cancel()
+	assert.Nil(t, router.SendContext(ctx, "billing", StringEvent("charge")))
+	assert.Nil(t, router.Close())
+	assert.Equal(t, "stripe", charged)
+}
@@ -0,0 +1,67 @@
+package fsm
+
+import "context"
+
+// Services are the named dependencies of the actions and guards of a machine, e.g., the clients of databases and
+// payment gateways. See `FSM.SetServices`.
+type Services map[string]interface{}
+
+type servicesKey struct{}
+
+// SetServices sets the services of the FSM, which are carried by the `ActionContext`, so the actions can be
+// top-level functions rather than closures capturing the dependencies:
+//
+//	func charge(ctx context.Context, payload interface{}, ev fsm.Event) error {
+//		payments, _ := fsm.Service[*PaymentClient](ctx, "payments")
+//		return payments.Charge(ctx, payload.(*Order).Amount)
+//	}
+//
+//	machine.SetServices(fsm.Services{"payments": client})
+//	machine.AddTransition(created, "pay", paid, machine.ContextAction(charge), nil)
+//
+// NOTE: the services are shared by the actions, they should be thread-safe if the machines run concurrently.
+func (fsm *FSM) SetServices(services Services) {
+	fsm.services = services
+}
+
+// WithServices returns a copy of ctx carrying the services, e.g., to test an action of `FSM.ContextAction`
+// without a machine.
+func WithServices(ctx context.Context, services Services) context.Context {
+	return context.WithValue(ctx, servicesKey{}, services)
+}
+
+// Service returns the service `name` carried by ctx. It returns false if there is no such service, or the
+// service is not a T.
+func Service[T any](ctx context.Context, name string) (T, bool) {
+	services, _ := ctx.Value(servicesKey{}).(Services)
+	service, ok := services[name].(T)
+	return service, ok
+}
+
+// ContextAction adapts the action taking the `ActionContext`, which carries the services of `SetServices`, to an
+// action of the FSM.
+func (fsm *FSM) ContextAction(action func(ctx context.Context, payload interface{}, ev Event) error) func(
+	interface{}, Event) error {
+	return func(payload interface{}, ev Event) error {
+		return action(fsm.ActionContext(), payload, ev)
+	}
+}
+
+// ContextGuard adapts the guard taking a context, which carries the services of `SetServices`, to a guard of the
+// FSM.
+// NOTE: the guards are evaluated by `CanFire` and `Simulate` without a context, so the context of guards is
+// `context.Background()` with the services.
+func (fsm *FSM) ContextGuard(guard func(ctx context.Context, payload interface{}, ev Event) bool) func(
+	interface{}, Event) bool {
+	return func(payload interface{}, ev Event) bool {
+		return guard(fsm.servicesContext(context.Background()), payload, ev)
+	}
+}
+
+// servicesContext returns ctx carrying the services of the FSM, or ctx itself if there is no service.
+func (fsm *FSM) servicesContext(ctx context.Context) context.Context {
+	if fsm.services == nil {
+		return ctx
+	}
+	return WithServices(ctx, fsm.services)
+}
@@ -0,0 +1,82 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type ledger struct {
+	entries []int
+}
+
+type invoice struct {
+	amount int
+}
+
+func charge(ctx context.Context, payload interface{}, _ Event) error {
+	l, ok := Service[*ledger](ctx, "ledger")
+	if !ok {
+		return errors.New("no ledger")
+	}
+	l.entries = append(l.entries, payload.(*invoice).amount)
+	return nil
+}
+
+func withinLimit(ctx context.Context, payload interface{}, _ Event) bool {
+	limit, _ := Service[int](ctx, "limit")
+	return payload.(*invoice).amount <= limit
+}
+
+func newInvoiceFSM(payload *invoice) *FSM {
+	fsm := NewFSM(StringState("issued"), payload)
+	_ = fsm.AddState(StringState("paid"))
+	_ = fsm.AddEvent("pay")
+	_ = fsm.AddTransition(StringState("issued"), "pay", StringState("paid"), fsm.ContextAction(charge),
+		fsm.ContextGuard(withinLimit))
+	return fsm
+}
+
+func TestServices(t *testing.T) {
+	l := &ledger{}
+	fsm := newInvoiceFSM(&invoice{amount: 10})
+	fsm.SetServices(Services{"ledger": l, "limit": 100})
+	assert.True(t, fsm.CanFire(StringEvent("pay")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("pay")))
+	assert.Equal(t, []int{10}, l.entries)
+
+	// the guard rejects the invoice over the limit.
+	fsm = newInvoiceFSM(&invoice{amount: 1000})
+	fsm.SetServices(Services{"ledger": l, "limit": 100})
+	assert.False(t, fsm.CanFire(StringEvent("pay")))
+
+	// the action fails without the services.
+	fsm = newInvoiceFSM(&invoice{amount: 0})
+	assert.EqualError(t, fsm.ProcessEvent(StringEvent("pay")), "no ledger")
+}
+
+func TestServicesWithActionTimeout(t *testing.T) {
+	l := &ledger{}
+	fsm := NewFSM(StringState("issued"), &invoice{amount: 5})
+	assert.Nil(t, fsm.AddState(StringState("paid")))
+	assert.Nil(t, fsm.AddEvent("pay"))
+	assert.Nil(t, fsm.AddTransitionWithOptions(StringState("issued"), "pay", StringState("paid"),
+		fsm.ContextAction(charge), nil, TransitionOptions{ActionTimeout: time.Minute}))
+	fsm.SetServices(Services{"ledger": l})
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("pay")))
+	assert.Equal(t, []int{5}, l.entries)
+}
+
+func TestService(t *testing.T) {
+	ctx := WithServices(context.Background(), Services{"ledger": &ledger{}, "limit": 3})
+	_, ok := Service[*ledger](ctx, "ledger")
+	assert.True(t, ok)
+	// the service is not a *ledger.
+	_, ok = Service[*ledger](ctx, "limit")
+	assert.False(t, ok)
+	_, ok = Service[int](context.Background(), "limit")
+	assert.False(t, ok)
+	assert.Nil(t, charge(ctx, &invoice{amount: 1}, StringEvent("pay")))
+}
@@ -0,0 +1,94 @@
+package fsm
+
+import "errors"
+
+// Simulate returns the state which `CurrentState` would return if the event was processed, without invoking
+// actions, notifying observers or changing the current state. The guards are evaluated, so they should be free
+// of side effects. It returns the error if `ProcessEvent` would fail because there is no transition.
+// NOTE: the errors of actions cannot be predicted. The join transitions are evaluated against the current
+// states.
+func (fsm *FSM) Simulate(ev Event) (to State, wouldErr error) {
+	next, err := fsm.simulate(ev)
+	if err != nil {
+		return nil, err
+	}
+	return fsm.states[next], nil
+}
+
+func (fsm *FSM) simulate(ev Event) (string, error) {
+	if ev.FSMEventID() == CompletionEventID {
+		return "", noTrasitionFromStateAndEvent(fsm.curState, ev)
+	}
+	if sub, ok := fsm.subMachines[fsm.curState]; ok && sub.machine.CanFire(ev) {
+		subNext, err := sub.machine.simulate(ev)
+		if err != nil {
+			return "", err
+		}
+		if !sub.machine.isFinal(subNext) {
+			return fsm.curState, nil
+		}
+		return fsm.simulate(&SubMachineDoneEvent{ID: sub.doneEvent, Machine: sub.machine})
+	}
+	next, handled := "", false
+	if fsm.parallel[fsm.curState] {
+		for _, region := range fsm.children[fsm.curState] {
+			for from := fsm.regionLeaf(region); from != fsm.curState; from = fsm.parents[from] {
+				t := fsm.firstAccepted(from, ev, fsm.transitionsOf(from, ev))
+				if t == nil {
+					continue
+				}
+				handled = true
+				if target := fsm.resolveState(t.to.FSMStateID()); !fsm.isDescendant(target, region) {
+					next = target
+				}
+				break
+			}
+			if next != "" {
+				break
+			}
+		}
+		if handled && next == "" {
+			next = fsm.curState
+		}
+	}
+	for from, ok := fsm.curState, !handled; ok; from, ok = fsm.parents[from] {
+		if t := fsm.firstAccepted(from, ev, fsm.transitionsOf(from, ev)); t != nil {
+			next, handled = fsm.resolveState(t.to.FSMStateID()), true
+			break
+		}
+	}
+	if !handled {
+		return "", noTrasitionFromStateAndEvent(fsm.curState, ev)
+	}
+	return fsm.simulateCompletion(ev, fsm.visibleState(next))
+}
+
+// simulateCompletion follows the completion transitions from state `from`.
+func (fsm *FSM) simulateCompletion(cause Event, from string) (string, error) {
+	for i := 0; i < maxCompletionSteps; i++ {
+		t := fsm.firstAccepted(from, CompletionEvent{Cause: cause}, fsm.transitions[from][CompletionEventID])
+		if t == nil {
+			return from, nil
+		}
+		from = fsm.visibleState(fsm.resolveState(t.to.FSMStateID()))
+	}
+	return "", errors.New("too many completion transitions, there may be a loop")
+}
+
+// firstAccepted returns the first transition in transList of state `from` whose guard returns true, or nil.
+func (fsm *FSM) firstAccepted(from string, ev Event, transList []*transition) *transition {
+	for _, t := range transList {
+		if (t.join == nil || fsm.joinReady(t)) && fsm.checkGuard(from, t, ev) {
+			return t
+		}
+	}
+	return nil
+}
+
+// visibleState returns the state returned by `CurrentState` after the FSM enters the leaf state.
+func (fsm *FSM) visibleState(leaf string) string {
+	if parallel, ok := fsm.parallelAncestor(leaf); ok {
+		return parallel
+	}
+	return leaf
+}
@@ -0,0 +1,53 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSimulate(t *testing.T) {
+	fsm := newPlayerFSM(t)
+	assert.Nil(t, fsm.AddTransition(StringState("paused"), "switch", StringState("stopped"),
+		func(interface{}, Event) error {
+			panic("the action should not be invoked")
+		}, nil))
+	to, err := fsm.Simulate(StringEvent("start"))
+	assert.Nil(t, err)
+	assert.Equal(t, StringState("video"), to)
+	_, err = fsm.Simulate(StringEvent("pause"))
+	assert.NotNil(t, err)
+	assert.Equal(t, StringState("stopped"), fsm.CurrentState())
+
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("start")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("pause")))
+	to, err = fsm.Simulate(StringEvent("switch"))
+	assert.Nil(t, err)
+	assert.Equal(t, StringState("stopped"), to)
+	to, err = fsm.Simulate(StringEvent("stop"))
+	assert.Nil(t, err)
+	assert.Equal(t, StringState("stopped"), to)
+	assert.Equal(t, StringState("paused"), fsm.CurrentState())
+	assert.Equal(t, uint64(2), fsm.Version())
+}
+
+func TestSimulateCompletionAndRegions(t *testing.T) {
+	fsm := newDeviceFSM(t)
+	assert.Nil(t, fsm.AddState(StringState("booting")))
+	assert.Nil(t, fsm.AddEvent("boot"))
+	assert.Nil(t, fsm.AddTransition(StringState("off"), "boot", StringState("booting"), nil, nil))
+	assert.Nil(t, fsm.AddCompletionTransition(StringState("booting"), StringState("online"), nil, nil))
+	to, err := fsm.Simulate(StringEvent("boot"))
+	assert.Nil(t, err)
+	assert.Equal(t, StringState("on"), to)
+
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("powerOn")))
+	to, err = fsm.Simulate(StringEvent("connect"))
+	assert.Nil(t, err)
+	assert.Equal(t, StringState("on"), to)
+	to, err = fsm.Simulate(StringEvent("drain"))
+	assert.Nil(t, err)
+	assert.Equal(t, StringState("off"), to)
+	_, err = fsm.Simulate(StringEvent("boot"))
+	assert.NotNil(t, err)
+	assert.Equal(t, []State{StringState("offline"), StringState("discharging")}, fsm.CurrentStates())
+}
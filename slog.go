@@ -0,0 +1,132 @@
+package fsm
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// SlogAttrs is a set of attributes logged by the slog observer.
+type SlogAttrs int
+
+const (
+	SlogAttrMachine SlogAttrs = 1 << iota
+	SlogAttrEvent
+	SlogAttrFrom
+	SlogAttrTo
+	SlogAttrDuration
+	SlogAttrError
+	// SlogAttrCorrelation logs the `correlation_id` and `causation_id` of the events wrapped by `Envelope`.
+	SlogAttrCorrelation
+	// SlogAttrActor logs the `actor` of the events attributed by `ProcessEventAs` or `WithActor`.
+	SlogAttrActor
+
+	SlogAttrAll = SlogAttrMachine | SlogAttrEvent | SlogAttrFrom | SlogAttrTo | SlogAttrDuration | SlogAttrError |
+		SlogAttrCorrelation | SlogAttrActor
+)
+
+// SlogOptions are the optional arguments of `WithSlogOptions`.
+type SlogOptions struct {
+	// Name is logged as the `machine` attribute, the name of FSM by default.
+	Name string
+	// Level is the level of succeeded transitions, slog.LevelInfo by default.
+	Level slog.Leveler
+	// ErrorLevel is the level of failed transitions, slog.LevelWarn by default.
+	ErrorLevel slog.Leveler
+	// Attrs is the set of logged attributes, SlogAttrAll by default.
+	Attrs SlogAttrs
+}
+
+// WithSlog logs every event processing of the FSM to logger.
+func (fsm *FSM) WithSlog(logger *slog.Logger) {
+	fsm.WithSlogOptions(logger, SlogOptions{})
+}
+
+// WithSlogOptions is the same as `WithSlog`, but the log records are configured by opts.
+func (fsm *FSM) WithSlogOptions(logger *slog.Logger, opts SlogOptions) {
+	if opts.Level == nil {
+		opts.Level = slog.LevelInfo
+	}
+	if opts.ErrorLevel == nil {
+		opts.ErrorLevel = slog.LevelWarn
+	}
+	if opts.Attrs == 0 {
+		opts.Attrs = SlogAttrAll
+	}
+	fsm.AddObserver(&slogObserver{logger: logger, opts: opts})
+}
+
+type slogObserver struct {
+	NopObserver
+	logger *slog.Logger
+	opts   SlogOptions
+
+	// the processing event
+	begin time.Time
+	from  string
+	to    string
+}
+
+func (o *slogObserver) EventStarted(ctx context.Context, fsm *FSM, ev Event) {
+	o.begin = fsm.clock.Now()
+	o.from = fsm.curState
+	o.to = ""
+}
+
+func (o *slogObserver) ActionFinished(ctx context.Context, fsm *FSM, args ActionHookArgs,
+	duration time.Duration, err error) {
+	o.to = args.ToState.FSMStateID()
+}
+
+func (o *slogObserver) EventFinished(ctx context.Context, fsm *FSM, ev Event, err error) {
+	level := o.opts.Level.Level()
+	if err != nil {
+		level = o.opts.ErrorLevel.Level()
+	}
+	if !o.logger.Enabled(ctx, level) {
+		return
+	}
+	attrs := make([]slog.Attr, 0, 8)
+	if o.opts.Attrs&SlogAttrMachine != 0 {
+		name := o.opts.Name
+		if name == "" {
+			name = fsm.Name()
+		}
+		if name != "" {
+			attrs = append(attrs, slog.String("machine", name))
+		}
+	}
+	if o.opts.Attrs&SlogAttrEvent != 0 {
+		attrs = append(attrs, slog.String("event", ev.FSMEventID()))
+	}
+	if env, ok := ev.(*Envelope); ok && o.opts.Attrs&SlogAttrCorrelation != 0 {
+		if env.CorrelationID != "" {
+			attrs = append(attrs, slog.String("correlation_id", env.CorrelationID))
+		}
+		if env.CausationID != "" {
+			attrs = append(attrs, slog.String("causation_id", env.CausationID))
+		}
+	}
+	if o.opts.Attrs&SlogAttrActor != 0 {
+		if actor := ActorOf(ev); actor != "" {
+			attrs = append(attrs, slog.String("actor", actor))
+		}
+	}
+	if o.opts.Attrs&SlogAttrFrom != 0 {
+		attrs = append(attrs, slog.String("from", o.from))
+	}
+	if o.opts.Attrs&SlogAttrTo != 0 && o.to != "" {
+		attrs = append(attrs, slog.String("to", o.to))
+	}
+	if o.opts.Attrs&SlogAttrDuration != 0 {
+		attrs = append(attrs, slog.Duration("duration", fsm.clock.Now().Sub(o.begin)))
+	}
+	if o.opts.Attrs&SlogAttrError != 0 && err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	msg := "fsm transition"
+	if err != nil {
+		msg = "fsm transition failed"
+	}
+	o.logger.LogAttrs(ctx, level, msg, attrs...)
+}
@@ -0,0 +1,67 @@
+package fsm
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestWithSlog(t *testing.T) {
+	var (
+		on  = StringState("on")
+		off = StringState("off")
+	)
+	fsm := NewFSM(off, nil)
+	assert.Nil(t, fsm.AddState(on))
+	assert.Nil(t, fsm.AddEvent("switch"))
+	assert.Nil(t, fsm.AddTransition(off, "switch", on, nil, nil))
+	assert.Nil(t, fsm.AddTransition(on, "switch", off, func(i interface{}, event Event) error {
+		return errors.New("stuck")
+	}, nil))
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "duration" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	fsm.SetName("light")
+	fsm.WithSlog(logger)
+
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("switch")))
+	assert.NotNil(t, fsm.ProcessEvent(StringEvent("switch")))
+	assert.NotNil(t, fsm.ProcessEvent(StringEvent("unknown")))
+	assert.Equal(t, []string{
+		`level=INFO msg="fsm transition" machine=light event=switch from=off to=on`,
+		`level=WARN msg="fsm transition failed" machine=light event=switch from=on to=off error=stuck`,
+		`level=WARN msg="fsm transition failed" machine=light event=unknown from=on ` +
+			`error="no transition from state(on) and event(unknown)"`,
+	}, strings.Split(strings.TrimSpace(buf.String()), "\n"))
+
+	buf.Reset()
+	fsm = NewFSM(off, nil)
+	fsm.WithSlogOptions(logger, SlogOptions{Level: slog.LevelDebug, Attrs: SlogAttrEvent})
+	assert.NotNil(t, fsm.ProcessEvent(StringEvent("switch")))
+	assert.Equal(t, "level=WARN msg=\"fsm transition failed\" event=switch\n", buf.String())
+}
+
+func TestSlogEnvelope(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	fsm := NewFSM(StringState("off"), nil)
+	assert.Nil(t, fsm.AddEvent("switch"))
+	assert.Nil(t, fsm.AddTransition(StringState("off"), "switch", StringState("off"), nil, nil))
+	fsm.WithSlogOptions(logger, SlogOptions{Attrs: SlogAttrEvent | SlogAttrCorrelation})
+	assert.Nil(t, fsm.ProcessEvent(&Envelope{Event: StringEvent("switch"), CorrelationID: "req-1", CausationID: "1"}))
+	assert.Contains(t, buf.String(), "event=switch correlation_id=req-1 causation_id=1\n")
+
+	buf.Reset()
+	fsm.WithSlogOptions(logger, SlogOptions{Attrs: SlogAttrEvent | SlogAttrActor})
+	assert.Nil(t, fsm.ProcessEventAs("alice", StringEvent("switch")))
+	assert.Contains(t, buf.String(), "event=switch actor=alice\n")
+}
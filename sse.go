@@ -0,0 +1,98 @@
+package fsm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SSEKeepAlive is the interval of the keep-alive comments sent by the handler of `NewSSEHandler`, which prevents
+// the idle connections from being closed by the proxies.
+var SSEKeepAlive = 15 * time.Second
+
+// TransitionNotification is the JSON form of a `StateChange` streamed by `NewSSEHandler`.
+type TransitionNotification struct {
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Event   string    `json:"event"`
+	Actor   string    `json:"actor,omitempty"`
+	Time    time.Time `json:"time"`
+	Dropped int       `json:"dropped,omitempty"`
+}
+
+type sseHandler struct {
+	fsm *FSM
+}
+
+// NewSSEHandler creates an HTTP handler streaming the state changes of the machine as Server-Sent Events, so the
+// browsers can watch the machine by `EventSource`. The stream starts with a `state` event, whose data is the
+// `MachineStatus` of the machine, followed by a `transition` event of `TransitionNotification` per state change.
+// The stream ends when the client disconnects.
+//
+// NOTE: the notifications are delivered by `Subscribe`, the slow clients may miss some of them, which is
+// reported by the `dropped` field.
+func NewSSEHandler(fsm *FSM) http.Handler {
+	return &sseHandler{fsm: fsm}
+}
+
+func (h *sseHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, httpError{Error: "streaming is not supported"})
+		return
+	}
+	// subscribe before the initial state, so no change is missed between them.
+	changes, cancel := h.fsm.Subscribe()
+	defer cancel()
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if err := writeSSE(w, "state", machineStatus(h.fsm)); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(SSEKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case change, ok := <-changes:
+			if !ok {
+				return
+			}
+			if err := writeSSE(w, "transition", TransitionNotification{
+				From:    change.From.FSMStateID(),
+				To:      change.To.FSMStateID(),
+				Event:   change.Event.FSMEventID(),
+				Actor:   ActorOf(change.Event),
+				Time:    change.Time,
+				Dropped: change.Dropped,
+			}); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func writeSSE(w http.ResponseWriter, event string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
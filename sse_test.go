@@ -0,0 +1,68 @@
+package fsm
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// readSSE reads the next event of the stream, skipping the comments.
+func readSSE(t *testing.T, r *bufio.Reader) (string, string) {
+	var event, data string
+	for {
+		line, err := r.ReadString('\n')
+		assert.Nil(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if event != "" {
+				return event, data
+			}
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestSSEHandler(t *testing.T) {
+	h, fsm := newHTTPTestHandler(t)
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/machines/kitchen/stream")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	r := bufio.NewReader(resp.Body)
+
+	event, data := readSSE(t, r)
+	assert.Equal(t, "state", event)
+	var status MachineStatus
+	assert.Nil(t, json.Unmarshal([]byte(data), &status))
+	assert.Equal(t, "off", status.CurrentState)
+
+	assert.Nil(t, fsm.ProcessEventAs("alice", StringEvent("switch")))
+	event, data = readSSE(t, r)
+	assert.Equal(t, "transition", event)
+	var notification TransitionNotification
+	assert.Nil(t, json.Unmarshal([]byte(data), &notification))
+	assert.Equal(t, "off", notification.From)
+	assert.Equal(t, "on", notification.To)
+	assert.Equal(t, "switch", notification.Event)
+	assert.Equal(t, "alice", notification.Actor)
+	assert.False(t, notification.Time.IsZero())
+	assert.Equal(t, 0, notification.Dropped)
+}
+
+func TestSSEHandlerMethod(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewSSEHandler(NewFSM(StringState("off"), nil)).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
@@ -0,0 +1,68 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotStarted is returned by `ProcessEvent` of the FSM created without the initial state, before `Start`.
+var ErrNotStarted = errors.New("the FSM is not started")
+
+// StartEvent is the `StateChange.Event` and the `CompletionEvent.Cause` of the state change made by `Start`.
+type StartEvent struct{}
+
+func (StartEvent) FSMEventID() string {
+	return "start()"
+}
+
+// Start moves the FSM to initState, which becomes the initial state of the FSM, as if it is entered by a
+// transition, so the FSM can be built by `NewFSM` without an initial state, and started after all states are
+// added. It can also restart a started or finished machine.
+//   - initState should be added. If it is a composite state, its initial child is entered, and its history is
+//     restored if it is a history state. If it is a parallel state, its regions start from their initial children.
+//   - The sub-machine of initState is reset, and the completion transitions from initState are fired.
+//   - The subscribers receive a `StateChange` whose event is `StartEvent`, and the `Version` is increased. The
+//     From of the change is nil if the FSM is not started before.
+//   - The transitions kept for `Rollback` are dropped.
+//
+// It returns the error of the completion transitions, or of the internal events posted by them.
+// NOTE: Like `ProcessEvent`, it should not be invoked in action/guard.
+func (fsm *FSM) Start(initState State) error {
+	return fsm.StartContext(context.Background(), initState)
+}
+
+// StartContext is the same as `Start`, the ctx is passed to the observers of the completion transitions.
+func (fsm *FSM) StartContext(ctx context.Context, initState State) error {
+	if initState == nil {
+		return errors.New("the initial state should not be nil")
+	}
+	if !fsm.HasState(initState) {
+		return stateNotFound(initState)
+	}
+	fsm.checkOwner()
+	fsm.processEventInvokeCounter += 1
+	defer func() {
+		fsm.processEventInvokeCounter -= 1
+		fsm.internalEvents = nil
+	}()
+	if fsm.processEventInvokeCounter != 1 {
+		panic(ShouldNotReEnterPanic)
+	}
+	prev := fsm.curState
+	fsm.initState = initState.FSMStateID()
+	_, next := fsm.enter(prev, fsm.initState)
+	if sub, ok := fsm.subMachines[next]; ok {
+		sub.machine.reset()
+	}
+	for i := range fsm.undo {
+		fsm.undo[i] = undoEntry{}
+	}
+	fsm.undo = fsm.undo[:0]
+	ev := StartEvent{}
+	fsm.recordFrame(prev, ev)
+	fsm.publish(StateChange{From: fsm.states[prev], To: fsm.states[next], Event: ev, Time: fsm.clock.Now()})
+	if err := fsm.complete(ctx, ev); err != nil {
+		return err
+	}
+	return fsm.processInternalEvents(ctx)
+}
@@ -0,0 +1,93 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestStart(t *testing.T) {
+	fsm := NewFSM(nil, nil)
+	assert.Nil(t, fsm.CurrentState())
+	assert.Empty(t, fsm.CurrentStates())
+	assert.Nil(t, fsm.AddEvent("switch"))
+	assert.Equal(t, ErrNotStarted, fsm.ProcessEvent(StringEvent("switch")))
+
+	off, on := StringState("off"), StringState("on")
+	assert.Nil(t, fsm.AddState(off))
+	assert.Nil(t, fsm.AddState(on))
+	assert.Nil(t, fsm.AddTransition(off, "switch", on, nil, nil))
+	assert.Nil(t, fsm.AddTransition(on, "switch", off, nil, nil))
+	assert.NotNil(t, fsm.Start(nil))
+	assert.NotNil(t, fsm.Start(StringState("unknown")))
+
+	changes, cancel := fsm.Subscribe()
+	defer cancel()
+	assert.Nil(t, fsm.Start(on))
+	assert.Equal(t, on, fsm.CurrentState())
+	assert.Equal(t, uint64(1), fsm.Version())
+	change := <-changes
+	assert.Nil(t, change.From)
+	assert.Equal(t, on, change.To)
+	assert.Equal(t, StartEvent{}, change.Event)
+
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("switch")))
+	<-changes
+	assert.Equal(t, off, fsm.CurrentState())
+}
+
+func TestStartEntersInitialChild(t *testing.T) {
+	fsm := NewFSM(nil, nil)
+	idle, running, starting := StringState("idle"), StringState("running"), StringState("starting")
+	assert.Nil(t, fsm.AddState(idle))
+	assert.Nil(t, fsm.AddState(running))
+	assert.Nil(t, fsm.AddChildState(running, starting))
+	assert.Nil(t, fsm.Start(running))
+	assert.Equal(t, starting, fsm.CurrentState())
+	assert.True(t, fsm.IsIn(running))
+}
+
+func TestStartFiresCompletionTransitions(t *testing.T) {
+	fsm := NewFSM(nil, nil)
+	boot, ready := StringState("boot"), StringState("ready")
+	assert.Nil(t, fsm.AddState(boot))
+	assert.Nil(t, fsm.AddState(ready))
+	var causes []Event
+	assert.Nil(t, fsm.AddCompletionTransition(boot, ready, func(_ interface{}, ev Event) error {
+		causes = append(causes, ev.(CompletionEvent).Cause)
+		return nil
+	}, nil))
+	assert.Nil(t, fsm.Start(boot))
+	assert.Equal(t, ready, fsm.CurrentState())
+	assert.Equal(t, []Event{StartEvent{}}, causes)
+}
+
+func TestRestart(t *testing.T) {
+	fsm := NewFSM(StringState("pending"), nil)
+	done := StringState("done")
+	assert.Nil(t, fsm.AddState(done))
+	assert.Nil(t, fsm.AddEvent("finish"))
+	assert.Nil(t, fsm.AddTransition(StringState("pending"), "finish", done, nil, nil))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("finish")))
+	assert.True(t, fsm.InFinalState())
+
+	assert.Nil(t, fsm.Start(StringState("pending")))
+	assert.Equal(t, StringState("pending"), fsm.CurrentState())
+	assert.Equal(t, uint64(2), fsm.Version())
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("finish")))
+	assert.Equal(t, done, fsm.CurrentState())
+
+	// start from a later added state.
+	assert.Nil(t, fsm.Start(done))
+	assert.Equal(t, done, fsm.CurrentState())
+}
+
+func TestStartInAction(t *testing.T) {
+	fsm := NewFSM(StringState("a"), nil)
+	assert.Nil(t, fsm.AddEvent("go"))
+	assert.Nil(t, fsm.AddTransition(StringState("a"), "go", StringState("a"), func(interface{}, Event) error {
+		return fsm.Start(StringState("a"))
+	}, nil))
+	assert.PanicsWithValue(t, ShouldNotReEnterPanic, func() {
+		_ = fsm.ProcessEvent(StringEvent("go"))
+	})
+}
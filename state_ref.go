@@ -0,0 +1,40 @@
+package fsm
+
+import "sync/atomic"
+
+// AtomicStateRef is a read-only projection of the current state id of a FSM, which is updated by every
+// transition. It is read by an atomic load, so the high-frequency readers, e.g., the metrics scrapers and the
+// request routers, do not contend with the event processing, unlike `FSM.CurrentState`. See `FSM.StateRef`.
+type AtomicStateRef struct {
+	// id holds the *string of the current state id, which points into `FSM.stateIDs`, so storing it does not
+	// allocate.
+	id atomic.Value
+}
+
+// ID returns the current state id, or an empty string if the FSM is not started.
+func (r *AtomicStateRef) ID() string {
+	id, _ := r.id.Load().(*string)
+	if id == nil {
+		return ""
+	}
+	return *id
+}
+
+// Is returns true if the current state is `state`.
+func (r *AtomicStateRef) Is(state State) bool {
+	return r.ID() == state.FSMStateID()
+}
+
+// StateRef returns the read-only projection of the current state id, which can be read concurrently with
+// `ProcessEvent` without locks. The ref is the same during the lifetime of the FSM, so it can be shared with the
+// readers once:
+//
+//	ref := machine.StateRef()
+//	http.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
+//		fmt.Fprintln(w, ref.ID())
+//	})
+//
+// NOTE: like `CurrentState`, it is the parallel state rather than the states inside its regions.
+func (fsm *FSM) StateRef() *AtomicStateRef {
+	return &fsm.stateRef
+}
@@ -0,0 +1,58 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestStateRef(t *testing.T) {
+	fsm := NewFSM(StringState("off"), nil)
+	ref := fsm.StateRef()
+	assert.Equal(t, "off", ref.ID())
+	assert.True(t, ref.Is(StringState("off")))
+	assert.Nil(t, fsm.AddState(StringState("on")))
+	assert.Nil(t, fsm.AddEvent("switch"))
+	assert.Nil(t, fsm.AddTransition(StringState("off"), "switch", StringState("on"), nil, nil))
+	assert.Nil(t, fsm.AddTransition(StringState("on"), "switch", StringState("off"), nil, nil))
+	// the ids are interned into a growing slice, the ref should not be changed by the growth.
+	for _, state := range []string{"a", "b", "c", "d", "e"} {
+		assert.Nil(t, fsm.AddState(StringState(state)))
+	}
+
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("switch")))
+	assert.Equal(t, "on", ref.ID())
+	assert.Same(t, ref, fsm.StateRef())
+	assert.Nil(t, fsm.Restore([]State{StringState("off")}, 1))
+	assert.Equal(t, "off", ref.ID())
+
+	fsm = NewFSM(nil, nil)
+	assert.Equal(t, "", fsm.StateRef().ID())
+	assert.Nil(t, fsm.AddState(StringState("idle")))
+	assert.Nil(t, fsm.Start(StringState("idle")))
+	assert.Equal(t, "idle", fsm.StateRef().ID())
+}
+
+func TestStateRefConcurrent(t *testing.T) {
+	fsm := NewFSM(StringState("off"), nil)
+	assert.Nil(t, fsm.AddState(StringState("on")))
+	assert.Nil(t, fsm.AddEvent("switch"))
+	assert.Nil(t, fsm.AddTransition(StringState("off"), "switch", StringState("on"), nil, nil))
+	assert.Nil(t, fsm.AddTransition(StringState("on"), "switch", StringState("off"), nil, nil))
+	ref := fsm.StateRef()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			if id := ref.ID(); id != "on" && id != "off" {
+				t.Errorf("unexpected state %q", id)
+			}
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		assert.Nil(t, fsm.ProcessEvent(StringEvent("switch")))
+	}
+	wg.Wait()
+	assert.Equal(t, "off", ref.ID())
+}
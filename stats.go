@@ -0,0 +1,326 @@
+package fsm
+
+import (
+	"math/bits"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LatencyStats is the aggregate of durations. The P99 is estimated by a histogram whose buckets are within
+// 25% of their values.
+type LatencyStats struct {
+	Count uint64
+	Min   time.Duration
+	Avg   time.Duration
+	Max   time.Duration
+	P99   time.Duration
+}
+
+// TransitionStats is the statistics of the transitions of an edge. See `FSM.Stats`.
+type TransitionStats struct {
+	From  State
+	Event string
+	To    State
+	// Errors is the number of actions which returned errors, they are counted in the latency as well.
+	Errors uint64
+	// Latency is the duration of the actions.
+	Latency LatencyStats
+}
+
+// EventStats is the statistics of an event. See `FSM.Stats`.
+type EventStats struct {
+	Event string
+	// Errors is the number of `ProcessEvent` which returned errors, including the rejected events.
+	Errors uint64
+	// Latency is the duration of processing the events, including the guards and observers.
+	Latency LatencyStats
+}
+
+// StateStats is the statistics of a state. See `FSM.Stats`.
+type StateStats struct {
+	State State
+	// Entries is the number of times the state was entered, including the self-transitions.
+	Entries uint64
+	// Dwell is the time spent in the state by the finished visits, whose mean is `Dwell.Avg`, and Total is the sum.
+	Dwell LatencyStats
+	Total time.Duration
+	// Current is the time spent in the current visit if the state is the current state, which is not counted by
+	// Dwell and Total until the state is left.
+	Current time.Duration
+}
+
+// Stats is the statistics of an FSM. See `FSM.Stats`.
+type Stats struct {
+	// Transitions are sorted by from state id, event id and to state id.
+	Transitions []TransitionStats
+	// Events are sorted by event id.
+	Events []EventStats
+	// States are sorted by state id, only the entered states are listed.
+	States []StateStats
+}
+
+// Stats returns the statistics of the transitions, the events and the states since the FSM is created or
+// `ResetStats`. The transitions are identified by (from, event, to), where `from` is the state declaring the
+// transition, which may be a composite state. The `Replay`ed events are not counted.
+//
+// The states are the `CurrentState`s entered by `NewFSM`, `Start`, the transitions, and the restorations, e.g.,
+// `Rollback`, `CompensateTo`, an aborted `Transaction` and `Restore`, so the states where the machine stalls can be
+// found by their dwell time. The composite states containing them, and the states of the regions of
+// parallel states are not counted. The time is measured by the clock of the FSM, see `SetClock`.
+// NOTE: It can be invoked concurrently with `ProcessEvent`.
+func (fsm *FSM) Stats() Stats {
+	return fsm.stats.snapshot(fsm.states, fsm.clock.Now())
+}
+
+// ResetStats clears the statistics. The current visit of the current state is kept. It can be invoked
+// concurrently with `ProcessEvent`.
+func (fsm *FSM) ResetStats() {
+	fsm.stats.reset()
+}
+
+type edgeKey struct {
+	from, event, to string
+}
+
+// subBucketBits is the number of bits after the most significant bit used to split a power of 2 bucket.
+const subBucketBits = 2
+
+// histogram aggregates durations in log-linear buckets, so that recording is cheap and needs no allocation.
+type histogram struct {
+	errors  uint64
+	count   uint64
+	sum     time.Duration
+	min     time.Duration
+	max     time.Duration
+	buckets [64 << subBucketBits]uint64
+}
+
+func bucketOf(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	v := uint64(d)
+	n := bits.Len64(v)
+	if n <= subBucketBits+1 {
+		return int(v)
+	}
+	shift := n - 1 - subBucketBits
+	return (shift+1)<<subBucketBits | int(v>>shift)&(1<<subBucketBits-1)
+}
+
+// bucketUpperBound returns the max duration in the bucket.
+func bucketUpperBound(b int) time.Duration {
+	if b < 2<<subBucketBits {
+		return time.Duration(b)
+	}
+	shift := b>>subBucketBits - 1
+	mantissa := uint64(1<<subBucketBits | b&(1<<subBucketBits-1))
+	return time.Duration((mantissa+1)<<shift - 1)
+}
+
+func (h *histogram) record(d time.Duration, err error) {
+	if err != nil {
+		h.errors++
+	}
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.count++
+	h.sum += d
+	h.buckets[bucketOf(d)]++
+}
+
+func (h *histogram) latency() LatencyStats {
+	if h.count == 0 {
+		return LatencyStats{}
+	}
+	result := LatencyStats{Count: h.count, Min: h.min, Avg: h.sum / time.Duration(h.count), Max: h.max}
+	// the rank of p99 is ceil(count * 0.99).
+	rank, seen := (h.count*99+99)/100, uint64(0)
+	for b, n := range h.buckets {
+		if seen += n; seen >= rank {
+			result.P99 = bucketUpperBound(b)
+			break
+		}
+	}
+	if result.P99 > h.max {
+		result.P99 = h.max
+	}
+	if result.P99 < h.min {
+		result.P99 = h.min
+	}
+	return result
+}
+
+// stateHistogram is the entries and the dwell time of a state.
+type stateHistogram struct {
+	entries uint64
+	dwell   histogram
+}
+
+type statsCollector struct {
+	mu          sync.Mutex
+	transitions map[edgeKey]*histogram
+	events      map[string]*histogram
+	states      map[string]*stateHistogram
+	// current is the current state entered since, empty before the first entry.
+	current string
+	since   time.Time
+}
+
+func (c *statsCollector) recordTransition(from, event, to string, d time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.transitions == nil {
+		c.transitions = make(map[edgeKey]*histogram)
+	}
+	key := edgeKey{from: from, event: event, to: to}
+	h, ok := c.transitions[key]
+	if !ok {
+		h = &histogram{}
+		c.transitions[key] = h
+	}
+	h.record(d, err)
+}
+
+func (c *statsCollector) recordEvent(event string, d time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.events == nil {
+		c.events = make(map[string]*histogram)
+	}
+	h, ok := c.events[event]
+	if !ok {
+		h = &histogram{}
+		c.events[event] = h
+	}
+	h.record(d, err)
+}
+
+// recordEntry finishes the visit of the current state, and enters state at now.
+func (c *statsCollector) recordEntry(state string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enter(state, now)
+}
+
+// restoreEntry enters state at now if it is not the current state, e.g., after `FSM.Rollback`.
+func (c *statsCollector) restoreEntry(state string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if state != c.current {
+		c.enter(state, now)
+	}
+}
+
+// enter is `recordEntry` for the callers holding mu.
+func (c *statsCollector) enter(state string, now time.Time) {
+	if c.states == nil {
+		c.states = make(map[string]*stateHistogram)
+	}
+	if c.current != "" {
+		c.stateOf(c.current).dwell.record(now.Sub(c.since), nil)
+	}
+	c.stateOf(state).entries++
+	c.current, c.since = state, now
+}
+
+// restart restarts the current visit at now, e.g., after the clock is changed.
+func (c *statsCollector) restart(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.current != "" {
+		c.since = now
+	}
+}
+
+func (c *statsCollector) stateOf(state string) *stateHistogram {
+	h, ok := c.states[state]
+	if !ok {
+		h = &stateHistogram{}
+		c.states[state] = h
+	}
+	return h
+}
+
+func (c *statsCollector) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.transitions = nil
+	c.events = nil
+	c.states = nil
+}
+
+// prune drops the statistics of the states which are not in states, e.g., removed by `SwapDefinition`. If the
+// current visit is of a removed state, a visit of current is started.
+func (c *statsCollector) prune(states map[string]State, current string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.transitions {
+		if states[key.from] == nil || states[key.to] == nil {
+			delete(c.transitions, key)
+		}
+	}
+	for state := range c.states {
+		if states[state] == nil {
+			delete(c.states, state)
+		}
+	}
+	if c.current != "" && states[c.current] == nil {
+		c.current, c.since = current, now
+	}
+}
+
+func (c *statsCollector) snapshot(states map[string]State, now time.Time) Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result := Stats{
+		Transitions: make([]TransitionStats, 0, len(c.transitions)),
+		Events:      make([]EventStats, 0, len(c.events)),
+		States:      make([]StateStats, 0, len(c.states)),
+	}
+	for key, h := range c.transitions {
+		result.Transitions = append(result.Transitions, TransitionStats{
+			From:    states[key.from],
+			Event:   key.event,
+			To:      states[key.to],
+			Errors:  h.errors,
+			Latency: h.latency(),
+		})
+	}
+	sort.Slice(result.Transitions, func(i, j int) bool {
+		a, b := result.Transitions[i], result.Transitions[j]
+		if a.From.FSMStateID() != b.From.FSMStateID() {
+			return a.From.FSMStateID() < b.From.FSMStateID()
+		}
+		if a.Event != b.Event {
+			return a.Event < b.Event
+		}
+		return a.To.FSMStateID() < b.To.FSMStateID()
+	})
+	for event, h := range c.events {
+		result.Events = append(result.Events, EventStats{Event: event, Errors: h.errors, Latency: h.latency()})
+	}
+	sort.Slice(result.Events, func(i, j int) bool {
+		return result.Events[i].Event < result.Events[j].Event
+	})
+	for state, h := range c.states {
+		stats := StateStats{State: states[state], Entries: h.entries, Dwell: h.dwell.latency(), Total: h.dwell.sum}
+		if state == c.current {
+			stats.Current = now.Sub(c.since)
+		}
+		result.States = append(result.States, stats)
+	}
+	if c.current != "" && c.states[c.current] == nil {
+		// the current state entered before `ResetStats`.
+		result.States = append(result.States, StateStats{State: states[c.current], Current: now.Sub(c.since)})
+	}
+	sort.Slice(result.States, func(i, j int) bool {
+		return result.States[i].State.FSMStateID() < result.States[j].State.FSMStateID()
+	})
+	return result
+}
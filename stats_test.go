@@ -0,0 +1,205 @@
+package fsm
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	var (
+		idle    = StringState("idle")
+		running = StringState("running")
+		failing = true
+	)
+	clock := &manualClock{now: time.Unix(0, 0)}
+	fsm := NewFSM(idle, nil)
+	fsm.SetClock(clock)
+	assert.Nil(t, fsm.AddState(running))
+	assert.Nil(t, fsm.AddEvent("start"))
+	assert.Nil(t, fsm.AddEvent("stop"))
+	assert.Nil(t, fsm.AddTransition(idle, "start", running, func(interface{}, Event) error {
+		clock.now = clock.now.Add(time.Millisecond)
+		if failing {
+			return errors.New("failed")
+		}
+		return nil
+	}, nil))
+	assert.Nil(t, fsm.AddTransition(running, "stop", idle, nil, nil))
+	assert.Empty(t, fsm.Stats().Transitions)
+
+	assert.NotNil(t, fsm.ProcessEvent(StringEvent("start")))
+	failing = false
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("start")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("stop")))
+	assert.NotNil(t, fsm.ProcessEvent(StringEvent("stop")))
+
+	stats := fsm.Stats()
+	assert.Len(t, stats.Transitions, 2)
+	start, stop := stats.Transitions[0], stats.Transitions[1]
+	assert.Equal(t, idle, start.From)
+	assert.Equal(t, "start", start.Event)
+	assert.Equal(t, running, start.To)
+	assert.Equal(t, uint64(1), start.Errors)
+	assert.Equal(t, uint64(2), start.Latency.Count)
+	assert.Equal(t, time.Millisecond, start.Latency.Min)
+	assert.LessOrEqual(t, int64(start.Latency.Min), int64(start.Latency.Avg))
+	assert.LessOrEqual(t, int64(start.Latency.Avg), int64(start.Latency.Max))
+	assert.LessOrEqual(t, int64(start.Latency.P99), int64(start.Latency.Max))
+	assert.Equal(t, running, stop.From)
+	assert.Equal(t, uint64(0), stop.Errors)
+	assert.Equal(t, uint64(1), stop.Latency.Count)
+
+	assert.Equal(t, []string{"start", "stop"}, []string{stats.Events[0].Event, stats.Events[1].Event})
+	assert.Equal(t, uint64(2), stats.Events[0].Latency.Count)
+	assert.Equal(t, uint64(1), stats.Events[0].Errors)
+	assert.Equal(t, uint64(2), stats.Events[1].Latency.Count)
+	assert.Equal(t, uint64(1), stats.Events[1].Errors)
+
+	fsm.ResetStats()
+	assert.Empty(t, fsm.Stats().Transitions)
+	assert.Empty(t, fsm.Stats().Events)
+	// the current visit is kept.
+	assert.Equal(t, []StateStats{{State: idle}}, fsm.Stats().States)
+}
+
+func TestStatsReplayNotCounted(t *testing.T) {
+	fsm := NewFSM(StringState("a"), nil)
+	assert.Nil(t, fsm.AddState(StringState("b")))
+	assert.Nil(t, fsm.AddEvent("go"))
+	assert.Nil(t, fsm.AddTransition(StringState("a"), "go", StringState("b"), nil, nil))
+	assert.Nil(t, fsm.Replay([]Event{StringEvent("go")}))
+	assert.Empty(t, fsm.Stats().Transitions)
+	assert.Empty(t, fsm.Stats().Events)
+	// only the initial state entered by NewFSM.
+	if assert.Len(t, fsm.Stats().States, 1) {
+		assert.Equal(t, StringState("a"), fsm.Stats().States[0].State)
+	}
+}
+
+func TestStateStats(t *testing.T) {
+	var (
+		draft     = StringState("draft")
+		reviewing = StringState("reviewing")
+	)
+	clock := &manualClock{now: time.Unix(0, 0)}
+	fsm := NewFSM(draft, nil)
+	fsm.SetClock(clock)
+	assert.Nil(t, fsm.AddState(reviewing))
+	assert.Nil(t, fsm.AddEvent("submit"))
+	assert.Nil(t, fsm.AddEvent("comment"))
+	assert.Nil(t, fsm.AddEvent("reject"))
+	assert.Nil(t, fsm.AddTransition(draft, "submit", reviewing, nil, nil))
+	assert.Nil(t, fsm.AddTransition(reviewing, "comment", reviewing, nil, nil))
+	assert.Nil(t, fsm.AddTransition(reviewing, "reject", draft, nil, nil))
+
+	clock.now = clock.now.Add(time.Second)
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("submit")))
+	clock.now = clock.now.Add(time.Hour)
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("comment")))
+	clock.now = clock.now.Add(time.Hour)
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("reject")))
+	clock.now = clock.now.Add(3 * time.Second)
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("submit")))
+	clock.now = clock.now.Add(time.Minute)
+
+	stats := fsm.Stats().States
+	if !assert.Len(t, stats, 2) {
+		return
+	}
+	assert.Equal(t, draft, stats[0].State)
+	assert.Equal(t, uint64(2), stats[0].Entries)
+	assert.Equal(t, uint64(2), stats[0].Dwell.Count)
+	assert.Equal(t, 4*time.Second, stats[0].Total)
+	assert.Equal(t, 2*time.Second, stats[0].Dwell.Avg)
+	assert.Equal(t, time.Duration(0), stats[0].Current)
+	// the self-transition enters the state again, and the current visit is not finished.
+	assert.Equal(t, reviewing, stats[1].State)
+	assert.Equal(t, uint64(3), stats[1].Entries)
+	assert.Equal(t, 2*time.Hour, stats[1].Total)
+	assert.Equal(t, time.Hour, stats[1].Dwell.Max)
+	assert.Equal(t, time.Minute, stats[1].Current)
+
+	fsm.ResetStats()
+	clock.now = clock.now.Add(time.Minute)
+	assert.Equal(t, []StateStats{{State: reviewing, Current: 2 * time.Minute}}, fsm.Stats().States)
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("reject")))
+	stats = fsm.Stats().States
+	assert.Equal(t, reviewing, stats[1].State)
+	assert.Equal(t, 2*time.Minute, stats[1].Total)
+	assert.Equal(t, uint64(0), stats[1].Entries)
+}
+
+func TestHistogram(t *testing.T) {
+	for _, d := range []time.Duration{0, 1, 7, 8, 15, 16, 1000, time.Second, time.Hour} {
+		b := bucketOf(d)
+		assert.GreaterOrEqual(t, int64(bucketUpperBound(b)), int64(d))
+		assert.LessOrEqual(t, float64(bucketUpperBound(b)), float64(d)*1.25+1)
+		if b > 0 {
+			assert.Less(t, int64(bucketUpperBound(b-1)), int64(d))
+		}
+	}
+
+	h := &histogram{}
+	for i := 1; i <= 100; i++ {
+		h.record(time.Duration(i)*time.Millisecond, nil)
+	}
+	h.record(time.Second, nil)
+	latency := h.latency()
+	assert.Equal(t, uint64(101), latency.Count)
+	assert.Equal(t, time.Millisecond, latency.Min)
+	assert.Equal(t, time.Second, latency.Max)
+	assert.GreaterOrEqual(t, int64(latency.P99), int64(99*time.Millisecond))
+	assert.LessOrEqual(t, int64(latency.P99), int64(125*time.Millisecond))
+}
+
+func TestStateStatsRestored(t *testing.T) {
+	var (
+		a = StringState("a")
+		b = StringState("b")
+	)
+	clock := &manualClock{now: time.Unix(0, 0)}
+	fsm := NewFSM(a, nil)
+	fsm.SetClock(clock)
+	fsm.SetRollbackLimit(1)
+	assert.Nil(t, fsm.AddState(b))
+	assert.Nil(t, fsm.AddEvent("go"))
+	assert.Nil(t, fsm.AddTransition(a, "go", b, nil, nil))
+
+	// the initial state is entered by NewFSM without Start.
+	clock.now = clock.now.Add(time.Second)
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("go")))
+	clock.now = clock.now.Add(time.Hour)
+	assert.Nil(t, fsm.Rollback())
+	clock.now = clock.now.Add(time.Minute)
+	stats := fsm.Stats().States
+	if assert.Len(t, stats, 2) {
+		assert.Equal(t, uint64(2), stats[0].Entries)
+		assert.Equal(t, time.Second, stats[0].Total)
+		assert.Equal(t, time.Minute, stats[0].Current)
+		assert.Equal(t, time.Hour, stats[1].Total)
+		assert.Equal(t, time.Duration(0), stats[1].Current)
+	}
+
+	// the aborted transaction enters the state again.
+	assert.NotNil(t, fsm.Transaction(func(tx *Tx) error {
+		assert.Nil(t, tx.ProcessEvent(StringEvent("go")))
+		clock.now = clock.now.Add(time.Second)
+		return errors.New("abort")
+	}))
+	stats = fsm.Stats().States
+	assert.Equal(t, uint64(3), stats[0].Entries)
+	assert.Equal(t, time.Duration(0), stats[0].Current)
+	assert.Equal(t, time.Hour+time.Second, stats[1].Total)
+
+	assert.Nil(t, fsm.Restore([]State{b}, 0))
+	clock.now = clock.now.Add(time.Minute)
+	stats = fsm.Stats().States
+	assert.Equal(t, time.Duration(0), stats[0].Current)
+	assert.Equal(t, uint64(3), stats[1].Entries)
+	assert.Equal(t, time.Minute, stats[1].Current)
+	// restoring the current state is not an entry.
+	assert.Nil(t, fsm.Restore([]State{b}, 0))
+	assert.Equal(t, uint64(3), fsm.Stats().States[1].Entries)
+}
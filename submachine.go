@@ -0,0 +1,93 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+)
+
+type subMachine struct {
+	machine   *FSM
+	doneEvent string
+}
+
+// SubMachineDoneEvent is processed by the parent FSM when a sub-machine reaches a final state.
+// See `AddSubMachine`.
+type SubMachineDoneEvent struct {
+	ID      string
+	Machine *FSM
+}
+
+func (ev *SubMachineDoneEvent) FSMEventID() string {
+	return ev.ID
+}
+
+// AddSubMachine embeds `sub` as the state `state`, which should be a state without child states.
+//   - When the FSM enters the state, `sub` restarts from its initial state.
+//   - While the FSM is in the state, the events are forwarded to `sub` if `sub.CanFire` returns true.
+//     Otherwise, the events are processed by the transitions of the FSM as usual.
+//   - When `sub` reaches a final state, i.e., a state without any transitions, the FSM processes a
+//     `SubMachineDoneEvent` with id `doneEvent` like `PostInternal`.
+//
+// NOTE: the sub-machine should not be processed by others. The guards of the sub-machine may be invoked twice
+// for one event.
+func (fsm *FSM) AddSubMachine(state State, sub *FSM, doneEvent string) error {
+	if sub == nil || sub == fsm {
+		return errors.New("the sub-machine should be another FSM")
+	}
+	if err := fsm.checkMutable(); err != nil {
+		return err
+	}
+	if !fsm.HasState(state) {
+		return stateNotFound(state)
+	}
+	if !fsm.HasEvent(doneEvent) {
+		return eventNotFound(doneEvent)
+	}
+	if len(fsm.children[state.FSMStateID()]) != 0 {
+		return errors.New(fmt.Sprintf("the composite state %s cannot be a sub-machine", state.FSMStateID()))
+	}
+	if _, ok := fsm.subMachines[state.FSMStateID()]; ok {
+		return AlreadyExists
+	}
+	fsm.subMachines[state.FSMStateID()] = &subMachine{machine: sub, doneEvent: doneEvent}
+	return nil
+}
+
+// SubMachine returns the sub-machine of the state, or nil if the state is not a sub-machine.
+func (fsm *FSM) SubMachine(state State) *FSM {
+	if sub, ok := fsm.subMachines[state.FSMStateID()]; ok {
+		return sub.machine
+	}
+	return nil
+}
+
+// InFinalState returns true if there is no transition from the current states and the composite states
+// containing them.
+func (fsm *FSM) InFinalState() bool {
+	for _, leaf := range fsm.currentLeaves() {
+		if !fsm.isFinal(leaf) {
+			return false
+		}
+	}
+	return true
+}
+
+// isFinal returns true if there is no transition from the state and the composite states containing it.
+func (fsm *FSM) isFinal(state string) bool {
+	for from, ok := state, true; ok; from, ok = fsm.parents[from] {
+		for _, transList := range fsm.transitions[from] {
+			if len(transList) != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// reset moves the FSM to its initial state without invoking any action.
+func (fsm *FSM) reset() {
+	fsm.curStateMu.Lock()
+	defer fsm.curStateMu.Unlock()
+	fsm.setCurState(fsm.initState)
+	fsm.regionStates = make(map[string]string)
+}
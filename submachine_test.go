@@ -0,0 +1,67 @@
+package fsm
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSubMachine(t *testing.T) {
+	var (
+		cart    = StringState("cart")
+		paying  = StringState("paying")
+		shipped = StringState("shipped")
+	)
+	payment := newPaymentFSM(t)
+	fsm := NewFSM(cart, nil)
+	assert.Nil(t, fsm.AddState(paying))
+	assert.Nil(t, fsm.AddState(shipped))
+	for _, ev := range []string{"checkout", "paid", "cancel"} {
+		assert.Nil(t, fsm.AddEvent(ev))
+	}
+	assert.NotNil(t, fsm.AddSubMachine(paying, fsm, "paid"))
+	assert.NotNil(t, fsm.AddSubMachine(paying, payment, "unknown"))
+	assert.Nil(t, fsm.AddSubMachine(paying, payment, "paid"))
+	assert.Equal(t, AlreadyExists, fsm.AddSubMachine(paying, payment, "paid"))
+	assert.Equal(t, payment, fsm.SubMachine(paying))
+
+	var done *SubMachineDoneEvent
+	assert.Nil(t, fsm.AddTransition(cart, "checkout", paying, nil, nil))
+	assert.Nil(t, fsm.AddTransition(paying, "cancel", cart, nil, nil))
+	assert.Nil(t, fsm.AddTransition(paying, "paid", shipped, func(_ interface{}, ev Event) error {
+		done = ev.(*SubMachineDoneEvent)
+		return nil
+	}, nil))
+
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("checkout")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("authorize")))
+	assert.Equal(t, paying, fsm.CurrentState())
+	assert.Equal(t, StringState("authorized"), payment.CurrentState())
+
+	// the events not handled by the sub-machine are processed by the FSM, and the sub-machine restarts when
+	// the state is entered again.
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("cancel")))
+	assert.NotNil(t, fsm.ProcessEvent(StringEvent("authorize")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("checkout")))
+	assert.Equal(t, StringState("pending"), payment.CurrentState())
+
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("authorize")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("capture")))
+	assert.True(t, payment.InFinalState())
+	assert.Equal(t, shipped, fsm.CurrentState())
+	assert.Equal(t, payment, done.Machine)
+}
+
+func TestSubMachineError(t *testing.T) {
+	payment := NewFSM(StringState("pending"), nil)
+	assert.Nil(t, payment.AddState(StringState("authorized")))
+	assert.Nil(t, payment.AddEvent("authorize"))
+	assert.Nil(t, payment.AddTransition(StringState("pending"), "authorize", StringState("authorized"),
+		func(interface{}, Event) error { return errors.New("declined") }, nil))
+
+	fsm := NewFSM(StringState("paying"), nil)
+	assert.Nil(t, fsm.AddEvent("paid"))
+	assert.Nil(t, fsm.AddSubMachine(StringState("paying"), payment, "paid"))
+	assert.EqualError(t, fsm.ProcessEvent(StringEvent("authorize")), "declined")
+	assert.Equal(t, StringState("pending"), payment.CurrentState())
+}
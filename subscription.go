@@ -0,0 +1,112 @@
+package fsm
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultSubscriptionBuffer is the channel buffer size of `Subscribe`.
+const DefaultSubscriptionBuffer = 64
+
+// StateChange is the notification of a succeeded transition. See `Subscribe`.
+type StateChange struct {
+	From  State
+	To    State
+	Event Event
+	Time  time.Time
+	// Dropped is the number of notifications dropped before this one because the subscriber was slow.
+	Dropped int
+}
+
+type subscription struct {
+	ch      chan StateChange
+	dropped int
+}
+
+type subscriptions struct {
+	mu   sync.Mutex
+	subs map[*subscription]struct{}
+}
+
+// Subscribe returns a channel receiving the notifications of state changes, and a function to cancel the
+// subscription, which closes the channel. It can be invoked concurrently with `ProcessEvent`.
+//
+// The notifications are delivered without blocking the event processing. If the channel buffer
+// (`DefaultSubscriptionBuffer`) is full, the notification is dropped, and the number of dropped
+// notifications is reported by the `Dropped` field of the next delivered one.
+func (fsm *FSM) Subscribe() (<-chan StateChange, func()) {
+	return fsm.SubscribeWithBuffer(DefaultSubscriptionBuffer)
+}
+
+// SubscribeWithBuffer is the same as `Subscribe`, but the channel buffer size is `size`.
+func (fsm *FSM) SubscribeWithBuffer(size int) (<-chan StateChange, func()) {
+	sub := &subscription{ch: make(chan StateChange, size)}
+	fsm.subs.mu.Lock()
+	if fsm.subs.subs == nil {
+		fsm.subs.subs = make(map[*subscription]struct{})
+	}
+	fsm.subs.subs[sub] = struct{}{}
+	fsm.subs.mu.Unlock()
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			fsm.subs.mu.Lock()
+			defer fsm.subs.mu.Unlock()
+			delete(fsm.subs.subs, sub)
+			close(sub.ch)
+		})
+	}
+}
+
+func (fsm *FSM) publish(change StateChange) {
+	if fsm.tx != nil {
+		// delivered when the transaction commits.
+		fsm.tx.changes = append(fsm.tx.changes, change)
+		return
+	}
+	fsm.subs.mu.Lock()
+	defer fsm.subs.mu.Unlock()
+	for sub := range fsm.subs.subs {
+		change.Dropped = sub.dropped
+		select {
+		case sub.ch <- change:
+			sub.dropped = 0
+		default:
+			sub.dropped++
+		}
+	}
+}
+
+// WaitForState blocks until the FSM enters the state, or the ctx is done. It returns immediately if the
+// FSM is in the state already, see `IsIn`, so the state can be a composite state entered by any of its children.
+// It returns the ctx error if the ctx is done before.
+func (fsm *FSM) WaitForState(ctx context.Context, state State) error {
+	changes, cancel := fsm.Subscribe()
+	defer cancel()
+	// check after subscribing, so the transition cannot be missed.
+	if fsm.IsIn(state) {
+		return nil
+	}
+	for {
+		select {
+		case change := <-changes:
+			if change.To != nil && fsm.enters(change.To, state) {
+				return nil
+			}
+			if change.Dropped != 0 && fsm.IsIn(state) {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// enters returns true if entering `to` enters `state`, i.e., to is state or inside state.
+func (fsm *FSM) enters(to State, state State) bool {
+	fsm.curStateMu.RLock()
+	defer fsm.curStateMu.RUnlock()
+	return fsm.isDescendant(to.FSMStateID(), state.FSMStateID())
+}
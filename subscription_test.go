@@ -0,0 +1,110 @@
+package fsm
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	var (
+		on  = StringState("on")
+		off = StringState("off")
+	)
+	fsm := NewQueuedFSM(off, nil)
+	defer func() {
+		assert.Nil(t, fsm.Close())
+	}()
+	assert.Nil(t, fsm.AddState(on))
+	assert.Nil(t, fsm.AddEvent("switch"))
+	assert.Nil(t, fsm.AddTransition(off, "switch", on, nil, nil))
+	assert.Nil(t, fsm.AddTransition(on, "switch", off, nil, nil))
+
+	changes, cancel := fsm.Subscribe()
+	slow, cancelSlow := fsm.SubscribeWithBuffer(1)
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, fsm.ProcessEvent(StringEvent("switch")))
+	}
+	cancel()
+	cancel()
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("switch")))
+
+	var received []StateChange
+	for change := range changes {
+		received = append(received, change)
+	}
+	assert.Len(t, received, 3)
+	assert.Equal(t, off, received[0].From)
+	assert.Equal(t, on, received[0].To)
+	assert.Equal(t, StringEvent("switch"), received[0].Event)
+	assert.False(t, received[0].Time.IsZero())
+	assert.Equal(t, off, received[1].To)
+
+	first := <-slow
+	assert.Equal(t, 0, first.Dropped)
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("switch")))
+	second := <-slow
+	assert.Equal(t, 3, second.Dropped)
+	cancelSlow()
+	_, ok := <-slow
+	assert.False(t, ok)
+}
+
+func TestWaitForState(t *testing.T) {
+	var (
+		on  = StringState("on")
+		off = StringState("off")
+	)
+	fsm := NewQueuedFSM(off, nil)
+	defer func() {
+		assert.Nil(t, fsm.Close())
+	}()
+	assert.Nil(t, fsm.AddState(on))
+	assert.Nil(t, fsm.AddEvent("switch"))
+	assert.Nil(t, fsm.AddTransition(off, "switch", on, nil, nil))
+
+	assert.Nil(t, fsm.WaitForState(context.Background(), off))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, fsm.WaitForState(ctx, on))
+
+	done := make(chan error)
+	go func() {
+		done <- fsm.WaitForState(context.Background(), on)
+	}()
+	time.Sleep(time.Millisecond * 10)
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("switch")))
+	assert.Nil(t, <-done)
+}
+
+func TestWaitForStateNotStarted(t *testing.T) {
+	fsm := NewFSM(nil, nil)
+	off := StringState("off")
+	assert.Nil(t, fsm.AddState(off))
+	done := make(chan error)
+	go func() {
+		done <- fsm.WaitForState(context.Background(), off)
+	}()
+	time.Sleep(time.Millisecond * 10)
+	assert.Nil(t, fsm.Start(off))
+	assert.Nil(t, <-done)
+}
+
+func TestWaitForCompositeState(t *testing.T) {
+	fsm := newPlayerFSM(t)
+	done := make(chan error)
+	go func() {
+		done <- fsm.WaitForState(context.Background(), StringState("playing"))
+	}()
+	time.Sleep(time.Millisecond * 10)
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("start")))
+	assert.Nil(t, <-done)
+	assert.Equal(t, StringState("video"), fsm.CurrentState())
+	assert.Nil(t, fsm.WaitForState(context.Background(), StringState("active")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, fsm.WaitForState(ctx, StringState("paused")))
+}
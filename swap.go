@@ -0,0 +1,133 @@
+package fsm
+
+// SetHandlerRegistry sets the registry resolving the actions and guards of the definitions swapped in by
+// `SwapDefinition`. The FSMs created by `NewFSMFromDefinition` use the registry they are created with.
+func (fsm *FSM) SetHandlerRegistry(registry *HandlerRegistry) {
+	fsm.handlers = registry
+}
+
+// SwapDefinition replaces the topology of the FSM by newDef, e.g., when its configuration file is changed, while
+// the FSM keeps its payload, current states, `Version`, observers, subscriptions, hooks and statistics.
+//   - migrate maps each of the `CurrentStates` to the state of newDef, the state id is kept if migrate is nil.
+//     The mapped states should be in newDef, and be valid for `Restore`.
+//   - The actions and guards of newDef are resolved by the registry of `SetHandlerRegistry`.
+//   - The order of the transitions follows `SetPriorityOrder`.
+//
+// If anything is invalid, an error is returned and the FSM is not changed. Otherwise, the histories of the
+// composite states and the transitions kept for `Rollback` are dropped, since they are of the old topology. So are
+// the statistics, alarms, rate limits, strict guard orders and event validators of the removed states and events.
+// NOTE: the sub-machines are not part of a `Definition`, so they are removed. A compiled FSM cannot be swapped.
+// NOTE: Like `ProcessEvent`, it is not thread-safe, and should not be invoked in action/guard. All machines of a
+// `Manager` are swapped at once by `Manager.SwapDefinition`.
+func (fsm *FSM) SwapDefinition(newDef Definition, migrate func(oldState string) string) error {
+	if fsm.processEventInvokeCounter != 0 {
+		panic(ShouldNotReEnterPanic)
+	}
+	commit, err := fsm.prepareSwap(newDef, migrate)
+	if err != nil {
+		return err
+	}
+	commit()
+	return nil
+}
+
+// prepareSwap validates the swap of `SwapDefinition`, and returns the function committing it. The FSM should not
+// be changed before the commit.
+func (fsm *FSM) prepareSwap(newDef Definition, migrate func(oldState string) string) (func(), error) {
+	if fsm.compiled != nil {
+		return nil, ErrCompiled
+	}
+	next, err := NewFSMFromDefinition(&newDef, fsm.handlers, fsm.payload)
+	if err != nil {
+		return nil, err
+	}
+	if err := next.SetPriorityOrder(fsm.priorityOrder); err != nil {
+		return nil, err
+	}
+	leaves := fsm.currentLeaves()
+	states := make([]State, 0, len(leaves))
+	for _, leaf := range leaves {
+		if migrate != nil {
+			leaf = migrate(leaf)
+		}
+		states = append(states, StringState(leaf))
+	}
+	if err := next.Restore(states, fsm.Version()); err != nil {
+		return nil, err
+	}
+	return func() {
+		fsm.adopt(next)
+	}, nil
+}
+
+// adopt replaces the topology and the current states of the FSM by the ones of next.
+func (fsm *FSM) adopt(next *FSM) {
+	fsm.curStateMu.Lock()
+	defer fsm.curStateMu.Unlock()
+	fsm.definitionVersion = next.definitionVersion
+	fsm.initState = next.initState
+	fsm.states = next.states
+	fsm.events = next.events
+	fsm.stateIndex = next.stateIndex
+	fsm.stateIDs = next.stateIDs
+	fsm.table = next.table
+	fsm.transitions = next.transitions
+	fsm.transitionSeq = next.transitionSeq
+	fsm.wildcards = next.wildcards
+	fsm.matching = next.matching
+	fsm.parents = next.parents
+	fsm.children = next.children
+	fsm.initialChildren = next.initialChildren
+	fsm.histories = next.histories
+	fsm.parallel = next.parallel
+	fsm.subMachines = next.subMachines
+	fsm.activeChildren = next.activeChildren
+	fsm.activeLeaves = next.activeLeaves
+	fsm.regionStates = next.regionStates
+	fsm.setCurState(next.curState)
+	fsm.candidates = nil
+	fsm.lastRejection = nil
+	fsm.InvalidateGuards()
+	for i := range fsm.undo {
+		fsm.undo[i] = undoEntry{}
+	}
+	fsm.undo = fsm.undo[:0]
+	fsm.pruneRemoved()
+}
+
+// pruneRemoved drops the configurations and the statistics of the states and events removed by `SwapDefinition`,
+// and updates the alarms of the kept states after the current states are migrated.
+func (fsm *FSM) pruneRemoved() {
+	for key := range fsm.rateLimits {
+		if _, ok := fsm.states[key.from]; !ok || !fsm.HasEvent(key.ev) {
+			delete(fsm.rateLimits, key)
+		}
+	}
+	for key := range fsm.strictGuards {
+		if _, ok := fsm.states[key.from]; !ok || !fsm.HasEvent(key.ev) {
+			delete(fsm.strictGuards, key)
+		}
+	}
+	for evId := range fsm.validators {
+		if !fsm.HasEvent(evId) {
+			delete(fsm.validators, evId)
+		}
+	}
+	alarms := fsm.alarms[:0]
+	for _, alarm := range fsm.alarms {
+		if _, ok := fsm.states[alarm.state]; ok {
+			alarms = append(alarms, alarm)
+			continue
+		}
+		alarm.mu.Lock()
+		alarm.closed = true
+		alarm.stop()
+		alarm.mu.Unlock()
+	}
+	for i := len(alarms); i < len(fsm.alarms); i++ {
+		fsm.alarms[i] = nil
+	}
+	fsm.alarms = alarms
+	fsm.checkAlarms()
+	fsm.stats.prune(fsm.states, fsm.curState, fsm.clock.Now())
+}
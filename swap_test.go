@@ -0,0 +1,155 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSwapDefinition(t *testing.T) {
+	counter := 0
+	registry := NewHandlerRegistry().MustRegisterAction("tick", func(interface{}, Event) error {
+		counter++
+		return nil
+	})
+	fsm, err := LoadJSON([]byte(switchDefinitionJSON), NewHandlerRegistry().
+		MustRegisterAction("count", defaultAction).
+		MustRegisterGuard("enabled", defaultGuard))
+	assert.Nil(t, err)
+	fsm.SetRollbackLimit(4)
+	changes, cancel := fsm.Subscribe()
+	defer cancel()
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("switch")))
+	assert.Equal(t, uint64(1), fsm.Version())
+
+	// the light gains a dimmed state, and "on" is renamed to "bright".
+	def := Definition{
+		Version: "2",
+		Initial: "off",
+		States:  []string{"off", "dimmed", "bright"},
+		Events:  []string{"switch"},
+		Transitions: []TransitionDefinition{
+			{From: "off", Event: "switch", To: "dimmed", Action: "tick"},
+			{From: "dimmed", Event: "switch", To: "bright", Action: "tick"},
+			{From: "bright", Event: "switch", To: "off", Action: "tick"},
+		},
+	}
+	rename := func(state string) string {
+		if state == "on" {
+			return "bright"
+		}
+		return state
+	}
+	// the action is not registered.
+	assert.NotNil(t, fsm.SwapDefinition(def, rename))
+	fsm.SetHandlerRegistry(registry)
+	// the mapped state is not found.
+	assert.NotNil(t, fsm.SwapDefinition(def, nil))
+	assert.Equal(t, StringState("on"), fsm.CurrentState())
+	assert.True(t, fsm.HasState(StringState("on")))
+
+	assert.Nil(t, fsm.SwapDefinition(def, rename))
+	assert.Equal(t, StringState("bright"), fsm.CurrentState())
+	assert.Equal(t, uint64(1), fsm.Version())
+	assert.Equal(t, "2", fsm.DefinitionVersion())
+	assert.False(t, fsm.HasState(StringState("on")))
+	index, _ := fsm.StateIndex(StringState("bright"))
+	assert.Equal(t, index, fsm.CurrentStateIndex())
+	// the transitions of the old topology cannot be rolled back.
+	assert.NotNil(t, fsm.Rollback())
+
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("switch")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("switch")))
+	assert.Equal(t, StringState("dimmed"), fsm.CurrentState())
+	assert.Equal(t, 2, counter)
+	assert.Len(t, changes, 3)
+	assert.Equal(t, uint64(3), fsm.Version())
+	assert.ElementsMatch(t, def.Transitions, fsm.Definition().Transitions)
+
+	fsm.Compile()
+	assert.Equal(t, ErrCompiled, fsm.SwapDefinition(def, nil))
+}
+
+func TestSwapDefinitionHierarchy(t *testing.T) {
+	fsm, err := NewFSMFromDefinition(&Definition{
+		Initial: "idle",
+		States:  []string{"idle", "working"},
+		Events:  []string{"start", "stop"},
+		Transitions: []TransitionDefinition{
+			{From: "idle", Event: "start", To: "working"},
+		},
+	}, nil, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("start")))
+
+	// working becomes a composite state.
+	assert.Nil(t, fsm.SwapDefinition(Definition{
+		Initial: "idle",
+		States:  []string{"idle", "working", "loading", "running"},
+		Events:  []string{"start", "loaded", "stop"},
+		Transitions: []TransitionDefinition{
+			{From: "idle", Event: "start", To: "working"},
+			{From: "loading", Event: "loaded", To: "running"},
+			{From: "working", Event: "stop", To: "idle"},
+		},
+		Composites: []CompositeDefinition{{State: "working", Initial: "loading", Children: []string{"loading", "running"}}},
+	}, func(state string) string {
+		if state == "working" {
+			return "loading"
+		}
+		return state
+	}))
+	assert.Equal(t, StringState("loading"), fsm.CurrentState())
+	assert.True(t, fsm.IsIn(StringState("working")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("loaded")))
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("stop")))
+	assert.Equal(t, StringState("idle"), fsm.CurrentState())
+}
+
+func TestSwapDefinitionPrunesRemoved(t *testing.T) {
+	fsm, err := LoadJSON([]byte(switchDefinitionJSON), NewHandlerRegistry().
+		MustRegisterAction("count", defaultAction).
+		MustRegisterGuard("enabled", defaultGuard))
+	assert.Nil(t, err)
+	assert.Nil(t, fsm.AddEvent("break"))
+	assert.Nil(t, fsm.SetEventValidator("break", func(Event) error { return nil }))
+	assert.Nil(t, fsm.SetRateLimit(StringState("on"), "switch", RateLimit{Rate: 1, Burst: 1}))
+	assert.Nil(t, fsm.SetRateLimit(StringState("off"), "switch", RateLimit{Rate: 1, Burst: 1}))
+	fsm.SetStrictGuardOrder(StringState("on"), "switch", true)
+	noop := func(StuckAlarm) {}
+	assert.Nil(t, fsm.AlarmIfStuck(StringState("on"), time.Hour, noop))
+	assert.Nil(t, fsm.AlarmIfStuck(StringState("off"), time.Hour, noop))
+	defer fsm.stopAlarms()
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("switch")))
+	assert.Len(t, fsm.Stats().Transitions, 1)
+
+	// "on" is renamed to "bright", and "break" is removed.
+	assert.Nil(t, fsm.SwapDefinition(Definition{
+		Initial: "off",
+		States:  []string{"off", "bright"},
+		Events:  []string{"switch"},
+		Transitions: []TransitionDefinition{
+			{From: "off", Event: "switch", To: "bright"},
+			{From: "bright", Event: "switch", To: "off"},
+		},
+	}, func(state string) string {
+		if state == "on" {
+			return "bright"
+		}
+		return state
+	}))
+	stats := fsm.Stats()
+	assert.Empty(t, stats.Transitions)
+	if assert.Len(t, stats.States, 2) {
+		assert.Equal(t, StringState("bright"), stats.States[0].State)
+		assert.Equal(t, StringState("off"), stats.States[1].State)
+	}
+	assert.Len(t, fsm.rateLimits, 1)
+	assert.Empty(t, fsm.strictGuards)
+	assert.Empty(t, fsm.validators)
+	assert.Len(t, fsm.alarms, 1)
+	assert.Equal(t, "off", fsm.alarms[0].state)
+
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("switch")))
+	assert.Len(t, fsm.Stats().Transitions, 1)
+}
@@ -0,0 +1,156 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// T is a row of a transition table, see `FromTable`. Action and Guard are the names in a `HandlerRegistry`, and
+// they are optional.
+type T struct {
+	From   State
+	Event  string
+	To     State
+	Action string
+	Guard  string
+	// Name is the `TransitionMetadata.Name`.
+	Name string
+}
+
+// FromTable creates a FSM from a transition table, instead of the sequential `AddState`, `AddEvent` and
+// `AddTransition` calls. The table is either a `[]T`:
+//
+//	light, err := fsm.FromTable([]fsm.T{
+//		{From: Off, Event: "switch", To: On, Action: "turnOn"},
+//		{From: On, Event: "switch", To: Off},
+//	}, registry)
+//
+// or a struct, or a pointer to a struct, whose fields are annotated by the `fsm` tags. The states are created as
+// `StringState`, and the names of the fields are the names of the transitions. The fields without tags are
+// skipped, so the types of the fields do not matter:
+//
+//	type light struct {
+//		TurnOn  struct{} `fsm:"from=off,event=switch,to=on,action=turnOn"`
+//		TurnOff struct{} `fsm:"from=on,event=switch,to=off"`
+//	}
+//	machine, err := fsm.FromTable(light{}, registry)
+//
+// The From of the first row is the initial state, the other states and the events are added in the order they
+// appear. The transitions of the same state and event are evaluated in the order of the rows. The registry can be
+// nil if no action or guard is referenced. Like `LoadJSON`, the payload of the FSM is nil.
+func FromTable(table interface{}, registry *HandlerRegistry) (*FSM, error) {
+	rows, ok := table.([]T)
+	if !ok {
+		var err error
+		if rows, err = tableOfStruct(table); err != nil {
+			return nil, err
+		}
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("the transition table should not be empty")
+	}
+	for i, row := range rows {
+		if row.From == nil || row.To == nil {
+			return nil, errors.New(fmt.Sprintf("the states of row %d should not be nil", i))
+		}
+	}
+	if registry == nil {
+		registry = NewHandlerRegistry()
+	}
+	fsm := NewFSM(rows[0].From, nil)
+	fsm.handlers = registry
+	for _, row := range rows {
+		for _, state := range []State{row.From, row.To} {
+			if !fsm.HasState(state) {
+				if err := fsm.AddState(state); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if !fsm.HasEvent(row.Event) {
+			if err := fsm.AddEvent(row.Event); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, row := range rows {
+		action, guard, opts, err := transitionHandlers(TransitionDefinition{
+			Action: row.Action,
+			Guard:  row.Guard,
+			Name:   row.Name,
+		}, registry)
+		if err != nil {
+			return nil, err
+		}
+		if err := fsm.AddTransitionWithOptions(row.From, row.Event, row.To, action, guard, opts); err != nil {
+			return nil, err
+		}
+	}
+	return fsm, nil
+}
+
+// tableOfStruct returns the rows of the struct annotated by the `fsm` tags. See `FromTable`.
+func tableOfStruct(table interface{}) ([]T, error) {
+	typ := reflect.TypeOf(table)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, errors.New(fmt.Sprintf("the transition table should be []fsm.T or a struct, not %v", typ))
+	}
+	var rows []T
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag, ok := field.Tag.Lookup("fsm")
+		if !ok || tag == "-" {
+			continue
+		}
+		row, err := parseTableTag(tag)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid tag of field %s: %s", field.Name, err.Error()))
+		}
+		if row.Name == "" {
+			row.Name = field.Name
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseTableTag parses a tag like `from=off,event=switch,to=on,action=turnOn,guard=enabled,name=turn on`.
+func parseTableTag(tag string) (T, error) {
+	var (
+		row      T
+		from, to string
+	)
+	for _, item := range strings.Split(tag, ",") {
+		key, value, ok := strings.Cut(item, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || value == "" {
+			return row, errors.New(fmt.Sprintf("%q should be key=value", item))
+		}
+		switch key {
+		case "from":
+			from = value
+		case "event":
+			row.Event = value
+		case "to":
+			to = value
+		case "action":
+			row.Action = value
+		case "guard":
+			row.Guard = value
+		case "name":
+			row.Name = value
+		default:
+			return row, errors.New(fmt.Sprintf("unknown key %s", key))
+		}
+	}
+	if from == "" || to == "" || row.Event == "" {
+		return row, errors.New("from, event and to are required")
+	}
+	row.From, row.To = StringState(from), StringState(to)
+	return row, nil
+}
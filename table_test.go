@@ -0,0 +1,81 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFromTable(t *testing.T) {
+	var (
+		off = StringState("off")
+		on  = StringState("on")
+	)
+	turnedOn := 0
+	registry := NewHandlerRegistry().
+		MustRegisterAction("turnOn", func(interface{}, Event) error {
+			turnedOn++
+			return nil
+		}).
+		MustRegisterGuard("enabled", defaultGuard)
+	fsm, err := FromTable([]T{
+		{From: off, Event: "switch", To: on, Action: "turnOn", Guard: "enabled", Name: "turn on"},
+		{From: on, Event: "switch", To: off},
+		{From: on, Event: "reset", To: off},
+	}, registry)
+	assert.Nil(t, err)
+	assert.Equal(t, off, fsm.CurrentState())
+	assert.Equal(t, []string{"reset", "switch"}, fsm.Events())
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("switch")))
+	assert.Equal(t, on, fsm.CurrentState())
+	assert.Equal(t, 1, turnedOn)
+	info := fsm.Transitions()[0]
+	assert.Equal(t, "turn on", info.Metadata.Name)
+	assert.Equal(t, "turnOn", info.ActionName)
+	assert.Equal(t, "enabled", info.GuardName)
+
+	_, err = FromTable([]T{{From: off, Event: "switch", To: on, Action: "unknown"}}, registry)
+	assert.NotNil(t, err)
+	_, err = FromTable([]T{{From: off, Event: "switch"}}, nil)
+	assert.NotNil(t, err)
+	_, err = FromTable([]T{}, nil)
+	assert.NotNil(t, err)
+	_, err = FromTable(42, nil)
+	assert.NotNil(t, err)
+}
+
+type lightTable struct {
+	TurnOn  struct{} `fsm:"from=off,event=switch,to=on,action=turnOn"`
+	TurnOff struct{} `fsm:"from=on, event=switch, to=off, name=turn off"`
+	comment string
+	Ignored int `fsm:"-"`
+}
+
+func TestFromTableStruct(t *testing.T) {
+	registry := NewHandlerRegistry().MustRegisterAction("turnOn", defaultAction)
+	for _, table := range []interface{}{lightTable{}, &lightTable{}} {
+		fsm, err := FromTable(table, registry)
+		assert.Nil(t, err)
+		assert.Equal(t, StringState("off"), fsm.CurrentState())
+		transitions := fsm.Transitions()
+		assert.Len(t, transitions, 2)
+		assert.Equal(t, "TurnOn", transitions[0].Metadata.Name)
+		assert.Equal(t, "turnOn", transitions[0].ActionName)
+		assert.Equal(t, "turn off", transitions[1].Metadata.Name)
+	}
+
+	for _, table := range []interface{}{
+		struct{}{},
+		struct {
+			A int `fsm:"from=off,to=on"`
+		}{},
+		struct {
+			A int `fsm:"from=off,event=switch,to=on,color=red"`
+		}{},
+		struct {
+			A int `fsm:"from=off,event,to=on"`
+		}{},
+	} {
+		_, err := FromTable(table, nil)
+		assert.NotNil(t, err)
+	}
+}
@@ -0,0 +1,56 @@
+package templates
+
+import "github.com/reyoung/fsm"
+
+// The states and events of `Approval`.
+const (
+	ApprovalDraft    = "draft"
+	ApprovalPending  = "pending"
+	ApprovalApproved = "approved"
+	ApprovalRejected = "rejected"
+
+	ApprovalSubmit  = "submit"
+	ApprovalApprove = "approve"
+	ApprovalReject  = "reject"
+	ApprovalRevise  = "revise"
+)
+
+// ApprovalOptions are the handler names of `Approval`. The empty names are omitted.
+type ApprovalOptions struct {
+	// SubmittableGuard returns true if the draft can be submitted, e.g., the required fields are filled.
+	SubmittableGuard string
+	// ApprovedGuard returns true if an approval approves the request, e.g., enough approvers approved. Every
+	// approval approves the request if it is empty.
+	ApprovedGuard string
+	// RecordApprovalAction records an approval which does not approve the request yet.
+	RecordApprovalAction string
+	// NotifyAction notifies the requester when the request is approved or rejected.
+	NotifyAction string
+}
+
+// Approval returns the definition of an approval workflow:
+//   - draft: `ApprovalSubmit` submits the request if SubmittableGuard returns true.
+//   - pending: `ApprovalApprove` approves the request if ApprovedGuard returns true, otherwise it is recorded by
+//     RecordApprovalAction. `ApprovalReject` rejects the request.
+//   - rejected: `ApprovalRevise` moves the request back to draft.
+//   - approved is the final state.
+func Approval(opts ApprovalOptions) *fsm.Definition {
+	transitions := []fsm.TransitionDefinition{
+		transition(ApprovalDraft, ApprovalSubmit, ApprovalPending, "", opts.SubmittableGuard),
+		transition(ApprovalPending, ApprovalApprove, ApprovalApproved, opts.NotifyAction, opts.ApprovedGuard),
+	}
+	if opts.ApprovedGuard != "" {
+		transitions = append(transitions, transition(ApprovalPending, ApprovalApprove, ApprovalPending,
+			opts.RecordApprovalAction, ""))
+	}
+	transitions = append(transitions,
+		transition(ApprovalPending, ApprovalReject, ApprovalRejected, opts.NotifyAction, ""),
+		transition(ApprovalRejected, ApprovalRevise, ApprovalDraft, "", ""),
+	)
+	return &fsm.Definition{
+		Initial:     ApprovalDraft,
+		States:      []string{ApprovalDraft, ApprovalPending, ApprovalApproved, ApprovalRejected},
+		Events:      []string{ApprovalSubmit, ApprovalApprove, ApprovalReject, ApprovalRevise},
+		Transitions: transitions,
+	}
+}
@@ -0,0 +1,58 @@
+package templates
+
+import (
+	"github.com/reyoung/fsm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type approvalRequest struct {
+	title     string
+	approvals int
+	notified  []string
+}
+
+func TestApproval(t *testing.T) {
+	registry := fsm.NewHandlerRegistry().
+		MustRegisterGuard("hasTitle", func(payload interface{}, ev fsm.Event) bool {
+			return payload.(*approvalRequest).title != ""
+		}).
+		MustRegisterGuard("secondApproval", func(payload interface{}, ev fsm.Event) bool {
+			return payload.(*approvalRequest).approvals >= 1
+		}).
+		MustRegisterAction("recordApproval", func(payload interface{}, ev fsm.Event) error {
+			payload.(*approvalRequest).approvals++
+			return nil
+		}).
+		MustRegisterAction("notify", func(payload interface{}, ev fsm.Event) error {
+			request := payload.(*approvalRequest)
+			request.notified = append(request.notified, ev.FSMEventID())
+			return nil
+		})
+	def := Approval(ApprovalOptions{SubmittableGuard: "hasTitle", ApprovedGuard: "secondApproval",
+		RecordApprovalAction: "recordApproval", NotifyAction: "notify"})
+	// extend the template.
+	def.States = append(def.States, "archived")
+	def.Events = append(def.Events, "archive")
+	def.Transitions = append(def.Transitions, fsm.TransitionDefinition{From: ApprovalApproved, Event: "archive",
+		To: "archived"})
+	request := &approvalRequest{}
+	machine, err := fsm.NewFSMFromDefinition(def, registry, request)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.NotNil(t, machine.ProcessEvent(fsm.StringEvent(ApprovalSubmit)))
+	request.title = "budget"
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent(ApprovalSubmit)))
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent(ApprovalReject)))
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent(ApprovalRevise)))
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent(ApprovalSubmit)))
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent(ApprovalApprove)))
+	assert.Equal(t, ApprovalPending, machine.CurrentState().FSMStateID())
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent(ApprovalApprove)))
+	assert.Equal(t, ApprovalApproved, machine.CurrentState().FSMStateID())
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent("archive")))
+	assert.Equal(t, "archived", machine.CurrentState().FSMStateID())
+	assert.Equal(t, []string{ApprovalReject, ApprovalApprove}, request.notified)
+}
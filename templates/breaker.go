@@ -0,0 +1,55 @@
+package templates
+
+import "github.com/reyoung/fsm"
+
+// The states and events of `CircuitBreaker`.
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+
+	BreakerSuccess = "success"
+	BreakerFailure = "failure"
+	// BreakerCooldown is fired when the open breaker should try the calls again, e.g., by a timer.
+	BreakerCooldown = "cooldown"
+)
+
+// CircuitBreakerOptions are the handler names of `CircuitBreaker`. The empty names are omitted.
+type CircuitBreakerOptions struct {
+	// TripGuard returns true if a failure in the closed state should open the breaker, e.g., the failures exceed a
+	// threshold. Every failure opens the breaker if it is empty.
+	TripGuard string
+	// RecordFailureAction counts a failure which does not open the breaker.
+	RecordFailureAction string
+	// ResetAction resets the counted failures when the breaker is closed by a success.
+	ResetAction string
+	// OpenAction is invoked when the breaker opens, e.g., to schedule the `BreakerCooldown` event.
+	OpenAction string
+}
+
+// CircuitBreaker returns the definition of a circuit breaker:
+//   - closed: the calls are allowed. A failure opens the breaker if TripGuard returns true, otherwise it is
+//     counted by RecordFailureAction.
+//   - open: the calls are rejected, until `BreakerCooldown` moves it to half_open.
+//   - half_open: a trial call is allowed. A success closes the breaker, a failure opens it again.
+func CircuitBreaker(opts CircuitBreakerOptions) *fsm.Definition {
+	transitions := []fsm.TransitionDefinition{
+		transition(BreakerClosed, BreakerSuccess, BreakerClosed, opts.ResetAction, ""),
+		transition(BreakerClosed, BreakerFailure, BreakerOpen, opts.OpenAction, opts.TripGuard),
+	}
+	if opts.TripGuard != "" {
+		transitions = append(transitions, transition(BreakerClosed, BreakerFailure, BreakerClosed,
+			opts.RecordFailureAction, ""))
+	}
+	transitions = append(transitions,
+		transition(BreakerOpen, BreakerCooldown, BreakerHalfOpen, "", ""),
+		transition(BreakerHalfOpen, BreakerSuccess, BreakerClosed, opts.ResetAction, ""),
+		transition(BreakerHalfOpen, BreakerFailure, BreakerOpen, opts.OpenAction, ""),
+	)
+	return &fsm.Definition{
+		Initial:     BreakerClosed,
+		States:      []string{BreakerClosed, BreakerOpen, BreakerHalfOpen},
+		Events:      []string{BreakerSuccess, BreakerFailure, BreakerCooldown},
+		Transitions: transitions,
+	}
+}
@@ -0,0 +1,60 @@
+package templates
+
+import (
+	"github.com/reyoung/fsm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type breakerCounter struct {
+	failures int
+	opened   int
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	registry := fsm.NewHandlerRegistry().
+		MustRegisterGuard("tooManyFailures", func(payload interface{}, ev fsm.Event) bool {
+			return payload.(*breakerCounter).failures >= 2
+		}).
+		MustRegisterAction("recordFailure", func(payload interface{}, ev fsm.Event) error {
+			payload.(*breakerCounter).failures++
+			return nil
+		}).
+		MustRegisterAction("reset", func(payload interface{}, ev fsm.Event) error {
+			payload.(*breakerCounter).failures = 0
+			return nil
+		}).
+		MustRegisterAction("open", func(payload interface{}, ev fsm.Event) error {
+			payload.(*breakerCounter).opened++
+			return nil
+		})
+	counter := &breakerCounter{}
+	machine, err := fsm.NewFSMFromDefinition(CircuitBreaker(CircuitBreakerOptions{TripGuard: "tooManyFailures",
+		RecordFailureAction: "recordFailure", ResetAction: "reset", OpenAction: "open"}), registry, counter)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	for _, evID := range []string{BreakerFailure, BreakerFailure} {
+		assert.Nil(t, machine.ProcessEvent(fsm.StringEvent(evID)))
+		assert.Equal(t, BreakerClosed, machine.CurrentState().FSMStateID())
+	}
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent(BreakerFailure)))
+	assert.Equal(t, BreakerOpen, machine.CurrentState().FSMStateID())
+	assert.NotNil(t, machine.ProcessEvent(fsm.StringEvent(BreakerSuccess)))
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent(BreakerCooldown)))
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent(BreakerFailure)))
+	assert.Equal(t, BreakerOpen, machine.CurrentState().FSMStateID())
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent(BreakerCooldown)))
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent(BreakerSuccess)))
+	assert.Equal(t, BreakerClosed, machine.CurrentState().FSMStateID())
+	assert.Equal(t, 0, counter.failures)
+	assert.Equal(t, 2, counter.opened)
+
+	// without the guard, every failure opens the breaker.
+	machine, err = fsm.NewFSMFromDefinition(CircuitBreaker(CircuitBreakerOptions{}), nil, nil)
+	if assert.Nil(t, err) {
+		assert.Nil(t, machine.ProcessEvent(fsm.StringEvent(BreakerFailure)))
+		assert.Equal(t, BreakerOpen, machine.CurrentState().FSMStateID())
+	}
+}
@@ -0,0 +1,62 @@
+package templates
+
+import "github.com/reyoung/fsm"
+
+// The states and events of `Connection`.
+const (
+	ConnectionDisconnected = "disconnected"
+	ConnectionConnecting   = "connecting"
+	ConnectionConnected    = "connected"
+	ConnectionClosing      = "closing"
+
+	ConnectionConnect     = "connect"
+	ConnectionEstablished = "established"
+	ConnectionFailed      = "failed"
+	ConnectionLost        = "lost"
+	ConnectionClose       = "close"
+	ConnectionClosed      = "closed"
+)
+
+// ConnectionOptions are the handler names of `Connection`. The empty names are omitted.
+type ConnectionOptions struct {
+	// DialAction starts connecting, whose result is reported by the `ConnectionEstablished` or
+	// `ConnectionFailed` event.
+	DialAction string
+	// ReconnectGuard returns true if a lost connection should be reconnected. The lost connections are not
+	// reconnected if it is empty.
+	ReconnectGuard string
+	// CloseAction starts closing the connection, which reports the `ConnectionClosed` event when it is closed.
+	CloseAction string
+}
+
+// Connection returns the definition of the lifecycle of a connection:
+//   - disconnected: `ConnectionConnect` starts connecting by DialAction.
+//   - connecting: `ConnectionEstablished` connects, `ConnectionFailed` disconnects, `ConnectionClose` aborts.
+//   - connected: `ConnectionLost` reconnects by DialAction if ReconnectGuard returns true, otherwise disconnects.
+//     `ConnectionClose` starts closing by CloseAction.
+//   - closing: `ConnectionClosed` disconnects.
+func Connection(opts ConnectionOptions) *fsm.Definition {
+	transitions := []fsm.TransitionDefinition{
+		transition(ConnectionDisconnected, ConnectionConnect, ConnectionConnecting, opts.DialAction, ""),
+		transition(ConnectionConnecting, ConnectionEstablished, ConnectionConnected, "", ""),
+		transition(ConnectionConnecting, ConnectionFailed, ConnectionDisconnected, "", ""),
+		transition(ConnectionConnecting, ConnectionClose, ConnectionClosing, opts.CloseAction, ""),
+	}
+	if opts.ReconnectGuard != "" {
+		transitions = append(transitions, transition(ConnectionConnected, ConnectionLost, ConnectionConnecting,
+			opts.DialAction, opts.ReconnectGuard))
+	}
+	transitions = append(transitions,
+		transition(ConnectionConnected, ConnectionLost, ConnectionDisconnected, "", ""),
+		transition(ConnectionConnected, ConnectionClose, ConnectionClosing, opts.CloseAction, ""),
+		transition(ConnectionClosing, ConnectionClosed, ConnectionDisconnected, "", ""),
+	)
+	return &fsm.Definition{
+		Initial: ConnectionDisconnected,
+		States: []string{ConnectionDisconnected, ConnectionConnecting, ConnectionConnected,
+			ConnectionClosing},
+		Events: []string{ConnectionConnect, ConnectionEstablished, ConnectionFailed, ConnectionLost,
+			ConnectionClose, ConnectionClosed},
+		Transitions: transitions,
+	}
+}
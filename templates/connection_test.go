@@ -0,0 +1,51 @@
+package templates
+
+import (
+	"github.com/reyoung/fsm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestConnection(t *testing.T) {
+	var dialed, closed int
+	reconnect := true
+	registry := fsm.NewHandlerRegistry().
+		MustRegisterAction("dial", func(interface{}, fsm.Event) error {
+			dialed++
+			return nil
+		}).
+		MustRegisterGuard("reconnect", func(interface{}, fsm.Event) bool {
+			return reconnect
+		}).
+		MustRegisterAction("close", func(interface{}, fsm.Event) error {
+			closed++
+			return nil
+		})
+	machine, err := fsm.NewFSMFromDefinition(Connection(ConnectionOptions{DialAction: "dial",
+		ReconnectGuard: "reconnect", CloseAction: "close"}), registry, nil)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	for _, step := range [][2]string{
+		{ConnectionConnect, ConnectionConnecting},
+		{ConnectionFailed, ConnectionDisconnected},
+		{ConnectionConnect, ConnectionConnecting},
+		{ConnectionEstablished, ConnectionConnected},
+		{ConnectionLost, ConnectionConnecting},
+		{ConnectionEstablished, ConnectionConnected},
+		{ConnectionClose, ConnectionClosing},
+		{ConnectionClosed, ConnectionDisconnected},
+	} {
+		assert.Nil(t, machine.ProcessEvent(fsm.StringEvent(step[0])))
+		assert.Equal(t, step[1], machine.CurrentState().FSMStateID())
+	}
+	assert.Equal(t, 3, dialed)
+	assert.Equal(t, 1, closed)
+
+	reconnect = false
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent(ConnectionConnect)))
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent(ConnectionEstablished)))
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent(ConnectionLost)))
+	assert.Equal(t, ConnectionDisconnected, machine.CurrentState().FSMStateID())
+}
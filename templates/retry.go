@@ -0,0 +1,57 @@
+package templates
+
+import "github.com/reyoung/fsm"
+
+// The states and events of `Retry`.
+const (
+	RetryIdle      = "idle"
+	RetryRunning   = "running"
+	RetryWaiting   = "waiting"
+	RetrySucceeded = "succeeded"
+	RetryFailed    = "failed"
+
+	RetryStart   = "start"
+	RetrySucceed = "succeed"
+	RetryFail    = "fail"
+	// RetryBackoff is fired when the backoff of the waiting attempt elapses, e.g., by a timer.
+	RetryBackoff = "backoff"
+)
+
+// RetryOptions are the handler names of `Retry`. The empty names are omitted.
+type RetryOptions struct {
+	// AttemptAction starts an attempt, whose result is reported by the `RetrySucceed` or `RetryFail` event.
+	AttemptAction string
+	// CanRetryGuard returns true if a failed attempt should be retried, e.g., the attempts are fewer than the
+	// limit. The failures are never retried if it is empty.
+	CanRetryGuard string
+	// ScheduleAction schedules the `RetryBackoff` event after the backoff of the failed attempt, e.g., by
+	// `fsm.ExponentialBackoff`.
+	ScheduleAction string
+}
+
+// Retry returns the definition of an operation retried with backoffs:
+//   - idle: `RetryStart` starts the first attempt.
+//   - running: an attempt is running. `RetrySucceed` completes the operation, `RetryFail` waits for the retry if
+//     CanRetryGuard returns true, otherwise fails the operation.
+//   - waiting: `RetryBackoff` starts the next attempt.
+//   - succeeded and failed are the final states.
+func Retry(opts RetryOptions) *fsm.Definition {
+	transitions := []fsm.TransitionDefinition{
+		transition(RetryIdle, RetryStart, RetryRunning, opts.AttemptAction, ""),
+		transition(RetryRunning, RetrySucceed, RetrySucceeded, "", ""),
+	}
+	if opts.CanRetryGuard != "" {
+		transitions = append(transitions, transition(RetryRunning, RetryFail, RetryWaiting, opts.ScheduleAction,
+			opts.CanRetryGuard))
+	}
+	transitions = append(transitions,
+		transition(RetryRunning, RetryFail, RetryFailed, "", ""),
+		transition(RetryWaiting, RetryBackoff, RetryRunning, opts.AttemptAction, ""),
+	)
+	return &fsm.Definition{
+		Initial:     RetryIdle,
+		States:      []string{RetryIdle, RetryRunning, RetryWaiting, RetrySucceeded, RetryFailed},
+		Events:      []string{RetryStart, RetrySucceed, RetryFail, RetryBackoff},
+		Transitions: transitions,
+	}
+}
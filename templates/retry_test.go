@@ -0,0 +1,51 @@
+package templates
+
+import (
+	"github.com/reyoung/fsm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type retryAttempts struct {
+	attempts  int
+	scheduled int
+}
+
+func TestRetry(t *testing.T) {
+	registry := fsm.NewHandlerRegistry().
+		MustRegisterAction("attempt", func(payload interface{}, ev fsm.Event) error {
+			payload.(*retryAttempts).attempts++
+			return nil
+		}).
+		MustRegisterGuard("canRetry", func(payload interface{}, ev fsm.Event) bool {
+			return payload.(*retryAttempts).attempts < 3
+		}).
+		MustRegisterAction("schedule", func(payload interface{}, ev fsm.Event) error {
+			payload.(*retryAttempts).scheduled++
+			return nil
+		})
+	attempts := &retryAttempts{}
+	machine, err := fsm.NewFSMFromDefinition(Retry(RetryOptions{AttemptAction: "attempt",
+		CanRetryGuard: "canRetry", ScheduleAction: "schedule"}), registry, attempts)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent(RetryStart)))
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, machine.ProcessEvent(fsm.StringEvent(RetryFail)))
+		assert.Equal(t, RetryWaiting, machine.CurrentState().FSMStateID())
+		assert.Nil(t, machine.ProcessEvent(fsm.StringEvent(RetryBackoff)))
+	}
+	assert.Nil(t, machine.ProcessEvent(fsm.StringEvent(RetryFail)))
+	assert.Equal(t, RetryFailed, machine.CurrentState().FSMStateID())
+	assert.Equal(t, 3, attempts.attempts)
+	assert.Equal(t, 2, attempts.scheduled)
+
+	machine, err = fsm.NewFSMFromDefinition(Retry(RetryOptions{}), nil, nil)
+	if assert.Nil(t, err) {
+		assert.Nil(t, machine.ProcessEvent(fsm.StringEvent(RetryStart)))
+		assert.Nil(t, machine.ProcessEvent(fsm.StringEvent(RetrySucceed)))
+		assert.Equal(t, RetrySucceeded, machine.CurrentState().FSMStateID())
+	}
+}
@@ -0,0 +1,19 @@
+// Package templates provides the definitions of the machines of common patterns: `CircuitBreaker`, `Retry`,
+// `Connection` and `Approval`. The actions and guards are referenced by the names in the options, which should be
+// registered in the `fsm.HandlerRegistry` of `fsm.NewFSMFromDefinition`. The returned definitions can be extended
+// by appending states, events and transitions before creating the machines:
+//
+//	def := templates.Approval(templates.ApprovalOptions{ApprovedGuard: "enoughApprovers"})
+//	def.States = append(def.States, "archived")
+//	def.Events = append(def.Events, "archive")
+//	def.Transitions = append(def.Transitions, fsm.TransitionDefinition{
+//		From: templates.ApprovalApproved, Event: "archive", To: "archived"})
+//	machine, err := fsm.NewFSMFromDefinition(def, registry, doc)
+package templates
+
+import "github.com/reyoung/fsm"
+
+// transition returns the definition of a transition with the optional action and guard names.
+func transition(from string, event string, to string, action string, guard string) fsm.TransitionDefinition {
+	return fsm.TransitionDefinition{From: from, Event: event, To: to, Action: action, Guard: guard}
+}
@@ -0,0 +1,158 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTenantQuotaExceeded is returned by `Manager` if a machine is created over the quota of its tenant. See
+// `ManagerOptions.TenantQuota`.
+var ErrTenantQuotaExceeded = errors.New("the tenant exceeds its quota of machines")
+
+type tenantDefinition struct {
+	definition *Definition
+	migrate    func(oldState string) string
+}
+
+// TenantOf returns the tenant of key. See `ManagerOptions.Tenant`.
+func (m *Manager[K]) TenantOf(key K) string {
+	if m.options.Tenant == nil {
+		return ""
+	}
+	return m.options.Tenant(key)
+}
+
+// reserve counts a new machine of the tenant, or returns `ErrTenantQuotaExceeded`.
+func (m *Manager[K]) reserve(tenant string) error {
+	quota := 0
+	if m.options.TenantQuota != nil {
+		quota = m.options.TenantQuota(tenant)
+	}
+	m.tenantMu.Lock()
+	defer m.tenantMu.Unlock()
+	if quota > 0 && m.tenantMachines[tenant] >= quota {
+		return ErrTenantQuotaExceeded
+	}
+	m.tenantMachines[tenant]++
+	return nil
+}
+
+func (m *Manager[K]) release(tenant string) {
+	m.tenantMu.Lock()
+	defer m.tenantMu.Unlock()
+	if m.tenantMachines[tenant]--; m.tenantMachines[tenant] <= 0 {
+		delete(m.tenantMachines, tenant)
+	}
+}
+
+// TenantMachines returns the number of alive machines of the tenant, which is limited by
+// `ManagerOptions.TenantQuota`.
+func (m *Manager[K]) TenantMachines(tenant string) int {
+	m.tenantMu.Lock()
+	defer m.tenantMu.Unlock()
+	return m.tenantMachines[tenant]
+}
+
+// TenantKeys returns the keys of the alive machines of the tenant, in no particular order.
+func (m *Manager[K]) TenantKeys(tenant string) []K {
+	var keys []K
+	for _, s := range m.shards {
+		_ = m.do(s, func(s *managerShard[K]) error {
+			for key, machine := range s.machines {
+				if machine.tenant == tenant {
+					keys = append(keys, key)
+				}
+			}
+			return nil
+		})
+	}
+	return keys
+}
+
+// DrainTenant passivates and evicts all alive machines of the tenant, e.g., when the tenant is offboarded or
+// moved to another host, and returns the number of evicted machines. The machines failed to be passivated are
+// kept, and the first error of OnPassivate is returned after the other machines are evicted.
+// NOTE: the later events of the tenant create the machines again, they should be stopped by the caller.
+func (m *Manager[K]) DrainTenant(tenant string) (int, error) {
+	evicted := 0
+	var firstErr error
+	for _, s := range m.shards {
+		err := m.do(s, func(s *managerShard[K]) error {
+			for key, machine := range s.machines {
+				if machine.tenant != tenant {
+					continue
+				}
+				if err := m.evict(s, key); err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+					continue
+				}
+				evicted++
+			}
+			return nil
+		})
+		if err != nil {
+			return evicted, err
+		}
+	}
+	return evicted, firstErr
+}
+
+// ExportTenant invokes export with each alive machine of the tenant by the worker of its shard, e.g., to save the
+// snapshots of the machines by the persist package, or to dump their `Definition`. It stops at the first error of
+// export, and returns it. export should not invoke the manager.
+func (m *Manager[K]) ExportTenant(tenant string, export func(key K, fsm *FSM) error) error {
+	for _, s := range m.shards {
+		err := m.do(s, func(s *managerShard[K]) error {
+			for key, machine := range s.machines {
+				if machine.tenant != tenant {
+					continue
+				}
+				if err := export(key, machine.fsm); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SwapTenantDefinition is the same as `SwapDefinition`, but only the machines of the tenant are swapped, so the
+// tenants can upgrade their workflows independently. The definition of the tenant is not changed by the later
+// `SwapDefinition`. The actions and guards of newDef are resolved by `ManagerOptions.TenantRegistry`.
+func (m *Manager[K]) SwapTenantDefinition(tenant string, newDef Definition,
+	migrate func(oldState string) string) error {
+	m.swapMu.Lock()
+	defer m.swapMu.Unlock()
+	resume, err := m.pause()
+	if err != nil {
+		return err
+	}
+	defer resume()
+	var commits []func()
+	for _, s := range m.shards {
+		for key, machine := range s.machines {
+			if machine.tenant != tenant {
+				continue
+			}
+			commit, err := machine.fsm.prepareSwap(newDef, migrate)
+			if err != nil {
+				return errors.New(fmt.Sprintf("failed to swap the definition of machine %v: %s", key, err.Error()))
+			}
+			commits = append(commits, commit)
+		}
+	}
+	for _, commit := range commits {
+		commit()
+	}
+	if m.tenantDefinitions == nil {
+		m.tenantDefinitions = make(map[string]*tenantDefinition)
+	}
+	m.tenantDefinitions[tenant] = &tenantDefinition{definition: &newDef, migrate: migrate}
+	return nil
+}
@@ -0,0 +1,140 @@
+package fsm
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sort"
+	"sync/atomic"
+	"testing"
+)
+
+// the lights below 100 are of the tenant acme, others are of globex.
+func lightTenant(key int) string {
+	if key < 100 {
+		return "acme"
+	}
+	return "globex"
+}
+
+func TestManagerTenants(t *testing.T) {
+	m := NewManager(newManagedLight, ManagerOptions[int]{
+		Shards: 4,
+		Tenant: lightTenant,
+		TenantQuota: func(tenant string) int {
+			if tenant == "acme" {
+				return 3
+			}
+			return 0
+		},
+	})
+	defer m.Close()
+	// the quota is released if the factory fails.
+	assert.Equal(t, "invalid id", m.ProcessEvent(-1, StringEvent("switch")).Error())
+	for _, key := range []int{0, 1, 2, 100, 101, 102, 103} {
+		assert.Nil(t, m.ProcessEvent(key, StringEvent("switch")))
+	}
+	assert.Equal(t, ErrTenantQuotaExceeded, m.ProcessEvent(3, StringEvent("switch")))
+	assert.Equal(t, "acme", m.TenantOf(-1))
+	assert.Equal(t, 3, m.TenantMachines("acme"))
+	assert.Equal(t, map[string]int{"acme": 3, "globex": 4}, m.Stats().Tenants)
+
+	keys := m.TenantKeys("acme")
+	sort.Ints(keys)
+	assert.Equal(t, []int{0, 1, 2}, keys)
+	assert.Empty(t, m.TenantKeys("initech"))
+
+	exported := make(map[int]string)
+	assert.Nil(t, m.ExportTenant("globex", func(key int, fsm *FSM) error {
+		exported[key] = fsm.CurrentState().FSMStateID()
+		return nil
+	}))
+	assert.Equal(t, map[int]string{100: "on", 101: "on", 102: "on", 103: "on"}, exported)
+	errExport := errors.New("export failed")
+	assert.Equal(t, errExport, m.ExportTenant("globex", func(key int, fsm *FSM) error {
+		return errExport
+	}))
+
+	evicted, err := m.Evict(0)
+	assert.True(t, evicted)
+	assert.Nil(t, err)
+	assert.Nil(t, m.ProcessEvent(3, StringEvent("switch")))
+
+	drained, err := m.DrainTenant("acme")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, drained)
+	assert.Equal(t, 0, m.TenantMachines("acme"))
+	assert.Equal(t, map[string]int{"globex": 4}, m.Stats().Tenants)
+}
+
+func TestManagerDrainTenantError(t *testing.T) {
+	errPassivate := errors.New("passivate failed")
+	m := NewManager(newManagedLight, ManagerOptions[int]{
+		Shards: 2,
+		Tenant: lightTenant,
+		OnPassivate: func(key int, fsm *FSM) error {
+			if key == 1 {
+				return errPassivate
+			}
+			return nil
+		},
+	})
+	defer m.Close()
+	for key := 0; key < 4; key++ {
+		assert.Nil(t, m.ProcessEvent(key, StringEvent("switch")))
+	}
+	drained, err := m.DrainTenant("acme")
+	assert.Equal(t, errPassivate, err)
+	assert.Equal(t, 3, drained)
+	assert.Equal(t, []int{1}, m.TenantKeys("acme"))
+}
+
+func TestManagerTenantDefinition(t *testing.T) {
+	var acme, globex int32
+	registries := map[string]*HandlerRegistry{
+		"acme": NewHandlerRegistry().MustRegisterAction("count", func(interface{}, Event) error {
+			atomic.AddInt32(&acme, 1)
+			return nil
+		}),
+		"globex": NewHandlerRegistry().MustRegisterAction("count", func(interface{}, Event) error {
+			atomic.AddInt32(&globex, 1)
+			return nil
+		}),
+	}
+	m := NewManager(newManagedLight, ManagerOptions[int]{
+		Shards:         4,
+		Tenant:         lightTenant,
+		TenantRegistry: func(tenant string) *HandlerRegistry { return registries[tenant] },
+	})
+	defer m.Close()
+	for _, key := range []int{0, 1, 100, 101} {
+		assert.Nil(t, m.ProcessEvent(key, StringEvent("switch")))
+	}
+	counted := Definition{
+		Initial: "off",
+		States:  []string{"off", "on"},
+		Events:  []string{"switch"},
+		Transitions: []TransitionDefinition{
+			{From: "off", Event: "switch", To: "on", Action: "count"},
+			{From: "on", Event: "switch", To: "off", Action: "count"},
+		},
+	}
+	assert.Nil(t, m.SwapTenantDefinition("acme", counted, nil))
+	for _, key := range []int{0, 1, 2, 100, 101} {
+		assert.Nil(t, m.ProcessEvent(key, StringEvent("switch")))
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&acme))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&globex))
+
+	// the tenant keeps its definition after the global swap.
+	uncounted := counted
+	uncounted.Transitions = []TransitionDefinition{
+		{From: "off", Event: "switch", To: "on"},
+		{From: "on", Event: "switch", To: "off", Action: "count"},
+	}
+	assert.Nil(t, m.SwapDefinition(uncounted, nil))
+	for _, key := range []int{0, 3, 100, 100} {
+		assert.Nil(t, m.ProcessEvent(key, StringEvent("switch")))
+	}
+	assert.Equal(t, int32(5), atomic.LoadInt32(&acme))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&globex))
+}
@@ -0,0 +1,52 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrActionTimeout is returned by `ProcessEvent` when the action of a transition runs longer than its
+// `TransitionOptions.ActionTimeout`. The current state is not changed, and the error of the action is dropped.
+var ErrActionTimeout = errors.New("action timeout")
+
+// ActionContext returns the context of the running action. It is the context passed to `ProcessEventContext`,
+// and it has a deadline if the transition has an `ActionTimeout`. The actions should return as soon as the
+// context is done. It carries the services of `SetServices`. It returns `context.Background()` if no action is
+// running.
+func (fsm *FSM) ActionContext() context.Context {
+	fsm.actionCtxMu.Lock()
+	defer fsm.actionCtxMu.Unlock()
+	if fsm.actionCtx == nil {
+		return context.Background()
+	}
+	return fsm.actionCtx
+}
+
+func (fsm *FSM) setActionContext(ctx context.Context) {
+	fsm.actionCtxMu.Lock()
+	defer fsm.actionCtxMu.Unlock()
+	fsm.actionCtx = ctx
+}
+
+// invokeAction invokes the action of t with the context. If t has a timeout, the context is canceled when the
+// deadline exceeds, and the action is waited to return, so it never runs concurrently with the next events.
+func (fsm *FSM) invokeAction(ctx context.Context, t *transition, args ActionHookArgs) error {
+	ctx = fsm.servicesContext(ctx)
+	if t.timeout <= 0 {
+		fsm.setActionContext(ctx)
+		defer fsm.setActionContext(nil)
+		return t.action(args.Payload, args.Event)
+	}
+	ctx, cancel := WithTimeout(ctx, fsm.clock, t.timeout)
+	defer cancel()
+	fsm.setActionContext(ctx)
+	defer fsm.setActionContext(nil)
+	err := t.action(args.Payload, args.Event)
+	if ctx.Err() != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ErrActionTimeout
+		}
+		return ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package fsm
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newTimeoutFSM(t *testing.T, action func(interface{}, Event) error) *FSM {
+	fsm := NewFSM(StringState("idle"), nil)
+	assert.Nil(t, fsm.AddState(StringState("done")))
+	assert.Nil(t, fsm.AddEvent("run"))
+	assert.Nil(t, fsm.AddTransitionWithOptions(StringState("idle"), "run", StringState("done"), action, nil,
+		TransitionOptions{ActionTimeout: 20 * time.Millisecond}))
+	return fsm
+}
+
+func TestActionTimeout(t *testing.T) {
+	var fsm *FSM
+	canceled := make(chan struct{})
+	fsm = newTimeoutFSM(t, func(interface{}, Event) error {
+		ctx := fsm.ActionContext()
+		_, ok := ctx.Deadline()
+		assert.True(t, ok)
+		<-ctx.Done()
+		close(canceled)
+		return nil
+	})
+	assert.Equal(t, ErrActionTimeout, fsm.ProcessEvent(StringEvent("run")))
+	assert.Equal(t, StringState("idle"), fsm.CurrentState())
+	<-canceled
+	assert.Equal(t, context.Background(), fsm.ActionContext())
+}
+
+func TestActionTimeoutWaitsForAction(t *testing.T) {
+	var fsm *FSM
+	counter := 0
+	fsm = newTimeoutFSM(t, func(interface{}, Event) error {
+		<-fsm.ActionContext().Done()
+		counter++
+		fsm.SetResult(counter)
+		fsm.PostInternal(StringEvent("run"))
+		return nil
+	})
+	result, err := fsm.ProcessEventWithResult(StringEvent("run"))
+	assert.Equal(t, ErrActionTimeout, err)
+	assert.Nil(t, result)
+	assert.Equal(t, 1, counter)
+	assert.Equal(t, StringState("idle"), fsm.CurrentState())
+	assert.Equal(t, context.Background(), fsm.ActionContext())
+}
+
+func TestActionTimeoutNotExceeded(t *testing.T) {
+	fsm := newTimeoutFSM(t, func(interface{}, Event) error {
+		return nil
+	})
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("run")))
+	assert.Equal(t, StringState("done"), fsm.CurrentState())
+
+	fsm = newTimeoutFSM(t, func(interface{}, Event) error {
+		panic("boom")
+	})
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = fsm.ProcessEvent(StringEvent("run"))
+	})
+}
+
+func TestActionContextCanceled(t *testing.T) {
+	var timed *FSM
+	timed = newTimeoutFSM(t, func(interface{}, Event) error {
+		<-timed.ActionContext().Done()
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, timed.ProcessEventContext(ctx, StringEvent("run")))
+
+	fsm := NewFSM(StringState("idle"), nil)
+	assert.Nil(t, fsm.AddState(StringState("done")))
+	assert.Nil(t, fsm.AddEvent("run"))
+	type key struct{}
+	assert.Nil(t, fsm.AddTransition(StringState("idle"), "run", StringState("done"), func(interface{}, Event) error {
+		assert.Equal(t, "value", fsm.ActionContext().Value(key{}))
+		return nil
+	}, nil))
+	assert.Nil(t, fsm.ProcessEventContext(context.WithValue(context.Background(), key{}, "value"),
+		StringEvent("run")))
+}
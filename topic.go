@@ -0,0 +1,143 @@
+package fsm
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TopicMessage is a notification published to a topic when a machine enters a state. See `Topics`.
+type TopicMessage struct {
+	Topic string
+	// Publisher is the name of the publishing machine.
+	Publisher string
+	From      State
+	To        State
+	// Event is the event causing the transition.
+	Event Event
+	Time  time.Time
+}
+
+type topicSubscription struct {
+	name     string
+	newEvent func(msg TopicMessage) Event
+}
+
+// Topics is an in-process event bus connecting the machines of a `Router` by named topics:
+//
+//	topics := fsm.NewTopics(router)
+//	// payment publishes "order.paid" when it enters "paid".
+//	topics.PublishOnEnter("payment", payment.FSM, StringState("paid"), "order.paid")
+//	// shipping receives "ship" when "order.paid" is published.
+//	topics.SubscribeEvent("order.paid", "shipping", StringEvent("ship"))
+//
+// The messages are delivered by `Router.SendContext` to the subscribers in the order of subscribing, so they are
+// asynchronous, the loops are detected, and the errors are reported to `Router.SetErrorHandler`. It is
+// thread-safe.
+type Topics struct {
+	router *Router
+	mu     sync.RWMutex
+	subs   map[string][]topicSubscription
+}
+
+func NewTopics(router *Router) *Topics {
+	return &Topics{router: router, subs: make(map[string][]topicSubscription)}
+}
+
+// PublishOnEnter publishes the topic as the name when the machine enters the state, including the self
+// transitions of the state. The message is published after the event is processed successfully, with the context
+// of `ProcessEventContext`, so the events published by the routed events are chained.
+// NOTE: like `AddObserver`, it should not be invoked concurrently with `ProcessEvent`.
+func (t *Topics) PublishOnEnter(name string, machine *FSM, state State, topic string) {
+	machine.AddObserver(&topicPublisher{topics: t, name: name, state: state.FSMStateID(), topic: topic})
+}
+
+// Subscribe delivers the messages of the topic to the machine routed by the name, as the events created by
+// newEvent. The messages published before subscribing are not delivered.
+func (t *Topics) Subscribe(topic string, name string, newEvent func(msg TopicMessage) Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.subs[topic] = append(t.subs[topic], topicSubscription{name: name, newEvent: newEvent})
+}
+
+// SubscribeEvent delivers the event to the machine routed by the name for each message of the topic. See
+// `Subscribe`.
+func (t *Topics) SubscribeEvent(topic string, name string, ev Event) {
+	t.Subscribe(topic, name, func(TopicMessage) Event { return ev })
+}
+
+// Unsubscribe stops delivering the messages of the topic to the name. It returns false if the name does not
+// subscribe the topic.
+func (t *Topics) Unsubscribe(topic string, name string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	subs := t.subs[topic]
+	kept := subs[:0:0]
+	for _, sub := range subs {
+		if sub.name != name {
+			kept = append(kept, sub)
+		}
+	}
+	if len(kept) == len(subs) {
+		return false
+	}
+	if len(kept) == 0 {
+		delete(t.subs, topic)
+	} else {
+		t.subs[topic] = kept
+	}
+	return true
+}
+
+// Publish delivers the message to the subscribers of msg.Topic, it is used to publish the messages which are not
+// bound to the states. The errors of routing, e.g., `ErrRouteNotFound`, are reported to `Router.SetErrorHandler`,
+// and the first one is returned after the message is sent to all subscribers.
+func (t *Topics) Publish(ctx context.Context, msg TopicMessage) error {
+	t.mu.RLock()
+	subs := t.subs[msg.Topic]
+	t.mu.RUnlock()
+	var first error
+	for _, sub := range subs {
+		ev := sub.newEvent(msg)
+		if err := t.router.SendContext(ctx, sub.name, ev); err != nil {
+			t.router.reportError(sub.name, ev, err)
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// topicPublisher publishes the topic after the event entering the state is processed.
+type topicPublisher struct {
+	NopObserver
+	topics *Topics
+	name   string
+	state  string
+	topic  string
+	// entered is the message of the transition entering the state while processing the event.
+	entered *TopicMessage
+}
+
+func (p *topicPublisher) EventStarted(context.Context, *FSM, Event) {
+	p.entered = nil
+}
+
+func (p *topicPublisher) ActionFinished(ctx context.Context, fsm *FSM, args ActionHookArgs, _ time.Duration,
+	err error) {
+	if err == nil && args.ToState.FSMStateID() == p.state {
+		p.entered = &TopicMessage{Topic: p.topic, Publisher: p.name, From: args.FromState, To: args.ToState,
+			Event: args.Event}
+	}
+}
+
+func (p *topicPublisher) EventFinished(ctx context.Context, fsm *FSM, ev Event, err error) {
+	msg := p.entered
+	p.entered = nil
+	if msg == nil || err != nil {
+		return
+	}
+	msg.Time = fsm.clock.Now()
+	_ = p.topics.Publish(ctx, *msg)
+}
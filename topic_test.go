@@ -0,0 +1,99 @@
+package fsm
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+type shipEvent struct {
+	msg TopicMessage
+}
+
+func (shipEvent) FSMEventID() string {
+	return "ship"
+}
+
+func TestTopics(t *testing.T) {
+	router := NewRouter()
+	topics := NewTopics(router)
+	var mu sync.Mutex
+	var errs []error
+	router.SetErrorHandler(func(name string, ev Event, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	})
+
+	payment := NewQueuedFSM(StringState("unpaid"), nil)
+	defer payment.Close()
+	_ = payment.AddState(StringState("paid"))
+	_ = payment.AddEvent("pay")
+	_ = payment.AddEvent("fail")
+	_ = payment.AddTransition(StringState("unpaid"), "pay", StringState("paid"), nil, nil)
+	_ = payment.AddTransition(StringState("unpaid"), "fail", StringState("paid"), func(interface{}, Event) error {
+		return AlreadyExists
+	}, nil)
+	topics.PublishOnEnter("payment", payment.FSM, StringState("paid"), "order.paid")
+
+	var shipped []TopicMessage
+	shipping := NewQueuedFSM(StringState("waiting"), nil)
+	defer shipping.Close()
+	_ = shipping.AddState(StringState("shipped"))
+	_ = shipping.AddEvent("ship")
+	_ = shipping.AddTransition(StringState("waiting"), "ship", StringState("shipped"), func(payload interface{},
+		ev Event) error {
+		shipped = append(shipped, ev.(shipEvent).msg)
+		return nil
+	}, nil)
+	var audited int
+	audit := NewQueuedFSM(StringState("idle"), nil)
+	defer audit.Close()
+	_ = audit.AddEvent("record")
+	_ = audit.AddTransition(StringState("idle"), "record", StringState("idle"), func(interface{}, Event) error {
+		audited++
+		return nil
+	}, nil)
+	assert.Nil(t, router.Add("payment", payment))
+	assert.Nil(t, router.Add("shipping", shipping))
+	assert.Nil(t, router.Add("audit", audit))
+	topics.Subscribe("order.paid", "shipping", func(msg TopicMessage) Event {
+		return shipEvent{msg: msg}
+	})
+	topics.SubscribeEvent("order.paid", "audit", StringEvent("record"))
+	topics.SubscribeEvent("order.paid", "missing", StringEvent("record"))
+	assert.True(t, topics.Unsubscribe("order.paid", "missing"))
+	assert.False(t, topics.Unsubscribe("order.paid", "missing"))
+
+	// the failed transition does not publish.
+	assert.Nil(t, router.Send("payment", StringEvent("fail")))
+	assert.Nil(t, router.Send("payment", StringEvent("pay")))
+	assert.Nil(t, router.Close())
+	assert.Equal(t, "shipped", shipping.CurrentState().FSMStateID())
+	assert.Equal(t, 1, audited)
+	if assert.Len(t, shipped, 1) {
+		assert.Equal(t, "order.paid", shipped[0].Topic)
+		assert.Equal(t, "payment", shipped[0].Publisher)
+		assert.Equal(t, "unpaid", shipped[0].From.FSMStateID())
+		assert.Equal(t, "paid", shipped[0].To.FSMStateID())
+		assert.Equal(t, "pay", shipped[0].Event.FSMEventID())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []error{AlreadyExists}, errs)
+}
+
+func TestTopicsPublish(t *testing.T) {
+	router := NewRouter()
+	topics := NewTopics(router)
+	var reported []string
+	router.SetErrorHandler(func(name string, ev Event, err error) {
+		reported = append(reported, name)
+	})
+	assert.Nil(t, topics.Publish(context.Background(), TopicMessage{Topic: "nobody"}))
+	topics.SubscribeEvent("alarm", "missing", StringEvent("ring"))
+	assert.Equal(t, ErrRouteNotFound, topics.Publish(context.Background(), TopicMessage{Topic: "alarm"}))
+	assert.Equal(t, []string{"missing"}, reported)
+	assert.Nil(t, router.Close())
+}
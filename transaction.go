@@ -0,0 +1,187 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+)
+
+// machineState is the runtime state of an FSM and its sub-machines, which can be restored later.
+type machineState struct {
+	curState       string
+	version        uint64
+	regionStates   map[string]string
+	activeChildren map[string]string
+	activeLeaves   map[string]string
+	subMachines    map[string]*machineState
+}
+
+func copyStrings(m map[string]string) map[string]string {
+	result := make(map[string]string, len(m))
+	for k, v := range m {
+		result[k] = v
+	}
+	return result
+}
+
+// snapshotState returns the current runtime state.
+func (fsm *FSM) snapshotState() *machineState {
+	fsm.curStateMu.RLock()
+	s := &machineState{
+		curState:       fsm.curState,
+		version:        fsm.version,
+		regionStates:   copyStrings(fsm.regionStates),
+		activeChildren: copyStrings(fsm.activeChildren),
+		activeLeaves:   copyStrings(fsm.activeLeaves),
+	}
+	fsm.curStateMu.RUnlock()
+	if len(fsm.subMachines) != 0 {
+		s.subMachines = make(map[string]*machineState, len(fsm.subMachines))
+		for state, sub := range fsm.subMachines {
+			s.subMachines[state] = sub.machine.snapshotState()
+		}
+	}
+	return s
+}
+
+// restoreState moves the FSM back to the runtime state s, without invoking any action or observer. The alarms
+// of the states are started or stopped like transitions, see `AlarmIfStuck`.
+func (fsm *FSM) restoreState(s *machineState) {
+	fsm.curStateMu.Lock()
+	fsm.setCurState(s.curState)
+	fsm.version = s.version
+	fsm.regionStates = copyStrings(s.regionStates)
+	fsm.activeChildren = copyStrings(s.activeChildren)
+	fsm.activeLeaves = copyStrings(s.activeLeaves)
+	fsm.checkAlarms()
+	fsm.curStateMu.Unlock()
+	if !fsm.replaying {
+		fsm.stats.restoreEntry(fsm.curState, fsm.clock.Now())
+	}
+	for state, sub := range s.subMachines {
+		fsm.subMachines[state].machine.restoreState(sub)
+	}
+}
+
+// committedView is the runtime state before the running transaction, which is seen by the readers of the FSM
+// until the transaction ends. See `FSM.Transaction`.
+type committedView struct {
+	curState string
+	version  uint64
+	leaves   []string
+}
+
+// freeze keeps the runtime state seen by the readers of the FSM and its sub-machines unchanged until `unfreeze`.
+func (fsm *FSM) freeze() {
+	fsm.curStateMu.Lock()
+	fsm.committed = &committedView{
+		curState: fsm.curState,
+		version:  fsm.version,
+		leaves:   fsm.currentLeaves(),
+	}
+	fsm.curStateMu.Unlock()
+	for _, sub := range fsm.subMachines {
+		sub.machine.freeze()
+	}
+}
+
+// unfreeze publishes the runtime state to the readers of the FSM and its sub-machines.
+func (fsm *FSM) unfreeze() {
+	fsm.curStateMu.Lock()
+	fsm.committed = nil
+	// refresh the state ref, which is not changed in the transaction.
+	fsm.setCurState(fsm.curState)
+	fsm.curStateMu.Unlock()
+	for _, sub := range fsm.subMachines {
+		sub.machine.unfreeze()
+	}
+}
+
+// Tx processes the events of a transaction. See `FSM.Transaction`.
+type Tx struct {
+	fsm     *FSM
+	changes []StateChange
+	// prepared are the two-phase transitions taken in the transaction, see `AddTwoPhaseTransition`.
+	prepared []preparedTransition
+}
+
+// ProcessEvent is the same as `FSM.ProcessEvent`, but the state change is discarded if the transaction fails.
+func (tx *Tx) ProcessEvent(ev Event) error {
+	return tx.fsm.ProcessEvent(ev)
+}
+
+// ProcessEventContext is the same as `FSM.ProcessEventContext`, but the state change is discarded if the
+// transaction fails.
+func (tx *Tx) ProcessEventContext(ctx context.Context, ev Event) error {
+	return tx.fsm.ProcessEventContext(ctx, ev)
+}
+
+// CurrentState returns the current state in the transaction, including the uncommitted changes.
+func (tx *Tx) CurrentState() State {
+	return tx.fsm.states[tx.fsm.curState]
+}
+
+// CurrentStates is the same as `FSM.CurrentStates`, including the uncommitted changes.
+func (tx *Tx) CurrentStates() []State {
+	return tx.fsm.leafStates(tx.fsm.currentLeaves())
+}
+
+// IsIn is the same as `FSM.IsIn`, including the uncommitted changes.
+func (tx *Tx) IsIn(state State) bool {
+	return tx.fsm.isInLeaves(tx.fsm.currentLeaves(), state)
+}
+
+// Version is the same as `FSM.Version`, including the uncommitted changes.
+func (tx *Tx) Version() uint64 {
+	return tx.fsm.version
+}
+
+// Transaction processes several events as an all-or-nothing operation. If fn returns an error or panics, the
+// FSM, including its sub-machines, the history of composite states, the `Version` and the transitions kept for
+// `Rollback`, is restored to the state before the transaction, and the error is returned.
+//   - The subscribers are notified of the state changes after fn returns nil. The notifications are dropped if
+//     the transaction fails.
+//   - The commits of the two-phase transitions are invoked after fn returns nil, and the aborts are invoked in the
+//     reverse order if the transaction fails. See `AddTwoPhaseTransition`.
+//   - The actions and the observers are invoked as usual. The changes of the payload made by the actions are
+//     not restored, use `Compensate` or restore the payload in fn if needed.
+//
+// The events are applied to the shadow state of tx, which is read by the methods of tx. Until the transaction
+// ends, the readers of the FSM and its sub-machines, i.e., `CurrentState`, `CurrentStates`, `IsIn`, `Version` and
+// `StateRef`, see the committed state before it, including fn, the actions and the observers.
+//
+// NOTE: Transactions cannot be nested, and `Transaction` should not be invoked in action/guard.
+func (fsm *FSM) Transaction(fn func(tx *Tx) error) (err error) {
+	if fsm.tx != nil {
+		return errors.New("the transactions cannot be nested")
+	}
+	if fsm.processEventInvokeCounter != 0 {
+		panic(ShouldNotReEnterPanic)
+	}
+	tx := &Tx{fsm: fsm}
+	before := fsm.snapshotState()
+	undo := append([]undoEntry(nil), fsm.undo...)
+	fsm.tx = tx
+	fsm.freeze()
+	committed := false
+	defer func() {
+		fsm.tx = nil
+		if !committed {
+			fsm.restoreState(before)
+			fsm.unfreeze()
+			fsm.undo = undo
+			fsm.dropFrames(before.version)
+			tx.abort()
+			return
+		}
+		fsm.unfreeze()
+		tx.commit()
+		for _, change := range tx.changes {
+			fsm.publish(change)
+		}
+	}()
+	if err = fn(tx); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
@@ -0,0 +1,117 @@
+package fsm
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTransactionCommit(t *testing.T) {
+	fsm := newTransferFSM(t)
+	changes, cancel := fsm.Subscribe()
+	defer cancel()
+	assert.Nil(t, fsm.Transaction(func(tx *Tx) error {
+		assert.Nil(t, tx.ProcessEvent(StringEvent("reserve")))
+		assert.Equal(t, StringState("reserved"), tx.CurrentState())
+		assert.Len(t, changes, 0)
+		return tx.ProcessEvent(StringEvent("charge"))
+	}))
+	assert.Equal(t, StringState("charged"), fsm.CurrentState())
+	assert.Equal(t, uint64(2), fsm.Version())
+	assert.Equal(t, StringState("reserved"), (<-changes).To)
+	assert.Equal(t, StringState("charged"), (<-changes).To)
+}
+
+func TestTransactionRollback(t *testing.T) {
+	fsm := newTransferFSM(t)
+	changes, cancel := fsm.Subscribe()
+	defer cancel()
+	err := fsm.Transaction(func(tx *Tx) error {
+		assert.Nil(t, tx.ProcessEvent(StringEvent("reserve")))
+		return tx.ProcessEvent(StringEvent("reserve"))
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, StringState("created"), fsm.CurrentState())
+	assert.Equal(t, uint64(0), fsm.Version())
+	assert.Len(t, changes, 0)
+
+	assert.Panics(t, func() {
+		_ = fsm.Transaction(func(tx *Tx) error {
+			assert.Nil(t, tx.ProcessEvent(StringEvent("reserve")))
+			panic("boom")
+		})
+	})
+	assert.Equal(t, StringState("created"), fsm.CurrentState())
+
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("reserve")))
+	assert.Equal(t, StringState("reserved"), (<-changes).To)
+}
+
+func TestTransactionNested(t *testing.T) {
+	fsm := newTransferFSM(t)
+	nestedErr := errors.New("nested")
+	assert.Equal(t, nestedErr, fsm.Transaction(func(tx *Tx) error {
+		if err := fsm.Transaction(func(*Tx) error { return nil }); err != nil {
+			return nestedErr
+		}
+		return nil
+	}))
+}
+
+func TestTransactionRestoresHierarchy(t *testing.T) {
+	fsm := newPlayerFSM(t)
+	before := fsm.CurrentState()
+	assert.NotNil(t, fsm.Transaction(func(tx *Tx) error {
+		for _, evID := range fsm.AvailableEvents() {
+			if err := tx.ProcessEvent(StringEvent(evID)); err == nil {
+				assert.NotEqual(t, before, tx.CurrentState())
+				break
+			}
+		}
+		return errors.New("abort")
+	}))
+	assert.Equal(t, before, fsm.CurrentState())
+
+	payment := newPaymentFSM(t)
+	order := NewFSM(StringState("cart"), nil)
+	assert.Nil(t, order.AddState(StringState("paying")))
+	assert.Nil(t, order.AddEvent("checkout"))
+	assert.Nil(t, order.AddEvent("paid"))
+	assert.Nil(t, order.AddSubMachine(StringState("paying"), payment, "paid"))
+	assert.Nil(t, order.AddTransition(StringState("cart"), "checkout", StringState("paying"), nil, nil))
+	assert.Nil(t, order.ProcessEvent(StringEvent("checkout")))
+	assert.NotNil(t, order.Transaction(func(tx *Tx) error {
+		assert.Nil(t, tx.ProcessEvent(StringEvent("authorize")))
+		assert.Equal(t, StringState("pending"), payment.CurrentState())
+		return errors.New("abort")
+	}))
+	assert.Equal(t, StringState("paying"), order.CurrentState())
+	assert.Equal(t, StringState("pending"), payment.CurrentState())
+}
+
+func TestTransactionShadowState(t *testing.T) {
+	fsm := newTransferFSM(t)
+	assert.Nil(t, fsm.Transaction(func(tx *Tx) error {
+		assert.Nil(t, tx.ProcessEvent(StringEvent("reserve")))
+		assert.Equal(t, StringState("reserved"), tx.CurrentState())
+		assert.Equal(t, uint64(1), tx.Version())
+		assert.True(t, tx.IsIn(StringState("reserved")))
+		assert.Equal(t, StringState("created"), fsm.CurrentState())
+		assert.Equal(t, uint64(0), fsm.Version())
+		assert.True(t, fsm.IsIn(StringState("created")))
+		assert.Equal(t, "created", fsm.StateRef().ID())
+		return nil
+	}))
+	assert.Equal(t, StringState("reserved"), fsm.CurrentState())
+	assert.Equal(t, uint64(1), fsm.Version())
+	assert.Equal(t, "reserved", fsm.StateRef().ID())
+
+	assert.NotNil(t, fsm.Transaction(func(tx *Tx) error {
+		assert.Nil(t, tx.ProcessEvent(StringEvent("charge")))
+		assert.Equal(t, []State{StringState("charged")}, tx.CurrentStates())
+		assert.Equal(t, []State{StringState("reserved")}, fsm.CurrentStates())
+		return errors.New("abort")
+	}))
+	assert.Equal(t, StringState("reserved"), fsm.CurrentState())
+	assert.Equal(t, "reserved", fsm.StateRef().ID())
+}
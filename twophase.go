@@ -0,0 +1,54 @@
+package fsm
+
+// preparedTransition is a two-phase transition taken in a `Transaction`, which is committed or aborted when the
+// transaction completes.
+type preparedTransition struct {
+	t    *transition
+	args ActionHookArgs
+}
+
+// AddTwoPhaseTransition adds a transition whose action is split into two phases, for the external transactional
+// resources which should be committed after the state is changed, e.g., a prepared distributed transaction.
+//   - prepare is invoked before the state is changed, like the action of `AddTransition`. If it fails, the state
+//     is not changed, and commit is not invoked.
+//   - commit is invoked after the state is changed, before the subscribers are notified. It must not fail, so
+//     it does not return an error.
+//
+// In a `Transaction`, commit is deferred until the transaction succeeds. Use `TransitionOptions.Abort` of
+// `AddTransitionWithOptions` to release the prepared resources if the transaction fails.
+func (fsm *FSM) AddTwoPhaseTransition(from State, evId string, to State, prepare func(interface{}, Event) error,
+	commit func(interface{}, Event), guard func(interface{}, Event) bool) error {
+	return fsm.AddTransitionWithOptions(from, evId, to, prepare, guard, TransitionOptions{Commit: commit})
+}
+
+// commit invokes the commit of t, or defers it to the end of the transaction.
+func (fsm *FSM) commit(t *transition, args ActionHookArgs) {
+	if t.commit == nil && t.abort == nil {
+		return
+	}
+	if fsm.tx != nil {
+		fsm.tx.prepared = append(fsm.tx.prepared, preparedTransition{t: t, args: args})
+		return
+	}
+	if t.commit != nil {
+		t.commit(args.Payload, args.Event)
+	}
+}
+
+// commit invokes the commits of the transitions taken in the transaction, in order.
+func (tx *Tx) commit() {
+	for _, p := range tx.prepared {
+		if p.t.commit != nil {
+			p.t.commit(p.args.Payload, p.args.Event)
+		}
+	}
+}
+
+// abort invokes the aborts of the transitions taken in the transaction, in the reverse order.
+func (tx *Tx) abort() {
+	for i := len(tx.prepared) - 1; i >= 0; i-- {
+		if p := tx.prepared[i]; p.t.abort != nil {
+			p.t.abort(p.args.Payload, p.args.Event)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package fsm
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTwoPhaseTransition(t *testing.T) {
+	machine := NewFSM(StringState("open"), nil)
+	_ = machine.AddState(StringState("closed"))
+	_ = machine.AddEvent("close")
+	var log []string
+	prepareErr := errors.New("prepare")
+	fail := true
+	assert.Nil(t, machine.AddTwoPhaseTransition(StringState("open"), "close", StringState("closed"),
+		func(interface{}, Event) error {
+			log = append(log, "prepare in "+machine.CurrentState().FSMStateID())
+			if fail {
+				return prepareErr
+			}
+			return nil
+		}, func(interface{}, Event) {
+			log = append(log, "commit in "+machine.CurrentState().FSMStateID())
+		}, nil))
+	changes, cancel := machine.Subscribe()
+	defer cancel()
+
+	assert.Equal(t, prepareErr, machine.ProcessEvent(StringEvent("close")))
+	assert.Equal(t, "open", machine.CurrentState().FSMStateID())
+	fail = false
+	assert.Nil(t, machine.ProcessEvent(StringEvent("close")))
+	assert.Equal(t, []string{"prepare in open", "prepare in open", "commit in closed"}, log)
+	assert.Equal(t, "closed", (<-changes).To.FSMStateID())
+}
+
+func TestTwoPhaseTransaction(t *testing.T) {
+	machine := NewFSM(StringState("a"), nil)
+	_ = machine.AddState(StringState("b"))
+	_ = machine.AddEvent("next")
+	var log []string
+	for _, states := range [][2]string{{"a", "b"}, {"b", "a"}} {
+		name := states[0] + "->" + states[1]
+		assert.Nil(t, machine.AddTransitionWithOptions(StringState(states[0]), "next", StringState(states[1]), nil,
+			nil, TransitionOptions{
+				Commit: func(interface{}, Event) { log = append(log, "commit "+name) },
+				Abort:  func(interface{}, Event) { log = append(log, "abort "+name) },
+			}))
+	}
+
+	txErr := errors.New("tx")
+	assert.Equal(t, txErr, machine.Transaction(func(tx *Tx) error {
+		assert.Nil(t, tx.ProcessEvent(StringEvent("next")))
+		assert.Nil(t, tx.ProcessEvent(StringEvent("next")))
+		assert.Nil(t, log)
+		return txErr
+	}))
+	assert.Equal(t, []string{"abort b->a", "abort a->b"}, log)
+
+	log = nil
+	assert.Nil(t, machine.Transaction(func(tx *Tx) error {
+		assert.Nil(t, tx.ProcessEvent(StringEvent("next")))
+		assert.Nil(t, log)
+		return nil
+	}))
+	assert.Equal(t, []string{"commit a->b"}, log)
+	assert.Equal(t, "b", machine.CurrentState().FSMStateID())
+}
@@ -0,0 +1,46 @@
+package fsm
+
+import "errors"
+
+// ErrUnknownEvent is returned by `ProcessEvent` for the events which are not added by `AddEvent`, if the
+// `UnknownEventMode` is `UnknownEventError`.
+var ErrUnknownEvent = errors.New("unknown event")
+
+// UnknownEventMode decides how `ProcessEvent` handles the events which are not added by `AddEvent`. See
+// `SetUnknownEventMode`.
+type UnknownEventMode int
+
+const (
+	// UnknownEventNoTransition returns the no transition error, like the events without transitions. It is the
+	// default mode.
+	UnknownEventNoTransition UnknownEventMode = iota
+	// UnknownEventError returns `ErrUnknownEvent`, which tells the unknown events from the rejected ones.
+	UnknownEventError
+	// UnknownEventIgnore ignores the unknown events, `ProcessEvent` returns nil, e.g., for the protocol state
+	// machines which skip the unknown messages and continue.
+	UnknownEventIgnore
+)
+
+// SetUnknownEventMode sets how `ProcessEvent` handles the events which are not added. The unknown events are only
+// checked when no transition is fired, so the events forwarded to the sub-machines do not need to be added to the
+// parent FSM, and the events in the namespaces of wildcard transitions are not unknown, see `AddTransition`.
+//   - With `UnknownEventError`, the unknown events are recorded by `ExplainLastRejection`, and sent to the
+//     dead-letter sink, like the rejected events.
+//   - With `UnknownEventIgnore`, the unknown events are neither recorded nor sent to the dead-letter sink.
+func (fsm *FSM) SetUnknownEventMode(mode UnknownEventMode) {
+	fsm.unknownEventMode = mode
+}
+
+// rejectEvent returns the error of ev for which no transition is fired, according to the `UnknownEventMode`.
+func (fsm *FSM) rejectEvent(ev Event) error {
+	if fsm.unknownEventMode == UnknownEventNoTransition || fsm.HasEvent(ev.FSMEventID()) ||
+		fsm.inWildcardNamespace(ev.FSMEventID()) {
+		return fsm.noTransition(ev)
+	}
+	if fsm.unknownEventMode == UnknownEventIgnore {
+		fsm.candidates = nil
+		return nil
+	}
+	_ = fsm.noTransition(ev)
+	return ErrUnknownEvent
+}
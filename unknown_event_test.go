@@ -0,0 +1,73 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newUnknownEventFSM(mode UnknownEventMode, letters *[]DeadLetter) *FSM {
+	fsm := NewFSM(StringState("idle"), nil)
+	_ = fsm.AddState(StringState("busy"))
+	_ = fsm.AddEvent("start")
+	_ = fsm.AddEvent("stop")
+	_ = fsm.AddTransition(StringState("idle"), "start", StringState("busy"), nil, nil)
+	fsm.SetUnknownEventMode(mode)
+	fsm.SetDeadLetterSink(func(letter DeadLetter) {
+		*letters = append(*letters, letter)
+	})
+	return fsm
+}
+
+func TestUnknownEventNoTransition(t *testing.T) {
+	var letters []DeadLetter
+	fsm := newUnknownEventFSM(UnknownEventNoTransition, &letters)
+	assert.Equal(t, "no transition from state(idle) and event(ping)", fsm.ProcessEvent(StringEvent("ping")).Error())
+	assert.Len(t, letters, 1)
+}
+
+func TestUnknownEventError(t *testing.T) {
+	var letters []DeadLetter
+	fsm := newUnknownEventFSM(UnknownEventError, &letters)
+	assert.Equal(t, ErrUnknownEvent, fsm.ProcessEvent(StringEvent("ping")))
+	assert.Equal(t, StringEvent("ping"), fsm.ExplainLastRejection().Event)
+	assert.Len(t, letters, 1)
+
+	// the added events without transitions are still rejected by the no transition error.
+	err := fsm.ProcessEvent(StringEvent("stop"))
+	assert.NotNil(t, err)
+	assert.NotEqual(t, ErrUnknownEvent, err)
+	assert.Len(t, letters, 2)
+}
+
+func TestUnknownEventIgnore(t *testing.T) {
+	var letters []DeadLetter
+	fsm := newUnknownEventFSM(UnknownEventIgnore, &letters)
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("ping")))
+	assert.Nil(t, fsm.ExplainLastRejection())
+	assert.Len(t, letters, 0)
+	assert.Equal(t, uint64(0), fsm.Version())
+	assert.NotNil(t, fsm.ProcessEvent(StringEvent("stop")))
+
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("start")))
+	assert.Equal(t, StringState("busy"), fsm.CurrentState())
+	assert.Nil(t, fsm.Replay([]Event{StringEvent("ping")}))
+}
+
+func TestUnknownEventOfSubMachine(t *testing.T) {
+	sub := NewFSM(StringState("a"), nil)
+	assert.Nil(t, sub.AddState(StringState("b")))
+	assert.Nil(t, sub.AddEvent("next"))
+	assert.Nil(t, sub.AddTransition(StringState("a"), "next", StringState("b"), nil, nil))
+
+	fsm := NewFSM(StringState("running"), nil)
+	assert.Nil(t, fsm.AddState(StringState("finished")))
+	assert.Nil(t, fsm.AddEvent("done"))
+	assert.Nil(t, fsm.AddTransition(StringState("running"), "done", StringState("finished"), nil, nil))
+	assert.Nil(t, fsm.AddSubMachine(StringState("running"), sub, "done"))
+	fsm.SetUnknownEventMode(UnknownEventError)
+	// "next" is not added to the parent, but it is fired by the sub-machine.
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("next")))
+	assert.Equal(t, StringState("b"), sub.CurrentState())
+	assert.Equal(t, StringState("finished"), fsm.CurrentState())
+	assert.Equal(t, ErrUnknownEvent, fsm.ProcessEvent(StringEvent("next")))
+}
@@ -0,0 +1,55 @@
+package fsm
+
+import "fmt"
+
+// ValidationError is returned by `ProcessEvent` when the validator of the event rejects it. See
+// `SetEventValidator`.
+type ValidationError struct {
+	Event Event
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("event(%s) is invalid: %v", e.Event.FSMEventID(), e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// SetEventValidator sets the validator of the events of evId, e.g., to check the required fields of the payload.
+// The validator is invoked before the transitions are looked up, so the malformed events are rejected with a
+// `ValidationError` before any guard or action sees them, and the state is not changed. A nil validator removes
+// the validator of evId. It returns an error if the event is not added.
+//
+// The events of `Replay` are not validated, because they were accepted when they were processed.
+// NOTE: like other modifications, it should not be invoked concurrently with `ProcessEvent`.
+func (fsm *FSM) SetEventValidator(evId string, validator func(ev Event) error) error {
+	if !fsm.HasEvent(evId) {
+		return eventNotFound(evId)
+	}
+	if validator == nil {
+		delete(fsm.validators, evId)
+		return nil
+	}
+	if fsm.validators == nil {
+		fsm.validators = make(map[string]func(ev Event) error)
+	}
+	fsm.validators[evId] = validator
+	return nil
+}
+
+// validate invokes the validator of the event.
+func (fsm *FSM) validate(ev Event) error {
+	if len(fsm.validators) == 0 || fsm.replaying {
+		return nil
+	}
+	validator, ok := fsm.validators[ev.FSMEventID()]
+	if !ok {
+		return nil
+	}
+	if err := validator(ev); err != nil {
+		return &ValidationError{Event: ev, Err: err}
+	}
+	return nil
+}
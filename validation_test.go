@@ -0,0 +1,45 @@
+package fsm
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEventValidator(t *testing.T) {
+	machine := NewFSM(StringState("open"), nil)
+	_ = machine.AddEvent("deposit")
+	var guarded, deposited int
+	_ = machine.AddTransition(StringState("open"), "deposit", StringState("open"), func(interface{}, Event) error {
+		deposited++
+		return nil
+	}, func(interface{}, Event) bool {
+		guarded++
+		return true
+	})
+	errNotPositive := errors.New("amount should be positive")
+	assert.Equal(t, eventNotFound("withdraw"), machine.SetEventValidator("withdraw", nil))
+	assert.Nil(t, machine.SetEventValidator("deposit", func(ev Event) error {
+		if ev.(*depositEvent).amount <= 0 {
+			return errNotPositive
+		}
+		return nil
+	}))
+
+	err := machine.ProcessEvent(&depositEvent{amount: -1})
+	var validationErr *ValidationError
+	if assert.True(t, errors.As(err, &validationErr)) {
+		assert.Equal(t, &depositEvent{amount: -1}, validationErr.Event)
+		assert.True(t, errors.Is(err, errNotPositive))
+		assert.Equal(t, "event(deposit) is invalid: amount should be positive", err.Error())
+	}
+	assert.Equal(t, 0, guarded)
+	assert.Nil(t, machine.ProcessEvent(&depositEvent{amount: 1}))
+	assert.Equal(t, 1, deposited)
+
+	// the replayed events are not validated.
+	assert.Nil(t, machine.Replay([]Event{&depositEvent{amount: -1}}))
+	assert.Nil(t, machine.SetEventValidator("deposit", nil))
+	assert.Nil(t, machine.ProcessEvent(&depositEvent{amount: -1}))
+	assert.Equal(t, 2, deposited)
+}
@@ -0,0 +1,155 @@
+// Package watch reloads the definition of the machines of a `fsm.Manager` when the JSON or YAML definition file
+// changes, by fsnotify.
+//
+//	w, _ := watch.Watch("order.yaml", manager, watch.Options{Registry: registry})
+//	defer w.Close()
+//
+// The invalid definitions are rejected, and the machines keep the last valid one.
+package watch
+
+import (
+	"github.com/fsnotify/fsnotify"
+	"github.com/reyoung/fsm"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultDebounce is the default `Options.Debounce`.
+const DefaultDebounce = 100 * time.Millisecond
+
+// Swapper swaps the definition of machines, e.g., `*fsm.Manager`.
+type Swapper interface {
+	SwapDefinition(newDef fsm.Definition, migrate func(oldState string) string) error
+}
+
+// Options are the options of `Watch`.
+type Options struct {
+	// Registry resolves the actions and guards, the definition is validated by `fsm.NewFSMFromDefinition` with it
+	// before the swap. It can be nil if no action or guard is referenced.
+	// NOTE: the machines resolve the actions and guards by their own registries, see `fsm.FSM.SetHandlerRegistry`.
+	Registry *fsm.HandlerRegistry
+	// Migrate maps the old states to the new ones, see `fsm.FSM.SwapDefinition`.
+	Migrate func(oldState string) string
+	// Debounce is the quiet period after a change before the file is reloaded, so the file written by several
+	// writes is reloaded once. `DefaultDebounce` by default.
+	Debounce time.Duration
+	// OnReload is invoked after each reload. The err is not nil if the file cannot be read, or the definition is
+	// rejected, in which case the machines are not changed.
+	OnReload func(def *fsm.Definition, err error)
+}
+
+// Watcher watches a definition file. See `Watch`.
+type Watcher struct {
+	path    string
+	swapper Swapper
+	options Options
+	watcher *fsnotify.Watcher
+
+	mu      sync.Mutex
+	current *fsm.Definition
+	exitWG  sync.WaitGroup
+}
+
+// Watch watches the definition file of path, and swaps the definition of swapper when it changes. The directory
+// of the file is watched rather than the file, so the file replaced by renaming, which is how many editors save
+// files, is reloaded as well. The watcher should be closed by `Close`.
+func Watch(path string, swapper Swapper, options Options) (*Watcher, error) {
+	if options.Debounce <= 0 {
+		options.Debounce = DefaultDebounce
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+	w := &Watcher{path: filepath.Clean(path), swapper: swapper, options: options, watcher: watcher}
+	w.exitWG.Add(1)
+	go w.run()
+	return w, nil
+}
+
+func (w *Watcher) run() {
+	defer w.exitWG.Done()
+	var (
+		timer  *time.Timer
+		reload <-chan time.Time
+	)
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for {
+		select {
+		case ev, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(ev.Name) != w.path || !(ev.Has(fsnotify.Create) || ev.Has(fsnotify.Write)) {
+				continue
+			}
+			if timer == nil {
+				timer = time.NewTimer(w.options.Debounce)
+			} else {
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(w.options.Debounce)
+			}
+			reload = timer.C
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			if w.options.OnReload != nil {
+				w.options.OnReload(nil, err)
+			}
+		case <-reload:
+			reload = nil
+			def, err := w.Reload()
+			if w.options.OnReload != nil {
+				w.options.OnReload(def, err)
+			}
+		}
+	}
+}
+
+// Reload reads the definition file and swaps it. It is invoked by the watcher on changes, and can be invoked
+// manually, e.g., on SIGHUP. The definition is returned even if it is rejected.
+func (w *Watcher) Reload() (*fsm.Definition, error) {
+	def, err := fsm.ReadDefinitionFile(w.path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fsm.NewFSMFromDefinition(def, w.options.Registry, nil); err != nil {
+		return def, err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.swapper.SwapDefinition(*def, w.options.Migrate); err != nil {
+		return def, err
+	}
+	w.current = def
+	return def, nil
+}
+
+// Current returns the last definition swapped in, or nil if there is none.
+func (w *Watcher) Current() *fsm.Definition {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+// Close stops watching the file.
+func (w *Watcher) Close() error {
+	err := w.watcher.Close()
+	w.exitWG.Wait()
+	return err
+}
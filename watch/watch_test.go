@@ -0,0 +1,108 @@
+package watch
+
+import (
+	"github.com/reyoung/fsm"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const lightYAML = `
+initial: off
+states: [off, on]
+events: [switch]
+transitions:
+  - {from: off, event: switch, to: on}
+  - {from: on, event: switch, to: off}
+`
+
+const dimmerYAML = `
+initial: off
+states: [off, dimmed, bright]
+events: [switch]
+transitions:
+  - {from: off, event: switch, to: dimmed}
+  - {from: dimmed, event: switch, to: bright}
+  - {from: bright, event: switch, to: off}
+`
+
+// writeFile replaces the file by renaming, like many editors.
+func writeFile(t *testing.T, path string, data string) {
+	tmp := path + ".tmp"
+	assert.Nil(t, os.WriteFile(tmp, []byte(data), 0644))
+	assert.Nil(t, os.Rename(tmp, path))
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "light.yaml")
+	writeFile(t, path, lightYAML)
+	manager := fsm.NewManager(func(int) (*fsm.FSM, error) {
+		def, err := fsm.ReadDefinitionFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return fsm.NewFSMFromDefinition(def, nil, nil)
+	}, fsm.ManagerOptions[int]{Shards: 2})
+	defer manager.Close()
+	assert.Nil(t, manager.ProcessEvent(1, fsm.StringEvent("switch")))
+
+	reloaded := make(chan error, 10)
+	w, err := Watch(path, manager, Options{
+		Migrate: func(state string) string {
+			if state == "on" {
+				return "bright"
+			}
+			return state
+		},
+		Debounce: 10 * time.Millisecond,
+		OnReload: func(def *fsm.Definition, err error) {
+			reloaded <- err
+		},
+	})
+	assert.Nil(t, err)
+	defer w.Close()
+
+	// the invalid definition is rejected.
+	writeFile(t, path, "initial: off\ntransitions:\n  - {from: off, event: switch, to: on}\n")
+	assert.NotNil(t, <-reloaded)
+	assert.Nil(t, w.Current())
+	assert.Equal(t, map[string]int{"on": 1}, manager.Stats().States)
+
+	writeFile(t, path, dimmerYAML)
+	assert.Nil(t, <-reloaded)
+	assert.Equal(t, "dimmed", w.Current().Transitions[0].To)
+	assert.Equal(t, map[string]int{"bright": 1}, manager.Stats().States)
+	assert.Nil(t, manager.ProcessEvent(2, fsm.StringEvent("switch")))
+	assert.Equal(t, map[string]int{"bright": 1, "dimmed": 1}, manager.Stats().States)
+
+	// the machines in the states which cannot be migrated keep the last valid definition.
+	writeFile(t, path, lightYAML)
+	assert.NotNil(t, <-reloaded)
+	assert.Equal(t, "dimmed", w.Current().Transitions[0].To)
+
+	// the other files in the directory are ignored.
+	writeFile(t, filepath.Join(filepath.Dir(path), "other.yaml"), lightYAML)
+	time.Sleep(100 * time.Millisecond)
+	assert.Nil(t, w.Close())
+	assert.Len(t, reloaded, 0)
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "light.json")
+	w, err := Watch(path, fsm.NewFSM(fsm.StringState("off"), nil), Options{})
+	assert.Nil(t, err)
+	defer w.Close()
+	_, err = w.Reload()
+	assert.NotNil(t, err)
+
+	writeFile(t, path, `{"initial": "off", "states": ["off", "on"], "events": ["switch"], "transitions": []}`)
+	def, err := w.Reload()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"off", "on"}, def.States)
+	assert.Equal(t, def, w.Current())
+
+	_, err = Watch(filepath.Join(path, "missing", "light.json"), fsm.NewFSM(fsm.StringState("off"), nil), Options{})
+	assert.NotNil(t, err)
+}
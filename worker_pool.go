@@ -0,0 +1,179 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrQueueClosed is returned by `QueuedFSM.ProcessEvent` of a pooled machine after the machine or its
+// `WorkerPool` is closed, by the events held by an `EventPolicy` when the machine is closed, and by `Router.Send`
+// after the router is closed.
+var ErrQueueClosed = errors.New("the queue is closed")
+
+// workerPoolBatch is the max number of events processed for a machine before the worker switches to the next
+// ready machine, so a busy machine does not starve the others.
+const workerPoolBatch = 16
+
+// WorkerPool processes the events of many `QueuedFSM`s by a fixed number of goroutines, rather than one goroutine
+// per machine, e.g., to host 100k+ per-entity machines. The events of a machine are still processed one by one
+// in order. It is thread-safe.
+//
+//	pool := fsm.NewWorkerPool(runtime.NumCPU())
+//	defer pool.Close()
+//	machine := pool.NewQueuedFSM(initState, payload)
+type WorkerPool struct {
+	mu      sync.Mutex
+	waiting *sync.Cond
+	// ready are the machines having pending events, a machine is in it at most once.
+	ready  []*QueuedFSM
+	closed bool
+	exitWG sync.WaitGroup
+}
+
+// NewWorkerPool creates a pool of `workers` goroutines, 1 if workers is not positive. The pool should be closed
+// by `Close`.
+func NewWorkerPool(workers int) *WorkerPool {
+	if workers <= 0 {
+		workers = 1
+	}
+	p := &WorkerPool{}
+	p.waiting = sync.NewCond(&p.mu)
+	p.exitWG.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// NewQueuedFSM is the same as `fsm.NewQueuedFSM`, but the events are processed by the pool. Closing the machine
+// does not close the pool.
+func (p *WorkerPool) NewQueuedFSM(initState State, payload interface{}) *QueuedFSM {
+	return &QueuedFSM{FSM: NewFSM(initState, payload), pool: p}
+}
+
+func (p *WorkerPool) work() {
+	defer p.exitWG.Done()
+	for {
+		p.mu.Lock()
+		for len(p.ready) == 0 && !p.closed {
+			p.waiting.Wait()
+		}
+		if len(p.ready) == 0 {
+			p.mu.Unlock()
+			return
+		}
+		q := p.ready[0]
+		p.ready[0] = nil
+		p.ready = p.ready[1:]
+		p.mu.Unlock()
+
+		if q.runBatch() {
+			p.requeue(q)
+		}
+	}
+}
+
+// requeue appends the machine having more events to the ready machines. Unlike `schedule`, it is requeued even if
+// the pool is closed, so the events submitted before `Close` are all processed.
+func (p *WorkerPool) requeue(q *QueuedFSM) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ready = append(p.ready, q)
+	p.waiting.Signal()
+}
+
+// schedule appends the machine to the ready machines. It returns false if the pool is closed.
+func (p *WorkerPool) schedule(q *QueuedFSM) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return false
+	}
+	p.ready = append(p.ready, q)
+	p.waiting.Signal()
+	return true
+}
+
+// Close stops the pool after the submitted events are processed, including the events queued behind a batch of
+// their machine. The events submitted later return `ErrQueueClosed`, unless their machine still has the events
+// submitted before to process.
+func (p *WorkerPool) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	p.waiting.Broadcast()
+	p.mu.Unlock()
+	p.exitWG.Wait()
+	return nil
+}
+
+// submit appends the entry to the mailbox of the pooled machine q.
+func (q *QueuedFSM) submit(entry *eventEntry) {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		entry.done <- ErrQueueClosed
+		return
+	}
+	q.mailbox = append(q.mailbox, entry)
+	schedule := !q.scheduled
+	q.scheduled = true
+	q.mu.Unlock()
+	if schedule && !q.pool.schedule(q) {
+		q.failPending(ErrQueueClosed)
+	}
+}
+
+// runBatch processes the events in the mailbox, at most `workerPoolBatch` ones. It returns true if there are
+// more events, and the machine should be scheduled again.
+func (q *QueuedFSM) runBatch() bool {
+	for i := 0; i < workerPoolBatch; i++ {
+		q.mu.Lock()
+		if len(q.mailbox) == 0 {
+			q.scheduled = false
+			q.mu.Unlock()
+			return false
+		}
+		entry := q.mailbox[0]
+		q.mailbox[0] = nil
+		q.mailbox = q.mailbox[1:]
+		q.mu.Unlock()
+		if entry.ev == nil && entry.do == nil {
+			// the machine is closed by `Close`.
+			q.mu.Lock()
+			q.closed = true
+			q.mu.Unlock()
+			q.failPending(ErrQueueClosed)
+			entry.done <- nil
+			return false
+		}
+		entry.process(q.FSM)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.mailbox) == 0 {
+		q.scheduled = false
+		return false
+	}
+	return true
+}
+
+// failPending completes the pending events with err.
+func (q *QueuedFSM) failPending(err error) {
+	q.mu.Lock()
+	pending := q.mailbox
+	q.mailbox = nil
+	q.scheduled = false
+	q.mu.Unlock()
+	for _, entry := range pending {
+		entry.done <- err
+	}
+}
+
+// closePooled closes the pooled machine after its submitted events are processed.
+func (q *QueuedFSM) closePooled() error {
+	entry := getEventEntry(context.Background(), nil)
+	q.submit(entry)
+	_ = entry.wait()
+	return nil
+}
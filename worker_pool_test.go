@@ -0,0 +1,118 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newPooledSwitch(t *testing.T, pool *WorkerPool, counter *int, running *int32) *QueuedFSM {
+	var (
+		on  = StringState("on")
+		off = StringState("off")
+	)
+	fsm := pool.NewQueuedFSM(off, nil)
+	assert.Nil(t, fsm.AddState(on))
+	assert.Nil(t, fsm.AddEvent("switch"))
+	action := func(interface{}, Event) error {
+		// the events of a machine are never processed concurrently.
+		assert.Equal(t, int32(1), atomic.AddInt32(running, 1))
+		*counter++
+		atomic.AddInt32(running, -1)
+		return nil
+	}
+	assert.Nil(t, fsm.AddTransition(off, "switch", on, action, nil))
+	assert.Nil(t, fsm.AddTransition(on, "switch", off, action, nil))
+	return fsm
+}
+
+func TestWorkerPool(t *testing.T) {
+	pool := NewWorkerPool(4)
+	const machines, events = 100, 50
+	counters := make([]int, machines)
+	running := make([]int32, machines)
+	fsms := make([]*QueuedFSM, machines)
+	for i := range fsms {
+		fsms[i] = newPooledSwitch(t, pool, &counters[i], &running[i])
+	}
+
+	var wg sync.WaitGroup
+	for i := range fsms {
+		for j := 0; j < 4; j++ {
+			wg.Add(1)
+			go func(fsm *QueuedFSM) {
+				defer wg.Done()
+				for k := 0; k < events; k++ {
+					assert.Nil(t, fsm.ProcessEvent(StringEvent("switch")))
+				}
+			}(fsms[i])
+		}
+	}
+	wg.Wait()
+	for i := range fsms {
+		assert.Equal(t, 4*events, counters[i])
+		assert.Equal(t, StringState("off"), fsms[i].CurrentState())
+	}
+
+	assert.Nil(t, fsms[0].Close())
+	assert.Nil(t, fsms[0].Close())
+	assert.Equal(t, ErrQueueClosed, fsms[0].ProcessEvent(StringEvent("switch")))
+	assert.Nil(t, fsms[1].ProcessEvent(StringEvent("switch")))
+
+	assert.Nil(t, pool.Close())
+	assert.Equal(t, ErrQueueClosed, fsms[1].ProcessEvent(StringEvent("switch")))
+	assert.Equal(t, 4*events+1, counters[1])
+	assert.Nil(t, fsms[1].Close())
+}
+
+func TestWorkerPoolCloseDrains(t *testing.T) {
+	pool := NewWorkerPool(1)
+	fsm := pool.NewQueuedFSM(StringState("idle"), nil)
+	assert.Nil(t, fsm.AddEvent("tick"))
+	release := make(chan struct{})
+	var processed int32
+	assert.Nil(t, fsm.AddTransition(StringState("idle"), "tick", StringState("idle"),
+		func(interface{}, Event) error {
+			if atomic.AddInt32(&processed, 1) == 1 {
+				<-release
+			}
+			return nil
+		}, nil))
+
+	// more events than a batch are queued behind the blocked first one.
+	const events = 40
+	errs := make(chan error, events)
+	go func() {
+		errs <- fsm.ProcessEvent(StringEvent("tick"))
+	}()
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&processed) == 1 }, time.Second, time.Millisecond)
+	for i := 1; i < events; i++ {
+		go func() {
+			errs <- fsm.ProcessEvent(StringEvent("tick"))
+		}()
+	}
+	assert.Eventually(t, func() bool {
+		fsm.mu.Lock()
+		defer fsm.mu.Unlock()
+		return len(fsm.mailbox) == events-1
+	}, time.Second, time.Millisecond)
+
+	closed := make(chan error)
+	go func() {
+		closed <- pool.Close()
+	}()
+	assert.Eventually(t, func() bool {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		return pool.closed
+	}, time.Second, time.Millisecond)
+	close(release)
+	assert.Nil(t, <-closed)
+	for i := 0; i < events; i++ {
+		assert.Nil(t, <-errs)
+	}
+	assert.Equal(t, int32(events), atomic.LoadInt32(&processed))
+	assert.Equal(t, ErrQueueClosed, fsm.ProcessEvent(StringEvent("tick")))
+}
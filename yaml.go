@@ -0,0 +1,52 @@
+package fsm
+
+import (
+	"encoding/json"
+	"gopkg.in/yaml.v2"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadYAML creates a FSM from a YAML definition. It mirrors `LoadJSON`, the YAML form looks like:
+//
+//	initial: idle
+//	states: [idle, running]
+//	events: [start, stop]
+//	transitions:
+//	  - from: idle
+//	    event: start
+//	    to: running
+//	    action: launch
+//	  - {from: running, event: stop, to: idle}
+func LoadYAML(data []byte, registry *HandlerRegistry) (*FSM, error) {
+	def := &Definition{}
+	if err := yaml.Unmarshal(data, def); err != nil {
+		return nil, err
+	}
+	return NewFSMFromDefinition(def, registry, nil)
+}
+
+// ExportYAML exports the FSM as a YAML definition. See `LoadYAML` for the format.
+func (fsm *FSM) ExportYAML() ([]byte, error) {
+	return yaml.Marshal(fsm.Definition())
+}
+
+// ReadDefinitionFile reads a definition file, it is YAML if its extension is .yaml or .yml, otherwise JSON.
+func ReadDefinitionFile(path string) (*Definition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	def := &Definition{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, def)
+	default:
+		err = json.Unmarshal(data, def)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return def, nil
+}
@@ -0,0 +1,67 @@
+package fsm
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const jobDefinitionYAML = `
+initial: idle
+states: [idle, running]
+events: [start, stop]
+transitions:
+  - from: idle
+    event: start
+    to: running
+    action: launch
+    tags:
+      owner: ops
+  - from: running
+    event: stop
+    to: idle
+`
+
+func TestLoadYAML(t *testing.T) {
+	launched := false
+	registry := NewHandlerRegistry()
+	assert.Nil(t, registry.RegisterAction("launch", func(i interface{}, event Event) error {
+		launched = true
+		return nil
+	}))
+
+	fsm, err := LoadYAML([]byte(jobDefinitionYAML), registry)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"start", "stop"}, fsm.Events())
+	assert.Nil(t, fsm.ProcessEvent(StringEvent("start")))
+	assert.True(t, launched)
+	assert.Equal(t, StringState("running"), fsm.CurrentState())
+	assert.Equal(t, "ops", fsm.TransitionMetadata(StringState("idle"), "start")[0].Tags["owner"])
+
+	exported, err := fsm.ExportYAML()
+	assert.Nil(t, err)
+	reloaded, err := LoadYAML(exported, registry)
+	assert.Nil(t, err)
+	assert.Equal(t, fsm.Definition(), reloaded.Definition())
+}
+
+func TestReadDefinitionFile(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{"job.yaml": jobDefinitionYAML, "switch.json": switchDefinitionJSON} {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0644))
+	}
+	def, err := ReadDefinitionFile(filepath.Join(dir, "job.yaml"))
+	assert.Nil(t, err)
+	assert.Equal(t, "idle", def.Initial)
+	assert.Len(t, def.Transitions, 2)
+	def, err = ReadDefinitionFile(filepath.Join(dir, "switch.json"))
+	assert.Nil(t, err)
+	assert.Equal(t, "off", def.Initial)
+
+	_, err = ReadDefinitionFile(filepath.Join(dir, "missing.json"))
+	assert.NotNil(t, err)
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "bad.json"), []byte("{"), 0644))
+	_, err = ReadDefinitionFile(filepath.Join(dir, "bad.json"))
+	assert.NotNil(t, err)
+}